@@ -1,26 +1,203 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
 	"github.com/jessevdk/go-flags"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/bench"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/debugger"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/lsp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/plugin"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/replay"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/tracing"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/watch"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflowtest"
 	"github.com/mattn/go-isatty"
+	"github.com/samber/lo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+// Option is the options common to the subcommands.
 type Option struct {
-	File   string `short:"f" long:"file" description:"[REQUIRED] Workflow file" required:"true"`
-	Args   string `long:"args" description:"[OPTIONAL] Workflow Arguments (JSON)" required:"false"`
-	Listen string `short:"l" long:"listen" description:"[OPTIONAL] Listen host and port to emulate API" required:"false"`
+	File              []string `short:"f" long:"file" description:"[REQUIRED] Workflow file, directory of workflow files named as the workflow IDs, or URL of workflow file like https://... or gs://BUCKET/OBJECT (repeatable)" required:"true"`
+	SerializeParallel bool     `long:"serialize-parallel" description:"[OPTIONAL] Execute parallel steps sequentially in declaration order" required:"false"`
+	ProjectID         string   `long:"project" description:"[OPTIONAL] Project ID exposed as GOOGLE_CLOUD_PROJECT_ID" default:"emulator-project" required:"false"`
+	ProjectNumber     string   `long:"project-number" description:"[OPTIONAL] Project number exposed as GOOGLE_CLOUD_PROJECT_NUMBER" default:"000000000000" required:"false"`
+	Location          string   `long:"location" description:"[OPTIONAL] Location exposed as GOOGLE_CLOUD_LOCATION" default:"us-central1" required:"false"`
+	WorkflowID        string   `long:"workflow-id" description:"[OPTIONAL] Workflow ID exposed as GOOGLE_CLOUD_WORKFLOW_ID (default: the base name of the workflow file)" required:"false"`
+	Env               []string `long:"env" description:"[OPTIONAL] User-defined environment variable for sys.get_env (KEY=VALUE, repeatable)" required:"false"`
+	EnvFile           string   `long:"env-file" description:"[OPTIONAL] YAML file of user-defined environment variables for sys.get_env" required:"false"`
+	StorageEndpoint   string   `long:"storage-endpoint" description:"[OPTIONAL] Endpoint of the Cloud Storage emulator (e.g. fake-gcs-server) for googleapis.storage.* (default: $STORAGE_EMULATOR_HOST)" required:"false"`
+	Endpoints         []string `long:"connector-endpoint" description:"[OPTIONAL] Endpoint of the local emulator for the connector (SERVICE=ENDPOINT, e.g. pubsub=localhost:8085, repeatable)" required:"false"`
+	Discovery         []string `long:"discovery" description:"[OPTIONAL] Generate the connectors from the Google API discovery document (API:VERSION like bigquery:v2, or a path to the document JSON, repeatable)" required:"false"`
+	Stubs             string   `long:"stubs" description:"[OPTIONAL] YAML file mapping the function names (e.g. googleapis.bigquery.v2.jobs.query) to the canned responses or the local HTTP endpoints" required:"false"`
+	Symbols           []string `long:"symbol" description:"[OPTIONAL] Additional symbol of the standard library by the dotted name and the value in JSON (NAME=JSON, e.g. company.regions=[\"us-central1\"], repeatable)" required:"false"`
+	Extensions        bool     `long:"extensions" description:"[OPTIONAL] Enable the convenience functions of the emulator which Cloud Workflows doesn't have (list.filter, list.map, list.sort, math.floor, math.round, math.pow, time.add and text.join)" required:"false"`
+	Strict            bool     `long:"strict" description:"[OPTIONAL] Check the compatibility with Cloud Workflows by the production limits (the executed steps, the call depth, the size of the variables and the HTTP responses) and raising TypeError for the unexpected arguments (not available with --extensions)" required:"false"`
+	Plugins           string   `long:"plugins" description:"[OPTIONAL] YAML file mapping the function names to the functions of the Starlark scripts or the WASM modules to register them into the standard library" required:"false"`
+	EndpointsFile     string   `long:"connector-endpoints-file" description:"[OPTIONAL] YAML file mapping the connectors (service names or base URLs) to the endpoints of the local emulators" required:"false"`
+	MaxRedirects      int      `long:"max-redirects" description:"[OPTIONAL] Maximum number of the redirects followed by http.* (0 disables following the redirects, and the redirect response beyond the limit is returned)" default:"10" required:"false"`
+	HTTPCAFile        string   `long:"http-ca-file" description:"[OPTIONAL] PEM file of the CA certificates to trust in addition to the system ones for http.*" required:"false"`
+	HTTPInsecure      bool     `long:"http-insecure-skip-verify" description:"[OPTIONAL] Skip verifying the server certificates for http.* (e.g. the local services with the self-signed certificates)" required:"false"`
+	HTTPTimeout       float64  `long:"http-timeout" description:"[OPTIONAL] Default timeout in seconds of http.* when the timeout argument is omitted" default:"300" required:"false"`
+	HTTPProxy         string   `long:"http-proxy" description:"[OPTIONAL] Proxy URL for the outbound HTTP requests (default: $HTTP_PROXY, $HTTPS_PROXY and $NO_PROXY)" required:"false"`
+	HTTPMaxIdleConns  int      `long:"http-max-idle-conns" description:"[OPTIONAL] Maximum number of the idle connections kept for the outbound HTTP requests" default:"100" required:"false"`
+	HTTPMaxResponse   int64    `long:"http-max-response-size" description:"[OPTIONAL] Maximum size in bytes of the HTTP responses, ResourceLimitError is raised beyond it (0 means no limit)" default:"2097152" required:"false"`
+	FakeAuth          bool     `long:"fake-auth" description:"[OPTIONAL] Use the synthetic tokens for auth of OIDC and OAuth2 instead of the application default credentials" required:"false"`
+	FakeAuthClaims    []string `long:"fake-auth-claim" description:"[OPTIONAL] Claim of the synthetic tokens of --fake-auth (KEY=VALUE, e.g. email=sa@example.com, repeatable)" required:"false"`
+	MetadataListen    string   `long:"metadata-listen" description:"[OPTIONAL] Listen host and port to emulate the GCE metadata server, which is used via GCE_METADATA_HOST for the credentials" required:"false"`
+	MetadataAccount   string   `long:"metadata-service-account" description:"[OPTIONAL] Email of the service account served by the metadata server" default:"emulator@emulator-project.iam.gserviceaccount.com" required:"false"`
+	CredentialsFile   string   `long:"credentials-file" env:"WORKFLOW_EMULATOR_CREDENTIALS" description:"[OPTIONAL] Credentials file (e.g. the service account key) for auth of OIDC and OAuth2 instead of the application default credentials" required:"false"`
+	HTTPMocks         string   `long:"http-mocks" description:"[OPTIONAL] YAML file of the mocked responses for the outbound HTTP requests matched by the method and the URL pattern" required:"false"`
+	Record            string   `long:"record" description:"[OPTIONAL] Record the outbound HTTP interactions into the cassette file (JSON)" required:"false"`
+	Replay            string   `long:"replay" description:"[OPTIONAL] Replay the outbound HTTP interactions from the cassette file recorded by --record" required:"false"`
+	Chaos             string   `long:"chaos" description:"[OPTIONAL] YAML file of the rules to inject the faults (status codes or timeouts) and the latencies into the outbound HTTP requests" required:"false"`
+	ChaosSeed         int64    `long:"chaos-seed" description:"[OPTIONAL] Seed of the random faults of --chaos to reproduce them (default: --seed, or random)" required:"false"`
+	TraceEndpoint     string   `long:"trace-json-endpoint" description:"[OPTIONAL] Base URL of the collector (e.g. http://localhost:4318) to POST the spans of the executions, the steps and the outbound HTTP requests to /v1/traces in the JSON encoding of OTLP (a minimal exporter, not the OpenTelemetry SDK)" required:"false"`
+	TraceServiceName  string   `long:"trace-service-name" description:"[OPTIONAL] service.name of the spans exported by --trace-json-endpoint" default:"google-cloud-workflow-emulator" required:"false"`
+	MultiValueHeaders bool     `long:"multi-value-headers" description:"[OPTIONAL] Return all values of the response headers with multiple values (e.g. Set-Cookie) as a list in http.* responses" required:"false"`
+	FakeTime          string   `long:"fake-time" description:"[OPTIONAL] Start each execution at the time (RFC 3339, e.g. 2024-01-01T00:00:00Z) of the fake clock read by sys.now, which is advanced by sys.sleep, sys.sleep_until and the backoffs of the retries without waiting for them" required:"false"`
+	VirtualTime       bool     `long:"virtual-time" description:"[OPTIONAL] Execute by the fake clock starting at --fake-time (default: the current time) which jumps to the earliest deadline of the sleeps and the timeouts of events.await_callback when all branches wait for them, e.g. to test the timeouts instantly" required:"false"`
+	NoSleep           bool     `long:"no-sleep" description:"[OPTIONAL] Skip sys.sleep, sys.sleep_until, the backoffs of the retries and the polling intervals of the connectors" required:"false"`
+	TimeScale         float64  `long:"time-scale" description:"[OPTIONAL] Divide the durations of sys.sleep, sys.sleep_until, the backoffs of the retries and the polling intervals of the connectors by the factor (e.g. 60 makes a minute a second)" required:"false"`
+	Seed              int64    `long:"seed" description:"[OPTIONAL] Seed of the pseudo random numbers of uuid.generate and the execution IDs to reproduce them, each execution starts from the seed (default: random)" required:"false"`
+
+	// the options of the subcommands are accepted without them for the compatibility,
+	// which serves the APIs if --listen or --grpc-listen is given, or runs the workflow otherwise
+	Legacy struct {
+		RunOption
+		ServeOption
+	} `group:"Legacy Options" hidden:"true"`
+
+	Run           RunOption      `command:"run" description:"Execute the workflow and print the result (the default without --listen and --grpc-listen)"`
+	Serve         ServeOption    `command:"serve" description:"Serve the emulated Workflows and Workflow Executions APIs (the default with --listen or --grpc-listen)"`
+	Validate      ValidateOption `command:"validate" description:"Compile the workflows without executing them and report all errors with their locations"`
+	Graph         GraphOption    `command:"graph" description:"Render the step graph of the workflow as DOT or Mermaid"`
+	Test          TestOption     `command:"test" description:"Execute the workflows by the test cases and report whether their results are expected"`
+	LSP           LSPOption      `command:"lsp" description:"Serve the Language Server Protocol over stdio for the editors to validate the workflows as you type (-f is not required)"`
+	Schema        struct{}       `command:"schema" description:"Print the JSON Schema of the workflow syntax supported by the emulator (-f is not required)"`
+	ReplayJournal ReplayOption   `command:"replay" description:"Execute the workflow again by the journal of --journal with the recorded HTTP responses and timestamps, and step back and forth through the steps to inspect the variables"`
+	Debug         DebugOption    `command:"debug" description:"Execute the workflow pausing at the steps to inspect and modify the variables by the commands from stdin (the parallel steps are serialized)"`
+	Bench         BenchOption    `command:"bench" description:"Execute the workflow repeatedly with concurrency and report the latencies, the allocations and the slowest steps (the logs are discarded)"`
+	Init          struct{}       `command:"init" description:"Generate a starter workflow into the file of -f and its test cases next to it (NAME_test.yaml) for the test subcommand"`
+}
+
+// subcommandsWithoutFiles are the subcommands which don't read the workflow files of -f.
+var subcommandsWithoutFiles = []string{"lsp", "schema"}
+
+// requiresFiles reports whether -f is required by the subcommand of the args, which is found by parsing the args
+// without -f required not to take the values of the options (e.g. --project lsp) as the subcommand.
+func requiresFiles(args []string) bool {
+	var opt Option
+	parser := flags.NewParser(&opt, flags.None)
+	parser.SubcommandsOptional = true
+	parser.FindOptionByLongName("file").Required = false
+	if _, err := parser.ParseArgs(args); err != nil || parser.Active == nil {
+		return true
+	}
+	return !lo.Contains(subcommandsWithoutFiles, parser.Active.Name)
+}
+
+// ReplayOption is the options of the replay subcommand.
+type ReplayOption struct {
+	Journal string `long:"journal" description:"[REQUIRED] Journal file of the execution written by run --journal" required:"true"`
+}
+
+// DebugOption is the options of the debug subcommand.
+type DebugOption struct {
+	Args        string   `long:"args" description:"[OPTIONAL] Workflow Arguments (JSON)" required:"false"`
+	ArgsYAML    string   `long:"args-yaml" description:"[OPTIONAL] Workflow Arguments (YAML, or a path to the YAML file)" required:"false"`
+	Breakpoints []string `long:"break" description:"[OPTIONAL] Pause at the step (STEP or ROUTINE.STEP, repeatable) in addition to the first step" required:"false"`
+}
+
+// BenchOption is the options of the bench subcommand.
+type BenchOption struct {
+	Args        string `long:"args" description:"[OPTIONAL] Workflow Arguments (JSON)" required:"false"`
+	ArgsYAML    string `long:"args-yaml" description:"[OPTIONAL] Workflow Arguments (YAML, or a path to the YAML file)" required:"false"`
+	Count       int    `short:"n" long:"count" description:"[OPTIONAL] Number of the executions" default:"100"`
+	Concurrency int    `short:"c" long:"concurrency" description:"[OPTIONAL] Number of the concurrent executions" default:"10"`
+}
+
+// LSPOption is the options of the lsp subcommand.
+type LSPOption struct {
+	CheckTypes bool `long:"check-types" description:"[OPTIONAL] Report the likely type errors as the diagnostics too (see validate --check-types)" required:"false"`
+}
+
+// ValidateOption is the options of the validate subcommand.
+type ValidateOption struct {
+	CheckTypes bool `long:"check-types" description:"[OPTIONAL] Infer the types of the expressions and report the likely type errors (e.g. \"a\" + 1 or the wrong count of the arguments of the standard library)" required:"false"`
+}
+
+// GraphOption is the options of the graph subcommand.
+type GraphOption struct {
+	Format string `long:"format" description:"[OPTIONAL] Format of the graph" choice:"dot" choice:"mermaid" default:"dot" required:"false"`
+}
+
+// TestOption is the options of the test subcommand.
+type TestOption struct {
+	Tests      []string `long:"tests" description:"[OPTIONAL] YAML file of the test cases with the args, the HTTP mocks, the environment variables and the expected result or exception (repeatable, default: NAME_test.yaml next to each workflow file NAME.yaml)" required:"false"`
+	Report     string   `long:"report" description:"[OPTIONAL] Write the results of the test cases into the file in the JUnit XML format for the CI systems" required:"false"`
+	ReportJSON string   `long:"report-json" description:"[OPTIONAL] Write the results of the test cases into the file in JSON" required:"false"`
+}
+
+// RunOption is the options of the run subcommand.
+type RunOption struct {
+	Args         string   `long:"args" description:"[OPTIONAL] Workflow Arguments (JSON)" required:"false"`
+	ArgsYAML     string   `long:"args-yaml" description:"[OPTIONAL] Workflow Arguments (YAML, or a path to the YAML file)" required:"false"`
+	ArgsFile     string   `long:"args-file" description:"[OPTIONAL] Execute the workflow once per line of the file (JSON lines) as the arguments, and print a JSON line of the result or the exception per line in the order of the lines" required:"false"`
+	Parallel     int      `long:"parallel" description:"[OPTIONAL] Number of the concurrent executions of --args-file (default: 1)" required:"false"`
+	Output       string   `long:"output" description:"[OPTIONAL] Format of the result: compact JSON, YAML, or the raw strings without the quotes (the other values are compact JSON) (default: indented JSON)" choice:"json" choice:"yaml" choice:"raw" required:"false"`
+	CallLogLevel string   `long:"call-log-level" description:"[OPTIONAL] Call logging level of the execution (the executions of the serve subcommand are configured by their callLogLevel)" choice:"LOG_ALL_CALLS" choice:"LOG_ERRORS_ONLY" choice:"LOG_NONE" required:"false"`
+	Checkpoint   string   `long:"checkpoint" description:"[OPTIONAL] Save the variables and the next step at every step boundary of the main workflow into the file (JSON)" required:"false"`
+	Resume       string   `long:"resume" description:"[OPTIONAL] Resume the execution from the checkpoint file saved by --checkpoint (--args and --args-yaml are ignored)" required:"false"`
+	ExitCodes    []string `long:"exit-code" env:"WORKFLOW_EMULATOR_EXIT_CODES" env-delim:"," description:"[OPTIONAL] Exit code of the uncaught exceptions by the tag (TAG=CODE, e.g. HttpError=3, repeatable), the first mapped tag of the exception is used (default: 1)" required:"false"`
+	Watch        bool     `long:"watch" description:"[OPTIONAL] Execute the workflow again whenever the workflow files or the file of --args-yaml are changed until Ctrl-C" required:"false"`
+	Trace        string   `long:"trace" description:"[OPTIONAL] Write a JSON line per executed step with the path of the nested steps, the duration, the next step and the changed variables into stderr, or the file by --trace=FILE" optional:"true" optional-value:"-" required:"false"`
+	Verbose      bool     `long:"verbose" description:"[OPTIONAL] Print the variables assigned by the assign steps and the results of the calls with their new values into stderr" required:"false"`
+	Redact       []string `long:"redact" description:"[OPTIONAL] Regular expression of the names of the variables and the keys whose values are redacted by --verbose and --journal in addition to the default ones like password, token and authorization (repeatable)" required:"false"`
+	Journal      string   `long:"journal" description:"[OPTIONAL] Write the steps, the evaluated expressions, the outbound HTTP interactions and the exceptions of the execution with their timestamps into the file (JSON lines), whose values are redacted like --verbose and the responses of Secret Manager are redacted" required:"false"`
+	NoRedact     bool     `long:"no-journal-redaction" description:"[OPTIONAL] Write the values of --journal without the redaction to replay the requests carrying the redacted keys (the journal file may contain the credentials and the secrets)" required:"false"`
+	Snapshot     string   `long:"snapshot" description:"[OPTIONAL] Compare the trace of the steps, the assignments and the calls with the snapshot file (JSON lines), which is written if it doesn't exist, and fail on the differences (the parallel steps are serialized)" required:"false"`
+	Update       bool     `long:"update-snapshot" description:"[OPTIONAL] Overwrite the snapshot file of --snapshot by the trace of the execution" required:"false"`
+}
+
+// ServeOption is the options of the serve subcommand.
+type ServeOption struct {
+	Listen          string  `short:"l" long:"listen" description:"[OPTIONAL] Listen host and port to emulate API" required:"false"`
+	GRPCListen      string  `long:"grpc-listen" description:"[OPTIONAL] Listen host and port to emulate gRPC API of the executions" required:"false"`
+	TLSCert         string  `long:"tls-cert" description:"[OPTIONAL] PEM file of the certificate to serve --listen and --grpc-listen over TLS (requires --tls-key)" required:"false"`
+	TLSKey          string  `long:"tls-key" description:"[OPTIONAL] PEM file of the private key of --tls-cert" required:"false"`
+	AdvertisedHost  string  `long:"advertised-host" description:"[OPTIONAL] Host (or host:port) of the callback URLs of events.create_callback_endpoint to reach --listen from the other hosts (e.g. the service name of docker-compose)" required:"false"`
+	ShutdownTimeout float64 `long:"shutdown-timeout" description:"[OPTIONAL] Seconds to wait for the in-flight executions to finish on SIGINT or SIGTERM before cancelling them" default:"30" required:"false"`
+	StrictPath      bool    `long:"strict-path" description:"[OPTIONAL] Respond 404 to the API requests to the projects other than --project (or --project-number) and the locations other than --location" required:"false"`
+	LogLevel        string  `long:"log-level" description:"[OPTIONAL] Minimum level of the logs (the access logs, the callbacks, the exporters and the errors of the subcommands)" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"info" required:"false"`
 }
 
 func main() {
@@ -30,6 +207,10 @@ func main() {
 func run(args []string) int {
 	var opt Option
 	parser := flags.NewParser(&opt, flags.Default)
+	parser.SubcommandsOptional = true
+	if !requiresFiles(args) {
+		parser.FindOptionByLongName("file").Required = false
+	}
 	_, err := parser.ParseArgs(args)
 	if err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
@@ -39,102 +220,1667 @@ func run(args []string) int {
 			return 1
 		}
 	}
-	if opt.Args != "" && opt.Listen != "" {
-		parser.WriteHelp(os.Stdout)
+	runOpt, serveOpt, err := selectSubcommand(parser, &opt)
+	if err != nil {
+		logging.Logf(logging.LevelError, "invalid subcommand", "error", err)
 		return 1
 	}
-
-	// server mode
-	if opt.Listen != "" {
-		err = serveWorkflow(opt.Listen, func() (workflow.WorkflowRoot, error) {
-			return loadWorkflow(opt.File)
-		})
-		if err != nil {
-			log.Printf("failed to serve workflow: %v", err)
+	if parser.Active != nil && parser.Active.Name == "validate" {
+		return validateWorkflows(&opt)
+	}
+	if parser.Active != nil && parser.Active.Name == "graph" {
+		return graphWorkflow(&opt)
+	}
+	if parser.Active != nil && parser.Active.Name == "lsp" {
+		return serveLSP(&opt)
+	}
+	if parser.Active != nil && parser.Active.Name == "init" {
+		return initWorkflow(&opt)
+	}
+	if parser.Active != nil && parser.Active.Name == "schema" {
+		if err := dumpJSON(os.Stdout, workflow.JSONSchema()); err != nil {
+			logging.Logf(logging.LevelError, "failed to dump schema", "error", err)
 			return 1
 		}
 		return 0
 	}
+	if serveOpt != nil && (serveOpt.TLSCert == "") != (serveOpt.TLSKey == "") {
+		logging.Logf(logging.LevelError, "--tls-cert and --tls-key are required together")
+		return 1
+	}
+	if opt.Record != "" && opt.Replay != "" {
+		logging.Logf(logging.LevelError, "--record and --replay are exclusive")
+		return 1
+	}
+
+	if name := workflowFileName(opt.File[0]); opt.WorkflowID == "" && len(opt.File) == 1 && filepath.Ext(name) != "" {
+		opt.WorkflowID = strings.TrimSuffix(name, filepath.Ext(name))
+	}
 
-	root, err := loadWorkflow(opt.File)
+	env, err := loadEnv(opt.EnvFile, opt.Env)
 	if err != nil {
-		log.Printf("failed to load workflow: %v", err)
+		logging.Logf(logging.LevelError, "failed to load environment variables", "error", err)
 		return 1
 	}
 
-	var workflowArgs any
-	if opt.Args != "" {
-		if err = json.Unmarshal([]byte(opt.Args), &workflowArgs); err != nil {
-			log.Printf("failed to parse args as JSON: %v", err)
+	for _, discovery := range opt.Discovery {
+		if err := registerDiscoveryDocument(discovery); err != nil {
+			logging.Logf(logging.LevelError, "failed to register discovery document", "error", err)
 			return 1
 		}
 	}
 
-	ret, err := root.Execute(workflowArgs)
+	endpoints, err := loadKeyValues(opt.EndpointsFile, opt.Endpoints)
 	if err != nil {
-		var exception types.Exception
-		if errors.As(err, &exception) {
-			if _, err = fmt.Fprintln(os.Stderr, exception.Error()); err != nil {
-				log.Printf("failed to dump workflow error: %v", err)
+		logging.Logf(logging.LevelError, "failed to load connector endpoints", "error", err)
+		return 1
+	}
+	if opt.StorageEndpoint != "" {
+		endpoints["storage"] = opt.StorageEndpoint
+	}
+	if serveOpt != nil && serveOpt.Listen != "" {
+		// dispatch the executions connector to the workflows served by this emulator instead of GCP
+		if _, ok := endpoints["workflowexecutions"]; !ok {
+			host := serveOpt.Listen
+			if strings.HasPrefix(host, ":") {
+				host = "127.0.0.1" + host
 			}
-			if err = dumpJSON(os.Stderr, exception.Exception()); err != nil {
-				log.Printf("failed to dump workflow error as JSON: %v", err)
+			endpoints["workflowexecutions"] = apiScheme(serveOpt) + "://" + host
+		}
+	}
+	for service, endpoint := range endpoints {
+		if err := defaults.SetEmulatorHost(service, endpoint); err != nil {
+			logging.Logf(logging.LevelError, "failed to set connector endpoint", "error", err)
+			return 1
+		}
+	}
+
+	if opt.Strict && opt.Extensions {
+		logging.Logf(logging.LevelError, "--strict and --extensions are exclusive")
+		return 1
+	}
+	if opt.Strict && opt.HTTPMaxResponse != defaults.ProductionHTTPMaxResponseSize {
+		logging.Logf(logging.LevelError, "--http-max-response-size must be the production limit with --strict", "limit", defaults.ProductionHTTPMaxResponseSize)
+		return 1
+	}
+	defaults.SetHTTPMultiValueHeaders(opt.MultiValueHeaders)
+	defaults.SetHTTPMaxRedirects(opt.MaxRedirects)
+	defaults.SetHTTPDefaultTimeout(opt.HTTPTimeout)
+	defaults.SetHTTPMaxIdleConns(opt.HTTPMaxIdleConns)
+	defaults.SetHTTPMaxResponseSize(opt.HTTPMaxResponse)
+	if opt.HTTPMocks != "" {
+		mocks, err := loadHTTPMocks(opt.HTTPMocks)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load HTTP mocks", "error", err)
+			return 1
+		}
+		defaults.WrapHTTPTransport(func(http.RoundTripper) http.RoundTripper { return mocks })
+	}
+	if opt.Record != "" {
+		defaults.WrapHTTPTransport(defaults.NewHTTPCassette(opt.Record).Recorder)
+	}
+	if opt.Replay != "" {
+		cassette, err := defaults.LoadHTTPCassette(opt.Replay)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load cassette", "error", err)
+			return 1
+		}
+		defaults.WrapHTTPTransport(func(http.RoundTripper) http.RoundTripper { return cassette })
+	}
+	if opt.Chaos != "" {
+		seed := opt.ChaosSeed
+		if seed == 0 {
+			seed = opt.Seed
+		}
+		chaos, err := loadHTTPChaos(opt.Chaos, seed)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load chaos rules", "error", err)
+			return 1
+		}
+		defaults.WrapHTTPTransport(chaos.Wrap)
+	}
+	if opt.TraceEndpoint != "" {
+		defaults.WrapHTTPTransport(tracing.WrapTransport)
+	}
+	if runOpt != nil && runOpt.Journal != "" {
+		defaults.WrapHTTPTransport(func(next http.RoundTripper) http.RoundTripper {
+			return defaults.ObserveHTTPInteractions(next, workflow.JournalHTTPInteraction)
+		})
+	}
+	if opt.CredentialsFile != "" {
+		if err := defaults.SetHTTPCredentialsFile(opt.CredentialsFile); err != nil {
+			logging.Logf(logging.LevelError, "failed to load credentials", "error", err)
+			return 1
+		}
+	}
+	if opt.FakeAuth {
+		claims, err := loadKeyValues("", opt.FakeAuthClaims)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load fake auth claims", "error", err)
+			return 1
+		}
+		defaults.SetFakeAuth(lo.MapValues(claims, func(v string, _ string) any { return v }))
+	}
+	if opt.HTTPProxy != "" {
+		if err := defaults.SetHTTPProxy(opt.HTTPProxy); err != nil {
+			logging.Logf(logging.LevelError, "failed to configure HTTP proxy", "error", err)
+			return 1
+		}
+	}
+	caFile := opt.HTTPCAFile
+	if caFile == "" && serveOpt != nil && serveOpt.TLSCert != "" && serveOpt.Listen != "" {
+		caFile = serveOpt.TLSCert // to call the emulator itself by the executions connector
+	}
+	if caFile != "" || opt.HTTPInsecure {
+		if err := defaults.SetHTTPTLSConfig(caFile, opt.HTTPInsecure); err != nil {
+			logging.Logf(logging.LevelError, "failed to configure TLS", "error", err)
+			return 1
+		}
+	}
+
+	if opt.MetadataListen != "" {
+		if err := serveMetadata(opt.MetadataListen, server.MetadataConfig{
+			ProjectID:      opt.ProjectID,
+			ProjectNumber:  opt.ProjectNumber,
+			Zone:           opt.Location + "-a",
+			ServiceAccount: opt.MetadataAccount,
+			Scopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+		}); err != nil {
+			logging.Logf(logging.LevelError, "failed to serve metadata server", "error", err)
+			return 1
+		}
+	}
+
+	executeOpts := []workflow.ExecuteOption{
+		workflow.SerializeParallel(opt.SerializeParallel),
+		workflow.WithExecutionInfo(workflow.ExecutionInfo{
+			ProjectID:     opt.ProjectID,
+			ProjectNumber: opt.ProjectNumber,
+			Location:      opt.Location,
+			WorkflowID:    opt.WorkflowID,
+			RevisionID:    "000001-dummy",
+		}),
+		workflow.WithEnv(env),
+	}
+	if opt.TraceEndpoint != "" {
+		tracer := tracing.NewJSONTracer(strings.TrimSuffix(opt.TraceEndpoint, "/")+"/v1/traces", opt.TraceServiceName)
+		defer tracer.Shutdown()
+		executeOpts = append(executeOpts, workflow.WithTracer(tracer))
+	}
+	if (opt.FakeTime != "" || opt.VirtualTime) && (opt.NoSleep || opt.TimeScale != 0) {
+		logging.Logf(logging.LevelError, "--no-sleep and --time-scale are not available with --fake-time and --virtual-time, which don't wait for the sleeps")
+		return 1
+	}
+	if opt.NoSleep && opt.TimeScale != 0 {
+		logging.Logf(logging.LevelError, "--no-sleep and --time-scale are exclusive")
+		return 1
+	}
+	if opt.NoSleep || opt.TimeScale != 0 {
+		factor := opt.TimeScale
+		if opt.NoSleep {
+			factor = math.Inf(1)
+		} else if factor <= 0 || math.IsNaN(factor) {
+			logging.Logf(logging.LevelError, "--time-scale must be positive")
+			return 1
+		}
+		clock := defaults.NewScaledClock(factor)
+		executeOpts = append(executeOpts, workflow.WithClock(func() defaults.Clock { return clock }))
+	}
+	if opt.FakeTime != "" || opt.VirtualTime {
+		var start time.Time
+		if opt.FakeTime != "" {
+			var err error
+			start, err = time.Parse(time.RFC3339Nano, opt.FakeTime)
+			if err != nil {
+				logging.Logf(logging.LevelError, "invalid --fake-time", "error", err)
+				return 1
+			}
+		}
+		executeOpts = append(executeOpts, workflow.WithClock(func() defaults.Clock {
+			switch {
+			case !opt.VirtualTime:
+				return defaults.NewFakeClock(start)
+			case start.IsZero():
+				return defaults.NewVirtualClock(time.Now())
+			default:
+				return defaults.NewVirtualClock(start)
 			}
+		}))
+	}
+	if opt.Seed != 0 {
+		executeOpts = append(executeOpts, workflow.WithRandom(func() io.Reader {
+			return defaults.NewSeededRandom(opt.Seed)
+		}))
+	}
+	if opt.Stubs != "" {
+		stubs, err := loadStubs(opt.Stubs)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load stubs", "error", err)
 			return 1
-		} else {
-			log.Printf("failed to execute workflow: %v", err)
+		}
+		executeOpts = append(executeOpts, workflow.WithStubs(stubs))
+	}
+	if opt.Extensions {
+		executeOpts = append(executeOpts, workflow.WithSymbols(defaults.Extensions))
+	}
+	if opt.Strict {
+		executeOpts = append(executeOpts, workflow.WithLimits(workflow.ProductionLimits), workflow.StrictArgs(true))
+	}
+	if len(opt.Symbols) != 0 {
+		symbols, err := loadSymbols(opt.Symbols)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load symbols", "error", err)
 			return 1
 		}
+		executeOpts = append(executeOpts, workflow.WithSymbols(symbols))
 	}
-	if ret != nil {
-		if err = dumpJSON(os.Stdout, ret); err != nil {
-			log.Printf("failed to dump workflow result: %v", err)
+	if opt.Plugins != "" {
+		functions, err := plugin.Load(opt.Plugins)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load plugins", "error", err)
+			return 1
 		}
+		executeOpts = append(executeOpts, workflow.WithSymbols(functions))
 	}
 
-	return 0
-}
+	if parser.Active != nil && parser.Active.Name == "test" {
+		return testWorkflows(&opt, env, executeOpts)
+	}
+	if parser.Active != nil && parser.Active.Name == "replay" {
+		return replayWorkflow(&opt, executeOpts)
+	}
+	if parser.Active != nil && parser.Active.Name == "debug" {
+		return debugWorkflow(&opt, executeOpts)
+	}
+	if parser.Active != nil && parser.Active.Name == "bench" {
+		return benchWorkflow(&opt, executeOpts)
+	}
+	if serveOpt != nil {
+		store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+			return loadWorkflows(opt.File)
+		}, executeOpts...)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+			return 1
+		}
+		// the remote workflows are not reloaded
+		if localFiles := lo.Reject(opt.File, func(path string, _ int) bool { return defaults.IsRemoteSource(path) }); len(localFiles) != 0 {
+			if err := store.WatchWorkflows(localFiles); err != nil {
+				logging.Logf(logging.LevelError, "failed to watch workflow", "error", err)
+				return 1
+			}
+		}
+		if serveOpt.StrictPath {
+			store.RestrictLocation(opt.ProjectID, opt.ProjectNumber, opt.Location)
+		}
+		if serveOpt.Listen != "" {
+			callbackHost, err := advertisedHost(serveOpt.Listen, serveOpt.AdvertisedHost)
+			if err != nil {
+				logging.Logf(logging.LevelError, "invalid --listen or --advertised-host", "error", err)
+				return 1
+			}
+			store.SetCallbackBaseURL(apiScheme(serveOpt) + "://" + callbackHost)
+		}
 
-func loadWorkflow(filePath string) (workflow.WorkflowRoot, error) {
-	var parseWorkflow func(io.Reader) (workflow.WorkflowRoot, error)
-	switch filepath.Ext(filePath) {
-	case ".json":
-		parseWorkflow = workflow.ParseWorkflowJSON
-	case ".yaml":
-		parseWorkflow = workflow.ParseWorkflowYAML
-	default:
-		return nil, fmt.Errorf("unsupported file extension: %s", filePath)
+		err = serveWorkflow(serveOpt, store)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to serve workflow", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	if runOpt.Watch {
+		return watchWorkflow(&opt, runOpt, executeOpts)
 	}
 
+	// abort the execution at the next step boundary on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return executeWorkflow(ctx, &opt, runOpt, executeOpts)
+}
+
+// batchInput is the arguments of a line of --args-file.
+type batchInput struct {
+	line int
+	args json.RawMessage
+}
+
+// batchOutput is the JSON line of the execution of a line of --args-file.
+type batchOutput struct {
+	Line      int    `json:"line"`
+	Result    any    `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Exception any    `json:"exception,omitempty"`
+}
+
+// loadBatchInputs loads the arguments of the lines of the JSON lines file except for the blank lines.
+func loadBatchInputs(filePath string) ([]*batchInput, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("os.Open(%q): %w", filePath, err)
 	}
 	defer f.Close()
 
-	root, err := parseWorkflow(f)
+	inputs := []*batchInput{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		if !json.Valid(b) {
+			return nil, fmt.Errorf("%s:%d: invalid JSON", filePath, line)
+		}
+		inputs = append(inputs, &batchInput{line: line, args: append(json.RawMessage{}, b...)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+	return inputs, nil
+}
+
+// executeBatch executes the workflow per input by the parallel executions, and prints the outputs in the order of the
+// inputs as they are finished. The exit code is of the first failed input, or 0 if all of them are succeeded.
+func executeBatch(ctx context.Context, root workflow.WorkflowRoot, inputs []*batchInput, parallel int, exitCodes map[string]int, executeOpts []workflow.ExecuteOption) int {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	outputs := make([]*batchOutput, len(inputs))
+	codes := make([]int, len(inputs))
+	done := make([]chan struct{}, len(inputs))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range inputs {
+			select {
+			case queue <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < parallel; w++ {
+		go func() {
+			for i := range queue {
+				outputs[i], codes[i] = executeBatchInput(ctx, root, inputs[i], exitCodes, executeOpts)
+				close(done[i])
+			}
+		}()
+	}
+
+	exitCode := 0
+	for i := range inputs {
+		select {
+		case <-done[i]:
+		case <-ctx.Done():
+			logging.Logf(logging.LevelError, "interrupted", "line", inputs[i].line)
+			return 1
+		}
+		if err := dumpResult(os.Stdout, outputs[i], "json"); err != nil {
+			logging.Logf(logging.LevelError, "failed to dump workflow result", "error", err)
+		}
+		if exitCode == 0 {
+			exitCode = codes[i]
+		}
+	}
+	return exitCode
+}
+
+// executeBatchInput executes the workflow by the input, and returns the output and the exit code of it.
+func executeBatchInput(ctx context.Context, root workflow.WorkflowRoot, input *batchInput, exitCodes map[string]int, executeOpts []workflow.ExecuteOption) (*batchOutput, int) {
+	output := &batchOutput{Line: input.line}
+	var args any
+	if err := json.Unmarshal(input.args, &args); err != nil {
+		output.Error = err.Error()
+		return output, 1
+	}
+
+	ret, err := root.Execute(ctx, args, executeOpts...)
+	if err == nil {
+		output.Result = ret
+		return output, 0
+	}
+
+	output.Error = err.Error()
+	var exception types.Exception
+	if errors.As(err, &exception) {
+		output.Exception = exception.Exception()
+		return output, exceptionExitCode(exception, exitCodes)
+	}
+	return output, 1
+}
+
+// watchWorkflow executes the workflow, and executes it again whenever the workflow files or the args file are changed
+// until Ctrl-C.
+func watchWorkflow(opt *Option, runOpt *RunOption, executeOpts []workflow.ExecuteOption) int {
+	paths := lo.Reject(opt.File, func(path string, _ int) bool { return defaults.IsRemoteSource(path) })
+	if info, err := os.Stat(runOpt.ArgsYAML); runOpt.ArgsYAML != "" && err == nil && !info.IsDir() {
+		paths = append(paths, runOpt.ArgsYAML)
+	}
+	if runOpt.ArgsFile != "" {
+		paths = append(paths, runOpt.ArgsFile)
+	}
+	if len(paths) == 0 {
+		logging.Logf(logging.LevelError, "--watch requires the local workflow files")
+		return 1
+	}
+
+	changes := make(chan struct{}, 1)
+	err := watch.Watch(paths, func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("workflow.ParseWorkflow: %w", err)
+		logging.Logf(logging.LevelError, "failed to watch workflow", "error", err)
+		return 1
+	}
+
+	// abort the current execution and stop watching on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for run := 1; ; run++ {
+		start := time.Now()
+		fmt.Fprintf(os.Stderr, "=== RUN #%d at %s\n", run, start.Format("15:04:05"))
+		code := executeWorkflow(ctx, opt, runOpt, executeOpts)
+		fmt.Fprintf(os.Stderr, "=== DONE #%d: exit status %d (%.2fs), waiting for the changes (Ctrl-C to quit)\n", run, code, time.Since(start).Seconds())
+
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-changes:
+		}
 	}
-	return root, nil
 }
 
-func serveWorkflow(listen string, loader func() (workflow.WorkflowRoot, error)) error {
-	handler, err := server.NewHTTPHandler(loader)
+// executeWorkflow loads and executes the workflow once, and prints the result or the exception.
+// It returns the exit code of the run subcommand.
+func executeWorkflow(ctx context.Context, opt *Option, runOpt *RunOption, executeOpts []workflow.ExecuteOption) int {
+	roots, err := loadWorkflows(opt.File)
 	if err != nil {
-		return err
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
 	}
+	wf, err := selectWorkflow(roots, opt.WorkflowID)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to select workflow", "error", err)
+		return 1
+	}
+	executeOpts = append(executeOpts[:len(executeOpts):len(executeOpts)], workflow.WithWorkflowResolver(resolveLoadedWorkflow(roots)))
 
-	srv := http.Server{
-		Handler: handler,
-		Addr:    listen,
+	var workflowArgs any
+	var batchArgs []*batchInput
+	if runOpt.ArgsFile != "" {
+		if runOpt.Args != "" || runOpt.ArgsYAML != "" {
+			logging.Logf(logging.LevelError, "--args-file is exclusive with --args and --args-yaml")
+			return 1
+		}
+		if runOpt.Checkpoint != "" || runOpt.Resume != "" || runOpt.Snapshot != "" {
+			logging.Logf(logging.LevelError, "--checkpoint, --resume and --snapshot are not available with --args-file")
+			return 1
+		}
+		batchArgs, err = loadBatchInputs(runOpt.ArgsFile)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load args file", "error", err)
+			return 1
+		}
+	} else if runOpt.Parallel != 0 {
+		logging.Logf(logging.LevelError, "--parallel requires --args-file")
+		return 1
+	} else {
+		workflowArgs, err = parseWorkflowArgs(runOpt.Args, runOpt.ArgsYAML)
+		if err != nil {
+			logging.Logf(logging.LevelError, "invalid arguments", "error", err)
+			return 1
+		}
 	}
 
-	log.Printf("Listen HTTP on %s", listen)
-	if err := srv.ListenAndServe(); errors.Is(err, http.ErrServerClosed) {
-		return nil
-	} else if err != nil {
-		return err
+	exitCodes, err := parseExitCodes(runOpt.ExitCodes)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to parse exit codes", "error", err)
+		return 1
+	}
+
+	// the options are not shared with the other executions of --watch
+	executeOpts = append(executeOpts[:len(executeOpts):len(executeOpts)], workflow.WithCallLogLevel(runOpt.CallLogLevel))
+	if runOpt.Checkpoint != "" {
+		executeOpts = append(executeOpts, workflow.WithCheckpoint(func(checkpoint *workflow.Checkpoint) {
+			if err := saveCheckpoint(runOpt.Checkpoint, checkpoint); err != nil {
+				logging.Logf(logging.LevelError, "failed to save checkpoint", "step", checkpoint.Step, "error", err)
+			}
+		}))
+	}
+	if runOpt.Resume != "" {
+		checkpoint, err := loadCheckpoint(runOpt.Resume)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load checkpoint", "error", err)
+			return 1
+		}
+		executeOpts = append(executeOpts, workflow.ResumeFrom(checkpoint))
+	}
+
+	switch runOpt.Trace {
+	case "":
+	case "-":
+		executeOpts = append(executeOpts, workflow.WithStepTrace(os.Stderr))
+	default:
+		f, err := os.Create(runOpt.Trace)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to create trace file", "path", runOpt.Trace, "error", err)
+			return 1
+		}
+		defer f.Close()
+		executeOpts = append(executeOpts, workflow.WithStepTrace(f))
+	}
+
+	patterns := lo.Map(append([]string{defaultRedactPattern}, runOpt.Redact...), func(pattern string, _ int) string {
+		return "(?:" + pattern + ")"
+	})
+	redact, err := regexp.Compile("(?i)" + strings.Join(patterns, "|"))
+	if err != nil {
+		logging.Logf(logging.LevelError, "invalid --redact", "error", err)
+		return 1
+	}
+	if runOpt.Journal != "" {
+		f, err := os.Create(runOpt.Journal)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to create journal file", "path", runOpt.Journal, "error", err)
+			return 1
+		}
+		defer f.Close()
+		journalRedact := redact
+		if runOpt.NoRedact {
+			journalRedact = nil
+		}
+		executeOpts = append(executeOpts, workflow.WithJournal(workflow.NewJournal(f, journalRedact)))
+	}
+	if runOpt.Verbose {
+		executeOpts = append(executeOpts, workflow.WithVariableLog(os.Stderr, redact))
+	}
+
+	if batchArgs != nil {
+		return executeBatch(ctx, wf.Root, batchArgs, runOpt.Parallel, exitCodes, executeOpts)
+	}
+
+	var trace *workflow.Trace
+	if runOpt.Snapshot != "" {
+		trace = &workflow.Trace{}
+		executeOpts = append(executeOpts, workflow.WithTrace(trace), workflow.SerializeParallel(true))
+	}
+
+	ret, err := wf.Root.Execute(ctx, workflowArgs, executeOpts...)
+	snapshotMatched := true
+	if trace != nil {
+		matched, err := matchSnapshot(runOpt.Snapshot, runOpt.Update, trace)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to compare snapshot", "error", err)
+			return 1
+		}
+		snapshotMatched = matched
+	}
+	if err != nil {
+		var exception types.Exception
+		if errors.As(err, &exception) {
+			if _, err := fmt.Fprintln(os.Stderr, exception.Error()); err != nil {
+				logging.Logf(logging.LevelError, "failed to dump workflow error", "error", err)
+			}
+			for _, frame := range workflow.StackTrace(err) {
+				line := ""
+				if frame.Position != nil {
+					line = fmt.Sprintf(", line: %d", frame.Position.Line)
+				}
+				if _, err := fmt.Fprintf(os.Stderr, "\tin step %q, routine %q%s\n", frame.Step, frame.Routine, line); err != nil {
+					logging.Logf(logging.LevelError, "failed to dump workflow error", "error", err)
+				}
+			}
+			if err = dumpJSON(os.Stderr, exception.Exception()); err != nil {
+				logging.Logf(logging.LevelError, "failed to dump workflow error as JSON", "error", err)
+			}
+			return exceptionExitCode(exception, exitCodes)
+		} else {
+			logging.Logf(logging.LevelError, "failed to execute workflow", "error", err)
+			return 1
+		}
+	}
+	if ret != nil {
+		if err = dumpResult(os.Stdout, ret, runOpt.Output); err != nil {
+			logging.Logf(logging.LevelError, "failed to dump workflow result", "error", err)
+		}
+	}
+	if !snapshotMatched {
+		return 1
+	}
+
+	return 0
+}
+
+// replayWorkflow executes the workflow again by the journal, and browses the steps of it by the commands from stdin.
+func replayWorkflow(opt *Option, executeOpts []workflow.ExecuteOption) int {
+	f, err := os.Open(opt.ReplayJournal.Journal)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load journal", "path", opt.ReplayJournal.Journal, "error", err)
+		return 1
+	}
+	r, err := replay.Load(opt.ReplayJournal.Journal, f)
+	_ = f.Close()
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load journal", "error", err)
+		return 1
+	}
+	defaults.WrapHTTPTransport(func(http.RoundTripper) http.RoundTripper { return r.Cassette })
+
+	roots, err := loadWorkflows(opt.File)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+	wf, err := selectWorkflow(roots, opt.WorkflowID)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to select workflow", "error", err)
+		return 1
+	}
+	executeOpts = append(executeOpts[:len(executeOpts):len(executeOpts)], workflow.WithWorkflowResolver(resolveLoadedWorkflow(roots)))
+
+	frames, ret, err := r.Execute(context.Background(), wf.Root, executeOpts...)
+	if err != nil {
+		fmt.Printf("replayed %d steps, failed: %v\n", len(frames), err)
+	} else {
+		fmt.Printf("replayed %d steps, succeeded: ", len(frames))
+		if err := dumpResult(os.Stdout, ret, "json"); err != nil {
+			logging.Logf(logging.LevelError, "failed to dump workflow result", "error", err)
+		}
+	}
+
+	if err := replay.Browse(os.Stdin, os.Stdout, frames); err != nil {
+		logging.Logf(logging.LevelError, "failed to read commands", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// debugWorkflow executes the workflow by the debugger reading the commands from stdin.
+func debugWorkflow(opt *Option, executeOpts []workflow.ExecuteOption) int {
+	roots, err := loadWorkflows(opt.File)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+	wf, err := selectWorkflow(roots, opt.WorkflowID)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to select workflow", "error", err)
+		return 1
+	}
+	executeOpts = append(executeOpts[:len(executeOpts):len(executeOpts)], workflow.WithWorkflowResolver(resolveLoadedWorkflow(roots)))
+	workflowArgs, err := parseWorkflowArgs(opt.Debug.Args, opt.Debug.ArgsYAML)
+	if err != nil {
+		logging.Logf(logging.LevelError, "invalid arguments", "error", err)
+		return 1
+	}
+
+	d := debugger.New(os.Stdin, os.Stdout, opt.Debug.Breakpoints)
+	executeOpts = append(executeOpts, workflow.WithStepHook(d.Hook), workflow.SerializeParallel(true))
+	ret, err := wf.Root.Execute(context.Background(), workflowArgs, executeOpts...)
+	if errors.Is(err, debugger.ErrQuit) {
+		return 1
+	} else if err != nil {
+		fmt.Printf("failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Print("succeeded: ")
+	if err := dumpResult(os.Stdout, ret, "json"); err != nil {
+		logging.Logf(logging.LevelError, "failed to dump workflow result", "error", err)
+	}
+	return 0
+}
+
+// benchWorkflow executes the workflow repeatedly and prints the report of the executions.
+func benchWorkflow(opt *Option, executeOpts []workflow.ExecuteOption) int {
+	roots, err := loadWorkflows(opt.File)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+	wf, err := selectWorkflow(roots, opt.WorkflowID)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to select workflow", "error", err)
+		return 1
+	}
+	executeOpts = append(executeOpts[:len(executeOpts):len(executeOpts)], workflow.WithWorkflowResolver(resolveLoadedWorkflow(roots)))
+	workflowArgs, err := parseWorkflowArgs(opt.Bench.Args, opt.Bench.ArgsYAML)
+	if err != nil {
+		logging.Logf(logging.LevelError, "invalid arguments", "error", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// the logs of sys.log and the call logging of the executions would bury the report
+	log.SetOutput(io.Discard)
+	result, err := bench.Run(ctx, wf.Root, workflowArgs, opt.Bench.Count, opt.Bench.Concurrency, executeOpts...)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to benchmark workflow", "error", err)
+		return 1
+	}
+	if err := result.Report(os.Stdout); err != nil {
+		logging.Logf(logging.LevelError, "failed to write report", "error", err)
+		return 1
+	}
+	if result.Failures != 0 {
+		return 1
+	}
+	return 0
+}
+
+// selectSubcommand returns the options of the active subcommand, or the legacy options without the subcommands.
+// Either of the returned options is nil, or both of them are nil for the other subcommands.
+func selectSubcommand(parser *flags.Parser, opt *Option) (*RunOption, *ServeOption, error) {
+	if parser.Active == nil {
+		legacy := &opt.Legacy
+		if legacy.Listen == "" && legacy.GRPCListen == "" {
+			return &legacy.RunOption, nil, nil
+		}
+		if legacy.Args != "" || legacy.ArgsYAML != "" || legacy.ArgsFile != "" || legacy.Parallel != 0 {
+			return nil, nil, errors.New("--args, --args-yaml, --args-file and --parallel are not available with --listen or --grpc-listen")
+		}
+		if legacy.Checkpoint != "" || legacy.Resume != "" {
+			return nil, nil, errors.New("--checkpoint and --resume are not available with --listen or --grpc-listen")
+		}
+		if legacy.Snapshot != "" {
+			return nil, nil, errors.New("--snapshot is not available with --listen or --grpc-listen")
+		}
+		if legacy.Trace != "" || legacy.Verbose || legacy.Journal != "" {
+			return nil, nil, errors.New("--trace, --verbose and --journal are not available with --listen or --grpc-listen")
+		}
+		if legacy.Watch {
+			return nil, nil, errors.New("--watch is not available with --listen or --grpc-listen (the workflows are reloaded on the changes)")
+		}
+		return nil, &legacy.ServeOption, nil
+	}
+
+	for _, option := range parser.Command.Group.Find("Legacy Options").Options() {
+		if option.IsSet() && !option.IsSetDefault() {
+			return nil, nil, fmt.Errorf("--%s is not an option of the %s subcommand", option.LongName, parser.Active.Name)
+		}
+	}
+	switch parser.Active.Name {
+	case "run":
+		return &opt.Run, nil, nil
+	case "serve":
+		if opt.Serve.Listen == "" && opt.Serve.GRPCListen == "" {
+			return nil, nil, errors.New("--listen or --grpc-listen is required")
+		}
+		return nil, &opt.Serve, nil
+	case "validate", "graph", "test", "lsp", "schema", "init", "replay", "debug", "bench":
+		return nil, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown subcommand: %s", parser.Active.Name)
+	}
+}
+
+// selectWorkflow returns the workflow of the ID, or the only one in the workflow files.
+func selectWorkflow(roots map[string]*server.LoadedWorkflow, id string) (*server.LoadedWorkflow, error) {
+	wf, ok := roots[id]
+	if !ok && len(roots) == 1 {
+		for _, wf = range roots {
+			ok = true
+		}
+	}
+	if !ok && id == "" {
+		return nil, errors.New("--workflow-id is required to select one of the workflows")
+	} else if !ok {
+		return nil, fmt.Errorf("workflow %q is not found in the workflow files", id)
+	}
+	return wf, nil
+}
+
+// resolveLoadedWorkflow resolves the workflow IDs of experimental.executions.map into the loaded workflows.
+func resolveLoadedWorkflow(roots map[string]*server.LoadedWorkflow) workflow.WorkflowResolver {
+	return func(workflowID string) (workflow.WorkflowRoot, string, bool) {
+		wf, ok := roots[workflowID]
+		if !ok {
+			return nil, "", false
+		}
+		return wf.Root, "", true
+	}
+}
+
+// loadWorkflows loads the workflow files, and the workflow files in the directories, by the base names of them.
+func loadWorkflows(paths []string) (map[string]*server.LoadedWorkflow, error) {
+	files, err := listWorkflowFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := make(map[string]*server.LoadedWorkflow, len(files))
+	for _, file := range files {
+		name := workflowFileName(file)
+		id := strings.TrimSuffix(name, filepath.Ext(name))
+		if _, duplicated := roots[id]; duplicated {
+			return nil, fmt.Errorf("duplicated workflow ID: %s", id)
+		}
+
+		wf, err := loadWorkflow(file)
+		if err != nil {
+			return nil, err
+		}
+		roots[id] = wf
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no workflow files in %s", strings.Join(paths, ", "))
+	}
+	return roots, nil
+}
+
+// listWorkflowFiles returns the workflow files, and the workflow files (*.yaml and *.json) in the directories.
+func listWorkflowFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		if defaults.IsRemoteSource(path) {
+			files = append(files, path)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("os.Stat(%q): %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadDir(%q): %w", path, err)
+		}
+		for _, entry := range entries {
+			if ext := filepath.Ext(entry.Name()); !entry.IsDir() && (ext == ".yaml" || ext == ".json") && !workflowtest.IsTestFile(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// workflowFileName returns the base name of the workflow file, or the object of the URL like https://... or gs://...
+func workflowFileName(file string) string {
+	if defaults.IsRemoteSource(file) {
+		if u, err := url.Parse(file); err == nil {
+			return path.Base(u.Path)
+		}
+	}
+	return filepath.Base(file)
+}
+
+// readWorkflowSource reads the workflow file, or fetches the source of the workflow from the URL.
+func readWorkflowSource(path string) ([]byte, error) {
+	if defaults.IsRemoteSource(path) {
+		source, err := defaults.FetchSource(context.Background(), path)
+		if err != nil {
+			return nil, fmt.Errorf("defaults.FetchSource(%q): %w", path, err)
+		}
+		return source, nil
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(%q): %w", path, err)
+	}
+	return source, nil
+}
+
+func loadWorkflow(filePath string) (*server.LoadedWorkflow, error) {
+	var parseWorkflow func(io.Reader) (workflow.WorkflowRoot, error)
+	switch filepath.Ext(workflowFileName(filePath)) {
+	case ".json":
+		parseWorkflow = workflow.ParseWorkflowJSON
+	case ".yaml":
+		parseWorkflow = workflow.ParseWorkflowYAML
+	default:
+		return nil, fmt.Errorf("unsupported file extension: %s", filePath)
+	}
+
+	source, err := readWorkflowSource(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseWorkflow(bytes.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("workflow.ParseWorkflow(%q): %w", filePath, err)
+	}
+	return &server.LoadedWorkflow{Root: root, Source: source}, nil
+}
+
+// validateWorkflows validates the workflow files without executing them, and reports all errors of them to stderr.
+func validateWorkflows(opt *Option) int {
+	// the connectors generated from the discovery documents are callable
+	for _, discovery := range opt.Discovery {
+		if err := registerDiscoveryDocument(discovery); err != nil {
+			logging.Logf(logging.LevelError, "failed to register discovery document", "error", err)
+			return 1
+		}
+	}
+
+	files, err := listWorkflowFiles(opt.File)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+	if len(files) == 0 {
+		logging.Logf(logging.LevelError, "no workflow files", "path", strings.Join(opt.File, ", "))
+		return 1
+	}
+
+	var invalid bool
+	for _, file := range files {
+		errs, err := validateWorkflow(file, workflow.WithTypeCheck(opt.Validate.CheckTypes))
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to validate workflow", "error", err)
+			return 1
+		}
+		for _, e := range errs {
+			if e.Position != nil {
+				fmt.Fprintf(os.Stderr, "%s:%v\n", file, e)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", file, e)
+			}
+		}
+		if lo.ContainsBy(errs, func(e *workflow.ValidationError) bool { return !e.Warning }) {
+			invalid = true
+		}
+	}
+	if invalid {
+		return 1
+	}
+	return 0
+}
+
+func validateWorkflow(filePath string, opts ...workflow.ValidateOption) ([]*workflow.ValidationError, error) {
+	var validateWorkflow func(io.Reader, ...workflow.ValidateOption) ([]*workflow.ValidationError, error)
+	switch filepath.Ext(workflowFileName(filePath)) {
+	case ".json":
+		validateWorkflow = workflow.ValidateWorkflowJSON
+	case ".yaml":
+		validateWorkflow = workflow.ValidateWorkflowYAML
+	default:
+		return nil, fmt.Errorf("unsupported file extension: %s", filePath)
+	}
+
+	source, err := readWorkflowSource(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return validateWorkflow(bytes.NewReader(source), opts...)
+}
+
+func graphWorkflow(opt *Option) int {
+	files, err := listWorkflowFiles(opt.File)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+	if len(files) != 1 {
+		logging.Logf(logging.LevelError, "graph renders a single workflow file", "files", len(files), "path", strings.Join(opt.File, ", "))
+		return 1
+	}
+
+	var graphWorkflow func(io.Reader) (*workflow.Graph, error)
+	switch filepath.Ext(workflowFileName(files[0])) {
+	case ".json":
+		graphWorkflow = workflow.GraphWorkflowJSON
+	case ".yaml":
+		graphWorkflow = workflow.GraphWorkflowYAML
+	default:
+		logging.Logf(logging.LevelError, "unsupported file extension", "path", files[0])
+		return 1
+	}
+
+	source, err := readWorkflowSource(files[0])
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+
+	g, err := graphWorkflow(bytes.NewReader(source))
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+	if opt.Graph.Format == "mermaid" {
+		err = g.WriteMermaid(os.Stdout)
+	} else {
+		err = g.WriteDOT(os.Stdout)
+	}
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to write graph", "error", err)
+		return 1
+	}
+	return 0
+}
+
+type testFile struct {
+	path       string
+	workflowID string // of the test cases without workflow, empty to select it by --workflow-id
+}
+
+// listTestFiles returns the files of --tests, or the test case files of the workflow files by the convention.
+func listTestFiles(opt *Option) ([]testFile, error) {
+	if len(opt.Test.Tests) != 0 {
+		return lo.Map(opt.Test.Tests, func(path string, _ int) testFile { return testFile{path: path} }), nil
+	}
+
+	files, err := listWorkflowFiles(opt.File)
+	if err != nil {
+		return nil, err
+	}
+	var testFiles []testFile
+	for _, file := range files {
+		if defaults.IsRemoteSource(file) {
+			continue
+		}
+		path := workflowtest.TestFilePath(file)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("os.Stat(%q): %w", path, err)
+		}
+		testFiles = append(testFiles, testFile{path: path, workflowID: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))})
+	}
+	if len(testFiles) == 0 {
+		return nil, errors.New("no test case files next to the workflow files, specify them by --tests")
+	}
+	return testFiles, nil
+}
+
+// initWorkflow writes the starter workflow into the file of -f and its test cases next to it without overwriting the files.
+func initWorkflow(opt *Option) int {
+	if len(opt.File) != 1 || filepath.Ext(opt.File[0]) != ".yaml" {
+		logging.Logf(logging.LevelError, "init requires a single YAML file to write by -f")
+		return 1
+	}
+
+	files := []struct {
+		path    string
+		content string
+	}{
+		{path: opt.File[0], content: workflowtest.ScaffoldWorkflow},
+		{path: workflowtest.TestFilePath(opt.File[0]), content: workflowtest.ScaffoldTestCases},
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil {
+			logging.Logf(logging.LevelError, "file already exists", "path", file.path)
+			return 1
+		}
+	}
+	for _, file := range files {
+		if err := os.WriteFile(file.path, []byte(file.content), 0o644); err != nil {
+			logging.Logf(logging.LevelError, "failed to write file", "path", file.path, "error", err)
+			return 1
+		}
+		fmt.Println("created " + file.path)
+	}
+	return 0
+}
+
+// serveLSP serves the Language Server Protocol over stdin and stdout.
+func serveLSP(opt *Option) int {
+	if err := lsp.NewServer(workflow.WithTypeCheck(opt.LSP.CheckTypes)).Serve(os.Stdin, os.Stdout); err != nil {
+		logging.Logf(logging.LevelError, "failed to serve LSP", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// testWorkflows executes the workflows by the test cases, and reports the results of them like go test.
+func testWorkflows(opt *Option, env map[string]string, executeOpts []workflow.ExecuteOption) int {
+	testFiles, err := listTestFiles(opt)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to find test cases", "error", err)
+		return 1
+	}
+	testCases := make([][]*workflowtest.TestCase, len(testFiles))
+	for i, file := range testFiles {
+		cases, err := loadTestCases(file.path)
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to load test cases", "error", err)
+			return 1
+		}
+		for _, tc := range cases {
+			if tc.Workflow == "" {
+				tc.Workflow = file.workflowID
+			}
+		}
+		testCases[i] = cases
+	}
+	roots, err := loadWorkflows(opt.File)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to load workflow", "error", err)
+		return 1
+	}
+
+	// abort the test cases at the next step boundary on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := workflowtest.NewRunner()
+	suites := make([]*workflowtest.Suite, 0, len(testFiles))
+	total, failed := 0, 0
+	for i, file := range testFiles {
+		suite := &workflowtest.Suite{Name: file.path}
+		suites = append(suites, suite)
+		for _, tc := range testCases[i] {
+			if ctx.Err() != nil {
+				break
+			}
+
+			id := tc.Workflow
+			if id == "" {
+				id = opt.WorkflowID
+			}
+			var result *workflowtest.Result
+			if wf, err := selectWorkflow(roots, id); err != nil {
+				result = &workflowtest.Result{Name: tc.Name, Failure: err.Error()}
+			} else {
+				result = runner.Run(ctx, wf.Root, tc, env, executeOpts...)
+			}
+			suite.Results = append(suite.Results, result)
+
+			total++
+			status := "PASS"
+			if !result.Passed() {
+				status = "FAIL"
+				failed++
+			}
+			fmt.Printf("--- %s: %s (%.2fs)\n", status, result.Name, result.Duration.Seconds())
+			if !result.Passed() {
+				fmt.Println("    " + strings.ReplaceAll(strings.TrimSuffix(result.Failure, "\n"), "\n", "\n    "))
+			}
+		}
+	}
+
+	if opt.Test.Report != "" {
+		if err := writeTestReport(opt.Test.Report, suites, workflowtest.WriteJUnitXML); err != nil {
+			logging.Logf(logging.LevelError, "failed to write test report", "error", err)
+			return 1
+		}
+	}
+	if opt.Test.ReportJSON != "" {
+		if err := writeTestReport(opt.Test.ReportJSON, suites, workflowtest.WriteJSON); err != nil {
+			logging.Logf(logging.LevelError, "failed to write test report", "error", err)
+			return 1
+		}
+	}
+
+	if failed != 0 {
+		fmt.Printf("FAIL: %d of %d test cases failed\n", failed, total)
+		return 1
+	}
+	fmt.Printf("PASS: %d test cases\n", total)
+	return 0
+}
+
+func loadEnv(filePath string, pairs []string) (map[string]string, error) {
+	env, err := loadKeyValues(filePath, pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range env {
+		if err := workflow.ValidateEnvName(name); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+func loadSymbols(pairs []string) (map[string]any, error) {
+	values, err := loadKeyValues("", pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[string]any, len(values))
+	for name, value := range values {
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, fmt.Errorf("invalid symbol %s: %w", name, err)
+		}
+		symbols[name] = v
+	}
+	return symbols, nil
+}
+
+func loadStubs(filePath string) (*workflow.Stubs, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%q): %w", filePath, err)
+	}
+	defer f.Close()
+
+	stubs, err := workflow.ParseStubsYAML(f)
+	if err != nil {
+		return nil, fmt.Errorf("workflow.ParseStubsYAML: %w", err)
+	}
+	return stubs, nil
+}
+
+func loadTestCases(filePath string) ([]*workflowtest.TestCase, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%q): %w", filePath, err)
+	}
+	defer f.Close()
+
+	testCases, err := workflowtest.ParseTestCasesYAML(f)
+	if err != nil {
+		return nil, fmt.Errorf("workflowtest.ParseTestCasesYAML(%q): %w", filePath, err)
+	}
+	return testCases, nil
+}
+
+func writeTestReport(filePath string, suites []*workflowtest.Suite, write func(io.Writer, []*workflowtest.Suite) error) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("os.Create(%q): %w", filePath, err)
+	}
+	if err := write(f, suites); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("f.Close: %w", err)
+	}
+	return nil
+}
+
+// matchSnapshot compares the trace with the snapshot file, and reports the differences to stderr.
+// The snapshot file is written by the trace if it doesn't exist or update is true.
+func matchSnapshot(filePath string, update bool, trace *workflow.Trace) (bool, error) {
+	if update {
+		return true, saveSnapshot(filePath, trace)
+	}
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, saveSnapshot(filePath, trace)
+	} else if err != nil {
+		return false, fmt.Errorf("os.Open(%q): %w", filePath, err)
+	}
+	defer f.Close()
+
+	expected, err := workflow.ReadTraceLines(f)
+	if err != nil {
+		return false, fmt.Errorf("workflow.ReadTraceLines: %w", err)
+	}
+	if diff := workflow.DiffTraceLines(expected, trace.Lines()); diff != "" {
+		if _, err := fmt.Fprintf(os.Stderr, "trace diverged from the snapshot %s (-snapshot +actual):\n%s", filePath, diff); err != nil {
+			logging.Logf(logging.LevelError, "failed to dump snapshot diff", "error", err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func saveSnapshot(filePath string, trace *workflow.Trace) error {
+	var buf bytes.Buffer
+	if _, err := trace.WriteTo(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("os.WriteFile(%q): %w", filePath, err)
+	}
+	return nil
+}
+
+func loadHTTPMocks(filePath string) (*defaults.HTTPMocks, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%q): %w", filePath, err)
+	}
+	defer f.Close()
+
+	mocks, err := defaults.ParseHTTPMocksYAML(f)
+	if err != nil {
+		return nil, fmt.Errorf("defaults.ParseHTTPMocksYAML: %w", err)
+	}
+	return mocks, nil
+}
+
+func loadHTTPChaos(filePath string, seed int64) (*defaults.HTTPChaos, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%q): %w", filePath, err)
+	}
+	defer f.Close()
+
+	chaos, err := defaults.ParseHTTPChaosYAML(f, seed)
+	if err != nil {
+		return nil, fmt.Errorf("defaults.ParseHTTPChaosYAML: %w", err)
+	}
+	return chaos, nil
+}
+
+func loadCheckpoint(filePath string) (*workflow.Checkpoint, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(%q): %w", filePath, err)
+	}
+
+	var checkpoint workflow.Checkpoint
+	if err := json.Unmarshal(b, &checkpoint); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// saveCheckpoint writes the checkpoint into the file atomically to not break the last one on the interruptions.
+func saveCheckpoint(filePath string, checkpoint *workflow.Checkpoint) error {
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0o644); err != nil {
+		return fmt.Errorf("os.WriteFile(%q): %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("os.Rename(%q, %q): %w", tmpPath, filePath, err)
+	}
+	return nil
+}
+
+func registerDiscoveryDocument(discovery string) error {
+	var b []byte
+	if api, version, ok := strings.Cut(discovery, ":"); ok && !strings.ContainsAny(discovery, `/\`) {
+		var err error
+		b, err = defaults.LoadDiscoveryDocument(api, version)
+		if err != nil {
+			return fmt.Errorf("defaults.LoadDiscoveryDocument(%q, %q): %w", api, version, err)
+		}
+	} else {
+		var err error
+		b, err = os.ReadFile(discovery)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile(%q): %w", discovery, err)
+		}
+	}
+
+	if err := defaults.RegisterDiscoveryDocument(b); err != nil {
+		return fmt.Errorf("defaults.RegisterDiscoveryDocument(%q): %w", discovery, err)
+	}
+	return nil
+}
+
+// parseWorkflowArgs parses the workflow arguments of --args or --args-yaml, or returns nil without them.
+func parseWorkflowArgs(argsJSON, argsYAML string) (any, error) {
+	if argsJSON != "" && argsYAML != "" {
+		return nil, errors.New("--args and --args-yaml are exclusive")
+	}
+
+	var args any
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return nil, fmt.Errorf("failed to parse args as JSON: %w", err)
+		}
+	}
+	if argsYAML != "" {
+		var err error
+		args, err = loadArgsYAML(argsYAML)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse args as YAML: %w", err)
+		}
+	}
+	return args, nil
+}
+
+// loadArgsYAML parses the inline YAML, or the YAML file if the value is a path to it, as the workflow arguments.
+// It's converted into JSON at first, so the numbers are handled in the same way as --args.
+func loadArgsYAML(value string) (any, error) {
+	b := []byte(value)
+	if info, err := os.Stat(value); err == nil && !info.IsDir() {
+		b, err = os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile(%q): %w", value, err)
+		}
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
+	}
+	var args any
+	if err := json.Unmarshal(jsonBytes, &args); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return args, nil
+}
+
+// loadKeyValues loads the YAML map file and then overrides it by the KEY=VALUE pairs.
+func loadKeyValues(filePath string, pairs []string) (map[string]string, error) {
+	m := map[string]string{}
+	if filePath != "" {
+		b, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile(%q): %w", filePath, err)
+		}
+		if err = yaml.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("yaml.Unmarshal(%q): %w", filePath, err)
+		}
+	}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pair %q: must be KEY=VALUE", pair)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// serveMetadata starts the metadata server in background and points GCE_METADATA_HOST at it.
+func serveMetadata(listen string, config server.MetadataConfig) error {
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+	if err = os.Setenv("GCE_METADATA_HOST", l.Addr().String()); err != nil {
+		return fmt.Errorf("os.Setenv: %w", err)
+	}
+
+	logging.Logf(logging.LevelInfo, "listen metadata server", "address", l.Addr())
+	go func() {
+		if err := http.Serve(l, server.NewMetadataHandler(config)); err != nil {
+			logging.Logf(logging.LevelError, "failed to serve metadata server", "error", err)
+		}
+	}()
+	return nil
+}
+
+// advertisedHost returns the host:port to reach the listen address, which is the advertised host with the port
+// of the listen address if the advertised host has no port, or the listen address with the loopback host
+// instead of the unspecified (any) host if the advertised host is empty.
+func advertisedHost(listen, advertised string) (string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("net.SplitHostPort: %w", err)
+	}
+
+	if advertised != "" {
+		if _, _, err := net.SplitHostPort(advertised); err == nil {
+			return advertised, nil
+		}
+		return net.JoinHostPort(advertised, port), nil
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// apiScheme returns the URL scheme of the API server.
+func apiScheme(opt *ServeOption) string {
+	if opt.TLSCert != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// serveWorkflow serves the APIs until SIGINT or SIGTERM, then drains the in-flight executions before closing the listeners.
+func serveWorkflow(opt *ServeOption, store *server.ExecutionStore) error {
+	level, err := logging.ParseLevel(opt.LogLevel)
+	if err != nil {
+		return err
+	}
+	logging.SetLevel(level)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 2)
+	var grpcServer *grpc.Server
+	if opt.GRPCListen != "" {
+		l, err := net.Listen("tcp", opt.GRPCListen)
+		if err != nil {
+			return fmt.Errorf("net.Listen: %w", err)
+		}
+
+		var grpcOpts []grpc.ServerOption
+		if opt.TLSCert != "" {
+			creds, err := credentials.NewServerTLSFromFile(opt.TLSCert, opt.TLSKey)
+			if err != nil {
+				return fmt.Errorf("credentials.NewServerTLSFromFile: %w", err)
+			}
+			grpcOpts = append(grpcOpts, grpc.Creds(creds))
+		}
+		grpcServer = server.NewGRPCServer(store, grpcOpts...)
+		logging.Logf(logging.LevelInfo, "listen gRPC", "address", l.Addr())
+		go func() {
+			if err := grpcServer.Serve(l); err != nil {
+				errCh <- fmt.Errorf("failed to serve gRPC: %w", err)
+			}
+		}()
+	}
+
+	var srv *http.Server
+	if opt.Listen != "" {
+		srv = &http.Server{
+			Handler: server.NewHTTPHandler(store),
+			Addr:    opt.Listen,
+		}
+
+		logging.Logf(logging.LevelInfo, "listen "+strings.ToUpper(apiScheme(opt)), "address", opt.Listen)
+		go func() {
+			var err error
+			if opt.TLSCert != "" {
+				err = srv.ListenAndServeTLS(opt.TLSCert, opt.TLSKey)
+			} else {
+				err = srv.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		stop() // terminate immediately by the second signal
+	}
+
+	// keep serving the other requests (e.g. polling the executions) while draining the executions
+	shutdownTimeout := time.Duration(opt.ShutdownTimeout * float64(time.Second))
+	logging.Logf(logging.LevelInfo, "shutting down: waiting for the in-flight executions", "timeout", shutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := store.Shutdown(drainCtx); err != nil {
+		logging.Logf(logging.LevelError, "cancelled the in-flight executions", "error", err)
+	}
+
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if srv != nil {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			return fmt.Errorf("http.Server.Shutdown: %w", err)
+		}
+	}
+	return nil
+}
+
+// defaultRedactPattern matches the names of the variables and the keys of the credentials redacted by --verbose and
+// --journal.
+const defaultRedactPattern = `password|passwd|secret|token|api_?key|authorization|cookie|credential`
+
+// parseExitCodes parses the TAG=CODE pairs of --exit-code.
+func parseExitCodes(pairs []string) (map[string]int, error) {
+	codes := make(map[string]int, len(pairs))
+	for _, pair := range pairs {
+		tag, value, ok := strings.Cut(pair, "=")
+		if !ok || tag == "" {
+			return nil, fmt.Errorf("invalid pair %q: must be TAG=CODE", pair)
+		}
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 1 || code > 255 {
+			return nil, fmt.Errorf("invalid exit code %q of %s: must be 1-255", value, tag)
+		}
+		codes[tag] = code
+	}
+	return codes, nil
+}
+
+// exceptionExitCode returns the exit code of the first tag of the exception mapped by --exit-code, or 1.
+func exceptionExitCode(exception types.Exception, codes map[string]int) int {
+	if m, ok := exception.Exception().(map[string]any); ok {
+		tags, _ := m["tags"].([]any)
+		for _, tag := range tags {
+			if code, ok := codes[fmt.Sprint(tag)]; ok {
+				return code
+			}
+		}
+	}
+	return 1
+}
+
+// dumpResult writes the result of the workflow in the format of --output, or indented JSON by default.
+func dumpResult(w io.Writer, v any, format string) error {
+	if format == "" {
+		return dumpJSON(w, v)
+	}
+
+	b, err := json.MarshalWithOption(v, json.DisableHTMLEscape())
+	if err != nil {
+		return fmt.Errorf("json.MarshalWithOption: %w", err)
+	}
+	switch format {
+	case "yaml":
+		b, err = yaml.JSONToYAML(b)
+		if err != nil {
+			return fmt.Errorf("yaml.JSONToYAML: %w", err)
+		}
+		b = bytes.TrimSuffix(b, []byte("\n"))
+	case "raw":
+		if s, ok := v.(string); ok {
+			b = []byte(s)
+		}
+	}
+
+	if _, err = w.Write(b); err != nil {
+		return fmt.Errorf("w.Write: %w", err)
+	}
+	if _, err = io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("io.WriteString: %w", err)
 	}
 	return nil
 }
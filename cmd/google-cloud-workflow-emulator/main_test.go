@@ -0,0 +1,1380 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jessevdk/go-flags"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflowtest"
+	"github.com/samber/lo"
+)
+
+const testWorkflowSource = `
+main:
+  steps:
+    - done:
+        return: ok
+`
+
+// writeFiles writes the files of the contents by the relative paths in the temporary directory, and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadWorkflows(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		files    map[string]string
+		paths    []string // relative to the directory of the files
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "file",
+			files:    map[string]string{"foo.yaml": testWorkflowSource},
+			paths:    []string{"foo.yaml"},
+			expected: []string{"foo"},
+		},
+		{
+			name:     "repeated files",
+			files:    map[string]string{"foo.yaml": testWorkflowSource, "bar.json": `{"main":{"steps":[{"done":{"return":"ok"}}]}}`},
+			paths:    []string{"foo.yaml", "bar.json"},
+			expected: []string{"bar", "foo"},
+		},
+		{
+			name: "directory",
+			files: map[string]string{
+				"wf/foo.yaml":      testWorkflowSource,
+				"wf/bar.yaml":      testWorkflowSource,
+				"wf/foo_test.yaml": "tests: []",
+				"wf/README.md":     "# workflows",
+				"wf/sub/baz.yaml":  testWorkflowSource,
+			},
+			paths:    []string{"wf"},
+			expected: []string{"bar", "foo"},
+		},
+		{
+			name:     "directory and file",
+			files:    map[string]string{"wf/foo.yaml": testWorkflowSource, "bar.yaml": testWorkflowSource},
+			paths:    []string{"wf", "bar.yaml"},
+			expected: []string{"bar", "foo"},
+		},
+		{
+			name:    "duplicated workflow IDs",
+			files:   map[string]string{"wf/foo.yaml": testWorkflowSource, "foo.json": `{"main":{"steps":[]}}`},
+			paths:   []string{"wf", "foo.json"},
+			wantErr: true,
+		},
+		{
+			name:    "empty directory",
+			files:   map[string]string{"wf/README.md": "# workflows"},
+			paths:   []string{"wf"},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			paths:   []string{"foo.yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported extension",
+			files:   map[string]string{"foo.yml": testWorkflowSource},
+			paths:   []string{"foo.yml"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid workflow",
+			files:   map[string]string{"foo.yaml": "main: ["},
+			paths:   []string{"foo.yaml"},
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := writeFiles(t, tt.files)
+			paths := make([]string, len(tt.paths))
+			for i, path := range tt.paths {
+				paths[i] = filepath.Join(dir, path)
+			}
+
+			roots, err := loadWorkflows(paths)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := make([]string, 0, len(roots))
+			for id, wf := range roots {
+				if wf.Root == nil {
+					t.Errorf("workflow %s is not parsed", id)
+				}
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			if diff := cmp.Diff(tt.expected, ids); diff != "" {
+				t.Errorf("unexpected workflow IDs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadRemoteWorkflows(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wf/foo.yaml":
+			_, _ = w.Write([]byte(testWorkflowSource))
+		case "/wf/bar.json":
+			_, _ = w.Write([]byte(`{"main":{"steps":[{"done":{"return":"ok"}}]}}`))
+		case "/wf/invalid.yaml":
+			_, _ = w.Write([]byte("main: ["))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tt := range []struct {
+		name     string
+		paths    []string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "URL",
+			paths:    []string{ts.URL + "/wf/foo.yaml"},
+			expected: []string{"foo"},
+		},
+		{
+			name:     "URL with the query",
+			paths:    []string{ts.URL + "/wf/bar.json?token=secret"},
+			expected: []string{"bar"},
+		},
+		{
+			name:     "URL and file",
+			paths:    []string{ts.URL + "/wf/foo.yaml", "bar.yaml"},
+			expected: []string{"bar", "foo"},
+		},
+		{
+			name:    "duplicated workflow IDs of the URL and file",
+			paths:   []string{ts.URL + "/wf/foo.yaml", "foo.yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "not found",
+			paths:   []string{ts.URL + "/wf/missing.yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid workflow",
+			paths:   []string{ts.URL + "/wf/invalid.yaml"},
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := writeFiles(t, map[string]string{"foo.yaml": testWorkflowSource, "bar.yaml": testWorkflowSource})
+			paths := make([]string, len(tt.paths))
+			for i, path := range tt.paths {
+				if strings.HasPrefix(path, ts.URL) {
+					paths[i] = path
+				} else {
+					paths[i] = filepath.Join(dir, path)
+				}
+			}
+
+			roots, err := loadWorkflows(paths)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := lo.Keys(roots)
+			sort.Strings(ids)
+			if diff := cmp.Diff(tt.expected, ids); diff != "" {
+				t.Errorf("unexpected workflow IDs (-want +got):\n%s", diff)
+			}
+			for _, id := range ids {
+				if len(roots[id].Source) == 0 {
+					t.Errorf("source of workflow %s is not read", id)
+				}
+			}
+		})
+	}
+}
+
+func TestAdvertisedHost(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name       string
+		listen     string
+		advertised string
+		expected   string
+		wantErr    bool
+	}{
+		{name: "loopback", listen: "127.0.0.1:8080", expected: "127.0.0.1:8080"},
+		{name: "any host", listen: ":8080", expected: "localhost:8080"},
+		{name: "unspecified IPv4", listen: "0.0.0.0:8080", expected: "localhost:8080"},
+		{name: "unspecified IPv6", listen: "[::]:8080", expected: "localhost:8080"},
+		{name: "advertised host", listen: "0.0.0.0:8080", advertised: "emulator", expected: "emulator:8080"},
+		{name: "advertised host with port", listen: "0.0.0.0:8080", advertised: "emulator:80", expected: "emulator:80"},
+		{name: "invalid listen", listen: "localhost", wantErr: true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			host, err := advertisedHost(tt.listen, tt.advertised)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host != tt.expected {
+				t.Errorf("unexpected host: %s, want %s", host, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckpointFile(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name       string
+		content    string // written before loading unless it's empty
+		checkpoint *workflow.Checkpoint
+		wantErr    bool
+	}{
+		{
+			name:       "saved one",
+			checkpoint: &workflow.Checkpoint{Step: "next", Variables: map[string]any{"n": int64(1), "f": 1.0}},
+		},
+		{
+			name:       "overwritten one",
+			content:    `{"step":"first","variables":{}}`,
+			checkpoint: &workflow.Checkpoint{Step: "second", Variables: map[string]any{}},
+		},
+		{
+			name:    "missing file",
+			wantErr: true,
+		},
+		{
+			name:    "invalid file",
+			content: `{"step":`,
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "state.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.checkpoint != nil {
+				if err := saveCheckpoint(path, tt.checkpoint); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			checkpoint, err := loadCheckpoint(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.checkpoint, checkpoint); diff != "" {
+				t.Errorf("unexpected checkpoint (-want +got):\n%s", diff)
+			}
+			if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+				t.Errorf("temporary file should be renamed: %v", err)
+			}
+		})
+	}
+}
+
+// writeSelfSignedCert writes the PEM files of the self-signed certificate for 127.0.0.1 and its private key.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := writeFiles(t, map[string]string{
+		"cert.pem": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		"key.pem":  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	})
+	return filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+}
+
+// TestServeTLS isn't parallel since serveWorkflow is stopped by sending SIGTERM to the test process itself.
+func TestServeTLS(t *testing.T) {
+	t.Cleanup(func() { logging.SetLevel(logging.LevelInfo) })
+
+	certFile, keyFile := writeSelfSignedCert(t)
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	trusted := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	for _, tt := range []struct {
+		name           string
+		tls            bool
+		client         *http.Client
+		scheme         string
+		expectedStatus int
+		wantErr        bool
+	}{
+		{name: "plain HTTP", client: http.DefaultClient, scheme: "http", expectedStatus: http.StatusOK},
+		{name: "HTTPS trusting the certificate", tls: true, client: trusted, scheme: "https", expectedStatus: http.StatusOK},
+		{name: "HTTPS without trusting the certificate", tls: true, client: http.DefaultClient, scheme: "https", wantErr: true},
+		{name: "plain HTTP to TLS", tls: true, client: http.DefaultClient, scheme: "http", expectedStatus: http.StatusBadRequest},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(testWorkflowSource))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+				return map[string]*server.LoadedWorkflow{"test": {Root: root, Source: []byte(testWorkflowSource)}}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := l.Addr().String()
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			opt := &ServeOption{Listen: addr, ShutdownTimeout: 1, LogLevel: "error"}
+			expectedScheme := "http"
+			if tt.tls {
+				opt.TLSCert, opt.TLSKey = certFile, keyFile
+				expectedScheme = "https"
+			}
+			if scheme := apiScheme(opt); scheme != expectedScheme {
+				t.Errorf("unexpected API scheme: %s, want %s", scheme, expectedScheme)
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- serveWorkflow(opt, store) }()
+			t.Cleanup(func() {
+				if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+					t.Fatal(err)
+				}
+				if err := <-done; err != nil {
+					t.Errorf("failed to shut down: %v", err)
+				}
+			})
+
+			// wait for listening by the plain TCP connection not to depend on the scheme
+			for i := 0; ; i++ {
+				conn, err := net.Dial("tcp", addr)
+				if err == nil {
+					conn.Close()
+					break
+				}
+				if i == 100 {
+					t.Fatal(err)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			res, err := tt.client.Get(tt.scheme + "://" + addr + "/v1/projects/p/locations/l/workflows/test/executions")
+			if tt.wantErr {
+				if err == nil {
+					res.Body.Close()
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.expectedStatus {
+				t.Errorf("unexpected status: %d, want %d", res.StatusCode, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSelectSubcommand(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		args     []string
+		runArgs  string // the args of the selected run options
+		listen   string // the listen address of the selected serve options
+		selected string // "run", "serve" or "" for the other subcommands
+		wantErr  bool
+	}{
+		{name: "legacy run", args: []string{"--args", `{"a":1}`}, runArgs: `{"a":1}`, selected: "run"},
+		{name: "legacy serve", args: []string{"--listen", ":8080"}, listen: ":8080", selected: "serve"},
+		{name: "legacy serve by gRPC", args: []string{"--grpc-listen", ":9090"}, selected: "serve"},
+		{name: "legacy args with listen", args: []string{"--listen", ":8080", "--args", "{}"}, wantErr: true},
+		{name: "legacy checkpoint with listen", args: []string{"--listen", ":8080", "--checkpoint", "state.json"}, wantErr: true},
+		{name: "legacy watch with listen", args: []string{"--listen", ":8080", "--watch"}, wantErr: true},
+		{name: "run", args: []string{"run", "--args", `{"a":1}`}, runArgs: `{"a":1}`, selected: "run"},
+		{name: "serve", args: []string{"serve", "--listen", ":8080"}, listen: ":8080", selected: "serve"},
+		{name: "serve without listen", args: []string{"serve"}, wantErr: true},
+		{name: "legacy option with subcommand", args: []string{"--args", "{}", "run"}, wantErr: true},
+		{name: "validate", args: []string{"validate"}, selected: ""},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var opt Option
+			parser := flags.NewParser(&opt, flags.None)
+			parser.SubcommandsOptional = true
+			if _, err := parser.ParseArgs(append([]string{"-f", "wf.yaml"}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+
+			runOpt, serveOpt, err := selectSubcommand(parser, &opt)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			selected := ""
+			switch {
+			case runOpt != nil && serveOpt != nil:
+				t.Fatal("either of the options should be nil")
+			case runOpt != nil:
+				selected = "run"
+				if runOpt.Args != tt.runArgs {
+					t.Errorf("unexpected args: %s, want %s", runOpt.Args, tt.runArgs)
+				}
+			case serveOpt != nil:
+				selected = "serve"
+				if serveOpt.Listen != tt.listen {
+					t.Errorf("unexpected listen: %s, want %s", serveOpt.Listen, tt.listen)
+				}
+			}
+			if selected != tt.selected {
+				t.Errorf("unexpected subcommand: %q, want %q", selected, tt.selected)
+			}
+		})
+	}
+}
+
+func TestRequiresFiles(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{name: "legacy run", args: []string{"--args", "{}"}, expected: true},
+		{name: "run", args: []string{"run"}, expected: true},
+		{name: "lsp", args: []string{"lsp"}, expected: false},
+		{name: "schema", args: []string{"--project", "my-project", "schema"}, expected: false},
+		{name: "value of the option named as the subcommand", args: []string{"--project", "lsp"}, expected: true},
+		{name: "value of the option of the subcommand named as the subcommand", args: []string{"run", "--args", "schema"}, expected: true},
+		{name: "invalid option", args: []string{"--unknown", "lsp"}, expected: true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := requiresFiles(tt.args); got != tt.expected {
+				t.Errorf("unexpected result: %t, want %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateWorkflowFile(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{
+		"valid.yaml":   testWorkflowSource,
+		"invalid.yaml": "main:\n  steps:\n    - bad:\n        call: no.such.function\n",
+		"invalid.json": `{"main": {"steps": [{"bad": {"call": "no.such.function"}}]}}`,
+		"workflow.txt": testWorkflowSource,
+	})
+	for _, tt := range []struct {
+		name     string
+		file     string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "valid YAML", file: "valid.yaml", expected: []string{}},
+		{name: "invalid YAML", file: "invalid.yaml", expected: []string{`3:7: main > bad: unknown call "no.such.function"`}},
+		{name: "invalid JSON", file: "invalid.json", expected: []string{`1:22: main > bad: unknown call "no.such.function"`}},
+		{name: "unsupported extension", file: "workflow.txt", wantErr: true},
+		{name: "missing file", file: "missing.yaml", wantErr: true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			errs, err := validateWorkflow(filepath.Join(dir, tt.file))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := lo.Map(errs, func(e *workflow.ValidationError, _ int) string { return e.Error() })
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("unexpected errors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMatchSnapshot(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - init:
+        assign:
+          - n: 1
+    - done:
+        return: ${n}
+`
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := &workflow.Trace{}
+	if _, err := root.Execute(context.Background(), nil, workflow.WithTrace(trace)); err != nil {
+		t.Fatal(err)
+	}
+	current := strings.Join(trace.Lines(), "\n") + "\n"
+	const diverged = `{"step":"init"}` + "\n" + `{"assign":{"n":2}}` + "\n"
+
+	for _, tt := range []struct {
+		name     string
+		snapshot string // written before matching unless it's empty
+		update   bool
+		matched  bool
+		expected string // the snapshot after matching
+	}{
+		{name: "new snapshot", matched: true, expected: current},
+		{name: "same snapshot", snapshot: current, matched: true, expected: current},
+		{name: "diverged snapshot", snapshot: diverged, matched: false, expected: diverged},
+		{name: "updated snapshot", snapshot: diverged, update: true, matched: true, expected: current},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "trace.jsonl")
+			if tt.snapshot != "" {
+				if err := os.WriteFile(path, []byte(tt.snapshot), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			matched, err := matchSnapshot(path, tt.update, trace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched != tt.matched {
+				t.Errorf("unexpected matched: %v, want %v", matched, tt.matched)
+			}
+			snapshot, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, string(snapshot)); diff != "" {
+				t.Errorf("unexpected snapshot (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInitWorkflow(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		files    map[string]string // existing files
+		file     string
+		expected int
+	}{
+		{name: "new workflow", file: "hello.yaml", expected: 0},
+		{name: "existing workflow", files: map[string]string{"hello.yaml": testWorkflowSource}, file: "hello.yaml", expected: 1},
+		{name: "existing test cases", files: map[string]string{"hello_test.yaml": "[]"}, file: "hello.yaml", expected: 1},
+		{name: "JSON workflow", file: "hello.json", expected: 1},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := writeFiles(t, tt.files)
+			opt := &Option{File: []string{filepath.Join(dir, tt.file)}}
+			if code := initWorkflow(opt); code != tt.expected {
+				t.Fatalf("unexpected exit code: %d, want %d", code, tt.expected)
+			}
+			if tt.expected != 0 {
+				// the existing files are not overwritten
+				for name, content := range tt.files {
+					b, err := os.ReadFile(filepath.Join(dir, name))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if string(b) != content {
+						t.Errorf("%s should not be overwritten", name)
+					}
+				}
+				return
+			}
+
+			for name, expected := range map[string]string{
+				"hello.yaml":      workflowtest.ScaffoldWorkflow,
+				"hello_test.yaml": workflowtest.ScaffoldTestCases,
+			} {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != expected {
+					t.Errorf("unexpected content of %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestListTestFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{
+		"tested.yaml":      testWorkflowSource,
+		"tested_test.yaml": "[]",
+		"untested.yaml":    testWorkflowSource,
+	})
+	for _, tt := range []struct {
+		name     string
+		files    []string
+		tests    []string
+		expected []testFile
+		wantErr  bool
+	}{
+		{
+			name:     "test cases next to the workflow files",
+			files:    []string{dir},
+			expected: []testFile{{path: filepath.Join(dir, "tested_test.yaml"), workflowID: "tested"}},
+		},
+		{
+			name:     "test cases by --tests",
+			files:    []string{dir},
+			tests:    []string{"a_test.yaml", "b_test.yaml"},
+			expected: []testFile{{path: "a_test.yaml"}, {path: "b_test.yaml"}},
+		},
+		{
+			name:    "no test cases",
+			files:   []string{filepath.Join(dir, "untested.yaml")},
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opt := &Option{File: tt.files}
+			opt.Test.Tests = tt.tests
+			testFiles, err := listTestFiles(opt)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, testFiles, cmp.AllowUnexported(testFile{})); diff != "" {
+				t.Errorf("unexpected test files (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseWorkflowArgs(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{"args.yaml": "name: foo\nitems:\n  - 1\n  - 2.5\n"})
+	for _, tt := range []struct {
+		name     string
+		argsJSON string
+		argsYAML string
+		expected any
+		wantErr  bool
+	}{
+		{
+			name:     "no arguments",
+			expected: nil,
+		},
+		{
+			name:     "JSON",
+			argsJSON: `{"name":"foo","items":[1,2.5]}`,
+			expected: map[string]any{"name": "foo", "items": []any{1.0, 2.5}},
+		},
+		{
+			name:     "inline YAML",
+			argsYAML: "{name: foo, items: [1, 2.5], nested: {enabled: true}}",
+			expected: map[string]any{"name": "foo", "items": []any{1.0, 2.5}, "nested": map[string]any{"enabled": true}},
+		},
+		{
+			name:     "YAML file",
+			argsYAML: filepath.Join(dir, "args.yaml"),
+			expected: map[string]any{"name": "foo", "items": []any{1.0, 2.5}},
+		},
+		{
+			name:     "exclusive JSON and YAML",
+			argsJSON: `{}`,
+			argsYAML: "{}",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid JSON",
+			argsJSON: `{`,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid YAML",
+			argsYAML: "{name: [",
+			wantErr:  true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			args, err := parseWorkflowArgs(tt.argsJSON, tt.argsYAML)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, args); diff != "" {
+				t.Errorf("unexpected arguments (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDumpResult(t *testing.T) {
+	t.Parallel()
+
+	result := map[string]any{"name": "<foo>", "items": []any{int64(1), 2.5}}
+	for _, tt := range []struct {
+		name     string
+		value    any
+		format   string
+		expected string
+	}{
+		{
+			name:     "indented JSON by default",
+			value:    result,
+			expected: "{\n\t\"items\": [\n\t\t1,\n\t\t2.5\n\t],\n\t\"name\": \"<foo>\"\n}\n",
+		},
+		{
+			name:     "compact JSON",
+			value:    result,
+			format:   "json",
+			expected: "{\"items\":[1,2.5],\"name\":\"<foo>\"}\n",
+		},
+		{
+			name:     "YAML",
+			value:    result,
+			format:   "yaml",
+			expected: "items:\n- 1\n- 2.5\nname: <foo>\n",
+		},
+		{
+			name:     "raw string",
+			value:    "hello \"world\"",
+			format:   "raw",
+			expected: "hello \"world\"\n",
+		},
+		{
+			name:     "raw non-string as compact JSON",
+			value:    result,
+			format:   "raw",
+			expected: "{\"items\":[1,2.5],\"name\":\"<foo>\"}\n",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf strings.Builder
+			if err := dumpResult(&buf, tt.value, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		pairs    []string
+		expected map[string]int
+		wantErr  bool
+	}{
+		{
+			name:     "no pairs",
+			expected: map[string]int{},
+		},
+		{
+			name:     "pairs",
+			pairs:    []string{"HttpError=3", "TimeoutError=4", "HttpError=5"},
+			expected: map[string]int{"HttpError": 5, "TimeoutError": 4},
+		},
+		{
+			name:    "missing code",
+			pairs:   []string{"HttpError"},
+			wantErr: true,
+		},
+		{
+			name:    "missing tag",
+			pairs:   []string{"=3"},
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			pairs:   []string{"HttpError=x"},
+			wantErr: true,
+		},
+		{
+			name:    "out of range",
+			pairs:   []string{"HttpError=256"},
+			wantErr: true,
+		},
+		{
+			name:    "zero",
+			pairs:   []string{"HttpError=0"},
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			codes, err := parseExitCodes(tt.pairs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, codes); diff != "" {
+				t.Errorf("unexpected exit codes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExceptionExitCode(t *testing.T) {
+	t.Parallel()
+
+	codes := map[string]int{"HttpError": 3, "TimeoutError": 4, "ConnectionError": 5}
+	for _, tt := range []struct {
+		name      string
+		exception types.Exception
+		expected  int
+	}{
+		{
+			name:      "mapped tag",
+			exception: &types.Error{Tag: types.HttpErrorTag, Err: errors.New("404 Not Found")},
+			expected:  3,
+		},
+		{
+			name:      "first mapped tag of the wrapped errors",
+			exception: &types.Error{Tag: types.ConnectionFailedErrorTag, Err: &types.Error{Tag: types.ConnectionErrorTag, Err: &types.Error{Tag: types.TimeoutErrorTag}}},
+			expected:  5,
+		},
+		{
+			name:      "unmapped tag",
+			exception: &types.Error{Tag: types.KeyErrorTag},
+			expected:  1,
+		},
+		{
+			name:      "tags of the custom map exception",
+			exception: types.NewExceptionByMap(map[string]any{"tags": []any{"MyError", "TimeoutError"}}),
+			expected:  4,
+		},
+		{
+			name:      "string exception",
+			exception: types.NewExceptionByString("HttpError"),
+			expected:  1,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := exceptionExitCode(tt.exception, codes); got != tt.expected {
+				t.Errorf("exceptionExitCode() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestWatchWorkflow isn't parallel since watchWorkflow is stopped by sending SIGINT to the test process itself, and the
+// results and the separators of the runs are written to the replaced os.Stdout and os.Stderr.
+func TestWatchWorkflow(t *testing.T) {
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+          query:
+            workflow: "%[1]s"
+            args: ${args.version}
+    - done:
+        return: ${"%[1]s/" + args.version}
+`
+
+	requests := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.RawQuery
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tt := range []struct {
+		name             string
+		modify           string
+		content          string
+		expectedRequests []string
+		expectedResults  string
+	}{
+		{
+			name:             "modified workflow file",
+			modify:           "wf.yaml",
+			content:          fmt.Sprintf(source, "v2"),
+			expectedRequests: []string{"args=v1&workflow=v1", "args=v1&workflow=v2"},
+			expectedResults:  "v1/v1\nv2/v1\n",
+		},
+		{
+			name:             "modified args file",
+			modify:           "args.yaml",
+			content:          fmt.Sprintf("{url: %q, version: v2}", ts.URL),
+			expectedRequests: []string{"args=v1&workflow=v1", "args=v2&workflow=v1"},
+			expectedResults:  "v1/v1\nv1/v2\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{
+				"wf.yaml":   fmt.Sprintf(source, "v1"),
+				"args.yaml": fmt.Sprintf("{url: %q, version: v1}", ts.URL),
+			})
+			stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdout.Close()
+			stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stderr.Close()
+
+			origStdout, origStderr := os.Stdout, os.Stderr
+			os.Stdout, os.Stderr = stdout, stderr
+			defer func() { os.Stdout, os.Stderr = origStdout, origStderr }()
+			done := make(chan int)
+			go func() {
+				runOpt := &RunOption{ArgsYAML: filepath.Join(dir, "args.yaml"), Output: "raw"}
+				done <- watchWorkflow(&Option{File: []string{filepath.Join(dir, "wf.yaml")}}, runOpt, nil)
+			}()
+
+			var got []string
+			for len(got) < len(tt.expectedRequests) {
+				select {
+				case query := <-requests:
+					got = append(got, query)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out waiting for the execution #%d", len(got)+1)
+				}
+				if len(got) == 1 {
+					if err := os.WriteFile(filepath.Join(dir, tt.modify), []byte(tt.content), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if diff := cmp.Diff(tt.expectedRequests, got); diff != "" {
+				t.Errorf("unexpected requests (-want +got):\n%s", diff)
+			}
+
+			// wait for the last run to be done before interrupting the execution
+			lastDone := fmt.Sprintf("=== DONE #%d:", len(tt.expectedRequests))
+			for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+				if b, err := os.ReadFile(stderr.Name()); err == nil && strings.Contains(string(b), lastDone) {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("timed out waiting for the last run")
+				}
+			}
+			if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+				t.Fatal(err)
+			}
+			if code := <-done; code != 0 {
+				t.Errorf("unexpected exit code: %d", code)
+			}
+
+			b, err := os.ReadFile(stdout.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedResults, string(b)); diff != "" {
+				t.Errorf("unexpected results (-want +got):\n%s", diff)
+			}
+
+			b, err = os.ReadFile(stderr.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			separators := lo.Filter(strings.Split(string(b), "\n"), func(line string, _ int) bool {
+				return strings.HasPrefix(line, "=== ")
+			})
+			expectedSeparators := []string{"=== RUN #1", "=== DONE #1: exit status 0", "=== RUN #2", "=== DONE #2: exit status 0"}
+			if len(separators) != len(expectedSeparators) {
+				t.Fatalf("unexpected separators: %q", separators)
+			}
+			for i, prefix := range expectedSeparators {
+				if !strings.HasPrefix(separators[i], prefix) {
+					t.Errorf("separator #%d = %q, want the prefix %q", i+1, separators[i], prefix)
+				}
+			}
+		})
+	}
+
+	t.Run("no local files", func(t *testing.T) {
+		if code := watchWorkflow(&Option{File: []string{ts.URL + "/wf.yaml"}}, &RunOption{}, nil); code != 1 {
+			t.Errorf("unexpected exit code: %d", code)
+		}
+	})
+}
+
+func TestLoadBatchInputs(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{
+		"args.jsonl":    "{\"x\": 1}\n\n  [1, 2]  \n\"s\"\n",
+		"invalid.jsonl": "{\"x\": 1}\n{\"x\":\n",
+	})
+	for _, tt := range []struct {
+		name     string
+		file     string
+		expected []string
+		lines    []int
+		wantErr  bool
+	}{
+		{
+			name:     "JSON lines",
+			file:     "args.jsonl",
+			expected: []string{`{"x": 1}`, `[1, 2]`, `"s"`},
+			lines:    []int{1, 3, 4},
+		},
+		{
+			name:    "invalid JSON",
+			file:    "invalid.jsonl",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			file:    "missing.jsonl",
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			inputs, err := loadBatchInputs(filepath.Join(dir, tt.file))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := lo.Map(inputs, func(input *batchInput, _ int) string { return string(input.args) })
+			if diff := cmp.Diff(tt.expected, args); diff != "" {
+				t.Errorf("unexpected args (-want +got):\n%s", diff)
+			}
+			lines := lo.Map(inputs, func(input *batchInput, _ int) int { return input.line })
+			if diff := cmp.Diff(tt.lines, lines); diff != "" {
+				t.Errorf("unexpected lines (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestExecuteBatch isn't parallel since the outputs are written to the replaced os.Stdout.
+func TestExecuteBatch(t *testing.T) {
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(`
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${args.x == 0}
+            raise:
+              message: x must not be zero
+              tags: [ValueError]
+    - divide:
+        return: ${10 / args.x}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		args         []string
+		parallel     int
+		expected     string
+		expectedCode int
+	}{
+		{
+			name:     "succeeded inputs in parallel",
+			args:     []string{`{"x": 1}`, `{"x": 2}`, `{"x": 4}`, `{"x": 5}`},
+			parallel: 3,
+			expected: `{"line":1,"result":10}
+{"line":2,"result":5}
+{"line":3,"result":2.5}
+{"line":4,"result":2}
+`,
+		},
+		{
+			name: "failed inputs sequentially",
+			args: []string{`{"x": 2}`, `{"x": 0}`, `{}`},
+			expected: `{"line":1,"result":5}
+{"line":2,"error":"check: custom map exception: {\"message\":\"x must not be zero\",\"tags\":[\"ValueError\"]}","exception":{"message":"x must not be zero","tags":["ValueError"]}}
+{"line":3,"error":"check: invalid condition[0]: left of operator \"==\": KeyError: args.x: not found","exception":{"message":"KeyError: args.x: not found","tags":["KeyError"]}}
+`,
+			expectedCode: 4,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdout.Close()
+			origStdout := os.Stdout
+			os.Stdout = stdout
+			defer func() { os.Stdout = origStdout }()
+
+			inputs := make([]*batchInput, len(tt.args))
+			for i, args := range tt.args {
+				inputs[i] = &batchInput{line: i + 1, args: json.RawMessage(args)}
+			}
+			codes := map[string]int{"ValueError": 4, "KeyError": 5}
+			if code := executeBatch(context.Background(), root, inputs, tt.parallel, codes, nil); code != tt.expectedCode {
+				t.Errorf("unexpected exit code: %d", code)
+			}
+
+			b, err := os.ReadFile(stdout.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, string(b)); diff != "" {
+				t.Errorf("unexpected outputs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadSymbols(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		pairs    []string
+		expected map[string]any
+		wantErr  bool
+	}{
+		{
+			name:  "JSON values",
+			pairs: []string{`company.regions=["us-central1","asia-northeast1"]`, `company.name="example"`, `company.limits={"max":10}`},
+			expected: map[string]any{
+				"company.regions": []any{"us-central1", "asia-northeast1"},
+				"company.name":    "example",
+				"company.limits":  map[string]any{"max": 10.0},
+			},
+		},
+		{
+			name:     "last one wins",
+			pairs:    []string{`company.name="a"`, `company.name="b"`},
+			expected: map[string]any{"company.name": "b"},
+		},
+		{
+			name:    "not a pair",
+			pairs:   []string{`company.name`},
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			pairs:   []string{`company.name=example`},
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			symbols, err := loadSymbols(tt.pairs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, symbols); diff != "" {
+				t.Errorf("unexpected symbols (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
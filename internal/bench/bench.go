@@ -0,0 +1,228 @@
+// Package bench executes a workflow repeatedly with concurrency, and measures the latencies and the allocations of the
+// executions and the durations of the steps, e.g. to find the slow steps and to track the performance of the executor.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// maxReportedSteps is the number of the slowest steps in the report.
+const maxReportedSteps = 10
+
+// Result is the measurements of the executions.
+type Result struct {
+	Executions  int
+	Concurrency int
+	Failures    int
+	FirstError  error // of the failed executions, or nil
+	Elapsed     time.Duration
+	Latencies   []time.Duration // of the executions in ascending order
+	Mallocs     uint64          // of all executions
+	TotalAlloc  uint64          // bytes of all executions
+	Steps       []*StepStat     // in descending order of the total durations
+}
+
+// StepStat is the durations of the executed steps of a name.
+type StepStat struct {
+	Step  workflow.StepName
+	Count int
+	Total time.Duration // including the nested steps
+}
+
+// Run executes the main workflow of the root count times by the concurrency with the copies of the arguments.
+// The executions are not aborted by the failures of the others, but are by the context.
+func Run(ctx context.Context, root workflow.WorkflowRoot, args any, count, concurrency int, opts ...workflow.ExecuteOption) (*Result, error) {
+	if count < 1 || concurrency < 1 {
+		return nil, fmt.Errorf("the count and the concurrency must be positive")
+	}
+
+	// the workflows can modify their arguments in place
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	argsList := make([]any, count)
+	for i := range argsList {
+		if err := json.Unmarshal(argsJSON, &argsList[i]); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+
+	timer := &stepTimer{base: time.Now(), steps: map[workflow.StepName]*stepTotal{}}
+	opts = append(opts[:len(opts):len(opts)], workflow.WithStepObserver(timer))
+	result := &Result{Executions: count, Concurrency: concurrency, Latencies: make([]time.Duration, count)}
+	errs := make([]error, count)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	startedAt := time.Now()
+
+	var next int64 = -1
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= count || ctx.Err() != nil {
+					return
+				}
+
+				executedAt := time.Now()
+				_, errs[i] = root.Execute(ctx, argsList[i], opts...)
+				result.Latencies[i] = time.Since(executedAt)
+			}
+		}()
+	}
+	wg.Wait()
+
+	result.Elapsed = time.Since(startedAt)
+	runtime.ReadMemStats(&after)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result.Mallocs = after.Mallocs - before.Mallocs
+	result.TotalAlloc = after.TotalAlloc - before.TotalAlloc
+	for _, err := range errs {
+		if err != nil {
+			if result.FirstError == nil {
+				result.FirstError = err
+			}
+			result.Failures++
+		}
+	}
+	sort.Slice(result.Latencies, func(i, j int) bool {
+		return result.Latencies[i] < result.Latencies[j]
+	})
+	result.Steps = timer.stats()
+	return result, nil
+}
+
+// Percentile returns the latency of the percentile (0-100) by the nearest-rank method.
+func (r *Result) Percentile(p float64) time.Duration {
+	i := int(math.Ceil(p/100*float64(len(r.Latencies)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(r.Latencies) {
+		i = len(r.Latencies) - 1
+	}
+	return r.Latencies[i]
+}
+
+// Mean returns the mean of the latencies.
+func (r *Result) Mean() time.Duration {
+	var total time.Duration
+	for _, latency := range r.Latencies {
+		total += latency
+	}
+	return total / time.Duration(len(r.Latencies))
+}
+
+// Report writes the human-readable report of the result into w.
+func (r *Result) Report(w io.Writer) error {
+	executions := float64(r.Executions)
+	fmt.Fprintf(w, "executions:  %d (concurrency: %d, failures: %d)\n", r.Executions, r.Concurrency, r.Failures)
+	if r.FirstError != nil {
+		fmt.Fprintf(w, "first error: %v\n", r.FirstError)
+	}
+	fmt.Fprintf(w, "elapsed:     %s (%.1f executions/s)\n", round(r.Elapsed), executions/r.Elapsed.Seconds())
+	fmt.Fprintf(w, "latency:     min %s, p50 %s, p90 %s, p95 %s, p99 %s, max %s, mean %s\n",
+		round(r.Latencies[0]), round(r.Percentile(50)), round(r.Percentile(90)), round(r.Percentile(95)),
+		round(r.Percentile(99)), round(r.Latencies[len(r.Latencies)-1]), round(r.Mean()))
+	fmt.Fprintf(w, "allocations: %.0f allocs/execution, %.1f KiB/execution\n", float64(r.Mallocs)/executions, float64(r.TotalAlloc)/executions/1024)
+
+	if len(r.Steps) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "slowest steps (including the nested steps):")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  STEP\tCOUNT\tTOTAL\tMEAN")
+	for i, step := range r.Steps {
+		if i == maxReportedSteps {
+			break
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%s\t%s\n", step.Step, step.Count, round(step.Total), round(step.Total/time.Duration(step.Count)))
+	}
+	return tw.Flush()
+}
+
+// round rounds the duration to be readable.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	default:
+		return d
+	}
+}
+
+// stepTimer totals the durations of the steps by their names. The executions are concurrent and the starts and the
+// finishes of the steps of a name are not paired, so it subtracts the starts from the total and adds the finishes to it.
+type stepTimer struct {
+	base  time.Time
+	mu    sync.Mutex
+	steps map[workflow.StepName]*stepTotal
+}
+
+type stepTotal struct {
+	count int
+	total time.Duration
+}
+
+var _ workflow.StepObserver = (*stepTimer)(nil)
+
+func (t *stepTimer) StepStarted(step workflow.StepName) {
+	elapsed := time.Since(t.base)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.get(step).total -= elapsed
+}
+
+func (t *stepTimer) StepFinished(step workflow.StepName, _ error) {
+	elapsed := time.Since(t.base)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	total := t.get(step)
+	total.total += elapsed
+	total.count++
+}
+
+func (t *stepTimer) get(step workflow.StepName) *stepTotal {
+	total, ok := t.steps[step]
+	if !ok {
+		total = &stepTotal{}
+		t.steps[step] = total
+	}
+	return total
+}
+
+func (t *stepTimer) stats() []*StepStat {
+	stats := make([]*StepStat, 0, len(t.steps))
+	for step, total := range t.steps {
+		stats = append(stats, &StepStat{Step: step, Count: total.count, Total: total.total})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Total != stats[j].Total {
+			return stats[i].Total > stats[j].Total
+		}
+		return stats[i].Step < stats[j].Step
+	})
+	return stats
+}
@@ -0,0 +1,247 @@
+package bench_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/bench"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tt := range []struct {
+		name             string
+		ctx              context.Context
+		source           string
+		count            int
+		concurrency      int
+		expectedFailures int
+		expectedSteps    map[workflow.StepName]int
+		expectedSlowest  workflow.StepName
+		wantErr          bool
+	}{
+		{
+			name: "succeeded executions",
+			ctx:  context.Background(),
+			source: `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - args.n: ${args.n + 1}
+    - slow:
+        call: sys.sleep
+        args:
+          seconds: 0.01
+    - done:
+        return: ${args.n}
+`,
+			count:           5,
+			concurrency:     2,
+			expectedSteps:   map[workflow.StepName]int{"init": 5, "slow": 5, "done": 5},
+			expectedSlowest: "slow",
+		},
+		{
+			name: "failed executions",
+			ctx:  context.Background(),
+			source: `
+main:
+  steps:
+    - fail:
+        raise: boom
+`,
+			count:            3,
+			concurrency:      5,
+			expectedFailures: 3,
+			expectedSteps:    map[workflow.StepName]int{"fail": 3},
+			expectedSlowest:  "fail",
+		},
+		{
+			name:        "zero count",
+			ctx:         context.Background(),
+			source:      "main:\n  steps:\n    - done:\n        return: ok\n",
+			count:       0,
+			concurrency: 1,
+			wantErr:     true,
+		},
+		{
+			name:        "zero concurrency",
+			ctx:         context.Background(),
+			source:      "main:\n  steps:\n    - done:\n        return: ok\n",
+			count:       1,
+			concurrency: 0,
+			wantErr:     true,
+		},
+		{
+			name:        "cancelled context",
+			ctx:         cancelled,
+			source:      "main:\n  steps:\n    - done:\n        return: ok\n",
+			count:       1,
+			concurrency: 1,
+			wantErr:     true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := bench.Run(tt.ctx, root, map[string]any{"n": int64(1)}, tt.count, tt.concurrency)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Executions != tt.count || result.Concurrency != tt.concurrency {
+				t.Errorf("unexpected executions and concurrency: %d, %d", result.Executions, result.Concurrency)
+			}
+			if result.Failures != tt.expectedFailures {
+				t.Errorf("unexpected failures: %d", result.Failures)
+			}
+			if (result.FirstError != nil) != (tt.expectedFailures != 0) {
+				t.Errorf("unexpected first error: %v", result.FirstError)
+			}
+			if len(result.Latencies) != tt.count {
+				t.Fatalf("unexpected latencies: %v", result.Latencies)
+			}
+			for i := 1; i < len(result.Latencies); i++ {
+				if result.Latencies[i-1] > result.Latencies[i] {
+					t.Errorf("latencies are not sorted: %v", result.Latencies)
+					break
+				}
+			}
+
+			steps := make(map[workflow.StepName]int, len(result.Steps))
+			for _, step := range result.Steps {
+				steps[step.Step] = step.Count
+				if step.Total <= 0 {
+					t.Errorf("unexpected total of %s: %s", step.Step, step.Total)
+				}
+			}
+			if diff := cmp.Diff(tt.expectedSteps, steps); diff != "" {
+				t.Errorf("unexpected steps (-want +got):\n%s", diff)
+			}
+			if result.Steps[0].Step != tt.expectedSlowest {
+				t.Errorf("unexpected slowest step: %s", result.Steps[0].Step)
+			}
+		})
+	}
+}
+
+func TestResultReport(t *testing.T) {
+	t.Parallel()
+
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	steps := make([]*bench.StepStat, 12)
+	for i := range steps {
+		steps[i] = &bench.StepStat{Step: workflow.StepName("step" + string(rune('a'+i))), Count: 2, Total: time.Duration(12-i) * time.Second}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		result   *bench.Result
+		expected string
+	}{
+		{
+			name: "succeeded executions",
+			result: &bench.Result{
+				Executions:  100,
+				Concurrency: 4,
+				Elapsed:     2 * time.Second,
+				Latencies:   latencies,
+				Mallocs:     1000,
+				TotalAlloc:  204800,
+				Steps:       steps[:2],
+			},
+			expected: `executions:  100 (concurrency: 4, failures: 0)
+elapsed:     2s (50.0 executions/s)
+latency:     min 1ms, p50 50ms, p90 90ms, p95 95ms, p99 99ms, max 100ms, mean 50.5ms
+allocations: 10 allocs/execution, 2.0 KiB/execution
+slowest steps (including the nested steps):
+  STEP   COUNT  TOTAL  MEAN
+  stepa  2      12s    6s
+  stepb  2      11s    5.5s
+`,
+		},
+		{
+			name: "failed executions with the steps more than the reported",
+			result: &bench.Result{
+				Executions:  1,
+				Concurrency: 1,
+				Failures:    1,
+				FirstError:  errors.New("fail: boom"),
+				Elapsed:     1234567 * time.Nanosecond,
+				Latencies:   []time.Duration{1234567 * time.Nanosecond},
+				Steps:       steps,
+			},
+			expected: `executions:  1 (concurrency: 1, failures: 1)
+first error: fail: boom
+elapsed:     1.235ms (810.0 executions/s)
+latency:     min 1.235ms, p50 1.235ms, p90 1.235ms, p95 1.235ms, p99 1.235ms, max 1.235ms, mean 1.235ms
+allocations: 0 allocs/execution, 0.0 KiB/execution
+slowest steps (including the nested steps):
+  STEP   COUNT  TOTAL  MEAN
+  stepa  2      12s    6s
+  stepb  2      11s    5.5s
+  stepc  2      10s    5s
+  stepd  2      9s     4.5s
+  stepe  2      8s     4s
+  stepf  2      7s     3.5s
+  stepg  2      6s     3s
+  steph  2      5s     2.5s
+  stepi  2      4s     2s
+  stepj  2      3s     1.5s
+`,
+		},
+		{
+			name: "no steps",
+			result: &bench.Result{
+				Executions:  2,
+				Concurrency: 1,
+				Elapsed:     500 * time.Microsecond,
+				Latencies:   []time.Duration{100 * time.Microsecond, 300 * time.Microsecond},
+			},
+			expected: `executions:  2 (concurrency: 1, failures: 0)
+elapsed:     500µs (4000.0 executions/s)
+latency:     min 100µs, p50 100µs, p90 300µs, p95 300µs, p99 300µs, max 300µs, mean 200µs
+allocations: 0 allocs/execution, 0.0 KiB/execution
+`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf strings.Builder
+			if err := tt.result.Report(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("unexpected report (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package debugger pauses the executions of the workflows at the breakpoints on the steps, and reads the commands to
+// inspect and modify the variables and to resume the executions by the step hook of the executor.
+package debugger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// ErrQuit is the error of the execution aborted by the quit command.
+var ErrQuit = errors.New("quit by the debugger")
+
+// help is the help of the commands.
+const help = `commands:
+  c, continue         resume until the next breakpoint
+  s, step             step into the next step (including the steps of the subworkflows)
+  n, next             step over the nested steps and the calls of the subworkflows
+  o, out              step out of the step containing the current step
+  b, break STEP       set the breakpoint on the step (STEP or ROUTINE.STEP)
+  d, delete STEP      delete the breakpoint on the step
+  breakpoints         list the breakpoints
+  p, print [NAME]     print the variables, or the variable of the name
+  set NAME JSON       set the value of the variable
+  w, where            print the routine and the path of the current step
+  h, help             print this help
+  q, quit             abort the execution
+`
+
+type mode int
+
+const (
+	modeContinue mode = iota
+	modeStep
+	modeNext
+	modeOut
+)
+
+// Debugger is the step hook pausing the execution. It pauses at the first step, and then by the commands.
+// The parallel steps must be serialized, since it pauses one step at a time.
+type Debugger struct {
+	in          *bufio.Scanner
+	out         io.Writer
+	breakpoints map[string]bool
+	mode        mode
+	depth       int // of the path of the step paused last
+	detached    bool
+}
+
+// New returns the debugger reading the commands from in and printing into out with the initial breakpoints.
+func New(in io.Reader, out io.Writer, breakpoints []string) *Debugger {
+	d := &Debugger{
+		in:          bufio.NewScanner(in),
+		out:         out,
+		breakpoints: map[string]bool{},
+		mode:        modeStep,
+	}
+	for _, step := range breakpoints {
+		d.breakpoints[step] = true
+	}
+	return d
+}
+
+// Hook pauses the execution before the step if it should, and reads the commands until resuming it.
+// The execution is resumed without pausing anymore at the end of the commands.
+func (d *Debugger) Hook(_ context.Context, state *workflow.StepState) error {
+	if d.detached || !d.shouldPause(state) {
+		return nil
+	}
+	d.depth = len(state.Path)
+	d.printLocation(state)
+
+	for {
+		fmt.Fprint(d.out, "(debug) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.detached = true
+			return d.in.Err()
+		}
+
+		fields := strings.Fields(d.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		command, args := fields[0], fields[1:]
+		switch command {
+		case "c", "continue":
+			d.mode = modeContinue
+			return nil
+		case "s", "step":
+			d.mode = modeStep
+			return nil
+		case "n", "next":
+			d.mode = modeNext
+			return nil
+		case "o", "out":
+			d.mode = modeOut
+			return nil
+		case "b", "break":
+			for _, step := range args {
+				d.breakpoints[step] = true
+			}
+		case "d", "delete":
+			for _, step := range args {
+				delete(d.breakpoints, step)
+			}
+		case "breakpoints":
+			steps := make([]string, 0, len(d.breakpoints))
+			for step := range d.breakpoints {
+				steps = append(steps, step)
+			}
+			sort.Strings(steps)
+			for _, step := range steps {
+				fmt.Fprintln(d.out, step)
+			}
+		case "p", "print":
+			d.printVariables(state, args)
+		case "set":
+			if len(args) < 2 {
+				fmt.Fprintln(d.out, "usage: set NAME JSON")
+				continue
+			}
+			// the JSON can contain the spaces
+			rest := strings.TrimSpace(strings.TrimSpace(d.in.Text())[len(command):])
+			value := strings.TrimSpace(rest[len(args[0]):])
+			if err := state.SetVariable(args[0], []byte(value)); err != nil {
+				fmt.Fprintf(d.out, "failed to set %s: %v\n", args[0], err)
+			}
+		case "w", "where":
+			d.printLocation(state)
+		case "h", "help":
+			fmt.Fprint(d.out, help)
+		case "q", "quit":
+			d.detached = true
+			return ErrQuit
+		default:
+			fmt.Fprintf(d.out, "unknown command: %s\n%s", command, help)
+		}
+	}
+}
+
+func (d *Debugger) shouldPause(state *workflow.StepState) bool {
+	if d.breakpoints[string(state.Step)] || d.breakpoints[state.Routine+"."+string(state.Step)] {
+		return true
+	}
+
+	switch d.mode {
+	case modeStep:
+		return true
+	case modeNext:
+		return len(state.Path) <= d.depth
+	case modeOut:
+		return len(state.Path) < d.depth
+	default:
+		return false
+	}
+}
+
+func (d *Debugger) printLocation(state *workflow.StepState) {
+	fmt.Fprintf(d.out, "%s: %s\n", state.Routine, strings.Join(state.Path, " > "))
+}
+
+func (d *Debugger) printVariables(state *workflow.StepState, names []string) {
+	variables := state.Variables()
+	if len(names) == 0 {
+		names = make([]string, 0, len(variables))
+		for name := range variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		value, ok := variables[name]
+		if !ok {
+			fmt.Fprintf(d.out, "%s is not defined\n", name)
+			continue
+		}
+		b, err := json.MarshalIndentWithOption(value, "", "  ", json.DisableHTMLEscape())
+		if err != nil {
+			b = []byte(fmt.Sprintf("%#v", value))
+		}
+		fmt.Fprintf(d.out, "%s = %s\n", name, b)
+	}
+}
@@ -0,0 +1,170 @@
+package debugger_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/debugger"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+const testSource = `
+main:
+  steps:
+    - init:
+        assign:
+          - n: 1
+    - outer:
+        steps:
+          - call_sub:
+              call: sub
+              args:
+                x: ${n}
+              result: r
+          - add:
+              assign:
+                - n: ${n + r}
+    - done:
+        return: ${n}
+sub:
+  params: [x]
+  steps:
+    - double:
+        assign:
+          - y: ${x * 2}
+    - finish:
+        return: ${y}
+`
+
+func TestDebugger(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name           string
+		breakpoints    []string
+		input          string
+		expected       string
+		expectedResult any
+		expectedErr    error
+	}{
+		{
+			name:  "step into the subworkflows",
+			input: "s\ns\ns\ns\ns\ns\ns\n",
+			expected: `main: init
+(debug) main: outer
+(debug) main: outer > call_sub
+(debug) sub: outer > call_sub > double
+(debug) sub: outer > call_sub > finish
+(debug) main: outer > add
+(debug) main: done
+(debug) `,
+			expectedResult: int64(3),
+		},
+		{
+			name:  "step over the nested steps and the calls",
+			input: "n\nn\nn\n",
+			expected: `main: init
+(debug) main: outer
+(debug) main: done
+(debug) `,
+			expectedResult: int64(3),
+		},
+		{
+			name:  "step out of the subworkflow",
+			input: "s\ns\ns\no\nc\n",
+			expected: `main: init
+(debug) main: outer
+(debug) main: outer > call_sub
+(debug) sub: outer > call_sub > double
+(debug) main: outer > add
+(debug) `,
+			expectedResult: int64(3),
+		},
+		{
+			name:        "continue to the breakpoints",
+			breakpoints: []string{"double", "main.done"},
+			input:       "c\nc\nc\n",
+			expected: `main: init
+(debug) sub: outer > call_sub > double
+(debug) main: done
+(debug) `,
+			expectedResult: int64(3),
+		},
+		{
+			name:  "set the breakpoints",
+			input: "b finish add\nb x\nd x\nbreakpoints\nc\nw\nc\nc\n",
+			expected: `main: init
+(debug) (debug) (debug) (debug) add
+finish
+(debug) sub: outer > call_sub > finish
+(debug) sub: outer > call_sub > finish
+(debug) main: outer > add
+(debug) `,
+			expectedResult: int64(3),
+		},
+		{
+			name:        "print and set the variables",
+			breakpoints: []string{"add"},
+			input:       "c\np\nset n {\"a\": [1, 2]}\np n missing\nset n 10\nset\nset __INTERNAL_x 1\nset n {\nc\n",
+			expected: `main: init
+(debug) main: outer > add
+(debug) n = 1
+r = 2
+(debug) (debug) n = {
+  "a": [
+    1,
+    2
+  ]
+}
+missing is not defined
+(debug) (debug) usage: set NAME JSON
+(debug) failed to set __INTERNAL_x: cannot set the internal variable: __INTERNAL_x
+(debug) failed to set n: invalid JSON: unexpected EOF
+(debug) `,
+			expectedResult: int64(12),
+		},
+		{
+			name:        "quit",
+			input:       "\nx\nh\nq\n",
+			expected:    "main: init\n(debug) (debug) unknown command: x\n" + debugger.Help + "(debug) " + debugger.Help + "(debug) ",
+			expectedErr: debugger.ErrQuit,
+		},
+		{
+			name:           "resume without pausing at the end of the commands",
+			input:          "s\n",
+			expected:       "main: init\n(debug) main: outer\n(debug) \n",
+			expectedResult: int64(3),
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(testSource))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out strings.Builder
+			d := debugger.New(strings.NewReader(tt.input), &out, tt.breakpoints)
+			ret, err := root.Execute(context.Background(), nil, workflow.WithStepHook(d.Hook), workflow.SerializeParallel(true))
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				t.Logf("expected error: %v", err)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedResult, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expected, out.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
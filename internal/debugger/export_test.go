@@ -0,0 +1,4 @@
+package debugger
+
+// Help is the help printed by the help and the unknown commands.
+var Help = help
@@ -10,7 +10,7 @@ var Base64 = aggregateFunctionsToMap("base64", []types.Function{
 	types.MustNewFunction("base64.encode", []types.Argument{
 		{Name: "data"},
 		{Name: "padding", Default: true},
-	}, func(data []byte, padding bool) (string, error) {
+	}, func(data types.Bytes, padding bool) (string, error) {
 		encoder := base64.RawStdEncoding
 		if padding {
 			encoder = base64.StdEncoding
@@ -20,7 +20,7 @@ var Base64 = aggregateFunctionsToMap("base64", []types.Function{
 	types.MustNewFunction("base64.decode", []types.Argument{
 		{Name: "data"},
 		{Name: "padding", Default: true},
-	}, func(data string, padding bool) ([]byte, error) {
+	}, func(data string, padding bool) (types.Bytes, error) {
 		decoder := base64.RawStdEncoding
 		if padding {
 			decoder = base64.StdEncoding
@@ -35,6 +35,6 @@ var Base64 = aggregateFunctionsToMap("base64", []types.Function{
 			}
 		}
 
-		return b, nil
+		return types.Bytes(b), nil
 	}),
 })
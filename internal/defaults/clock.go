@@ -0,0 +1,275 @@
+package defaults
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the time of the executions, which is read by sys.now and the deadlines of the connectors, and is slept by
+// sys.sleep, sys.sleep_until, the polling of the connectors and the backoffs of the retries.
+type Clock interface {
+	Now() time.Time
+	// Sleep sleeps for the duration, or returns the error of the context if it is done before that.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type clockKey struct{}
+
+// WithClock returns the context of the executions using the clock instead of the real time.
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// ClockFromContext returns the clock of the context given by WithClock, or nil.
+func ClockFromContext(ctx context.Context) Clock {
+	clock, _ := ctx.Value(clockKey{}).(Clock)
+	return clock
+}
+
+// now returns the time of the clock of the context, or the current time.
+func now(ctx context.Context) time.Time {
+	if clock := ClockFromContext(ctx); clock != nil {
+		return clock.Now()
+	}
+	return time.Now()
+}
+
+// Sleep sleeps for the duration by the clock of the context, or until the context is done.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if clock := ClockFromContext(ctx); clock != nil {
+		return clock.Sleep(ctx, d)
+	}
+	return sleepRealTime(ctx, d)
+}
+
+func sleepRealTime(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FakeClock is the clock starting at the time, which is advanced only by the sleeps without waiting for them, so the
+// executions depending on the time are deterministic.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns the fake clock starting at the time.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// ScaledClock is the real clock whose sleeps are shortened by the factor, e.g. 60 makes sys.sleep(60) sleep for a
+// second, and the infinite factor skips the sleeps.
+type ScaledClock struct {
+	factor float64
+}
+
+var _ Clock = (*ScaledClock)(nil)
+
+// NewScaledClock returns the scaled clock dividing the durations of the sleeps by the positive factor.
+func NewScaledClock(factor float64) *ScaledClock {
+	return &ScaledClock{factor: factor}
+}
+
+func (c *ScaledClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *ScaledClock) Sleep(ctx context.Context, d time.Duration) error {
+	return sleepRealTime(ctx, time.Duration(float64(d)/c.factor))
+}
+
+// waiter is implemented by the clocks waiting for the channels with the timeouts by themselves, see Await.
+type waiter interface {
+	await(ctx context.Context, d time.Duration, wake <-chan struct{}) (timedOut bool, err error)
+}
+
+// Await waits for the channel until the timeout by the clock of the context, and reports whether it is timed out.
+// The timeout is in the real time unless the clock is a VirtualClock.
+func Await(ctx context.Context, d time.Duration, wake <-chan struct{}) (timedOut bool, err error) {
+	if w, ok := ClockFromContext(ctx).(waiter); ok {
+		return w.await(ctx, d, wake)
+	}
+	if d <= 0 {
+		return true, ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true, nil
+	case <-wake:
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// goroutineTracker is implemented by the clocks tracking the goroutines of the executions, see TrackGoroutines.
+type goroutineTracker interface {
+	trackGoroutines(delta int)
+}
+
+// TrackGoroutines adds the delta to the number of the running goroutines of the execution of the context for the
+// clock of it, e.g. by the branches of the parallel steps. It does nothing for the clocks not tracking them.
+func TrackGoroutines(ctx context.Context, delta int) {
+	if tracker, ok := ClockFromContext(ctx).(goroutineTracker); ok {
+		tracker.trackGoroutines(delta)
+	}
+}
+
+// VirtualClock is the fake clock starting at the time, which jumps to the earliest deadline of the sleeps and the
+// timeouts of the callbacks when all goroutines of the execution wait for them, so the executions depending on the
+// time (including the timeouts of events.await_callback) finish instantly in the order of the deadlines.
+// The goroutines are tracked by TrackGoroutines, and the goroutine starting the execution is running initially.
+// The goroutines waiting for the others (e.g. the HTTP responses) are running, which the clock waits for.
+type VirtualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	running int
+	timers  []*virtualTimer
+}
+
+type virtualTimer struct {
+	deadline time.Time
+	fired    chan struct{}
+}
+
+var (
+	_ Clock            = (*VirtualClock)(nil)
+	_ waiter           = (*VirtualClock)(nil)
+	_ goroutineTracker = (*VirtualClock)(nil)
+)
+
+// NewVirtualClock returns the virtual clock starting at the time.
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start, running: 1}
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *VirtualClock) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	_, err := c.await(ctx, d, nil)
+	return err
+}
+
+func (c *VirtualClock) await(ctx context.Context, d time.Duration, wake <-chan struct{}) (bool, error) {
+	if d <= 0 {
+		return true, ctx.Err()
+	}
+
+	t := c.start(d)
+	select {
+	case <-t.fired:
+		return true, nil
+	case <-wake:
+		c.stop(t)
+		return false, nil
+	case <-ctx.Done():
+		c.stop(t)
+		return false, ctx.Err()
+	}
+}
+
+func (c *VirtualClock) trackGoroutines(delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running += delta
+	c.advanceIfIdle()
+}
+
+// start starts the timer of the waiting goroutine.
+func (c *VirtualClock) start(d time.Duration) *virtualTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &virtualTimer{deadline: c.now.Add(d), fired: make(chan struct{})}
+	c.timers = append(c.timers, t)
+	c.running--
+	c.advanceIfIdle()
+	return t
+}
+
+// stop stops the timer of the goroutine woken by the others, unless it is fired already.
+func (c *VirtualClock) stop(t *virtualTimer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.running++
+			return
+		}
+	}
+}
+
+// advanceIfIdle jumps to the earliest deadline and fires the timers of it if no goroutines are running.
+// The goroutines of the fired timers are running as soon as they are fired not to jump again before they wake up.
+func (c *VirtualClock) advanceIfIdle() {
+	if c.running > 0 || len(c.timers) == 0 {
+		return
+	}
+
+	earliest := c.timers[0].deadline
+	for _, t := range c.timers[1:] {
+		if t.deadline.Before(earliest) {
+			earliest = t.deadline
+		}
+	}
+	if earliest.After(c.now) {
+		c.now = earliest
+	}
+
+	rest := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			rest = append(rest, t)
+			continue
+		}
+		close(t.fired)
+		c.running++
+	}
+	c.timers = rest
+}
@@ -0,0 +1,515 @@
+package defaults_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+var fakeTimeStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tt := range []struct {
+		name     string
+		ctx      context.Context
+		sleeps   []time.Duration
+		expected time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "start",
+			ctx:      context.Background(),
+			expected: fakeTimeStart,
+		},
+		{
+			name:     "advanced by the sleeps",
+			ctx:      context.Background(),
+			sleeps:   []time.Duration{time.Hour, 0, -time.Minute, 1500 * time.Millisecond},
+			expected: fakeTimeStart.Add(time.Hour + 1500*time.Millisecond),
+		},
+		{
+			name:     "not advanced by the cancelled context",
+			ctx:      cancelled,
+			sleeps:   []time.Duration{time.Hour},
+			expected: fakeTimeStart,
+			wantErr:  true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := defaults.NewFakeClock(fakeTimeStart)
+			for _, d := range tt.sleeps {
+				// the sleeps return without waiting for them, or the test times out
+				err := clock.Sleep(tt.ctx, d)
+				if tt.wantErr {
+					if err == nil {
+						t.Fatal("should be error")
+					}
+					t.Logf("expected error: %v", err)
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := clock.Now(); !got.Equal(tt.expected) {
+				t.Errorf("unexpected time: %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFakeClockWorkflow(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		source   string
+		expected any
+	}{
+		{
+			name: "sys.now",
+			source: `
+main:
+  steps:
+    - done:
+        return: ${sys.now()}
+`,
+			expected: fakeTimeStart.Unix(),
+		},
+		{
+			name: "sys.sleep and sys.sleep_until",
+			source: `
+main:
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 3600
+    - sleep_until:
+        call: sys.sleep_until
+        args:
+          time: "2024-01-01T02:00:00Z"
+    - past:
+        call: sys.sleep_until
+        args:
+          time: "2023-01-01T00:00:00Z"
+    - done:
+        return: ${time.format(sys.now())}
+`,
+			expected: "2024-01-01T02:00:00Z",
+		},
+		{
+			name: "backoffs of the retries",
+			source: `
+main:
+  steps:
+    - try_raise:
+        try:
+          call: sys.sleep
+          args:
+            seconds: "not a number"
+        retry:
+          predicate: ${lambda_true}
+          max_retries: 2
+          backoff:
+            initial_delay: 10
+            max_delay: 60
+            multiplier: 3
+        except:
+          as: e
+          steps:
+            - done:
+                return: ${time.format(sys.now())}
+lambda_true:
+  params: [e]
+  steps:
+    - done:
+        return: true
+`,
+			expected: "2024-01-01T00:00:40Z",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ret, err := execute(t, tt.source, nil, workflow.WithClock(func() defaults.Clock { return defaults.NewFakeClock(fakeTimeStart) }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("connector deadline by the fake clock in the past", func(t *testing.T) {
+		t.Parallel()
+
+		runConnectorTest(t, connectorTest{
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.storage.v1.buckets.get
+        args:
+          bucket: b
+        result: bucket
+    - done:
+        return: ${bucket.name}
+`,
+			opts:             []workflow.ExecuteOption{workflow.WithClock(func() defaults.Clock { return defaults.NewFakeClock(fakeTimeStart) })},
+			responses:        []connectorResponse{{code: http.StatusOK, body: `{"name":"b"}`}},
+			expected:         "b",
+			expectedRequests: []connectorRequest{{Method: http.MethodGet, Host: "storage.googleapis.com", Path: "/storage/v1/b/b", Authorized: true}},
+		})
+	})
+}
+
+func TestScaledClock(t *testing.T) {
+	t.Parallel()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tt := range []struct {
+		name        string
+		ctx         context.Context
+		factor      float64
+		sleep       time.Duration
+		minDuration time.Duration
+		maxDuration time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "scaled sleep",
+			ctx:         context.Background(),
+			factor:      36000,
+			sleep:       time.Hour,
+			minDuration: 100 * time.Millisecond,
+			maxDuration: time.Second,
+		},
+		{
+			name:        "skipped sleep by the infinite factor",
+			ctx:         context.Background(),
+			factor:      math.Inf(1),
+			sleep:       time.Hour,
+			maxDuration: 100 * time.Millisecond,
+		},
+		{
+			name:        "cancelled context",
+			ctx:         cancelled,
+			factor:      1,
+			sleep:       time.Hour,
+			maxDuration: 100 * time.Millisecond,
+			wantErr:     true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := defaults.NewScaledClock(tt.factor)
+			if d := time.Since(clock.Now()); d < 0 || d > time.Second {
+				t.Errorf("unexpected time: %v", clock.Now())
+			}
+
+			start := time.Now()
+			err := clock.Sleep(tt.ctx, tt.sleep)
+			elapsed := time.Since(start)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed < tt.minDuration || elapsed > tt.maxDuration {
+				t.Errorf("unexpected duration of the sleep: %v", elapsed)
+			}
+		})
+	}
+
+	t.Run("workflow", func(t *testing.T) {
+		t.Parallel()
+
+		start := time.Now()
+		_, err := execute(t, `
+main:
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 3600
+    - sleep_until:
+        call: sys.sleep_until
+        args:
+          time: ${time.format(sys.now() + 3600)}
+`, nil, workflow.WithClock(func() defaults.Clock { return defaults.NewScaledClock(math.Inf(1)) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("unexpected duration of the execution: %v", elapsed)
+		}
+	})
+}
+
+func TestVirtualClockWorkflow(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond) // the clock must not jump while waiting for the response
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tt := range []struct {
+		name     string
+		source   string
+		expected any
+	}{
+		{
+			name: "sleeps in the order of the deadlines",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - start: ${sys.now()}
+          - woken: []
+    - wait:
+        parallel:
+          shared: [woken]
+          for:
+            value: seconds
+            in: [3600, 60, 600]
+            steps:
+              - sleep:
+                  call: sys.sleep
+                  args:
+                    seconds: ${seconds}
+              - wake:
+                  assign:
+                    - woken: ${list.concat(woken, sys.now() - start)}
+    - done:
+        return: ${woken}
+`,
+			expected: []any{int64(60), int64(600), int64(3600)},
+		},
+		{
+			name: "timeout of the callback",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - start: ${sys.now()}
+    - create:
+        call: events.create_callback_endpoint
+        result: callback
+    - await:
+        try:
+          call: events.await_callback
+          args:
+            callback: ${callback}
+            timeout: 3600.0
+        except:
+          as: e
+          steps:
+            - timed_out:
+                return:
+                  - ${e.tags}
+                  - ${sys.now() - start}
+`,
+			expected: []any{[]any{"TimeoutError"}, int64(3600)},
+		},
+		{
+			name: "callback before the timeout",
+			source: `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - start: ${sys.now()}
+          - received: null
+    - create:
+        call: events.create_callback_endpoint
+        result: callback
+    - branches:
+        parallel:
+          shared: [received]
+          branches:
+            - await:
+                steps:
+                  - await_callback:
+                      call: events.await_callback
+                      args:
+                        callback: ${callback}
+                        timeout: 3600.0
+                  - received:
+                      assign:
+                        - received: ${sys.now() - start}
+            - send:
+                steps:
+                  - sleep:
+                      call: sys.sleep
+                      args:
+                        seconds: 60
+                  - post:
+                      call: http.post
+                      args:
+                        url: ${callback.url}
+    - done:
+        return: ${received}
+`,
+			expected: int64(60),
+		},
+		{
+			name: "not jumping while waiting for the response",
+			source: `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - start: ${sys.now()}
+          - events: []
+    - branches:
+        parallel:
+          shared: [events]
+          branches:
+            - sleep:
+                steps:
+                  - sleep:
+                      call: sys.sleep
+                      args:
+                        seconds: 10
+                  - woken:
+                      assign:
+                        - events: ${list.concat(events, "woken at " + string(sys.now() - start))}
+            - request:
+                steps:
+                  - get:
+                      call: http.get
+                      args:
+                        url: ${args.url}
+                  - responded:
+                      assign:
+                        - events: ${list.concat(events, "responded at " + string(sys.now() - start))}
+    - done:
+        return: ${events}
+`,
+			expected: []any{"responded at 0", "woken at 10"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ret, err := execute(t, tt.source, map[string]any{"url": ts.URL},
+				workflow.WithClock(func() defaults.Clock { return defaults.NewVirtualClock(fakeTimeStart) }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAwait(t *testing.T) {
+	t.Parallel()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	woken := make(chan struct{})
+	close(woken)
+
+	for _, tt := range []struct {
+		name             string
+		clock            defaults.Clock
+		ctx              context.Context
+		timeout          time.Duration
+		wake             <-chan struct{}
+		others           int // the goroutines running besides the waiting one, which wake it
+		expectedTimedOut bool
+		wantErr          bool
+	}{
+		{
+			name:             "real time out",
+			ctx:              context.Background(),
+			timeout:          10 * time.Millisecond,
+			expectedTimedOut: true,
+		},
+		{
+			name:    "real time woken",
+			ctx:     context.Background(),
+			timeout: time.Hour,
+			wake:    woken,
+		},
+		{
+			name:    "real time cancelled",
+			ctx:     cancelled,
+			timeout: time.Hour,
+			wantErr: true,
+		},
+		{
+			name:             "virtual time out",
+			clock:            defaults.NewVirtualClock(fakeTimeStart),
+			ctx:              context.Background(),
+			timeout:          time.Hour,
+			expectedTimedOut: true,
+		},
+		{
+			name:    "virtual time woken",
+			clock:   defaults.NewVirtualClock(fakeTimeStart),
+			ctx:     context.Background(),
+			timeout: time.Hour,
+			wake:    woken,
+			others:  1,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := tt.ctx
+			if tt.clock != nil {
+				ctx = defaults.WithClock(ctx, tt.clock)
+			}
+			defaults.TrackGoroutines(ctx, tt.others)
+			defer defaults.TrackGoroutines(ctx, -tt.others)
+			timedOut, err := defaults.Await(ctx, tt.timeout, tt.wake)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if timedOut != tt.expectedTimedOut {
+				t.Errorf("unexpected timed out: %v", timedOut)
+			}
+		})
+	}
+}
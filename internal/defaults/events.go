@@ -4,124 +4,197 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"mime"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
 )
 
 const internalEventCallbackSymbol = "__INTERNAL_EVENT_CALLBACK"
 
-type eventCallback struct {
-	resultChan chan map[string]any
-	received   atomic.Bool
-	server     http.Server
+// EventCallback is the callback endpoint created by events.create_callback_endpoint.
+// It queues the received requests until events.await_callback consumes them one by one.
+type EventCallback struct {
+	method   string
+	mu       sync.Mutex
+	payloads []map[string]any
+	notify   chan struct{}
+	waiters  atomic.Int32
 }
 
-var Events = aggregateFunctionsToMap("events", []types.Function{
-	types.MustNewFunction("events.create_callback_endpoint", []types.Argument{
-		{Name: "http_callback_method", Default: http.MethodPost},
-	}, func(httpCallbackMethod string) (map[string]any, error) {
-		listener, err := net.ListenTCP("tcp", &net.TCPAddr{
-			IP:   net.IPv4zero,
-			Port: 0,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("net.Listen: %w", err)
+func (c *EventCallback) push(payload map[string]any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.payloads = append(c.payloads, payload)
+	c.signal()
+}
+
+// pop returns the oldest payload in the queue if exists.
+func (c *EventCallback) pop() (map[string]any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.payloads) == 0 {
+		return nil, false
+	}
+
+	payload := c.payloads[0]
+	c.payloads = c.payloads[1:]
+	if len(c.payloads) != 0 {
+		c.signal() // wake up the other waiters for the rest
+	}
+	return payload, true
+}
+
+func (c *EventCallback) signal() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// CallbackRegistry hosts the callback endpoints of an execution on the API server of the emulator.
+// The callback endpoints are served on the ad-hoc local ports if it's not given.
+type CallbackRegistry interface {
+	// RegisterCallback starts routing the requests to the callback until the end of the execution, and returns the URL of it.
+	RegisterCallback(callback *EventCallback) (url string)
+}
+
+// Method returns the HTTP method accepted by the callback.
+func (c *EventCallback) Method() string {
+	return c.method
+}
+
+// Waiters returns the number of the events.await_callback waiting for the callback.
+func (c *EventCallback) Waiters() int {
+	return int(c.waiters.Load())
+}
+
+// AvailablePayloads returns the number of the received requests not consumed by events.await_callback yet.
+func (c *EventCallback) AvailablePayloads() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.payloads)
+}
+
+// ServeHTTP receives the callback request into the queue.
+func (c *EventCallback) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != c.method {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	headers := map[string]any{}
+	for key := range r.Header {
+		value := r.Header.Get(key)
+		headers[key] = value
+	}
+	query := map[string]any{}
+	if r.URL.RawQuery != "" {
+		q := r.URL.Query()
+		for key := range q {
+			value := q.Get(key)
+			query[key] = value
 		}
+	}
 
-		callback := eventCallback{
-			resultChan: make(chan map[string]any),
+	var body any
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			logging.Logf(logging.LevelDebug, "invalid Content-Type of callback", "error", err)
+			http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
+			return
 		}
-		callback.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != httpCallbackMethod {
-				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+		if mt == "application/json" || strings.HasPrefix(mt, "application/json+") || strings.HasSuffix(mt, "+json") {
+			if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+				logging.Logf(logging.LevelDebug, "invalid JSON of callback", "error", err)
+				http.Error(w, "Invalid JSON format", http.StatusBadRequest)
 				return
 			}
-			if callback.received.Swap(true) {
-				http.Error(w, "Callback request is already consumed", http.StatusBadRequest)
+		} else {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				logging.Logf(logging.LevelDebug, "failed to read request body of callback", "error", err)
+				http.Error(w, "Failed to read request body:", http.StatusInternalServerError)
 				return
 			}
+			body = string(b)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
 
-			headers := map[string]any{}
-			for key := range r.Header {
-				value := r.Header.Get(key)
-				headers[key] = value
-			}
-			query := map[string]any{}
-			if r.URL.RawQuery != "" {
-				q := r.URL.Query()
-				for key := range q {
-					value := q.Get(key)
-					query[key] = value
-				}
+	c.push(map[string]any{
+		"type": "HTTP",
+		"http_request": map[string]any{
+			"method":  r.Method,
+			"headers": headers,
+			"url":     r.URL.Path,
+			"query":   query,
+			"body":    body,
+		},
+		"received_time": time.Now().String(),
+	})
+}
+
+var Events = aggregateFunctionsToMap("events", []types.Function{
+	types.MustNewScopedFunction("events.create_callback_endpoint", []types.Argument{
+		{Name: "http_callback_method", Default: http.MethodPost},
+	}, func(st *types.SymbolTable) any {
+		return func(httpCallbackMethod string) (map[string]any, error) {
+			callback := &EventCallback{
+				method: httpCallbackMethod,
+				notify: make(chan struct{}, 1),
 			}
 
-			var body any
-			if ct := r.Header.Get("Content-Type"); ct != "" {
-				mt, _, err := mime.ParseMediaType(ct)
-				if err != nil {
-					log.Println("Invalid Content-Type: ", err)
-					http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
-					return
-				}
+			if st != nil {
+				if registry, ok := st.Get(types.InternalCallbackRegistrySymbol); ok {
+					u := registry.(CallbackRegistry).RegisterCallback(callback)
+					logging.Logf(logging.LevelInfo, "created HTTP callback endpoint", "url", u)
 
-				if mt == "application/json" || strings.HasPrefix(mt, "application/json+") || strings.HasSuffix(mt, "+json") {
-					if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
-						log.Println("Invalid JSON format: ", err)
-						http.Error(w, "Invalid JSON format", http.StatusBadRequest)
-						return
-					}
-				} else {
-					b, err := io.ReadAll(r.Body)
-					if err != nil {
-						log.Println("Failed to read request body: ", err)
-						http.Error(w, "Failed to read request body:", http.StatusInternalServerError)
-						return
-					}
-					body = string(b)
+					return map[string]any{
+						"url":                       u,
+						internalEventCallbackSymbol: callback,
+					}, nil
 				}
 			}
-			w.WriteHeader(http.StatusNoContent)
-
-			callback.resultChan <- map[string]any{
-				"type": "HTTP",
-				"http_request": map[string]any{
-					"method":  r.Method,
-					"headers": headers,
-					"url":     r.URL.Path,
-					"query":   query,
-					"body":    body,
-				},
-				"received_time": time.Now().String(),
+
+			listener, err := net.ListenTCP("tcp", &net.TCPAddr{
+				IP:   net.IPv4zero,
+				Port: 0,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("net.Listen: %w", err)
 			}
-		})
-		go callback.server.Serve(listener)
 
-		u := url.URL{
-			Scheme: "http",
-			Host:   listener.Addr().String(),
-			Path:   "/",
-		}
-		log.Println("Created HTTP callback endpoint: ", u.String())
+			go http.Serve(listener, callback) // alive until the end of the process to receive the callbacks repeatedly
+
+			u := url.URL{
+				Scheme: "http",
+				Host:   listener.Addr().String(),
+				Path:   "/",
+			}
+			logging.Logf(logging.LevelInfo, "created HTTP callback endpoint", "url", u.String())
 
-		return map[string]any{
-			"url":                       u.String(),
-			internalEventCallbackSymbol: &callback,
-		}, nil
+			return map[string]any{
+				"url":                       u.String(),
+				internalEventCallbackSymbol: callback,
+			}, nil
+		}
 	}),
 	types.MustNewFunction("events.await_callback", []types.Argument{
 		{Name: "callback"},
 		{Name: "timeout", Default: float64(43200.0)},
-	}, func(m map[string]any, timeout float64) (any, error) {
-		callback, ok := m[internalEventCallbackSymbol].(*eventCallback)
+	}, func(ctx context.Context, m map[string]any, timeout float64) (any, error) {
+		callback, ok := m[internalEventCallbackSymbol].(*EventCallback)
 		if !ok {
 			return nil, &types.Error{
 				Tag: types.TypeErrorTag,
@@ -129,23 +202,24 @@ var Events = aggregateFunctionsToMap("events", []types.Function{
 			}
 		}
 
-		defer func() {
-			close(callback.resultChan)
-			err := callback.server.Shutdown(context.Background())
-			if err != nil {
-				log.Printf("callback.server.Shutdown: %v", err)
+		callback.waiters.Add(1)
+		defer callback.waiters.Add(-1)
+
+		deadline := now(ctx).Add(time.Duration(timeout * float64(time.Second)))
+		for {
+			if res, ok := callback.pop(); ok {
+				return res, nil
 			}
-		}()
 
-		t := time.NewTimer(time.Duration(timeout * float64(time.Second)))
-		defer t.Stop()
-		select {
-		case <-t.C:
-			return nil, &types.Error{
-				Tag: types.TimeoutErrorTag,
+			timedOut, err := Await(ctx, deadline.Sub(now(ctx)), callback.notify)
+			if err != nil {
+				return nil, err
+			}
+			if timedOut {
+				return nil, &types.Error{
+					Tag: types.TimeoutErrorTag,
+				}
 			}
-		case res := <-callback.resultChan:
-			return res, nil
 		}
 	}),
 })
@@ -0,0 +1,18 @@
+package defaults
+
+// ResetHTTPAuth disables the fake authentication and the credentials file until the returned function restores them.
+func ResetHTTPAuth() (restore func()) {
+	sharedHTTPClient.Lock()
+	defer sharedHTTPClient.Unlock()
+
+	fakeAuthClaims := sharedHTTPClient.fakeAuthClaims
+	credentialsFile, credentialsJSON := sharedHTTPClient.credentialsFile, sharedHTTPClient.credentialsJSON
+	sharedHTTPClient.fakeAuthClaims = nil
+	sharedHTTPClient.credentialsFile, sharedHTTPClient.credentialsJSON = "", nil
+	return func() {
+		sharedHTTPClient.Lock()
+		defer sharedHTTPClient.Unlock()
+		sharedHTTPClient.fakeAuthClaims = fakeAuthClaims
+		sharedHTTPClient.credentialsFile, sharedHTTPClient.credentialsJSON = credentialsFile, credentialsJSON
+	}
+}
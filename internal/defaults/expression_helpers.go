@@ -99,6 +99,8 @@ var ExpressionHelpers = aggregateFunctionsToReadonlySymbolTable(
 		switch v := attribute.(type) {
 		case string:
 			return int64(utf8.RuneCountInString(v)), nil
+		case types.Bytes:
+			return int64(len(v)), nil
 		case []any:
 			return int64(len(v)), nil
 		case map[string]any:
@@ -106,7 +108,7 @@ var ExpressionHelpers = aggregateFunctionsToReadonlySymbolTable(
 		default:
 			return 0, &types.Error{
 				Tag: types.TypeErrorTag,
-				Err: fmt.Errorf("attribute is not a string, array or map: %v", attribute),
+				Err: fmt.Errorf("attribute is not a string, bytes, array or map: %v", attribute),
 			}
 		}
 	}),
@@ -0,0 +1,283 @@
+package defaults
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// Extensions are the convenience functions of the emulator which Cloud Workflows doesn't have, by the dotted names
+// to be registered into the standard library by workflow.WithSymbols. They are disabled by default not to pass the
+// workflows locally which fail on Cloud Workflows.
+var Extensions = map[string]any{
+	"list.filter": types.MustNewScopedFunction("list.filter", []types.Argument{
+		{Name: "objs"},
+		{Name: "predicate"},
+	}, func(st *types.SymbolTable) any {
+		return func(ctx context.Context, list []any, predicate types.Function) ([]any, error) {
+			result := []any{}
+			for _, v := range list {
+				ok, err := callInScope(ctx, st, predicate, v)
+				if err != nil {
+					return nil, err
+				}
+				b, isBool := ok.(bool)
+				if !isBool {
+					return nil, &types.Error{
+						Tag: types.TypeErrorTag,
+						Err: fmt.Errorf("predicate must return a boolean but got %T", ok),
+					}
+				}
+				if b {
+					result = append(result, v)
+				}
+			}
+			return result, nil
+		}
+	}),
+	"list.map": types.MustNewScopedFunction("list.map", []types.Argument{
+		{Name: "objs"},
+		{Name: "function"},
+	}, func(st *types.SymbolTable) any {
+		return func(ctx context.Context, list []any, function types.Function) ([]any, error) {
+			result := make([]any, len(list))
+			for i, v := range list {
+				var err error
+				result[i], err = callInScope(ctx, st, function, v)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		}
+	}),
+	"list.sort": types.MustNewScopedFunction("list.sort", []types.Argument{
+		{Name: "objs"},
+		{Name: "key", Optional: true},
+	}, func(st *types.SymbolTable) any {
+		return func(ctx context.Context, list []any, key any) ([]any, error) {
+			keys := list
+			if key != nil {
+				f, ok := key.(types.Function)
+				if !ok {
+					return nil, &types.Error{
+						Tag: types.TypeErrorTag,
+						Err: fmt.Errorf("key must be a function but got %T", key),
+					}
+				}
+
+				keys = make([]any, len(list))
+				for i, v := range list {
+					var err error
+					keys[i], err = callInScope(ctx, st, f, v)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			indexes := make([]int, len(list))
+			for i := range indexes {
+				indexes[i] = i
+			}
+			var err error
+			sort.SliceStable(indexes, func(i, j int) bool {
+				less, e := lessValue(keys[indexes[i]], keys[indexes[j]])
+				if e != nil && err == nil {
+					err = e
+				}
+				return less
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			result := make([]any, len(list))
+			for i, index := range indexes {
+				result[i] = list[index]
+			}
+			return result, nil
+		}
+	}),
+	"math.floor": types.MustNewFunction("math.floor", []types.Argument{
+		{Name: "x"},
+	}, func(x any) (int64, error) {
+		return roundNumber(x, math.Floor)
+	}),
+	"math.round": types.MustNewFunction("math.round", []types.Argument{
+		{Name: "x"},
+	}, func(x any) (int64, error) {
+		return roundNumber(x, math.Round)
+	}),
+	"math.pow": types.MustNewFunction("math.pow", []types.Argument{
+		{Name: "x"},
+		{Name: "y"},
+	}, func(x, y any) (any, error) {
+		base, baseIsInt := x.(int64)
+		exp, expIsInt := y.(int64)
+		if baseIsInt && expIsInt && exp >= 0 {
+			return powInt(base, exp)
+		}
+
+		fx, err := toFloat("x", x)
+		if err != nil {
+			return nil, err
+		}
+		fy, err := toFloat("y", y)
+		if err != nil {
+			return nil, err
+		}
+		ret := math.Pow(fx, fy)
+		if math.IsNaN(ret) || math.IsInf(ret, 0) {
+			return nil, &types.Error{
+				Tag: types.ValueErrorTag,
+				Err: fmt.Errorf("math.pow(%v, %v) is not a finite number", x, y),
+			}
+		}
+		return ret, nil
+	}),
+	"time.add": types.MustNewFunction("time.add", []types.Argument{
+		{Name: "value"},
+		{Name: "seconds"},
+	}, func(value string, seconds any) (string, error) {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return "", &types.Error{
+				Tag: types.ValueErrorTag,
+				Err: err,
+			}
+		}
+
+		d, err := toFloat("seconds", seconds)
+		if err != nil {
+			return "", err
+		}
+		return t.Add(time.Duration(math.Round(d * float64(time.Second)))).Format(time.RFC3339Nano), nil
+	}),
+	"text.join": types.MustNewFunction("text.join", []types.Argument{
+		{Name: "objs"},
+		{Name: "separator"},
+	}, func(list []any, separator string) (string, error) {
+		elems := make([]string, len(list))
+		for i, v := range list {
+			s, ok := v.(string)
+			if !ok {
+				return "", &types.Error{
+					Tag: types.TypeErrorTag,
+					Err: fmt.Errorf("objs[%d] is not a string: %v", i, v),
+				}
+			}
+			elems[i] = s
+		}
+		return strings.Join(elems, separator), nil
+	}),
+}
+
+// callInScope calls the function given as the argument in the scope of the caller, e.g. a subworkflow calling the
+// other subworkflows.
+func callInScope(ctx context.Context, st *types.SymbolTable, f types.Function, args ...any) (any, error) {
+	if sf, ok := f.(types.ScopedFunction); ok {
+		return sf.CallInScope(ctx, st, args)
+	}
+	return f.Call(ctx, args)
+}
+
+// lessValue compares the numbers or the strings.
+func lessValue(x, y any) (bool, error) {
+	if sx, ok := x.(string); ok {
+		if sy, ok := y.(string); ok {
+			return sx < sy, nil
+		}
+	}
+	if ix, ok := x.(int64); ok {
+		if iy, ok := y.(int64); ok {
+			return ix < iy, nil
+		}
+	}
+
+	fx, errX := toFloat("x", x)
+	fy, errY := toFloat("y", y)
+	if errX != nil || errY != nil {
+		return false, &types.Error{
+			Tag: types.TypeErrorTag,
+			Err: fmt.Errorf("cannot compare %v and %v", x, y),
+		}
+	}
+	return fx < fy, nil
+}
+
+func toFloat(name string, v any) (float64, error) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	default:
+		return 0, &types.Error{
+			Tag: types.TypeErrorTag,
+			Err: fmt.Errorf("%s is not an integer or floating-point number: %v", name, v),
+		}
+	}
+}
+
+// roundNumber rounds the number to the integer by the function.
+func roundNumber(x any, round func(float64) float64) (int64, error) {
+	if n, ok := x.(int64); ok {
+		return n, nil
+	}
+
+	f, err := toFloat("x", x)
+	if err != nil {
+		return 0, err
+	}
+	r := round(f)
+	if math.IsNaN(r) || r < math.MinInt64 || r >= math.MaxInt64 {
+		return 0, &types.Error{
+			Tag: types.ValueErrorTag,
+			Err: fmt.Errorf("x is out of the range of integer: %v", x),
+		}
+	}
+	return int64(r), nil
+}
+
+// powInt calculates the power of the integers by squaring, and raises ValueError if it overflows.
+func powInt(base, exp int64) (int64, error) {
+	overflow := &types.Error{
+		Tag: types.ValueErrorTag,
+		Err: fmt.Errorf("math.pow(%d, %d) overflows", base, exp),
+	}
+
+	result := int64(1)
+	for exp > 0 {
+		var ok bool
+		if exp&1 == 1 {
+			if result, ok = mulInt(result, base); !ok {
+				return 0, overflow
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = mulInt(base, base); !ok {
+				return 0, overflow
+			}
+		}
+	}
+	return result, nil
+}
+
+// mulInt multiplies the integers, and reports whether it doesn't overflow.
+func mulInt(x, y int64) (int64, bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	if (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		return 0, false
+	}
+	z := x * y
+	return z, z/y == x
+}
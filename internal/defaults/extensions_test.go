@@ -0,0 +1,258 @@
+package defaults_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// extensionsWorkflow is the workflow returning the result of the expression (%s) with the subworkflows given to the
+// extensions.
+const extensionsWorkflow = `
+main:
+  params: [args]
+  steps:
+    - done:
+        return: ${%s}
+is_even:
+  params: [x]
+  steps:
+    - done:
+        return: ${x %% 2 == 0}
+double:
+  params: [x]
+  steps:
+    - done:
+        return: ${x * 2}
+negate:
+  params: [x]
+  steps:
+    - done:
+        return: ${-x}
+name_of:
+  params: [x]
+  steps:
+    - done:
+        return: ${x.name}
+`
+
+func TestExtensions(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name             string
+		expr             string
+		args             map[string]any
+		expected         any
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name:     "list.filter",
+			expr:     `list.filter(args.list, is_even)`,
+			args:     map[string]any{"list": []any{int64(1), int64(2), int64(3), int64(4)}},
+			expected: []any{int64(2), int64(4)},
+		},
+		{
+			name:     "list.filter of the empty list",
+			expr:     `list.filter(args.list, is_even)`,
+			args:     map[string]any{"list": []any{}},
+			expected: []any{},
+		},
+		{
+			name:             "list.filter by the predicate not returning the boolean",
+			expr:             `list.filter(args.list, double)`,
+			args:             map[string]any{"list": []any{int64(1)}},
+			expectedErrorTag: types.TypeErrorTag,
+		},
+		{
+			name:     "list.map",
+			expr:     `list.map(args.list, double)`,
+			args:     map[string]any{"list": []any{int64(1), int64(2), 1.5}},
+			expected: []any{int64(2), int64(4), 3.0},
+		},
+		{
+			name:     "list.sort",
+			expr:     `list.sort(args.list)`,
+			args:     map[string]any{"list": []any{int64(3), 1.5, int64(2)}},
+			expected: []any{1.5, int64(2), int64(3)},
+		},
+		{
+			name:     "list.sort of the strings",
+			expr:     `list.sort(args.list)`,
+			args:     map[string]any{"list": []any{"b", "c", "a"}},
+			expected: []any{"a", "b", "c"},
+		},
+		{
+			name:     "list.sort by the key",
+			expr:     `list.sort(args.list, negate)`,
+			args:     map[string]any{"list": []any{int64(1), int64(3), int64(2)}},
+			expected: []any{int64(3), int64(2), int64(1)},
+		},
+		{
+			name: "list.sort stably",
+			expr: `list.sort(args.list, name_of)`,
+			args: map[string]any{"list": []any{
+				map[string]any{"name": "b", "id": int64(1)},
+				map[string]any{"name": "a", "id": int64(2)},
+				map[string]any{"name": "b", "id": int64(3)},
+			}},
+			expected: []any{
+				map[string]any{"name": "a", "id": int64(2)},
+				map[string]any{"name": "b", "id": int64(1)},
+				map[string]any{"name": "b", "id": int64(3)},
+			},
+		},
+		{
+			name:             "list.sort of the incomparable values",
+			expr:             `list.sort(args.list)`,
+			args:             map[string]any{"list": []any{"a", int64(1)}},
+			expectedErrorTag: types.TypeErrorTag,
+		},
+		{
+			name:             "list.sort by the key not the function",
+			expr:             `list.sort(args.list, 1)`,
+			args:             map[string]any{"list": []any{int64(1)}},
+			expectedErrorTag: types.TypeErrorTag,
+		},
+		{
+			name:     "math.floor",
+			expr:     `math.floor(-1.5)`,
+			expected: int64(-2),
+		},
+		{
+			name:     "math.floor of the integer",
+			expr:     `math.floor(3)`,
+			expected: int64(3),
+		},
+		{
+			name:             "math.floor out of the range",
+			expr:             `math.floor(args.x)`,
+			args:             map[string]any{"x": 1e20},
+			expectedErrorTag: types.ValueErrorTag,
+		},
+		{
+			name:             "math.floor of the string",
+			expr:             `math.floor("1")`,
+			expectedErrorTag: types.TypeErrorTag,
+		},
+		{
+			name:     "math.round",
+			expr:     `math.round(2.5)`,
+			expected: int64(3),
+		},
+		{
+			name:     "math.pow of the integers",
+			expr:     `math.pow(2, 10)`,
+			expected: int64(1024),
+		},
+		{
+			name:     "math.pow of the negative exponent",
+			expr:     `math.pow(2, -1)`,
+			expected: 0.5,
+		},
+		{
+			name:     "math.pow of the floats",
+			expr:     `math.pow(4.0, 0.5)`,
+			expected: 2.0,
+		},
+		{
+			name:             "math.pow overflowing",
+			expr:             `math.pow(2, 63)`,
+			expectedErrorTag: types.ValueErrorTag,
+		},
+		{
+			name:             "math.pow not finite",
+			expr:             `math.pow(-1, 0.5)`,
+			expectedErrorTag: types.ValueErrorTag,
+		},
+		{
+			name:     "time.add",
+			expr:     `time.add("2024-01-01T00:00:00Z", 90.5)`,
+			expected: "2024-01-01T00:01:30.5Z",
+		},
+		{
+			name:     "time.add of the negative seconds",
+			expr:     `time.add("2024-01-01T00:00:00+09:00", -3600)`,
+			expected: "2023-12-31T23:00:00+09:00",
+		},
+		{
+			name:             "time.add of the invalid time",
+			expr:             `time.add("2024-01-01", 1)`,
+			expectedErrorTag: types.ValueErrorTag,
+		},
+		{
+			name:     "text.join",
+			expr:     `text.join(args.list, ", ")`,
+			args:     map[string]any{"list": []any{"a", "b", "c"}},
+			expected: "a, b, c",
+		},
+		{
+			name:             "text.join of the non-strings",
+			expr:             `text.join(args.list, ", ")`,
+			args:             map[string]any{"list": []any{"a", int64(1)}},
+			expectedErrorTag: types.TypeErrorTag,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := fmt.Sprintf(extensionsWorkflow, tt.expr)
+			ret, err := execute(t, source, tt.args, workflow.WithSymbols(defaults.Extensions))
+			if tt.expectedErrorTag != "" {
+				var e *types.Error
+				if !errors.As(err, &e) || e.Tag != tt.expectedErrorTag {
+					t.Fatalf("should be %s but got %v", tt.expectedErrorTag, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExtensionsDisabled(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name string
+		expr string
+	}{
+		{name: "list.filter", expr: `list.filter(args.list, is_even)`},
+		{name: "list.map", expr: `list.map(args.list, double)`},
+		{name: "list.sort", expr: `list.sort(args.list)`},
+		{name: "math.floor", expr: `math.floor(1.5)`},
+		{name: "math.round", expr: `math.round(1.5)`},
+		{name: "math.pow", expr: `math.pow(2, 2)`},
+		{name: "time.add", expr: `time.add("2024-01-01T00:00:00Z", 1)`},
+		{name: "text.join", expr: `text.join(args.list, ",")`},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := fmt.Sprintf(extensionsWorkflow, tt.expr)
+			args := map[string]any{"list": []any{}}
+			if _, err := execute(t, source, args, workflow.WithSymbols(defaults.Extensions)); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := execute(t, source, args)
+			if err == nil {
+				t.Fatal("should be error")
+			}
+			t.Logf("expected error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package defaults
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"golang.org/x/oauth2"
+)
+
+// fakeAuthSigningKey is the key to sign the fake tokens, which is public and must not be trusted.
+const fakeAuthSigningKey = "google-cloud-workflow-emulator"
+
+// SetFakeAuth makes the OIDC and OAuth2 authentications of http.* and the connectors use the synthetic tokens
+// instead of the application default credentials, idtoken or gcloud. The claims override the default ones of the tokens.
+// It must be called before executing any workflows.
+func SetFakeAuth(claims map[string]any) {
+	if claims == nil {
+		claims = map[string]any{}
+	}
+	sharedHTTPClient.fakeAuthClaims = claims
+}
+
+// fakeTokenSource issues the synthetic tokens by NewFakeToken.
+type fakeTokenSource struct {
+	claims map[string]any
+}
+
+func newFakeOIDCTokenSource(claims map[string]any, audience string) oauth2.TokenSource {
+	return &fakeTokenSource{claims: mergeMaps(map[string]any{"aud": audience}, claims)}
+}
+
+func newFakeOAuth2TokenSource(claims map[string]any, scopes []string) oauth2.TokenSource {
+	return &fakeTokenSource{claims: mergeMaps(map[string]any{"scope": strings.Join(scopes, " ")}, claims)}
+}
+
+func (ts *fakeTokenSource) Token() (*oauth2.Token, error) {
+	return NewFakeToken(ts.claims)
+}
+
+// NewFakeToken issues the synthetic token which is the JWT signed by HS256 with the well-known key "google-cloud-workflow-emulator".
+// The claims override the default ones of the token.
+func NewFakeToken(extraClaims map[string]any) (*oauth2.Token, error) {
+	now := time.Now()
+	expiry := now.Add(time.Hour)
+	claims := mergeMaps(map[string]any{
+		"iss":            "https://accounts.google.com",
+		"sub":            "000000000000000000000",
+		"email":          "emulator@emulator-project.iam.gserviceaccount.com",
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            expiry.Unix(),
+	}, extraClaims)
+
+	header, err := json.Marshal(map[string]any{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(fakeAuthSigningKey))
+	mac.Write([]byte(signingInput))
+
+	return &oauth2.Token{
+		TokenType:   "Bearer",
+		AccessToken: signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)),
+		Expiry:      expiry,
+	}, nil
+}
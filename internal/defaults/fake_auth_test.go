@@ -0,0 +1,154 @@
+package defaults_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+)
+
+// verifyFakeToken verifies the signature of the fake token and returns its claims.
+func verifyFakeToken(t *testing.T, token string) map[string]any {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("invalid token: %s", token)
+	}
+
+	mac := hmac.New(sha256.New, []byte("google-cloud-workflow-emulator"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); parts[2] != expected {
+		t.Errorf("invalid signature: %s", parts[2])
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(b, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestFakeAuth(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tt := range []struct {
+		name           string
+		auth           string
+		expectedClaims map[string]any
+	}{
+		{
+			name: "issue the ID token for the URL",
+			auth: `
+            type: OIDC`,
+			expectedClaims: map[string]any{"aud": ts.URL + "/path"},
+		},
+		{
+			name: "issue the ID token for the audience",
+			auth: `
+            type: OIDC
+            audience: https://example.com`,
+			expectedClaims: map[string]any{"aud": "https://example.com"},
+		},
+		{
+			name: "issue the access token for the scopes",
+			auth: `
+            type: OAuth2
+            scopes: [https://www.googleapis.com/auth/a, https://www.googleapis.com/auth/b]`,
+			expectedClaims: map[string]any{"scope": "https://www.googleapis.com/auth/a https://www.googleapis.com/auth/b"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+          auth:` + tt.auth + `
+        result: res
+    - done:
+        return: ${text.decode(res.body)}
+`
+			ret, err := execute(t, source, map[string]any{"url": ts.URL + "/path"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authorization := ret.(string)
+			if !strings.HasPrefix(authorization, "Bearer ") {
+				t.Fatalf("unexpected Authorization: %s", authorization)
+			}
+			claims := verifyFakeToken(t, strings.TrimPrefix(authorization, "Bearer "))
+			for name, expected := range tt.expectedClaims {
+				if claims[name] != expected {
+					t.Errorf("unexpected %s: %v, want %v", name, claims[name], expected)
+				}
+			}
+			if claims["email"] != "emulator@emulator-project.iam.gserviceaccount.com" {
+				t.Errorf("unexpected email: %v", claims["email"])
+			}
+		})
+	}
+}
+
+func TestNewFakeToken(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name           string
+		claims         map[string]any
+		expectedClaims map[string]any
+	}{
+		{
+			name:           "issue the token of the default identity",
+			expectedClaims: map[string]any{"iss": "https://accounts.google.com", "email": "emulator@emulator-project.iam.gserviceaccount.com", "email_verified": true},
+		},
+		{
+			name:           "override the default claims",
+			claims:         map[string]any{"email": "user@example.com", "custom": "x"},
+			expectedClaims: map[string]any{"iss": "https://accounts.google.com", "email": "user@example.com", "custom": "x"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := defaults.NewFakeToken(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.TokenType != "Bearer" || !token.Valid() {
+				t.Errorf("unexpected token: %+v", token)
+			}
+
+			claims := verifyFakeToken(t, token.AccessToken)
+			for name, expected := range tt.expectedClaims {
+				if claims[name] != expected {
+					t.Errorf("unexpected %s: %v, want %v", name, claims[name], expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package defaults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/mitchellh/mapstructure"
+	"github.com/samber/lo"
+)
+
+// googleAPIServices is the list of the connectors exposed under googleapis.*
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis
+var googleAPIServices = []*googleAPIService{
+	firestoreV1,
+	storageV1,
+	cloudTasksV2,
+	runV1,
+	runV2,
+	computeV1,
+	workflowExecutionsV1,
+	pubsubV1,
+}
+
+// googleAPIHelperFunctions is the list of the connector functions which are not a REST method.
+var googleAPIHelperFunctions = []types.Function{
+	workflowExecutionsRun,
+}
+
+var GoogleAPIs = aggregateFunctionsToNestedMap("googleapis", append(lo.FlatMap(googleAPIServices, func(s *googleAPIService, _ int) []types.Function {
+	return s.functions()
+}), googleAPIHelperFunctions...))
+
+const (
+	defaultConnectorTimeout = 1800
+	maxConnectorTimeout     = 31536000
+	maxHTTPRequestTimeout   = 1800
+	defaultConnectorScope   = "https://www.googleapis.com/auth/cloud-platform"
+	connectorMaxRetries     = 5
+)
+
+// googleAPIService is a connector of a version of a Google Cloud API.
+type googleAPIService struct {
+	name    string // e.g. firestore
+	version string // e.g. v1
+	rootURL string // e.g. https://firestore.googleapis.com/ (the regional endpoints take {location} like https://{location}-run.googleapis.com/)
+	methods []googleAPIMethod
+
+	// operationStyle is the style of the long-running operations, defaults to googleLongRunningOperation
+	operationStyle *operationStyle
+
+	// emulatorHostEnv is the environment variable to point the service at its local emulator, e.g. FIRESTORE_EMULATOR_HOST
+	emulatorHostEnv string
+}
+
+// googleAPIMethod is a REST method of a Google Cloud API.
+type googleAPIMethod struct {
+	name        string // e.g. projects.databases.documents.get
+	httpMethod  string
+	path        string // relative to the root URL of the service, e.g. v1/{+name}
+	pathParams  []string
+	queryParams []string
+	body        bool
+	mediaUpload bool // the body is uploaded as the content of the media (uploadType=media)
+	operation   bool // the method returns a long-running operation
+}
+
+func (s *googleAPIService) functions() []types.Function {
+	return lo.Map(s.methods, func(m googleAPIMethod, _ int) types.Function {
+		return s.newFunction(m)
+	})
+}
+
+func (s *googleAPIService) newFunction(m googleAPIMethod) types.Function {
+	pathParams := m.pathParams
+	if strings.Contains(s.rootURL, "{location}") {
+		pathParams = append(pathParams[:len(pathParams):len(pathParams)], "location")
+	}
+
+	args := make([]types.Argument, 0, len(pathParams)+len(m.queryParams)+2)
+	for _, name := range pathParams {
+		args = append(args, types.Argument{Name: name})
+	}
+	for _, name := range m.queryParams {
+		args = append(args, types.Argument{Name: name, Optional: true})
+	}
+	if m.body {
+		args = append(args, types.Argument{Name: "body", Optional: true})
+	}
+	args = append(args, types.Argument{Name: "connector_params", Optional: true})
+
+	argNames := lo.Map(args, func(arg types.Argument, _ int) string { return arg.Name })
+
+	name := fmt.Sprintf("googleapis.%s.%s.%s", s.name, s.version, m.name)
+	return types.NewRawFunction(name, args, func(ctx context.Context, values []any) (any, error) {
+		arg := func(name string) any {
+			i := lo.IndexOf(argNames, name)
+			if i < 0 || i >= len(values) || values[i] == types.SubstitutionNone {
+				return nil
+			}
+			return values[i]
+		}
+
+		pathValues := make(map[string]string, len(pathParams))
+		for _, param := range pathParams {
+			value, ok := arg(param).(string)
+			if !ok {
+				return nil, &types.Error{
+					Tag: types.TypeErrorTag,
+					Err: fmt.Errorf("%s: %s must be a string but got %T", name, param, arg(param)),
+				}
+			}
+			pathValues[param] = value
+		}
+
+		query := map[string]any{}
+		for _, param := range m.queryParams {
+			switch v := arg(param).(type) {
+			case nil:
+				// skip
+			case bool:
+				query[param] = strconv.FormatBool(v)
+			default:
+				query[param] = v
+			}
+		}
+
+		params, err := decodeConnectorParams(name, arg("connector_params"))
+		if err != nil {
+			return nil, err
+		}
+
+		return s.call(ctx, m, pathValues, query, arg("body"), params)
+	})
+}
+
+func decodeConnectorParams(name string, rawParams any) (*connectorParams, error) {
+	var params connectorParams
+	if m, ok := rawParams.(map[string]any); ok {
+		if err := mapstructure.Decode(m, &params); err != nil {
+			return nil, &types.Error{
+				Tag: types.TypeErrorTag,
+				Err: fmt.Errorf("%s: invalid connector_params: %w", name, err),
+			}
+		}
+	}
+	return &params, nil
+}
+
+// connectorParams is the connector_params argument of the connectors.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis#connector_params
+type connectorParams struct {
+	Timeout       float64                 `mapstructure:"timeout"`
+	SkipPolling   bool                    `mapstructure:"skip_polling"`
+	PollingPolicy *connectorPollingPolicy `mapstructure:"polling_policy"`
+	Scopes        any                     `mapstructure:"scopes"`
+}
+
+type connectorPollingPolicy struct {
+	InitialDelay float64 `mapstructure:"initial_delay"`
+	Multiplier   float64 `mapstructure:"multiplier"`
+	MaxDelay     float64 `mapstructure:"max_delay"`
+}
+
+func (s *googleAPIService) call(ctx context.Context, m googleAPIMethod, pathValues map[string]string, query map[string]any, body any, params *connectorParams) (any, error) {
+	timeout := params.Timeout
+	if timeout == 0 {
+		timeout = defaultConnectorTimeout
+	} else if timeout < 0 || timeout > maxConnectorTimeout {
+		return nil, &types.Error{
+			Tag: types.ValueErrorTag,
+			Err: fmt.Errorf("connector_params.timeout must be in 1..%d", maxConnectorTimeout),
+		}
+	}
+	deadline := now(ctx).Add(time.Duration(timeout * float64(time.Second)))
+
+	rootURL, emulated := s.endpoint()
+	rootURL = expandGoogleAPIPath(rootURL, pathValues)
+
+	var auth map[string]any
+	if !emulated {
+		auth = map[string]any{
+			"type":   "OAuth2",
+			"scopes": defaultConnectorScope,
+		}
+		if params.Scopes != nil {
+			auth["scopes"] = params.Scopes
+		}
+	}
+
+	var headers map[string]any
+	if m.mediaUpload {
+		if _, ok := query["uploadType"]; !ok {
+			query["uploadType"] = "media"
+		}
+		if _, ok := body.(string); ok {
+			headers = map[string]any{"Content-Type": "text/plain"}
+		}
+	}
+
+	ret, err := s.request(ctx, m.httpMethod, rootURL+expandGoogleAPIPath(m.path, pathValues), query, body, headers, auth, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if !m.operation || params.SkipPolling {
+		return ret, nil
+	}
+
+	return s.pollOperation(ctx, rootURL, ret, auth, params.PollingPolicy, deadline)
+}
+
+var emulatorHosts sync.Map
+
+// SetEmulatorHost points the connectors of the service at the local emulator.
+// The service is specified by its name (e.g. storage) or its root URL (e.g. https://storage.googleapis.com/).
+// It takes precedence over the environment variable of the emulator host, and the empty host clears it.
+func SetEmulatorHost(service, host string) error {
+	found := false
+	for _, s := range googleAPIServices {
+		if s.name == service || strings.TrimSuffix(s.rootURL, "/") == strings.TrimSuffix(service, "/") {
+			if host == "" {
+				emulatorHosts.Delete(s.name)
+			} else {
+				emulatorHosts.Store(s.name, host)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown connector: %s", service)
+	}
+	return nil
+}
+
+// endpoint returns the root URL of the service, which is the local emulator if it's configured.
+// The emulators don't require any credentials.
+func (s *googleAPIService) endpoint() (rootURL string, emulated bool) {
+	host, _ := emulatorHosts.Load(s.name)
+	if host == nil && s.emulatorHostEnv != "" {
+		if v := os.Getenv(s.emulatorHostEnv); v != "" {
+			host = v
+		}
+	}
+	if host == nil {
+		return s.rootURL, false
+	}
+
+	rootURL = strings.TrimSuffix(host.(string), "/") + "/"
+	if !strings.Contains(rootURL, "://") {
+		rootURL = "http://" + rootURL
+	}
+	return rootURL, true
+}
+
+// request sends the request with the default retry policy of the connectors:
+// retries on 429, 502, 503 and 504 for idempotent methods and 429 and 503 for the others.
+func (s *googleAPIService) request(ctx context.Context, method, rawURL string, query map[string]any, body any, headers, auth map[string]any, deadline time.Time) (any, error) {
+	retryableCodes := []int64{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	if method == http.MethodGet {
+		retryableCodes = append(retryableCodes, http.StatusBadGateway, http.StatusGatewayTimeout)
+	}
+
+	delay := time.Second
+	for retries := 0; ; retries++ {
+		timeout := math.Min(deadline.Sub(now(ctx)).Seconds(), maxHTTPRequestTimeout)
+		if timeout <= 0 {
+			return nil, &types.Error{
+				Tag: types.TimeoutErrorTag,
+				Err: fmt.Errorf("connector call exceeded the timeout"),
+			}
+		}
+
+		res, err := sharedHTTPClient.request(ctx, method, rawURL, timeout, body, headers, query, auth)
+		if err == nil {
+			if b, ok := res["body"].(types.Bytes); ok && len(b) == 0 {
+				return nil, nil // e.g. 204 No Content
+			}
+			return res["body"], nil
+		}
+
+		var e *types.Error
+		if retries >= connectorMaxRetries || !errors.As(err, &e) || e.Tag != types.HttpErrorTag {
+			return nil, err
+		}
+		if code, ok := e.Extra["code"].(int64); !ok || !lo.Contains(retryableCodes, code) {
+			return nil, err
+		}
+
+		if err := Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		delay *= 2
+		if delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+}
+
+// expandGoogleAPIPath expands the URI template of the path like v1/{+name} or v1/{parent}/documents.
+func expandGoogleAPIPath(path string, values map[string]string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(path, '{')
+		j := strings.IndexByte(path, '}')
+		if i < 0 || j < i {
+			b.WriteString(path)
+			return b.String()
+		}
+
+		b.WriteString(path[:i])
+		if name := path[i+1 : j]; strings.HasPrefix(name, "+") {
+			b.WriteString(values[name[1:]])
+		} else {
+			b.WriteString(url.PathEscape(values[name]))
+		}
+		path = path[j+1:]
+	}
+}
@@ -0,0 +1,54 @@
+package defaults
+
+import "net/http"
+
+// cloudTasksV2 is the Cloud Tasks connector.
+// The OIDC/OAuth token config of the HTTP target (e.g. body.task.httpRequest.oidcToken) is passed through to the API as is.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/cloudtasks/Overview
+var cloudTasksV2 = &googleAPIService{
+	name:    "cloudtasks",
+	version: "v2",
+	rootURL: "https://cloudtasks.googleapis.com/",
+	methods: []googleAPIMethod{
+		{
+			name:       "projects.locations.queues.get",
+			httpMethod: http.MethodGet,
+			path:       "v2/{+name}",
+			pathParams: []string{"name"},
+		},
+		{
+			name:        "projects.locations.queues.list",
+			httpMethod:  http.MethodGet,
+			path:        "v2/{+parent}/queues",
+			pathParams:  []string{"parent"},
+			queryParams: []string{"filter", "pageSize", "pageToken"},
+		},
+		{
+			name:       "projects.locations.queues.tasks.create",
+			httpMethod: http.MethodPost,
+			path:       "v2/{+parent}/tasks",
+			pathParams: []string{"parent"},
+			body:       true,
+		},
+		{
+			name:        "projects.locations.queues.tasks.get",
+			httpMethod:  http.MethodGet,
+			path:        "v2/{+name}",
+			pathParams:  []string{"name"},
+			queryParams: []string{"responseView"},
+		},
+		{
+			name:        "projects.locations.queues.tasks.list",
+			httpMethod:  http.MethodGet,
+			path:        "v2/{+parent}/tasks",
+			pathParams:  []string{"parent"},
+			queryParams: []string{"pageSize", "pageToken", "responseView"},
+		},
+		{
+			name:       "projects.locations.queues.tasks.delete",
+			httpMethod: http.MethodDelete,
+			path:       "v2/{+name}",
+			pathParams: []string{"name"},
+		},
+	},
+}
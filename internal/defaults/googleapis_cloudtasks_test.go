@@ -0,0 +1,79 @@
+package defaults_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCloudTasksConnector(t *testing.T) {
+	t.Parallel()
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "create the HTTP task with the OIDC token config as is",
+			source: `
+main:
+  steps:
+    - create:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.tasks.create
+        args:
+          parent: projects/p/locations/l/queues/q
+          body:
+            task:
+              httpRequest:
+                url: https://example.com/task
+                oidcToken:
+                  serviceAccountEmail: sa@p.iam.gserviceaccount.com
+        result: task
+    - done:
+        return: ${task.name}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"projects/p/locations/l/queues/q/tasks/1"}`}},
+			expected:  "projects/p/locations/l/queues/q/tasks/1",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q/tasks", Body: `{"task":{"httpRequest":{"oidcToken":{"serviceAccountEmail":"sa@p.iam.gserviceaccount.com"},"url":"https://example.com/task"}}}`, Authorized: true},
+			},
+		},
+		{
+			name: "get the task with the view",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.tasks.get
+        args:
+          name: projects/p/locations/l/queues/q/tasks/1
+          responseView: FULL
+        result: task
+    - done:
+        return: ${task.view}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"view":"FULL"}`}},
+			expected:  "FULL",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q/tasks/1", Query: "responseView=FULL", Authorized: true},
+			},
+		},
+		{
+			name: "list the tasks with the page token",
+			source: `
+main:
+  steps:
+    - list:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.tasks.list
+        args:
+          parent: projects/p/locations/l/queues/q
+          pageSize: 1
+          pageToken: next
+        result: res
+    - done:
+        return: ${res.nextPageToken}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"tasks":[{}],"nextPageToken":"last"}`}},
+			expected:  "last",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q/tasks", Query: "pageSize=1&pageToken=next", Authorized: true},
+			},
+		},
+	})
+}
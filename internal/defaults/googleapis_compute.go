@@ -0,0 +1,90 @@
+package defaults
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// computeV1 is the Compute Engine connector.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/compute/Overview
+var computeV1 = &googleAPIService{
+	name:           "compute",
+	version:        "v1",
+	rootURL:        "https://compute.googleapis.com/",
+	operationStyle: computeOperation,
+	methods: []googleAPIMethod{
+		{
+			name:       "instances.get",
+			httpMethod: http.MethodGet,
+			path:       "compute/v1/projects/{project}/zones/{zone}/instances/{instance}",
+			pathParams: []string{"project", "zone", "instance"},
+		},
+		{
+			name:        "instances.list",
+			httpMethod:  http.MethodGet,
+			path:        "compute/v1/projects/{project}/zones/{zone}/instances",
+			pathParams:  []string{"project", "zone"},
+			queryParams: []string{"filter", "maxResults", "orderBy", "pageToken", "returnPartialSuccess"},
+		},
+		{
+			name:        "instances.insert",
+			httpMethod:  http.MethodPost,
+			path:        "compute/v1/projects/{project}/zones/{zone}/instances",
+			pathParams:  []string{"project", "zone"},
+			queryParams: []string{"requestId", "sourceInstanceTemplate", "sourceMachineImage"},
+			body:        true,
+			operation:   true,
+		},
+		{
+			name:        "instances.start",
+			httpMethod:  http.MethodPost,
+			path:        "compute/v1/projects/{project}/zones/{zone}/instances/{instance}/start",
+			pathParams:  []string{"project", "zone", "instance"},
+			queryParams: []string{"requestId"},
+			operation:   true,
+		},
+		{
+			name:        "instances.stop",
+			httpMethod:  http.MethodPost,
+			path:        "compute/v1/projects/{project}/zones/{zone}/instances/{instance}/stop",
+			pathParams:  []string{"project", "zone", "instance"},
+			queryParams: []string{"discardLocalSsd", "requestId"},
+			operation:   true,
+		},
+		{
+			name:        "instances.delete",
+			httpMethod:  http.MethodDelete,
+			path:        "compute/v1/projects/{project}/zones/{zone}/instances/{instance}",
+			pathParams:  []string{"project", "zone", "instance"},
+			queryParams: []string{"requestId"},
+			operation:   true,
+		},
+	},
+}
+
+// computeOperation is the style of the Compute Engine operations which are done with status=DONE and polled by selfLink.
+// The polled operation itself is the result.
+var computeOperation = &operationStyle{
+	done: func(op map[string]any) bool {
+		return op["status"] == "DONE"
+	},
+	error: operationErrorField,
+	result: func(op map[string]any) any {
+		return op
+	},
+	url: func(rootURL, _ string, op map[string]any) (string, error) {
+		selfLink, ok := op["selfLink"].(string)
+		if !ok {
+			return "", fmt.Errorf("operation selfLink is missing: %+v", op)
+		}
+
+		// use the root URL instead of the host of the selfLink to respect the emulator host
+		u, err := url.Parse(selfLink)
+		if err != nil {
+			return "", fmt.Errorf("invalid operation selfLink %q: %w", selfLink, err)
+		}
+		return rootURL + strings.TrimPrefix(u.EscapedPath(), "/"), nil
+	},
+}
@@ -0,0 +1,99 @@
+package defaults_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+func TestComputeConnector(t *testing.T) {
+	t.Parallel()
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "get the instance",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.compute.v1.instances.get
+        args:
+          project: p
+          zone: us-central1-a
+          instance: vm
+        result: instance
+    - done:
+        return: ${instance.status}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"status":"RUNNING"}`}},
+			expected:  "RUNNING",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "compute.googleapis.com", Path: "/compute/v1/projects/p/zones/us-central1-a/instances/vm", Authorized: true},
+			},
+		},
+		{
+			name: "poll the operation by the selfLink until it's done",
+			source: `
+main:
+  steps:
+    - stop:
+        call: googleapis.compute.v1.instances.stop
+        args:
+          project: p
+          zone: us-central1-a
+          instance: vm
+        result: op
+    - done:
+        return: ${op.status}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"name":"op","status":"RUNNING","selfLink":"https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-a/operations/op"}`},
+				{code: http.StatusOK, body: `{"name":"op","status":"DONE","selfLink":"https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-a/operations/op"}`},
+			},
+			expected: "DONE",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "compute.googleapis.com", Path: "/compute/v1/projects/p/zones/us-central1-a/instances/vm/stop", Authorized: true},
+				{Method: http.MethodGet, Host: "compute.googleapis.com", Path: "/compute/v1/projects/p/zones/us-central1-a/operations/op", Authorized: true},
+			},
+		},
+		{
+			name: "raise OperationError for the failed operation",
+			source: `
+main:
+  steps:
+    - delete:
+        call: googleapis.compute.v1.instances.delete
+        args:
+          project: p
+          zone: us-central1-a
+          instance: vm
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"name":"op","status":"DONE","error":{"errors":[{"code":"RESOURCE_NOT_FOUND"}]}}`},
+			},
+			expectedErrorTag: types.OperationErrorTag,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodDelete, Host: "compute.googleapis.com", Path: "/compute/v1/projects/p/zones/us-central1-a/instances/vm", Authorized: true},
+			},
+		},
+		{
+			name: "raise ValueError for the operation without the selfLink",
+			source: `
+main:
+  steps:
+    - start:
+        call: googleapis.compute.v1.instances.start
+        args:
+          project: p
+          zone: us-central1-a
+          instance: vm
+`,
+			responses:        []connectorResponse{{code: http.StatusOK, body: `{"name":"op","status":"PENDING"}`}},
+			expectedErrorTag: types.ValueErrorTag,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "compute.googleapis.com", Path: "/compute/v1/projects/p/zones/us-central1-a/instances/vm/start", Authorized: true},
+			},
+		},
+	})
+}
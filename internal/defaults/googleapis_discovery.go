@@ -0,0 +1,166 @@
+package defaults
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/samber/lo"
+)
+
+const discoveryURLFormat = "https://www.googleapis.com/discovery/v1/apis/%s/%s/rest"
+
+// discoveryDocument is the subset of the Google API Discovery document to generate the connectors.
+// refs. https://developers.google.com/discovery/v1/reference/apis
+type discoveryDocument struct {
+	Name        string                        `json:"name"`
+	Version     string                        `json:"version"`
+	RootURL     string                        `json:"rootUrl"`
+	ServicePath string                        `json:"servicePath"`
+	Methods     map[string]*discoveryMethod   `json:"methods"`
+	Resources   map[string]*discoveryResource `json:"resources"`
+	Schemas     map[string]struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	} `json:"schemas"`
+}
+
+type discoveryResource struct {
+	Methods   map[string]*discoveryMethod   `json:"methods"`
+	Resources map[string]*discoveryResource `json:"resources"`
+}
+
+type discoveryMethod struct {
+	HTTPMethod string `json:"httpMethod"`
+	Path       string `json:"path"`
+	Parameters map[string]struct {
+		Location string `json:"location"`
+	} `json:"parameters"`
+	ParameterOrder []string `json:"parameterOrder"`
+	Request        *struct {
+		Ref string `json:"$ref"`
+	} `json:"request"`
+	Response *struct {
+		Ref string `json:"$ref"`
+	} `json:"response"`
+}
+
+// LoadDiscoveryDocument loads the discovery document of the API (e.g. bigquery and v2) from the user cache directory,
+// or fetches it from the discovery service and caches it.
+func LoadDiscoveryDocument(api, version string) ([]byte, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("os.UserCacheDir: %w", err)
+	}
+	cachePath := filepath.Join(cacheDir, "google-cloud-workflow-emulator", "discovery", api+"."+version+".json")
+	if b, err := os.ReadFile(cachePath); err == nil {
+		return b, nil
+	}
+
+	res, err := http.Get(fmt.Sprintf(discoveryURLFormat, api, version))
+	if err != nil {
+		return nil, fmt.Errorf("http.Get: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery document of %s %s is not found: status code %d is returned", api, version, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	if err = os.WriteFile(cachePath, b, 0o644); err != nil {
+		return nil, fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return b, nil
+}
+
+// RegisterDiscoveryDocument generates the connectors from the discovery document and exposes them under googleapis.*
+// The connectors defined by the emulator take precedence over the generated ones.
+// It must be called before executing any workflows.
+func RegisterDiscoveryDocument(b []byte) error {
+	var doc discoveryDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if doc.Name == "" || doc.Version == "" || doc.RootURL == "" {
+		return fmt.Errorf("invalid discovery document: name, version and rootUrl are required")
+	}
+
+	s := &googleAPIService{
+		name:           doc.Name,
+		version:        doc.Version,
+		rootURL:        doc.RootURL,
+		operationStyle: doc.operationStyle(),
+	}
+	doc.collectMethods(s, "", doc.Methods, doc.Resources)
+	for _, known := range googleAPIServices {
+		if known.name == s.name && known.emulatorHostEnv != "" {
+			s.emulatorHostEnv = known.emulatorHostEnv
+		}
+	}
+	googleAPIServices = append(googleAPIServices, s)
+
+	for _, f := range s.functions() {
+		names := strings.Split(strings.TrimPrefix(f.Name(), "googleapis."), ".")
+		_ = putFunctionToNestedMap(GoogleAPIs, names, f) // ignore the duplicated ones
+	}
+	return nil
+}
+
+func (doc *discoveryDocument) operationStyle() *operationStyle {
+	op, ok := doc.Schemas["Operation"]
+	if !ok {
+		return nil
+	}
+	if _, ok := op.Properties["done"]; ok {
+		return googleLongRunningOperation
+	}
+	if _, ok := op.Properties["selfLink"]; ok {
+		return computeOperation
+	}
+	return nil
+}
+
+func (doc *discoveryDocument) collectMethods(s *googleAPIService, prefix string, methods map[string]*discoveryMethod, resources map[string]*discoveryResource) {
+	for name, method := range methods {
+		var pathParams, queryParams []string
+		for _, param := range method.ParameterOrder {
+			if method.Parameters[param].Location == "path" {
+				pathParams = append(pathParams, param)
+			}
+		}
+		for param, def := range method.Parameters {
+			switch def.Location {
+			case "path":
+				if !lo.Contains(pathParams, param) {
+					pathParams = append(pathParams, param)
+				}
+			case "query":
+				queryParams = append(queryParams, param)
+			}
+		}
+		sort.Strings(queryParams)
+
+		s.methods = append(s.methods, googleAPIMethod{
+			name:        prefix + name,
+			httpMethod:  method.HTTPMethod,
+			path:        doc.ServicePath + method.Path,
+			pathParams:  pathParams,
+			queryParams: queryParams,
+			body:        method.Request != nil,
+			operation:   s.operationStyle != nil && method.Response != nil && method.Response.Ref == "Operation",
+		})
+	}
+	for name, resource := range resources {
+		doc.collectMethods(s, prefix+name+".", resource.Methods, resource.Resources)
+	}
+}
@@ -0,0 +1,176 @@
+package defaults_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+)
+
+const testDiscoveryDocument = `{
+  "name": "testapi",
+  "version": "v1",
+  "rootUrl": "https://testapi.googleapis.com/",
+  "servicePath": "",
+  "schemas": {
+    "Operation": {"properties": {"name": {}, "done": {}, "response": {}, "error": {}}}
+  },
+  "resources": {
+    "projects": {
+      "resources": {
+        "items": {
+          "methods": {
+            "get": {
+              "httpMethod": "GET",
+              "path": "v1/{+name}",
+              "parameters": {"name": {"location": "path"}, "view": {"location": "query"}},
+              "parameterOrder": ["name"],
+              "response": {"$ref": "Item"}
+            },
+            "create": {
+              "httpMethod": "POST",
+              "path": "v1/{+parent}/items",
+              "parameters": {"parent": {"location": "path"}},
+              "parameterOrder": ["parent"],
+              "request": {"$ref": "Item"},
+              "response": {"$ref": "Operation"}
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// the connectors generated from the discovery documents are registered globally, so these tests must not be parallel.
+func TestRegisterDiscoveryDocument(t *testing.T) {
+	if err := defaults.RegisterDiscoveryDocument([]byte(testDiscoveryDocument)); err != nil {
+		t.Fatal(err)
+	}
+	if err := defaults.RegisterDiscoveryDocument([]byte(`{
+  "name": "storage",
+  "version": "v1",
+  "rootUrl": "https://storage.googleapis.com/",
+  "servicePath": "storage/v1/",
+  "resources": {
+    "buckets": {
+      "methods": {
+        "get": {"httpMethod": "GET", "path": "generated/{bucket}", "parameters": {"bucket": {"location": "path"}}, "parameterOrder": ["bucket"]}
+      }
+    }
+  }
+}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []connectorTest{
+		{
+			name: "call the generated method with the query parameter",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.testapi.v1.projects.items.get
+        args:
+          name: projects/p/items/i
+          view: FULL
+        result: item
+    - done:
+        return: ${item.name}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"projects/p/items/i"}`}},
+			expected:  "projects/p/items/i",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "testapi.googleapis.com", Path: "/v1/projects/p/items/i", Query: "view=FULL", Authorized: true},
+			},
+		},
+		{
+			name: "poll the operation returned by the generated method",
+			source: `
+main:
+  steps:
+    - create:
+        call: googleapis.testapi.v1.projects.items.create
+        args:
+          parent: projects/p
+          body:
+            title: new
+        result: item
+    - done:
+        return: ${item.title}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"name":"operations/op","done":false}`},
+				{code: http.StatusOK, body: `{"name":"operations/op","done":true,"response":{"title":"new"}}`},
+			},
+			expected: "new",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "testapi.googleapis.com", Path: "/v1/projects/p/items", Body: `{"title":"new"}`, Authorized: true},
+				{Method: http.MethodGet, Host: "testapi.googleapis.com", Path: "/v1/operations/op", Authorized: true},
+			},
+		},
+		{
+			name: "prefer the connector defined by the emulator",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.storage.v1.buckets.get
+        args:
+          bucket: b
+        result: bucket
+    - done:
+        return: ${bucket.name}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"b"}`}},
+			expected:  "b",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "storage.googleapis.com", Path: "/storage/v1/b/b", Authorized: true},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			runConnectorTest(t, tt)
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		doc  string
+	}{
+		{name: "malformed JSON", doc: `{`},
+		{name: "missing rootUrl", doc: `{"name":"x","version":"v1"}`},
+	} {
+		t.Run("reject "+tt.name, func(t *testing.T) {
+			err := defaults.RegisterDiscoveryDocument([]byte(tt.doc))
+			if err == nil {
+				t.Fatal("should be error")
+			}
+			t.Logf("expected error: %v", err)
+		})
+	}
+}
+
+func TestLoadDiscoveryDocument(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	t.Setenv("HOME", cacheDir)
+
+	cachePath := filepath.Join(cacheDir, "google-cloud-workflow-emulator", "discovery", "testapi.v1.json")
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, []byte(testDiscoveryDocument), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := defaults.LoadDiscoveryDocument("testapi", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testDiscoveryDocument {
+		t.Errorf("unexpected document: %s", b)
+	}
+}
@@ -0,0 +1,79 @@
+package defaults
+
+import "net/http"
+
+// firestoreV1 is the Firestore connector.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/firestore/Overview
+var firestoreV1 = &googleAPIService{
+	name:            "firestore",
+	version:         "v1",
+	rootURL:         "https://firestore.googleapis.com/",
+	emulatorHostEnv: "FIRESTORE_EMULATOR_HOST",
+	methods: []googleAPIMethod{
+		{
+			name:        "projects.databases.documents.get",
+			httpMethod:  http.MethodGet,
+			path:        "v1/{+name}",
+			pathParams:  []string{"name"},
+			queryParams: []string{"readTime", "transaction"},
+		},
+		{
+			name:        "projects.databases.documents.list",
+			httpMethod:  http.MethodGet,
+			path:        "v1/{+parent}/{collectionId}",
+			pathParams:  []string{"parent", "collectionId"},
+			queryParams: []string{"orderBy", "pageSize", "pageToken", "readTime", "showMissing", "transaction"},
+		},
+		{
+			name:        "projects.databases.documents.createDocument",
+			httpMethod:  http.MethodPost,
+			path:        "v1/{+parent}/{collectionId}",
+			pathParams:  []string{"parent", "collectionId"},
+			queryParams: []string{"documentId"},
+			body:        true,
+		},
+		{
+			name:        "projects.databases.documents.patch",
+			httpMethod:  http.MethodPatch,
+			path:        "v1/{+name}",
+			pathParams:  []string{"name"},
+			queryParams: []string{"currentDocument.exists", "currentDocument.updateTime"},
+			body:        true,
+		},
+		{
+			name:        "projects.databases.documents.delete",
+			httpMethod:  http.MethodDelete,
+			path:        "v1/{+name}",
+			pathParams:  []string{"name"},
+			queryParams: []string{"currentDocument.exists", "currentDocument.updateTime"},
+		},
+		{
+			name:       "projects.databases.documents.runQuery",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+parent}:runQuery",
+			pathParams: []string{"parent"},
+			body:       true,
+		},
+		{
+			name:       "projects.databases.documents.beginTransaction",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+database}/documents:beginTransaction",
+			pathParams: []string{"database"},
+			body:       true,
+		},
+		{
+			name:       "projects.databases.documents.commit",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+database}/documents:commit",
+			pathParams: []string{"database"},
+			body:       true,
+		},
+		{
+			name:       "projects.databases.documents.rollback",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+database}/documents:rollback",
+			pathParams: []string{"database"},
+			body:       true,
+		},
+	},
+}
@@ -0,0 +1,153 @@
+package defaults_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+func TestFirestoreConnector(t *testing.T) {
+	t.Parallel()
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "get the document",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.firestore.v1.projects.databases.documents.get
+        args:
+          name: projects/p/databases/(default)/documents/users/alice
+          transaction: dHg=
+        result: doc
+    - done:
+        return: ${doc.fields.name.stringValue}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"projects/p/databases/(default)/documents/users/alice","fields":{"name":{"stringValue":"Alice"}}}`}},
+			expected:  "Alice",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents/users/alice", Query: "transaction=dHg%3D", Authorized: true},
+			},
+		},
+		{
+			name: "create the document",
+			source: `
+main:
+  steps:
+    - create:
+        call: googleapis.firestore.v1.projects.databases.documents.createDocument
+        args:
+          parent: projects/p/databases/(default)/documents
+          collectionId: users
+          documentId: bob
+          body:
+            fields:
+              name:
+                stringValue: Bob
+        result: doc
+    - done:
+        return: ${doc.name}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"projects/p/databases/(default)/documents/users/bob"}`}},
+			expected:  "projects/p/databases/(default)/documents/users/bob",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents/users", Query: "documentId=bob", Body: `{"fields":{"name":{"stringValue":"Bob"}}}`, Authorized: true},
+			},
+		},
+		{
+			name: "patch the existing document",
+			source: `
+main:
+  steps:
+    - patch:
+        call: googleapis.firestore.v1.projects.databases.documents.patch
+        args:
+          name: projects/p/databases/(default)/documents/users/bob
+          currentDocument.exists: true
+          body:
+            fields:
+              age:
+                integerValue: "20"
+        result: doc
+    - done:
+        return: ${doc.fields.age.integerValue}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"fields":{"age":{"integerValue":"20"}}}`}},
+			expected:  "20",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPatch, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents/users/bob", Query: "currentDocument.exists=true", Body: `{"fields":{"age":{"integerValue":"20"}}}`, Authorized: true},
+			},
+		},
+		{
+			name: "run the query",
+			source: `
+main:
+  steps:
+    - query:
+        call: googleapis.firestore.v1.projects.databases.documents.runQuery
+        args:
+          parent: projects/p/databases/(default)/documents
+          body:
+            structuredQuery:
+              from:
+                - collectionId: users
+        result: results
+    - done:
+        return: ${len(results)}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `[{"document":{"name":"a"}},{"document":{"name":"b"}}]`}},
+			expected:  int64(2),
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents:runQuery", Body: `{"structuredQuery":{"from":[{"collectionId":"users"}]}}`, Authorized: true},
+			},
+		},
+		{
+			name: "commit the transaction",
+			source: `
+main:
+  steps:
+    - begin:
+        call: googleapis.firestore.v1.projects.databases.documents.beginTransaction
+        args:
+          database: projects/p/databases/(default)
+        result: tx
+    - commit:
+        call: googleapis.firestore.v1.projects.databases.documents.commit
+        args:
+          database: projects/p/databases/(default)
+          body:
+            transaction: ${tx.transaction}
+        result: res
+    - done:
+        return: ${res.commitTime}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"transaction":"dHg="}`},
+				{code: http.StatusOK, body: `{"commitTime":"2024-01-01T00:00:00Z"}`},
+			},
+			expected: "2024-01-01T00:00:00Z",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents:beginTransaction", Authorized: true},
+				{Method: http.MethodPost, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents:commit", Body: `{"transaction":"dHg="}`, Authorized: true},
+			},
+		},
+		{
+			name: "raise HttpError for the missing document",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.firestore.v1.projects.databases.documents.get
+        args:
+          name: projects/p/databases/(default)/documents/users/nobody
+`,
+			responses:        []connectorResponse{{code: http.StatusNotFound, body: `{"error":{"code":404,"status":"NOT_FOUND"}}`}},
+			expectedErrorTag: types.HttpErrorTag,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents/users/nobody", Authorized: true},
+			},
+		},
+	})
+}
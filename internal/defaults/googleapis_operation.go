@@ -0,0 +1,118 @@
+package defaults
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// pollOperation polls the long-running operation returned by the connector until it's done,
+// and returns its result or raises OperationError if it failed.
+// refs. https://cloud.google.com/workflows/docs/connectors#long-running_operations
+func (s *googleAPIService) pollOperation(ctx context.Context, rootURL string, ret any, auth map[string]any, policy *connectorPollingPolicy, deadline time.Time) (any, error) {
+	if policy == nil {
+		policy = &connectorPollingPolicy{}
+	}
+	if policy.InitialDelay == 0 {
+		policy.InitialDelay = 1
+	}
+	if policy.Multiplier == 0 {
+		policy.Multiplier = 1.25
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = 60
+	}
+	if policy.InitialDelay < 0 || policy.Multiplier < 1 || policy.MaxDelay < policy.InitialDelay {
+		return nil, &types.Error{
+			Tag: types.ValueErrorTag,
+			Err: fmt.Errorf("invalid connector_params.polling_policy: %+v", *policy),
+		}
+	}
+
+	style := s.operationStyle
+	if style == nil {
+		style = googleLongRunningOperation
+	}
+
+	delay := time.Duration(policy.InitialDelay * float64(time.Second))
+	for {
+		op, ok := ret.(map[string]any)
+		if !ok {
+			return nil, &types.Error{
+				Tag: types.TypeErrorTag,
+				Err: fmt.Errorf("unexpected operation type: %T", ret),
+			}
+		}
+		if style.done(op) {
+			if opErr := style.error(op); opErr != nil {
+				return nil, &types.Error{
+					Tag:   types.OperationErrorTag,
+					Err:   fmt.Errorf("operation %v failed", op["name"]),
+					Extra: map[string]any{"operation": op},
+				}
+			}
+			return style.result(op), nil
+		}
+
+		pollURL, err := style.url(rootURL, s.version, op)
+		if err != nil {
+			return nil, &types.Error{
+				Tag: types.ValueErrorTag,
+				Err: err,
+			}
+		}
+
+		if now(ctx).Add(delay).After(deadline) {
+			return nil, &types.Error{
+				Tag: types.TimeoutErrorTag,
+				Err: fmt.Errorf("operation %v is not done until the timeout", op["name"]),
+			}
+		}
+		if err := Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		delay = time.Duration(float64(delay) * policy.Multiplier)
+		if maxDelay := time.Duration(policy.MaxDelay * float64(time.Second)); delay > maxDelay {
+			delay = maxDelay
+		}
+
+		ret, err = s.request(ctx, http.MethodGet, pollURL, nil, nil, nil, auth, deadline)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// operationStyle is the way to poll the long-running operations which differs by the services.
+type operationStyle struct {
+	done   func(op map[string]any) bool
+	error  func(op map[string]any) any // returns nil if the done operation succeeded
+	result func(op map[string]any) any
+	url    func(rootURL, version string, op map[string]any) (string, error)
+}
+
+func operationErrorField(op map[string]any) any {
+	return op["error"]
+}
+
+// googleLongRunningOperation is the style of google.longrunning.Operation which is used by the most of the services.
+var googleLongRunningOperation = &operationStyle{
+	done: func(op map[string]any) bool {
+		done, _ := op["done"].(bool)
+		return done
+	},
+	error: operationErrorField,
+	result: func(op map[string]any) any {
+		return op["response"]
+	},
+	url: func(rootURL, version string, op map[string]any) (string, error) {
+		name, ok := op["name"].(string)
+		if !ok {
+			return "", fmt.Errorf("operation name is missing: %+v", op)
+		}
+		return rootURL + version + "/" + name, nil
+	},
+}
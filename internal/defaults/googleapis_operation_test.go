@@ -0,0 +1,162 @@
+package defaults_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+func TestConnectorOperation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		running = `{"name":"projects/p/locations/l/operations/op","done":false}`
+		done    = `{"name":"projects/p/locations/l/operations/op","done":true,"response":{"name":"projects/p/locations/l/jobs/j/executions/e"}}`
+		failed  = `{"name":"projects/p/locations/l/operations/op","done":true,"error":{"code":9,"message":"failed"}}`
+	)
+	runJob := connectorRequest{Method: http.MethodPost, Host: "run.googleapis.com", Path: "/v2/projects/p/locations/l/jobs/j:run", Authorized: true}
+	getOperation := connectorRequest{Method: http.MethodGet, Host: "run.googleapis.com", Path: "/v2/projects/p/locations/l/operations/op", Authorized: true}
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "skip polling",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.run.v2.projects.locations.jobs.run
+        args:
+          name: projects/p/locations/l/jobs/j
+          connector_params:
+            skip_polling: true
+        result: op
+    - done:
+        return: ${op.done}
+`,
+			responses:        []connectorResponse{{code: http.StatusOK, body: running}},
+			expected:         false,
+			expectedRequests: []connectorRequest{runJob},
+		},
+		{
+			name: "poll by the polling policy",
+			source: `
+main:
+  steps:
+    - start:
+        assign:
+          - start: ${sys.now()}
+    - run:
+        call: googleapis.run.v2.projects.locations.jobs.run
+        args:
+          name: projects/p/locations/l/jobs/j
+          connector_params:
+            polling_policy:
+              initial_delay: 2
+              multiplier: 2
+              max_delay: 3
+        result: execution
+    - done:
+        return: ${sys.now() - start}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: running},
+				{code: http.StatusOK, body: running},
+				{code: http.StatusOK, body: running},
+				{code: http.StatusOK, body: done},
+			},
+			expected:         int64(2 + 3 + 3),
+			expectedRequests: []connectorRequest{runJob, getOperation, getOperation, getOperation},
+		},
+		{
+			name: "raise OperationError with the operation",
+			source: `
+main:
+  steps:
+    - run:
+        try:
+          call: googleapis.run.v2.projects.locations.jobs.run
+          args:
+            name: projects/p/locations/l/jobs/j
+        except:
+          as: e
+          steps:
+            - caught:
+                return: ${e.operation.error.message}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: running},
+				{code: http.StatusOK, body: failed},
+			},
+			expected:         "failed",
+			expectedRequests: []connectorRequest{runJob, getOperation},
+		},
+		{
+			name: "raise TimeoutError if the operation is not done until the timeout",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.run.v2.projects.locations.jobs.run
+        args:
+          name: projects/p/locations/l/jobs/j
+          connector_params:
+            timeout: 10
+            polling_policy:
+              initial_delay: 4
+              multiplier: 1
+`,
+			responses:        []connectorResponse{{code: http.StatusOK, body: running}},
+			expectedErrorTag: types.TimeoutErrorTag,
+			expectedRequests: []connectorRequest{runJob, getOperation, getOperation},
+		},
+		{
+			name: "reject the invalid timeout",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.run.v2.projects.locations.jobs.run
+        args:
+          name: projects/p/locations/l/jobs/j
+          connector_params:
+            timeout: -1
+`,
+			responses:        []connectorResponse{{code: http.StatusOK, body: done}},
+			expectedErrorTag: types.ValueErrorTag,
+		},
+		{
+			name: "reject the invalid polling policy",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.run.v2.projects.locations.jobs.run
+        args:
+          name: projects/p/locations/l/jobs/j
+          connector_params:
+            polling_policy:
+              initial_delay: 10
+              max_delay: 5
+`,
+			responses:        []connectorResponse{{code: http.StatusOK, body: running}},
+			expectedErrorTag: types.ValueErrorTag,
+			expectedRequests: []connectorRequest{runJob},
+		},
+		{
+			name: "reject the malformed connector_params",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.run.v2.projects.locations.jobs.run
+        args:
+          name: projects/p/locations/l/jobs/j
+          connector_params:
+            skip_polling: yes please
+`,
+			responses:        []connectorResponse{{code: http.StatusOK, body: done}},
+			expectedErrorTag: types.TypeErrorTag,
+		},
+	})
+}
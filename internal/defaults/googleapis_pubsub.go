@@ -0,0 +1,41 @@
+package defaults
+
+import "net/http"
+
+// pubsubV1 is the Pub/Sub connector.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/pubsub/Overview
+var pubsubV1 = &googleAPIService{
+	name:            "pubsub",
+	version:         "v1",
+	rootURL:         "https://pubsub.googleapis.com/",
+	emulatorHostEnv: "PUBSUB_EMULATOR_HOST",
+	methods: []googleAPIMethod{
+		{
+			name:       "projects.topics.get",
+			httpMethod: http.MethodGet,
+			path:       "v1/{+topic}",
+			pathParams: []string{"topic"},
+		},
+		{
+			name:       "projects.topics.publish",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+topic}:publish",
+			pathParams: []string{"topic"},
+			body:       true,
+		},
+		{
+			name:       "projects.subscriptions.pull",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+subscription}:pull",
+			pathParams: []string{"subscription"},
+			body:       true,
+		},
+		{
+			name:       "projects.subscriptions.acknowledge",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+subscription}:acknowledge",
+			pathParams: []string{"subscription"},
+			body:       true,
+		},
+	},
+}
@@ -0,0 +1,74 @@
+package defaults
+
+import "net/http"
+
+// runV1 is the Cloud Run Admin API v1 connector, which is served by the regional endpoints.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/run/v1/Overview
+var runV1 = &googleAPIService{
+	name:    "run",
+	version: "v1",
+	rootURL: "https://{location}-run.googleapis.com/",
+	methods: []googleAPIMethod{
+		{
+			name:       "namespaces.services.get",
+			httpMethod: http.MethodGet,
+			path:       "apis/serving.knative.dev/v1/{+name}",
+			pathParams: []string{"name"},
+		},
+		{
+			name:       "namespaces.jobs.get",
+			httpMethod: http.MethodGet,
+			path:       "apis/run.googleapis.com/v1/{+name}",
+			pathParams: []string{"name"},
+		},
+		{
+			name:       "namespaces.jobs.run",
+			httpMethod: http.MethodPost,
+			path:       "apis/run.googleapis.com/v1/{+name}:run",
+			pathParams: []string{"name"},
+			body:       true,
+		},
+		{
+			name:       "namespaces.executions.get",
+			httpMethod: http.MethodGet,
+			path:       "apis/run.googleapis.com/v1/{+name}",
+			pathParams: []string{"name"},
+		},
+	},
+}
+
+// runV2 is the Cloud Run Admin API v2 connector.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/run/v2/Overview
+var runV2 = &googleAPIService{
+	name:    "run",
+	version: "v2",
+	rootURL: "https://run.googleapis.com/",
+	methods: []googleAPIMethod{
+		{
+			name:       "projects.locations.services.get",
+			httpMethod: http.MethodGet,
+			path:       "v2/{+name}",
+			pathParams: []string{"name"},
+		},
+		{
+			name:       "projects.locations.jobs.get",
+			httpMethod: http.MethodGet,
+			path:       "v2/{+name}",
+			pathParams: []string{"name"},
+		},
+		{
+			name:       "projects.locations.jobs.run",
+			httpMethod: http.MethodPost,
+			path:       "v2/{+name}:run",
+			pathParams: []string{"name"},
+			body:       true,
+			operation:  true,
+		},
+		{
+			name:       "projects.locations.jobs.executions.get",
+			httpMethod: http.MethodGet,
+			path:       "v2/{+name}",
+			pathParams: []string{"name"},
+		},
+	},
+}
@@ -0,0 +1,78 @@
+package defaults_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRunConnector(t *testing.T) {
+	t.Parallel()
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "call the regional endpoint of v1",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.run.v1.namespaces.services.get
+        args:
+          name: namespaces/p/services/s
+          location: us-central1
+        result: svc
+    - done:
+        return: ${svc.status.url}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"status":{"url":"https://s-xxx.a.run.app"}}`}},
+			expected:  "https://s-xxx.a.run.app",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "us-central1-run.googleapis.com", Path: "/apis/serving.knative.dev/v1/namespaces/p/services/s", Authorized: true},
+			},
+		},
+		{
+			name: "run the job of v1 without polling",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.run.v1.namespaces.jobs.run
+        args:
+          name: namespaces/p/jobs/j
+          location: asia-northeast1
+        result: execution
+    - done:
+        return: ${execution.metadata.name}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"metadata":{"name":"j-abc"}}`}},
+			expected:  "j-abc",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "asia-northeast1-run.googleapis.com", Path: "/apis/run.googleapis.com/v1/namespaces/p/jobs/j:run", Authorized: true},
+			},
+		},
+		{
+			name: "run the job of v2 until the operation is done",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.run.v2.projects.locations.jobs.run
+        args:
+          name: projects/p/locations/l/jobs/j
+        result: execution
+    - done:
+        return: ${execution.name}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"name":"projects/p/locations/l/operations/op","done":false}`},
+				{code: http.StatusOK, body: `{"name":"projects/p/locations/l/operations/op","done":false}`},
+				{code: http.StatusOK, body: `{"name":"projects/p/locations/l/operations/op","done":true,"response":{"name":"projects/p/locations/l/jobs/j/executions/e"}}`},
+			},
+			expected: "projects/p/locations/l/jobs/j/executions/e",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "run.googleapis.com", Path: "/v2/projects/p/locations/l/jobs/j:run", Authorized: true},
+				{Method: http.MethodGet, Host: "run.googleapis.com", Path: "/v2/projects/p/locations/l/operations/op", Authorized: true},
+				{Method: http.MethodGet, Host: "run.googleapis.com", Path: "/v2/projects/p/locations/l/operations/op", Authorized: true},
+			},
+		},
+	})
+}
@@ -0,0 +1,51 @@
+package defaults
+
+import "net/http"
+
+// storageV1 is the Cloud Storage connector.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/storage/Overview
+var storageV1 = &googleAPIService{
+	name:            "storage",
+	version:         "v1",
+	rootURL:         "https://storage.googleapis.com/",
+	emulatorHostEnv: "STORAGE_EMULATOR_HOST",
+	methods: []googleAPIMethod{
+		{
+			name:        "buckets.get",
+			httpMethod:  http.MethodGet,
+			path:        "storage/v1/b/{bucket}",
+			pathParams:  []string{"bucket"},
+			queryParams: []string{"ifMetagenerationMatch", "ifMetagenerationNotMatch", "projection", "userProject"},
+		},
+		{
+			name:        "objects.get",
+			httpMethod:  http.MethodGet,
+			path:        "storage/v1/b/{bucket}/o/{object}",
+			pathParams:  []string{"bucket", "object"},
+			queryParams: []string{"alt", "generation", "ifGenerationMatch", "ifGenerationNotMatch", "ifMetagenerationMatch", "ifMetagenerationNotMatch", "projection", "userProject"},
+		},
+		{
+			name:        "objects.list",
+			httpMethod:  http.MethodGet,
+			path:        "storage/v1/b/{bucket}/o",
+			pathParams:  []string{"bucket"},
+			queryParams: []string{"delimiter", "endOffset", "includeTrailingDelimiter", "matchGlob", "maxResults", "pageToken", "prefix", "projection", "startOffset", "userProject", "versions"},
+		},
+		{
+			name:        "objects.insert",
+			httpMethod:  http.MethodPost,
+			path:        "upload/storage/v1/b/{bucket}/o",
+			pathParams:  []string{"bucket"},
+			queryParams: []string{"name", "uploadType", "contentEncoding", "ifGenerationMatch", "ifGenerationNotMatch", "ifMetagenerationMatch", "ifMetagenerationNotMatch", "kmsKeyName", "predefinedAcl", "projection", "userProject"},
+			body:        true,
+			mediaUpload: true,
+		},
+		{
+			name:        "objects.delete",
+			httpMethod:  http.MethodDelete,
+			path:        "storage/v1/b/{bucket}/o/{object}",
+			pathParams:  []string{"bucket", "object"},
+			queryParams: []string{"generation", "ifGenerationMatch", "ifGenerationNotMatch", "ifMetagenerationMatch", "ifMetagenerationNotMatch", "userProject"},
+		},
+	},
+}
@@ -0,0 +1,138 @@
+package defaults_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStorageConnector(t *testing.T) {
+	t.Parallel()
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "get the metadata of the object",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.storage.v1.objects.get
+        args:
+          bucket: my-bucket
+          object: dir/file.txt
+        result: obj
+    - done:
+        return: ${obj.size}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"dir/file.txt","size":"5"}`}},
+			expected:  "5",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "storage.googleapis.com", Path: "/storage/v1/b/my-bucket/o/dir%2Ffile.txt", Authorized: true},
+			},
+		},
+		{
+			name: "download the content of the object",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.storage.v1.objects.get
+        args:
+          bucket: my-bucket
+          object: file.txt
+          alt: media
+        result: content
+    - done:
+        return: ${text.decode(content)}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, contentType: "text/plain", body: "hello"}},
+			expected:  "hello",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "storage.googleapis.com", Path: "/storage/v1/b/my-bucket/o/file.txt", Query: "alt=media", Authorized: true},
+			},
+		},
+		{
+			name: "upload the text as the media",
+			source: `
+main:
+  steps:
+    - insert:
+        call: googleapis.storage.v1.objects.insert
+        args:
+          bucket: my-bucket
+          name: file.txt
+          body: hello
+        result: obj
+    - done:
+        return: ${obj.name}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"file.txt"}`}},
+			expected:  "file.txt",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "storage.googleapis.com", Path: "/upload/storage/v1/b/my-bucket/o", Query: "name=file.txt&uploadType=media", Body: "hello", Authorized: true},
+			},
+		},
+		{
+			name: "upload the map as the JSON media",
+			source: `
+main:
+  steps:
+    - insert:
+        call: googleapis.storage.v1.objects.insert
+        args:
+          bucket: my-bucket
+          name: data.json
+          body:
+            key: value
+        result: obj
+    - done:
+        return: ${obj.name}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"data.json"}`}},
+			expected:  "data.json",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "storage.googleapis.com", Path: "/upload/storage/v1/b/my-bucket/o", Query: "name=data.json&uploadType=media", Body: `{"key":"value"}`, Authorized: true},
+			},
+		},
+		{
+			name: "list the objects with the prefix",
+			source: `
+main:
+  steps:
+    - list:
+        call: googleapis.storage.v1.objects.list
+        args:
+          bucket: my-bucket
+          prefix: dir/
+          versions: true
+        result: res
+    - done:
+        return: ${len(res.items)}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"items":[{"name":"dir/a"},{"name":"dir/b"}]}`}},
+			expected:  int64(2),
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "storage.googleapis.com", Path: "/storage/v1/b/my-bucket/o", Query: "prefix=dir%2F&versions=true", Authorized: true},
+			},
+		},
+		{
+			name: "delete the object",
+			source: `
+main:
+  steps:
+    - delete:
+        call: googleapis.storage.v1.objects.delete
+        args:
+          bucket: my-bucket
+          object: file.txt
+        result: res
+    - done:
+        return: ${res == null}
+`,
+			responses: []connectorResponse{{code: http.StatusNoContent}},
+			expected:  true,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodDelete, Host: "storage.googleapis.com", Path: "/storage/v1/b/my-bucket/o/file.txt", Authorized: true},
+			},
+		},
+	})
+}
@@ -0,0 +1,387 @@
+package defaults_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// connectorRequest is the request received by the fake API of the connectors.
+type connectorRequest struct {
+	Method     string
+	Host       string
+	Path       string
+	Query      string
+	Body       string
+	Authorized bool
+}
+
+// connectorResponse is the response of the fake API of the connectors.
+type connectorResponse struct {
+	code        int
+	contentType string // defaults to application/json if the body is given
+	body        string
+}
+
+type connectorTest struct {
+	name             string
+	source           string
+	opts             []workflow.ExecuteOption
+	responses        []connectorResponse
+	expected         any
+	expectedRequests []connectorRequest
+	expectedErrorTag types.ErrorTag
+}
+
+// routeTransport sends all the requests to the test server instead of the hosts of the URLs.
+type routeTransport struct {
+	target *url.URL
+}
+
+func (rt *routeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Forwarded-Host", req.URL.Host)
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	req.Host = ""
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newFakeAPI starts the fake API responding the responses in order (the last one is repeated), and returns the client
+// routing all the requests to it and the function to get the received requests.
+func newFakeAPI(t *testing.T, responses []connectorResponse) (*http.Client, func() []connectorRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []connectorRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		i := len(requests)
+		requests = append(requests, connectorRequest{
+			Method:     r.Method,
+			Host:       r.Header.Get("X-Forwarded-Host"),
+			Path:       r.URL.EscapedPath(),
+			Query:      r.URL.RawQuery,
+			Body:       strings.TrimSpace(string(body)),
+			Authorized: strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "),
+		})
+		mu.Unlock()
+
+		if i >= len(responses) {
+			i = len(responses) - 1
+		}
+		res := responses[i]
+		if res.contentType != "" {
+			w.Header().Set("Content-Type", res.contentType)
+		} else if res.body != "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(res.code)
+		io.WriteString(w, res.body)
+	}))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: &routeTransport{target: target}}, func() []connectorRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]connectorRequest(nil), requests...)
+	}
+}
+
+// runConnectorTests runs the tests in parallel by runConnectorTest.
+func runConnectorTests(t *testing.T, tests []connectorTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			runConnectorTest(t, tt)
+		})
+	}
+}
+
+// runConnectorTest executes the workflow calling the connectors against the fake API without waiting for the
+// backoffs of the retries and the polling.
+func runConnectorTest(t *testing.T, tt connectorTest) {
+	t.Helper()
+
+	client, requests := newFakeAPI(t, tt.responses)
+	ret, err := execute(t, tt.source, nil, append([]workflow.ExecuteOption{
+		workflow.WithHTTPClient(client),
+		workflow.WithClock(func() defaults.Clock { return defaults.NewFakeClock(time.Now()) }),
+	}, tt.opts...)...)
+	if err != nil {
+		var e *types.Error
+		if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+			t.Logf("expected error: %v", err)
+		} else {
+			t.Fatal(err)
+		}
+	} else if tt.expectedErrorTag != "" {
+		t.Fatalf("should be %s", tt.expectedErrorTag)
+	} else if diff := cmp.Diff(tt.expected, ret); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff(tt.expectedRequests, requests()); diff != "" {
+		t.Errorf("unexpected requests (-want +got):\n%s", diff)
+	}
+}
+
+func TestGoogleAPIs(t *testing.T) {
+	t.Parallel()
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "expand the path and the query parameters",
+			source: `
+main:
+  steps:
+    - list:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.list
+        args:
+          parent: projects/p/locations/l
+          filter: state = RUNNING
+          pageSize: 10
+        result: res
+    - done:
+        return: ${res}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"queues":[]}`}},
+			expected:  map[string]any{"queues": []any{}},
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues", Query: "filter=state+%3D+RUNNING&pageSize=10", Authorized: true},
+			},
+		},
+		{
+			name: "escape the simple path parameters",
+			source: `
+main:
+  steps:
+    - list:
+        call: googleapis.firestore.v1.projects.databases.documents.list
+        args:
+          parent: projects/p/databases/(default)/documents
+          collectionId: a/b
+        result: res
+    - done:
+        return: ${res}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{}`}},
+			expected:  map[string]any{},
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "firestore.googleapis.com", Path: "/v1/projects/p/databases/(default)/documents/a%2Fb", Authorized: true},
+			},
+		},
+		{
+			name: "send the body",
+			source: `
+main:
+  steps:
+    - publish:
+        call: googleapis.pubsub.v1.projects.topics.publish
+        args:
+          topic: projects/p/topics/t
+          body:
+            messages:
+              - data: aGVsbG8=
+        result: res
+    - done:
+        return: ${res.messageIds}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"messageIds":["1"]}`}},
+			expected:  []any{"1"},
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "pubsub.googleapis.com", Path: "/v1/projects/p/topics/t:publish", Body: `{"messages":[{"data":"aGVsbG8="}]}`, Authorized: true},
+			},
+		},
+		{
+			name: "return null for the empty response",
+			source: `
+main:
+  steps:
+    - delete:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.tasks.delete
+        args:
+          name: projects/p/locations/l/queues/q/tasks/t
+        result: res
+    - done:
+        return: ${res == null}
+`,
+			responses: []connectorResponse{{code: http.StatusNoContent}},
+			expected:  true,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodDelete, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q/tasks/t", Authorized: true},
+			},
+		},
+		{
+			name: "retry the idempotent method on 503",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.get
+        args:
+          name: projects/p/locations/l/queues/q
+        result: res
+    - done:
+        return: ${res.name}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusServiceUnavailable},
+				{code: http.StatusBadGateway},
+				{code: http.StatusOK, body: `{"name":"projects/p/locations/l/queues/q"}`},
+			},
+			expected: "projects/p/locations/l/queues/q",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+			},
+		},
+		{
+			name: "do not retry the non-idempotent method on 502",
+			source: `
+main:
+  steps:
+    - create:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.tasks.create
+        args:
+          parent: projects/p/locations/l/queues/q
+          body:
+            task: {}
+`,
+			responses:        []connectorResponse{{code: http.StatusBadGateway}},
+			expectedErrorTag: types.HttpErrorTag,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q/tasks", Body: `{"task":{}}`, Authorized: true},
+			},
+		},
+		{
+			name: "give up the retries",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.get
+        args:
+          name: projects/p/locations/l/queues/q
+`,
+			responses:        []connectorResponse{{code: http.StatusTooManyRequests}},
+			expectedErrorTag: types.HttpErrorTag,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+				{Method: http.MethodGet, Host: "cloudtasks.googleapis.com", Path: "/v2/projects/p/locations/l/queues/q", Authorized: true},
+			},
+		},
+		{
+			name: "reject the non-string path parameter",
+			source: `
+main:
+  steps:
+    - get:
+        call: googleapis.cloudtasks.v2.projects.locations.queues.get
+        args:
+          name: 1
+`,
+			responses:        []connectorResponse{{code: http.StatusOK}},
+			expectedErrorTag: types.TypeErrorTag,
+		},
+	})
+}
+
+func TestEmulatorHost(t *testing.T) {
+	const source = `
+main:
+  steps:
+    - get:
+        call: googleapis.storage.v1.buckets.get
+        args:
+          bucket: b
+        result: bucket
+    - done:
+        return: ${bucket.name}
+`
+	responses := []connectorResponse{{code: http.StatusOK, body: `{"name":"b"}`}}
+
+	for _, tt := range []struct {
+		name        string
+		env         string
+		service     string
+		host        string
+		expectedReq connectorRequest
+	}{
+		{
+			name:        "call the production without the emulator",
+			expectedReq: connectorRequest{Method: http.MethodGet, Host: "storage.googleapis.com", Path: "/storage/v1/b/b", Authorized: true},
+		},
+		{
+			name:        "call the emulator of the environment variable without the credentials",
+			env:         "localhost:9023",
+			expectedReq: connectorRequest{Method: http.MethodGet, Host: "localhost:9023", Path: "/storage/v1/b/b"},
+		},
+		{
+			name:        "call the emulator of the service name",
+			service:     "storage",
+			host:        "localhost:4443",
+			expectedReq: connectorRequest{Method: http.MethodGet, Host: "localhost:4443", Path: "/storage/v1/b/b"},
+		},
+		{
+			name:        "call the emulator of the root URL in precedence to the environment variable",
+			env:         "localhost:9023",
+			service:     "https://storage.googleapis.com/",
+			host:        "http://localhost:4443/",
+			expectedReq: connectorRequest{Method: http.MethodGet, Host: "localhost:4443", Path: "/storage/v1/b/b"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STORAGE_EMULATOR_HOST", tt.env)
+			if tt.service != "" {
+				if err := defaults.SetEmulatorHost(tt.service, tt.host); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() {
+					if err := defaults.SetEmulatorHost(tt.service, ""); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+
+			runConnectorTest(t, connectorTest{
+				source:           source,
+				responses:        responses,
+				expected:         "b",
+				expectedRequests: []connectorRequest{tt.expectedReq},
+			})
+		})
+	}
+
+	t.Run("reject the unknown connector", func(t *testing.T) {
+		err := defaults.SetEmulatorHost("unknown", "localhost:8080")
+		if err == nil {
+			t.Fatal("should be error")
+		}
+		t.Logf("expected error: %v", err)
+	})
+}
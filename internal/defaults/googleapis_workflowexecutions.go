@@ -0,0 +1,142 @@
+package defaults
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// workflowExecutionsV1 is the Workflow Executions connector.
+// In server mode, it's pointed at the emulator itself to execute the workflows served by the emulator.
+// refs. https://cloud.google.com/workflows/docs/reference/googleapis/workflowexecutions/Overview
+var workflowExecutionsV1 = &googleAPIService{
+	name:           "workflowexecutions",
+	version:        "v1",
+	rootURL:        "https://workflowexecutions.googleapis.com/",
+	operationStyle: workflowExecution,
+	methods: []googleAPIMethod{
+		{
+			name:       "projects.locations.workflows.executions.create",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+parent}/executions",
+			pathParams: []string{"parent"},
+			body:       true,
+		},
+		{
+			name:        "projects.locations.workflows.executions.get",
+			httpMethod:  http.MethodGet,
+			path:        "v1/{+name}",
+			pathParams:  []string{"name"},
+			queryParams: []string{"view"},
+		},
+		{
+			name:        "projects.locations.workflows.executions.list",
+			httpMethod:  http.MethodGet,
+			path:        "v1/{+parent}/executions",
+			pathParams:  []string{"parent"},
+			queryParams: []string{"filter", "orderBy", "pageSize", "pageToken", "view"},
+		},
+		{
+			name:       "projects.locations.workflows.executions.cancel",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+name}:cancel",
+			pathParams: []string{"name"},
+			body:       true,
+		},
+	},
+}
+
+// workflowExecution is the style of the executions which are polled until they finish.
+// The result of the execution is decoded from JSON.
+var workflowExecution = &operationStyle{
+	done: func(op map[string]any) bool {
+		switch op["state"] {
+		case "QUEUED", "ACTIVE":
+			return false
+		default:
+			return true
+		}
+	},
+	error: func(op map[string]any) any {
+		if op["state"] == "SUCCEEDED" {
+			return nil
+		}
+		if err, ok := op["error"]; ok {
+			return err
+		}
+		return op["state"]
+	},
+	result: func(op map[string]any) any {
+		result, ok := op["result"].(string)
+		if !ok {
+			return nil
+		}
+
+		var v any
+		if err := json.Unmarshal([]byte(result), &v); err != nil {
+			return result
+		}
+		return v
+	},
+	url: googleLongRunningOperation.url,
+}
+
+// workflowExecutionsRun creates an execution of the workflow and waits for its result.
+// The project and location default to the ones of the caller's execution.
+var workflowExecutionsRun = types.MustNewScopedFunction("googleapis.workflowexecutions.v1.projects.locations.workflows.executions.run", []types.Argument{
+	{Name: "workflow_id"},
+	{Name: "argument", Optional: true},
+	{Name: "location", Optional: true},
+	{Name: "project_id", Optional: true},
+	{Name: "connector_params", Optional: true},
+}, func(st *types.SymbolTable) any {
+	return func(ctx context.Context, workflowID string, argument any, location, projectID string, rawParams map[string]any) (any, error) {
+		if st != nil {
+			if env, ok := st.Get(types.InternalEnvironmentSymbol); ok {
+				if location == "" {
+					location = env.(map[string]string)["GOOGLE_CLOUD_LOCATION"]
+				}
+				if projectID == "" {
+					projectID = env.(map[string]string)["GOOGLE_CLOUD_PROJECT_ID"]
+				}
+			}
+		}
+		if location == "" || projectID == "" {
+			return nil, &types.Error{
+				Tag: types.ValueErrorTag,
+				Err: fmt.Errorf("location and project_id are required"),
+			}
+		}
+
+		name := "googleapis.workflowexecutions.v1.projects.locations.workflows.executions.run"
+		params, err := decodeConnectorParams(name, rawParams)
+		if err != nil {
+			return nil, err
+		}
+
+		body := map[string]any{}
+		if argument != nil {
+			b, err := json.Marshal(argument)
+			if err != nil {
+				return nil, &types.Error{
+					Tag: types.TypeErrorTag,
+					Err: fmt.Errorf("%s: invalid argument: %w", name, err),
+				}
+			}
+			body["argument"] = string(b)
+		}
+
+		return workflowExecutionsV1.call(ctx, googleAPIMethod{
+			name:       "projects.locations.workflows.executions.run",
+			httpMethod: http.MethodPost,
+			path:       "v1/{+parent}/executions",
+			body:       true,
+			operation:  true,
+		}, map[string]string{
+			"parent": fmt.Sprintf("projects/%s/locations/%s/workflows/%s", projectID, location, workflowID),
+		}, map[string]any{}, body, params)
+	}
+})
@@ -0,0 +1,108 @@
+package defaults_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestWorkflowExecutionsConnector(t *testing.T) {
+	t.Parallel()
+
+	runConnectorTests(t, []connectorTest{
+		{
+			name: "create the execution",
+			source: `
+main:
+  steps:
+    - create:
+        call: googleapis.workflowexecutions.v1.projects.locations.workflows.executions.create
+        args:
+          parent: projects/p/locations/l/workflows/w
+          body:
+            argument: '{"a":1}'
+        result: execution
+    - done:
+        return: ${execution.state}
+`,
+			responses: []connectorResponse{{code: http.StatusOK, body: `{"name":"projects/p/locations/l/workflows/w/executions/e","state":"ACTIVE"}`}},
+			expected:  "ACTIVE",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "workflowexecutions.googleapis.com", Path: "/v1/projects/p/locations/l/workflows/w/executions", Body: `{"argument":"{\"a\":1}"}`, Authorized: true},
+			},
+		},
+		{
+			name: "run the workflow and decode the result",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.workflowexecutions.v1.projects.locations.workflows.executions.run
+        args:
+          workflow_id: w
+          argument:
+            a: 1
+          project_id: p
+          location: l
+        result: result
+    - done:
+        return: ${result.sum}
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"name":"projects/p/locations/l/workflows/w/executions/e","state":"ACTIVE"}`},
+				{code: http.StatusOK, body: `{"name":"projects/p/locations/l/workflows/w/executions/e","state":"SUCCEEDED","result":"{\"sum\":3}"}`},
+			},
+			expected: float64(3),
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "workflowexecutions.googleapis.com", Path: "/v1/projects/p/locations/l/workflows/w/executions", Body: `{"argument":"{\"a\":1}"}`, Authorized: true},
+				{Method: http.MethodGet, Host: "workflowexecutions.googleapis.com", Path: "/v1/projects/p/locations/l/workflows/w/executions/e", Authorized: true},
+			},
+		},
+		{
+			name: "run the workflow of the project and the location of the caller",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.workflowexecutions.v1.projects.locations.workflows.executions.run
+        args:
+          workflow_id: w
+        result: result
+    - done:
+        return: ${result}
+`,
+			opts: []workflow.ExecuteOption{
+				workflow.WithExecutionInfo(workflow.ExecutionInfo{ProjectID: "caller-project", Location: "asia-northeast1"}),
+			},
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"name":"projects/caller-project/locations/asia-northeast1/workflows/w/executions/e","state":"SUCCEEDED","result":"\"ok\""}`},
+			},
+			expected: "ok",
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "workflowexecutions.googleapis.com", Path: "/v1/projects/caller-project/locations/asia-northeast1/workflows/w/executions", Body: `{}`, Authorized: true},
+			},
+		},
+		{
+			name: "raise OperationError for the failed execution",
+			source: `
+main:
+  steps:
+    - run:
+        call: googleapis.workflowexecutions.v1.projects.locations.workflows.executions.run
+        args:
+          workflow_id: w
+          project_id: p
+          location: l
+`,
+			responses: []connectorResponse{
+				{code: http.StatusOK, body: `{"name":"projects/p/locations/l/workflows/w/executions/e","state":"FAILED","error":{"payload":"boom"}}`},
+			},
+			expectedErrorTag: types.OperationErrorTag,
+			expectedRequests: []connectorRequest{
+				{Method: http.MethodPost, Host: "workflowexecutions.googleapis.com", Path: "/v1/projects/p/locations/l/workflows/w/executions", Body: `{}`, Authorized: true},
+			},
+		},
+	})
+}
@@ -3,25 +3,30 @@ package defaults
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"mime"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/samber/lo"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/idtoken"
@@ -36,10 +41,27 @@ const (
 	jsonBody
 	stringBody
 	queryFormBody
+	multipartFormBody
+)
+
+const (
+	// defaultHTTPTimeout is the default timeout of http.* in seconds
+	defaultHTTPTimeout = 300
+
+	// defaultMaxRedirects is the same as the redirect policy of http.DefaultClient
+	defaultMaxRedirects = 10
+
+	// ProductionHTTPMaxResponseSize is the limit of the HTTP response size of the production Workflows
+	// refs. https://cloud.google.com/workflows/quotas#resource_limit
+	ProductionHTTPMaxResponseSize = 2 * 1024 * 1024
 )
 
 var sharedHTTPClient = httpClient{
 	defaultBodyKind:        jsonBody,
+	defaultTimeout:         defaultHTTPTimeout,
+	maxRedirects:           defaultMaxRedirects,
+	maxResponseSize:        ProductionHTTPMaxResponseSize,
+	transport:              http.DefaultTransport.(*http.Transport).Clone(), // respects HTTP_PROXY, HTTPS_PROXY and NO_PROXY
 	oidcTokenSourceCache:   map[string]oauth2.TokenSource{},
 	oauth2TokenSourceCache: map[string]oauth2.TokenSource{},
 }
@@ -49,62 +71,62 @@ var HTTP = mergeMaps(
 		types.MustNewFunction("http.request", []types.Argument{
 			{Name: "method"},
 			{Name: "url"},
-			{Name: "timeout", Default: float64(300)},
+			{Name: "timeout", Optional: true},
 			{Name: "body", Optional: true},
 			{Name: "headers", Optional: true},
 			{Name: "query", Optional: true},
 			{Name: "auth", Optional: true},
-		}, func(method, rawURL string, timeout float64, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
-			return sharedHTTPClient.request(method, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
+		}, func(ctx context.Context, method, rawURL string, timeout, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+			return sharedHTTPClient.requestWithRawTimeout(ctx, method, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
 		}),
 		types.MustNewFunction("http.get", []types.Argument{
 			{Name: "url"},
-			{Name: "timeout", Default: float64(300)},
+			{Name: "timeout", Optional: true},
 			{Name: "headers", Optional: true},
 			{Name: "query", Optional: true},
 			{Name: "auth", Optional: true},
-		}, func(rawURL string, timeout float64, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
-			return sharedHTTPClient.request(http.MethodGet, rawURL, timeout, nil, rawHeaders, rawQuery, auth)
+		}, func(ctx context.Context, rawURL string, timeout any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+			return sharedHTTPClient.requestWithRawTimeout(ctx, http.MethodGet, rawURL, timeout, nil, rawHeaders, rawQuery, auth)
 		}),
 		types.MustNewFunction("http.post", []types.Argument{
 			{Name: "url"},
-			{Name: "timeout", Default: float64(300)},
+			{Name: "timeout", Optional: true},
 			{Name: "body", Optional: true},
 			{Name: "headers", Optional: true},
 			{Name: "query", Optional: true},
 			{Name: "auth", Optional: true},
-		}, func(rawURL string, timeout float64, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
-			return sharedHTTPClient.request(http.MethodPost, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
+		}, func(ctx context.Context, rawURL string, timeout, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+			return sharedHTTPClient.requestWithRawTimeout(ctx, http.MethodPost, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
 		}),
 		types.MustNewFunction("http.put", []types.Argument{
 			{Name: "url"},
-			{Name: "timeout", Default: float64(300)},
+			{Name: "timeout", Optional: true},
 			{Name: "body", Optional: true},
 			{Name: "headers", Optional: true},
 			{Name: "query", Optional: true},
 			{Name: "auth", Optional: true},
-		}, func(rawURL string, timeout float64, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
-			return sharedHTTPClient.request(http.MethodPut, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
+		}, func(ctx context.Context, rawURL string, timeout, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+			return sharedHTTPClient.requestWithRawTimeout(ctx, http.MethodPut, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
 		}),
 		types.MustNewFunction("http.patch", []types.Argument{
 			{Name: "url"},
-			{Name: "timeout", Default: float64(300)},
+			{Name: "timeout", Optional: true},
 			{Name: "body", Optional: true},
 			{Name: "headers", Optional: true},
 			{Name: "query", Optional: true},
 			{Name: "auth", Optional: true},
-		}, func(rawURL string, timeout float64, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
-			return sharedHTTPClient.request(http.MethodPatch, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
+		}, func(ctx context.Context, rawURL string, timeout, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+			return sharedHTTPClient.requestWithRawTimeout(ctx, http.MethodPatch, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
 		}),
 		types.MustNewFunction("http.delete", []types.Argument{
 			{Name: "url"},
-			{Name: "timeout", Default: float64(300)},
+			{Name: "timeout", Optional: true},
 			{Name: "body", Optional: true},
 			{Name: "headers", Optional: true},
 			{Name: "query", Optional: true},
 			{Name: "auth", Optional: true},
-		}, func(rawURL string, timeout float64, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
-			return sharedHTTPClient.request(http.MethodDelete, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
+		}, func(ctx context.Context, rawURL string, timeout, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+			return sharedHTTPClient.requestWithRawTimeout(ctx, http.MethodDelete, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
 		}),
 		types.MustNewFunction("http.default_retry_predicate", []types.Argument{
 			{Name: "exception"},
@@ -164,28 +186,191 @@ var HTTP = mergeMaps(
 type httpClient struct {
 	sync.RWMutex
 	defaultBodyKind        bodyKind
+	defaultTimeout         float64
+	multiValueHeaders      bool
+	maxRedirects           int
+	maxResponseSize        int64
+	transport              *http.Transport
+	roundTripper           http.RoundTripper // wraps the transport if it's not nil
+	fakeAuthClaims         map[string]any    // enables the fake authentication if it's not nil
+	credentialsFile        string            // overrides the application default credentials if it's not empty
+	credentialsJSON        []byte
 	oidcTokenSourceCache   map[string]oauth2.TokenSource
 	oauth2TokenSourceCache map[string]oauth2.TokenSource
 }
 
-func (c *httpClient) request(method, rawURL string, timeout float64, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+// SetHTTPCredentialsFile makes the OIDC and OAuth2 authentications of http.* and the connectors use the credentials file
+// (e.g. the service account key) instead of the application default credentials. It must be called before executing any workflows.
+func SetHTTPCredentialsFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	sharedHTTPClient.Lock()
+	defer sharedHTTPClient.Unlock()
+	sharedHTTPClient.credentialsFile = path
+	sharedHTTPClient.credentialsJSON = b
+	return nil
+}
+
+// SetHTTPMultiValueHeaders makes http.* return all values of the response headers which have multiple values
+// (e.g. Set-Cookie) as a list instead of the first one. It must be called before executing any workflows.
+func SetHTTPMultiValueHeaders(enabled bool) {
+	sharedHTTPClient.multiValueHeaders = enabled
+}
+
+// SetHTTPMaxRedirects limits the number of the redirects followed by http.* (0 disables following the redirects).
+// The redirect response of the limit is returned as is instead of raising HttpError, e.g. to read its Location header.
+// It must be called before executing any workflows.
+func SetHTTPMaxRedirects(n int) {
+	sharedHTTPClient.maxRedirects = n
+}
+
+// SetHTTPMaxResponseSize sets the limit in bytes of the HTTP response size (0 means no limit).
+// ResourceLimitError is raised when the response exceeds it. It must be called before executing any workflows.
+func SetHTTPMaxResponseSize(n int64) {
+	sharedHTTPClient.maxResponseSize = n
+}
+
+// SetHTTPTLSConfig configures the verification of the server certificates for http.* to call the local services with the self-signed certificates.
+// The certificates in the PEM file of caFile are trusted in addition to the system ones. It must be called before executing any workflows.
+func SetHTTPTLSConfig(caFile string, insecureSkipVerify bool) error {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates are found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	sharedHTTPClient.transport.TLSClientConfig = tlsConfig
+	return nil
+}
+
+// SetHTTPDefaultTimeout sets the timeout in seconds of http.* used when the timeout argument is omitted.
+// It must be called before executing any workflows.
+func SetHTTPDefaultTimeout(seconds float64) {
+	sharedHTTPClient.defaultTimeout = seconds
+}
+
+// SetHTTPProxy routes the outbound HTTP requests via the proxy instead of the one of HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+// The empty URL restores the proxy of the environment variables. It must be called before executing any workflows.
+func SetHTTPProxy(proxyURL string) error {
+	if proxyURL == "" {
+		sharedHTTPClient.transport.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("url.Parse: %w", err)
+	}
+	sharedHTTPClient.transport.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+// SetHTTPMaxIdleConns sets the size of the connection pool of the outbound HTTP requests (0 means no limit).
+// It must be called before executing any workflows.
+func SetHTTPMaxIdleConns(n int) {
+	sharedHTTPClient.transport.MaxIdleConns = n
+	sharedHTTPClient.transport.MaxIdleConnsPerHost = n
+}
+
+// WrapHTTPTransport is the hook for the embedding programs to intercept the outbound HTTP requests of http.* and the connectors,
+// e.g. to record them or to return the canned responses. It must be called before executing any workflows.
+func WrapHTTPTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	sharedHTTPClient.roundTripper = wrap(sharedHTTPClient.httpTransport())
+}
+
+type httpClientKey struct{}
+
+// WithHTTPClient returns the context of the executions sending the outbound HTTP requests of http.* and the connectors
+// by the client instead of the transport of the process (including the wrappers of WrapHTTPTransport), e.g. to send
+// them to httptest.Server or to authenticate them by the custom transport. The redirects are limited as
+// SetHTTPMaxRedirects unless the client has its own CheckRedirect.
+func WithHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, httpClientKey{}, client)
+}
+
+// HTTPClientFromContext returns the client of the context given by WithHTTPClient, or nil.
+func HTTPClientFromContext(ctx context.Context) *http.Client {
+	client, _ := ctx.Value(httpClientKey{}).(*http.Client)
+	return client
+}
+
+// httpClientOf returns the client of the context, or the client of the transport of the process.
+func (c *httpClient) httpClientOf(ctx context.Context) *http.Client {
+	if injected := HTTPClientFromContext(ctx); injected != nil {
+		client := *injected
+		if client.CheckRedirect == nil {
+			client.CheckRedirect = c.checkRedirect
+		}
+		return &client
+	}
+	return &http.Client{Transport: c.httpTransport(), CheckRedirect: c.checkRedirect}
+}
+
+func (c *httpClient) httpTransport() http.RoundTripper {
+	if c.roundTripper != nil {
+		return c.roundTripper
+	}
+	return c.transport
+}
+
+func (c *httpClient) checkRedirect(_ *http.Request, via []*http.Request) error {
+	if len(via) > c.maxRedirects {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// requestWithRawTimeout sends the request with the timeout argument of http.*, which accepts both of int and double.
+func (c *httpClient) requestWithRawTimeout(ctx context.Context, method, rawURL string, rawTimeout, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
+	var timeout float64
+	switch v := rawTimeout.(type) {
+	case nil:
+		timeout = c.defaultTimeout
+	case int64:
+		timeout = float64(v)
+	case float64:
+		timeout = v
+	default:
+		return nil, &types.Error{
+			Tag: types.TypeErrorTag,
+			Err: fmt.Errorf("timeout must be a number but got %T", rawTimeout),
+		}
+	}
+
+	return c.request(ctx, method, rawURL, timeout, rawBody, rawHeaders, rawQuery, auth)
+}
+
+func (c *httpClient) request(ctx context.Context, method, rawURL string, timeout float64, rawBody any, rawHeaders, rawQuery, auth map[string]any) (map[string]any, error) {
 	var bodyFormat bodyKind
 	var reqBody io.Reader
+	var contentType string
 	switch method {
-	case http.MethodDelete:
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
 		if rawBody == nil {
 			break
 		}
-		fallthrough
 
-	case http.MethodPost, http.MethodPut, http.MethodPatch:
 		var err error
 		bodyFormat, err = c.detectBodyFormat(rawHeaders)
 		if err != nil {
 			return nil, err
 		}
 
-		reqBody, err = c.createBodyReader(bodyFormat, rawBody)
+		reqBody, contentType, err = c.createBodyReader(bodyFormat, rawBody)
 		if err != nil {
 			return nil, err
 		}
@@ -199,8 +384,7 @@ func (c *httpClient) request(method, rawURL string, timeout float64, rawBody any
 		return nil, err
 	}
 
-	log.Println(method, u.String())
-	req, err := http.NewRequest(method, u.String(), reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
 	if err != nil {
 		return nil, &types.Error{
 			Tag: types.SystemErrorTag,
@@ -212,6 +396,9 @@ func (c *httpClient) request(method, rawURL string, timeout float64, rawBody any
 	if err != nil {
 		return nil, err
 	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType) // e.g. multipart/form-data with the boundary
+	}
 	err = c.setAuthHeaders(u, req, auth)
 	if err != nil {
 		return nil, err
@@ -229,34 +416,12 @@ func (c *httpClient) request(method, rawURL string, timeout float64, rawBody any
 		req = req.WithContext(ctx)
 	}
 
-	var netErr net.Error
-	res, err := http.DefaultClient.Do(req)
-	if errors.Is(err, context.DeadlineExceeded) {
-		return nil, &types.Error{
-			Tag: types.TimeoutErrorTag,
-			Err: err,
-		}
-	} else if errors.As(err, &netErr) {
-		if netErr.Timeout() {
-			return nil, &types.Error{
-				Tag: types.TimeoutErrorTag,
-				Err: err,
-			}
-		}
-		return nil, &types.Error{
-			Tag: types.ConnectionErrorTag,
-			Err: err,
-		}
-	} else if errors.Is(err, net.ErrClosed) {
-		return nil, &types.Error{
-			Tag: types.ConnectionErrorTag,
-			Err: err,
-		}
-	} else if err != nil {
-		return nil, &types.Error{
-			Tag: types.SystemErrorTag,
-			Err: fmt.Errorf("http.DefaultClient.Do: %w", err),
+	res, err := c.httpClientOf(ctx).Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err() // the execution is cancelled, which must not be caught by the workflow
 		}
+		return nil, newTransportError(err)
 	}
 	defer res.Body.Close()
 
@@ -270,13 +435,23 @@ func (c *httpClient) request(method, rawURL string, timeout float64, rawBody any
 
 	var resBody any
 	{
-		b, err := io.ReadAll(res.Body)
+		var body io.Reader = res.Body
+		if c.maxResponseSize > 0 {
+			body = io.LimitReader(res.Body, c.maxResponseSize+1)
+		}
+		b, err := io.ReadAll(body)
 		if err != nil {
 			return nil, &types.Error{
 				Tag: types.ConnectionErrorTag,
 				Err: fmt.Errorf("io.ReadAll: %w", err),
 			}
 		}
+		if c.maxResponseSize > 0 && int64(len(b)) > c.maxResponseSize {
+			return nil, &types.Error{
+				Tag: types.ResourceLimitErrorTag,
+				Err: fmt.Errorf("HTTP response size exceeds the limit of %d bytes", c.maxResponseSize),
+			}
+		}
 		if isJSON {
 			err := json.Unmarshal(b, &resBody)
 			if err != nil {
@@ -284,30 +459,79 @@ func (c *httpClient) request(method, rawURL string, timeout float64, rawBody any
 			}
 		}
 		if resBody == nil {
-			resBody = b
+			resBody = types.Bytes(b)
 		}
 	}
 
 	resHeaders := map[string]any{}
-	for name := range res.Header {
-		resHeaders[name] = res.Header.Get(name)
+	for name, values := range res.Header {
+		if c.multiValueHeaders && len(values) > 1 {
+			resHeaders[name] = lo.ToAnySlice(values)
+		} else {
+			resHeaders[name] = res.Header.Get(name)
+		}
 	}
 
 	resMap := map[string]any{
-		"code":    res.StatusCode,
+		"code":    int64(res.StatusCode),
 		"headers": resHeaders,
 		"body":    resBody,
+		"url":     res.Request.URL.String(), // the final URL after following the redirects
 	}
-	if res.StatusCode >= 400 {
+	if (res.StatusCode < 200 || res.StatusCode >= 300) && !isUnfollowedRedirect(res) {
+		// refs. https://cloud.google.com/workflows/docs/reference/syntax/catching-errors#map-fields
+		message := fmt.Sprintf("HTTP server responded with error code %d", res.StatusCode)
 		return nil, &types.Error{
 			Tag:   types.HttpErrorTag,
-			Err:   fmt.Errorf("status code %d is returned", res.StatusCode),
-			Extra: resMap,
+			Err:   errors.New(message),
+			Extra: lo.Assign(resMap, map[string]any{"message": message}),
 		}
 	}
 	return resMap, nil
 }
 
+// isUnfollowedRedirect reports whether the response is the redirect not followed by the limit of the redirects.
+func isUnfollowedRedirect(res *http.Response) bool {
+	return res.StatusCode >= 300 && res.StatusCode < 400 && res.Header.Get("Location") != ""
+}
+
+// newTransportError maps the error of the HTTP transport to the exception.
+// refs. https://cloud.google.com/workflows/docs/reference/syntax/catching-errors#error-tags
+func newTransportError(err error) *types.Error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return &types.Error{
+			Tag: types.TimeoutErrorTag,
+			Err: err,
+		}
+	}
+
+	// the connection couldn't be established
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial" || errors.As(err, &certErr) || errors.As(err, &recordErr) {
+		return &types.Error{
+			Tag: types.ConnectionFailedErrorTag,
+			Err: err,
+		}
+	}
+
+	// the connection was broken midway
+	if errors.As(err, &opErr) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return &types.Error{
+			Tag: types.ConnectionErrorTag,
+			Err: err,
+		}
+	}
+
+	return &types.Error{
+		Tag: types.SystemErrorTag,
+		Err: fmt.Errorf("http.Client.Do: %w", err),
+	}
+}
+
 func (c *httpClient) detectBodyFormat(rawHeaders map[string]any) (bodyKind, error) {
 	for name := range rawHeaders {
 		if !strings.EqualFold(name, "Content-Type") {
@@ -330,10 +554,12 @@ func (c *httpClient) detectBodyFormat(rawHeaders map[string]any) (bodyKind, erro
 			}
 		}
 
-		if strings.HasSuffix(mediaType, "text/") {
+		if strings.HasPrefix(mediaType, "text/") {
 			return stringBody, nil
 		} else if mediaType == "application/x-www-form-urlencoded" {
 			return queryFormBody, nil
+		} else if mediaType == "multipart/form-data" {
+			return multipartFormBody, nil
 		} else if mediaType == "application/json" {
 			return jsonBody, nil
 		} else if strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json") {
@@ -349,22 +575,23 @@ func (c *httpClient) detectBodyFormat(rawHeaders map[string]any) (bodyKind, erro
 	return c.defaultBodyKind, nil
 }
 
-func (c *httpClient) createBodyReader(bodyFormat bodyKind, rawBody any) (io.Reader, error) {
+// createBodyReader returns the reader of the request body and the Content-Type to override the one of the headers if needed.
+func (c *httpClient) createBodyReader(bodyFormat bodyKind, rawBody any) (io.Reader, string, error) {
 	switch body := rawBody.(type) {
 	case string:
 		switch bodyFormat {
 		case queryFormBody:
 			if _, err := url.ParseQuery(body); err != nil {
-				return nil, &types.Error{
+				return nil, "", &types.Error{
 					Tag: types.ValueErrorTag,
 					Err: fmt.Errorf("url.ParseQuery: %w", err),
 				}
 			}
 			fallthrough
 		case stringBody:
-			return strings.NewReader(body), nil
+			return strings.NewReader(body), "", nil
 		default:
-			return nil, &types.Error{
+			return nil, "", &types.Error{
 				Tag: types.TypeErrorTag,
 				Err: fmt.Errorf("invalid body type with content-type: %T", rawBody),
 			}
@@ -375,28 +602,98 @@ func (c *httpClient) createBodyReader(bodyFormat bodyKind, rawBody any) (io.Read
 		case jsonBody:
 			b, err := json.Marshal(body)
 			if err != nil {
-				return nil, &types.Error{
+				return nil, "", &types.Error{
 					Tag: types.ValueErrorTag,
 					Err: fmt.Errorf("json.Marshal: %w", err),
 				}
 			}
 
-			return bytes.NewReader(b), nil
+			return bytes.NewReader(b), "", nil
+		case multipartFormBody:
+			return c.createMultipartBody(body)
 		default:
-			return nil, &types.Error{
+			return nil, "", &types.Error{
 				Tag: types.TypeErrorTag,
 				Err: fmt.Errorf("invalid body type with content-type: %T", rawBody),
 			}
 		}
 
 	default:
-		return nil, &types.Error{
+		return nil, "", &types.Error{
 			Tag: types.TypeErrorTag,
 			Err: fmt.Errorf("invalid body type with content-type: %T", rawBody),
 		}
 	}
 }
 
+// createMultipartBody encodes the map as multipart/form-data.
+// The bytes values are sent as the file parts named by the keys, and the lists are sent as the repeated parts.
+func (c *httpClient) createMultipartBody(body map[string]any) (io.Reader, string, error) {
+	names := lo.Keys(body)
+	sort.Strings(names) // for the stable order of the parts
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, name := range names {
+		values, ok := body[name].([]any)
+		if !ok {
+			values = []any{body[name]}
+		}
+
+		for _, value := range values {
+			if err := c.writeMultipartPart(w, name, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", &types.Error{
+			Tag: types.SystemErrorTag,
+			Err: fmt.Errorf("multipart.Writer.Close: %w", err),
+		}
+	}
+
+	return &buf, w.FormDataContentType(), nil
+}
+
+func (c *httpClient) writeMultipartPart(w *multipart.Writer, name string, value any) error {
+	var part io.Writer
+	var err error
+	var content []byte
+	switch v := value.(type) {
+	case types.Bytes:
+		part, err = w.CreateFormFile(name, name)
+		content = v
+	case string:
+		part, err = w.CreateFormField(name)
+		content = []byte(v)
+	case int64:
+		part, err = w.CreateFormField(name)
+		content = []byte(strconv.FormatInt(v, 10))
+	case float64:
+		part, err = w.CreateFormField(name)
+		content = []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		part, err = w.CreateFormField(name)
+		content = []byte(strconv.FormatBool(v))
+	default:
+		return &types.Error{
+			Tag: types.TypeErrorTag,
+			Err: fmt.Errorf("unsupported type for multipart/form-data value for name=%s: %T", name, v),
+		}
+	}
+	if err == nil {
+		_, err = part.Write(content)
+	}
+	if err != nil {
+		return &types.Error{
+			Tag: types.SystemErrorTag,
+			Err: fmt.Errorf("multipart.Writer: %w", err),
+		}
+	}
+	return nil
+}
+
 func (c *httpClient) createURL(rawURL string, rawQuery map[string]any) (*url.URL, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
@@ -409,17 +706,26 @@ func (c *httpClient) createURL(rawURL string, rawQuery map[string]any) (*url.URL
 	if rawQuery != nil {
 		query := u.Query()
 		for name, value := range rawQuery {
-			switch v := value.(type) {
-			case string:
-				query.Set(name, v)
-			case int64:
-				query.Set(name, strconv.FormatInt(v, 10))
-			case float64:
-				query.Set(name, strconv.FormatFloat(v, 'f', -1, 64))
-			default:
-				return nil, &types.Error{
-					Tag: types.TypeErrorTag,
-					Err: fmt.Errorf("unsupported type for query value for name=%s: %T", name, v),
+			values, ok := value.([]any)
+			if !ok {
+				values = []any{value}
+			}
+
+			// the list values are encoded as the repeated parameters like ?a=1&a=2
+			query.Del(name)
+			for _, value := range values {
+				switch v := value.(type) {
+				case string:
+					query.Add(name, v)
+				case int64:
+					query.Add(name, strconv.FormatInt(v, 10))
+				case float64:
+					query.Add(name, strconv.FormatFloat(v, 'f', -1, 64))
+				default:
+					return nil, &types.Error{
+						Tag: types.TypeErrorTag,
+						Err: fmt.Errorf("unsupported type for query value for name=%s: %T", name, v),
+					}
 				}
 			}
 		}
@@ -494,14 +800,33 @@ func (c *httpClient) setOIDCAuthHeaders(u *url.URL, req *http.Request, auth map[
 		audience = u.String()
 	}
 
-	ts, ok := c.oidcTokenSourceCache[audience]
+	// the tokens are cached per the credentials not to be shared between the identities
+	key := c.credentialsFile + "::" + audience
+	ts, ok := c.oidcTokenSourceCache[key]
+	if !ok && c.fakeAuthClaims != nil {
+		ts = newFakeOIDCTokenSource(c.fakeAuthClaims, audience)
+		c.oidcTokenSourceCache[key] = ts
+		ok = true
+	}
+	if !ok && c.credentialsJSON != nil {
+		var err error
+		ts, err = idtoken.NewTokenSource(context.Background(), audience, option.WithCredentialsJSON(c.credentialsJSON))
+		if err != nil {
+			return &types.Error{
+				Tag: types.AuthErrorTag,
+				Err: fmt.Errorf("idtoken.NewTokenSource: %w", err),
+			}
+		}
+		c.oidcTokenSourceCache[key] = ts
+		ok = true
+	}
 	if !ok {
 		// XXX: dirty hack for authorized_user default application credential
 		creds, err := google.FindDefaultCredentials(context.Background())
 		if err == nil {
 			if isAuthorizedUser(creds.JSON) == nil {
 				ts = &gcloudAuthPrintIdentityTokenSource{}
-				c.oidcTokenSourceCache[audience] = ts
+				c.oidcTokenSourceCache[key] = ts
 				ok = true
 			}
 		}
@@ -514,7 +839,7 @@ func (c *httpClient) setOIDCAuthHeaders(u *url.URL, req *http.Request, auth map[
 					Err: fmt.Errorf("idtoken.NewTokenSource: %w", err),
 				}
 			}
-			c.oidcTokenSourceCache[audience] = ts
+			c.oidcTokenSourceCache[key] = ts
 		}
 	}
 
@@ -592,10 +917,19 @@ func (c *httpClient) setOAuth2Headers(req *http.Request, auth map[string]any) er
 	}
 
 	sort.Strings(scopes)
-	key := strings.Join(scopes, "::")
+	key := c.credentialsFile + "::" + strings.Join(scopes, "::")
 	ts, ok := c.oauth2TokenSourceCache[key]
+	if !ok && c.fakeAuthClaims != nil {
+		ts = newFakeOAuth2TokenSource(c.fakeAuthClaims, scopes)
+		c.oauth2TokenSourceCache[key] = ts
+		ok = true
+	}
 	if !ok {
-		creds, err := transport.Creds(context.Background(), option.WithScopes(scopes...))
+		opts := []option.ClientOption{option.WithScopes(scopes...)}
+		if c.credentialsJSON != nil {
+			opts = append(opts, option.WithCredentialsJSON(c.credentialsJSON))
+		}
+		creds, err := transport.Creds(context.Background(), opts...)
 		if err != nil {
 			return &types.Error{
 				Tag: types.AuthErrorTag,
@@ -604,6 +938,7 @@ func (c *httpClient) setOAuth2Headers(req *http.Request, auth map[string]any) er
 		}
 
 		ts = creds.TokenSource
+		c.oauth2TokenSourceCache[key] = ts
 	}
 
 	token, err := ts.Token()
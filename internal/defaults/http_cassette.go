@@ -0,0 +1,247 @@
+package defaults
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+// HTTPCassette is the recorded interactions of the outbound HTTP requests to replay them deterministically and offline.
+// The requests are matched by the method, the URL and the body. The interactions are replayed in the recorded order,
+// and the last matched one is replayed again when all of the matched ones are used.
+type HTTPCassette struct {
+	mu           sync.Mutex
+	path         string
+	Interactions []*httpInteraction `json:"interactions"`
+	used         []bool
+}
+
+type httpInteraction struct {
+	Request  httpInteractionRequest  `json:"request"`
+	Response httpInteractionResponse `json:"response"`
+}
+
+type httpInteractionRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   *httpRecordBody `json:"body,omitempty"`
+}
+
+type httpInteractionResponse struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"headers"`
+	Body   *httpRecordBody `json:"body,omitempty"`
+}
+
+// httpRecordBody is the recorded body, which is encoded by base64 if it's not a valid UTF-8 string.
+type httpRecordBody struct {
+	String string `json:"string,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+}
+
+func newHTTPRecordBody(b []byte) *httpRecordBody {
+	if len(b) == 0 {
+		return nil
+	}
+	if utf8.Valid(b) {
+		return &httpRecordBody{String: string(b)}
+	}
+	return &httpRecordBody{Base64: base64.StdEncoding.EncodeToString(b)}
+}
+
+func (b *httpRecordBody) bytes() ([]byte, error) {
+	if b == nil {
+		return nil, nil
+	}
+	if b.Base64 != "" {
+		return base64.StdEncoding.DecodeString(b.Base64)
+	}
+	return []byte(b.String), nil
+}
+
+func (b *httpRecordBody) equal(other *httpRecordBody) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+	return *b == *other
+}
+
+// NewHTTPCassette returns the empty cassette to record the interactions into the file.
+func NewHTTPCassette(path string) *HTTPCassette {
+	return &HTTPCassette{path: path}
+}
+
+// LoadHTTPCassette loads the cassette recorded before to replay it.
+func LoadHTTPCassette(path string) (*HTTPCassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	c := &HTTPCassette{path: path}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	c.used = make([]bool, len(c.Interactions))
+	return c, nil
+}
+
+// NewHTTPCassetteFromInteractions returns the cassette to replay the interactions observed by ObserveHTTPInteractions,
+// e.g. in the journal of an execution. The name is shown in the errors of the requests matching none of them.
+func NewHTTPCassetteFromInteractions(name string, interactions []json.RawMessage) (*HTTPCassette, error) {
+	c := &HTTPCassette{path: name, Interactions: make([]*httpInteraction, len(interactions))}
+	for i, raw := range interactions {
+		c.Interactions[i] = &httpInteraction{}
+		if err := json.Unmarshal(raw, c.Interactions[i]); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+	c.used = make([]bool, len(c.Interactions))
+	return c, nil
+}
+
+// Recorder returns the transport which records the interactions of the transport into the cassette.
+// The cassette file is written for each interaction not to lose them when the emulator is stopped.
+func (c *HTTPCassette) Recorder(next http.RoundTripper) http.RoundTripper {
+	return &httpRecorder{
+		record: func(_ context.Context, interaction *httpInteraction) error {
+			return c.record(interaction)
+		},
+		next: next,
+	}
+}
+
+// ObserveHTTPInteractions returns the transport which passes the interactions of the transport to the observer with
+// the contexts of the requests, e.g. to journal them. The interactions are marshaled into JSON in the same format as
+// the interactions of the cassette.
+func ObserveHTTPInteractions(next http.RoundTripper, observer func(ctx context.Context, interaction any)) http.RoundTripper {
+	return &httpRecorder{
+		record: func(ctx context.Context, interaction *httpInteraction) error {
+			observer(ctx, interaction)
+			return nil
+		},
+		next: next,
+	}
+}
+
+type httpRecorder struct {
+	record func(context.Context, *httpInteraction) error
+	next   http.RoundTripper
+}
+
+func (r *httpRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readAndRestoreBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := readAndRestoreBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.record(req.Context(), &httpInteraction{
+		Request: httpInteractionRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   newHTTPRecordBody(reqBody),
+		},
+		Response: httpInteractionResponse{
+			Status: res.StatusCode,
+			Header: res.Header,
+			Body:   newHTTPRecordBody(resBody),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func readAndRestoreBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil {
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	_ = (*body).Close()
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func (c *HTTPCassette) record(interaction *httpInteraction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, interaction)
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	if err = os.WriteFile(c.path, b, 0o644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+// RoundTrip replays the recorded response of the request.
+func (c *HTTPCassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readAndRestoreBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	key := httpInteractionRequest{Method: req.Method, URL: req.URL.String(), Body: newHTTPRecordBody(reqBody)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matched := -1
+	for i, interaction := range c.Interactions {
+		r := interaction.Request
+		if r.Method != key.Method || r.URL != key.URL || !r.Body.equal(key.Body) {
+			continue
+		}
+
+		matched = i
+		if !c.used[i] {
+			break
+		}
+	}
+	if matched < 0 {
+		return nil, fmt.Errorf("no recorded interactions in %s match %s %s", c.path, req.Method, req.URL)
+	}
+	c.used[matched] = true
+
+	recorded := c.Interactions[matched].Response
+	b, err := recorded.Body.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded body: %w", err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package defaults_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// httpRequestsWorkflow sends the requests of args in order and returns the bodies of the responses.
+const httpRequestsWorkflow = `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - bodies: []
+    - loop:
+        for:
+          value: req
+          in: ${args}
+          steps:
+            - request:
+                call: http.request
+                args:
+                  method: ${req.method}
+                  url: ${req.url}
+                  headers:
+                    Content-Type: text/plain
+                  body: ${map.get(req, "body")}
+                result: res
+            - collect:
+                assign:
+                  - bodies: ${list.concat(bodies, res.body)}
+    - done:
+        return: ${bodies}
+`
+
+func TestHTTPCassette(t *testing.T) {
+	t.Parallel()
+
+	var count int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/count", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strconv.FormatInt(atomic.AddInt64(&count, 1), 10))
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0xff, 0xfe})
+	})
+	ts := httptest.NewServer(mux)
+
+	// record the interactions, and stop the server to replay them offline
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := &http.Client{Transport: defaults.NewHTTPCassette(path).Recorder(http.DefaultTransport)}
+	recorded, err := execute(t, httpRequestsWorkflow, []any{
+		map[string]any{"method": "GET", "url": ts.URL + "/count"},
+		map[string]any{"method": "GET", "url": ts.URL + "/count"},
+		map[string]any{"method": "POST", "url": ts.URL + "/echo", "body": "a"},
+		map[string]any{"method": "POST", "url": ts.URL + "/echo", "body": "b"},
+		map[string]any{"method": "GET", "url": ts.URL + "/binary"},
+	}, workflow.WithHTTPClient(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	if diff := cmp.Diff([]any{types.Bytes("1"), types.Bytes("2"), types.Bytes("a"), types.Bytes("b"), types.Bytes{0xff, 0xfe}}, recorded); diff != "" {
+		t.Fatalf("unexpected recorded result (-want +got):\n%s", diff)
+	}
+
+	for _, tt := range []struct {
+		name             string
+		requests         []any
+		expected         any
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name: "replay in the recorded order",
+			requests: []any{
+				map[string]any{"method": "GET", "url": ts.URL + "/count"},
+				map[string]any{"method": "GET", "url": ts.URL + "/count"},
+			},
+			expected: []any{types.Bytes("1"), types.Bytes("2")},
+		},
+		{
+			name: "replay the last matched one again",
+			requests: []any{
+				map[string]any{"method": "GET", "url": ts.URL + "/count"},
+				map[string]any{"method": "GET", "url": ts.URL + "/count"},
+				map[string]any{"method": "GET", "url": ts.URL + "/count"},
+			},
+			expected: []any{types.Bytes("1"), types.Bytes("2"), types.Bytes("2")},
+		},
+		{
+			name: "match the body",
+			requests: []any{
+				map[string]any{"method": "POST", "url": ts.URL + "/echo", "body": "b"},
+				map[string]any{"method": "POST", "url": ts.URL + "/echo", "body": "a"},
+			},
+			expected: []any{types.Bytes("b"), types.Bytes("a")},
+		},
+		{
+			name: "replay the binary body",
+			requests: []any{
+				map[string]any{"method": "GET", "url": ts.URL + "/binary"},
+			},
+			expected: []any{types.Bytes{0xff, 0xfe}},
+		},
+		{
+			name: "fail the unrecorded request",
+			requests: []any{
+				map[string]any{"method": "POST", "url": ts.URL + "/echo", "body": "c"},
+			},
+			expectedErrorTag: types.SystemErrorTag,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cassette, err := defaults.LoadHTTPCassette(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ret, err := execute(t, httpRequestsWorkflow, tt.requests, workflow.WithHTTPClient(&http.Client{Transport: cassette}))
+			if err != nil {
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
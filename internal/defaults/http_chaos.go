@@ -0,0 +1,160 @@
+package defaults
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+)
+
+// HTTPChaos injects the faults and the latencies into the outbound HTTP requests matched by the method and the URL pattern
+// to validate the retry policies and the except blocks of the workflows.
+type HTTPChaos struct {
+	mu    sync.Mutex
+	rand  *rand.Rand
+	rules []*httpChaosRule
+}
+
+// httpChaosRule is a rule of the chaos mode. The first matched fault is injected by its probability.
+//
+//	url: https://api.example.com/*
+//	latency: 0.5 # seconds
+//	faults:
+//	  - {probability: 0.2, status: 503}
+//	  - {probability: 0.1, timeout: true}
+type httpChaosRule struct {
+	Method  string           `json:"method"` // matches any methods if it's empty
+	URL     string           `json:"url"`    // * matches any characters
+	Latency float64          `json:"latency"`
+	Faults  []httpChaosFault `json:"faults"`
+
+	urlPattern *regexp.Regexp
+}
+
+type httpChaosFault struct {
+	Probability float64 `json:"probability"`
+	Status      int     `json:"status"`  // responds the status code
+	Timeout     bool    `json:"timeout"` // hangs until the timeout of the request
+}
+
+// ParseHTTPChaosYAML parses the rules of the chaos mode. The faults are reproducible with the same non-zero seed.
+func ParseHTTPChaosYAML(r io.Reader, seed int64) (*HTTPChaos, error) {
+	yamlBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
+	}
+
+	var rules []*httpChaosRule
+	if err = json.Unmarshal(jsonBytes, &rules); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	for i, rule := range rules {
+		if rule.URL == "" {
+			return nil, fmt.Errorf("invalid rule[%d]: url is required", i)
+		}
+		if rule.Latency < 0 {
+			return nil, fmt.Errorf("invalid rule[%d]: latency must not be negative", i)
+		}
+		for j, fault := range rule.Faults {
+			if fault.Probability < 0 || fault.Probability > 1 {
+				return nil, fmt.Errorf("invalid rule[%d].faults[%d]: probability must be in 0..1", i, j)
+			}
+			if (fault.Status == 0) == !fault.Timeout {
+				return nil, fmt.Errorf("invalid rule[%d].faults[%d]: exactly one of status or timeout is required", i, j)
+			}
+		}
+		rule.urlPattern = compileURLPattern(rule.URL)
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &HTTPChaos{rand: rand.New(rand.NewSource(seed)), rules: rules}, nil
+}
+
+// Wrap returns the transport which injects the faults into the requests sent by the transport.
+func (c *HTTPChaos) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &httpChaosTransport{chaos: c, next: next}
+}
+
+type httpChaosTransport struct {
+	chaos *HTTPChaos
+	next  http.RoundTripper
+}
+
+func (t *httpChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule := t.chaos.match(req)
+	if rule == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	if rule.Latency > 0 {
+		select {
+		case <-time.After(time.Duration(rule.Latency * float64(time.Second))):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	fault := t.chaos.pickFault(rule)
+	switch {
+	case fault == nil:
+		return t.next.RoundTrip(req)
+
+	case fault.Timeout:
+		logging.Logf(logging.LevelInfo, "chaos: inject timeout", "method", req.Method, "url", req.URL)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+
+	default:
+		logging.Logf(logging.LevelInfo, "chaos: inject status", "status", fault.Status, "method", req.Method, "url", req.URL)
+		body := []byte("injected fault by the chaos mode")
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", fault.Status, http.StatusText(fault.Status)),
+			StatusCode:    fault.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain"}},
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+}
+
+func (c *HTTPChaos) match(req *http.Request) *httpChaosRule {
+	for _, rule := range c.rules {
+		if (rule.Method == "" || strings.EqualFold(rule.Method, req.Method)) && rule.urlPattern.MatchString(req.URL.String()) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (c *HTTPChaos) pickFault(rule *httpChaosRule) *httpChaosFault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range rule.Faults {
+		if c.rand.Float64() < rule.Faults[i].Probability {
+			return &rule.Faults[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package defaults_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+func TestHTTPChaos(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(ts.Close)
+
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+          timeout: 0.2
+        result: res
+    - done:
+        return: ${text.decode(res.body)}
+`
+	for _, tt := range []struct {
+		name             string
+		rules            string
+		path             string
+		minLatency       time.Duration
+		expected         any
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name: "inject the status",
+			rules: `
+- url: "*/fault"
+  faults:
+    - {probability: 1, status: 503}
+`,
+			path:             "/fault",
+			expectedErrorTag: types.HttpErrorTag,
+		},
+		{
+			name: "inject the timeout",
+			rules: `
+- method: GET
+  url: "*/fault"
+  faults:
+    - {probability: 1, timeout: true}
+`,
+			path:             "/fault",
+			expectedErrorTag: types.TimeoutErrorTag,
+		},
+		{
+			name: "inject the latency",
+			rules: `
+- url: "*/slow"
+  latency: 0.05
+`,
+			path:       "/slow",
+			minLatency: 50 * time.Millisecond,
+			expected:   "ok",
+		},
+		{
+			name: "pass through the unmatched requests",
+			rules: `
+- method: POST
+  url: "*/fault"
+  faults:
+    - {probability: 1, status: 503}
+`,
+			path:     "/fault",
+			expected: "ok",
+		},
+		{
+			name: "pass through without the faults by the probability",
+			rules: `
+- url: "*/fault"
+  faults:
+    - {probability: 0, status: 503}
+`,
+			path:     "/fault",
+			expected: "ok",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			chaos, err := defaults.ParseHTTPChaosYAML(strings.NewReader(tt.rules), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: chaos.Wrap(http.DefaultTransport)}
+
+			start := time.Now()
+			ret, err := execute(t, source, map[string]any{"url": ts.URL + tt.path}, workflow.WithHTTPClient(client))
+			if err != nil {
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minLatency {
+				t.Errorf("latency is not injected: %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestHTTPChaosSeed(t *testing.T) {
+	t.Parallel()
+
+	const rules = `
+- url: "*"
+  faults:
+    - {probability: 0.5, status: 503}
+`
+	// codes returns the status codes of the requests through the chaos of the seed
+	codes := func(t *testing.T, seed int64) []int {
+		chaos, err := defaults.ParseHTTPChaosYAML(strings.NewReader(rules), seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transport := chaos.Wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		}))
+
+		codes := make([]int, 20)
+		for i := range codes {
+			req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			codes[i] = res.StatusCode
+		}
+		return codes
+	}
+
+	first := codes(t, 42)
+	if diff := cmp.Diff(first, codes(t, 42)); diff != "" {
+		t.Errorf("the faults are not reproducible by the seed (-first +second):\n%s", diff)
+	}
+	if !lo.Contains(first, http.StatusOK) || !lo.Contains(first, http.StatusServiceUnavailable) {
+		t.Errorf("the faults are not injected by the probability: %v", first)
+	}
+}
+
+// roundTripperFunc is the transport of the function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestParseHTTPChaos(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name  string
+		rules string
+	}{
+		{name: "missing url", rules: `[{faults: [{probability: 1, status: 503}]}]`},
+		{name: "negative latency", rules: `[{url: "*", latency: -1}]`},
+		{name: "probability out of range", rules: `[{url: "*", faults: [{probability: 2, status: 503}]}]`},
+		{name: "both status and timeout", rules: `[{url: "*", faults: [{probability: 1, status: 503, timeout: true}]}]`},
+		{name: "neither status nor timeout", rules: `[{url: "*", faults: [{probability: 1}]}]`},
+	} {
+		tt := tt
+		t.Run("reject "+tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := defaults.ParseHTTPChaosYAML(strings.NewReader(tt.rules), 1)
+			if err == nil {
+				t.Fatal("should be parse error")
+			}
+			t.Logf("expected error: %v", err)
+		})
+	}
+}
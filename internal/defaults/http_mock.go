@@ -0,0 +1,218 @@
+package defaults
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+)
+
+// HTTPMocks is the transport which returns the configured responses for the outbound HTTP requests instead of sending them.
+// The requests which don't match any mocks fail not to reach the real services.
+type HTTPMocks struct {
+	mocks []*httpMock
+}
+
+// httpMock is a mock of the HTTP requests matched by the method and the URL pattern.
+// The strings in the headers and the body of the response are templates (text/template) of the request.
+//
+//	method: GET
+//	url: https://api.example.com/users/*
+//	response:
+//	  status: 200
+//	  body: {id: "{{ .Query.Get \"id\" }}", path: "{{ .Path }}"}
+type httpMock struct {
+	Method   string `json:"method"` // matches any methods if it's empty
+	URL      string `json:"url"`    // * matches any characters
+	Response struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers"`
+		Body    any               `json:"body"`
+	} `json:"response"`
+
+	urlPattern *regexp.Regexp
+}
+
+// httpMockRequest is the data of the templates of the mocked responses.
+type httpMockRequest struct {
+	Method string
+	URL    string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+}
+
+func ParseHTTPMocksYAML(r io.Reader) (*HTTPMocks, error) {
+	yamlBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
+	}
+	return parseHTTPMocksJSON(jsonBytes)
+}
+
+// ParseHTTPMocksJSON is the same as ParseHTTPMocksYAML for the mocks in JSON.
+func ParseHTTPMocksJSON(r io.Reader) (*HTTPMocks, error) {
+	jsonBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	return parseHTTPMocksJSON(jsonBytes)
+}
+
+func parseHTTPMocksJSON(jsonBytes []byte) (*HTTPMocks, error) {
+	var mocks []*httpMock
+	if err := json.Unmarshal(jsonBytes, &mocks); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	for i, mock := range mocks {
+		if mock.URL == "" {
+			return nil, fmt.Errorf("invalid mock[%d]: url is required", i)
+		}
+		if mock.Response.Status == 0 {
+			mock.Response.Status = http.StatusOK
+		}
+		mock.urlPattern = compileURLPattern(mock.URL)
+	}
+	return &HTTPMocks{mocks: mocks}, nil
+}
+
+// compileURLPattern compiles the URL pattern whose * matches any characters.
+func compileURLPattern(pattern string) *regexp.Regexp {
+	quoted := strings.Split(pattern, "*")
+	for i := range quoted {
+		quoted[i] = regexp.QuoteMeta(quoted[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(quoted, ".*") + "$")
+}
+
+func (m *HTTPMocks) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, mock := range m.mocks {
+		if (mock.Method == "" || strings.EqualFold(mock.Method, req.Method)) && mock.urlPattern.MatchString(req.URL.String()) {
+			return mock.respond(req)
+		}
+	}
+	return nil, fmt.Errorf("no HTTP mocks match %s %s", req.Method, req.URL)
+}
+
+func (mock *httpMock) respond(req *http.Request) (*http.Response, error) {
+	data := httpMockRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Path:   req.URL.Path,
+		Query:  req.URL.Query(),
+		Header: req.Header,
+	}
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("io.ReadAll: %w", err)
+		}
+		data.Body = string(b)
+	}
+
+	header := http.Header{}
+	for name, value := range mock.Response.Headers {
+		v, err := renderHTTPMockTemplate(value, &data)
+		if err != nil {
+			return nil, err
+		}
+		header.Set(name, v)
+	}
+
+	body, err := renderHTTPMockBody(mock.Response.Body, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	switch v := body.(type) {
+	case nil:
+		// no body
+	case string:
+		b = []byte(v)
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "text/plain")
+		}
+	default:
+		b, err = json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("json.Marshal: %w", err)
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/json")
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", mock.Response.Status, http.StatusText(mock.Response.Status)),
+		StatusCode:    mock.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
+
+// renderHTTPMockBody renders the string values in the body recursively.
+func renderHTTPMockBody(body any, data *httpMockRequest) (any, error) {
+	switch v := body.(type) {
+	case string:
+		return renderHTTPMockTemplate(v, data)
+	case []any:
+		ret := make([]any, len(v))
+		for i, value := range v {
+			var err error
+			ret[i], err = renderHTTPMockBody(value, data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	case map[string]any:
+		ret := make(map[string]any, len(v))
+		for key, value := range v {
+			var err error
+			ret[key], err = renderHTTPMockBody(value, data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	default:
+		return v, nil
+	}
+}
+
+func renderHTTPMockTemplate(text string, data *httpMockRequest) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("mock").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("template.Parse: %w", err)
+	}
+
+	var s strings.Builder
+	if err = tmpl.Execute(&s, data); err != nil {
+		return "", fmt.Errorf("template.Execute: %w", err)
+	}
+	return s.String(), nil
+}
@@ -0,0 +1,167 @@
+package defaults_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+const testHTTPMocks = `
+- method: GET
+  url: https://api.example.com/users/*
+  response:
+    body:
+      path: "{{ .Path }}"
+      id: "{{ .Query.Get \"id\" }}"
+- method: POST
+  url: https://api.example.com/users
+  response:
+    status: 201
+    headers:
+      Location: https://api.example.com/users/new
+    body: "created {{ .Body }}"
+- url: https://api.example.com/*
+  response:
+    status: 503
+`
+
+func TestHTTPMocks(t *testing.T) {
+	t.Parallel()
+
+	mocks, err := defaults.ParseHTTPMocksYAML(strings.NewReader(testHTTPMocks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: mocks}
+
+	for _, tt := range []struct {
+		name             string
+		method           string
+		url              string
+		body             string
+		expected         any
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name:     "render the JSON body by the request",
+			method:   "GET",
+			url:      "https://api.example.com/users/alice?id=1",
+			expected: map[string]any{"code": int64(200), "body": map[string]any{"path": "/users/alice", "id": "1"}},
+		},
+		{
+			name:     "match the method and respond the text body",
+			method:   "POST",
+			url:      "https://api.example.com/users",
+			body:     "alice",
+			expected: map[string]any{"code": int64(201), "body": types.Bytes("created alice")},
+		},
+		{
+			name:             "match the first mock of any methods",
+			method:           "DELETE",
+			url:              "https://api.example.com/users",
+			expectedErrorTag: types.HttpErrorTag,
+		},
+		{
+			name:             "fail the unmatched request",
+			method:           "GET",
+			url:              "https://other.example.com/",
+			expectedErrorTag: types.SystemErrorTag,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			const source = `
+main:
+  params: [args]
+  steps:
+    - request:
+        call: http.request
+        args:
+          method: ${args.method}
+          url: ${args.url}
+          headers:
+            Content-Type: text/plain
+          body: ${args.body}
+        result: res
+    - done:
+        return:
+          code: ${res.code}
+          body: ${res.body}
+`
+			ret, err := execute(t, source, map[string]any{"method": tt.method, "url": tt.url, "body": tt.body}, workflow.WithHTTPClient(client))
+			if err != nil {
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseHTTPMocks(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name               string
+		parse              func(string) (*defaults.HTTPMocks, error)
+		source             string
+		expectToBeParseErr bool
+	}{
+		{
+			name:   "parse YAML",
+			parse:  func(s string) (*defaults.HTTPMocks, error) { return defaults.ParseHTTPMocksYAML(strings.NewReader(s)) },
+			source: testHTTPMocks,
+		},
+		{
+			name:   "parse JSON",
+			parse:  func(s string) (*defaults.HTTPMocks, error) { return defaults.ParseHTTPMocksJSON(strings.NewReader(s)) },
+			source: `[{"url":"https://api.example.com/*","response":{"body":"ok"}}]`,
+		},
+		{
+			name:               "reject the mock without the URL",
+			parse:              func(s string) (*defaults.HTTPMocks, error) { return defaults.ParseHTTPMocksYAML(strings.NewReader(s)) },
+			source:             "- method: GET\n",
+			expectToBeParseErr: true,
+		},
+		{
+			name:               "reject the mocks which are not a list",
+			parse:              func(s string) (*defaults.HTTPMocks, error) { return defaults.ParseHTTPMocksJSON(strings.NewReader(s)) },
+			source:             `{"url":"https://api.example.com/"}`,
+			expectToBeParseErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tt.parse(tt.source)
+			if err != nil {
+				if tt.expectToBeParseErr {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectToBeParseErr {
+				t.Fatal("should be parse error")
+			}
+		})
+	}
+}
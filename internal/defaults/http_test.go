@@ -0,0 +1,947 @@
+package defaults_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestMain(m *testing.M) {
+	// the connectors and the authenticated requests must not depend on the credentials of the environment
+	defaults.SetFakeAuth(nil)
+	os.Exit(m.Run())
+}
+
+// execute executes the workflow of the source with the arguments.
+func execute(t *testing.T, source string, args any, opts ...workflow.ExecuteOption) (any, error) {
+	t.Helper()
+
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root.Execute(context.Background(), args, opts...)
+}
+
+const httpGetWorkflow = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+        result: res
+    - done:
+        return: ${res}
+`
+
+func TestHTTPMaxRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if n == 0 {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	t.Cleanup(func() { defaults.SetHTTPMaxRedirects(10) })
+	for _, tt := range []struct {
+		name         string
+		maxRedirects int
+		redirects    int
+		expectedCode int64
+		expectedURL  string
+	}{
+		{
+			name:         "follow the redirects",
+			maxRedirects: 10,
+			redirects:    3,
+			expectedCode: http.StatusOK,
+			expectedURL:  "/redirect/0",
+		},
+		{
+			name:         "follow the redirects up to the limit",
+			maxRedirects: 3,
+			redirects:    3,
+			expectedCode: http.StatusOK,
+			expectedURL:  "/redirect/0",
+		},
+		{
+			name:         "return the redirect beyond the limit",
+			maxRedirects: 2,
+			redirects:    3,
+			expectedCode: http.StatusFound,
+			expectedURL:  "/redirect/1",
+		},
+		{
+			name:         "disable following the redirects",
+			maxRedirects: 0,
+			redirects:    3,
+			expectedCode: http.StatusFound,
+			expectedURL:  "/redirect/3",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults.SetHTTPMaxRedirects(tt.maxRedirects)
+
+			ret, err := execute(t, httpGetWorkflow, map[string]any{"url": ts.URL + "/redirect/" + strconv.Itoa(tt.redirects)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := ret.(map[string]any)
+			if res["code"] != tt.expectedCode {
+				t.Errorf("unexpected code: %v, want %d", res["code"], tt.expectedCode)
+			}
+			if res["url"] != ts.URL+tt.expectedURL {
+				t.Errorf("unexpected url: %v, want %s", res["url"], ts.URL+tt.expectedURL)
+			}
+			if tt.expectedCode == http.StatusFound {
+				headers := res["headers"].(map[string]any)
+				if headers["Location"] != "/redirect/"+strconv.Itoa(tt.redirects-tt.maxRedirects-1) {
+					t.Errorf("unexpected Location: %v", headers["Location"])
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if code >= 300 && code < 400 {
+			w.Header().Set("Location", "/200")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"status":%d}`, code)
+	}))
+	t.Cleanup(ts.Close)
+
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        try:
+          call: http.get
+          args:
+            url: ${args.url}
+          result: res
+        except:
+          as: e
+          steps:
+            - caught:
+                return:
+                  tagged: ${"HttpError" in e.tags}
+                  code: ${e.code}
+                  message: ${e.message}
+                  body: ${e.body}
+    - done:
+        return:
+          code: ${res.code}
+          body: ${res.body}
+`
+	for _, tt := range []struct {
+		name     string
+		code     int
+		expected map[string]any
+	}{
+		{
+			name:     "return the successful response",
+			code:     http.StatusCreated,
+			expected: map[string]any{"code": int64(201), "body": map[string]any{"status": float64(201)}},
+		},
+		{
+			name: "raise HttpError for the client error",
+			code: http.StatusNotFound,
+			expected: map[string]any{
+				"tagged":  true,
+				"code":    int64(404),
+				"message": "HTTP server responded with error code 404",
+				"body":    map[string]any{"status": float64(404)},
+			},
+		},
+		{
+			name: "raise HttpError for the server error",
+			code: http.StatusInternalServerError,
+			expected: map[string]any{
+				"tagged":  true,
+				"code":    int64(500),
+				"message": "HTTP server responded with error code 500",
+				"body":    map[string]any{"status": float64(500)},
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ret, err := execute(t, source, map[string]any{"url": ts.URL + "/" + strconv.Itoa(tt.code)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPTransportError(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("/hangup", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	tlsServer := httptest.NewUnstartedServer(http.NotFoundHandler())
+	tlsServer.Config.ErrorLog = log.New(io.Discard, "", 0) // the handshake is expected to fail
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        try:
+          call: http.get
+          args:
+            url: ${args.url}
+            timeout: 0.5
+        except:
+          as: e
+          steps:
+            - caught:
+                return: ${e.tags[0]}
+`
+	for _, tt := range []struct {
+		name     string
+		url      string
+		expected types.ErrorTag
+	}{
+		{
+			name:     "raise ConnectionFailedError if the connection is refused",
+			url:      closed.URL,
+			expected: types.ConnectionFailedErrorTag,
+		},
+		{
+			name:     "raise ConnectionFailedError if the certificate is not trusted",
+			url:      tlsServer.URL,
+			expected: types.ConnectionFailedErrorTag,
+		},
+		{
+			name:     "raise ConnectionError if the connection is closed midway",
+			url:      ts.URL + "/hangup",
+			expected: types.ConnectionErrorTag,
+		},
+		{
+			name:     "raise TimeoutError if the response is not returned until the timeout",
+			url:      ts.URL + "/slow",
+			expected: types.TimeoutErrorTag,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ret, err := execute(t, source, map[string]any{"url": tt.url})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ret != string(tt.expected) {
+				t.Errorf("unexpected tag: %v, want %s", ret, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPMultipartBody(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		files := map[string]any{}
+		for name, headers := range r.MultipartForm.File {
+			f, err := headers[0].Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			b, _ := io.ReadAll(f)
+			f.Close()
+			files[name] = headers[0].Filename + ":" + string(b)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"values": r.MultipartForm.Value, "files": files})
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tt := range []struct {
+		name             string
+		body             string
+		expected         any
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name: "send the fields",
+			body: `
+            name: alice
+            age: 20
+            ratio: 0.5
+            active: true`,
+			expected: map[string]any{
+				"values": map[string]any{"name": []any{"alice"}, "age": []any{"20"}, "ratio": []any{"0.5"}, "active": []any{"true"}},
+				"files":  map[string]any{},
+			},
+		},
+		{
+			name: "send the list as the repeated fields",
+			body: `
+            tag: [a, b]`,
+			expected: map[string]any{
+				"values": map[string]any{"tag": []any{"a", "b"}},
+				"files":  map[string]any{},
+			},
+		},
+		{
+			name: "send the bytes as the file",
+			body: `
+            upload: ${text.encode("hello")}`,
+			expected: map[string]any{
+				"values": map[string]any{},
+				"files":  map[string]any{"upload": "upload:hello"},
+			},
+		},
+		{
+			name: "reject the nested map",
+			body: `
+            nested:
+              key: value`,
+			expectedErrorTag: types.TypeErrorTag,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := `
+main:
+  params: [args]
+  steps:
+    - post:
+        call: http.post
+        args:
+          url: ${args.url}
+          headers:
+            Content-Type: multipart/form-data
+          body:` + tt.body + `
+        result: res
+    - done:
+        return: ${res.body}
+`
+			ret, err := execute(t, source, map[string]any{"url": ts.URL})
+			if err != nil {
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPQuery(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.RawQuery)
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tt := range []struct {
+		name             string
+		path             string
+		query            string
+		expected         string
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name: "encode the scalar values",
+			query: `
+            s: a b
+            i: 1
+            f: 1.5`,
+			expected: "f=1.5&i=1&s=a+b",
+		},
+		{
+			name: "encode the list as the repeated parameters",
+			query: `
+            id: [1, 2, "x"]`,
+			expected: "id=1&id=2&id=x",
+		},
+		{
+			name: "override the parameters of the URL",
+			path: "/?id=0&keep=1",
+			query: `
+            id: [1, 2]`,
+			expected: "id=1&id=2&keep=1",
+		},
+		{
+			name: "reject the map value",
+			query: `
+            m:
+              k: v`,
+			expectedErrorTag: types.TypeErrorTag,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			source := `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+          query:` + tt.query + `
+        result: res
+    - done:
+        return: ${text.decode(res.body)}
+`
+			ret, err := execute(t, source, map[string]any{"url": ts.URL + tt.path})
+			if err != nil {
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+			if ret != tt.expected {
+				t.Errorf("unexpected query: %v, want %s", ret, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPMultiValueHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.Header().Set("X-Single", "only")
+	}))
+	defer ts.Close()
+
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+        result: res
+    - done:
+        return:
+          - ${res.headers["Set-Cookie"]}
+          - ${res.headers["X-Single"]}
+`
+	t.Cleanup(func() { defaults.SetHTTPMultiValueHeaders(false) })
+	for _, tt := range []struct {
+		name     string
+		enabled  bool
+		expected any
+	}{
+		{
+			name:     "return the first value by default",
+			enabled:  false,
+			expected: []any{"a=1", "only"},
+		},
+		{
+			name:     "return the multiple values as the list",
+			enabled:  true,
+			expected: []any{[]any{"a=1", "b=2"}, "only"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults.SetHTTPMultiValueHeaders(tt.enabled)
+
+			ret, err := execute(t, source, map[string]any{"url": ts.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected headers (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPTLSConfig(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0) // the handshake of the untrusted certificate is expected to fail
+	ts.Config.SetKeepAlivesEnabled(false)           // verify the certificate for each request
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := defaults.SetHTTPTLSConfig("", false); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, tt := range []struct {
+		name               string
+		caFile             string
+		insecureSkipVerify bool
+		expectToBeSetErr   bool
+		expectedErrorTag   types.ErrorTag
+	}{
+		{
+			name:             "reject the untrusted certificate",
+			expectedErrorTag: types.ConnectionFailedErrorTag,
+		},
+		{
+			name:   "trust the certificate of the CA bundle",
+			caFile: caFile,
+		},
+		{
+			name:               "skip the verification",
+			insecureSkipVerify: true,
+		},
+		{
+			name:             "reject the CA bundle without the certificates",
+			caFile:           emptyFile,
+			expectToBeSetErr: true,
+		},
+		{
+			name:             "reject the missing CA bundle",
+			caFile:           filepath.Join(dir, "missing.pem"),
+			expectToBeSetErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := defaults.SetHTTPTLSConfig(tt.caFile, tt.insecureSkipVerify); err != nil {
+				if tt.expectToBeSetErr {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectToBeSetErr {
+				t.Fatal("should be error")
+			}
+
+			ret, err := execute(t, httpGetWorkflow, map[string]any{"url": ts.URL})
+			if err != nil {
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+			if code := ret.(map[string]any)["code"]; code != int64(http.StatusOK) {
+				t.Errorf("unexpected code: %v", code)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfig(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "proxied "+r.URL.String())
+	}))
+	defer proxy.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+        result: res
+    - done:
+        return: ${text.decode(res.body)}
+`
+	t.Run("route the requests via the proxy", func(t *testing.T) {
+		if err := defaults.SetHTTPProxy(proxy.URL); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := defaults.SetHTTPProxy(""); err != nil {
+				t.Error(err)
+			}
+		})
+
+		ret, err := execute(t, source, map[string]any{"url": "http://backend.invalid/path?q=1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "proxied http://backend.invalid/path?q=1"; ret != expected {
+			t.Errorf("unexpected result: %v, want %s", ret, expected)
+		}
+	})
+
+	t.Run("reject the invalid proxy URL", func(t *testing.T) {
+		err := defaults.SetHTTPProxy("://proxy")
+		if err == nil {
+			t.Fatal("should be error")
+		}
+		t.Logf("expected error: %v", err)
+	})
+
+	t.Run("time out by the default timeout", func(t *testing.T) {
+		defaults.SetHTTPDefaultTimeout(0.2)
+		t.Cleanup(func() { defaults.SetHTTPDefaultTimeout(300) })
+
+		_, err := execute(t, source, map[string]any{"url": slow.URL})
+		var e *types.Error
+		if !errors.As(err, &e) || e.Tag != types.TimeoutErrorTag {
+			t.Fatalf("should be TimeoutError but got %v", err)
+		}
+		t.Logf("expected error: %v", err)
+	})
+}
+
+func TestHTTPClientOption(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if n == 0 {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	ts := httptest.NewTLSServer(mux)
+	t.Cleanup(ts.Close)
+
+	noRedirects := *ts.Client()
+	noRedirects.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, tt := range []struct {
+		name         string
+		opts         []workflow.ExecuteOption
+		redirects    int
+		expectedCode int64
+		wantErr      bool
+	}{
+		{
+			name:         "send the requests by the client",
+			opts:         []workflow.ExecuteOption{workflow.WithHTTPClient(ts.Client())},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:    "send the requests by the transport of the process",
+			wantErr: true,
+		},
+		{
+			name:         "follow the redirects up to the limit of the process",
+			opts:         []workflow.ExecuteOption{workflow.WithHTTPClient(ts.Client())},
+			redirects:    10,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "return the redirect beyond the limit of the process",
+			opts:         []workflow.ExecuteOption{workflow.WithHTTPClient(ts.Client())},
+			redirects:    11,
+			expectedCode: http.StatusFound,
+		},
+		{
+			name:         "check the redirects by the client",
+			opts:         []workflow.ExecuteOption{workflow.WithHTTPClient(&noRedirects)},
+			redirects:    1,
+			expectedCode: http.StatusFound,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ret, err := execute(t, httpGetWorkflow, map[string]any{"url": ts.URL + "/redirect/" + strconv.Itoa(tt.redirects)}, tt.opts...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res := ret.(map[string]any); res["code"] != tt.expectedCode {
+				t.Errorf("unexpected code: %v, want %d", res["code"], tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestHTTPMaxResponseSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		io.WriteString(w, strings.Repeat("x", n))
+	}))
+	defer ts.Close()
+
+	t.Cleanup(func() { defaults.SetHTTPMaxResponseSize(defaults.ProductionHTTPMaxResponseSize) })
+	for _, tt := range []struct {
+		name             string
+		maxResponseSize  int64
+		size             int
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name:            "accept the response under the limit",
+			maxResponseSize: 10,
+			size:            9,
+		},
+		{
+			name:            "accept the response of the limit",
+			maxResponseSize: 10,
+			size:            10,
+		},
+		{
+			name:             "raise ResourceLimitError for the response over the limit",
+			maxResponseSize:  10,
+			size:             11,
+			expectedErrorTag: types.ResourceLimitErrorTag,
+		},
+		{
+			name:             "limit the response like the production by default",
+			maxResponseSize:  defaults.ProductionHTTPMaxResponseSize,
+			size:             defaults.ProductionHTTPMaxResponseSize + 1,
+			expectedErrorTag: types.ResourceLimitErrorTag,
+		},
+		{
+			name:            "disable the limit",
+			maxResponseSize: 0,
+			size:            defaults.ProductionHTTPMaxResponseSize + 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults.SetHTTPMaxResponseSize(tt.maxResponseSize)
+
+			ret, err := execute(t, httpGetWorkflow, map[string]any{"url": ts.URL + "?size=" + strconv.Itoa(tt.size)})
+			if err != nil {
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+			if body := ret.(map[string]any)["body"].(types.Bytes); len(body) != tt.size {
+				t.Errorf("unexpected body size: %d, want %d", len(body), tt.size)
+			}
+		})
+	}
+}
+
+func TestHTTPCredentialsFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken, err := defaults.NewFakeToken(map[string]any{"email": "sa@my-project.iam.gserviceaccount.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("assertion") == "" {
+			http.Error(w, "assertion is required", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "sa-access-token",
+			"id_token":     idToken.AccessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	credentialsFile := filepath.Join(t.TempDir(), "key.json")
+	credentials, err := json.Marshal(map[string]any{
+		"type":           "service_account",
+		"project_id":     "my-project",
+		"private_key_id": "key-id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "sa@my-project.iam.gserviceaccount.com",
+		"client_id":      "1",
+		"token_uri":      tokenServer.URL + "/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentialsFile, credentials, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	t.Cleanup(defaults.ResetHTTPAuth())
+	if err := defaults.SetHTTPCredentialsFile(credentialsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		auth     string
+		expected string
+	}{
+		{
+			name: "authenticate OAuth2 by the credentials file",
+			auth: `
+            type: OAuth2
+            scopes: https://www.googleapis.com/auth/cloud-platform`,
+			expected: "Bearer sa-access-token",
+		},
+		{
+			name: "authenticate OIDC by the credentials file",
+			auth: `
+            type: OIDC
+            audience: https://example.com`,
+			expected: "Bearer " + idToken.AccessToken,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			source := `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+          auth:` + tt.auth + `
+        result: res
+    - done:
+        return: ${text.decode(res.body)}
+`
+			ret, err := execute(t, source, map[string]any{"url": ts.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ret != tt.expected {
+				t.Errorf("unexpected Authorization: %v, want %s", ret, tt.expected)
+			}
+		})
+	}
+
+	t.Run("reject the missing credentials file", func(t *testing.T) {
+		err := defaults.SetHTTPCredentialsFile(filepath.Join(t.TempDir(), "missing.json"))
+		if err == nil {
+			t.Fatal("should be error")
+		}
+		if !strings.Contains(err.Error(), "missing.json") {
+			t.Errorf("unexpected error: %v", err)
+		}
+		t.Logf("expected error: %v", err)
+	})
+}
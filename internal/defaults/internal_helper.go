@@ -49,3 +49,42 @@ func mergeMaps(maps ...map[string]any) map[string]any {
 	}
 	return m
 }
+
+func aggregateFunctionsToNestedMap(prefix string, funcs []types.Function) map[string]any {
+	prefix += "."
+
+	m := map[string]any{}
+	for _, f := range funcs {
+		if !strings.HasPrefix(f.Name(), prefix) {
+			panic(fmt.Sprintf("invalid prefix for function name: %s (expected to start with %q)", f.Name(), prefix))
+		}
+
+		names := strings.Split(strings.TrimPrefix(f.Name(), prefix), ".")
+		if err := putFunctionToNestedMap(m, names, f); err != nil {
+			panic(err.Error())
+		}
+	}
+	return m
+}
+
+func putFunctionToNestedMap(m map[string]any, names []string, f types.Function) error {
+	for _, name := range names[:len(names)-1] {
+		switch v := m[name].(type) {
+		case nil:
+			child := map[string]any{}
+			m[name] = child
+			m = child
+		case map[string]any:
+			m = v
+		default:
+			return fmt.Errorf("conflicted function name: %s", f.Name())
+		}
+	}
+
+	name := names[len(names)-1]
+	if _, duplicated := m[name]; duplicated {
+		return fmt.Errorf("duplicated function name: %s", f.Name())
+	}
+	m[name] = f
+	return nil
+}
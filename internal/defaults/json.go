@@ -14,7 +14,7 @@ var JSON = aggregateFunctionsToMap("json", []types.Function{
 	}, func(dataAny any) (ret any, err error) {
 		var data []byte
 		switch v := dataAny.(type) {
-		case []byte:
+		case types.Bytes:
 			data = v
 		case string:
 			data = []byte(v)
@@ -50,7 +50,7 @@ var JSON = aggregateFunctionsToMap("json", []types.Function{
 	}),
 })
 
-func encodeJSON(data, indent any) ([]byte, error) {
+func encodeJSON(data, indent any) (types.Bytes, error) {
 	type indentConfig struct {
 		Prefix string `mapstructure:"prefix"`
 		Indent string `mapstructure:"indent"`
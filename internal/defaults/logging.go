@@ -0,0 +1,17 @@
+package defaults
+
+import "time"
+
+// LogEntry is the log entry of an execution written by sys.log or the call logging.
+// refs. https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+type LogEntry struct {
+	Timestamp   time.Time      `json:"timestamp"`
+	Severity    string         `json:"severity"`
+	TextPayload string         `json:"textPayload,omitempty"`
+	JSONPayload map[string]any `json:"jsonPayload,omitempty"`
+}
+
+// LogRecorder captures the log entries of an execution, e.g. to assert them in the tests.
+type LogRecorder interface {
+	RecordLog(entry *LogEntry)
+}
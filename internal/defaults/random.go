@@ -0,0 +1,67 @@
+package defaults
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"sync"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+var UUID = aggregateFunctionsToMap("uuid", []types.Function{
+	types.NewRawFunction("uuid.generate", []types.Argument{}, func(ctx context.Context, _ []any) (any, error) {
+		return NewUUID(ctx), nil
+	}),
+})
+
+type randomKey struct{}
+
+// WithRandom returns the context of the executions reading the random bytes (e.g. of uuid.generate and the execution
+// IDs) from r instead of crypto/rand.
+func WithRandom(ctx context.Context, r io.Reader) context.Context {
+	return context.WithValue(ctx, randomKey{}, r)
+}
+
+// RandomFromContext returns the random source of the context given by WithRandom, or nil.
+func RandomFromContext(ctx context.Context) io.Reader {
+	r, _ := ctx.Value(randomKey{}).(io.Reader)
+	return r
+}
+
+// NewUUID generates a random UUID v4 by the random source of the context, or crypto/rand.
+func NewUUID(ctx context.Context) string {
+	r := RandomFromContext(ctx)
+	if r == nil {
+		r = rand.Reader
+	}
+
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// SeededRandom is the random source of the pseudo random bytes reproduced by the seed, which is safe for the
+// concurrent use. The bytes read by the concurrent executions are not reproducible.
+type SeededRandom struct {
+	mu   sync.Mutex
+	rand *mathrand.Rand
+}
+
+// NewSeededRandom returns the random source of the seed.
+func NewSeededRandom(seed int64) *SeededRandom {
+	return &SeededRandom{rand: mathrand.New(mathrand.NewSource(seed))}
+}
+
+func (r *SeededRandom) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Read(b)
+}
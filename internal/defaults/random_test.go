@@ -0,0 +1,123 @@
+package defaults_test
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+var uuidV4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUID(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name         string
+		newRandom    func() io.Reader
+		reproducible bool
+	}{
+		{
+			name: "crypto/rand",
+		},
+		{
+			name:         "seeded random",
+			newRandom:    func() io.Reader { return defaults.NewSeededRandom(42) },
+			reproducible: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			generate := func() []string {
+				ctx := context.Background()
+				if tt.newRandom != nil {
+					ctx = defaults.WithRandom(ctx, tt.newRandom())
+				}
+				return []string{defaults.NewUUID(ctx), defaults.NewUUID(ctx), defaults.NewUUID(ctx)}
+			}
+
+			first, second := generate(), generate()
+			for _, id := range append(first, second...) {
+				if !uuidV4Pattern.MatchString(id) {
+					t.Errorf("not UUID v4: %s", id)
+				}
+			}
+			if first[0] == first[1] || first[1] == first[2] {
+				t.Errorf("duplicated UUIDs: %v", first)
+			}
+			if diff := cmp.Diff(first, second); (diff == "") != tt.reproducible {
+				t.Errorf("unexpected reproducibility (-first +second):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("different seeds", func(t *testing.T) {
+		t.Parallel()
+
+		a := defaults.NewUUID(defaults.WithRandom(context.Background(), defaults.NewSeededRandom(1)))
+		b := defaults.NewUUID(defaults.WithRandom(context.Background(), defaults.NewSeededRandom(2)))
+		if a == b {
+			t.Errorf("same UUIDs of the different seeds: %s", a)
+		}
+	})
+}
+
+func TestUUIDGenerateWorkflow(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - done:
+        return:
+          - ${uuid.generate()}
+          - ${uuid.generate()}
+          - ${sys.get_env("GOOGLE_CLOUD_WORKFLOW_EXECUTION_ID", "")}
+`
+	for _, tt := range []struct {
+		name         string
+		opts         []workflow.ExecuteOption
+		reproducible bool
+	}{
+		{
+			name: "random IDs",
+		},
+		{
+			name:         "seeded IDs",
+			opts:         []workflow.ExecuteOption{workflow.WithRandom(func() io.Reader { return defaults.NewSeededRandom(42) })},
+			reproducible: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var results [][]any
+			for i := 0; i < 2; i++ {
+				ret, err := execute(t, source, nil, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids, ok := ret.([]any)
+				if !ok || len(ids) != 3 {
+					t.Fatalf("unexpected result: %v", ret)
+				}
+				for _, id := range ids {
+					if s, ok := id.(string); !ok || !uuidV4Pattern.MatchString(s) {
+						t.Errorf("not UUID v4: %v", id)
+					}
+				}
+				results = append(results, ids)
+			}
+			if diff := cmp.Diff(results[0], results[1]); (diff == "") != tt.reproducible {
+				t.Errorf("unexpected reproducibility (-first +second):\n%s", diff)
+			}
+		})
+	}
+}
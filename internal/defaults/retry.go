@@ -1,6 +1,10 @@
 package defaults
 
-import "github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+import (
+	"context"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
 
 var Retry = mergeMaps(
 	map[string]any{
@@ -13,12 +17,12 @@ var Retry = mergeMaps(
 	aggregateFunctionsToMap("retry", []types.Function{
 		types.NewRawFunction("retry.always", []types.Argument{
 			{Name: "exception"},
-		}, func([]any) (any, error) {
+		}, func(context.Context, []any) (any, error) {
 			return true, nil
 		}),
 		types.NewRawFunction("retry.never", []types.Argument{
 			{Name: "exception"},
-		}, func([]any) (any, error) {
+		}, func(context.Context, []any) (any, error) {
 			return false, nil
 		}),
 	}),
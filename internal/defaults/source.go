@@ -0,0 +1,67 @@
+package defaults
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// IsRemoteSource reports whether the path is the URL of FetchSource instead of a local file.
+func IsRemoteSource(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "gs://")
+}
+
+// FetchSource reads the content of the http(s):// URL, or the gs://BUCKET/OBJECT of Cloud Storage by the credentials of
+// the connectors (or from the Cloud Storage emulator), e.g. the source of the deployed workflow.
+// The requests are sent by the configured transport, but not intercepted by WrapHTTPTransport (e.g. the HTTP mocks).
+func FetchSource(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse: %w", err)
+	}
+
+	var req *http.Request
+	switch u.Scheme {
+	case "http", "https":
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("http.NewRequestWithContext: %w", err)
+		}
+	case "gs":
+		rootURL, emulated := storageV1.endpoint()
+		path := expandGoogleAPIPath("storage/v1/b/{bucket}/o/{object}", map[string]string{
+			"bucket": u.Host,
+			"object": strings.TrimPrefix(u.Path, "/"),
+		})
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, rootURL+path+"?alt=media", nil)
+		if err != nil {
+			return nil, fmt.Errorf("http.NewRequestWithContext: %w", err)
+		}
+		if !emulated {
+			if err := sharedHTTPClient.setOAuth2Headers(req, map[string]any{"scope": "https://www.googleapis.com/auth/devstorage.read_only"}); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme: %s", rawURL)
+	}
+
+	res, err := (&http.Client{Transport: sharedHTTPClient.transport}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", rawURL, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	return body, nil
+}
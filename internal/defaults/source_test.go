@@ -0,0 +1,106 @@
+package defaults_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+)
+
+func TestIsRemoteSource(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		path     string
+		expected bool
+	}{
+		{path: "https://example.com/workflow.yaml", expected: true},
+		{path: "http://localhost:8080/workflow.yaml", expected: true},
+		{path: "gs://my-bucket/dir/workflow.yaml", expected: true},
+		{path: "workflow.yaml", expected: false},
+		{path: "/path/to/workflow.yaml", expected: false},
+		{path: "ftp://example.com/workflow.yaml", expected: false},
+	} {
+		tt := tt
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			if got := defaults.IsRemoteSource(tt.path); got != tt.expected {
+				t.Errorf("IsRemoteSource(%q) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFetchSource isn't parallel since it configures the Cloud Storage emulator by the environment variable.
+func TestFetchSource(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.EscapedPath() {
+		case "/workflow.yaml", "/storage/v1/b/my-bucket/o/dir%2Fworkflow.yaml":
+			_, _ = w.Write([]byte("main:\n  steps: []\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", ts.URL)
+
+	for _, tt := range []struct {
+		name             string
+		url              string
+		expected         string
+		expectedRequests []string
+		wantErr          bool
+	}{
+		{
+			name:             "fetch the http URL",
+			url:              ts.URL + "/workflow.yaml",
+			expected:         "main:\n  steps: []\n",
+			expectedRequests: []string{"GET /workflow.yaml"},
+		},
+		{
+			name:             "fetch the object of the Cloud Storage emulator",
+			url:              "gs://my-bucket/dir/workflow.yaml",
+			expected:         "main:\n  steps: []\n",
+			expectedRequests: []string{"GET /storage/v1/b/my-bucket/o/dir%2Fworkflow.yaml?alt=media"},
+		},
+		{
+			name:             "reject the not found",
+			url:              ts.URL + "/missing.yaml",
+			expectedRequests: []string{"GET /missing.yaml"},
+			wantErr:          true,
+		},
+		{
+			name:    "reject the unsupported scheme",
+			url:     "ftp://example.com/workflow.yaml",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+
+			got, err := defaults.FetchSource(context.Background(), tt.url)
+			if diff := cmp.Diff(tt.expectedRequests, requests); diff != "" {
+				t.Errorf("unexpected requests (-want +got):\n%s", diff)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, string(got)); diff != "" {
+				t.Errorf("unexpected source (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -4,17 +4,19 @@ import "github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
 
 var DefaultSymbolTable = &types.SymbolTable{
 	Symbols: map[string]any{
-		"base64": Base64,
-		"events": Events,
-		"http":   HTTP,
-		"json":   JSON,
-		"list":   List,
-		"map":    Map,
-		"math":   Math,
-		"retry":  Retry,
-		"sys":    Sys,
-		"text":   Text,
-		"time":   Time,
+		"base64":     Base64,
+		"events":     Events,
+		"googleapis": GoogleAPIs,
+		"http":       HTTP,
+		"json":       JSON,
+		"list":       List,
+		"map":        Map,
+		"math":       Math,
+		"retry":      Retry,
+		"sys":        Sys,
+		"text":       Text,
+		"time":       Time,
+		"uuid":       UUID,
 	},
 	ReadOnly: true,
 	Parent:   ExpressionHelpers,
@@ -1,9 +1,11 @@
 package defaults
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -11,13 +13,12 @@ import (
 )
 
 var Sys = aggregateFunctionsToMap("sys", []types.Function{
-	types.NewRawFunction("sys.now", []types.Argument{}, func([]any) (any, error) {
-		now := time.Now().Unix()
-		return now, nil
+	types.NewRawFunction("sys.now", []types.Argument{}, func(ctx context.Context, _ []any) (any, error) {
+		return now(ctx).Unix(), nil
 	}),
 	types.MustNewFunction("sys.sleep", []types.Argument{
 		{Name: "seconds"},
-	}, func(seconds any) (any, error) {
+	}, func(ctx context.Context, seconds any) (any, error) {
 		var duration time.Duration
 		switch n := seconds.(type) {
 		case int64:
@@ -31,12 +32,11 @@ var Sys = aggregateFunctionsToMap("sys", []types.Function{
 			}
 		}
 
-		time.Sleep(duration)
-		return nil, nil
+		return nil, Sleep(ctx, duration)
 	}),
 	types.MustNewFunction("sys.sleep_until", []types.Argument{
 		{Name: "time"},
-	}, func(seconds string) (any, error) {
+	}, func(ctx context.Context, seconds string) (any, error) {
 		target, err := time.Parse(time.RFC3339Nano, seconds)
 		if err != nil {
 			return nil, &types.Error{
@@ -46,61 +46,92 @@ var Sys = aggregateFunctionsToMap("sys", []types.Function{
 		}
 		target = target.Truncate(time.Microsecond)
 
-		time.Sleep(time.Until(target))
-		return nil, nil
+		return nil, Sleep(ctx, target.Sub(now(ctx)))
 	}),
-	types.MustNewFunction("sys.get_env", []types.Argument{
+	types.MustNewScopedFunction("sys.get_env", []types.Argument{
 		{Name: "name"},
 		{Name: "default"},
-	}, func(name, defaultValue string) (any, error) {
-		value, ok := os.LookupEnv(name)
-		if !ok {
-			value = defaultValue
-		}
+	}, func(st *types.SymbolTable) any {
+		return func(name, defaultValue string) (any, error) {
+			// the environment variables of the execution take precedence over the ones of the emulator process
+			if st != nil {
+				if env, ok := st.Get(types.InternalEnvironmentSymbol); ok {
+					if value, ok := env.(map[string]string)[name]; ok {
+						return value, nil
+					}
+				}
+				// the user-defined environment variables replace the ones of the emulator process except the built-in ones
+				if _, ok := st.Get(types.InternalIsolatedEnvironmentSymbol); ok && !strings.HasPrefix(name, "GOOGLE_CLOUD_") {
+					return defaultValue, nil
+				}
+			}
+
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				value = defaultValue
+			}
 
-		return value, nil
+			return value, nil
+		}
 	}),
-	types.MustNewFunction("sys.log", []types.Argument{
+	types.MustNewScopedFunction("sys.log", []types.Argument{
 		{Name: "data", Default: types.SubstitutionNone},
 		{Name: "severity", Default: "DEFAULT"},
 		{Name: "text", Optional: true},
 		{Name: "json", Optional: true},
-	}, func(data any, severity string, text any, jsonValue map[string]any) (any, error) {
-		if data != nil && text != nil || text != nil && jsonValue != nil || data != nil && jsonValue != nil {
-			return nil, &types.Error{
-				Tag: types.TypeErrorTag,
-				Err: fmt.Errorf("one of data or text or json is needed, cannot specify multiple"),
+	}, func(st *types.SymbolTable) any {
+		return func(data any, severity string, text any, jsonValue map[string]any) (any, error) {
+			hasData := data != types.SubstitutionNone
+			if hasData && text != nil || text != nil && jsonValue != nil || hasData && jsonValue != nil {
+				return nil, &types.Error{
+					Tag: types.TypeErrorTag,
+					Err: fmt.Errorf("one of data or text or json is needed, cannot specify multiple"),
+				}
 			}
-		}
-		if data == types.SubstitutionNone && text == nil && jsonValue == nil {
-			return nil, &types.Error{
-				Tag: types.TypeErrorTag,
-				Err: fmt.Errorf("one of data or text or json is required"),
+			if !hasData && text == nil && jsonValue == nil {
+				return nil, &types.Error{
+					Tag: types.TypeErrorTag,
+					Err: fmt.Errorf("one of data or text or json is required"),
+				}
 			}
-		}
 
-		if data != types.SubstitutionNone {
-			switch d := data.(type) {
-			case map[string]any:
-				jsonValue = d
-			default:
-				text = d
+			if hasData {
+				switch d := data.(type) {
+				case map[string]any:
+					jsonValue = d
+				default:
+					text = d
+				}
 			}
-		}
 
-		if text != nil {
-			b, err := json.Marshal(text)
-			if err != nil {
-				return nil, fmt.Errorf("json.Marshal: %w", err)
+			entry := &LogEntry{Timestamp: time.Now().UTC(), Severity: severity}
+			if text != nil {
+				b, err := json.Marshal(text)
+				if err != nil {
+					return nil, fmt.Errorf("json.Marshal: %w", err)
+				}
+				log.Printf(`{"severity":%q,"textPayload":%s}`, severity, string(b))
+
+				if s, ok := text.(string); ok {
+					entry.TextPayload = s
+				} else {
+					entry.TextPayload = string(b)
+				}
+			} else {
+				b, err := json.Marshal(jsonValue)
+				if err != nil {
+					return nil, fmt.Errorf("json.Marshal: %w", err)
+				}
+				log.Printf(`{"severity":%q,"jsonPayload":%s}`, severity, string(b))
+				entry.JSONPayload = jsonValue
 			}
-			log.Printf(`{"severity":%q,"textPayload":%s}`, severity, string(b))
-		} else {
-			b, err := json.Marshal(jsonValue)
-			if err != nil {
-				return nil, fmt.Errorf("json.Marshal: %w", err)
+
+			if st != nil {
+				if recorder, ok := st.Get(types.InternalLogRecorderSymbol); ok {
+					recorder.(LogRecorder).RecordLog(entry)
+				}
 			}
-			log.Printf(`{"severity":%q,"jsonPayload":%s}`, severity, string(b))
+			return nil, nil
 		}
-		return nil, nil
 	}),
 })
@@ -18,7 +18,7 @@ var Text = aggregateFunctionsToMap("text", []types.Function{
 	types.MustNewFunction("text.decode", []types.Argument{
 		{Name: "data"},
 		{Name: "charset", Default: "UTF-8"},
-	}, func(data []byte, charset string) (string, error) {
+	}, func(data types.Bytes, charset string) (string, error) {
 		enc, err := ianaindex.IANA.Encoding(charset)
 		if err != nil {
 			return "", &types.Error{
@@ -41,7 +41,7 @@ var Text = aggregateFunctionsToMap("text", []types.Function{
 	types.MustNewFunction("text.encode", []types.Argument{
 		{Name: "data"},
 		{Name: "charset", Default: "UTF-8"},
-	}, func(data, charset string) ([]byte, error) {
+	}, func(data, charset string) (types.Bytes, error) {
 		enc, err := ianaindex.IANA.Encoding(charset)
 		if err != nil {
 			return nil, &types.Error{
@@ -59,7 +59,7 @@ var Text = aggregateFunctionsToMap("text", []types.Function{
 			}
 		}
 
-		return b.Bytes(), nil
+		return types.Bytes(b.Bytes()), nil
 	}),
 	types.MustNewFunction("text.find_all", []types.Argument{
 		{Name: "source"},
@@ -26,7 +26,20 @@ var Time = aggregateFunctionsToMap("time", []types.Function{
 			}
 		}
 
-		return t.Format(time.RFC3339Nano), nil
+		// the time zone is UTC by default as Cloud Workflows, not the local one of the emulator
+		loc := time.UTC
+		if timeZone != "" {
+			var err error
+			loc, err = time.LoadLocation(timeZone)
+			if err != nil {
+				return "", &types.Error{
+					Tag: types.ValueErrorTag,
+					Err: err,
+				}
+			}
+		}
+
+		return t.In(loc).Format(time.RFC3339Nano), nil
 	}),
 	types.MustNewFunction("time.parse", []types.Argument{
 		{Name: "value"},
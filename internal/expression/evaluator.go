@@ -1,17 +1,36 @@
 package expression
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/samber/lo"
 )
 
 type Evaluator struct {
 	SymbolTable *types.SymbolTable
 }
 
-func (e *Evaluator) EvaluateValue(expr *Expr) (ret any, err error) {
-	ret, err = expr.execute(e.SymbolTable)
+// Observer observes the values (or the errors) of the expressions evaluated by EvaluateValue.
+type Observer func(ctx context.Context, expr *Expr, value any, err error)
+
+type observerKey struct{}
+
+// WithObserver returns the context whose evaluations of the expressions are observed by the observer, e.g. to journal them.
+func WithObserver(ctx context.Context, observer Observer) context.Context {
+	return context.WithValue(ctx, observerKey{}, observer)
+}
+
+func (e *Evaluator) EvaluateValue(ctx context.Context, expr *Expr) (ret any, err error) {
+	if observer, ok := ctx.Value(observerKey{}).(Observer); ok {
+		defer func() {
+			observer(ctx, expr, ret, err)
+		}()
+	}
+
+	ret, err = expr.execute(ctx, e.SymbolTable)
 	if err != nil {
 		return
 	}
@@ -28,16 +47,16 @@ func (e *Evaluator) EvaluateValue(expr *Expr) (ret any, err error) {
 	return
 }
 
-func (e *Evaluator) EvaluateValueRecursive(value any) (any, error) {
+func (e *Evaluator) EvaluateValueRecursive(ctx context.Context, value any) (any, error) {
 	switch v := value.(type) {
 	case *Expr:
-		return e.EvaluateValue(v)
+		return e.EvaluateValue(ctx, v)
 
 	case map[string]any:
 		result := make(map[string]any, len(v))
 		for key, value := range v {
 			var err error
-			result[key], err = e.EvaluateValueRecursive(value)
+			result[key], err = e.EvaluateValueRecursive(ctx, value)
 			if err != nil {
 				return nil, fmt.Errorf("key=%q: %w", key, err)
 			}
@@ -48,7 +67,7 @@ func (e *Evaluator) EvaluateValueRecursive(value any) (any, error) {
 		result := make([]any, len(v))
 		for i, value := range v {
 			var err error
-			result[i], err = e.EvaluateValueRecursive(value)
+			result[i], err = e.EvaluateValueRecursive(ctx, value)
 			if err != nil {
 				return nil, fmt.Errorf("index=%d: %w", i, err)
 			}
@@ -60,8 +79,8 @@ func (e *Evaluator) EvaluateValueRecursive(value any) (any, error) {
 	}
 }
 
-func (e *Evaluator) ResolveReference(expr *Expr) (Reference, error) {
-	ret, err := expr.execute(e.SymbolTable)
+func (e *Evaluator) ResolveReference(ctx context.Context, expr *Expr) (Reference, error) {
+	ret, err := expr.execute(ctx, e.SymbolTable)
 	if err != nil {
 		return nil, err
 	}
@@ -74,16 +93,16 @@ func (e *Evaluator) ResolveReference(expr *Expr) (Reference, error) {
 	return ref, nil
 }
 
-func (e *Evaluator) ResolveReferenceRecursive(value any) (any, error) {
+func (e *Evaluator) ResolveReferenceRecursive(ctx context.Context, value any) (any, error) {
 	switch v := value.(type) {
 	case *Expr:
-		return e.ResolveReference(v)
+		return e.ResolveReference(ctx, v)
 
 	case map[string]any:
 		result := make(map[string]any, len(v))
 		for key, value := range v {
 			var err error
-			result[key], err = e.ResolveReferenceRecursive(value)
+			result[key], err = e.ResolveReferenceRecursive(ctx, value)
 			if err != nil {
 				return nil, fmt.Errorf("key=%q: %w", key, err)
 			}
@@ -94,7 +113,7 @@ func (e *Evaluator) ResolveReferenceRecursive(value any) (any, error) {
 		result := make([]any, len(v))
 		for i, value := range v {
 			var err error
-			result[i], err = e.ResolveReferenceRecursive(value)
+			result[i], err = e.ResolveReferenceRecursive(ctx, value)
 			if err != nil {
 				return nil, fmt.Errorf("index=%d: %w", i, err)
 			}
@@ -106,16 +125,19 @@ func (e *Evaluator) ResolveReferenceRecursive(value any) (any, error) {
 	}
 }
 
-func (e *Evaluator) LockSharedVariablesIfNeeded(exprs ...*Expr) (func(), error) {
+// LockSharedVariablesIfNeeded locks the shared variables referenced by the expressions for writing.
+// While locked, the raw values of them are visible in the current scope instead of the shared variables, and
+// the values are written through to the shared variables by calling the returned function.
+func (e *Evaluator) LockSharedVariablesIfNeeded(ctx context.Context, exprs ...*Expr) (func(), error) {
 	inheritedVariablesAny, ok := e.SymbolTable.Get(types.InternalInheritedVariablesSymbol)
 	if !ok {
 		return func() {}, nil
 	}
 	inheritedVariables := inheritedVariablesAny.(*types.InternalInheritedVariables)
 
-	unlockers := make([]func(), 0, len(exprs))
+	rootSyms := make([]string, 0, len(exprs))
 	for _, expr := range exprs {
-		ref, err := e.ResolveReference(expr)
+		ref, err := e.ResolveReference(ctx, expr)
 		if err != nil {
 			return nil, err
 		}
@@ -126,27 +148,42 @@ func (e *Evaluator) LockSharedVariablesIfNeeded(exprs ...*Expr) (func(), error)
 		}
 
 		rootSym, _ := variable.Paths()
-		if inheritedVariables.Shared[rootSym] {
-			v, ok := e.SymbolTable.Get(rootSym)
-			if !ok {
-				panic(fmt.Sprintf("assertion failure: not found shared variable=%q", rootSym))
-			}
-
-			sharedVar := v.(*types.SharedVariable)
-			sharedVar.Lock()
-			e.SymbolTable.Set(rootSym, sharedVar.Value)
-			unlockers = append(unlockers, func() {
-				sharedVar.Value, _ = e.SymbolTable.Get(rootSym)
-				e.SymbolTable.Set(rootSym, sharedVar)
-				sharedVar.Unlock()
-			})
+		if inheritedVariables.Shared[rootSym] && !lo.Contains(rootSyms, rootSym) {
+			rootSyms = append(rootSyms, rootSym)
 		}
 	}
-	if len(unlockers) == 0 {
+	if len(rootSyms) == 0 {
 		return func() {}, nil
 	}
-	if len(unlockers) == 1 {
-		return unlockers[0], nil
+
+	// lock by the stable order to avoid dead locks between branches
+	sort.Strings(rootSyms)
+
+	unlockers := make([]func(), 0, len(rootSyms))
+	for _, rootSym := range rootSyms {
+		rootSym := rootSym
+		v, ok := e.SymbolTable.Get(rootSym)
+		if !ok {
+			panic(fmt.Sprintf("assertion failure: not found shared variable=%q", rootSym))
+		}
+
+		sharedVar, ok := v.(*types.SharedVariable)
+		if !ok {
+			continue // already locked in the current scope
+		}
+
+		sharedVar.Lock()
+		prev, shadowed := e.SymbolTable.Symbols[rootSym]
+		e.SymbolTable.Symbols[rootSym] = sharedVar.Value
+		unlockers = append(unlockers, func() {
+			sharedVar.Value = e.SymbolTable.Symbols[rootSym]
+			if shadowed {
+				e.SymbolTable.Symbols[rootSym] = prev
+			} else {
+				delete(e.SymbolTable.Symbols, rootSym)
+			}
+			sharedVar.Unlock()
+		})
 	}
 
 	return func() {
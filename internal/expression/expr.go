@@ -29,6 +29,39 @@ func (e *Expr) CanReference() (ok bool) {
 	}
 }
 
+// Symbols returns the names of the symbols read by the expression (e.g. "a" and "i" of "a.b[i]") without duplicates
+// in the order of their appearance. The fields of the values are not symbols.
+func (e *Expr) Symbols() []string {
+	var names []string
+	collectSymbols(e.operation, &names)
+	return names
+}
+
+func collectSymbols(ope operation, names *[]string) {
+	switch o := ope.(type) {
+	case *retrieveSymbolOperation:
+		for _, name := range *names {
+			if name == o.name {
+				return
+			}
+		}
+		*names = append(*names, o.name)
+	case *retrieveFieldOperation:
+		collectSymbols(o.context, names)
+		collectSymbols(o.field, names)
+	case *calculateUnaryOperation:
+		collectSymbols(o.value, names)
+	case *calculateBinaryOperation:
+		collectSymbols(o.left, names)
+		collectSymbols(o.right, names)
+	case *callFunctionOperation:
+		collectSymbols(o.function, names)
+		for _, arg := range o.args {
+			collectSymbols(arg, names)
+		}
+	}
+}
+
 func (e *Expr) String() string {
 	return e.Source
 }
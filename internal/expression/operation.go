@@ -1,6 +1,8 @@
 package expression
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"math"
 	"reflect"
@@ -9,14 +11,14 @@ import (
 )
 
 type operation interface {
-	execute(*types.SymbolTable) (any, error)
+	execute(context.Context, *types.SymbolTable) (any, error)
 }
 
 var nullLiteralOperation = nullLiteralOperationTyp{}
 
 type nullLiteralOperationTyp struct{}
 
-func (s nullLiteralOperationTyp) execute(*types.SymbolTable) (any, error) {
+func (s nullLiteralOperationTyp) execute(context.Context, *types.SymbolTable) (any, error) {
 	return nil, nil
 }
 
@@ -24,7 +26,7 @@ type valueOperation[T any] struct {
 	value T
 }
 
-func (s *valueOperation[T]) execute(*types.SymbolTable) (any, error) {
+func (s *valueOperation[T]) execute(context.Context, *types.SymbolTable) (any, error) {
 	return s.value, nil
 }
 
@@ -37,7 +39,7 @@ type retrieveSymbolOperation struct {
 	name string
 }
 
-func (s *retrieveSymbolOperation) execute(*types.SymbolTable) (any, error) {
+func (s *retrieveSymbolOperation) execute(context.Context, *types.SymbolTable) (any, error) {
 	return &symbolReference{name: s.name}, nil
 }
 
@@ -46,13 +48,13 @@ type retrieveFieldOperation struct {
 	field   operation
 }
 
-func (s *retrieveFieldOperation) execute(st *types.SymbolTable) (any, error) {
-	rawContext, err := s.context.execute(st)
+func (s *retrieveFieldOperation) execute(ctx context.Context, st *types.SymbolTable) (any, error) {
+	rawContext, err := s.context.execute(ctx, st)
 	if err != nil {
 		return nil, fmt.Errorf("invalid context: %w", err)
 	}
 
-	rawField, err := s.field.execute(st)
+	rawField, err := s.field.execute(ctx, st)
 	if err != nil {
 		return nil, fmt.Errorf("invalid field: %w", err)
 	}
@@ -91,8 +93,8 @@ type calculateUnaryOperation struct {
 	value    operation
 }
 
-func (s *calculateUnaryOperation) execute(st *types.SymbolTable) (any, error) {
-	value, err := s.value.execute(st)
+func (s *calculateUnaryOperation) execute(ctx context.Context, st *types.SymbolTable) (any, error) {
+	value, err := s.value.execute(ctx, st)
 	if err != nil {
 		return nil, fmt.Errorf("value of unary operator %q: %w", s.operator, err)
 	}
@@ -158,8 +160,8 @@ type calculateBinaryOperation struct {
 	right    operation
 }
 
-func (s *calculateBinaryOperation) execute(st *types.SymbolTable) (any, error) {
-	left, err := s.left.execute(st)
+func (s *calculateBinaryOperation) execute(ctx context.Context, st *types.SymbolTable) (any, error) {
+	left, err := s.left.execute(ctx, st)
 	if err != nil {
 		return nil, fmt.Errorf("left of operator %q: %w", s.operator, err)
 	}
@@ -171,7 +173,7 @@ func (s *calculateBinaryOperation) execute(st *types.SymbolTable) (any, error) {
 		left = v.Get()
 	}
 
-	right, err := s.right.execute(st)
+	right, err := s.right.execute(ctx, st)
 	if err != nil {
 		return nil, fmt.Errorf("right of operator %q: %w", s.operator, err)
 	}
@@ -490,6 +492,28 @@ func (s *calculateBinaryOperation) execute(st *types.SymbolTable) (any, error) {
 			}
 		}
 
+	case types.Bytes:
+		switch rhs := right.(type) {
+		case types.Bytes:
+			switch s.operator {
+			case "==":
+				return bytes.Equal(lhs, rhs), nil
+			case "!=":
+				return !bytes.Equal(lhs, rhs), nil
+			default:
+				return nil, &types.Error{
+					Tag: types.TypeErrorTag,
+					Err: fmt.Errorf("invalid operator %q for left=%T right=%T", s.operator, left, right),
+				}
+			}
+
+		default:
+			return nil, &types.Error{
+				Tag: types.TypeErrorTag,
+				Err: fmt.Errorf("unknown right value type of operator %q: %T", s.operator, right),
+			}
+		}
+
 	default:
 		return nil, &types.Error{
 			Tag: types.TypeErrorTag,
@@ -503,8 +527,8 @@ type callFunctionOperation struct {
 	args     []operation
 }
 
-func (s *callFunctionOperation) execute(st *types.SymbolTable) (any, error) {
-	value, err := s.function.execute(st)
+func (s *callFunctionOperation) execute(ctx context.Context, st *types.SymbolTable) (any, error) {
+	value, err := s.function.execute(ctx, st)
 	if err != nil {
 		return nil, err
 	}
@@ -525,7 +549,7 @@ func (s *callFunctionOperation) execute(st *types.SymbolTable) (any, error) {
 	}
 
 	type function interface {
-		Call([]any) (any, error)
+		Call(context.Context, []any) (any, error)
 	}
 	f, ok := value.(function)
 	if !ok {
@@ -538,7 +562,7 @@ func (s *callFunctionOperation) execute(st *types.SymbolTable) (any, error) {
 	args := make([]any, len(s.args))
 	for i, arg := range s.args {
 		var err error
-		args[i], err = arg.execute(st)
+		args[i], err = arg.execute(ctx, st)
 		if err != nil {
 			return nil, fmt.Errorf("%s args[%d]: %w", path, i, err)
 		}
@@ -552,7 +576,12 @@ func (s *callFunctionOperation) execute(st *types.SymbolTable) (any, error) {
 		}
 	}
 
-	ret, err := f.Call(args)
+	var ret any
+	if sf, ok := f.(types.ScopedFunction); ok {
+		ret, err = sf.CallInScope(ctx, st, args)
+	} else {
+		ret, err = f.Call(ctx, args)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", path, err)
 	}
@@ -1,6 +1,7 @@
 package expression_test
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"reflect"
@@ -13,7 +14,7 @@ import (
 
 type function func([]any) (any, error)
 
-func (f function) Call(args []any) (any, error) {
+func (f function) Call(_ context.Context, args []any) (any, error) {
 	return f(args)
 }
 
@@ -656,6 +657,35 @@ func TestParseExpr(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			source: `a == b`,
+			symbols: &types.SymbolTable{
+				Symbols: map[string]any{
+					"a": types.Bytes("ok"),
+					"b": types.Bytes("ok"),
+				},
+			},
+			expected: true,
+		},
+		{
+			source: `a != b`,
+			symbols: &types.SymbolTable{
+				Symbols: map[string]any{
+					"a": types.Bytes("ok"),
+					"b": types.Bytes("ng"),
+				},
+			},
+			expected: true,
+		},
+		{
+			source: `a == "ok"`,
+			symbols: &types.SymbolTable{
+				Symbols: map[string]any{
+					"a": types.Bytes("ok"),
+				},
+			},
+			expectToBeEvaluateErr: true,
+		},
 		{
 			source: `"b" in map`,
 			symbols: &types.SymbolTable{
@@ -822,7 +852,7 @@ func TestParseExpr(t *testing.T) {
 			}
 
 			e := expression.Evaluator{SymbolTable: tt.symbols}
-			ret, err := e.EvaluateValue(expr)
+			ret, err := e.EvaluateValue(context.Background(), expr)
 			if err != nil {
 				if tt.expectToBeEvaluateErr {
 					t.Logf("expected evaluate error: %v", err)
@@ -862,6 +892,85 @@ func TestParseExpr(t *testing.T) {
 	}
 }
 
+func TestExprSymbols(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		source   string
+		expected []string
+	}{
+		{source: "1 + 2", expected: nil},
+		{source: "a", expected: []string{"a"}},
+		{source: "a.b.c", expected: []string{"a"}},
+		{source: "a[i] + a[j]", expected: []string{"a", "i", "j"}},
+		{source: "not (x in keys(m))", expected: []string{"x", "keys", "m"}},
+		{source: "sys.get_env(name)", expected: []string{"sys", "name"}},
+	} {
+		tt := tt
+		t.Run(tt.source, func(t *testing.T) {
+			t.Parallel()
+
+			expr, err := expression.ParseExpr(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, expr.Symbols()); diff != "" {
+				t.Errorf("unexpected symbols (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExprCheckTypes(t *testing.T) {
+	t.Parallel()
+
+	symbols := &types.SymbolTable{
+		Symbols: map[string]any{
+			"text": map[string]any{
+				"split": types.MustNewFunction("text.split", []types.Argument{{Name: "source"}, {Name: "separator"}}, func(source, separator string) ([]any, error) {
+					return nil, nil
+				}),
+			},
+		},
+	}
+	variables := map[string]types.ValueType{"s": types.StringType, "n": types.IntegerType, "v": types.UnknownType}
+	for _, tt := range []struct {
+		source      string
+		expected    types.ValueType
+		expectToErr bool
+	}{
+		{source: "1 + 2", expected: types.IntegerType},
+		{source: "1 / 2", expected: types.DoubleType},
+		{source: "n + 1.5", expected: types.DoubleType},
+		{source: `s + "a"`, expected: types.StringType},
+		{source: `"a" + 1`, expectToErr: true},
+		{source: "s + n", expectToErr: true},
+		{source: "s == null", expected: types.BooleanType},
+		{source: "v + 1", expected: types.UnknownType},
+		{source: "not n", expectToErr: true},
+		{source: `text.split(s, ",")`, expected: types.ListType},
+		{source: "text.split(s)", expected: types.ListType, expectToErr: true},
+		{source: `text.split(n, ",")`, expected: types.ListType, expectToErr: true},
+	} {
+		tt := tt
+		t.Run(tt.source, func(t *testing.T) {
+			t.Parallel()
+
+			expr, err := expression.ParseExpr(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, errs := expr.CheckTypes(symbols, variables)
+			if tt.expectToErr != (len(errs) != 0) {
+				t.Errorf("unexpected errors: %v", errs)
+			}
+			if actual != tt.expected {
+				t.Errorf("expect to %s but got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
 func FuzzParseExpr(f *testing.F) {
 	f.Fuzz(func(t *testing.T, source string) {
 		_, err := expression.ParseExpr(source)
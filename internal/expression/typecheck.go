@@ -0,0 +1,205 @@
+package expression
+
+import (
+	"fmt"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// CheckTypes infers the type of the expression from the literals, the types of the variables and the values of the
+// symbol table (e.g. the signatures of the functions of the standard library), and returns the type errors found without
+// evaluating it. The operations of the unknown types are not checked.
+func (e *Expr) CheckTypes(st *types.SymbolTable, variables map[string]types.ValueType) (types.ValueType, []error) {
+	c := &typeChecker{symbolTable: st, variables: variables}
+	return c.infer(e.operation), c.errors
+}
+
+type typeChecker struct {
+	symbolTable *types.SymbolTable
+	variables   map[string]types.ValueType
+	errors      []error
+}
+
+func (c *typeChecker) report(format string, args ...any) {
+	c.errors = append(c.errors, fmt.Errorf(format, args...))
+}
+
+func (c *typeChecker) infer(ope operation) types.ValueType {
+	switch o := ope.(type) {
+	case nullLiteralOperationTyp:
+		return types.NullType
+	case *stringLiteralOperation:
+		return types.StringType
+	case *booleanLiteralOperation:
+		return types.BooleanType
+	case *int64LiteralOperation:
+		return types.IntegerType
+	case *float64LiteralOperation:
+		return types.DoubleType
+
+	case *retrieveSymbolOperation:
+		if t, ok := c.variables[o.name]; ok {
+			return t
+		}
+		if v, ok := c.symbolTable.Get(o.name); ok {
+			return types.TypeOf(v)
+		}
+		return types.UnknownType
+
+	case *retrieveFieldOperation:
+		if v, ok := c.staticValue(o); ok {
+			return types.TypeOf(v)
+		}
+		switch context := c.infer(o.context); context {
+		case types.UnknownType, types.MapType, types.ListType:
+			// ok
+		default:
+			c.report("retrieve field of %s", context)
+		}
+		switch field := c.infer(o.field); field {
+		case types.UnknownType, types.StringType, types.IntegerType:
+			// ok
+		default:
+			c.report("retrieve field by %s", field)
+		}
+		return types.UnknownType
+
+	case *calculateUnaryOperation:
+		value := c.infer(o.value)
+		if value == types.UnknownType {
+			if o.operator == "not" {
+				return types.BooleanType
+			}
+			return types.UnknownType
+		}
+		if (o.operator == "not" && value == types.BooleanType) || (o.operator != "not" && isNumberType(value)) {
+			return value
+		}
+		c.report("invalid value type for unary operator %q: %s", o.operator, value)
+		return types.UnknownType
+
+	case *calculateBinaryOperation:
+		left, right := c.infer(o.left), c.infer(o.right)
+		t, ok := binaryOperationType(o.operator, left, right)
+		if !ok {
+			c.report("invalid operator %q for left=%s right=%s", o.operator, left, right)
+		}
+		return t
+
+	case *callFunctionOperation:
+		args := make([]types.ValueType, len(o.args))
+		for i, arg := range o.args {
+			args[i] = c.infer(arg)
+		}
+
+		value, ok := c.staticValue(o.function)
+		if !ok {
+			return types.UnknownType
+		}
+		f, ok := value.(types.TypedFunction)
+		if !ok {
+			if _, isFunction := value.(types.Function); !isFunction {
+				c.report("not a function: %s", types.TypeOf(value))
+			}
+			return types.UnknownType
+		}
+
+		signature := f.Signature()
+		if len(args) > len(signature.Args) {
+			c.report("%s: too many arguments: %d arguments are allowed but got %d arguments", f.Name(), len(signature.Args), len(args))
+		} else if len(args) < signature.MinimumArgs {
+			c.report("%s: missing arguments: %d arguments are required but got %d arguments", f.Name(), signature.MinimumArgs, len(args))
+		}
+		for i, arg := range args {
+			if i < len(signature.Args) && !arg.AssignableTo(signature.Args[i]) {
+				c.report("%s: invalid argument[%d] %s: expected type is %s but actual %s", f.Name(), i, f.Args()[i], signature.Args[i], arg)
+			}
+		}
+		return signature.Result
+
+	default:
+		return types.UnknownType
+	}
+}
+
+// staticValue returns the value of the symbol or the field of it in the symbol table unless it is a variable.
+func (c *typeChecker) staticValue(ope operation) (any, bool) {
+	switch o := ope.(type) {
+	case *retrieveSymbolOperation:
+		if _, ok := c.variables[o.name]; ok {
+			return nil, false
+		}
+		return c.symbolTable.Get(o.name)
+
+	case *retrieveFieldOperation:
+		field, ok := o.field.(*stringLiteralOperation)
+		if !ok {
+			return nil, false
+		}
+		context, ok := c.staticValue(o.context)
+		if !ok {
+			return nil, false
+		}
+		m, ok := context.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value, ok := m[field.value]
+		return value, ok
+
+	default:
+		return nil, false
+	}
+}
+
+func isNumberType(t types.ValueType) bool {
+	return t == types.IntegerType || t == types.DoubleType
+}
+
+// binaryOperationType returns the type of the result of the operator for the types of the operands as the evaluation,
+// or false if the operator raises TypeError for them.
+// refs. https://cloud.google.com/workflows/docs/reference/syntax/datatypes#implicit-conversions
+func binaryOperationType(operator string, left, right types.ValueType) (types.ValueType, bool) {
+	var result types.ValueType
+	switch operator {
+	case "==", "!=", ">", ">=", "<", "<=", "in", "and", "or":
+		result = types.BooleanType
+	case "//", "%":
+		result = types.IntegerType
+	case "/":
+		result = types.DoubleType
+	case "+", "-", "*":
+		if left == types.IntegerType && right == types.IntegerType {
+			result = types.IntegerType
+		} else if isNumberType(left) && isNumberType(right) {
+			result = types.DoubleType
+		} else if operator == "+" && left == types.StringType && right == types.StringType {
+			result = types.StringType
+		}
+	}
+	if left == types.UnknownType || right == types.UnknownType {
+		return result, true
+	}
+	if (operator == "==" || operator == "!=") && (left == types.NullType || right == types.NullType) {
+		return result, true
+	}
+
+	switch {
+	case left == types.BooleanType && right == types.BooleanType:
+		return result, operator == "==" || operator == "!=" || operator == "and" || operator == "or"
+	case right == types.ListType && operator == "in":
+		return result, left == types.BooleanType || left == types.StringType || isNumberType(left)
+	case left == types.StringType && right == types.StringType:
+		return result, operator == "==" || operator == "!=" || operator == "+"
+	case left == types.StringType && right == types.MapType:
+		return result, operator == "in"
+	case left == types.IntegerType && right == types.IntegerType:
+		return result, operator != "in" && operator != "and" && operator != "or"
+	case isNumberType(left) && isNumberType(right):
+		return result, operator != "in" && operator != "and" && operator != "or" && operator != "%"
+	case left == types.BytesType && right == types.BytesType:
+		return result, operator == "==" || operator == "!="
+	default:
+		return types.UnknownType, false
+	}
+}
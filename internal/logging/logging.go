@@ -0,0 +1,88 @@
+// Package logging writes the leveled logs of the emulator in the logfmt like
+// `level=WARN msg="failed to decode request body" error="EOF"`, which are filtered by --log-level.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the minimum level of the logs.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return strconv.Itoa(int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of the level (debug, info, warn or error) case-insensitively.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level: %q", s)
+}
+
+var currentLevel atomic.Int32
+
+func init() {
+	currentLevel.Store(int32(LevelInfo))
+}
+
+// SetLevel sets the minimum level of the logs. The default is INFO.
+func SetLevel(level Level) {
+	currentLevel.Store(int32(level))
+}
+
+// Enabled reports whether the logs of the level are written.
+func Enabled(level Level) bool {
+	return int32(level) >= currentLevel.Load()
+}
+
+// Logf writes the log if the level is enabled. The key-value pairs follow the message.
+func Logf(level Level, msg string, keyValues ...any) {
+	if !Enabled(level) {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(msg))
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		fmt.Fprintf(&b, " %s=%s", keyValues[i], logfmtValue(fmt.Sprint(keyValues[i+1])))
+	}
+	log.Print(b.String())
+}
+
+// Text writes the multi-line text (e.g. a diff) as is if the level is enabled.
+func Text(level Level, text string) {
+	if !Enabled(level) || text == "" {
+		return
+	}
+	log.Print("\n" + text)
+}
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \"=\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
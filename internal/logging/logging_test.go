@@ -0,0 +1,107 @@
+package logging_test
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+)
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		expected logging.Level
+		wantErr  bool
+	}{
+		{name: "debug", expected: logging.LevelDebug},
+		{name: "INFO", expected: logging.LevelInfo},
+		{name: "Warn", expected: logging.LevelWarn},
+		{name: "error", expected: logging.LevelError},
+		{name: "fatal", wantErr: true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			level, err := logging.ParseLevel(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if level != tt.expected {
+				t.Errorf("unexpected level: %s, want %s", level, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLogf(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+		logging.SetLevel(logging.LevelInfo)
+	})
+
+	for _, tt := range []struct {
+		name     string
+		level    logging.Level
+		logLevel logging.Level
+		msg      string
+		kv       []any
+		expected string
+	}{
+		{
+			name:     "message",
+			level:    logging.LevelInfo,
+			logLevel: logging.LevelInfo,
+			msg:      "listening",
+			expected: "level=INFO msg=listening\n",
+		},
+		{
+			name:     "quoted values",
+			level:    logging.LevelError,
+			logLevel: logging.LevelInfo,
+			msg:      "failed to read",
+			kv:       []any{"error", `unexpected "EOF"`, "status", 500},
+			expected: `level=ERROR msg="failed to read" error="unexpected \"EOF\"" status=500` + "\n",
+		},
+		{
+			name:     "filtered",
+			level:    logging.LevelDebug,
+			logLevel: logging.LevelInfo,
+			msg:      "callback",
+		},
+		{
+			name:     "enabled debug",
+			level:    logging.LevelDebug,
+			logLevel: logging.LevelDebug,
+			msg:      "callback",
+			kv:       []any{"url", "http://localhost/callbacks/1"},
+			expected: "level=DEBUG msg=callback url=http://localhost/callbacks/1\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			logging.SetLevel(tt.logLevel)
+			logging.Logf(tt.level, tt.msg, tt.kv...)
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("unexpected log (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
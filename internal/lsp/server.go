@@ -0,0 +1,324 @@
+// Package lsp serves the Language Server Protocol for the workflow files, which reports the errors and the warnings of
+// the validation as the diagnostics, and answers the hovers of the functions of the standard library and the
+// definitions of the steps and the subworkflows.
+// refs. https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// Server is the language server of the workflow files opened by the editor.
+type Server struct {
+	opts      []workflow.ValidateOption
+	documents map[string]string // by the URIs
+	w         io.Writer
+}
+
+// NewServer creates a server validating the workflows by the options.
+func NewServer(opts ...workflow.ValidateOption) *Server {
+	return &Server{opts: opts, documents: map[string]string{}}
+}
+
+// Serve handles the messages from r and writes the responses to w until the exit notification or the end of r.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	reader := bufio.NewReader(r)
+	for {
+		body, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("readMessage: %w", err)
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req *request) error {
+	var (
+		result any
+		err    error
+	)
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // full
+				"hoverProvider":      true,
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "google-cloud-workflow-emulator"},
+		}
+	case "shutdown":
+		// nothing to clean up before the exit notification
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			return s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil && len(params.ContentChanges) != 0 {
+			// the changes are the whole documents by the full sync
+			return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			return s.notify("textDocument/publishDiagnostics", map[string]any{"uri": params.TextDocument.URI, "diagnostics": []any{}})
+		}
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.hover(&params)
+		}
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.definition(&params)
+		}
+	default:
+		if req.isNotification() {
+			return nil // e.g. initialized and $/cancelRequest
+		}
+		return s.replyError(req.ID, codeMethodNotFound, "method not found: "+req.Method)
+	}
+
+	if req.isNotification() {
+		return nil
+	}
+	if err != nil {
+		return s.replyError(req.ID, codeInvalidParams, err.Error())
+	}
+	return writeMessage(s.w, &response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func (s *Server) replyError(id json.RawMessage, code int, message string) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return writeMessage(s.w, &errorResponse{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message}})
+}
+
+func (s *Server) notify(method string, params any) error {
+	return writeMessage(s.w, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`      // from 0
+	Character int `json:"character"` // from 0
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+func newRange(pos *workflow.Position) lspRange {
+	start := lspPosition{Line: pos.Line - 1, Character: pos.Column - 1}
+	return lspRange{Start: start, End: lspPosition{Line: start.Line, Character: start.Character + pos.Length}}
+}
+
+type textDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+// update validates the document, and publishes the errors and the warnings of it as the diagnostics.
+func (s *Server) update(uri, text string) error {
+	s.documents[uri] = text
+
+	validate := workflow.ValidateWorkflowYAML
+	if strings.HasSuffix(uri, ".json") {
+		validate = workflow.ValidateWorkflowJSON
+	}
+	errs, err := validate(strings.NewReader(text), s.opts...)
+	if err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	diagnostics := make([]map[string]any, 0, len(errs))
+	for _, e := range errs {
+		severity := 1 // error
+		if e.Warning {
+			severity = 2 // warning
+		}
+		pos := e.Position
+		message := strings.TrimPrefix(strings.TrimPrefix(e.Error(), positionPrefix(pos)), "warning: ")
+		if pos == nil {
+			pos, message = syntaxErrorPosition(message)
+		}
+		diagnostics = append(diagnostics, map[string]any{
+			"range":    newRange(pos),
+			"severity": severity,
+			"source":   "google-cloud-workflow-emulator",
+			"message":  message,
+		})
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": diagnostics})
+}
+
+func positionPrefix(pos *workflow.Position) string {
+	if pos == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d: ", pos.Line, pos.Column)
+}
+
+// syntaxErrorRegexp matches the position of the syntax errors of the YAML, e.g. "[29:5] unexpected mapping key".
+var syntaxErrorRegexp = regexp.MustCompile(`\[(\d+):(\d+)\] `)
+
+// syntaxErrorPosition returns the position of the error of the whole workflow (or the top of the document if unknown),
+// and the message without the source excerpt following it.
+func syntaxErrorPosition(message string) (*workflow.Position, string) {
+	message = strings.SplitN(message, "\n", 2)[0]
+	pos := &workflow.Position{Line: 1, Column: 1}
+	if m := syntaxErrorRegexp.FindStringSubmatch(message); m != nil {
+		pos.Line, _ = strconv.Atoi(m[1])
+		pos.Column, _ = strconv.Atoi(m[2])
+		pos.Length = 1
+	}
+	return pos, message
+}
+
+// wordAt returns the dotted name (e.g. http.get or a step name) at the position of the document, and its range.
+func (s *Server) wordAt(params *textDocumentPositionParams) (string, lspRange, bool) {
+	text, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return "", lspRange{}, false
+	}
+	lines := strings.Split(text, "\n")
+	if params.Position.Line < 0 || params.Position.Line >= len(lines) {
+		return "", lspRange{}, false
+	}
+	line := []rune(lines[params.Position.Line])
+	if params.Position.Character < 0 || params.Position.Character > len(line) {
+		return "", lspRange{}, false
+	}
+
+	start, end := params.Position.Character, params.Position.Character
+	for start > 0 && isWordRune(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordRune(line[end]) {
+		end++
+	}
+	word := strings.Trim(string(line[start:end]), ".")
+	if word == "" {
+		return "", lspRange{}, false
+	}
+	return word, lspRange{
+		Start: lspPosition{Line: params.Position.Line, Character: start},
+		End:   lspPosition{Line: params.Position.Line, Character: end},
+	}, true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '-' || r == '.' || ('0' <= r && r <= '9') || ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z')
+}
+
+// hover returns the signature of the function of the standard library at the position, or nil.
+func (s *Server) hover(params *textDocumentPositionParams) any {
+	word, r, ok := s.wordAt(params)
+	if !ok {
+		return nil
+	}
+	f, ok := workflow.LookupFunction(word)
+	if !ok {
+		return nil
+	}
+
+	var b bytes.Buffer
+	b.WriteString("```\n")
+	b.WriteString(formatSignature(f))
+	b.WriteString("\n```\n")
+	if !strings.HasPrefix(f.Name(), "googleapis.") {
+		fmt.Fprintf(&b, "\n[Reference](https://cloud.google.com/workflows/docs/reference/stdlib/%s)\n", strings.ReplaceAll(f.Name(), ".", "/"))
+	}
+	return map[string]any{
+		"contents": map[string]any{"kind": "markdown", "value": b.String()},
+		"range":    r,
+	}
+}
+
+// formatSignature formats the function like "http.get(url: string, timeout?: double, ...) -> map".
+func formatSignature(f types.Function) string {
+	tf, typed := f.(types.TypedFunction)
+	var signature types.Signature
+	if typed {
+		signature = tf.Signature()
+	}
+
+	args := make([]string, len(f.Args()))
+	for i, name := range f.Args() {
+		if typed && i >= signature.MinimumArgs {
+			name += "?"
+		}
+		if typed && i < len(signature.Args) && signature.Args[i] != types.UnknownType {
+			name += ": " + signature.Args[i].String()
+		}
+		args[i] = name
+	}
+	s := f.Name() + "(" + strings.Join(args, ", ") + ")"
+	if typed && signature.Result != types.UnknownType {
+		s += " -> " + signature.Result.String()
+	}
+	return s
+}
+
+// definition returns the location of the step or the subworkflow named at the position, or nil.
+func (s *Server) definition(params *textDocumentPositionParams) any {
+	word, _, ok := s.wordAt(params)
+	if !ok {
+		return nil
+	}
+	pos := workflow.FindDefinition([]byte(s.documents[params.TextDocument.URI]), params.Position.Line+1, word)
+	if pos == nil {
+		return nil
+	}
+	return map[string]any{"uri": params.TextDocument.URI, "range": newRange(pos)}
+}
@@ -0,0 +1,220 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/lsp"
+)
+
+const testDocument = `main:
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: https://example.com
+        next: done
+    - done:
+        call: sub
+sub:
+  steps:
+    - r:
+        return: ok
+`
+
+// frame frames the JSON-RPC messages by the Content-Length headers.
+func frame(t *testing.T, messages ...any) io.Reader {
+	t.Helper()
+
+	var b bytes.Buffer
+	for _, message := range messages {
+		body, ok := message.(string) // the raw body
+		if !ok {
+			raw, err := json.Marshal(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = string(raw)
+		}
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	return &b
+}
+
+// readMessages reads the messages framed by the Content-Length headers.
+func readMessages(t *testing.T, r io.Reader) []any {
+	t.Helper()
+
+	messages := []any{}
+	reader := bufio.NewReader(r)
+	for {
+		header, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if errors.Is(err, io.EOF) {
+			return messages
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			t.Fatal(err)
+		}
+		var message any
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, message)
+	}
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notification(method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func didOpen(uri, text string) map[string]any {
+	return notification("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "yaml", "version": 1, "text": text}})
+}
+
+func position(id int, method, uri string, line, character int) map[string]any {
+	return request(id, method, map[string]any{"textDocument": map[string]any{"uri": uri}, "position": map[string]any{"line": line, "character": character}})
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		messages []any
+		expected []string // the JSON of the messages from the server
+	}{
+		{
+			name:     "initialize",
+			messages: []any{request(1, "initialize", map[string]any{}), notification("initialized", map[string]any{}), request(2, "shutdown", nil), notification("exit", nil)},
+			expected: []string{
+				`{"id":1,"jsonrpc":"2.0","result":{"capabilities":{"definitionProvider":true,"hoverProvider":true,"textDocumentSync":1},"serverInfo":{"name":"google-cloud-workflow-emulator"}}}`,
+				`{"id":2,"jsonrpc":"2.0","result":null}`,
+			},
+		},
+		{
+			name:     "diagnostics of the errors and the warnings",
+			messages: []any{didOpen("file:///wf.yaml", "main:\n  steps:\n    - bad:\n        call: no.such.function\nsub:\n  steps:\n    - r:\n        return: ok\n")},
+			expected: []string{
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///wf.yaml","diagnostics":[{"message":"main > bad: unknown call \"no.such.function\"","range":{"start":{"line":2,"character":6},"end":{"line":2,"character":9}},"severity":1,"source":"google-cloud-workflow-emulator"},{"message":"sub: subworkflow is never called","range":{"start":{"line":4,"character":0},"end":{"line":4,"character":3}},"severity":2,"source":"google-cloud-workflow-emulator"}]}}`,
+			},
+		},
+		{
+			name:     "diagnostics of the syntax error",
+			messages: []any{didOpen("file:///wf.yaml", "main:\n  steps: [\n    {a: 1\n")},
+			expected: []string{
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///wf.yaml","diagnostics":[{"message":"yaml.YAMLToJSON: [3:5] unterminated flow mapping","range":{"start":{"line":2,"character":4},"end":{"line":2,"character":5}},"severity":1,"source":"google-cloud-workflow-emulator"}]}}`,
+			},
+		},
+		{
+			name: "diagnostics cleared by the change and the close",
+			messages: []any{
+				didOpen("file:///wf.yaml", "main:\n  steps:\n    - bad:\n        call: no.such.function\n"),
+				notification("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": "file:///wf.yaml", "version": 2}, "contentChanges": []any{map[string]any{"text": testDocument}}}),
+				notification("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": "file:///wf.yaml"}}),
+			},
+			expected: []string{
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///wf.yaml","diagnostics":[{"message":"main > bad: unknown call \"no.such.function\"","range":{"start":{"line":2,"character":6},"end":{"line":2,"character":9}},"severity":1,"source":"google-cloud-workflow-emulator"}]}}`,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[],"uri":"file:///wf.yaml"}}`,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[],"uri":"file:///wf.yaml"}}`,
+			},
+		},
+		{
+			name:     "hover on the function",
+			messages: []any{didOpen("file:///wf.yaml", testDocument), position(1, "textDocument/hover", "file:///wf.yaml", 3, 16)},
+			expected: []string{
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[],"uri":"file:///wf.yaml"}}`,
+				`{"id":1,"jsonrpc":"2.0","result":{"contents":{"kind":"markdown","value":"\u0060\u0060\u0060\nhttp.get(url: string, timeout?, headers?: map, query?: map, auth?: map) -> map\n\u0060\u0060\u0060\n\n[Reference](https://cloud.google.com/workflows/docs/reference/stdlib/http/get)\n"},"range":{"start":{"line":3,"character":14},"end":{"line":3,"character":22}}}}`,
+			},
+		},
+		{
+			name:     "hover on the others",
+			messages: []any{didOpen("file:///wf.yaml", testDocument), position(1, "textDocument/hover", "file:///wf.yaml", 6, 16)},
+			expected: []string{
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[],"uri":"file:///wf.yaml"}}`,
+				`{"id":1,"jsonrpc":"2.0","result":null}`,
+			},
+		},
+		{
+			name:     "definition of the step",
+			messages: []any{didOpen("file:///wf.yaml", testDocument), position(1, "textDocument/definition", "file:///wf.yaml", 6, 16)},
+			expected: []string{
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[],"uri":"file:///wf.yaml"}}`,
+				`{"id":1,"jsonrpc":"2.0","result":{"uri":"file:///wf.yaml","range":{"start":{"line":7,"character":6},"end":{"line":7,"character":10}}}}`,
+			},
+		},
+		{
+			name:     "definition of the subworkflow",
+			messages: []any{didOpen("file:///wf.yaml", testDocument), position(1, "textDocument/definition", "file:///wf.yaml", 8, 15)},
+			expected: []string{
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[],"uri":"file:///wf.yaml"}}`,
+				`{"id":1,"jsonrpc":"2.0","result":{"uri":"file:///wf.yaml","range":{"start":{"line":9,"character":0},"end":{"line":9,"character":3}}}}`,
+			},
+		},
+		{
+			name:     "definition in the unknown document",
+			messages: []any{position(1, "textDocument/definition", "file:///unknown.yaml", 0, 0)},
+			expected: []string{
+				`{"id":1,"jsonrpc":"2.0","result":null}`,
+			},
+		},
+		{
+			name:     "unknown method",
+			messages: []any{request(1, "workspace/symbol", map[string]any{})},
+			expected: []string{
+				`{"id":1,"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found: workspace/symbol"}}`,
+			},
+		},
+		{
+			name:     "invalid params",
+			messages: []any{request(1, "textDocument/hover", []any{})},
+			expected: []string{
+				`{"id":1,"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid character '[' looking for beginning of value"}}`,
+			},
+		},
+		{
+			name:     "invalid JSON",
+			messages: []any{`{"jsonrpc":`},
+			expected: []string{
+				`{"id":null,"jsonrpc":"2.0","error":{"code":-32700,"message":"json: null unexpected end of JSON input"}}`,
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			if err := lsp.NewServer().Serve(frame(t, tt.messages...), &out); err != nil {
+				t.Fatal(err)
+			}
+			expected := make([]any, len(tt.expected))
+			for i, message := range tt.expected {
+				if err := json.Unmarshal([]byte(message), &expected[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if diff := cmp.Diff(expected, readMessages(t, &out)); diff != "" {
+				t.Errorf("unexpected messages (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package plugin loads the custom functions written in the scripts by the plugins file, e.g. to stub the
+// project-specific helpers without writing Go.
+//
+//	# plugins.yaml
+//	company.text.slugify:
+//	  starlark: ./plugins/text.star # relative to the plugins file
+//	  function: slugify             # (optional) the last part of the name by default
+//	company.text.hash:
+//	  wasm: ./plugins/text.wasm     # the module of the JSON-in/JSON-out ABI (see wasm.go)
+//	  params: [source, algorithm]   # the names of the arguments
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/tetratelabs/wazero"
+)
+
+// pluginDef is the definition of a function of the plugins, which has the script implementing it.
+type pluginDef struct {
+	Starlark string   `json:"starlark"`
+	WASM     string   `json:"wasm"`
+	Function string   `json:"function"`
+	Params   []string `json:"params"`
+}
+
+// Load loads the functions of the plugins file in YAML by their dotted names, which are registered by
+// workflow.WithSymbols.
+func Load(filePath string) (map[string]any, error) {
+	yamlBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
+	}
+
+	var defs map[string]*pluginDef
+	if err = json.Unmarshal(jsonBytes, &defs); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	ctx := context.Background()
+	dir := filepath.Dir(filePath)
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	var runtime wazero.Runtime
+	scripts := map[string]*starlarkScript{}
+	modules := map[string]*wasmModule{}
+	functions := make(map[string]any, len(defs))
+	for name, def := range defs {
+		function := def.Function
+		if function == "" {
+			function = name[strings.LastIndex(name, ".")+1:]
+		}
+
+		switch {
+		case def.Starlark != "" && def.WASM != "":
+			return nil, fmt.Errorf("invalid plugin %s: starlark and wasm are exclusive", name)
+
+		case def.Starlark != "":
+			path := resolve(def.Starlark)
+			script, ok := scripts[path]
+			if !ok {
+				script, err = loadStarlarkScript(path)
+				if err != nil {
+					return nil, fmt.Errorf("invalid plugin %s: %w", name, err)
+				}
+				scripts[path] = script
+			}
+
+			f, err := script.function(name, function)
+			if err != nil {
+				return nil, fmt.Errorf("invalid plugin %s: %w", name, err)
+			}
+			functions[name] = f
+
+		case def.WASM != "":
+			path := resolve(def.WASM)
+			module, ok := modules[path]
+			if !ok {
+				if runtime == nil {
+					runtime, err = newWASMRuntime(ctx)
+					if err != nil {
+						return nil, fmt.Errorf("invalid plugin %s: %w", name, err)
+					}
+				}
+				module, err = loadWASMModule(ctx, runtime, path)
+				if err != nil {
+					return nil, fmt.Errorf("invalid plugin %s: %w", name, err)
+				}
+				modules[path] = module
+			}
+
+			f, err := module.function(name, function, def.Params)
+			if err != nil {
+				return nil, fmt.Errorf("invalid plugin %s: %w", name, err)
+			}
+			functions[name] = f
+
+		default:
+			return nil, fmt.Errorf("invalid plugin %s: starlark or wasm is required", name)
+		}
+	}
+	return functions, nil
+}
+
+// raiseException returns the exception of the value raised by the plugins.
+func raiseException(v any) (types.Exception, error) {
+	switch e := v.(type) {
+	case string:
+		return types.NewExceptionByString(e), nil
+	case map[string]any:
+		return types.NewExceptionByMap(e), nil
+	default:
+		return nil, fmt.Errorf("exception must be a string or a map but got %T", v)
+	}
+}
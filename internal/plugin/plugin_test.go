@@ -0,0 +1,230 @@
+package plugin_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/plugin"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	functions, err := plugin.Load("testdata/plugins.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name             string
+		expr             string
+		expected         any
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name:     "starlark",
+			expr:     `text.slugify("Hello World")`,
+			expected: "hello-world",
+		},
+		{
+			name:     "starlark with optional argument",
+			expr:     `text.slugify("Hello World", "_")`,
+			expected: "hello_world",
+		},
+		{
+			name:     "starlark by function name",
+			expr:     `users.find("1")`,
+			expected: map[string]any{"id": "1", "name": "alice", "age": int64(20)},
+		},
+		{
+			name:             "starlark raise_exception",
+			expr:             `users.find("2")`,
+			expectedErrorTag: types.KeyErrorTag,
+		},
+		{
+			name:             "starlark error",
+			expr:             `users.fail()`,
+			expectedErrorTag: types.SystemErrorTag,
+		},
+		{
+			name:     "wasm",
+			expr:     `wasm.echo(1, m)`,
+			expected: map[string]any{"x": int64(1), "y": map[string]any{"a": []any{1.5, "b"}}},
+		},
+		{
+			name:             "wasm exception",
+			expr:             `wasm.fail()`,
+			expectedErrorTag: types.ValueErrorTag,
+		},
+		{
+			name:             "wasm trap",
+			expr:             `wasm.trap()`,
+			expectedErrorTag: types.SystemErrorTag,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(`
+main:
+  steps:
+    - init:
+        assign:
+          - m:
+              a: [1.5, "b"]
+    - done:
+        return: '${` + tt.expr + `}'
+`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := root.Execute(context.Background(), nil, workflow.WithSymbols(functions))
+			if tt.expectedErrorTag != "" {
+				if !hasTag(err, tt.expectedErrorTag) {
+					t.Fatalf("should be %s but got %v", tt.expectedErrorTag, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name    string
+		plugins string
+		files   map[string]string
+	}{
+		{
+			name: "no implementation",
+			plugins: `
+text.slugify:
+  function: slugify
+`,
+		},
+		{
+			name: "undefined function",
+			plugins: `
+text.slugify:
+  starlark: ./text.star
+`,
+			files: map[string]string{"text.star": "def other():\n    return 1\n"},
+		},
+		{
+			name: "not a function",
+			plugins: `
+text.slugify:
+  starlark: ./text.star
+`,
+			files: map[string]string{"text.star": "slugify = 1\n"},
+		},
+		{
+			name: "syntax error",
+			plugins: `
+text.slugify:
+  starlark: ./text.star
+`,
+			files: map[string]string{"text.star": "def slugify(:\n"},
+		},
+		{
+			name: "starlark and wasm",
+			plugins: `
+text.slugify:
+  starlark: ./text.star
+  wasm: ./text.wasm
+`,
+			files: map[string]string{"text.star": "def slugify(s):\n    return s\n"},
+		},
+		{
+			name: "invalid wasm",
+			plugins: `
+text.slugify:
+  wasm: ./text.wasm
+`,
+			files: map[string]string{"text.wasm": "not a module"},
+		},
+		{
+			name: "wasm function not exported",
+			plugins: `
+text.slugify:
+  wasm: ` + absPath(t, "testdata/plugins.wasm") + `
+`,
+		},
+		{
+			name: "wasm function of invalid signature",
+			plugins: `
+text.slugify:
+  wasm: ` + absPath(t, "testdata/plugins.wasm") + `
+  function: alloc
+`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path := filepath.Join(dir, "plugins.yaml")
+			if err := os.WriteFile(path, []byte(tt.plugins), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := plugin.Load(path); err == nil {
+				t.Fatal("should be error")
+			} else {
+				t.Logf("expected error: %v", err)
+			}
+		})
+	}
+}
+
+func absPath(t *testing.T, path string) string {
+	t.Helper()
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return abs
+}
+
+func hasTag(err error, tag types.ErrorTag) bool {
+	var e *types.Error
+	if errors.As(err, &e) {
+		return e.Tag == tag
+	}
+	var exception types.Exception
+	if errors.As(err, &exception) {
+		m, _ := exception.Exception().(map[string]any)
+		tags, _ := m["tags"].([]any)
+		for _, t := range tags {
+			if t == string(tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+)
+
+// starlarkPredeclared are the symbols of the scripts in addition to the built-ins of Starlark.
+//
+//	def find_user(id):
+//	    if id not in USERS:
+//	        raise_exception({"message": "user not found", "tags": ["KeyError"]})
+//	    return json.decode(USERS[id])
+var starlarkPredeclared = starlark.StringDict{
+	"json":            starlarkjson.Module,
+	"raise_exception": starlark.NewBuiltin("raise_exception", starlarkRaiseException),
+}
+
+// starlarkScript is the script whose global functions are the functions of the plugins.
+type starlarkScript struct {
+	path    string
+	globals starlark.StringDict
+}
+
+func loadStarlarkScript(path string) (*starlarkScript, error) {
+	thread := &starlark.Thread{Name: path, Print: starlarkPrint}
+	globals, err := starlark.ExecFile(thread, path, nil, starlarkPredeclared)
+	if err != nil {
+		return nil, fmt.Errorf("starlark.ExecFile: %w", err)
+	}
+
+	// the frozen functions are safe to be called by the parallel branches concurrently
+	globals.Freeze()
+	return &starlarkScript{path: path, globals: globals}, nil
+}
+
+// function returns the function of the global function of the script. The parameters are the arguments of it, and
+// the ones with the default values are optional.
+func (s *starlarkScript) function(name, function string) (types.Function, error) {
+	v, ok := s.globals[function]
+	if !ok {
+		return nil, fmt.Errorf("%s is not defined in %s", function, s.path)
+	}
+	fn, ok := v.(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("%s of %s is not a function but %s", function, s.path, v.Type())
+	}
+
+	n := fn.NumParams()
+	if fn.HasVarargs() {
+		n--
+	}
+	if fn.HasKwargs() {
+		n--
+	}
+	args := make([]types.Argument, n)
+	for i := range args {
+		args[i].Name, _ = fn.Param(i)
+		if fn.ParamDefault(i) != nil {
+			args[i].Default = types.SubstitutionNone // omitted to use the default value of the script
+		}
+	}
+
+	return types.NewRawFunction(name, args, func(ctx context.Context, values []any) (any, error) {
+		kwargs := make([]starlark.Tuple, 0, len(values))
+		for i, value := range values {
+			if value == types.SubstitutionNone {
+				continue
+			}
+
+			v, err := toStarlark(value)
+			if err != nil {
+				return nil, &types.Error{
+					Tag: types.TypeErrorTag,
+					Err: fmt.Errorf("invalid argument %s: %w", args[i].Name, err),
+				}
+			}
+			kwargs = append(kwargs, starlark.Tuple{starlark.String(args[i].Name), v})
+		}
+		return callStarlark(ctx, name, fn, kwargs)
+	}), nil
+}
+
+// callStarlark calls the function, which is cancelled when the context is done. The exceptions raised by
+// raise_exception are raised as they are, and the other errors are raised as SystemError.
+func callStarlark(ctx context.Context, name string, fn *starlark.Function, kwargs []starlark.Tuple) (any, error) {
+	thread := &starlark.Thread{Name: name, Print: starlarkPrint}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	ret, err := starlark.Call(thread, fn, nil, kwargs)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var raised *starlarkException
+		if errors.As(err, &raised) {
+			return nil, raised.exception
+		}
+		return nil, &types.Error{
+			Tag: types.SystemErrorTag,
+			Err: fmt.Errorf("%s: %w", name, err),
+		}
+	}
+
+	v, err := fromStarlark(ret)
+	if err != nil {
+		return nil, &types.Error{
+			Tag: types.TypeErrorTag,
+			Err: fmt.Errorf("invalid result of %s: %w", name, err),
+		}
+	}
+	return v, nil
+}
+
+// starlarkException is the error to raise the exception by raise_exception.
+type starlarkException struct {
+	exception types.Exception
+}
+
+func (e *starlarkException) Error() string {
+	return e.exception.Error()
+}
+
+func starlarkRaiseException(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var value starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &value); err != nil {
+		return nil, err
+	}
+
+	v, err := fromStarlark(value)
+	if err != nil {
+		return nil, err
+	}
+	exception, err := raiseException(v)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &starlarkException{exception: exception}
+}
+
+func starlarkPrint(thread *starlark.Thread, msg string) {
+	log.Printf("%s: %s", thread.Name, msg)
+}
+
+// toStarlark converts the value of the workflows into the value of Starlark.
+func toStarlark(value any) (starlark.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case string:
+		return starlark.String(v), nil
+	case []any:
+		elems := make([]starlark.Value, len(v))
+		for i, elem := range v {
+			var err error
+			elems[i], err = toStarlark(elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return starlark.NewList(elems), nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			elem, err := toStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key), elem); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// fromStarlark converts the value of Starlark into the value of the workflows.
+func fromStarlark(value starlark.Value) (any, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer out of range: %s", v)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case *starlark.List, starlark.Tuple:
+		iter := v.(starlark.Iterable).Iterate()
+		defer iter.Done()
+
+		list := []any{}
+		var elem starlark.Value
+		for iter.Next(&elem) {
+			e, err := fromStarlark(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, e)
+		}
+		return list, nil
+	case *starlark.Dict:
+		m := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("key of the dict must be a string but got %s", item[0].Type())
+			}
+			elem, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[string(key)] = elem
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", value.Type())
+	}
+}
@@ -0,0 +1,30 @@
+;; The source of plugins.wasm, e.g. by `wat2wasm plugins.wat -o plugins.wasm`.
+(module
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+  (data (i32.const 0) "{\"exception\":{\"message\":\"boom\",\"tags\":[\"ValueError\"]}}")
+  (data (i32.const 256) "{\"result\":")
+
+  (func $alloc (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (global.get $heap) (local.get $size)))
+    (local.get $ptr))
+
+  ;; returns {"result": <the arguments>}
+  (func (export "echo") (param $ptr i32) (param $len i32) (result i64)
+    (local $out i32)
+    (local.set $out (call $alloc (i32.add (local.get $len) (i32.const 11))))
+    (memory.copy (local.get $out) (i32.const 256) (i32.const 10))
+    (memory.copy (i32.add (local.get $out) (i32.const 10)) (local.get $ptr) (local.get $len))
+    (i32.store8 (i32.add (i32.add (local.get $out) (i32.const 10)) (local.get $len)) (i32.const 125))
+    (i64.or
+      (i64.shl (i64.extend_i32_u (local.get $out)) (i64.const 32))
+      (i64.extend_i32_u (i32.add (local.get $len) (i32.const 11)))))
+
+  ;; returns the exception at 0
+  (func (export "fail") (param i32 i32) (result i64)
+    (i64.const 54))
+
+  (func (export "trap") (param i32 i32) (result i64)
+    (unreachable)))
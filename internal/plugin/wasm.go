@@ -0,0 +1,237 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// The ABI of the WASM modules is JSON-in/JSON-out by their linear memory:
+//
+//	(memory (export "memory") ...)
+//	;; allocates the bytes of the input, which is a JSON object of the arguments by the names of the params
+//	(func (export "alloc") (param $size i32) (result i32))
+//	;; returns the pointer and the length of the output as (ptr << 32 | len), which is a JSON object of
+//	;; {"result": any} or {"exception": string or map}
+//	(func (export "slugify") (param $ptr i32) (param $len i32) (result i64))
+//
+// Each call runs on a new instance of the module, so the modules don't need to free the memory nor to be
+// reentrant. The WASI imports are available, and "_initialize" of the reactors is called on the instantiation.
+const (
+	wasmMemoryExport = "memory"
+	wasmAllocExport  = "alloc"
+)
+
+// wasmOutput is the output of the functions of the WASM modules.
+type wasmOutput struct {
+	Result    any `json:"result"`
+	Exception any `json:"exception"`
+}
+
+// wasmModule is the compiled module whose exported functions are the functions of the plugins.
+type wasmModule struct {
+	path     string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// newWASMRuntime returns the runtime of the WASM modules, whose calls are aborted when the context is done.
+func newWASMRuntime(ctx context.Context) (wazero.Runtime, error) {
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("wasi_snapshot_preview1.Instantiate: %w", err)
+	}
+	return runtime, nil
+}
+
+func loadWASMModule(ctx context.Context, runtime wazero.Runtime, path string) (*wasmModule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	compiled, err := runtime.CompileModule(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("wazero.CompileModule: %w", err)
+	}
+	if _, ok := compiled.ExportedMemories()[wasmMemoryExport]; !ok {
+		return nil, fmt.Errorf("%s doesn't export %s", path, wasmMemoryExport)
+	}
+	if err := checkWASMSignature(compiled, wasmAllocExport, []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &wasmModule{path: path, runtime: runtime, compiled: compiled}, nil
+}
+
+// function returns the function of the exported function of the module, whose arguments are the params.
+func (m *wasmModule) function(name, function string, params []string) (types.Function, error) {
+	if err := checkWASMSignature(m.compiled, function, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}); err != nil {
+		return nil, fmt.Errorf("%s: %w", m.path, err)
+	}
+
+	args := make([]types.Argument, len(params))
+	for i, param := range params {
+		args[i].Name = param
+	}
+
+	return types.NewRawFunction(name, args, func(ctx context.Context, values []any) (any, error) {
+		input := make(map[string]any, len(values))
+		for i, value := range values {
+			input[args[i].Name] = value
+		}
+		b, err := json.Marshal(input)
+		if err != nil {
+			return nil, &types.Error{
+				Tag: types.TypeErrorTag,
+				Err: fmt.Errorf("invalid arguments of %s: %w", name, err),
+			}
+		}
+		return m.call(ctx, name, function, b)
+	}), nil
+}
+
+// call calls the function by the input on a new instance of the module. The exceptions of the output are raised as
+// they are, and the other errors are raised as SystemError.
+func (m *wasmModule) call(ctx context.Context, name, function string, input []byte) (any, error) {
+	output, err := m.invoke(ctx, function, input)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &types.Error{
+			Tag: types.SystemErrorTag,
+			Err: fmt.Errorf("%s: %w", name, err),
+		}
+	}
+
+	if output.Exception != nil {
+		exception, err := raiseException(output.Exception)
+		if err != nil {
+			return nil, &types.Error{
+				Tag: types.SystemErrorTag,
+				Err: fmt.Errorf("%s: %w", name, err),
+			}
+		}
+		return nil, exception
+	}
+	return output.Result, nil
+}
+
+func (m *wasmModule) invoke(ctx context.Context, function string, input []byte) (*wasmOutput, error) {
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr)
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, config)
+	if err != nil {
+		return nil, fmt.Errorf("wazero.InstantiateModule: %w", err)
+	}
+	defer mod.Close(ctx)
+
+	ret, err := mod.ExportedFunction(wasmAllocExport).Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", wasmAllocExport, err)
+	}
+	ptr := uint32(ret[0])
+	if !mod.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("%s returned the out of range pointer %d", wasmAllocExport, ptr)
+	}
+
+	ret, err = mod.ExportedFunction(function).Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", function, err)
+	}
+	outPtr, outLen := uint32(ret[0]>>32), uint32(ret[0])
+	b, ok := mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("%s returned the out of range output (ptr=%d, len=%d)", function, outPtr, outLen)
+	}
+
+	var output wasmOutput
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&output); err != nil {
+		return nil, fmt.Errorf("invalid output of %s: %w", function, err)
+	}
+	if output.Result, err = fromJSONNumbers(output.Result); err != nil {
+		return nil, fmt.Errorf("invalid output of %s: %w", function, err)
+	}
+	if output.Exception, err = fromJSONNumbers(output.Exception); err != nil {
+		return nil, fmt.Errorf("invalid output of %s: %w", function, err)
+	}
+	return &output, nil
+}
+
+// fromJSONNumbers converts the numbers decoded as json.Number into int64 for the integers and float64 for the others.
+func fromJSONNumbers(value any) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []any:
+		for i, elem := range v {
+			var err error
+			if v[i], err = fromJSONNumbers(elem); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[string]any:
+		for key, elem := range v {
+			var err error
+			if v[key], err = fromJSONNumbers(elem); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+func checkWASMSignature(compiled wazero.CompiledModule, function string, params, results []api.ValueType) error {
+	def, ok := compiled.ExportedFunctions()[function]
+	if !ok {
+		return fmt.Errorf("%s is not exported", function)
+	}
+	if !equalValueTypes(def.ParamTypes(), params) || !equalValueTypes(def.ResultTypes(), results) {
+		return fmt.Errorf("%s must be %s", function, wasmSignature(params, results))
+	}
+	return nil
+}
+
+func equalValueTypes(x, y []api.ValueType) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func wasmSignature(params, results []api.ValueType) string {
+	return "(" + valueTypeNames(params) + ") -> (" + valueTypeNames(results) + ")"
+}
+
+func valueTypeNames(valueTypes []api.ValueType) string {
+	names := make([]string, len(valueTypes))
+	for i, t := range valueTypes {
+		names[i] = api.ValueTypeName(t)
+	}
+	return strings.Join(names, ", ")
+}
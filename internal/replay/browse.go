@@ -0,0 +1,150 @@
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// browseHelp is the help of the commands of Browse.
+const browseHelp = `commands:
+  n, next [COUNT]   step forward
+  p, prev [COUNT]   step back
+  g, goto INDEX     go to the step of the index (from 1)
+  first, last       go to the first or the last step
+  l, list           list the steps
+  v, vars [NAME]    print the variables, or the variable of the name
+  h, help           print this help
+  q, quit           quit
+`
+
+// Browse reads the commands from in, and prints the frames stepping back and forth through them into out until the
+// quit command or the end of in. It starts at the last frame, which is the failed step of a failed execution.
+func Browse(in io.Reader, out io.Writer, frames []*Frame) error {
+	if len(frames) == 0 {
+		_, err := fmt.Fprintln(out, "no steps are executed")
+		return err
+	}
+
+	b := &browser{out: out, frames: frames, current: len(frames) - 1}
+	b.printFrame()
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "(replay) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		command, args := fields[0], fields[1:]
+		switch command {
+		case "n", "next":
+			b.move(count(args))
+		case "p", "prev":
+			b.move(-count(args))
+		case "g", "goto":
+			index, err := strconv.Atoi(strings.Join(args, ""))
+			if err != nil || index < 1 || index > len(frames) {
+				fmt.Fprintf(out, "invalid index: must be 1-%d\n", len(frames))
+				continue
+			}
+			b.current = index - 1
+			b.printFrame()
+		case "first":
+			b.current = 0
+			b.printFrame()
+		case "last":
+			b.current = len(frames) - 1
+			b.printFrame()
+		case "l", "list":
+			for i := range frames {
+				marker := " "
+				if i == b.current {
+					marker = ">"
+				}
+				fmt.Fprintf(out, "%s %s\n", marker, b.describe(i))
+			}
+		case "v", "vars":
+			b.printVariables(args)
+		case "h", "help":
+			fmt.Fprint(out, browseHelp)
+		case "q", "quit":
+			return nil
+		default:
+			fmt.Fprintf(out, "unknown command: %s\n%s", command, browseHelp)
+		}
+	}
+}
+
+type browser struct {
+	out     io.Writer
+	frames  []*Frame
+	current int
+}
+
+// count returns the count of the argument of next and prev, or 1.
+func count(args []string) int {
+	if len(args) != 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
+
+func (b *browser) move(delta int) {
+	b.current += delta
+	if b.current < 0 {
+		b.current = 0
+	} else if b.current >= len(b.frames) {
+		b.current = len(b.frames) - 1
+	}
+	b.printFrame()
+}
+
+// describe returns the line of the frame like "[3/10] main: loop > add (15:04:05.000)".
+func (b *browser) describe(i int) string {
+	frame := b.frames[i]
+	s := fmt.Sprintf("[%d/%d] %s: %s", i+1, len(b.frames), frame.Routine, strings.Join(frame.Path, " > "))
+	if !frame.Time.IsZero() {
+		s += " (" + frame.Time.Local().Format("15:04:05.000") + ")"
+	}
+	return s
+}
+
+func (b *browser) printFrame() {
+	fmt.Fprintln(b.out, b.describe(b.current))
+}
+
+func (b *browser) printVariables(names []string) {
+	variables := b.frames[b.current].Variables
+	if len(names) == 0 {
+		names = make([]string, 0, len(variables))
+		for name := range variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		value, ok := variables[name]
+		if !ok {
+			fmt.Fprintf(b.out, "%s is not defined\n", name)
+			continue
+		}
+		v, err := json.MarshalIndentWithOption(value, "", "  ", json.DisableHTMLEscape())
+		if err != nil {
+			v = []byte(fmt.Sprintf("%#v", value))
+		}
+		fmt.Fprintf(b.out, "%s = %s\n", name, v)
+	}
+}
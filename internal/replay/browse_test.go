@@ -0,0 +1,106 @@
+package replay_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/replay"
+)
+
+func TestBrowse(t *testing.T) {
+	t.Parallel()
+
+	// the times are zero not to depend on the local time zone
+	frames := []*replay.Frame{
+		{Routine: "main", Path: []string{"init"}, Variables: map[string]any{}},
+		{Routine: "main", Path: []string{"loop", "add"}, Variables: map[string]any{"items": []any{1.0, "<a>"}, "n": 1.0}},
+		{Routine: "sub", Path: []string{"call_sub", "done"}, Variables: map[string]any{"x": "foo"}},
+	}
+	for _, tt := range []struct {
+		name     string
+		frames   []*replay.Frame
+		input    string
+		expected string
+	}{
+		{
+			name:     "start at the last frame",
+			frames:   frames,
+			input:    "",
+			expected: "[3/3] sub: call_sub > done\n(replay) \n",
+		},
+		{
+			name:   "step back and forth",
+			frames: frames,
+			input:  "p\nprev 5\nn\nnext 2\nfirst\nlast\nq\n(not read)\n",
+			expected: `[3/3] sub: call_sub > done
+(replay) [2/3] main: loop > add
+(replay) [1/3] main: init
+(replay) [2/3] main: loop > add
+(replay) [3/3] sub: call_sub > done
+(replay) [1/3] main: init
+(replay) [3/3] sub: call_sub > done
+(replay) `,
+		},
+		{
+			name:   "go to the index",
+			frames: frames,
+			input:  "g 2\ngoto 0\ngoto x\nquit\n",
+			expected: `[3/3] sub: call_sub > done
+(replay) [2/3] main: loop > add
+(replay) invalid index: must be 1-3
+(replay) invalid index: must be 1-3
+(replay) `,
+		},
+		{
+			name:   "list the steps",
+			frames: frames,
+			input:  "g 2\n\nl\nq\n",
+			expected: `[3/3] sub: call_sub > done
+(replay) [2/3] main: loop > add
+(replay) (replay)   [1/3] main: init
+> [2/3] main: loop > add
+  [3/3] sub: call_sub > done
+(replay) `,
+		},
+		{
+			name:   "print the variables",
+			frames: frames,
+			input:  "p\nv\nvars n missing\nq\n",
+			expected: `[3/3] sub: call_sub > done
+(replay) [2/3] main: loop > add
+(replay) items = [
+  1,
+  "<a>"
+]
+n = 1
+(replay) n = 1
+missing is not defined
+(replay) `,
+		},
+		{
+			name:     "unknown command",
+			frames:   frames,
+			input:    "x\nh\nq\n",
+			expected: "[3/3] sub: call_sub > done\n(replay) unknown command: x\n" + replay.BrowseHelp + "(replay) " + replay.BrowseHelp + "(replay) ",
+		},
+		{
+			name:     "no frames",
+			input:    "q\n",
+			expected: "no steps are executed\n",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out strings.Builder
+			if err := replay.Browse(strings.NewReader(tt.input), &out, tt.frames); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, out.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
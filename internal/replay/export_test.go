@@ -0,0 +1,4 @@
+package replay
+
+// BrowseHelp is the help printed by the help and the unknown commands of Browse.
+var BrowseHelp = browseHelp
@@ -0,0 +1,134 @@
+// Package replay executes the workflow again by the journal of an execution (see workflow.WithJournal) with the
+// recorded HTTP responses and timestamps, and steps back and forth through the states of the steps of it, e.g. to
+// inspect the variables at any step of a failed execution.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// Replay is the recorded execution to execute again.
+type Replay struct {
+	Args     any
+	Cassette *defaults.HTTPCassette // the recorded HTTP interactions, which must be installed by defaults.WrapHTTPTransport
+
+	startedAt time.Time
+	stepTimes []time.Time // in the order of the steps
+}
+
+// Frame is the state of the execution before a step.
+type Frame struct {
+	Routine   string
+	Path      []string
+	Time      time.Time // of the step in the journal, or zero if the execution is diverged from the journal
+	Variables map[string]any
+}
+
+// Load loads the replay from the journal. The name of the journal is shown in the errors of the unrecorded requests.
+func Load(name string, r io.Reader) (*Replay, error) {
+	entries, err := workflow.ReadJournal(r)
+	if err != nil {
+		return nil, fmt.Errorf("workflow.ReadJournal: %w", err)
+	}
+
+	replay := &Replay{}
+	var interactions []json.RawMessage
+	for _, entry := range entries {
+		switch entry.Type {
+		case "execution":
+			// the others are the child executions, e.g. of experimental.executions.map
+			if replay.startedAt.IsZero() {
+				replay.Args = entry.Args
+				replay.startedAt = entry.Time
+			}
+		case "step":
+			replay.stepTimes = append(replay.stepTimes, entry.Time)
+		case "http":
+			interactions = append(interactions, entry.Interaction)
+		}
+	}
+	if replay.startedAt.IsZero() {
+		return nil, fmt.Errorf("%s has no executions", name)
+	}
+
+	replay.Cassette, err = defaults.NewHTTPCassetteFromInteractions(name, interactions)
+	if err != nil {
+		return nil, fmt.Errorf("defaults.NewHTTPCassetteFromInteractions: %w", err)
+	}
+	return replay, nil
+}
+
+// Execute executes the main workflow with the recorded arguments at the recorded times (sys.sleep returns
+// immediately), and returns the frames of the executed steps in order with the result of the execution.
+func (r *Replay) Execute(ctx context.Context, root workflow.WorkflowRoot, opts ...workflow.ExecuteOption) ([]*Frame, any, error) {
+	var (
+		mu     sync.Mutex
+		frames []*Frame
+		clock  = &replayClock{now: r.startedAt}
+	)
+	ctx = defaults.WithClock(ctx, clock)
+	hook := func(_ context.Context, state *workflow.StepState) error {
+		variables, err := copyVariables(state.Variables())
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		frame := &Frame{Routine: state.Routine, Path: state.Path, Variables: variables}
+		if len(frames) < len(r.stepTimes) {
+			frame.Time = r.stepTimes[len(frames)]
+			clock.set(frame.Time)
+		}
+		frames = append(frames, frame)
+		return nil
+	}
+
+	ret, err := root.Execute(ctx, r.Args, append(opts[:len(opts):len(opts)], workflow.WithStepHook(hook), workflow.SerializeParallel(true))...)
+	return frames, ret, err
+}
+
+// replayClock is the clock at the recorded time of the current step, whose sleeps return immediately.
+type replayClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ defaults.Clock = (*replayClock)(nil)
+
+func (c *replayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *replayClock) Sleep(ctx context.Context, _ time.Duration) error {
+	return ctx.Err()
+}
+
+func (c *replayClock) set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// copyVariables copies the variables by JSON not to be modified by the following steps.
+func copyVariables(variables map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(variables)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	var copied map[string]any
+	if err := json.Unmarshal(b, &copied); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,192 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/replay"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+const testSource = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+        result: res
+    - count:
+        assign:
+          - n: ${len(res.body.items)}
+    - done:
+        return: ${n}
+`
+
+// recordJournal executes the workflow of the source with the server of the items, and returns the journal of it.
+func recordJournal(t *testing.T) []byte {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"items":[1,2,3]}`))
+	}))
+	defer ts.Close()
+
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(testSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	client := &http.Client{Transport: defaults.ObserveHTTPInteractions(http.DefaultTransport, workflow.JournalHTTPInteraction)}
+	_, err = root.Execute(context.Background(), map[string]any{"url": ts.URL + "/items"},
+		workflow.WithJournal(workflow.NewJournal(&buf, nil)),
+		workflow.WithHTTPClient(client),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	journal := recordJournal(t)
+	entries, err := workflow.ReadJournal(bytes.NewReader(journal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stepTimes []time.Time
+	for _, entry := range entries {
+		if entry.Type == "step" {
+			stepTimes = append(stepTimes, entry.Time)
+		}
+	}
+
+	for _, tt := range []struct {
+		name           string
+		source         string
+		expectedPaths  [][]string
+		expectedResult any
+		wantErr        bool
+	}{
+		{
+			name:           "same workflow",
+			source:         testSource,
+			expectedPaths:  [][]string{{"get"}, {"count"}, {"done"}},
+			expectedResult: int64(3),
+		},
+		{
+			name:           "diverged workflow without the recorded request",
+			source:         strings.Replace(testSource, "url: ${args.url}", `url: ${args.url + "?page=2"}`, 1),
+			expectedPaths:  [][]string{{"get"}},
+			expectedResult: nil,
+			wantErr:        true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := replay.Load("journal.jsonl", bytes.NewReader(journal))
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the server of the recording is closed, so the requests are served only by the cassette
+			frames, ret, err := r.Execute(context.Background(), root, workflow.WithHTTPClient(&http.Client{Transport: r.Cassette}))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expectedResult, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+
+			paths := make([][]string, len(frames))
+			for i, frame := range frames {
+				paths[i] = frame.Path
+				if frame.Routine != "main" {
+					t.Errorf("unexpected routine of frame #%d: %s", i+1, frame.Routine)
+				}
+				if !frame.Time.Equal(stepTimes[i]) {
+					t.Errorf("unexpected time of frame #%d: %v, want %v", i+1, frame.Time, stepTimes[i])
+				}
+			}
+			if diff := cmp.Diff(tt.expectedPaths, paths); diff != "" {
+				t.Errorf("unexpected paths of the frames (-want +got):\n%s", diff)
+			}
+			if !tt.wantErr {
+				if n := frames[len(frames)-1].Variables["n"]; n != float64(3) {
+					t.Errorf("unexpected variable n of the last frame: %v", n)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name    string
+		journal string
+		wantErr bool
+	}{
+		{
+			name:    "recorded journal",
+			journal: string(recordJournal(t)),
+		},
+		{
+			name:    "no executions",
+			journal: `{"time":"2026-01-01T00:00:00Z","type":"step","routine":"main","path":["done"]}` + "\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid journal",
+			journal: "{\n",
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := replay.Load("journal.jsonl", strings.NewReader(tt.journal))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if args, _ := r.Args.(map[string]any); !strings.HasSuffix(fmt.Sprint(args["url"]), "/items") {
+				t.Errorf("unexpected args: %v", r.Args)
+			}
+			if len(r.Cassette.Interactions) != 1 {
+				t.Errorf("unexpected interactions: %d", len(r.Cassette.Interactions))
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+)
+
+var callbackNameRegexp = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/workflows/[^/]+/executions/[^/]+/callbacks/[^/]+$`)
+
+// callback is the callback endpoint of the execution created by events.create_callback_endpoint.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rest/v1/projects.locations.workflows.executions.callbacks
+type callback struct {
+	Name              string `json:"name"`
+	Method            string `json:"method"`
+	URL               string `json:"url"`
+	Waiters           int    `json:"waiters,string"`
+	AvailablePayloads int    `json:"availablePayloads,string"`
+}
+
+// executionCallbacks is the registry of the callback endpoints of the execution, which are served by the API server.
+type executionCallbacks struct {
+	store *ExecutionStore
+	name  string // of the execution
+}
+
+var _ defaults.CallbackRegistry = (*executionCallbacks)(nil)
+
+func (c *executionCallbacks) RegisterCallback(cb *defaults.EventCallback) string {
+	name := fmt.Sprintf("%s/callbacks/%016x", c.name, atomic.AddUint64(&c.store.idBase, 1))
+	c.store.callbacks.Store(name, cb)
+	return c.store.callbackBaseURL + "/v1/" + name
+}
+
+// SetCallbackBaseURL makes the executions host the callback endpoints under the URL of the API server
+// instead of the ad-hoc local ports. It must be called before serving any requests.
+func (s *ExecutionStore) SetCallbackBaseURL(baseURL string) {
+	s.callbackBaseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// lookupCallback returns the callback endpoint specified by the name like projects/*/locations/*/workflows/*/executions/*/callbacks/*.
+func (s *ExecutionStore) lookupCallback(name string) (*defaults.EventCallback, error) {
+	m := callbackNameRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid name: %q", errInvalidArgument, name)
+	}
+	if err := s.checkLocation(m[1], m[2]); err != nil {
+		return nil, err
+	}
+
+	v, ok := s.callbacks.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: callback %s", errNotFound, name)
+	}
+	return v.(*defaults.EventCallback), nil
+}
+
+// listCallbacks returns the callback endpoints of the execution specified by the parent in the name order.
+func (s *ExecutionStore) listCallbacks(parent string) ([]*callback, error) {
+	m := executionNameRegexp.FindStringSubmatch(parent)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid parent: %q", errInvalidArgument, parent)
+	}
+	if err := s.checkLocation(m[1], m[2]); err != nil {
+		return nil, err
+	}
+	if _, ok := s.executions.Load(parent); !ok {
+		return nil, fmt.Errorf("%w: execution %s", errNotFound, parent)
+	}
+
+	results := []*callback{}
+	s.callbacks.Range(func(key, value any) bool {
+		name := key.(string)
+		if strings.HasPrefix(name, parent+"/callbacks/") {
+			cb := value.(*defaults.EventCallback)
+			results = append(results, &callback{
+				Name:              name,
+				Method:            cb.Method(),
+				URL:               s.callbackBaseURL + "/v1/" + name,
+				Waiters:           cb.Waiters(),
+				AvailablePayloads: cb.AvailablePayloads(),
+			})
+		}
+		return true
+	})
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+// deleteCallbacks deletes the callback endpoints of the finished execution.
+func (s *ExecutionStore) deleteCallbacks(parent string) {
+	s.callbacks.Range(func(key, value any) bool {
+		if strings.HasPrefix(key.(string), parent+"/callbacks/") {
+			s.callbacks.Delete(key)
+		}
+		return true
+	})
+}
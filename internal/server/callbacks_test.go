@@ -0,0 +1,338 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+// newCallbackServer serves the REST API of the workflows of the sources, which hosts the callback endpoints.
+func newCallbackServer(t *testing.T, sources map[string]string) *httptest.Server {
+	t.Helper()
+
+	loaded := make(map[string]*server.LoadedWorkflow, len(sources))
+	for id, source := range sources {
+		root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded[id] = &server.LoadedWorkflow{Root: root, Source: []byte(source)}
+	}
+
+	store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+		return loaded, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(server.NewHTTPHandler(store))
+	store.SetCallbackBaseURL("http://" + ts.Listener.Addr().String() + "/")
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// waitCallback polls the callbacks of the execution until the condition is satisfied by one of them.
+func waitCallback(t *testing.T, baseURL, execution string, cond func(cb map[string]any) bool) map[string]any {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var res struct {
+			Callbacks []map[string]any `json:"callbacks"`
+		}
+		if status := doJSON(t, http.MethodGet, baseURL+"/v1/"+execution+"/callbacks", nil, &res); status != http.StatusOK {
+			t.Fatalf("unexpected status: %d", status)
+		}
+		for _, cb := range res.Callbacks {
+			if cond(cb) {
+				return cb
+			}
+		}
+		if time.Now().After(deadline) {
+			var ex map[string]any
+			doJSON(t, http.MethodGet, baseURL+"/v1/"+execution, nil, &ex)
+			t.Fatalf("callback is not ready: %v, execution: %v", res.Callbacks, ex)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+const testCallbackWorkflow = `
+main:
+  params: [args]
+  steps:
+    - create:
+        call: events.create_callback_endpoint
+        args:
+          http_callback_method: ${args.method}
+        result: cb
+    - await:
+        call: events.await_callback
+        args:
+          callback: ${cb}
+          timeout: 10.0
+        result: req
+    - done:
+        return:
+          method: ${req.http_request.method}
+          query: ${req.http_request.query}
+          body: ${req.http_request.body}
+`
+
+func TestCallbacks(t *testing.T) {
+	t.Parallel()
+
+	ts := newCallbackServer(t, map[string]string{"wf": testCallbackWorkflow})
+
+	for _, tt := range []struct {
+		name           string
+		method         string
+		requestMethod  string
+		query          string
+		contentType    string
+		body           string
+		expectedStatus int
+		expected       map[string]any
+	}{
+		{
+			name:           "JSON body",
+			method:         http.MethodPost,
+			requestMethod:  http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"approved":true}`,
+			expectedStatus: http.StatusNoContent,
+			expected:       map[string]any{"method": "POST", "query": map[string]any{}, "body": map[string]any{"approved": true}},
+		},
+		{
+			name:           "text body",
+			method:         http.MethodPut,
+			requestMethod:  http.MethodPut,
+			contentType:    "text/plain",
+			body:           "approved",
+			expectedStatus: http.StatusNoContent,
+			expected:       map[string]any{"method": "PUT", "query": map[string]any{}, "body": "approved"},
+		},
+		{
+			name:           "query",
+			method:         http.MethodGet,
+			requestMethod:  http.MethodGet,
+			query:          "?approved=true",
+			expectedStatus: http.StatusNoContent,
+			expected:       map[string]any{"method": "GET", "query": map[string]any{"approved": "true"}, "body": nil},
+		},
+		{
+			name:           "other method",
+			method:         http.MethodPost,
+			requestMethod:  http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "invalid JSON body",
+			method:         http.MethodPost,
+			requestMethod:  http.MethodPost,
+			contentType:    "application/json",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/wf/executions", map[string]any{"argument": `{"method":"` + tt.method + `"}`}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			name := ex["name"].(string)
+			t.Cleanup(func() {
+				doJSON(t, http.MethodPost, ts.URL+"/v1/"+name+":cancel", nil, nil)
+			})
+
+			cb := waitCallback(t, ts.URL, name, func(cb map[string]any) bool { return cb["waiters"] == "1" })
+			if cb["method"] != tt.method {
+				t.Errorf("unexpected method: %v", cb["method"])
+			}
+			if u := cb["url"].(string); u != ts.URL+"/v1/"+cb["name"].(string) || !strings.HasPrefix(u, ts.URL+"/v1/"+name+"/callbacks/") {
+				t.Errorf("unexpected url: %s", u)
+			}
+
+			req, err := http.NewRequest(tt.requestMethod, cb["url"].(string)+tt.query, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", res.StatusCode, tt.expectedStatus)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			ex = waitExecution(t, ts.URL+"/v1/"+name)
+			if ex["state"] != "SUCCEEDED" {
+				t.Fatalf("unexpected state: %v", ex)
+			}
+			var actual map[string]any
+			if err := json.Unmarshal([]byte(ex["result"].(string)), &actual); err != nil {
+				t.Fatalf("failed to decode result %v: %v", ex["result"], err)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected request (-want +got):\n%s", diff)
+			}
+
+			// the callbacks are deleted at the end of the execution
+			if status := doJSON(t, http.MethodPost, cb["url"].(string), nil, nil); status != http.StatusNotFound {
+				t.Errorf("unexpected status of the finished callback: %d", status)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{name: "unknown callback", path: testWorkflowsPath + "/wf/executions/unknown/callbacks/unknown", expectedStatus: http.StatusNotFound},
+		{name: "callbacks of unknown execution", path: testWorkflowsPath + "/wf/executions/unknown/callbacks", expectedStatus: http.StatusNotFound},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if status := doJSON(t, http.MethodGet, ts.URL+tt.path, nil, nil); status != tt.expectedStatus {
+				t.Errorf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestMultipleCallbacks(t *testing.T) {
+	t.Parallel()
+
+	ts := newCallbackServer(t, map[string]string{"wf": `
+main:
+  params: [args]
+  steps:
+    - create:
+        call: events.create_callback_endpoint
+        args:
+          http_callback_method: POST
+        result: cb
+    - createGate:
+        call: events.create_callback_endpoint
+        args:
+          http_callback_method: PUT
+        result: gate
+    - awaitGate:
+        call: events.await_callback
+        args:
+          callback: ${gate}
+          timeout: 10.0
+    - init:
+        assign:
+          - bodies: []
+    - drain:
+        try:
+          for:
+            value: i
+            in: ${args.awaits}
+            steps:
+              - await:
+                  call: events.await_callback
+                  args:
+                    callback: ${cb}
+                    timeout: 0.2
+                  result: req
+              - append:
+                  assign:
+                    - bodies: ${list.concat(bodies, req.http_request.body)}
+        except:
+          as: e
+          steps:
+            - timedOut:
+                switch:
+                  - condition: ${not("TimeoutError" in e.tags)}
+                    raise: ${e}
+    - done:
+        return: ${bodies}
+`})
+
+	for _, tt := range []struct {
+		name     string
+		requests int
+		awaits   int
+		expected []string
+	}{
+		{name: "drain all", requests: 3, awaits: 3, expected: []string{"0", "1", "2"}},
+		{name: "drain some", requests: 3, awaits: 2, expected: []string{"0", "1"}},
+		{name: "await more", requests: 1, awaits: 2, expected: []string{"0"}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			awaits, err := json.Marshal(lo.Range(tt.awaits))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/wf/executions", map[string]any{"argument": `{"awaits":` + string(awaits) + `}`}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			name := ex["name"].(string)
+			t.Cleanup(func() {
+				doJSON(t, http.MethodPost, ts.URL+"/v1/"+name+":cancel", nil, nil)
+			})
+
+			gate := waitCallback(t, ts.URL, name, func(cb map[string]any) bool { return cb["method"] == "PUT" && cb["waiters"] == "1" })
+			cb := waitCallback(t, ts.URL, name, func(cb map[string]any) bool { return cb["method"] == "POST" })
+			for i := 0; i < tt.requests; i++ {
+				res, err := http.Post(cb["url"].(string), "text/plain", strings.NewReader(strconv.Itoa(i)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if res.StatusCode != http.StatusNoContent {
+					t.Fatalf("unexpected status: %d", res.StatusCode)
+				}
+			}
+
+			// the requests are queued until they are awaited
+			waitCallback(t, ts.URL, name, func(cb map[string]any) bool {
+				return cb["method"] == "POST" && cb["availablePayloads"] == strconv.Itoa(tt.requests)
+			})
+			if status := doJSON(t, http.MethodPut, gate["url"].(string), nil, nil); status != http.StatusNoContent {
+				t.Fatalf("unexpected status: %d", status)
+			}
+
+			ex = waitExecution(t, ts.URL+"/v1/"+name)
+			if ex["state"] != "SUCCEEDED" {
+				t.Fatalf("unexpected state: %v", ex)
+			}
+			var actual []string
+			if err := json.Unmarshal([]byte(ex["result"].(string)), &actual); err != nil {
+				t.Fatalf("failed to decode result %v: %v", ex["result"], err)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected bodies (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of the unchanged lines around the changes in the hunks of the diff.
+const diffContext = 2
+
+type diffOp struct {
+	kind   byte // ' ', '-' or '+'
+	line   string
+	oldPos int // the number of the old lines before the op
+	newPos int // the number of the new lines before the op
+}
+
+// diffLines returns the unified diff of the sources of the revisions by the longest common subsequence of the lines.
+func diffLines(oldSource, newSource, oldName, newName string) string {
+	a := strings.Split(strings.TrimSuffix(oldSource, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(newSource, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', line: a[i], oldPos: i, newPos: j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', line: a[i], oldPos: i, newPos: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: b[j], oldPos: i, newPos: j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		// extend the hunk while the next change is within the context of the last one
+		first, last := start, start
+		for k := start + 1; k < len(ops) && k <= last+2*diffContext+1; k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			}
+		}
+		from, to := first-diffContext, last+diffContext+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		var oldLines, newLines int
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldLines++
+			}
+			if op.kind != '-' {
+				newLines++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ops[from].oldPos, oldLines), hunkRange(ops[from].newPos, newLines))
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// hunkRange formats the range of the lines in the hunk header, which starts from 1, or the line before the empty range.
+func hunkRange(pos, lines int) string {
+	if lines == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, lines)
+}
@@ -0,0 +1,66 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+)
+
+func TestDiffLines(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "unchanged",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: "",
+		},
+		{
+			name:     "changed line",
+			old:      "a\nb\nc\n",
+			new:      "a\nB\nc\n",
+			expected: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c",
+		},
+		{
+			name:     "added lines",
+			old:      "a\n",
+			new:      "a\nb\nc\n",
+			expected: "--- old\n+++ new\n@@ -1,1 +1,3 @@\n a\n+b\n+c",
+		},
+		{
+			name:     "removed lines",
+			old:      "a\nb\nc\n",
+			new:      "c\n",
+			expected: "--- old\n+++ new\n@@ -1,3 +1,1 @@\n-a\n-b\n c",
+		},
+		{
+			name:     "separated hunks",
+			old:      "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:      "one\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			expected: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n-1\n+one\n 2\n 3\n@@ -7,3 +7,3 @@\n 7\n 8\n-9\n+nine",
+		},
+		{
+			name:     "merged hunks within the context",
+			old:      "1\n2\n3\n4\n5\n6\n",
+			new:      "one\n2\n3\n4\n5\nsix\n",
+			expected: "--- old\n+++ new\n@@ -1,6 +1,6 @@\n-1\n+one\n 2\n 3\n 4\n 5\n-6\n+six",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			diff := server.DiffLines(tt.old, tt.new, "old", "new")
+			if d := cmp.Diff(tt.expected, diff); d != "" {
+				t.Errorf("unexpected diff (-want +got):\n%s", d)
+			}
+		})
+	}
+}
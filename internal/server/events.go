@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// executionEvent is the event of the progress of the execution streamed by the emulator specific events endpoint.
+type executionEvent struct {
+	Type      string             `json:"type"` // stepStarted, stepFinished, log, paused, resumed or finished
+	Timestamp time.Time          `json:"timestamp"`
+	Step      string             `json:"step,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Log       *defaults.LogEntry `json:"log,omitempty"`
+	State     string             `json:"state,omitempty"` // of the finished execution
+}
+
+// publishLocked appends the event to the execution and wakes up the streams. The execution must be locked by the caller.
+func (ex *execution) publishLocked(event *executionEvent) {
+	event.Timestamp = time.Now().UTC()
+	ex.events = append(ex.events, event)
+	close(ex.eventsUpdated)
+	ex.eventsUpdated = make(chan struct{})
+}
+
+// executionSteps observes the progress of the steps of the execution.
+type executionSteps struct {
+	ex *execution
+}
+
+var _ workflow.StepObserver = (*executionSteps)(nil)
+
+func (o *executionSteps) StepStarted(step workflow.StepName) {
+	o.ex.mu.Lock()
+	defer o.ex.mu.Unlock()
+	o.ex.publishLocked(&executionEvent{Type: "stepStarted", Step: string(step)})
+}
+
+func (o *executionSteps) StepFinished(step workflow.StepName, err error) {
+	event := &executionEvent{Type: "stepFinished", Step: string(step)}
+	var exception types.Exception
+	if errors.As(err, &exception) {
+		event.Error = exception.Error()
+	} else if err != nil {
+		event.Error = err.Error()
+	}
+
+	o.ex.mu.Lock()
+	defer o.ex.mu.Unlock()
+	o.ex.publishLocked(event)
+}
+
+// streamEvents is the emulator specific method to stream the progress of the execution as the server-sent events.
+// The events from the start of the execution (or after the Last-Event-ID) are sent, and the stream ends when the execution finishes.
+func (h *httpHandler) streamEvents(w http.ResponseWriter, r *http.Request, name string) {
+	ex, err := h.store.load(name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Internal Server Error: streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var sent int
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		sent, err = strconv.Atoi(id)
+		if err != nil || sent < 0 {
+			http.Error(w, "Bad Request: invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		ex.mu.RLock()
+		if sent > len(ex.events) {
+			sent = len(ex.events)
+		}
+		events := ex.events[sent:]
+		updated := ex.eventsUpdated
+		finished := ex.EndTime != nil
+		ex.mu.RUnlock()
+
+		for _, event := range events {
+			b, err := json.Marshal(event)
+			if err != nil {
+				b, _ = json.Marshal(&executionEvent{Type: event.Type, Timestamp: event.Timestamp, Error: fmt.Sprintf("json.Marshal: %v", err)})
+			}
+			sent++
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", sent, event.Type, b); err != nil {
+				return // disconnected
+			}
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
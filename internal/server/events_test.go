@@ -0,0 +1,220 @@
+package server_test
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+)
+
+type serverSentEvent struct {
+	ID    int
+	Event string
+	Data  map[string]any
+}
+
+// summary formats the event like "stepFinished fail: failed" without the timestamp to compare them.
+func (e *serverSentEvent) summary() string {
+	s := e.Event
+	for _, key := range []string{"step", "state"} {
+		if v, ok := e.Data[key]; ok {
+			s += " " + v.(string)
+		}
+	}
+	if v, ok := e.Data["error"]; ok {
+		s += ": " + v.(string)
+	}
+	if v, ok := e.Data["log"].(map[string]any); ok {
+		s += " " + v["textPayload"].(string)
+	}
+	return s
+}
+
+// readEvents reads the server-sent events until the end of the stream.
+func readEvents(t *testing.T, res *http.Response) []*serverSentEvent {
+	t.Helper()
+
+	var events []*serverSentEvent
+	event := &serverSentEvent{}
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, event)
+			event = &serverSentEvent{}
+		case strings.HasPrefix(line, "id: "):
+			id, err := strconv.Atoi(strings.TrimPrefix(line, "id: "))
+			if err != nil {
+				t.Fatal(err)
+			}
+			event.ID = id
+		case strings.HasPrefix(line, "event: "):
+			event.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			t.Fatalf("unexpected line: %q", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestStreamEvents(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{
+		"succeeded": `
+main:
+  steps:
+    - log:
+        call: sys.log
+        args:
+          text: hello
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 0.1
+    - done:
+        return: ok
+`,
+		"failed": `
+main:
+  steps:
+    - fail:
+        raise: failed
+`,
+	})
+
+	for _, tt := range []struct {
+		name           string
+		workflowID     string
+		lastEventID    string
+		expectedStatus int
+		expectedFirst  int
+		expected       []string
+	}{
+		{
+			name:           "succeeded",
+			workflowID:     "succeeded",
+			expectedStatus: http.StatusOK,
+			expectedFirst:  1,
+			expected: []string{
+				"stepStarted log",
+				"log hello",
+				"stepFinished log",
+				"stepStarted sleep",
+				"stepFinished sleep",
+				"stepStarted done",
+				"stepFinished done",
+				"finished SUCCEEDED",
+			},
+		},
+		{
+			name:           "failed",
+			workflowID:     "failed",
+			expectedStatus: http.StatusOK,
+			expectedFirst:  1,
+			expected: []string{
+				"stepStarted fail",
+				"stepFinished fail: failed",
+				"finished FAILED",
+			},
+		},
+		{
+			name:           "after Last-Event-ID",
+			workflowID:     "succeeded",
+			lastEventID:    "5",
+			expectedStatus: http.StatusOK,
+			expectedFirst:  6,
+			expected: []string{
+				"stepStarted done",
+				"stepFinished done",
+				"finished SUCCEEDED",
+			},
+		},
+		{
+			name:           "Last-Event-ID after the end",
+			workflowID:     "failed",
+			lastEventID:    "100",
+			expectedStatus: http.StatusOK,
+			expected:       nil,
+		},
+		{
+			name:           "invalid Last-Event-ID",
+			workflowID:     "failed",
+			lastEventID:    "-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/"+tt.workflowID+"/executions", map[string]any{}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if tt.lastEventID != "" {
+				// resume the stream of the events sent before
+				waitExecution(t, ts.URL+"/v1/"+ex["name"].(string))
+			}
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/"+ex["name"].(string)+"/events", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", res.StatusCode, tt.expectedStatus)
+			}
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("unexpected Content-Type: %s", ct)
+			}
+
+			var actual []string
+			for i, event := range readEvents(t, res) {
+				if event.ID != tt.expectedFirst+i {
+					t.Errorf("unexpected id of %s: %d, want %d", event.Event, event.ID, tt.expectedFirst+i)
+				}
+				if event.Event != event.Data["type"] {
+					t.Errorf("unexpected type of %s: %v", event.Event, event.Data["type"])
+				}
+				actual = append(actual, event.summary())
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected events (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("unknown execution", func(t *testing.T) {
+		t.Parallel()
+
+		if status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/failed/executions/unknown/events", nil, nil); status != http.StatusNotFound {
+			t.Errorf("unexpected status: %d", status)
+		}
+		if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/failed/executions/unknown/events", nil, nil); status != http.StatusMethodNotAllowed {
+			t.Errorf("unexpected status: %d", status)
+		}
+	})
+}
@@ -0,0 +1,597 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/watch"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+var workflowNameRegexp = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/workflows/([^/]+)$`)
+
+var executionNameRegexp = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/workflows/[^/]+/executions/[^/]+$`)
+
+var numericRegexp = regexp.MustCompile(`^[0-9]+$`)
+
+var (
+	errInvalidArgument = errors.New("invalid argument")
+	errNotFound        = errors.New("not found")
+	errAlreadyExists   = errors.New("already exists")
+	errPrecondition    = errors.New("failed precondition")
+	errUnavailable     = errors.New("unavailable")
+)
+
+type execution struct {
+	mu sync.RWMutex
+
+	Name               string            `json:"name"`
+	StartTime          time.Time         `json:"startTime"`
+	EndTime            *time.Time        `json:"endTime,omitempty"` // nil while it's active
+	Duration           string            `json:"duration,omitempty"`
+	State              string            `json:"state"`
+	StateError         *stateError       `json:"stateError,omitempty"`
+	Error              *executionError   `json:"error,omitempty"`
+	Argument           string            `json:"argument,omitempty"`
+	Result             string            `json:"result,omitempty"`
+	WorkflowRevisionId string            `json:"workflowRevisionId"`
+	CallLogLevel       string            `json:"callLogLevel"`
+	Labels             map[string]string `json:"labels,omitempty"`
+
+	// the emulator extension to assert the logs of the execution
+	LogEntries []*defaults.LogEntry `json:"logEntries,omitempty"`
+	// the emulator extension to debug the execution, which holds the steps at the next step boundary
+	Paused bool `json:"paused,omitempty"`
+
+	gate   *workflow.StepGate
+	cancel context.CancelFunc // cancels the execution at the next step boundary
+	done   chan struct{}      // closed when the execution is finished
+	// the progress of the execution to stream
+	events        []*executionEvent
+	eventsUpdated chan struct{} // closed when the events are published
+}
+
+// executionLogs captures the logs of the execution.
+type executionLogs struct {
+	ex *execution
+}
+
+var _ defaults.LogRecorder = (*executionLogs)(nil)
+
+func (l *executionLogs) RecordLog(entry *defaults.LogEntry) {
+	l.ex.mu.Lock()
+	defer l.ex.mu.Unlock()
+	l.ex.LogEntries = append(l.ex.LogEntries, entry)
+	l.ex.publishLocked(&executionEvent{Type: "log", Log: entry})
+}
+
+// stateError is the error of the execution not caused by the workflow, e.g. the cancellations and the system failures.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rest/v1/projects.locations.workflows.executions#StateError
+type stateError struct {
+	Details string `json:"details"`
+	Type    string `json:"type"`
+}
+
+// formatDuration formats the duration as the JSON of google.protobuf.Duration like "1.5s".
+func formatDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// validateCallLogLevel validates the callLogLevel of the workflows and the executions. The empty one is unspecified.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rest/v1/projects.locations.workflows.executions#CallLogLevel
+func validateCallLogLevel(level string) error {
+	switch level {
+	case "", "CALL_LOG_LEVEL_UNSPECIFIED", "LOG_ALL_CALLS", "LOG_ERRORS_ONLY", "LOG_NONE":
+		return nil
+	default:
+		return fmt.Errorf("%w: invalid callLogLevel: %q", errInvalidArgument, level)
+	}
+}
+
+// executionError is the error of the failed execution.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rest/v1/projects.locations.workflows.executions#Error
+type executionError struct {
+	Payload    string               `json:"payload"`
+	Context    string               `json:"context"`
+	StackTrace *executionStackTrace `json:"stackTrace,omitempty"`
+}
+
+type executionStackTrace struct {
+	Elements []*executionStackTraceElement `json:"elements"`
+}
+
+type executionStackTraceElement struct {
+	Step     string             `json:"step"`
+	Routine  string             `json:"routine"`
+	Position *executionPosition `json:"position,omitempty"`
+}
+
+type executionPosition struct {
+	Line   int `json:"line,string"`
+	Column int `json:"column,string"`
+	Length int `json:"length,string"`
+}
+
+// newExecutionError builds the error of the execution from the error returned by the workflow.
+// The payload is the JSON of the exception, and the context is the message with the failed step.
+func newExecutionError(err error) *executionError {
+	var payload any = err.Error()
+	message := "RuntimeError: " + err.Error()
+
+	var exception types.Exception
+	if errors.As(err, &exception) {
+		payload = exception.Exception()
+
+		var e *types.Error
+		if errors.As(err, &e) {
+			message = e.Error()
+		} else if b, err := json.Marshal(payload); err == nil {
+			message = "RuntimeError: " + string(b)
+		}
+	}
+
+	ret := &executionError{Context: message}
+	if b, dumpErr := json.Marshal(payload); dumpErr != nil {
+		logging.Logf(logging.LevelError, "failed to encode workflow exception", "error", dumpErr)
+		ret.Payload = strconv.Quote(err.Error())
+	} else {
+		ret.Payload = string(b)
+	}
+
+	frames := workflow.StackTrace(err)
+	if len(frames) == 0 {
+		return ret
+	}
+	ret.StackTrace = &executionStackTrace{Elements: make([]*executionStackTraceElement, len(frames))}
+	for i, frame := range frames {
+		ret.StackTrace.Elements[i] = &executionStackTraceElement{Step: string(frame.Step), Routine: frame.Routine}
+		if frame.Position != nil {
+			ret.StackTrace.Elements[i].Position = &executionPosition{Line: frame.Position.Line, Column: frame.Position.Column, Length: frame.Position.Length}
+		}
+	}
+
+	ret.Context += fmt.Sprintf("\nin step %q, routine %q", frames[0].Step, frames[0].Routine)
+	if frames[0].Position != nil {
+		ret.Context += fmt.Sprintf(", line: %d", frames[0].Position.Line)
+	}
+	return ret
+}
+
+// withView returns the copy of the execution in the view. The BASIC view omits the argument, the result, the error and the logs.
+// The execution must be locked by the caller.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rest/v1/ExecutionView
+func (ex *execution) withView(view string) *execution {
+	ret := &execution{
+		Name:               ex.Name,
+		StartTime:          ex.StartTime,
+		EndTime:            ex.EndTime,
+		Duration:           ex.Duration,
+		State:              ex.State,
+		StateError:         ex.StateError,
+		WorkflowRevisionId: ex.WorkflowRevisionId,
+		CallLogLevel:       ex.CallLogLevel,
+		Labels:             ex.Labels,
+		Paused:             ex.Paused,
+	}
+	if view == "FULL" {
+		ret.Argument = ex.Argument
+		ret.Result = ex.Result
+		ret.Error = ex.Error
+		ret.LogEntries = ex.LogEntries[:len(ex.LogEntries):len(ex.LogEntries)]
+	}
+	return ret
+}
+
+// ExecutionStore is the executions of the workflows shared by the REST and the gRPC APIs.
+type ExecutionStore struct {
+	loadedWorkflows atomic.Value // map[string]*loadedRevision by the workflow IDs
+	loader          func() (map[string]*LoadedWorkflow, error)
+	workflows       sync.Map // deployed by the admin API
+	operations      sync.Map // of the admin API by the names
+	idBase          uint64
+	executions      sync.Map // by the names
+	executeOpts     []workflow.ExecuteOption
+	callbacks       sync.Map // of the executions by the names
+	callbackBaseURL string   // hosts the callbacks by the store if it's not empty
+
+	// the in-flight executions, which are cancelled by cancel if they are not finished in the shutdown
+	mu       sync.RWMutex
+	draining bool
+	inflight sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	// the project IDs (or numbers) and the location accepted by the store, which accepts any of them if empty
+	projects []string
+	location string
+}
+
+// NewExecutionStore returns the store which executes the workflows loaded by the loader by the workflow IDs.
+// Call WatchWorkflows to reflect the changes of the workflow files as the new revisions.
+func NewExecutionStore(loader func() (map[string]*LoadedWorkflow, error), opts ...workflow.ExecuteOption) (*ExecutionStore, error) {
+	s := &ExecutionStore{loader: loader, executeOpts: opts}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WatchWorkflows reloads the workflows by the loader when the workflow files (or the directories of them) of the paths are changed.
+// The last good revisions are kept serving if the changed files are failed to load.
+func (s *ExecutionStore) WatchWorkflows(paths []string) error {
+	return watch.Watch(paths, func() {
+		if err := s.reload(); err != nil {
+			logging.Logf(logging.LevelError, "failed to reload workflows, keep serving the last good revisions", "error", err)
+		}
+	})
+}
+
+// RestrictLocation makes the store accept only the requests to the project (by the ID or the number) and the location.
+// It must be called before serving any requests.
+func (s *ExecutionStore) RestrictLocation(projectID, projectNumber, location string) {
+	s.projects = []string{projectID, projectNumber}
+	s.location = location
+}
+
+// checkLocation checks the project and the location in the resource name are accepted by the store.
+func (s *ExecutionStore) checkLocation(project, location string) error {
+	if len(s.projects) != 0 && !lo.Contains(s.projects, project) {
+		return fmt.Errorf("%w: project %s", errNotFound, project)
+	}
+	if s.location != "" && location != s.location {
+		return fmt.Errorf("%w: location %s", errNotFound, location)
+	}
+	return nil
+}
+
+// create starts the execution of the workflow specified by the parent like projects/*/locations/*/workflows/*,
+// and returns the snapshot of the created execution. The current revision of the workflow is executed.
+func (s *ExecutionStore) create(parent string, ex *execution) (*execution, error) {
+	m := workflowNameRegexp.FindStringSubmatch(parent)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid parent: %q", errInvalidArgument, parent)
+	}
+	if err := s.checkLocation(m[1], m[2]); err != nil {
+		return nil, err
+	}
+
+	if err := validateCallLogLevel(ex.CallLogLevel); err != nil {
+		return nil, err
+	}
+
+	var args any
+	if ex.Argument == "" {
+		ex.Argument = "null"
+	} else {
+		if err := json.NewDecoder(strings.NewReader(ex.Argument)).Decode(&args); err != nil {
+			return nil, fmt.Errorf("%w: failed to decode argument JSON: %v", errInvalidArgument, err)
+		}
+	}
+
+	// go go
+	wf, ok := s.lookupWorkflow(parent)
+	if !ok {
+		return nil, fmt.Errorf("%w: workflow %s", errNotFound, parent)
+	}
+	if ex.CallLogLevel == "" || ex.CallLogLevel == "CALL_LOG_LEVEL_UNSPECIFIED" {
+		// inherit the call log level of the workflow
+		ex.CallLogLevel = wf.callLogLevel
+	}
+	if ex.CallLogLevel == "" {
+		ex.CallLogLevel = "CALL_LOG_LEVEL_UNSPECIFIED"
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.draining {
+		return nil, fmt.Errorf("%w: the emulator is shutting down", errUnavailable)
+	}
+
+	id := fmt.Sprintf("00000000-0000-0000-0000-%012x", atomic.AddUint64(&s.idBase, 1))
+	ex.Name = parent + "/executions/" + id
+	ex.StartTime = time.Now().UTC()
+	ex.State = "ACTIVE"
+	ex.WorkflowRevisionId = wf.revisionID
+	ex.eventsUpdated = make(chan struct{})
+	ex.gate = &workflow.StepGate{}
+	ex.done = make(chan struct{})
+	ctx, cancel := context.WithCancel(s.ctx)
+	ex.cancel = cancel
+	snapshot := ex.withView("FULL")
+	s.executions.Store(ex.Name, ex)
+
+	// the project of the path is the project number if it's numeric
+	info := workflow.ExecutionInfo{
+		ProjectID:   m[1],
+		Location:    m[2],
+		WorkflowID:  m[3],
+		RevisionID:  ex.WorkflowRevisionId,
+		ExecutionID: id,
+	}
+	if numericRegexp.MatchString(m[1]) {
+		info.ProjectID, info.ProjectNumber = "", m[1]
+	}
+	opts := append(s.executeOpts[:len(s.executeOpts):len(s.executeOpts)],
+		workflow.WithExecutionInfo(info),
+		workflow.WithEnv(wf.userEnvVars),
+		workflow.WithLogRecorder(&executionLogs{ex: ex}),
+		workflow.WithCallLogLevel(ex.CallLogLevel),
+		workflow.WithStepObserver(&executionSteps{ex: ex}),
+		workflow.WithStepGate(ex.gate),
+		workflow.WithWorkflowResolver(s.workflowResolver(m[1], m[2])),
+	)
+	if s.callbackBaseURL != "" {
+		opts = append(opts, workflow.WithCallbackRegistry(&executionCallbacks{store: s, name: ex.Name}))
+	}
+	s.inflight.Add(1)
+	go s.execute(ctx, wf.root, ex, args, opts)
+	return snapshot, nil
+}
+
+// Shutdown stops accepting the new executions, and waits for the in-flight executions to finish.
+// The executions not finished until the context is done are cancelled at the next step boundary, and it waits for them to stop.
+func (s *ExecutionStore) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *ExecutionStore) execute(ctx context.Context, root workflow.WorkflowRoot, ex *execution, args any, opts []workflow.ExecuteOption) {
+	defer s.inflight.Done()
+	defer close(ex.done)
+	defer ex.cancel()
+	ret, err := root.Execute(ctx, args, opts...)
+	s.deleteCallbacks(ex.Name)
+	ex.gate.Resume() // the last step may finish while it's paused
+	if err == nil {
+		ex.mu.Lock()
+		defer ex.mu.Unlock()
+		ex.finishLocked()
+		ex.State = "SUCCEEDED"
+		var s strings.Builder
+		if dumpErr := json.NewEncoder(&s).Encode(ret); dumpErr != nil {
+			logging.Logf(logging.LevelError, "failed to encode workflow result", "error", dumpErr, "execution", ex.Name, "result", ret)
+		} else {
+			ex.Result = strings.TrimSuffix(s.String(), "\n")
+		}
+		ex.Paused = false
+		ex.publishLocked(&executionEvent{Type: "finished", State: ex.State})
+		return
+	}
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.finishLocked()
+	ex.State = "FAILED"
+	ex.Error = newExecutionError(err)
+
+	var exception types.Exception
+	if errors.Is(err, context.Canceled) {
+		ex.State = "CANCELLED"
+		ex.StateError = &stateError{Details: "the execution is cancelled", Type: "TYPE_UNSPECIFIED"}
+	} else if !errors.As(err, &exception) {
+		logging.Logf(logging.LevelError, "failed to execute workflow", "error", err, "execution", ex.Name)
+		ex.StateError = &stateError{Details: err.Error(), Type: "TYPE_UNSPECIFIED"}
+	}
+	ex.Paused = false
+	ex.publishLocked(&executionEvent{Type: "finished", State: ex.State})
+}
+
+// finishLocked sets the end time and the duration of the execution. The execution must be locked by the caller.
+func (ex *execution) finishLocked() {
+	endTime := time.Now().UTC()
+	ex.EndTime = &endTime
+	ex.Duration = formatDuration(endTime.Sub(ex.StartTime))
+}
+
+// load returns the execution specified by the name like projects/*/locations/*/workflows/*/executions/*.
+func (s *ExecutionStore) load(name string) (*execution, error) {
+	m := executionNameRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid name: %q", errInvalidArgument, name)
+	}
+	if err := s.checkLocation(m[1], m[2]); err != nil {
+		return nil, err
+	}
+
+	ret, ok := s.executions.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: execution %s", errNotFound, name)
+	}
+	return ret.(*execution), nil
+}
+
+// get returns the snapshot of the execution specified by the name in the view.
+func (s *ExecutionStore) get(name, view string) (*execution, error) {
+	ex, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+	return ex.withView(view), nil
+}
+
+// cancelExecution cancels the active execution at the next step boundary, and returns the snapshot of it after it's
+// stopped, or the error if the context is done before it.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rest/v1/projects.locations.workflows.executions/cancel
+func (s *ExecutionStore) cancelExecution(ctx context.Context, name string) (*execution, error) {
+	ex, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ex.mu.RLock()
+	state := ex.State
+	ex.mu.RUnlock()
+	if state != "ACTIVE" {
+		return nil, fmt.Errorf("%w: execution %s is %s", errPrecondition, name, state)
+	}
+
+	ex.cancel()
+	select {
+	case <-ex.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+	return ex.withView("FULL"), nil
+}
+
+// pause pauses the active execution at the next step boundary, and returns the snapshot of it.
+func (s *ExecutionStore) pause(name string) (*execution, error) {
+	ex, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if ex.State != "ACTIVE" {
+		return nil, fmt.Errorf("%w: execution %s is %s", errPrecondition, name, ex.State)
+	}
+	if ex.gate.Pause() {
+		ex.Paused = true
+		ex.publishLocked(&executionEvent{Type: "paused"})
+	}
+	return ex.withView("BASIC"), nil
+}
+
+// resume resumes the paused execution, and returns the snapshot of it.
+func (s *ExecutionStore) resume(name string) (*execution, error) {
+	ex, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if ex.gate.Resume() {
+		ex.Paused = false
+		ex.publishLocked(&executionEvent{Type: "resumed"})
+	}
+	return ex.withView("BASIC"), nil
+}
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// normalizePageSize returns the page size applied for the requested one. It must not be negative.
+func normalizePageSize(n int) int {
+	if n > maxPageSize {
+		return maxPageSize
+	} else if n == 0 {
+		return defaultPageSize
+	}
+	return n
+}
+
+// list returns the snapshots of a page of the executions of the workflow specified by the parent in the view,
+// and the token of the next page if exists.
+func (s *ExecutionStore) list(parent string, filter executionFilter, order executionOrder, view string, pageSize int, pageToken string) ([]*execution, string, error) {
+	m := workflowNameRegexp.FindStringSubmatch(parent)
+	if m == nil {
+		return nil, "", fmt.Errorf("%w: invalid parent: %q", errInvalidArgument, parent)
+	}
+	if err := s.checkLocation(m[1], m[2]); err != nil {
+		return nil, "", err
+	}
+
+	offset := 0
+	if pageToken != "" {
+		var err error
+		offset, err = decodePageToken(pageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: invalid pageToken", errInvalidArgument)
+		}
+	}
+
+	results := []*execution{}
+	s.executions.Range(func(key, value any) bool {
+		if strings.HasPrefix(key.(string), parent+"/executions/") {
+			results = append(results, value.(*execution))
+		}
+		return true
+	})
+	for _, ex := range results {
+		ex.mu.RLock()
+	}
+	defer func() {
+		for _, ex := range results {
+			ex.mu.RUnlock()
+		}
+	}()
+	matched := lo.Filter(results, func(ex *execution, _ int) bool {
+		return filter.match(ex)
+	})
+	sort.Slice(matched, func(i, j int) bool {
+		return order.less(matched[i], matched[j])
+	})
+
+	page := []*execution{}
+	if offset < len(matched) {
+		page = matched[offset:]
+	}
+	nextPageToken := ""
+	if len(page) > pageSize {
+		page = page[:pageSize]
+		nextPageToken = encodePageToken(offset + pageSize)
+	}
+	return lo.Map(page, func(ex *execution, _ int) *execution {
+		return ex.withView(view)
+	}), nextPageToken, nil
+}
+
+// encodePageToken encodes the offset of the next page as the opaque token.
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(string(b), "offset:") {
+		return 0, fmt.Errorf("invalid page token: %q", token)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(b), "offset:"))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid page token: %q", token)
+	}
+	return offset, nil
+}
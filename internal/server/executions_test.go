@@ -0,0 +1,895 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+)
+
+const testExecutionsWorkflow = `
+main:
+  params: [args]
+  steps:
+    - done:
+        return: ${args}
+`
+
+// runExecutions runs the executions of the workflow with the requests one by one, and returns the names of them.
+func runExecutions(t *testing.T, baseURL, workflowID string, requests []map[string]any) []string {
+	t.Helper()
+
+	names := make([]string, 0, len(requests))
+	for _, req := range requests {
+		var ex map[string]any
+		if status := doJSON(t, http.MethodPost, baseURL+testWorkflowsPath+"/"+workflowID+"/executions", req, &ex); status != http.StatusOK {
+			t.Fatalf("unexpected status: %d", status)
+		}
+		waitExecution(t, baseURL+"/v1/"+ex["name"].(string))
+		names = append(names, ex["name"].(string))
+	}
+	return names
+}
+
+type listExecutionsPage struct {
+	Executions []struct {
+		Name string `json:"name"`
+	} `json:"executions"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func TestListExecutionsPagination(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{"wf": testExecutionsWorkflow})
+	names := runExecutions(t, ts.URL, "wf", []map[string]any{{}, {}, {}, {}, {}})
+
+	for _, tt := range []struct {
+		name           string
+		query          url.Values
+		expected       [][]string
+		expectedStatus int
+	}{
+		{
+			name:           "default page size",
+			query:          url.Values{},
+			expected:       [][]string{names},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "zero page size is the default one",
+			query:          url.Values{"pageSize": {"0"}},
+			expected:       [][]string{names},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "page size 2",
+			query:          url.Values{"pageSize": {"2"}},
+			expected:       [][]string{names[0:2], names[2:4], names[4:5]},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "page size dividing the executions",
+			query:          url.Values{"pageSize": {"5"}},
+			expected:       [][]string{names},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "page size 1",
+			query:          url.Values{"pageSize": {"1"}},
+			expected:       [][]string{names[0:1], names[1:2], names[2:3], names[3:4], names[4:5]},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "negative page size",
+			query:          url.Values{"pageSize": {"-1"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "non-numeric page size",
+			query:          url.Values{"pageSize": {"two"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid page token",
+			query:          url.Values{"pageToken": {"invalid"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var pages [][]string
+			query := tt.query
+			for {
+				var page listExecutionsPage
+				status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/wf/executions?"+query.Encode(), nil, &page)
+				if status != tt.expectedStatus {
+					t.Fatalf("unexpected status: %d", status)
+				}
+				if status != http.StatusOK {
+					return
+				}
+
+				names := []string{}
+				for _, ex := range page.Executions {
+					names = append(names, ex.Name)
+				}
+				pages = append(pages, names)
+				if page.NextPageToken == "" {
+					break
+				}
+				if len(pages) > len(tt.expected) {
+					t.Fatalf("too many pages: %v", pages)
+				}
+
+				query = url.Values{"pageToken": {page.NextPageToken}}
+				if v := tt.query.Get("pageSize"); v != "" {
+					query.Set("pageSize", v)
+				}
+			}
+			if diff := cmp.Diff(tt.expected, pages); diff != "" {
+				t.Errorf("unexpected pages (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListExecutionsFilterAndOrderBy(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{"wf": `
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${map.get(args, "fail") == true}
+            raise: failed
+    - done:
+        return: ${args}
+`})
+	names := runExecutions(t, ts.URL, "wf", []map[string]any{
+		{"argument": `{}`, "labels": map[string]string{"env": "prod", "team": "a"}},
+		{"argument": `{"fail":true}`, "labels": map[string]string{"env": "dev"}},
+		{"argument": `{}`, "labels": map[string]string{"env": "dev"}},
+		{"argument": `{"fail":true}`},
+	})
+
+	var second map[string]any
+	if status := doJSON(t, http.MethodGet, ts.URL+"/v1/"+names[1], nil, &second); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	secondStartTime := second["startTime"].(string)
+
+	for _, tt := range []struct {
+		name           string
+		filter         string
+		orderBy        string
+		expected       []int
+		expectedStatus int
+	}{
+		{
+			name:           "no filter",
+			expected:       []int{0, 1, 2, 3},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "state",
+			filter:         `state="SUCCEEDED"`,
+			expected:       []int{0, 2},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not state",
+			filter:         `state!="SUCCEEDED"`,
+			expected:       []int{1, 3},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "label",
+			filter:         `labels.env="dev"`,
+			expected:       []int{1, 2},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not label matches the executions without it",
+			filter:         `labels.env!="dev"`,
+			expected:       []int{0, 3},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "has label",
+			filter:         `labels.env:*`,
+			expected:       []int{0, 1, 2},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "startTime range",
+			filter:         `startTime>"` + secondStartTime + `"`,
+			expected:       []int{2, 3},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "startTime inclusive range",
+			filter:         `startTime<="` + secondStartTime + `"`,
+			expected:       []int{0, 1},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "terms joined by AND",
+			filter:         `state="FAILED" AND labels.env="dev"`,
+			expected:       []int{1},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "name",
+			filter:         `name="` + names[3] + `"`,
+			expected:       []int{3},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "no matches",
+			filter:         `labels.team="b"`,
+			expected:       []int{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "startTime desc",
+			orderBy:        "startTime desc",
+			expected:       []int{3, 2, 1, 0},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "state and startTime desc",
+			orderBy:        "state, startTime desc",
+			expected:       []int{3, 1, 2, 0},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "state desc broken the tie by startTime",
+			orderBy:        "state desc",
+			expected:       []int{0, 2, 1, 3},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "filter and orderBy",
+			filter:         `state="SUCCEEDED"`,
+			orderBy:        "startTime desc",
+			expected:       []int{2, 0},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unsupported filter field",
+			filter:         `argument="{}"`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported operator",
+			filter:         `state>"FAILED"`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid timestamp",
+			filter:         `startTime>"yesterday"`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid filter term",
+			filter:         `state`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported orderBy field",
+			orderBy:        "labels",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid orderBy direction",
+			orderBy:        "startTime up",
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := url.Values{"filter": {tt.filter}, "orderBy": {tt.orderBy}}
+			var page listExecutionsPage
+			status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/wf/executions?"+query.Encode(), nil, &page)
+			if status != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			actual := []int{}
+			for _, ex := range page.Executions {
+				actual = append(actual, lo.IndexOf(names, ex.Name))
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected executions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExecutionView(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{"wf": testExecutionsWorkflow})
+	names := runExecutions(t, ts.URL, "wf", []map[string]any{{"argument": `{"foo":"bar"}`}})
+
+	for _, tt := range []struct {
+		name           string
+		list           bool
+		view           string
+		expected       map[string]bool
+		expectedStatus int
+	}{
+		{
+			name:           "get defaults to FULL",
+			expected:       map[string]bool{"argument": true, "result": true},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "get with unspecified view",
+			view:           "EXECUTION_VIEW_UNSPECIFIED",
+			expected:       map[string]bool{"argument": true, "result": true},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "get with BASIC",
+			view:           "BASIC",
+			expected:       map[string]bool{"argument": false, "result": false},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "list defaults to BASIC",
+			list:           true,
+			expected:       map[string]bool{"argument": false, "result": false},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "list with FULL",
+			list:           true,
+			view:           "FULL",
+			expected:       map[string]bool{"argument": true, "result": true},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "get with invalid view",
+			view:           "ALL",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "list with invalid view",
+			list:           true,
+			view:           "ALL",
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := url.Values{}
+			if tt.view != "" {
+				query.Set("view", tt.view)
+			}
+
+			var ex map[string]any
+			if tt.list {
+				var res struct {
+					Executions []map[string]any `json:"executions"`
+				}
+				status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/wf/executions?"+query.Encode(), nil, &res)
+				if status != tt.expectedStatus {
+					t.Fatalf("unexpected status: %d", status)
+				}
+				if status != http.StatusOK {
+					return
+				}
+				if len(res.Executions) != 1 {
+					t.Fatalf("unexpected executions: %v", res.Executions)
+				}
+				ex = res.Executions[0]
+			} else {
+				status := doJSON(t, http.MethodGet, ts.URL+"/v1/"+names[0]+"?"+query.Encode(), nil, &ex)
+				if status != tt.expectedStatus {
+					t.Fatalf("unexpected status: %d", status)
+				}
+				if status != http.StatusOK {
+					return
+				}
+			}
+
+			actual := map[string]bool{}
+			for key := range tt.expected {
+				_, actual[key] = ex[key]
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected fields (-want +got):\n%s", diff)
+			}
+			if ex["state"] != "SUCCEEDED" {
+				t.Errorf("unexpected state: %v", ex["state"])
+			}
+		})
+	}
+}
+
+func TestExecutionError(t *testing.T) {
+	t.Parallel()
+
+	sources := map[string]string{
+		"string": `
+main:
+  steps:
+    - fail:
+        raise: failed
+`,
+		"map": `
+main:
+  steps:
+    - fail:
+        raise:
+          code: 400
+          message: bad request
+`,
+		"builtin": `
+main:
+  params: [args]
+  steps:
+    - get:
+        return: ${args.missing}
+`,
+		"subworkflow": `
+main:
+  steps:
+    - callSub:
+        call: sub
+        result: r
+    - done:
+        return: ${r}
+sub:
+  steps:
+    - fail:
+        raise: failed in sub
+`,
+		"nested": `
+main:
+  steps:
+    - outer:
+        steps:
+          - inner:
+              raise: nested
+`,
+		"succeeded": `
+main:
+  steps:
+    - done:
+        return: ok
+`,
+	}
+	_, ts := newTestServer(t, sources)
+
+	for _, tt := range []struct {
+		workflowID string
+		expected   map[string]any
+	}{
+		{
+			workflowID: "string",
+			expected: map[string]any{
+				"payload": `"failed"`,
+				"context": "RuntimeError: \"failed\"\nin step \"fail\", routine \"main\", line: 4",
+				"stackTrace": map[string]any{"elements": []any{
+					map[string]any{"step": "fail", "routine": "main", "position": map[string]any{"line": "4", "column": "7", "length": "4"}},
+				}},
+			},
+		},
+		{
+			workflowID: "map",
+			expected: map[string]any{
+				"payload": `{"code":400,"message":"bad request"}`,
+				"context": "RuntimeError: {\"code\":400,\"message\":\"bad request\"}\nin step \"fail\", routine \"main\", line: 4",
+				"stackTrace": map[string]any{"elements": []any{
+					map[string]any{"step": "fail", "routine": "main", "position": map[string]any{"line": "4", "column": "7", "length": "4"}},
+				}},
+			},
+		},
+		{
+			workflowID: "builtin",
+			expected: map[string]any{
+				"payload": `{"message":"KeyError: args.missing: not found","tags":["KeyError"]}`,
+				"context": "KeyError: args.missing: not found\nin step \"get\", routine \"main\", line: 5",
+				"stackTrace": map[string]any{"elements": []any{
+					map[string]any{"step": "get", "routine": "main", "position": map[string]any{"line": "5", "column": "7", "length": "3"}},
+				}},
+			},
+		},
+		{
+			workflowID: "subworkflow",
+			expected: map[string]any{
+				"payload": `"failed in sub"`,
+				"context": "RuntimeError: \"failed in sub\"\nin step \"fail\", routine \"sub\", line: 11",
+				"stackTrace": map[string]any{"elements": []any{
+					map[string]any{"step": "fail", "routine": "sub", "position": map[string]any{"line": "11", "column": "7", "length": "4"}},
+					map[string]any{"step": "callSub", "routine": "main", "position": map[string]any{"line": "4", "column": "7", "length": "7"}},
+				}},
+			},
+		},
+		{
+			workflowID: "nested",
+			expected: map[string]any{
+				"payload": `"nested"`,
+				"context": "RuntimeError: \"nested\"\nin step \"inner\", routine \"main\", line: 6",
+				"stackTrace": map[string]any{"elements": []any{
+					map[string]any{"step": "inner", "routine": "main", "position": map[string]any{"line": "6", "column": "13", "length": "5"}},
+				}},
+			},
+		},
+		{
+			workflowID: "succeeded",
+		},
+	} {
+		tt := tt
+		t.Run(tt.workflowID, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/"+tt.workflowID+"/executions", map[string]any{"argument": `{}`}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			ex = waitExecution(t, ts.URL+"/v1/"+ex["name"].(string))
+			actual, _ := ex["error"].(map[string]any)
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCallLogLevel(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - get:
+        call: sys.get_env
+        args:
+          name: GOOGLE_CLOUD_WORKFLOW_ID
+          default: none
+    - sleep:
+        try:
+          call: sys.sleep
+          args:
+            seconds: invalid
+        except:
+          as: e
+          steps:
+            - done:
+                return: ok
+`
+	_, ts := newTestServer(t, map[string]string{})
+	for _, wf := range []struct {
+		id           string
+		callLogLevel string
+	}{
+		{id: "wf"},
+		{id: "errors", callLogLevel: "LOG_ERRORS_ONLY"},
+	} {
+		if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"?workflowId="+wf.id, map[string]any{"sourceContents": source, "callLogLevel": wf.callLogLevel}, nil); status != http.StatusOK {
+			t.Fatalf("unexpected status: %d", status)
+		}
+	}
+
+	allCalls := []string{
+		"INFO callStarted sys.get_env",
+		"INFO callSucceeded sys.get_env",
+		"INFO callStarted sys.sleep",
+		"ERROR exceptionRaised sys.sleep",
+	}
+	for _, tt := range []struct {
+		name                 string
+		workflowID           string
+		callLogLevel         string
+		expectedStatus       int
+		expectedCallLogLevel string
+		expected             []string
+	}{
+		{
+			name:                 "all calls",
+			workflowID:           "wf",
+			callLogLevel:         "LOG_ALL_CALLS",
+			expectedStatus:       http.StatusOK,
+			expectedCallLogLevel: "LOG_ALL_CALLS",
+			expected:             allCalls,
+		},
+		{
+			name:                 "errors only",
+			workflowID:           "wf",
+			callLogLevel:         "LOG_ERRORS_ONLY",
+			expectedStatus:       http.StatusOK,
+			expectedCallLogLevel: "LOG_ERRORS_ONLY",
+			expected:             []string{"ERROR exceptionRaised sys.sleep"},
+		},
+		{
+			name:                 "none",
+			workflowID:           "wf",
+			callLogLevel:         "LOG_NONE",
+			expectedStatus:       http.StatusOK,
+			expectedCallLogLevel: "LOG_NONE",
+			expected:             []string{},
+		},
+		{
+			name:                 "unspecified",
+			workflowID:           "wf",
+			expectedStatus:       http.StatusOK,
+			expectedCallLogLevel: "CALL_LOG_LEVEL_UNSPECIFIED",
+			expected:             []string{},
+		},
+		{
+			name:                 "inherited from the workflow",
+			workflowID:           "errors",
+			callLogLevel:         "CALL_LOG_LEVEL_UNSPECIFIED",
+			expectedStatus:       http.StatusOK,
+			expectedCallLogLevel: "LOG_ERRORS_ONLY",
+			expected:             []string{"ERROR exceptionRaised sys.sleep"},
+		},
+		{
+			name:                 "overriding the workflow",
+			workflowID:           "errors",
+			callLogLevel:         "LOG_ALL_CALLS",
+			expectedStatus:       http.StatusOK,
+			expectedCallLogLevel: "LOG_ALL_CALLS",
+			expected:             allCalls,
+		},
+		{
+			name:           "invalid",
+			workflowID:     "wf",
+			callLogLevel:   "LOG_EVERYTHING",
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/"+tt.workflowID+"/executions", map[string]any{"callLogLevel": tt.callLogLevel}, &ex)
+			if status != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if ex["callLogLevel"] != tt.expectedCallLogLevel {
+				t.Errorf("unexpected callLogLevel: %v, want %s", ex["callLogLevel"], tt.expectedCallLogLevel)
+			}
+
+			name := ex["name"].(string)
+			if ex = waitExecution(t, ts.URL+"/v1/"+name); ex["state"] != "SUCCEEDED" {
+				t.Fatalf("unexpected state: %v", ex)
+			}
+			var res struct {
+				Entries []struct {
+					Severity    string                    `json:"severity"`
+					JSONPayload map[string]map[string]any `json:"jsonPayload"`
+				} `json:"entries"`
+			}
+			if status := doJSON(t, http.MethodGet, ts.URL+"/v1/"+name+"/logs", nil, &res); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			actual := []string{}
+			for _, entry := range res.Entries {
+				for kind, payload := range entry.JSONPayload {
+					actual = append(actual, fmt.Sprintf("%s %s %v", entry.Severity, kind, payload["function"]))
+				}
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected call logs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPauseExecution(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{
+		"wf": `
+main:
+  steps:
+    - wait:
+        call: sys.sleep
+        args:
+          seconds: 0.1
+    - done:
+        return: ok
+`,
+	})
+
+	for _, tt := range []struct {
+		name           string
+		requests       []string // the custom methods requested in order
+		expectedStatus []int
+		expectedPaused bool
+		expectedState  string
+	}{
+		{
+			name:           "pause and resume",
+			requests:       []string{"pause", "resume"},
+			expectedStatus: []int{http.StatusOK, http.StatusOK},
+			expectedState:  "SUCCEEDED",
+		},
+		{
+			name:           "pause twice",
+			requests:       []string{"pause", "pause", "resume"},
+			expectedStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+			expectedState:  "SUCCEEDED",
+		},
+		{
+			name:           "resume the running one",
+			requests:       []string{"resume"},
+			expectedStatus: []int{http.StatusOK},
+			expectedState:  "SUCCEEDED",
+		},
+		{
+			name:           "pause",
+			requests:       []string{"pause"},
+			expectedStatus: []int{http.StatusOK},
+			expectedPaused: true,
+			expectedState:  "ACTIVE",
+		},
+		{
+			name:           "cancel the paused one",
+			requests:       []string{"pause", "cancel"},
+			expectedStatus: []int{http.StatusOK, http.StatusOK},
+			expectedState:  "CANCELLED",
+		},
+		{
+			name:           "pause the cancelled one",
+			requests:       []string{"cancel", "pause"},
+			expectedStatus: []int{http.StatusOK, http.StatusBadRequest},
+			expectedState:  "CANCELLED",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/wf/executions", map[string]any{}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			name := ex["name"].(string)
+			t.Cleanup(func() {
+				doJSON(t, http.MethodPost, ts.URL+"/v1/"+name+":cancel", nil, nil)
+			})
+
+			for i, method := range tt.requests {
+				var res map[string]any
+				status := doJSON(t, http.MethodPost, ts.URL+"/v1/"+name+":"+method, nil, &res)
+				if status != tt.expectedStatus[i] {
+					t.Fatalf("unexpected status of %s: %d, want %d", method, status, tt.expectedStatus[i])
+				}
+				if status == http.StatusOK && method != "cancel" {
+					if paused, _ := res["paused"].(bool); paused != (method == "pause") {
+						t.Errorf("unexpected paused of %s: %v", method, res["paused"])
+					}
+				}
+			}
+
+			if tt.expectedState == "ACTIVE" {
+				// the execution is not finished while it's paused
+				time.Sleep(300 * time.Millisecond)
+				if status := doJSON(t, http.MethodGet, ts.URL+"/v1/"+name, nil, &ex); status != http.StatusOK {
+					t.Fatalf("unexpected status: %d", status)
+				}
+			} else {
+				ex = waitExecution(t, ts.URL+"/v1/"+name)
+			}
+			if ex["state"] != tt.expectedState {
+				t.Errorf("unexpected state: %v, want %s", ex["state"], tt.expectedState)
+			}
+			if paused, _ := ex["paused"].(bool); paused != tt.expectedPaused {
+				t.Errorf("unexpected paused: %v", ex["paused"])
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "pause unknown", method: http.MethodPost, path: testWorkflowsPath + "/wf/executions/unknown:pause", expectedStatus: http.StatusNotFound},
+		{name: "resume unknown", method: http.MethodPost, path: testWorkflowsPath + "/wf/executions/unknown:resume", expectedStatus: http.StatusNotFound},
+		{name: "pause by GET", method: http.MethodGet, path: testWorkflowsPath + "/wf/executions/unknown:pause", expectedStatus: http.StatusMethodNotAllowed},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if status := doJSON(t, tt.method, ts.URL+tt.path, nil, nil); status != tt.expectedStatus {
+				t.Errorf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name          string
+		seconds       float64
+		timeout       time.Duration
+		expectedErr   error
+		expectedState string
+	}{
+		{
+			name:          "drained",
+			seconds:       0.1,
+			timeout:       10 * time.Second,
+			expectedState: "SUCCEEDED",
+		},
+		{
+			name:          "cancelled",
+			seconds:       60,
+			timeout:       100 * time.Millisecond,
+			expectedErr:   context.DeadlineExceeded,
+			expectedState: "CANCELLED",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, ts := newTestServer(t, map[string]string{"wf": `
+main:
+  params: [args]
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: ${args.seconds}
+    - done:
+        return: ok
+`})
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/wf/executions", map[string]any{"argument": fmt.Sprintf(`{"seconds":%v}`, tt.seconds)}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := store.Shutdown(ctx); !errors.Is(err, tt.expectedErr) {
+				t.Errorf("unexpected error: %v, want %v", err, tt.expectedErr)
+			}
+
+			// the in-flight execution is finished when the shutdown returns
+			if status := doJSON(t, http.MethodGet, ts.URL+"/v1/"+ex["name"].(string), nil, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if ex["state"] != tt.expectedState {
+				t.Errorf("unexpected state: %v, want %s", ex["state"], tt.expectedState)
+			}
+
+			// the new executions are not accepted
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/wf/executions", map[string]any{"argument": `{"seconds":0}`}, nil); status != http.StatusServiceUnavailable {
+				t.Errorf("unexpected status: %d", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package server
+
+// Reload reloads the workflows by the loader of the store like the changes of the files are detected.
+func (s *ExecutionStore) Reload() error {
+	return s.reload()
+}
+
+// DiffLines is the unified diff of the sources logged on the revisions.
+var DiffLines = diffLines
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// executionFilter is the filter of the executions list like `state="SUCCEEDED" AND startTime>"2023-01-01T00:00:00Z"`.
+// It supports the comparisons of state, name, workflowRevisionId, startTime, endTime and labels.* joined by AND.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rest/v1/projects.locations.workflows.executions/list
+type executionFilter []executionFilterTerm
+
+type executionFilterTerm struct {
+	field    string
+	operator string
+	value    string
+}
+
+var executionFilterAndRegexp = regexp.MustCompile(`\s+AND\s+`)
+
+var executionFilterTermRegexp = regexp.MustCompile(`^\s*([A-Za-z][\w.]*)\s*(!=|>=|<=|=|>|<|:)\s*("(?:[^"\\]|\\.)*"|\S+)\s*$`)
+
+func parseExecutionFilter(filter string) (executionFilter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+
+	var terms executionFilter
+	for _, s := range executionFilterAndRegexp.Split(filter, -1) {
+		m := executionFilterTermRegexp.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("invalid filter term: %q", s)
+		}
+
+		value := m[3]
+		if strings.HasPrefix(value, `"`) {
+			var err error
+			value, err = strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter value %s: %w", m[3], err)
+			}
+		}
+
+		term := executionFilterTerm{field: m[1], operator: m[2], value: value}
+		switch {
+		case term.field == "startTime" || term.field == "endTime":
+			if _, err := time.Parse(time.RFC3339Nano, term.value); err != nil {
+				return nil, fmt.Errorf("invalid timestamp of %s: %w", term.field, err)
+			}
+		case term.field == "state" || term.field == "name" || term.field == "workflowRevisionId" || strings.HasPrefix(term.field, "labels."):
+			if term.operator != "=" && term.operator != "!=" && term.operator != ":" {
+				return nil, fmt.Errorf("unsupported operator %s for %s", term.operator, term.field)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported filter field: %s", term.field)
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// match reports whether the execution matches all of the terms. The execution must be locked by the caller.
+func (f executionFilter) match(ex *execution) bool {
+	for _, term := range f {
+		if !term.match(ex) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *executionFilterTerm) match(ex *execution) bool {
+	switch t.field {
+	case "startTime":
+		return t.compareTime(ex.StartTime)
+	case "endTime":
+		if ex.EndTime == nil {
+			return false
+		}
+		return t.compareTime(*ex.EndTime)
+	case "state":
+		return t.compareString(ex.State, true)
+	case "name":
+		return t.compareString(ex.Name, true)
+	case "workflowRevisionId":
+		return t.compareString(ex.WorkflowRevisionId, true)
+	default:
+		value, ok := ex.Labels[strings.TrimPrefix(t.field, "labels.")]
+		return t.compareString(value, ok)
+	}
+}
+
+func (t *executionFilterTerm) compareString(value string, exists bool) bool {
+	switch t.operator {
+	case "=":
+		return exists && value == t.value
+	case "!=":
+		return !exists || value != t.value
+	default: // ":" means has
+		return exists && (t.value == "*" || strings.Contains(value, t.value))
+	}
+}
+
+func (t *executionFilterTerm) compareTime(value time.Time) bool {
+	v, _ := time.Parse(time.RFC3339Nano, t.value) // validated by parseExecutionFilter
+	switch t.operator {
+	case "=", ":":
+		return value.Equal(v)
+	case "!=":
+		return !value.Equal(v)
+	case ">":
+		return value.After(v)
+	case ">=":
+		return !value.Before(v)
+	case "<":
+		return value.Before(v)
+	default: // "<="
+		return !value.After(v)
+	}
+}
+
+// executionOrder is the orderBy of the executions list like `startTime desc, name`.
+type executionOrder []executionOrderKey
+
+type executionOrderKey struct {
+	field string
+	desc  bool
+}
+
+func parseExecutionOrder(orderBy string) (executionOrder, error) {
+	var order executionOrder
+	for _, s := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 || (len(fields) == 2 && fields[1] != "asc" && fields[1] != "desc") {
+			return nil, fmt.Errorf("invalid orderBy: %q", s)
+		}
+
+		key := executionOrderKey{field: fields[0], desc: len(fields) == 2 && fields[1] == "desc"}
+		switch key.field {
+		case "startTime", "endTime", "state", "name", "workflowRevisionId":
+		default:
+			return nil, fmt.Errorf("unsupported orderBy field: %s", key.field)
+		}
+		order = append(order, key)
+	}
+
+	// the default order and the tie-breaker for the stable pagination
+	return append(order, executionOrderKey{field: "startTime"}, executionOrderKey{field: "name"}), nil
+}
+
+// less reports whether a precedes b. The executions must be locked by the caller.
+func (o executionOrder) less(a, b *execution) bool {
+	for _, key := range o {
+		c := key.compare(a, b)
+		if c == 0 {
+			continue
+		}
+		if key.desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+func (k *executionOrderKey) compare(a, b *execution) int {
+	switch k.field {
+	case "startTime":
+		return compareTime(a.StartTime, b.StartTime)
+	case "endTime":
+		return compareTime(timeOrZero(a.EndTime), timeOrZero(b.EndTime))
+	case "state":
+		return strings.Compare(a.State, b.State)
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	default: // "workflowRevisionId"
+		return strings.Compare(a.WorkflowRevisionId, b.WorkflowRevisionId)
+	}
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// timeOrZero returns the time, or the zero time of the active executions to order them first.
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
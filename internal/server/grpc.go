@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	executionspb "google.golang.org/genproto/googleapis/cloud/workflows/executions/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcExecutionsServer is the google.cloud.workflows.executions.v1.Executions service of the executions in the store.
+// refs. https://cloud.google.com/workflows/docs/reference/executions/rpc/google.cloud.workflows.executions.v1
+type grpcExecutionsServer struct {
+	executionspb.UnimplementedExecutionsServer
+	store *ExecutionStore
+}
+
+// NewGRPCServer returns the gRPC server of the executions in the store configured by the options (e.g. TLS).
+// Point the client libraries at it with the endpoint option and without any credentials.
+func NewGRPCServer(store *ExecutionStore, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append([]grpc.ServerOption{grpc.UnaryInterceptor(grpcAccessLog)}, opts...)...)
+	executionspb.RegisterExecutionsServer(s, &grpcExecutionsServer{store: store})
+	return s
+}
+
+func (s *grpcExecutionsServer) ListExecutions(_ context.Context, req *executionspb.ListExecutionsRequest) (*executionspb.ListExecutionsResponse, error) {
+	if req.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_size")
+	}
+
+	order, err := parseExecutionOrder("")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	executions, nextPageToken, err := s.store.list(req.GetParent(), nil, order, grpcExecutionView(req.GetView(), "BASIC"), normalizePageSize(int(req.GetPageSize())), req.GetPageToken())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &executionspb.ListExecutionsResponse{
+		Executions:    make([]*executionspb.Execution, len(executions)),
+		NextPageToken: nextPageToken,
+	}
+	for i, ex := range executions {
+		res.Executions[i] = ex.toProto()
+	}
+	return res, nil
+}
+
+func (s *grpcExecutionsServer) CreateExecution(_ context.Context, req *executionspb.CreateExecutionRequest) (*executionspb.Execution, error) {
+	ex, err := s.store.create(req.GetParent(), &execution{
+		Argument:     req.GetExecution().GetArgument(),
+		CallLogLevel: req.GetExecution().GetCallLogLevel().String(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return ex.toProto(), nil
+}
+
+func (s *grpcExecutionsServer) GetExecution(_ context.Context, req *executionspb.GetExecutionRequest) (*executionspb.Execution, error) {
+	ex, err := s.store.get(req.GetName(), grpcExecutionView(req.GetView(), "FULL"))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return ex.toProto(), nil
+}
+
+func (s *grpcExecutionsServer) CancelExecution(ctx context.Context, req *executionspb.CancelExecutionRequest) (*executionspb.Execution, error) {
+	ex, err := s.store.cancelExecution(ctx, req.GetName())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return ex.toProto(), nil
+}
+
+func grpcExecutionView(view executionspb.ExecutionView, defaultView string) string {
+	if view == executionspb.ExecutionView_EXECUTION_VIEW_UNSPECIFIED {
+		return defaultView
+	}
+	return view.String()
+}
+
+func grpcError(err error) error {
+	if errors.Is(err, errInvalidArgument) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, errNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, errPrecondition) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, errUnavailable) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	logging.Logf(logging.LevelError, "failed to handle gRPC request", "error", err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+// toProto converts the snapshot of the execution to the message of the gRPC API.
+// The duration and the stateError are not mapped, because the pinned genproto doesn't have the fields of them yet.
+func (ex *execution) toProto() *executionspb.Execution {
+	ret := &executionspb.Execution{
+		Name:               ex.Name,
+		StartTime:          timestamppb.New(ex.StartTime),
+		State:              executionspb.Execution_State(executionspb.Execution_State_value[ex.State]),
+		Argument:           ex.Argument,
+		Result:             ex.Result,
+		WorkflowRevisionId: ex.WorkflowRevisionId,
+		CallLogLevel:       executionspb.Execution_CallLogLevel(executionspb.Execution_CallLogLevel_value[ex.CallLogLevel]),
+	}
+	if ex.EndTime != nil {
+		ret.EndTime = timestamppb.New(*ex.EndTime)
+	}
+	if ex.Error != nil {
+		ret.Error = &executionspb.Execution_Error{Payload: ex.Error.Payload, Context: ex.Error.Context}
+		if ex.Error.StackTrace != nil {
+			ret.Error.StackTrace = &executionspb.Execution_StackTrace{}
+			for _, e := range ex.Error.StackTrace.Elements {
+				element := &executionspb.Execution_StackTraceElement{Step: e.Step, Routine: e.Routine}
+				if e.Position != nil {
+					element.Position = &executionspb.Execution_StackTraceElement_Position{
+						Line:   int64(e.Position.Line),
+						Column: int64(e.Position.Column),
+						Length: int64(e.Position.Length),
+					}
+				}
+				ret.Error.StackTrace.Elements = append(ret.Error.StackTrace.Elements, element)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,190 @@
+package server_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+	executionspb "google.golang.org/genproto/googleapis/cloud/workflows/executions/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testWorkflowName = "projects/my-project/locations/us-central1/workflows/sample"
+
+func newTestGRPCClient(t *testing.T, store *server.ExecutionStore) executionspb.ExecutionsClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := server.NewGRPCServer(store)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return executionspb.NewExecutionsClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	t.Parallel()
+
+	store, _ := newTestServer(t, map[string]string{
+		"sample": `
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${args.sleep}
+            next: sleep
+    - done:
+        return: ${args.name}
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 60
+`,
+	})
+	client := newTestGRPCClient(t, store)
+	ctx := context.Background()
+
+	created, err := client.CreateExecution(ctx, &executionspb.CreateExecutionRequest{
+		Parent:    testWorkflowName,
+		Execution: &executionspb.Execution{Argument: `{"name":"alice","sleep":false}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetState() != executionspb.Execution_ACTIVE {
+		t.Errorf("unexpected state: %v", created.GetState())
+	}
+
+	var got *executionspb.Execution
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		got, err = client.GetExecution(ctx, &executionspb.GetExecutionRequest{Name: created.GetName()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.GetState() != executionspb.Execution_ACTIVE {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("execution is not finished: %v", got)
+		}
+	}
+	if got.GetState() != executionspb.Execution_SUCCEEDED || got.GetResult() != `"alice"` {
+		t.Errorf("unexpected execution: %v", got)
+	}
+	if got.GetEndTime() == nil {
+		t.Error("end_time of the succeeded execution should be set")
+	}
+
+	listed, err := client.ListExecutions(ctx, &executionspb.ListExecutionsRequest{Parent: testWorkflowName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.GetExecutions()) != 1 || listed.GetExecutions()[0].GetName() != created.GetName() {
+		t.Errorf("unexpected executions: %v", listed.GetExecutions())
+	}
+	if listed.GetExecutions()[0].GetArgument() != "" {
+		t.Errorf("argument should be omitted in the BASIC view: %v", listed.GetExecutions()[0])
+	}
+
+	sleeping, err := client.CreateExecution(ctx, &executionspb.CreateExecutionRequest{
+		Parent:    testWorkflowName,
+		Execution: &executionspb.Execution{Argument: `{"sleep":true}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := client.CancelExecution(ctx, &executionspb.CancelExecutionRequest{Name: sleeping.GetName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.GetState() != executionspb.Execution_CANCELLED {
+		t.Errorf("unexpected state: %v", cancelled.GetState())
+	}
+
+	for _, tt := range []struct {
+		name     string
+		call     func() error
+		expected codes.Code
+	}{
+		{
+			name: "get unknown execution",
+			call: func() error {
+				_, err := client.GetExecution(ctx, &executionspb.GetExecutionRequest{Name: testWorkflowName + "/executions/unknown"})
+				return err
+			},
+			expected: codes.NotFound,
+		},
+		{
+			name: "cancel unknown execution",
+			call: func() error {
+				_, err := client.CancelExecution(ctx, &executionspb.CancelExecutionRequest{Name: testWorkflowName + "/executions/unknown"})
+				return err
+			},
+			expected: codes.NotFound,
+		},
+		{
+			name: "list executions of invalid parent",
+			call: func() error {
+				_, err := client.ListExecutions(ctx, &executionspb.ListExecutionsRequest{Parent: "invalid"})
+				return err
+			},
+			expected: codes.InvalidArgument,
+		},
+		{
+			name: "get execution of invalid name",
+			call: func() error {
+				_, err := client.GetExecution(ctx, &executionspb.GetExecutionRequest{Name: "invalid"})
+				return err
+			},
+			expected: codes.InvalidArgument,
+		},
+		{
+			name: "create execution of invalid argument",
+			call: func() error {
+				_, err := client.CreateExecution(ctx, &executionspb.CreateExecutionRequest{
+					Parent:    testWorkflowName,
+					Execution: &executionspb.Execution{Argument: "{"},
+				})
+				return err
+			},
+			expected: codes.InvalidArgument,
+		},
+		{
+			name: "list executions of invalid page size",
+			call: func() error {
+				_, err := client.ListExecutions(ctx, &executionspb.ListExecutionsRequest{Parent: testWorkflowName, PageSize: -1})
+				return err
+			},
+			expected: codes.InvalidArgument,
+		},
+		{
+			name: "cancel finished execution",
+			call: func() error {
+				_, err := client.CancelExecution(ctx, &executionspb.CancelExecutionRequest{Name: created.GetName()})
+				return err
+			},
+			expected: codes.FailedPrecondition,
+		},
+	} {
+		if code := status.Code(tt.call()); code != tt.expected {
+			t.Errorf("%s: unexpected code: %v, want %v", tt.name, code, tt.expected)
+		}
+	}
+}
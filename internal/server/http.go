@@ -4,44 +4,81 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
-	"time"
 
 	"github.com/goccy/go-json"
-	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
-	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
 )
 
-var basePathRegexp = regexp.MustCompile(`^/v1/projects/[^/]+/locations/[^/]+/workflows/[^/]+/executions`)
+var basePathRegexp = regexp.MustCompile(`^/v1/projects/([^/]+)/locations/([^/]+)/workflows/([^/]+)/executions`)
 
-type execution struct {
-	mu sync.RWMutex
+var workflowsPathRegexp = regexp.MustCompile(`^/v1/(projects/([^/]+)/locations/([^/]+))/workflows(?:/([^/]+))?$`)
 
-	Name               string    `json:"name"`
-	StartTime          time.Time `json:"startTime"`
-	EndTime            time.Time `json:"endTime,omitempty"`
-	State              string    `json:"state"`
-	Error              string    `json:"error,omitempty"`
-	Argument           string    `json:"argument"`
-	Result             string    `json:"result,omitempty"`
-	WorkflowRevisionId string    `json:"workflowRevisionId"`
-	CallLogLevel       string    `json:"callLogLevel"`
+var callbacksPathRegexp = regexp.MustCompile(`^/v1/(projects/[^/]+/locations/[^/]+/workflows/[^/]+/executions/[^/]+)/callbacks(?:/([^/]+))?$`)
+
+var logsPathRegexp = regexp.MustCompile(`^/v1/(projects/[^/]+/locations/[^/]+/workflows/[^/]+/executions/[^/]+)/logs$`)
+
+var eventsPathRegexp = regexp.MustCompile(`^/v1/(projects/[^/]+/locations/[^/]+/workflows/[^/]+/executions/[^/]+)/events$`)
+
+var operationsPathRegexp = regexp.MustCompile(`^/v1/(projects/([^/]+)/locations/([^/]+))/operations(?:/([^/]+))?$`)
+
+// parseExecutionView parses the view parameter, which defaults to defaultView.
+func parseExecutionView(r *http.Request, defaultView string) (string, error) {
+	switch view := r.URL.Query().Get("view"); view {
+	case "", "EXECUTION_VIEW_UNSPECIFIED":
+		return defaultView, nil
+	case "BASIC", "FULL":
+		return view, nil
+	default:
+		return "", fmt.Errorf("invalid view: %q", view)
+	}
 }
 
 type httpHandler struct {
-	workflowRoot atomic.Value
-	idBase       uint64
-	executions   sync.Map
+	store *ExecutionStore
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m := workflowsPathRegexp.FindStringSubmatch(r.URL.Path); m != nil {
+		if err := h.store.checkLocation(m[2], m[3]); err != nil {
+			httpError(w, err)
+			return
+		}
+		h.serveWorkflows(w, r, m[1], m[4])
+		return
+	}
+	if m := operationsPathRegexp.FindStringSubmatch(r.URL.Path); m != nil {
+		if err := h.store.checkLocation(m[2], m[3]); err != nil {
+			httpError(w, err)
+			return
+		}
+		h.serveOperations(w, r, m[1], m[4])
+		return
+	}
+	if m := callbacksPathRegexp.FindStringSubmatch(r.URL.Path); m != nil {
+		h.serveCallbacks(w, r, m[1], m[2])
+		return
+	}
+	if m := logsPathRegexp.FindStringSubmatch(r.URL.Path); m != nil {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.listLogs(w, r, m[1])
+		return
+	}
+	if m := eventsPathRegexp.FindStringSubmatch(r.URL.Path); m != nil {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.streamEvents(w, r, m[1])
+		return
+	}
 	if !basePathRegexp.MatchString(r.URL.Path) {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
@@ -62,14 +99,21 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else {
-		executionID := r.URL.Path[strings.LastIndexByte(r.URL.Path, '/')+1:]
-		if i := strings.LastIndexByte(executionID, ':'); i != -1 {
-			customMethod := executionID[i+1:]
-			executionID = executionID[:i]
+		name := strings.TrimPrefix(r.URL.Path, "/v1/")
+		if i := strings.LastIndexByte(name, ':'); i > strings.LastIndexByte(name, '/') {
+			customMethod := name[i+1:]
+			name = name[:i]
 			switch customMethod {
 			case "cancel":
 				if r.Method == http.MethodPost {
-					h.cancelExecution(w, r, executionID)
+					h.cancelExecution(w, r, name)
+					return
+				}
+				fallthrough
+
+			case "pause", "resume":
+				if r.Method == http.MethodPost {
+					h.pauseExecution(w, r, name, customMethod == "pause")
 					return
 				}
 				fallthrough
@@ -82,14 +126,205 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		switch r.Method {
 		case http.MethodGet:
-			h.getExecution(w, r, executionID)
+			h.getExecution(w, r, name)
+			return
+
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
+		}
+	}
+}
+
+// serveWorkflows serves the admin API of the workflows to deploy them at runtime.
+func (h *httpHandler) serveWorkflows(w http.ResponseWriter, r *http.Request, parent, id string) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			resJSON(w, http.StatusOK, &listWorkflowsResponse{Workflows: h.store.listWorkflows(parent)})
+		case http.MethodPost:
+			h.createWorkflow(w, r, parent)
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
 
+	name := parent + "/workflows/" + id
+	if i := strings.LastIndexByte(name, ':'); i != -1 {
+		customMethod := name[i+1:]
+		name = name[:i]
+		switch {
+		case customMethod == "triggerPubsubExecution" && r.Method == http.MethodPost:
+			h.triggerPubsubExecution(w, r, name)
+		case customMethod == "triggerCloudEvent" && r.Method == http.MethodPost:
+			h.triggerCloudEvent(w, r, name)
 		default:
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		wf, err := h.store.getWorkflow(name)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		resJSON(w, http.StatusOK, wf)
+	case http.MethodPatch:
+		h.patchWorkflow(w, r, name)
+	case http.MethodDelete:
+		if err := h.store.deleteWorkflow(name); err != nil {
+			httpError(w, err)
 			return
 		}
+		resJSON(w, http.StatusOK, h.store.newOperation(name, "delete", nil))
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveOperations serves the operations of the deployments by the admin API to poll them.
+func (h *httpHandler) serveOperations(w http.ResponseWriter, r *http.Request, parent, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if id == "" {
+		resJSON(w, http.StatusOK, &listOperationsResponse{Operations: h.store.listOperations(parent)})
+		return
+	}
+
+	op, err := h.store.getOperation(parent + "/operations/" + id)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	resJSON(w, http.StatusOK, op)
+}
+
+// serveCallbacks serves the callback endpoints of the execution, and lists them.
+func (h *httpHandler) serveCallbacks(w http.ResponseWriter, r *http.Request, parent, id string) {
+	if id != "" {
+		cb, err := h.store.lookupCallback(parent + "/callbacks/" + id)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		cb.ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	callbacks, err := h.store.listCallbacks(parent)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	resJSON(w, http.StatusOK, &listCallbacksResponse{Callbacks: callbacks})
+}
+
+type listCallbacksResponse struct {
+	Callbacks []*callback `json:"callbacks"`
+}
+
+type listOperationsResponse struct {
+	Operations []*operation `json:"operations"`
+}
+
+type listWorkflowsResponse struct {
+	Workflows []*deployedWorkflow `json:"workflows"`
+}
+
+func (h *httpHandler) createWorkflow(w http.ResponseWriter, r *http.Request, parent string) {
+	defer r.Body.Close()
+
+	var wf deployedWorkflow
+	if err := json.NewDecoder(r.Body).Decode(&wf); err != nil {
+		logging.Logf(logging.LevelDebug, "failed to decode request body", "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.store.createWorkflow(parent, r.URL.Query().Get("workflowId"), &wf)
+	if err != nil {
+		httpError(w, err)
+		return
 	}
+	resJSON(w, http.StatusOK, h.store.newOperation(ret.Name, "create", ret))
+}
+
+func (h *httpHandler) patchWorkflow(w http.ResponseWriter, r *http.Request, name string) {
+	defer r.Body.Close()
+
+	var patch deployedWorkflow
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		logging.Logf(logging.LevelDebug, "failed to decode request body", "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var updateMask []string
+	if v := r.URL.Query().Get("updateMask"); v != "" {
+		updateMask = strings.Split(v, ",")
+	}
+	ret, err := h.store.patchWorkflow(name, &patch, updateMask)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	resJSON(w, http.StatusOK, h.store.newOperation(ret.Name, "update", ret))
+}
+
+func (h *httpHandler) triggerPubsubExecution(w http.ResponseWriter, r *http.Request, name string) {
+	defer r.Body.Close()
+
+	var req triggerPubsubExecutionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.Logf(logging.LevelDebug, "failed to decode request body", "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.store.triggerPubsub(name, &req)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	setAccessLogExecution(r, ret.Name)
+	resJSON(w, http.StatusOK, ret)
+}
+
+// triggerCloudEvent is the emulator specific method to deliver the CloudEvent to the workflow like the Eventarc triggers.
+func (h *httpHandler) triggerCloudEvent(w http.ResponseWriter, r *http.Request, name string) {
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.Logf(logging.LevelDebug, "failed to read request body", "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	event, err := parseCloudEvent(r.Header, body)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	ret, err := h.store.triggerCloudEvent(name, event)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	setAccessLogExecution(r, ret.Name)
+	resJSON(w, http.StatusOK, ret)
 }
 
 func (h *httpHandler) createExecution(w http.ResponseWriter, r *http.Request) {
@@ -97,140 +332,143 @@ func (h *httpHandler) createExecution(w http.ResponseWriter, r *http.Request) {
 
 	var ex *execution
 	if err := json.NewDecoder(r.Body).Decode(&ex); err != nil {
-		log.Printf("failed to decode request body: %v", err)
+		logging.Logf(logging.LevelDebug, "failed to decode request body", "error", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 
-	var args any
-	if ex.Argument == "" {
-		ex.Argument = "null"
-	} else {
-		if err := json.NewDecoder(strings.NewReader(ex.Argument)).Decode(&args); err != nil {
-			log.Printf("failed to decode argument JSON: %v", err)
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
-		}
+	parent := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), "/executions")
+	ret, err := h.store.create(parent, ex)
+	if err != nil {
+		httpError(w, err)
+		return
 	}
+	setAccessLogExecution(r, ret.Name)
+	resJSON(w, http.StatusOK, ret)
+}
 
-	// go go
-	id := fmt.Sprintf("00000000-0000-0000-0000-%012x", atomic.AddUint64(&h.idBase, 1))
-	ex.Name = r.URL.Path + "/" + id
-	ex.StartTime = time.Now().UTC()
-	ex.State = "ACTIVE"
-	ex.WorkflowRevisionId = "000001-dummy"
-	ex.CallLogLevel = "LOG_ALL_CALLS"
-	h.executions.Store(id, ex)
-	go h.execute(ex, args)
-	resJSON(w, http.StatusOK, ex)
+type listExecutionsResponse struct {
+	Executions    []*execution `json:"executions"`
+	NextPageToken string       `json:"nextPageToken,omitempty"`
 }
 
-func (h *httpHandler) execute(ex *execution, args any) {
-	ret, err := h.workflowRoot.Load().(workflow.WorkflowRoot).Execute(args)
-	if err == nil {
-		ex.mu.Lock()
-		defer ex.mu.Unlock()
-		ex.EndTime = time.Now().UTC()
-		ex.State = "SUCCEEDED"
-		var s strings.Builder
-		if dumpErr := json.NewEncoder(&s).Encode(ret); dumpErr != nil {
-			log.Printf("failed to encode workflow result: %v", dumpErr)
-			log.Printf("result: %v", ret)
-		} else {
-			ex.Result = strings.TrimSuffix(s.String(), "\n")
+func (h *httpHandler) listExecutions(w http.ResponseWriter, r *http.Request) {
+	pageSize := defaultPageSize
+	if v := r.URL.Query().Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Bad Request: invalid pageSize", http.StatusBadRequest)
+			return
 		}
+		pageSize = normalizePageSize(n)
+	}
+
+	filter, err := parseExecutionFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	order, err := parseExecutionOrder(r.URL.Query().Get("orderBy"))
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	view, err := parseExecutionView(r, "BASIC")
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ex.mu.Lock()
-	defer ex.mu.Unlock()
-	ex.EndTime = time.Now().UTC()
-	ex.State = "FAILED"
-	var exception types.Exception
-	if errors.As(err, &exception) {
-		var s strings.Builder
-		if dumpErr := json.NewEncoder(&s).Encode(exception); dumpErr != nil {
-			log.Printf("failed to encode workflow exception: %v", dumpErr)
-			s.Reset()
-			if dumpErr = json.NewEncoder(&s).Encode(err); dumpErr != nil {
-				log.Printf("failed to encode workflow error: %v", dumpErr)
-				ex.Error = fmt.Sprint(err)
-			} else {
-				ex.Error = strings.TrimSuffix(s.String(), "\n")
-			}
-		} else {
-			ex.Error = strings.TrimSuffix(s.String(), "\n")
-		}
-	} else {
-		log.Printf("failed to execute workflow: %v", err)
-		var s strings.Builder
-		if dumpErr := json.NewEncoder(&s).Encode(err); dumpErr != nil {
-			log.Printf("failed to encode workflow error: %v", dumpErr)
-			ex.Error = fmt.Sprint(err)
-		} else {
-			ex.Error = strings.TrimSuffix(s.String(), "\n")
-		}
+	var res listExecutionsResponse
+	parent := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), "/executions")
+	res.Executions, res.NextPageToken, err = h.store.list(parent, filter, order, view, pageSize, r.URL.Query().Get("pageToken"))
+	if err != nil {
+		httpError(w, err)
+		return
 	}
+	resJSON(w, http.StatusOK, &res)
 }
 
-func (h *httpHandler) listExecutions(w http.ResponseWriter, r *http.Request) {
-	results := []*execution{}
-	h.executions.Range(func(key, value any) bool {
-		results = append(results, value.(*execution))
-		return true
-	})
-	for _, ex := range results {
-		ex.mu.RLock()
-	}
-	defer func() {
-		for _, ex := range results {
-			ex.mu.RUnlock()
-		}
-	}()
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].StartTime.Before(results[j].StartTime)
-	})
+func (h *httpHandler) getExecution(w http.ResponseWriter, r *http.Request, name string) {
+	view, err := parseExecutionView(r, "FULL")
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	resJSON(w, http.StatusOK, map[string][]*execution{"executions": results})
+	ex, err := h.store.get(name, view)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	resJSON(w, http.StatusOK, ex)
 }
 
-func (h *httpHandler) getExecution(w http.ResponseWriter, r *http.Request, id string) {
-	ret, ok := h.executions.Load(id)
-	if !ok {
-		http.Error(w, "Not Found", http.StatusNotFound)
+type listLogsResponse struct {
+	Entries []*defaults.LogEntry `json:"entries"`
+}
+
+// listLogs is the emulator specific method to get the logs captured from the execution.
+func (h *httpHandler) listLogs(w http.ResponseWriter, r *http.Request, name string) {
+	ex, err := h.store.get(name, "FULL")
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	execution := ret.(*execution)
 
-	execution.mu.RLock()
-	defer execution.mu.RUnlock()
-	resJSON(w, http.StatusOK, execution)
+	res := listLogsResponse{Entries: ex.LogEntries}
+	if res.Entries == nil {
+		res.Entries = []*defaults.LogEntry{}
+	}
+	resJSON(w, http.StatusOK, &res)
 }
 
-func (h *httpHandler) cancelExecution(w http.ResponseWriter, r *http.Request, id string) {
-	http.Error(w, "Not Implemented", http.StatusNotImplemented) // patches welcome
+// pauseExecution is the emulator specific method to pause the execution at the next step boundary, or resume it.
+func (h *httpHandler) pauseExecution(w http.ResponseWriter, r *http.Request, name string, pause bool) {
+	pauseOrResume := h.store.resume
+	if pause {
+		pauseOrResume = h.store.pause
+	}
+
+	ex, err := pauseOrResume(name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	resJSON(w, http.StatusOK, ex)
 }
 
-func NewHTTPHandler(loader func() (workflow.WorkflowRoot, error)) (http.Handler, error) {
-	root, err := loader()
+func (h *httpHandler) cancelExecution(w http.ResponseWriter, r *http.Request, name string) {
+	ex, err := h.store.cancelExecution(r.Context(), name)
 	if err != nil {
-		return nil, err
+		httpError(w, err)
+		return
 	}
+	resJSON(w, http.StatusOK, ex)
+}
 
-	h := &httpHandler{}
-	h.workflowRoot.Store(root)
-	go func() {
-		t := time.NewTicker(5 * time.Second)
-		for range t.C {
-			root, err := loader()
-			if err != nil {
-				log.Printf("failed to reload workflow: %v", err)
-				continue
-			}
-			h.workflowRoot.Store(root)
-		}
-	}()
-	return h, nil
+// NewHTTPHandler returns the handler of the REST API of the executions in the store.
+func NewHTTPHandler(store *ExecutionStore) http.Handler {
+	return withAccessLog(&httpHandler{store: store})
+}
+
+// httpError responds the error returned by the store with the status code of its kind.
+func httpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidArgument):
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errNotFound):
+		http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, errAlreadyExists):
+		http.Error(w, "Conflict: "+err.Error(), http.StatusConflict)
+	case errors.Is(err, errPrecondition):
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errUnavailable):
+		http.Error(w, "Service Unavailable: "+err.Error(), http.StatusServiceUnavailable)
+	default:
+		logging.Logf(logging.LevelError, "failed to handle request", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
 }
 
 func resJSON(w http.ResponseWriter, status int, v any) error {
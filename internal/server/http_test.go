@@ -0,0 +1,417 @@
+package server_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+const testWorkflowsPath = "/v1/projects/my-project/locations/us-central1/workflows"
+
+// newTestServer serves the REST API of the workflows of the sources by the workflow IDs.
+func newTestServer(t *testing.T, sources map[string]string, opts ...workflow.ExecuteOption) (*server.ExecutionStore, *httptest.Server) {
+	t.Helper()
+
+	loaded := make(map[string]*server.LoadedWorkflow, len(sources))
+	for id, source := range sources {
+		root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded[id] = &server.LoadedWorkflow{Root: root, Source: []byte(source)}
+	}
+
+	store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+		return loaded, nil
+	}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.NewHTTPHandler(store))
+	t.Cleanup(ts.Close)
+	return store, ts
+}
+
+// doJSON requests the JSON body, and decodes the JSON response into the result if it's not nil.
+func doJSON(t *testing.T, method, url string, body any, result any) int {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != nil && res.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(b, result); err != nil {
+			t.Fatalf("failed to decode %s: %v", b, err)
+		}
+	}
+	return res.StatusCode
+}
+
+// waitExecution polls the execution until it's finished.
+func waitExecution(t *testing.T, url string) map[string]any {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var ex map[string]any
+		if status := doJSON(t, http.MethodGet, url, nil, &ex); status != http.StatusOK {
+			t.Fatalf("unexpected status: %d", status)
+		}
+		if ex["state"] != "ACTIVE" {
+			return ex
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("execution is not finished: %v", ex)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelExecution(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{
+		"sleep": `
+main:
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 60
+`,
+		"done": `
+main:
+  steps:
+    - done:
+        return: ok
+`,
+	})
+
+	var ex map[string]any
+	if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/sleep/executions", map[string]any{}, &ex); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if ex["state"] != "ACTIVE" {
+		t.Fatalf("unexpected state: %v", ex["state"])
+	}
+	if _, ok := ex["endTime"]; ok {
+		t.Errorf("endTime of the active execution should be omitted: %v", ex["endTime"])
+	}
+
+	name := ex["name"].(string)
+	var cancelled map[string]any
+	if status := doJSON(t, http.MethodPost, ts.URL+"/v1/"+name+":cancel", nil, &cancelled); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if cancelled["state"] != "CANCELLED" {
+		t.Errorf("unexpected state: %v", cancelled["state"])
+	}
+	if _, ok := cancelled["endTime"]; !ok {
+		t.Error("endTime of the cancelled execution should be set")
+	}
+	if _, ok := cancelled["stateError"]; !ok {
+		t.Error("stateError of the cancelled execution should be set")
+	}
+
+	// the finished execution is not cancellable
+	if status := doJSON(t, http.MethodPost, ts.URL+"/v1/"+name+":cancel", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("unexpected status: %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/sleep/executions/unknown:cancel", nil, nil); status != http.StatusNotFound {
+		t.Errorf("unexpected status: %d", status)
+	}
+
+	if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/done/executions", map[string]any{}, &ex); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	succeeded := waitExecution(t, ts.URL+"/v1/"+ex["name"].(string))
+	if succeeded["state"] != "SUCCEEDED" {
+		t.Errorf("unexpected state: %v", succeeded["state"])
+	}
+	if _, ok := succeeded["endTime"]; !ok {
+		t.Error("endTime of the succeeded execution should be set")
+	}
+	if _, ok := succeeded["stateError"]; ok {
+		t.Errorf("stateError of the succeeded execution should be omitted: %v", succeeded["stateError"])
+	}
+}
+
+func TestExecutionPathSegments(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - done:
+        return:
+          project_id: ${sys.get_env("GOOGLE_CLOUD_PROJECT_ID", "")}
+          project_number: ${sys.get_env("GOOGLE_CLOUD_PROJECT_NUMBER", "")}
+          location: ${sys.get_env("GOOGLE_CLOUD_LOCATION", "")}
+          workflow_id: ${sys.get_env("GOOGLE_CLOUD_WORKFLOW_ID", "")}
+`
+	_, unrestricted := newTestServer(t, map[string]string{"wf": source})
+
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+		return map[string]*server.LoadedWorkflow{"wf": {Root: root, Source: []byte(source)}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.RestrictLocation("my-project", "123456", "us-central1")
+	restricted := httptest.NewServer(server.NewHTTPHandler(store))
+	t.Cleanup(restricted.Close)
+
+	for _, tt := range []struct {
+		name           string
+		baseURL        string
+		path           string
+		expectedStatus int
+		expected       map[string]any
+	}{
+		{
+			name:           "any project and location",
+			baseURL:        unrestricted.URL,
+			path:           "/v1/projects/other-project/locations/asia-northeast1/workflows/wf/executions",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]any{"project_id": "other-project", "project_number": "", "location": "asia-northeast1", "workflow_id": "wf"},
+		},
+		{
+			name:           "project number",
+			baseURL:        unrestricted.URL,
+			path:           "/v1/projects/123456/locations/us-central1/workflows/wf/executions",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]any{"project_id": "", "project_number": "123456", "location": "us-central1", "workflow_id": "wf"},
+		},
+		{
+			name:           "configured project",
+			baseURL:        restricted.URL,
+			path:           "/v1/projects/my-project/locations/us-central1/workflows/wf/executions",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]any{"project_id": "my-project", "project_number": "", "location": "us-central1", "workflow_id": "wf"},
+		},
+		{
+			name:           "configured project number",
+			baseURL:        restricted.URL,
+			path:           "/v1/projects/123456/locations/us-central1/workflows/wf/executions",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]any{"project_id": "", "project_number": "123456", "location": "us-central1", "workflow_id": "wf"},
+		},
+		{
+			name:           "other project",
+			baseURL:        restricted.URL,
+			path:           "/v1/projects/other-project/locations/us-central1/workflows/wf/executions",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "other location",
+			baseURL:        restricted.URL,
+			path:           "/v1/projects/my-project/locations/asia-northeast1/workflows/wf/executions",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "missing workflow segment",
+			baseURL:        unrestricted.URL,
+			path:           "/v1/projects/my-project/locations/us-central1/executions",
+			expectedStatus: http.StatusNotFound,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			status := doJSON(t, http.MethodPost, tt.baseURL+tt.path, map[string]any{}, &ex)
+			if status != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if name := ex["name"].(string); !strings.HasPrefix("/v1/"+name, tt.path+"/") {
+				t.Errorf("unexpected name: %s", name)
+			}
+
+			ex = waitExecution(t, tt.baseURL+"/v1/"+ex["name"].(string))
+			var actual map[string]any
+			if err := json.Unmarshal([]byte(ex["result"].(string)), &actual); err != nil {
+				t.Fatalf("failed to decode result %v: %v", ex["result"], err)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected environment (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExecutionLogs(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{
+		"text": `
+main:
+  steps:
+    - log:
+        call: sys.log
+        args:
+          text: hello
+          severity: INFO
+`,
+		"json": `
+main:
+  steps:
+    - log:
+        call: sys.log
+        args:
+          json:
+            message: hello
+            n: 1
+          severity: WARNING
+`,
+		"data": `
+main:
+  steps:
+    - first:
+        call: sys.log
+        args:
+          data: 1
+    - second:
+        call: sys.log
+        args:
+          data:
+            message: second
+`,
+		"none": `
+main:
+  steps:
+    - done:
+        return: ok
+`,
+		"failed": `
+main:
+  steps:
+    - log:
+        call: sys.log
+        args:
+          text: before failure
+          severity: ERROR
+    - fail:
+        raise: failed
+`,
+	})
+
+	for _, tt := range []struct {
+		workflowID string
+		expected   []map[string]any
+	}{
+		{
+			workflowID: "text",
+			expected:   []map[string]any{{"severity": "INFO", "textPayload": "hello"}},
+		},
+		{
+			workflowID: "json",
+			expected:   []map[string]any{{"severity": "WARNING", "jsonPayload": map[string]any{"message": "hello", "n": float64(1)}}},
+		},
+		{
+			workflowID: "data",
+			expected: []map[string]any{
+				{"severity": "DEFAULT", "textPayload": "1"},
+				{"severity": "DEFAULT", "jsonPayload": map[string]any{"message": "second"}},
+			},
+		},
+		{
+			workflowID: "none",
+			expected:   []map[string]any{},
+		},
+		{
+			workflowID: "failed",
+			expected:   []map[string]any{{"severity": "ERROR", "textPayload": "before failure"}},
+		},
+	} {
+		tt := tt
+		t.Run(tt.workflowID, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/"+tt.workflowID+"/executions", map[string]any{}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			name := ex["name"].(string)
+			ex = waitExecution(t, ts.URL+"/v1/"+name)
+
+			var res struct {
+				Entries []map[string]any `json:"entries"`
+			}
+			if status := doJSON(t, http.MethodGet, ts.URL+"/v1/"+name+"/logs", nil, &res); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			actual := make([]map[string]any, len(res.Entries))
+			for i, entry := range res.Entries {
+				if _, ok := entry["timestamp"]; !ok {
+					t.Errorf("timestamp should be set: %v", entry)
+				}
+				actual[i] = lo.OmitByKeys(entry, []string{"timestamp"})
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected entries (-want +got):\n%s", diff)
+			}
+
+			// the entries are also in the execution as the emulator extension
+			entries, _ := ex["logEntries"].([]any)
+			if len(entries) != len(tt.expected) {
+				t.Errorf("unexpected logEntries of the execution: %v", ex["logEntries"])
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "unknown execution", method: http.MethodGet, path: testWorkflowsPath + "/none/executions/unknown/logs", expectedStatus: http.StatusNotFound},
+		{name: "unsupported method", method: http.MethodPost, path: testWorkflowsPath + "/none/executions/unknown/logs", expectedStatus: http.StatusMethodNotAllowed},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if status := doJSON(t, tt.method, ts.URL+tt.path, nil, nil); status != tt.expectedStatus {
+				t.Errorf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var executionIDRegexp = regexp.MustCompile(`/executions/([^/:]+)`)
+
+type accessLogKey struct{}
+
+// accessLogWriter records the status code of the response. It's also a http.Flusher to stream the events.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status    int
+	execution string // the ID of the execution of the request or the response
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// withAccessLog logs the requests to the handler in the level by the status code: ERROR for 5xx, WARN for 4xx, and INFO for the others.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lw := &accessLogWriter{ResponseWriter: w}
+		if m := executionIDRegexp.FindStringSubmatch(r.URL.Path); m != nil {
+			lw.execution = m[1]
+		}
+		next.ServeHTTP(lw, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, lw)))
+
+		level := logging.LevelInfo
+		if lw.status >= 500 {
+			level = logging.LevelError
+		} else if lw.status >= 400 {
+			level = logging.LevelWarn
+		}
+		keyValues := []any{"method", r.Method, "path", r.URL.Path, "status", lw.status, "latency", time.Since(start)}
+		if lw.execution != "" {
+			keyValues = append(keyValues, "execution", lw.execution)
+		}
+		logging.Logf(level, "access", keyValues...)
+	})
+}
+
+// setAccessLogExecution sets the execution created by the request to the access log.
+func setAccessLogExecution(r *http.Request, name string) {
+	if lw, ok := r.Context().Value(accessLogKey{}).(*accessLogWriter); ok {
+		lw.execution = executionID(name)
+	}
+}
+
+func executionID(name string) string {
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
+
+// grpcAccessLog logs the unary calls in the level by the status code: ERROR for the server errors, WARN for the client errors, and INFO for OK.
+func grpcAccessLog(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := logging.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		level = logging.LevelError
+	default:
+		level = logging.LevelWarn
+	}
+	keyValues := []any{"method", info.FullMethod, "code", code, "latency", time.Since(start)}
+	if named, ok := res.(interface{ GetName() string }); ok && err == nil {
+		keyValues = append(keyValues, "execution", executionID(named.GetName()))
+	} else if named, ok := req.(interface{ GetName() string }); ok {
+		keyValues = append(keyValues, "execution", executionID(named.GetName()))
+	}
+	logging.Logf(level, "access", keyValues...)
+	return res, err
+}
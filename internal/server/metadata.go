@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+)
+
+// MetadataConfig is the project and the service account served by the metadata server.
+type MetadataConfig struct {
+	ProjectID      string
+	ProjectNumber  string
+	Zone           string // e.g. us-central1-a
+	ServiceAccount string // e.g. emulator@emulator-project.iam.gserviceaccount.com
+	Scopes         []string
+}
+
+// metadataHandler emulates the GCE metadata server to make google.FindDefaultCredentials work without any credentials.
+// The tokens are issued by defaults.NewFakeToken.
+// refs. https://cloud.google.com/compute/docs/metadata/predefined-metadata-keys
+type metadataHandler struct {
+	config MetadataConfig
+}
+
+// NewMetadataHandler returns the handler of the metadata server.
+// Point GCE_METADATA_HOST at it to make the Google Cloud client libraries use it.
+func NewMetadataHandler(config MetadataConfig) http.Handler {
+	return &metadataHandler{config: config}
+}
+
+func (h *metadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Metadata-Flavor") != "Google" {
+		http.Error(w, "Missing Metadata-Flavor: Google header", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Metadata-Flavor", "Google")
+	path := strings.TrimPrefix(r.URL.Path, "/computeMetadata/v1/")
+	switch path {
+	case "project/project-id":
+		h.writeText(w, h.config.ProjectID)
+	case "project/numeric-project-id":
+		h.writeText(w, h.config.ProjectNumber)
+	case "instance/zone":
+		h.writeText(w, fmt.Sprintf("projects/%s/zones/%s", h.config.ProjectNumber, h.config.Zone))
+	case "instance/service-accounts/":
+		h.writeText(w, "default/\n"+h.config.ServiceAccount+"/\n")
+	default:
+		account, key, ok := strings.Cut(strings.TrimPrefix(path, "instance/service-accounts/"), "/")
+		if !ok || !strings.HasPrefix(path, "instance/service-accounts/") || (account != "default" && account != h.config.ServiceAccount) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.serveServiceAccount(w, r, key)
+	}
+}
+
+func (h *metadataHandler) serveServiceAccount(w http.ResponseWriter, r *http.Request, key string) {
+	switch key {
+	case "email":
+		h.writeText(w, h.config.ServiceAccount)
+	case "aliases":
+		h.writeText(w, "default")
+	case "scopes":
+		h.writeText(w, strings.Join(h.config.Scopes, "\n")+"\n")
+	case "token":
+		token, err := defaults.NewFakeToken(map[string]any{
+			"email": h.config.ServiceAccount,
+			"scope": strings.Join(h.config.Scopes, " "),
+		})
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to issue token", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": token.AccessToken,
+			"expires_in":   int64(time.Until(token.Expiry).Seconds()),
+			"token_type":   token.TokenType,
+		})
+	case "identity":
+		audience := r.URL.Query().Get("audience")
+		if audience == "" {
+			http.Error(w, "audience is required", http.StatusBadRequest)
+			return
+		}
+
+		token, err := defaults.NewFakeToken(map[string]any{
+			"aud":   audience,
+			"azp":   h.config.ServiceAccount,
+			"email": h.config.ServiceAccount,
+		})
+		if err != nil {
+			logging.Logf(logging.LevelError, "failed to issue token", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		h.writeText(w, token.AccessToken)
+	default:
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}
+}
+
+func (h *metadataHandler) writeText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "application/text")
+	_, _ = w.Write([]byte(s))
+}
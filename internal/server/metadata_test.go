@@ -0,0 +1,193 @@
+package server_test
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+)
+
+func TestMetadataHandler(t *testing.T) {
+	t.Parallel()
+
+	const account = "sa@my-project.iam.gserviceaccount.com"
+	ts := httptest.NewServer(server.NewMetadataHandler(server.MetadataConfig{
+		ProjectID:      "my-project",
+		ProjectNumber:  "123456",
+		Zone:           "us-central1-a",
+		ServiceAccount: account,
+		Scopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+	}))
+	t.Cleanup(ts.Close)
+
+	// claims decodes the claims of the fake token
+	claims := func(t *testing.T, token string) map[string]any {
+		t.Helper()
+
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			t.Fatalf("invalid token: %s", token)
+		}
+		b, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var claims map[string]any
+		if err := json.Unmarshal(b, &claims); err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+
+	for _, tt := range []struct {
+		name         string
+		method       string
+		path         string
+		noFlavor     bool
+		expectedCode int
+		expectedBody string
+		verify       func(t *testing.T, body string)
+	}{
+		{
+			name:         "project ID",
+			path:         "/computeMetadata/v1/project/project-id",
+			expectedCode: http.StatusOK,
+			expectedBody: "my-project",
+		},
+		{
+			name:         "numeric project ID",
+			path:         "/computeMetadata/v1/project/numeric-project-id",
+			expectedCode: http.StatusOK,
+			expectedBody: "123456",
+		},
+		{
+			name:         "zone",
+			path:         "/computeMetadata/v1/instance/zone",
+			expectedCode: http.StatusOK,
+			expectedBody: "projects/123456/zones/us-central1-a",
+		},
+		{
+			name:         "service accounts",
+			path:         "/computeMetadata/v1/instance/service-accounts/",
+			expectedCode: http.StatusOK,
+			expectedBody: "default/\n" + account + "/\n",
+		},
+		{
+			name:         "email of the default service account",
+			path:         "/computeMetadata/v1/instance/service-accounts/default/email",
+			expectedCode: http.StatusOK,
+			expectedBody: account,
+		},
+		{
+			name:         "scopes of the service account",
+			path:         "/computeMetadata/v1/instance/service-accounts/" + account + "/scopes",
+			expectedCode: http.StatusOK,
+			expectedBody: "https://www.googleapis.com/auth/cloud-platform\n",
+		},
+		{
+			name:         "access token",
+			path:         "/computeMetadata/v1/instance/service-accounts/default/token",
+			expectedCode: http.StatusOK,
+			verify: func(t *testing.T, body string) {
+				var token struct {
+					AccessToken string `json:"access_token"`
+					ExpiresIn   int64  `json:"expires_in"`
+					TokenType   string `json:"token_type"`
+				}
+				if err := json.Unmarshal([]byte(body), &token); err != nil {
+					t.Fatal(err)
+				}
+				if token.TokenType != "Bearer" || token.ExpiresIn <= 0 {
+					t.Errorf("unexpected token: %+v", token)
+				}
+				if c := claims(t, token.AccessToken); c["email"] != account || c["scope"] != "https://www.googleapis.com/auth/cloud-platform" {
+					t.Errorf("unexpected claims: %v", c)
+				}
+			},
+		},
+		{
+			name:         "identity token",
+			path:         "/computeMetadata/v1/instance/service-accounts/default/identity?audience=https://example.com",
+			expectedCode: http.StatusOK,
+			verify: func(t *testing.T, body string) {
+				if c := claims(t, body); c["aud"] != "https://example.com" || c["email"] != account {
+					t.Errorf("unexpected claims: %v", c)
+				}
+			},
+		},
+		{
+			name:         "identity token without the audience",
+			path:         "/computeMetadata/v1/instance/service-accounts/default/identity",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown service account",
+			path:         "/computeMetadata/v1/instance/service-accounts/other@example.com/email",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "unknown key",
+			path:         "/computeMetadata/v1/instance/hostname",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "missing Metadata-Flavor header",
+			path:         "/computeMetadata/v1/project/project-id",
+			noFlavor:     true,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "non-GET method",
+			method:       http.MethodPost,
+			path:         "/computeMetadata/v1/project/project-id",
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.noFlavor {
+				req.Header.Set("Metadata-Flavor", "Google")
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tt.expectedCode {
+				t.Fatalf("unexpected status code: %d, want %d: %s", res.StatusCode, tt.expectedCode, b)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			if flavor := res.Header.Get("Metadata-Flavor"); flavor != "Google" {
+				t.Errorf("unexpected Metadata-Flavor: %s", flavor)
+			}
+			if tt.expectedBody != "" && string(b) != tt.expectedBody {
+				t.Errorf("unexpected body: %q, want %q", b, tt.expectedBody)
+			}
+			if tt.verify != nil {
+				tt.verify(t, string(b))
+			}
+		})
+	}
+}
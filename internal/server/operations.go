@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var operationNameRegexp = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/operations/[^/]+$`)
+
+// operation is the long-running operation of a deployment by the admin API.
+// The deployments are synchronous, so the operations are always done when they are returned.
+// refs. https://cloud.google.com/workflows/docs/reference/rest/v1/projects.locations.operations
+type operation struct {
+	Name     string             `json:"name"`
+	Metadata *operationMetadata `json:"metadata"`
+	Done     bool               `json:"done"`
+	Response any                `json:"response"`
+}
+
+type operationMetadata struct {
+	Type       string    `json:"@type"`
+	CreateTime time.Time `json:"createTime"`
+	EndTime    time.Time `json:"endTime"`
+	Target     string    `json:"target"`
+	Verb       string    `json:"verb"`
+	APIVersion string    `json:"apiVersion"`
+}
+
+// workflowResponse is the workflow as the response of the operation, which is typed as google.protobuf.Any.
+type workflowResponse struct {
+	Type string `json:"@type"`
+	*deployedWorkflow
+}
+
+type emptyResponse struct {
+	Type string `json:"@type"`
+}
+
+// newOperation records the done operation of the verb (create, update or delete) to the workflow.
+// The response is the snapshot of the workflow, or the empty message if it's nil.
+func (s *ExecutionStore) newOperation(target, verb string, wf *deployedWorkflow) *operation {
+	now := time.Now().UTC()
+	parent := target[:strings.Index(target, "/workflows/")]
+	op := &operation{
+		Name: fmt.Sprintf("%s/operations/operation-%013d-%012x", parent, now.UnixNano()/int64(time.Millisecond), atomic.AddUint64(&s.idBase, 1)),
+		Metadata: &operationMetadata{
+			Type:       "type.googleapis.com/google.cloud.workflows.v1.OperationMetadata",
+			CreateTime: now,
+			EndTime:    now,
+			Target:     target,
+			Verb:       verb,
+			APIVersion: "v1",
+		},
+		Done:     true,
+		Response: &emptyResponse{Type: "type.googleapis.com/google.protobuf.Empty"},
+	}
+	if wf != nil {
+		op.Response = &workflowResponse{Type: "type.googleapis.com/google.cloud.workflows.v1.Workflow", deployedWorkflow: wf}
+	}
+	s.operations.Store(op.Name, op)
+	return op
+}
+
+// getOperation returns the operation specified by the name like projects/*/locations/*/operations/*.
+func (s *ExecutionStore) getOperation(name string) (*operation, error) {
+	if !operationNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("%w: invalid name: %q", errInvalidArgument, name)
+	}
+
+	v, ok := s.operations.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: operation %s", errNotFound, name)
+	}
+	return v.(*operation), nil // immutable
+}
+
+// listOperations returns the operations in the parent like projects/*/locations/* in the name order.
+func (s *ExecutionStore) listOperations(parent string) []*operation {
+	results := []*operation{}
+	s.operations.Range(func(key, value any) bool {
+		if strings.HasPrefix(key.(string), parent+"/operations/") {
+			results = append(results, value.(*operation))
+		}
+		return true
+	})
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+)
+
+func TestOperations(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - done:
+        return: ok
+`
+	_, ts := newTestServer(t, map[string]string{})
+	const operationsPath = "/v1/projects/my-project/locations/us-central1/operations"
+
+	for i, tt := range []struct {
+		name             string
+		method           string
+		path             string // relative to the workflow, and {id} is replaced by the workflow ID
+		body             any
+		expectedVerb     string
+		expectedResponse map[string]any
+	}{
+		{
+			name:             "create",
+			method:           http.MethodPost,
+			path:             "?workflowId={id}",
+			body:             map[string]any{"sourceContents": source},
+			expectedVerb:     "create",
+			expectedResponse: map[string]any{"@type": "type.googleapis.com/google.cloud.workflows.v1.Workflow", "state": "ACTIVE", "sourceContents": source},
+		},
+		{
+			name:             "update",
+			method:           http.MethodPatch,
+			path:             "/{id}",
+			body:             map[string]any{"description": "desc"},
+			expectedVerb:     "update",
+			expectedResponse: map[string]any{"@type": "type.googleapis.com/google.cloud.workflows.v1.Workflow", "state": "ACTIVE", "sourceContents": source, "description": "desc"},
+		},
+		{
+			name:             "delete",
+			method:           http.MethodDelete,
+			path:             "/{id}",
+			expectedVerb:     "delete",
+			expectedResponse: map[string]any{"@type": "type.googleapis.com/google.protobuf.Empty"},
+		},
+	} {
+		i, tt := i, tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			id := "op" + strconv.Itoa(i)
+			if tt.method != http.MethodPost {
+				if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"?workflowId="+id, map[string]any{"sourceContents": source}, nil); status != http.StatusOK {
+					t.Fatalf("unexpected status: %d", status)
+				}
+			}
+
+			var op map[string]any
+			if status := doJSON(t, tt.method, ts.URL+testWorkflowsPath+strings.ReplaceAll(tt.path, "{id}", id), tt.body, &op); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			name, _ := op["name"].(string)
+			if !strings.HasPrefix("/v1/"+name, operationsPath+"/operation-") {
+				t.Errorf("unexpected name: %v", op["name"])
+			}
+			if op["done"] != true {
+				t.Errorf("operation should be done: %v", op)
+			}
+			metadata, _ := op["metadata"].(map[string]any)
+			if diff := cmp.Diff(map[string]any{
+				"@type":      "type.googleapis.com/google.cloud.workflows.v1.OperationMetadata",
+				"target":     testWorkflowsPath[len("/v1/"):] + "/" + id,
+				"verb":       tt.expectedVerb,
+				"apiVersion": "v1",
+			}, lo.OmitByKeys(metadata, []string{"createTime", "endTime"})); diff != "" {
+				t.Errorf("unexpected metadata (-want +got):\n%s", diff)
+			}
+			response, _ := op["response"].(map[string]any)
+			if diff := cmp.Diff(tt.expectedResponse, lo.PickByKeys(response, []string{"@type", "state", "sourceContents", "description"})); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+
+			var polled map[string]any
+			if status := doJSON(t, http.MethodGet, ts.URL+"/v1/"+name, nil, &polled); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if diff := cmp.Diff(op, polled); diff != "" {
+				t.Errorf("unexpected polled operation (-want +got):\n%s", diff)
+			}
+
+			var list struct {
+				Operations []map[string]any `json:"operations"`
+			}
+			if status := doJSON(t, http.MethodGet, ts.URL+operationsPath, nil, &list); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if !lo.ContainsBy(list.Operations, func(o map[string]any) bool { return o["name"] == name }) {
+				t.Errorf("operation %s is not listed", name)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "unknown operation", method: http.MethodGet, path: operationsPath + "/unknown", expectedStatus: http.StatusNotFound},
+		{name: "cancel", method: http.MethodDelete, path: operationsPath + "/unknown", expectedStatus: http.StatusMethodNotAllowed},
+		{name: "invalid parent", method: http.MethodGet, path: "/v1/projects/my-project/operations/unknown", expectedStatus: http.StatusNotFound},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if status := doJSON(t, tt.method, ts.URL+tt.path, nil, nil); status != tt.expectedStatus {
+				t.Errorf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+		})
+	}
+}
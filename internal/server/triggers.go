@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// triggerPubsubExecutionRequest is the request of the triggerPubsubExecution method of the workflows.
+// refs. https://cloud.google.com/workflows/docs/reference/rest/v1/projects.locations.workflows/triggerPubsubExecution
+type triggerPubsubExecutionRequest struct {
+	GCPCloudEventsMode string         `json:"GCPCloudEventsMode"`
+	Subscription       string         `json:"subscription"`
+	Message            *pubsubMessage `json:"message"`
+	DeliveryAttempt    int            `json:"deliveryAttempt,omitempty"`
+}
+
+type pubsubMessage struct {
+	Data        string            `json:"data,omitempty"` // base64 encoded
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId"`
+	PublishTime time.Time         `json:"publishTime"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+// pubsubEvent is the CloudEvent of the message published to the topic, which is the argument of the executions
+// triggered by Pub/Sub in the same shape as Eventarc delivers.
+type pubsubEvent struct {
+	Data            *pubsubEventData `json:"data"`
+	DataContentType string           `json:"datacontenttype"`
+	ID              string           `json:"id"`
+	Source          string           `json:"source"`
+	SpecVersion     string           `json:"specversion"`
+	Time            time.Time        `json:"time"`
+	Type            string           `json:"type"`
+}
+
+type pubsubEventData struct {
+	Message         *pubsubMessage `json:"message"`
+	Subscription    string         `json:"subscription"`
+	DeliveryAttempt int            `json:"deliveryAttempt,omitempty"`
+}
+
+// triggerPubsub starts the execution of the workflow specified by the name with the event of the Pub/Sub message.
+// The message ID and the publish time are filled if they are omitted.
+func (s *ExecutionStore) triggerPubsub(name string, req *triggerPubsubExecutionRequest) (*execution, error) {
+	if req.Subscription == "" {
+		return nil, fmt.Errorf("%w: subscription is required", errInvalidArgument)
+	}
+	if req.Message == nil {
+		return nil, fmt.Errorf("%w: message is required", errInvalidArgument)
+	}
+
+	msg := *req.Message
+	if msg.MessageID == "" {
+		msg.MessageID = strconv.FormatUint(atomic.AddUint64(&s.idBase, 1), 10)
+	}
+	if msg.PublishTime.IsZero() {
+		msg.PublishTime = time.Now().UTC()
+	}
+
+	b, err := json.Marshal(&pubsubEvent{
+		Data: &pubsubEventData{
+			Message:         &msg,
+			Subscription:    req.Subscription,
+			DeliveryAttempt: req.DeliveryAttempt,
+		},
+		DataContentType: "application/json",
+		ID:              msg.MessageID,
+		Source:          "//pubsub.googleapis.com/" + req.Subscription,
+		SpecVersion:     "1.0",
+		Time:            msg.PublishTime,
+		Type:            "google.cloud.pubsub.topic.v1.messagePublished",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	return s.create(name, &execution{Argument: string(b)})
+}
+
+// parseCloudEvent parses the CloudEvent of the HTTP request in the binary or the structured content mode
+// to the argument of the execution in the same shape as Eventarc delivers to the workflows.
+// refs. https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/bindings/http-protocol-binding.md
+func parseCloudEvent(header http.Header, body []byte) (map[string]any, error) {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+
+	event := map[string]any{}
+	if mediaType == "application/cloudevents+json" {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&event); err != nil {
+			return nil, fmt.Errorf("%w: failed to decode the structured CloudEvent: %v", errInvalidArgument, err)
+		}
+	} else {
+		for key, values := range header {
+			if name := strings.ToLower(key); strings.HasPrefix(name, "ce-") && len(values) != 0 {
+				event[strings.TrimPrefix(name, "ce-")] = values[0]
+			}
+		}
+		if mediaType != "" {
+			event["datacontenttype"] = header.Get("Content-Type")
+		}
+
+		switch {
+		case len(body) == 0:
+			// no data
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			var data any
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			if err := decoder.Decode(&data); err != nil {
+				return nil, fmt.Errorf("%w: failed to decode the data of the CloudEvent: %v", errInvalidArgument, err)
+			}
+			event["data"] = data
+		case strings.HasPrefix(mediaType, "text/"):
+			event["data"] = string(body)
+		default:
+			event["data_base64"] = base64.StdEncoding.EncodeToString(body)
+		}
+	}
+
+	for _, name := range []string{"id", "source", "specversion", "type"} {
+		if v, ok := event[name].(string); !ok || v == "" {
+			return nil, fmt.Errorf("%w: CloudEvent attribute %s is required", errInvalidArgument, name)
+		}
+	}
+	return event, nil
+}
+
+// triggerCloudEvent starts the execution of the workflow specified by the name with the CloudEvent as the argument.
+func (s *ExecutionStore) triggerCloudEvent(name string, event map[string]any) (*execution, error) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	return s.create(name, &execution{Argument: string(b)})
+}
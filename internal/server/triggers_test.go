@@ -0,0 +1,291 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+)
+
+// decodeArgument waits the execution, and decodes the argument of it.
+func decodeArgument(t *testing.T, baseURL string, ex map[string]any) map[string]any {
+	t.Helper()
+
+	ex = waitExecution(t, baseURL+"/v1/"+ex["name"].(string))
+	if ex["state"] != "SUCCEEDED" {
+		t.Fatalf("unexpected state: %v", ex)
+	}
+	var arg map[string]any
+	if err := json.Unmarshal([]byte(ex["argument"].(string)), &arg); err != nil {
+		t.Fatalf("failed to decode argument %v: %v", ex["argument"], err)
+	}
+	return arg
+}
+
+func TestTriggerPubsubExecution(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{"wf": testExecutionsWorkflow, "other": testExecutionsWorkflow})
+	const subscription = "projects/my-project/subscriptions/my-sub"
+
+	for _, tt := range []struct {
+		name           string
+		body           any
+		expectedStatus int
+		expected       map[string]any
+	}{
+		{
+			name: "message",
+			body: map[string]any{
+				"subscription":    subscription,
+				"deliveryAttempt": 2,
+				"message": map[string]any{
+					"data":        "aGVsbG8=",
+					"attributes":  map[string]string{"key": "value"},
+					"messageId":   "123",
+					"publishTime": "2024-01-02T03:04:05Z",
+					"orderingKey": "order",
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expected: map[string]any{
+				"data": map[string]any{
+					"message": map[string]any{
+						"data":        "aGVsbG8=",
+						"attributes":  map[string]any{"key": "value"},
+						"messageId":   "123",
+						"publishTime": "2024-01-02T03:04:05Z",
+						"orderingKey": "order",
+					},
+					"subscription":    subscription,
+					"deliveryAttempt": float64(2),
+				},
+				"datacontenttype": "application/json",
+				"id":              "123",
+				"source":          "//pubsub.googleapis.com/" + subscription,
+				"specversion":     "1.0",
+				"time":            "2024-01-02T03:04:05Z",
+				"type":            "google.cloud.pubsub.topic.v1.messagePublished",
+			},
+		},
+		{
+			name: "minimal message",
+			body: map[string]any{
+				"subscription": subscription,
+				"message":      map[string]any{"publishTime": "2024-01-02T03:04:05Z"},
+			},
+			expectedStatus: http.StatusOK,
+			expected: map[string]any{
+				"data": map[string]any{
+					"message": map[string]any{
+						"messageId":   "<generated>",
+						"publishTime": "2024-01-02T03:04:05Z",
+					},
+					"subscription": subscription,
+				},
+				"datacontenttype": "application/json",
+				"id":              "<generated>",
+				"source":          "//pubsub.googleapis.com/" + subscription,
+				"specversion":     "1.0",
+				"time":            "2024-01-02T03:04:05Z",
+				"type":            "google.cloud.pubsub.topic.v1.messagePublished",
+			},
+		},
+		{
+			name:           "missing subscription",
+			body:           map[string]any{"message": map[string]any{"data": "aGVsbG8="}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing message",
+			body:           map[string]any{"subscription": subscription},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			body:           "message",
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/wf:triggerPubsubExecution", tt.body, &ex)
+			if status != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			arg := decodeArgument(t, ts.URL, ex)
+			if id, _ := arg["id"].(string); id == "" {
+				t.Errorf("id should be set: %v", arg)
+			} else if tt.expected["id"] == "<generated>" {
+				arg["id"] = "<generated>"
+				arg["data"].(map[string]any)["message"].(map[string]any)["messageId"] = "<generated>"
+			}
+			if diff := cmp.Diff(tt.expected, arg); diff != "" {
+				t.Errorf("unexpected argument (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("unknown workflow", func(t *testing.T) {
+		t.Parallel()
+
+		body := map[string]any{"subscription": subscription, "message": map[string]any{}}
+		if status := doJSON(t, http.MethodPost, ts.URL+"/v1/projects/my-project/locations/us-central1/workflows/unknown:triggerPubsubExecution", body, nil); status != http.StatusNotFound {
+			t.Errorf("unexpected status: %d", status)
+		}
+	})
+}
+
+func TestTriggerCloudEvent(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{"wf": testExecutionsWorkflow, "other": testExecutionsWorkflow})
+	binaryHeader := func(contentType string) map[string]string {
+		h := map[string]string{
+			"Ce-Id":          "event-1",
+			"Ce-Source":      "//storage.googleapis.com/projects/_/buckets/my-bucket",
+			"Ce-Specversion": "1.0",
+			"Ce-Type":        "google.cloud.storage.object.v1.finalized",
+			"Ce-Subject":     "objects/foo.txt",
+		}
+		if contentType != "" {
+			h["Content-Type"] = contentType
+		}
+		return h
+	}
+	binaryEvent := func(extra map[string]any) map[string]any {
+		return lo.Assign(map[string]any{
+			"id":          "event-1",
+			"source":      "//storage.googleapis.com/projects/_/buckets/my-bucket",
+			"specversion": "1.0",
+			"type":        "google.cloud.storage.object.v1.finalized",
+			"subject":     "objects/foo.txt",
+		}, extra)
+	}
+
+	for _, tt := range []struct {
+		name           string
+		workflowID     string
+		header         map[string]string
+		body           string
+		expectedStatus int
+		expected       map[string]any
+	}{
+		{
+			name:           "binary mode with JSON data",
+			header:         binaryHeader("application/json"),
+			body:           `{"bucket":"my-bucket","size":1024}`,
+			expectedStatus: http.StatusOK,
+			expected: binaryEvent(map[string]any{
+				"datacontenttype": "application/json",
+				"data":            map[string]any{"bucket": "my-bucket", "size": float64(1024)},
+			}),
+		},
+		{
+			name:           "binary mode with text data",
+			header:         binaryHeader("text/plain; charset=utf-8"),
+			body:           "hello",
+			expectedStatus: http.StatusOK,
+			expected:       binaryEvent(map[string]any{"datacontenttype": "text/plain; charset=utf-8", "data": "hello"}),
+		},
+		{
+			name:           "binary mode with binary data",
+			header:         binaryHeader("application/octet-stream"),
+			body:           "\x00\x01",
+			expectedStatus: http.StatusOK,
+			expected:       binaryEvent(map[string]any{"datacontenttype": "application/octet-stream", "data_base64": "AAE="}),
+		},
+		{
+			name:           "binary mode without data",
+			header:         binaryHeader(""),
+			expectedStatus: http.StatusOK,
+			expected:       binaryEvent(nil),
+		},
+		{
+			name:   "structured mode",
+			header: map[string]string{"Content-Type": "application/cloudevents+json; charset=utf-8"},
+			body: `{"id":"event-2","source":"//pubsub.googleapis.com/projects/my-project/topics/my-topic","specversion":"1.0",` +
+				`"type":"google.cloud.pubsub.topic.v1.messagePublished","datacontenttype":"application/json","data":{"n":1}}`,
+			expectedStatus: http.StatusOK,
+			expected: map[string]any{
+				"id":              "event-2",
+				"source":          "//pubsub.googleapis.com/projects/my-project/topics/my-topic",
+				"specversion":     "1.0",
+				"type":            "google.cloud.pubsub.topic.v1.messagePublished",
+				"datacontenttype": "application/json",
+				"data":            map[string]any{"n": float64(1)},
+			},
+		},
+		{
+			name:           "binary mode with invalid JSON data",
+			header:         binaryHeader("application/json"),
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "structured mode with invalid JSON",
+			header:         map[string]string{"Content-Type": "application/cloudevents+json"},
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing required attribute",
+			header:         lo.OmitByKeys(binaryHeader("application/json"), []string{"Ce-Type"}),
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown workflow",
+			workflowID:     "unknown",
+			header:         binaryHeader("application/json"),
+			body:           `{}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			workflowID := tt.workflowID
+			if workflowID == "" {
+				workflowID = "wf"
+			}
+			req, err := http.NewRequest(http.MethodPost, ts.URL+testWorkflowsPath+"/"+workflowID+":triggerCloudEvent", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", res.StatusCode, tt.expectedStatus)
+			}
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+
+			var ex map[string]any
+			if err := json.NewDecoder(res.Body).Decode(&ex); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, decodeArgument(t, ts.URL, ex)); diff != "" {
+				t.Errorf("unexpected argument (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+var workflowIDRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// deployedWorkflow is the workflow deployed by the admin API at runtime.
+// refs. https://cloud.google.com/workflows/docs/reference/rest/v1/projects.locations.workflows
+type deployedWorkflow struct {
+	mu   sync.RWMutex
+	root workflow.WorkflowRoot
+
+	Name               string            `json:"name"`
+	Description        string            `json:"description,omitempty"`
+	State              string            `json:"state"`
+	RevisionId         string            `json:"revisionId"`
+	CreateTime         time.Time         `json:"createTime"`
+	UpdateTime         time.Time         `json:"updateTime"`
+	RevisionCreateTime time.Time         `json:"revisionCreateTime"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	ServiceAccount     string            `json:"serviceAccount,omitempty"`
+	SourceContents     string            `json:"sourceContents"`
+	CallLogLevel       string            `json:"callLogLevel,omitempty"`
+	UserEnvVars        map[string]string `json:"userEnvVars,omitempty"`
+
+	revision int
+}
+
+// snapshot returns the copy of the workflow. The workflow must be locked by the caller.
+func (wf *deployedWorkflow) snapshot() *deployedWorkflow {
+	return &deployedWorkflow{
+		Name:               wf.Name,
+		Description:        wf.Description,
+		State:              wf.State,
+		RevisionId:         wf.RevisionId,
+		CreateTime:         wf.CreateTime,
+		UpdateTime:         wf.UpdateTime,
+		RevisionCreateTime: wf.RevisionCreateTime,
+		Labels:             wf.Labels,
+		ServiceAccount:     wf.ServiceAccount,
+		SourceContents:     wf.SourceContents,
+		CallLogLevel:       wf.CallLogLevel,
+		UserEnvVars:        wf.UserEnvVars,
+	}
+}
+
+// deploy compiles the source contents as the next revision of the workflow. The workflow must be locked by the caller.
+func (wf *deployedWorkflow) deploy(source string) error {
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(source)) // YAML is a superset of JSON
+	if err != nil {
+		return fmt.Errorf("%w: invalid sourceContents: %v", errInvalidArgument, err)
+	}
+
+	wf.revision++
+	wf.RevisionId = formatRevisionID(wf.revision, sourceHash([]byte(source)))
+	wf.RevisionCreateTime = time.Now().UTC()
+	wf.SourceContents = source
+	wf.root = root
+	return nil
+}
+
+// LoadedWorkflow is the workflow loaded from the file by the loader of the store.
+type LoadedWorkflow struct {
+	Root   workflow.WorkflowRoot
+	Source []byte // to detect the changes of the workflow
+}
+
+// loadedRevision is the current revision of the loaded workflow.
+type loadedRevision struct {
+	root       workflow.WorkflowRoot
+	source     string
+	hash       string
+	revision   int
+	revisionID string
+	createTime time.Time // of the first revision
+	updateTime time.Time
+}
+
+// toWorkflow returns the loaded workflow as the workflow resource of the name.
+func (rev *loadedRevision) toWorkflow(name string) *deployedWorkflow {
+	return &deployedWorkflow{
+		Name:               name,
+		State:              "ACTIVE",
+		RevisionId:         rev.revisionID,
+		CreateTime:         rev.createTime,
+		UpdateTime:         rev.updateTime,
+		RevisionCreateTime: rev.updateTime,
+		SourceContents:     rev.source,
+	}
+}
+
+// reload loads the workflows by the loader, and revises the ones of which the sources are changed.
+// It must not be called concurrently.
+func (s *ExecutionStore) reload() error {
+	loaded, err := s.loader()
+	if err != nil {
+		return err
+	}
+
+	prev, _ := s.loadedWorkflows.Load().(map[string]*loadedRevision)
+	next := make(map[string]*loadedRevision, len(loaded))
+	for id, wf := range loaded {
+		hash := sourceHash(wf.Source)
+		if rev, ok := prev[id]; ok && rev.hash == hash {
+			next[id] = rev
+			continue
+		}
+
+		now := time.Now().UTC()
+		rev := &loadedRevision{root: wf.Root, source: string(wf.Source), hash: hash, revision: 1, createTime: now, updateTime: now}
+		if old, ok := prev[id]; ok {
+			rev.revision = old.revision + 1
+			rev.createTime = old.createTime
+			rev.revisionID = formatRevisionID(rev.revision, hash)
+			logging.Logf(logging.LevelInfo, "workflow is revised", "workflow", id, "from", old.revisionID, "to", rev.revisionID)
+			logging.Text(logging.LevelInfo, diffLines(old.source, rev.source, old.revisionID, rev.revisionID))
+		} else {
+			rev.revisionID = formatRevisionID(rev.revision, hash)
+			if prev != nil {
+				logging.Logf(logging.LevelInfo, "workflow is added", "workflow", id, "revision", rev.revisionID)
+			}
+		}
+		next[id] = rev
+	}
+	for id, old := range prev {
+		if _, ok := next[id]; !ok {
+			logging.Logf(logging.LevelInfo, "workflow is removed", "workflow", id, "revision", old.revisionID)
+		}
+	}
+	s.loadedWorkflows.Store(next)
+	return nil
+}
+
+func sourceHash(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
+
+// formatRevisionID formats the revision ID like 000001-a4f of the revision number and the hash of the source.
+func formatRevisionID(revision int, hash string) string {
+	return fmt.Sprintf("%06d-%s", revision, hash[:3])
+}
+
+// createWorkflow deploys the new workflow to the parent like projects/*/locations/*.
+func (s *ExecutionStore) createWorkflow(parent, id string, wf *deployedWorkflow) (*deployedWorkflow, error) {
+	if !workflowIDRegexp.MatchString(id) {
+		return nil, fmt.Errorf("%w: invalid workflowId: %q", errInvalidArgument, id)
+	}
+
+	wf.Name = parent + "/workflows/" + id
+	m := workflowNameRegexp.FindStringSubmatch(wf.Name)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid parent: %q", errInvalidArgument, parent)
+	}
+	if err := s.checkLocation(m[1], m[2]); err != nil {
+		return nil, err
+	}
+	if err := validateCallLogLevel(wf.CallLogLevel); err != nil {
+		return nil, err
+	}
+	if err := validateUserEnvVars(wf.UserEnvVars); err != nil {
+		return nil, err
+	}
+	if err := wf.deploy(wf.SourceContents); err != nil {
+		return nil, err
+	}
+	wf.State = "ACTIVE"
+	wf.CreateTime = wf.RevisionCreateTime
+	wf.UpdateTime = wf.CreateTime
+
+	if _, loaded := s.workflows.LoadOrStore(wf.Name, wf); loaded {
+		return nil, fmt.Errorf("%w: workflow %s", errAlreadyExists, wf.Name)
+	}
+	return wf.snapshot(), nil
+}
+
+// patchWorkflow updates the fields of the workflow in the update mask, or the non-empty fields if the mask is empty.
+// A new revision is deployed if the source contents are updated.
+func (s *ExecutionStore) patchWorkflow(name string, patch *deployedWorkflow, updateMask []string) (*deployedWorkflow, error) {
+	v, ok := s.workflows.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: workflow %s", errNotFound, name)
+	}
+	wf := v.(*deployedWorkflow)
+
+	if len(updateMask) == 0 {
+		if patch.Description != "" {
+			updateMask = append(updateMask, "description")
+		}
+		if patch.Labels != nil {
+			updateMask = append(updateMask, "labels")
+		}
+		if patch.ServiceAccount != "" {
+			updateMask = append(updateMask, "serviceAccount")
+		}
+		if patch.SourceContents != "" {
+			updateMask = append(updateMask, "sourceContents")
+		}
+		if patch.CallLogLevel != "" {
+			updateMask = append(updateMask, "callLogLevel")
+		}
+		if patch.UserEnvVars != nil {
+			updateMask = append(updateMask, "userEnvVars")
+		}
+	}
+
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	for _, field := range updateMask {
+		switch field {
+		case "description":
+			wf.Description = patch.Description
+		case "labels":
+			wf.Labels = patch.Labels
+		case "serviceAccount", "service_account":
+			wf.ServiceAccount = patch.ServiceAccount
+		case "sourceContents", "source_contents":
+			if patch.SourceContents == wf.SourceContents {
+				continue // the revision is not changed
+			}
+			if err := wf.deploy(patch.SourceContents); err != nil {
+				return nil, err
+			}
+		case "callLogLevel", "call_log_level":
+			if err := validateCallLogLevel(patch.CallLogLevel); err != nil {
+				return nil, err
+			}
+			wf.CallLogLevel = patch.CallLogLevel
+		case "userEnvVars", "user_env_vars":
+			if err := validateUserEnvVars(patch.UserEnvVars); err != nil {
+				return nil, err
+			}
+			wf.UserEnvVars = patch.UserEnvVars
+		default:
+			return nil, fmt.Errorf("%w: unsupported updateMask field: %s", errInvalidArgument, field)
+		}
+	}
+	wf.UpdateTime = time.Now().UTC()
+	return wf.snapshot(), nil
+}
+
+// deleteWorkflow deletes the workflow. The running executions of it are not affected.
+func (s *ExecutionStore) deleteWorkflow(name string) error {
+	if _, loaded := s.workflows.LoadAndDelete(name); !loaded {
+		return fmt.Errorf("%w: workflow %s", errNotFound, name)
+	}
+	return nil
+}
+
+// getWorkflow returns the snapshot of the workflow specified by the name like projects/*/locations/*/workflows/*,
+// which is deployed by the admin API or loaded by the loader of the store by the workflow ID.
+func (s *ExecutionStore) getWorkflow(name string) (*deployedWorkflow, error) {
+	m := workflowNameRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid name: %q", errInvalidArgument, name)
+	}
+
+	if v, ok := s.workflows.Load(name); ok {
+		wf := v.(*deployedWorkflow)
+		wf.mu.RLock()
+		defer wf.mu.RUnlock()
+		return wf.snapshot(), nil
+	}
+	if rev, ok := s.loadedWorkflows.Load().(map[string]*loadedRevision)[m[3]]; ok {
+		return rev.toWorkflow(name), nil
+	}
+	return nil, fmt.Errorf("%w: workflow %s", errNotFound, name)
+}
+
+// listWorkflows returns the snapshots of the workflows in the parent like projects/*/locations/* in the name order.
+// The loaded workflows are listed in any parents unless the deployed ones have the same names.
+func (s *ExecutionStore) listWorkflows(parent string) []*deployedWorkflow {
+	results := []*deployedWorkflow{}
+	s.workflows.Range(func(key, value any) bool {
+		if strings.HasPrefix(key.(string), parent+"/workflows/") {
+			wf := value.(*deployedWorkflow)
+			wf.mu.RLock()
+			defer wf.mu.RUnlock()
+			results = append(results, wf.snapshot())
+		}
+		return true
+	})
+	for id, rev := range s.loadedWorkflows.Load().(map[string]*loadedRevision) {
+		name := parent + "/workflows/" + id
+		if _, deployed := s.workflows.Load(name); !deployed {
+			results = append(results, rev.toWorkflow(name))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// executableWorkflow is the current revision of the workflow to be executed.
+type executableWorkflow struct {
+	root         workflow.WorkflowRoot
+	revisionID   string
+	callLogLevel string
+	userEnvVars  map[string]string
+}
+
+// lookupWorkflow returns the current revision of the workflow deployed by the admin API, or the workflow loaded by
+// the loader of the store. The only loaded workflow is returned for any names to serve a workflow file without caring the names.
+// The call log level and the user-defined environment variables are only configured for the deployed workflows.
+func (s *ExecutionStore) lookupWorkflow(name string) (*executableWorkflow, bool) {
+	if v, ok := s.workflows.Load(name); ok {
+		wf := v.(*deployedWorkflow)
+		wf.mu.RLock()
+		defer wf.mu.RUnlock()
+		return &executableWorkflow{root: wf.root, revisionID: wf.RevisionId, callLogLevel: wf.CallLogLevel, userEnvVars: wf.UserEnvVars}, true
+	}
+
+	revs := s.loadedWorkflows.Load().(map[string]*loadedRevision)
+	if m := workflowNameRegexp.FindStringSubmatch(name); m != nil {
+		if rev, ok := revs[m[3]]; ok {
+			return &executableWorkflow{root: rev.root, revisionID: rev.revisionID}, true
+		}
+	}
+	if len(revs) == 1 {
+		for _, rev := range revs {
+			return &executableWorkflow{root: rev.root, revisionID: rev.revisionID}, true
+		}
+	}
+	return nil, false
+}
+
+// workflowResolver resolves the workflow IDs of experimental.executions.map into the current revisions of the workflows
+// deployed to the location, or the workflows loaded by the loader of the store.
+func (s *ExecutionStore) workflowResolver(project, location string) workflow.WorkflowResolver {
+	return func(workflowID string) (workflow.WorkflowRoot, string, bool) {
+		if v, ok := s.workflows.Load("projects/" + project + "/locations/" + location + "/workflows/" + workflowID); ok {
+			wf := v.(*deployedWorkflow)
+			wf.mu.RLock()
+			defer wf.mu.RUnlock()
+			return wf.root, wf.RevisionId, true
+		}
+		if rev, ok := s.loadedWorkflows.Load().(map[string]*loadedRevision)[workflowID]; ok {
+			return rev.root, rev.revisionID, true
+		}
+		return nil, "", false
+	}
+}
+
+// validateUserEnvVars validates the names of the user-defined environment variables of the workflows.
+// refs. https://cloud.google.com/workflows/docs/use-environment-variables
+func validateUserEnvVars(env map[string]string) error {
+	for name := range env {
+		if err := workflow.ValidateEnvName(name); err != nil {
+			return fmt.Errorf("%w: invalid userEnvVars: %v", errInvalidArgument, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,723 @@
+package server_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+func TestUserEnvVars(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        return: ${sys.get_env(args.name, "none")}
+`
+	_, ts := newTestServer(t, map[string]string{}, workflow.WithEnv(map[string]string{"FOO": "global", "BAR": "global"}))
+
+	for i, tt := range []struct {
+		name           string
+		userEnvVars    map[string]string
+		patch          map[string]any
+		env            string
+		expectedStatus int
+		expected       string
+	}{
+		{
+			name:           "user-defined one",
+			userEnvVars:    map[string]string{"FOO": "bar"},
+			env:            "FOO",
+			expectedStatus: http.StatusOK,
+			expected:       `"bar"`,
+		},
+		{
+			name:           "global one",
+			userEnvVars:    map[string]string{"FOO": "bar"},
+			env:            "BAR",
+			expectedStatus: http.StatusOK,
+			expected:       `"global"`,
+		},
+		{
+			name:           "global one without user-defined ones",
+			env:            "FOO",
+			expectedStatus: http.StatusOK,
+			expected:       `"global"`,
+		},
+		{
+			name:           "environment of the process",
+			userEnvVars:    map[string]string{"FOO": "bar"},
+			env:            "PATH",
+			expectedStatus: http.StatusOK,
+			expected:       `"none"`,
+		},
+		{
+			name:           "patched one",
+			userEnvVars:    map[string]string{"FOO": "bar"},
+			patch:          map[string]any{"userEnvVars": map[string]string{"FOO": "baz"}},
+			env:            "FOO",
+			expectedStatus: http.StatusOK,
+			expected:       `"baz"`,
+		},
+		{
+			name:           "reserved name",
+			userEnvVars:    map[string]string{"GOOGLE_CLOUD_PROJECT_ID": "other"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "patched reserved name",
+			patch:          map[string]any{"userEnvVars": map[string]string{"WORKFLOWS_FOO": "bar"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		i, tt := i, tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			id := "env" + strconv.Itoa(i)
+			body := map[string]any{"sourceContents": source, "userEnvVars": tt.userEnvVars}
+			status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"?workflowId="+id, body, nil)
+			if status == http.StatusOK && tt.patch != nil {
+				status = doJSON(t, http.MethodPatch, ts.URL+testWorkflowsPath+"/"+id, tt.patch, nil)
+			}
+			if status != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			var wf map[string]any
+			if status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/"+id, nil, &wf); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if _, ok := wf["userEnvVars"]; ok != (tt.userEnvVars != nil || tt.patch != nil) {
+				t.Errorf("unexpected userEnvVars: %v", wf["userEnvVars"])
+			}
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/"+id+"/executions", map[string]any{"argument": `{"name":"` + tt.env + `"}`}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			ex = waitExecution(t, ts.URL+"/v1/"+ex["name"].(string))
+			if ex["state"] != "SUCCEEDED" {
+				t.Fatalf("unexpected state: %v", ex)
+			}
+			if ex["result"] != tt.expected {
+				t.Errorf("unexpected result: %v, want %s", ex["result"], tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecutionsMap(t *testing.T) {
+	t.Parallel()
+
+	_, ts := newTestServer(t, map[string]string{
+		"parent": `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - arguments: [1, 2]
+    - map:
+        call: experimental.executions.map
+        args:
+          workflow_id: ${args.workflow_id}
+          arguments: ${arguments}
+        result: results
+    - done:
+        return: ${results}
+`,
+		"loaded": `
+main:
+  params: [n]
+  steps:
+    - double:
+        return: ${n * 2}
+`,
+	})
+	if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"?workflowId=deployed", map[string]any{"sourceContents": `
+main:
+  params: [n]
+  steps:
+    - triple:
+        return: ${n * 3}
+`}, nil); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+
+	for _, tt := range []struct {
+		workflowID    string
+		expectedState string
+		expected      string
+	}{
+		{workflowID: "loaded", expectedState: "SUCCEEDED", expected: "[2,4]"},
+		{workflowID: "deployed", expectedState: "SUCCEEDED", expected: "[3,6]"},
+		{workflowID: "unknown", expectedState: "FAILED"},
+	} {
+		tt := tt
+		t.Run(tt.workflowID, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/parent/executions", map[string]any{"argument": `{"workflow_id":"` + tt.workflowID + `"}`}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			ex = waitExecution(t, ts.URL+"/v1/"+ex["name"].(string))
+			if ex["state"] != tt.expectedState {
+				t.Fatalf("unexpected state: %v", ex)
+			}
+			if tt.expected != "" && ex["result"] != tt.expected {
+				t.Errorf("unexpected result: %v, want %s", ex["result"], tt.expected)
+			}
+		})
+	}
+}
+
+func TestWorkflowsAdminAPI(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - done:
+        return: v1
+`
+	const revisedSource = `
+main:
+  steps:
+    - done:
+        return: v2
+`
+	_, ts := newTestServer(t, map[string]string{})
+
+	type adminRequest struct {
+		method         string
+		path           string // relative to the workflow, and {id} is replaced by the workflow ID
+		body           any
+		expectedStatus int
+	}
+	for i, tt := range []struct {
+		name             string
+		requests         []adminRequest
+		expectedRevision string // the prefix of the revision ID, or empty if the workflow must not exist
+		expected         map[string]any
+		expectedResult   string
+	}{
+		{
+			name: "create",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source, "description": "desc"}, expectedStatus: http.StatusOK},
+			},
+			expectedRevision: "000001-",
+			expected:         map[string]any{"state": "ACTIVE", "description": "desc", "sourceContents": source},
+			expectedResult:   `"v1"`,
+		},
+		{
+			name: "create the existing one",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusOK},
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": revisedSource}, expectedStatus: http.StatusConflict},
+			},
+			expectedRevision: "000001-",
+			expected:         map[string]any{"state": "ACTIVE", "sourceContents": source},
+			expectedResult:   `"v1"`,
+		},
+		{
+			name: "create with invalid workflowId",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId=1-{id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusBadRequest},
+			},
+		},
+		{
+			name: "create with invalid sourceContents",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": "main: ["}, expectedStatus: http.StatusBadRequest},
+			},
+		},
+		{
+			name: "create with invalid body",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: "source", expectedStatus: http.StatusBadRequest},
+			},
+		},
+		{
+			name: "patch sourceContents",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusOK},
+				{method: http.MethodPatch, path: "/{id}", body: map[string]any{"sourceContents": revisedSource}, expectedStatus: http.StatusOK},
+			},
+			expectedRevision: "000002-",
+			expected:         map[string]any{"state": "ACTIVE", "sourceContents": revisedSource},
+			expectedResult:   `"v2"`,
+		},
+		{
+			name: "patch the same sourceContents",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusOK},
+				{method: http.MethodPatch, path: "/{id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusOK},
+			},
+			expectedRevision: "000001-",
+			expected:         map[string]any{"state": "ACTIVE", "sourceContents": source},
+			expectedResult:   `"v1"`,
+		},
+		{
+			name: "patch the fields in updateMask",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source, "description": "desc"}, expectedStatus: http.StatusOK},
+				{method: http.MethodPatch, path: "/{id}?updateMask=labels", body: map[string]any{"sourceContents": revisedSource, "labels": map[string]string{"env": "dev"}}, expectedStatus: http.StatusOK},
+			},
+			expectedRevision: "000001-",
+			expected:         map[string]any{"state": "ACTIVE", "description": "desc", "labels": map[string]any{"env": "dev"}, "sourceContents": source},
+			expectedResult:   `"v1"`,
+		},
+		{
+			name: "patch with invalid sourceContents",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusOK},
+				{method: http.MethodPatch, path: "/{id}", body: map[string]any{"sourceContents": "main: ["}, expectedStatus: http.StatusBadRequest},
+			},
+			expectedRevision: "000001-",
+			expected:         map[string]any{"state": "ACTIVE", "sourceContents": source},
+			expectedResult:   `"v1"`,
+		},
+		{
+			name: "patch unsupported field",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusOK},
+				{method: http.MethodPatch, path: "/{id}?updateMask=name", body: map[string]any{"name": "other"}, expectedStatus: http.StatusBadRequest},
+			},
+			expectedRevision: "000001-",
+			expected:         map[string]any{"state": "ACTIVE", "sourceContents": source},
+			expectedResult:   `"v1"`,
+		},
+		{
+			name: "patch unknown one",
+			requests: []adminRequest{
+				{method: http.MethodPatch, path: "/{id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusNotFound},
+			},
+		},
+		{
+			name: "delete",
+			requests: []adminRequest{
+				{method: http.MethodPost, path: "?workflowId={id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusOK},
+				{method: http.MethodDelete, path: "/{id}", expectedStatus: http.StatusOK},
+			},
+		},
+		{
+			name: "delete unknown one",
+			requests: []adminRequest{
+				{method: http.MethodDelete, path: "/{id}", expectedStatus: http.StatusNotFound},
+			},
+		},
+		{
+			name: "unsupported method",
+			requests: []adminRequest{
+				{method: http.MethodPut, path: "/{id}", body: map[string]any{"sourceContents": source}, expectedStatus: http.StatusMethodNotAllowed},
+			},
+		},
+	} {
+		i, tt := i, tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			id := "admin" + strconv.Itoa(i)
+			for _, req := range tt.requests {
+				path := strings.ReplaceAll(req.path, "{id}", id)
+				if status := doJSON(t, req.method, ts.URL+testWorkflowsPath+path, req.body, nil); status != req.expectedStatus {
+					t.Fatalf("unexpected status of %s %s: %d, want %d", req.method, path, status, req.expectedStatus)
+				}
+			}
+
+			var wf map[string]any
+			status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/"+id, nil, &wf)
+			if tt.expectedRevision == "" {
+				if status != http.StatusNotFound {
+					t.Errorf("unexpected status: %d", status)
+				}
+				if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/"+id+"/executions", map[string]any{}, nil); status != http.StatusNotFound {
+					t.Errorf("unexpected status of the execution: %d", status)
+				}
+				return
+			}
+			if status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if revisionID, _ := wf["revisionId"].(string); !strings.HasPrefix(revisionID, tt.expectedRevision) {
+				t.Errorf("unexpected revisionId: %v, want %s*", wf["revisionId"], tt.expectedRevision)
+			}
+			actual := lo.PickByKeys(wf, []string{"state", "description", "labels", "sourceContents"})
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected workflow (-want +got):\n%s", diff)
+			}
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/"+id+"/executions", map[string]any{}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status of the execution: %d", status)
+			}
+			ex = waitExecution(t, ts.URL+"/v1/"+ex["name"].(string))
+			if ex["result"] != tt.expectedResult {
+				t.Errorf("unexpected result: %v, want %s", ex["result"], tt.expectedResult)
+			}
+			if ex["workflowRevisionId"] != wf["revisionId"] {
+				t.Errorf("unexpected workflowRevisionId: %v, want %v", ex["workflowRevisionId"], wf["revisionId"])
+			}
+		})
+	}
+}
+
+func TestWorkflowRouting(t *testing.T) {
+	t.Parallel()
+
+	_, multi := newTestServer(t, map[string]string{
+		"foo": `
+main:
+  steps:
+    - done:
+        return: foo
+`,
+		"bar": `
+main:
+  steps:
+    - done:
+        return: bar
+`,
+	})
+	_, single := newTestServer(t, map[string]string{
+		"foo": `
+main:
+  steps:
+    - done:
+        return: foo
+`,
+	})
+
+	for _, tt := range []struct {
+		name           string
+		baseURL        string
+		workflowID     string
+		expectedStatus int
+		expected       string
+	}{
+		{name: "foo", baseURL: multi.URL, workflowID: "foo", expectedStatus: http.StatusOK, expected: `"foo"`},
+		{name: "bar", baseURL: multi.URL, workflowID: "bar", expectedStatus: http.StatusOK, expected: `"bar"`},
+		{name: "unknown of the workflows", baseURL: multi.URL, workflowID: "baz", expectedStatus: http.StatusNotFound},
+		{name: "the only workflow", baseURL: single.URL, workflowID: "foo", expectedStatus: http.StatusOK, expected: `"foo"`},
+		{name: "the only workflow for any names", baseURL: single.URL, workflowID: "baz", expectedStatus: http.StatusOK, expected: `"foo"`},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ex map[string]any
+			status := doJSON(t, http.MethodPost, tt.baseURL+testWorkflowsPath+"/"+tt.workflowID+"/executions", map[string]any{}, &ex)
+			if status != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if name := ex["name"].(string); !strings.HasPrefix(name, testWorkflowsPath[len("/v1/"):]+"/"+tt.workflowID+"/executions/") {
+				t.Errorf("unexpected name: %s", name)
+			}
+
+			ex = waitExecution(t, tt.baseURL+"/v1/"+ex["name"].(string))
+			if ex["result"] != tt.expected {
+				t.Errorf("unexpected result: %v, want %s", ex["result"], tt.expected)
+			}
+		})
+	}
+}
+
+func TestWorkflowRevisions(t *testing.T) {
+	t.Parallel()
+
+	const v1 = `
+main:
+  steps:
+    - done:
+        return: v1
+`
+	const v2 = `
+main:
+  steps:
+    - done:
+        return: v2
+`
+	revisionID := func(revision int, source string) string {
+		sum := sha256.Sum256([]byte(source))
+		return fmt.Sprintf("%06d-%s", revision, hex.EncodeToString(sum[:])[:3])
+	}
+
+	for _, tt := range []struct {
+		name     string
+		sources  []string // loaded one by one, and the empty one fails to load
+		expected string
+		result   string
+	}{
+		{name: "initial", sources: []string{v1}, expected: revisionID(1, v1), result: `"v1"`},
+		{name: "unchanged", sources: []string{v1, v1}, expected: revisionID(1, v1), result: `"v1"`},
+		{name: "changed", sources: []string{v1, v2}, expected: revisionID(2, v2), result: `"v2"`},
+		{name: "reverted", sources: []string{v1, v2, v1}, expected: revisionID(3, v1), result: `"v1"`},
+		{name: "failed to reload", sources: []string{v1, ""}, expected: revisionID(1, v1), result: `"v1"`},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i := 0
+			store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+				source := tt.sources[i]
+				if source == "" {
+					return nil, errors.New("failed to load")
+				}
+				root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+				if err != nil {
+					return nil, err
+				}
+				return map[string]*server.LoadedWorkflow{"wf": {Root: root, Source: []byte(source)}}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i = 1; i < len(tt.sources); i++ {
+				if err := store.Reload(); err != nil {
+					t.Logf("expected error: %v", err)
+				}
+			}
+			ts := httptest.NewServer(server.NewHTTPHandler(store))
+			t.Cleanup(ts.Close)
+
+			var wf map[string]any
+			if status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/wf", nil, &wf); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if wf["revisionId"] != tt.expected {
+				t.Errorf("unexpected revisionId: %v, want %s", wf["revisionId"], tt.expected)
+			}
+
+			var ex map[string]any
+			if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"/wf/executions", map[string]any{}, &ex); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			ex = waitExecution(t, ts.URL+"/v1/"+ex["name"].(string))
+			if ex["workflowRevisionId"] != tt.expected {
+				t.Errorf("unexpected workflowRevisionId: %v, want %s", ex["workflowRevisionId"], tt.expected)
+			}
+			if ex["result"] != tt.result {
+				t.Errorf("unexpected result: %v, want %s", ex["result"], tt.result)
+			}
+		})
+	}
+}
+
+func TestListWorkflows(t *testing.T) {
+	t.Parallel()
+
+	const loadedSource = `
+main:
+  steps:
+    - done:
+        return: loaded
+`
+	const deployedSource = `
+main:
+  steps:
+    - done:
+        return: deployed
+`
+	_, ts := newTestServer(t, map[string]string{"loaded": loadedSource, "shadowed": loadedSource})
+	for _, id := range []string{"deployed", "shadowed"} {
+		if status := doJSON(t, http.MethodPost, ts.URL+testWorkflowsPath+"?workflowId="+id, map[string]any{"sourceContents": deployedSource}, nil); status != http.StatusOK {
+			t.Fatalf("unexpected status: %d", status)
+		}
+	}
+
+	const otherWorkflowsPath = "/v1/projects/other-project/locations/us-central1/workflows"
+	for _, tt := range []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expected       []map[string]any
+	}{
+		{
+			name:           "list",
+			path:           testWorkflowsPath,
+			expectedStatus: http.StatusOK,
+			expected: []map[string]any{
+				{"name": testWorkflowsPath[len("/v1/"):] + "/deployed", "sourceContents": deployedSource},
+				{"name": testWorkflowsPath[len("/v1/"):] + "/loaded", "sourceContents": loadedSource},
+				{"name": testWorkflowsPath[len("/v1/"):] + "/shadowed", "sourceContents": deployedSource},
+			},
+		},
+		{
+			name:           "list in other project",
+			path:           otherWorkflowsPath,
+			expectedStatus: http.StatusOK,
+			expected: []map[string]any{
+				{"name": otherWorkflowsPath[len("/v1/"):] + "/loaded", "sourceContents": loadedSource},
+				{"name": otherWorkflowsPath[len("/v1/"):] + "/shadowed", "sourceContents": loadedSource},
+			},
+		},
+		{
+			name:           "get deployed",
+			path:           testWorkflowsPath + "/deployed",
+			expectedStatus: http.StatusOK,
+			expected:       []map[string]any{{"name": testWorkflowsPath[len("/v1/"):] + "/deployed", "sourceContents": deployedSource}},
+		},
+		{
+			name:           "get loaded",
+			path:           testWorkflowsPath + "/loaded",
+			expectedStatus: http.StatusOK,
+			expected:       []map[string]any{{"name": testWorkflowsPath[len("/v1/"):] + "/loaded", "sourceContents": loadedSource}},
+		},
+		{
+			name:           "get shadowed",
+			path:           testWorkflowsPath + "/shadowed",
+			expectedStatus: http.StatusOK,
+			expected:       []map[string]any{{"name": testWorkflowsPath[len("/v1/"):] + "/shadowed", "sourceContents": deployedSource}},
+		},
+		{
+			name:           "get unknown",
+			path:           testWorkflowsPath + "/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var res struct {
+				Workflows []map[string]any `json:"workflows"`
+			}
+			var status int
+			if strings.HasSuffix(tt.path, "/workflows") {
+				status = doJSON(t, http.MethodGet, ts.URL+tt.path, nil, &res)
+			} else {
+				var wf map[string]any
+				status = doJSON(t, http.MethodGet, ts.URL+tt.path, nil, &wf)
+				res.Workflows = []map[string]any{wf}
+			}
+			if status != tt.expectedStatus {
+				t.Fatalf("unexpected status: %d, want %d", status, tt.expectedStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			actual := make([]map[string]any, len(res.Workflows))
+			for i, wf := range res.Workflows {
+				if revisionID, _ := wf["revisionId"].(string); !strings.HasPrefix(revisionID, "000001-") {
+					t.Errorf("unexpected revisionId of %v: %v", wf["name"], wf["revisionId"])
+				}
+				for _, key := range []string{"createTime", "updateTime", "revisionCreateTime"} {
+					if _, ok := wf[key]; !ok {
+						t.Errorf("%s of %v should be set", key, wf["name"])
+					}
+				}
+				actual[i] = lo.PickByKeys(wf, []string{"name", "sourceContents"})
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("unexpected workflows (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWatchWorkflows(t *testing.T) {
+	t.Parallel()
+
+	const v1 = `
+main:
+  steps:
+    - done:
+        return: v1
+`
+	const v2 = `
+main:
+  steps:
+    - done:
+        return: v2
+`
+	for _, tt := range []struct {
+		name     string
+		source   string
+		expected string // the prefix of the revisionId after the change
+	}{
+		{name: "changed", source: v2, expected: "000002-"},
+		{name: "invalid", source: "main: [", expected: "000001-"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "wf.yaml")
+			if err := os.WriteFile(path, []byte(v1), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			reloaded := make(chan struct{}, 1)
+			store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+				defer func() {
+					select {
+					case reloaded <- struct{}{}:
+					default: // e.g. the temporary directory is removed after the test
+					}
+				}()
+				source, err := os.ReadFile(path)
+				if err != nil {
+					return nil, err
+				}
+				root, err := workflow.ParseWorkflowYAML(bytes.NewReader(source))
+				if err != nil {
+					return nil, err
+				}
+				return map[string]*server.LoadedWorkflow{"wf": {Root: root, Source: source}}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-reloaded // initial load
+			if err := store.WatchWorkflows([]string{path}); err != nil {
+				t.Fatal(err)
+			}
+			ts := httptest.NewServer(server.NewHTTPHandler(store))
+			t.Cleanup(ts.Close)
+
+			if err := os.WriteFile(path, []byte(tt.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-reloaded:
+			case <-time.After(5 * time.Second):
+				t.Fatal("should be reloaded")
+			}
+
+			var wf map[string]any
+			if status := doJSON(t, http.MethodGet, ts.URL+testWorkflowsPath+"/wf", nil, &wf); status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+			if revisionID, _ := wf["revisionId"].(string); !strings.HasPrefix(revisionID, tt.expected) {
+				t.Errorf("unexpected revisionId: %s, want %s*", revisionID, tt.expected)
+			}
+		})
+	}
+}
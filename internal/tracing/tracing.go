@@ -0,0 +1,336 @@
+// Package tracing traces the executions of the workflows as the spans, and POSTs them in the JSON encoding of
+// OTLP/HTTP to the collectors accepting it (e.g. the OpenTelemetry Collector and Jaeger).
+// It's a minimal exporter written by hand, not the OpenTelemetry SDK: the protobuf encoding, the sampling, the retries,
+// the OTEL_* environment variables and the propagation of the incoming trace contexts are not supported.
+// refs. https://opentelemetry.io/docs/specs/otlp/#otlphttp
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+)
+
+const flushInterval = time.Second
+
+// Tracer starts the root spans, and exports the ended spans to the collector periodically.
+type Tracer struct {
+	endpoint    string // like http://localhost:4318/v1/traces
+	serviceName string
+	client      *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewJSONTracer returns the tracer which exports the spans to the endpoint of the traces like
+// http://localhost:4318/v1/traces in the JSON encoding of OTLP/HTTP.
+func NewJSONTracer(endpoint, serviceName string) *Tracer {
+	t := &Tracer{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+func (t *Tracer) loop() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-t.stop:
+			t.flush()
+			return
+		}
+	}
+}
+
+// Shutdown exports the pending spans and stops the tracer.
+func (t *Tracer) Shutdown() {
+	close(t.stop)
+	<-t.stopped
+}
+
+// Start starts the root span of a new trace.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{tracer: t, name: name, kind: spanKindInternal, start: time.Now(), attributes: map[string]any{}}
+	randomID(span.traceID[:])
+	randomID(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *Tracer) export(span *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, span)
+}
+
+func (t *Tracer) flush() {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(newExportTraceServiceRequest(t.serviceName, spans))
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to encode spans", "error", err)
+		return
+	}
+	res, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to export spans", "error", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		logging.Logf(logging.LevelError, "failed to export spans", "status", res.Status)
+	}
+}
+
+func randomID(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand: %v", err))
+	}
+}
+
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+)
+
+type spanKey struct{}
+
+// Span is an operation in a trace. The methods of the nil span do nothing to trace optionally.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]any
+	err        error
+}
+
+// FromContext returns the span of the context, or nil if it's not traced.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts the child span of the span of the context. It returns the nil span if the context is not traced.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, spanKindInternal)
+}
+
+func start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: parent.tracer, traceID: parent.traceID, parentID: parent.spanID, name: name, kind: kind, start: time.Now(), attributes: map[string]any{}}
+	randomID(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttribute sets the attribute of the span. The value must be a string, a bool, an int, an int64 or a float64.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// End ends the span with the error of the operation if failed, and queues it to be exported.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.err = err
+	s.mu.Unlock()
+	s.tracer.export(s)
+}
+
+// Traceparent returns the traceparent header of the W3C Trace Context to propagate the span.
+// refs. https://www.w3.org/TR/trace-context/#traceparent-header
+func (s *Span) Traceparent() string {
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-01"
+}
+
+// WrapTransport traces the outbound HTTP requests as the client spans of the span of the request context,
+// and propagates them to the servers by the traceparent header.
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx, span := start(req.Context(), req.Method, spanKindClient)
+		if span == nil {
+			return rt.RoundTrip(req)
+		}
+
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("url.full", req.URL.String())
+		req = req.Clone(ctx)
+		req.Header.Set("traceparent", span.Traceparent())
+
+		res, err := rt.RoundTrip(req)
+		spanErr := err
+		if err == nil {
+			span.SetAttribute("http.response.status_code", res.StatusCode)
+			if res.StatusCode >= 400 {
+				spanErr = fmt.Errorf("HTTP %s", res.Status)
+			}
+		}
+		span.End(spanErr)
+		return res, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// the messages of opentelemetry.proto.collector.trace.v1.ExportTraceServiceRequest in the JSON encoding of OTLP,
+// which encodes the IDs in the hex strings instead of base64.
+// refs. https://github.com/open-telemetry/opentelemetry-proto/blob/v1.0.0/opentelemetry/proto/trace/v1/trace.proto
+
+type exportTraceServiceRequest struct {
+	ResourceSpans []*resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   *resource     `json:"resource"`
+	ScopeSpans []*scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []*keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope *instrumentationScope `json:"scope"`
+	Spans []*otlpSpan           `json:"spans"`
+}
+
+type instrumentationScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []*keyValue `json:"attributes,omitempty"`
+	Status            *status     `json:"status,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code"` // STATUS_CODE_ERROR is 2
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string    `json:"key"`
+	Value *anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 is encoded in the decimal string
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func newAnyValue(v any) *anyValue {
+	switch vv := v.(type) {
+	case string:
+		return &anyValue{StringValue: &vv}
+	case bool:
+		return &anyValue{BoolValue: &vv}
+	case int:
+		s := strconv.Itoa(vv)
+		return &anyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(vv, 10)
+		return &anyValue{IntValue: &s}
+	case float64:
+		return &anyValue{DoubleValue: &vv}
+	default:
+		s := fmt.Sprint(v)
+		return &anyValue{StringValue: &s}
+	}
+}
+
+func newExportTraceServiceRequest(serviceName string, spans []*Span) *exportTraceServiceRequest {
+	scope := &scopeSpans{
+		Scope: &instrumentationScope{Name: "github.com/karupanerura/google-cloud-workflow-emulator"},
+		Spans: make([]*otlpSpan, len(spans)),
+	}
+	for i, span := range spans {
+		scope.Spans[i] = span.toOTLP()
+	}
+	return &exportTraceServiceRequest{
+		ResourceSpans: []*resourceSpans{{
+			Resource:   &resource{Attributes: []*keyValue{{Key: "service.name", Value: newAnyValue(serviceName)}}},
+			ScopeSpans: []*scopeSpans{scope},
+		}},
+	}
+}
+
+func (s *Span) toOTLP() *otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := &otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parentID != [8]byte{} {
+		ret.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for key, value := range s.attributes {
+		ret.Attributes = append(ret.Attributes, &keyValue{Key: key, Value: newAnyValue(value)})
+	}
+	if s.err != nil {
+		ret.Status = &status{Code: 2, Message: s.err.Error()}
+	}
+	return ret
+}
@@ -0,0 +1,170 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/tracing"
+)
+
+type collectedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Attributes   []struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	} `json:"attributes"`
+	Status *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+// collector receives the spans like the OTLP/HTTP endpoint accepting JSON.
+type collector struct {
+	mu           sync.Mutex
+	serviceNames []string
+	spans        []*collectedSpan
+	traceparents []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string         `json:"key"`
+					Value map[string]any `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []*collectedSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, attr := range rs.Resource.Attributes {
+			if attr.Key == "service.name" {
+				c.serviceNames = append(c.serviceNames, attr.Value["stringValue"].(string))
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	c := &collector{}
+	ts := httptest.NewServer(c)
+	t.Cleanup(ts.Close)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		c.traceparents = append(c.traceparents, r.Header.Get("traceparent"))
+		c.mu.Unlock()
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(backend.Close)
+	client := &http.Client{Transport: tracing.WrapTransport(http.DefaultTransport)}
+
+	tracer := tracing.NewJSONTracer(ts.URL+"/v1/traces", "test-service")
+	ctx, root := tracer.Start(context.Background(), "execution")
+	root.SetAttribute("workflows.workflow_id", "hello")
+	stepCtx, step := tracing.Start(ctx, "step")
+	for _, path := range []string{"/ok", "/error"} {
+		req, err := http.NewRequestWithContext(stepCtx, http.MethodGet, backend.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	step.End(errors.New("boom"))
+	root.End(nil)
+	tracer.Shutdown()
+
+	// the spans without the tracer are ignored
+	if _, span := tracing.Start(context.Background(), "untraced"); span != nil {
+		t.Error("the span of the untraced context should be nil")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if diff := cmp.Diff([]string{"test-service"}, c.serviceNames); diff != "" {
+		t.Errorf("unexpected service names (-want +got):\n%s", diff)
+	}
+	spans := map[string]*collectedSpan{}
+	var names []string
+	for _, span := range c.spans {
+		spans[span.SpanID] = span
+		names = append(names, span.Name)
+	}
+	sort.Strings(names)
+	if diff := cmp.Diff([]string{"GET", "GET", "execution", "step"}, names); diff != "" {
+		t.Fatalf("unexpected spans (-want +got):\n%s", diff)
+	}
+
+	for _, span := range c.spans {
+		if span.TraceID != c.spans[0].TraceID {
+			t.Errorf("span %s is in the other trace %s", span.Name, span.TraceID)
+		}
+		var expectedParent string
+		switch span.Name {
+		case "execution":
+			if span.ParentSpanID != "" {
+				t.Errorf("root span has the parent %s", span.ParentSpanID)
+			}
+			continue
+		case "step":
+			expectedParent = "execution"
+			if span.Status == nil || span.Status.Code != 2 || span.Status.Message != "boom" {
+				t.Errorf("unexpected status of the step: %+v", span.Status)
+			}
+		case "GET":
+			expectedParent = "step"
+			if span.Kind != 3 {
+				t.Errorf("unexpected kind of the client span: %d", span.Kind)
+			}
+		}
+		if parent, ok := spans[span.ParentSpanID]; !ok || parent.Name != expectedParent {
+			t.Errorf("unexpected parent of %s: %v", span.Name, span.ParentSpanID)
+		}
+	}
+
+	for _, traceparent := range c.traceparents {
+		if len(traceparent) != 55 || traceparent[3:35] != c.spans[0].TraceID {
+			t.Errorf("unexpected traceparent: %q", traceparent)
+		}
+	}
+	if len(c.traceparents) != 2 {
+		t.Errorf("unexpected traceparents: %v", c.traceparents)
+	}
+}
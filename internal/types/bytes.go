@@ -0,0 +1,5 @@
+package types
+
+// Bytes is the bytes value of the workflows such as the non-JSON HTTP response bodies, text.encode and base64.decode.
+// refs. https://cloud.google.com/workflows/docs/reference/syntax/datatypes#bytes
+type Bytes []byte
@@ -12,20 +12,22 @@ import (
 type ErrorTag string
 
 const (
-	AuthErrorTag            ErrorTag = "AuthError"
-	ConnectionErrorTag      ErrorTag = "ConnectionError"
-	HttpErrorTag            ErrorTag = "HttpError"
-	IndexErrorTag           ErrorTag = "IndexError"
-	KeyErrorTag             ErrorTag = "KeyError"
-	ParallelNestingErrorTag ErrorTag = "ParallelNestingError"
-	RecursionErrorTag       ErrorTag = "RecursionError"
-	ResourceLimitErrorTag   ErrorTag = "ResourceLimitError"
-	SystemErrorTag          ErrorTag = "SystemError"
-	TypeErrorTag            ErrorTag = "TypeError"
-	TimeoutErrorTag         ErrorTag = "TimeoutError"
-	UnhandledBranchErrorTag ErrorTag = "UnhandledBranchError"
-	ValueErrorTag           ErrorTag = "ValueError"
-	ZeroDivisionErrorTag    ErrorTag = "ZeroDivisionError"
+	AuthErrorTag             ErrorTag = "AuthError"
+	ConnectionErrorTag       ErrorTag = "ConnectionError"
+	ConnectionFailedErrorTag ErrorTag = "ConnectionFailedError"
+	HttpErrorTag             ErrorTag = "HttpError"
+	IndexErrorTag            ErrorTag = "IndexError"
+	KeyErrorTag              ErrorTag = "KeyError"
+	OperationErrorTag        ErrorTag = "OperationError"
+	ParallelNestingErrorTag  ErrorTag = "ParallelNestingError"
+	RecursionErrorTag        ErrorTag = "RecursionError"
+	ResourceLimitErrorTag    ErrorTag = "ResourceLimitError"
+	SystemErrorTag           ErrorTag = "SystemError"
+	TypeErrorTag             ErrorTag = "TypeError"
+	TimeoutErrorTag          ErrorTag = "TimeoutError"
+	UnhandledBranchErrorTag  ErrorTag = "UnhandledBranchError"
+	ValueErrorTag            ErrorTag = "ValueError"
+	ZeroDivisionErrorTag     ErrorTag = "ZeroDivisionError"
 )
 
 type Exception interface {
@@ -93,7 +95,7 @@ func (e *Error) Exception() any {
 	tags := []any{}
 	for err := error(e); err != nil; err = errors.Unwrap(err) {
 		if e, ok := err.(*Error); ok {
-			tags = append(tags, e.Tag)
+			tags = append(tags, string(e.Tag)) // the strings to be matched by the in operator
 		}
 	}
 
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -11,7 +12,14 @@ import (
 type Function interface {
 	Name() string
 	Args() []string
-	Call([]any) (any, error)
+	Call(context.Context, []any) (any, error)
+}
+
+// ScopedFunction is a Function which is called with the symbol table of the caller's scope
+// to refer the internal symbols of the execution (e.g. subworkflows inherit them).
+type ScopedFunction interface {
+	Function
+	CallInScope(context.Context, *SymbolTable, []any) (any, error)
 }
 
 var nonNilableTypeSet = map[reflect.Kind]bool{
@@ -41,6 +49,7 @@ type reflectFunc struct {
 	name        string
 	args        []argDef
 	minimumArgs int
+	withContext bool
 	value       reflect.Value
 }
 
@@ -112,14 +121,21 @@ func (d *argDef) deepClone(src reflect.Value) reflect.Value {
 
 var errorInterfaceType = reflect.TypeOf((*error)(nil)).Elem()
 
+var contextInterfaceType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 func NewFunction(name string, args []Argument, f any) (Function, error) {
 	v := reflect.ValueOf(f)
 	if v.Kind() != reflect.Func {
 		return nil, fmt.Errorf("must be function but got %T: %+v", f, f)
 	}
 
+	// the context of the execution is passed as the first argument if the function receives it
 	t := v.Type()
-	if t.NumIn() != len(args) {
+	offset := 0
+	if t.NumIn() > 0 && t.In(0) == contextInterfaceType {
+		offset = 1
+	}
+	if t.NumIn()-offset != len(args) {
 		return nil, fmt.Errorf("mis-match arguments count with args %+v: %+v", args, f)
 	}
 	if t.NumOut() != 2 {
@@ -132,7 +148,7 @@ func NewFunction(name string, args []Argument, f any) (Function, error) {
 	minimumArgs := 0
 	defs := make([]argDef, len(args))
 	for i, arg := range args {
-		argType := t.In(i)
+		argType := t.In(i + offset)
 
 		// fill argDef
 		defs[i].name = arg.Name
@@ -174,6 +190,7 @@ func NewFunction(name string, args []Argument, f any) (Function, error) {
 		name:        name,
 		args:        defs,
 		minimumArgs: minimumArgs,
+		withContext: offset == 1,
 		value:       v,
 	}, nil
 }
@@ -196,7 +213,17 @@ func (f *reflectFunc) Args() []string {
 	})
 }
 
-func (f *reflectFunc) Call(args []any) (any, error) {
+func (f *reflectFunc) Signature() Signature {
+	return Signature{
+		Args: lo.Map(f.args, func(def argDef, _ int) ValueType {
+			return typeOfReflectType(def.valueType)
+		}),
+		MinimumArgs: f.minimumArgs,
+		Result:      typeOfReflectType(f.value.Type().Out(0)),
+	}
+}
+
+func (f *reflectFunc) Call(ctx context.Context, args []any) (any, error) {
 	if len(args) > len(f.args) {
 		return nil, fmt.Errorf("too many arguments: %d arguments are allowed but got %d arguments, usage: %s(%s)", len(f.args), len(args), f.name, renderArgDefs(f.args))
 	}
@@ -237,6 +264,10 @@ func (f *reflectFunc) Call(args []any) (any, error) {
 		return nil, fmt.Errorf("invalid argument[%d] %s: expected type is %s but actual %s (%+v)", i, arg.name, arg.valueType.String(), argValues[i].Type().String(), argValues[i].Interface())
 	}
 
+	if f.withContext {
+		argValues = append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, argValues...)
+	}
+
 	ret := f.value.Call(argValues)
 	if !ret[1].IsZero() {
 		err := ret[1].Interface().(error)
@@ -267,7 +298,48 @@ func renderArgDefs(args []argDef) string {
 	return s.String()
 }
 
-func NewRawFunction(name string, args []Argument, f func([]any) (any, error)) Function {
+// NewScopedFunction creates a ScopedFunction from the factory which builds the function body for the caller's scope.
+// The factory is called with nil if the function is called outside of any scope.
+func NewScopedFunction(name string, args []Argument, factory func(*SymbolTable) any) (ScopedFunction, error) {
+	f, err := NewFunction(name, args, factory(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return &scopedFunction{
+		Function: f,
+		args:     args,
+		factory:  factory,
+	}, nil
+}
+
+func MustNewScopedFunction(name string, args []Argument, factory func(*SymbolTable) any) ScopedFunction {
+	fun, err := NewScopedFunction(name, args, factory)
+	if err != nil {
+		panic(err)
+	}
+	return fun
+}
+
+type scopedFunction struct {
+	Function
+	args    []Argument
+	factory func(*SymbolTable) any
+}
+
+func (f *scopedFunction) Signature() Signature {
+	return f.Function.(TypedFunction).Signature()
+}
+
+func (f *scopedFunction) CallInScope(ctx context.Context, st *SymbolTable, args []any) (any, error) {
+	fun, err := NewFunction(f.Name(), f.args, f.factory(st))
+	if err != nil {
+		return nil, err
+	}
+	return fun.Call(ctx, args)
+}
+
+func NewRawFunction(name string, args []Argument, f func(context.Context, []any) (any, error)) Function {
 	return &rawFunction{
 		name: name,
 		args: args,
@@ -278,7 +350,7 @@ func NewRawFunction(name string, args []Argument, f func([]any) (any, error)) Fu
 type rawFunction struct {
 	name string
 	args []Argument
-	f    func([]any) (any, error)
+	f    func(context.Context, []any) (any, error)
 }
 
 func (f *rawFunction) Name() string {
@@ -291,7 +363,12 @@ func (f *rawFunction) Args() []string {
 	})
 }
 
-func (f *rawFunction) Call(args []any) (any, error) {
+// Signature returns the count of the arguments only, because the arguments and the result of the raw functions are untyped.
+func (f *rawFunction) Signature() Signature {
+	return Signature{Args: make([]ValueType, len(f.args))}
+}
+
+func (f *rawFunction) Call(ctx context.Context, args []any) (any, error) {
 	if len(args) > len(f.args) {
 		return nil, fmt.Errorf("invalid function usage: %s(%s)", f.name, renderArguments(f.args))
 	}
@@ -312,7 +389,7 @@ func (f *rawFunction) Call(args []any) (any, error) {
 		}
 		args = append(args, f.args[len(args)].Default)
 	}
-	return f.f(args)
+	return f.f(ctx, args)
 }
 
 func renderArguments(args []Argument) string {
@@ -9,9 +9,25 @@ import (
 
 const (
 	// internal symbols
-	InternalInheritedVariablesSymbol = "__INTERNAL_INHERITED_VARIABLE_SET"
+	InternalInheritedVariablesSymbol  = "__INTERNAL_INHERITED_VARIABLE_SET"
+	InternalParallelDepthSymbol       = "__INTERNAL_PARALLEL_DEPTH"
+	InternalExecuteConfigSymbol       = "__INTERNAL_EXECUTE_CONFIG"
+	InternalEnvironmentSymbol         = "__INTERNAL_ENVIRONMENT"
+	InternalIsolatedEnvironmentSymbol = "__INTERNAL_ISOLATED_ENVIRONMENT"
+	InternalCallbackRegistrySymbol    = "__INTERNAL_CALLBACK_REGISTRY"
+	InternalLogRecorderSymbol         = "__INTERNAL_LOG_RECORDER"
 )
 
+// InternalScopedSymbols are the internal symbols to be inherited to the scope of subworkflows.
+var InternalScopedSymbols = []string{
+	InternalParallelDepthSymbol,
+	InternalExecuteConfigSymbol,
+	InternalEnvironmentSymbol,
+	InternalIsolatedEnvironmentSymbol,
+	InternalCallbackRegistrySymbol,
+	InternalLogRecorderSymbol,
+}
+
 type InternalInheritedVariables struct {
 	Shared map[string]bool
 }
@@ -70,7 +86,10 @@ func (st *SymbolTable) set(key string, value any) bool {
 
 func (st *SymbolTable) KeysChan() <-chan string {
 	ch := make(chan string)
-	go st.keysChan(ch)
+	go func() {
+		defer close(ch)
+		st.keysChan(ch)
+	}()
 	return ch
 }
 
@@ -0,0 +1,89 @@
+package types
+
+import (
+	reflect "github.com/goccy/go-reflect"
+)
+
+// ValueType is the type of the values of the workflows inferred statically without executing them.
+type ValueType int
+
+const (
+	UnknownType ValueType = iota // any type
+	NullType
+	BooleanType
+	IntegerType
+	DoubleType
+	StringType
+	BytesType
+	ListType
+	MapType
+	FunctionType
+)
+
+var valueTypeNames = []string{"unknown", "null", "boolean", "integer", "double", "string", "bytes", "list", "map", "function"}
+
+func (t ValueType) String() string {
+	return valueTypeNames[t]
+}
+
+// AssignableTo reports whether the values of the type are accepted as the type of u. The unknown types and null are accepted as any types.
+func (t ValueType) AssignableTo(u ValueType) bool {
+	return t == UnknownType || t == NullType || u == UnknownType || t == u
+}
+
+// TypeOf returns the type of the value.
+func TypeOf(value any) ValueType {
+	switch value.(type) {
+	case nil:
+		return NullType
+	case bool:
+		return BooleanType
+	case int64:
+		return IntegerType
+	case float64:
+		return DoubleType
+	case string:
+		return StringType
+	case Bytes:
+		return BytesType
+	case []any:
+		return ListType
+	case map[string]any:
+		return MapType
+	case Function:
+		return FunctionType
+	default:
+		return UnknownType
+	}
+}
+
+var valueTypesByReflectType = map[reflect.Type]ValueType{
+	reflect.TypeOf(false):            BooleanType,
+	reflect.TypeOf(int64(0)):         IntegerType,
+	reflect.TypeOf(float64(0)):       DoubleType,
+	reflect.TypeOf(""):               StringType,
+	reflect.TypeOf(Bytes(nil)):       BytesType,
+	reflect.TypeOf([]any(nil)):       ListType,
+	reflect.TypeOf(map[string]any{}): MapType,
+}
+
+// typeOfReflectType returns the type of the values assignable to the Go type, or UnknownType for the interfaces.
+func typeOfReflectType(t reflect.Type) ValueType {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem() // nilable
+	}
+	return valueTypesByReflectType[t]
+}
+
+// Signature is the types of the arguments and the result of the function to check the calls statically.
+type Signature struct {
+	Args        []ValueType
+	MinimumArgs int
+	Result      ValueType
+}
+
+// TypedFunction is a Function with its signature.
+type TypedFunction interface {
+	Function
+	Signature() Signature
+}
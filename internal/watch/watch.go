@@ -0,0 +1,92 @@
+// Package watch notifies the changes of the workflow files to reload them.
+// The changes are watched by inotify on Linux, and by polling the modification times of the files on the other platforms.
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// debounceDelay is the time to wait for the burst of the changes (e.g. an editor saving a file by a rename) to settle.
+const debounceDelay = 100 * time.Millisecond
+
+// target is a directory to watch with the names of the files in it, or all of the files if the names are nil.
+type target struct {
+	dir   string
+	names map[string]bool
+}
+
+func (t *target) match(name string) bool {
+	return t.names == nil || t.names[name]
+}
+
+// Watch calls onChange after the files, or the files in the directories, of the paths are changed (including created,
+// removed or renamed) until the process exits. The files are watched by their parent directories to follow the editors
+// replacing the files by renames.
+func Watch(paths []string, onChange func()) error {
+	targets, err := newTargets(paths)
+	if err != nil {
+		return err
+	}
+
+	changes := make(chan struct{}, 1)
+	if err := start(targets, changes); err != nil {
+		return err
+	}
+	go debounce(changes, onChange)
+	return nil
+}
+
+func newTargets(paths []string) ([]*target, error) {
+	byDir := map[string]*target{}
+	var targets []*target
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("os.Stat(%q): %w", path, err)
+		}
+
+		dir, name := path, ""
+		if !info.IsDir() {
+			dir, name = filepath.Dir(path), filepath.Base(path)
+		}
+		t, ok := byDir[dir]
+		if !ok {
+			t = &target{dir: dir, names: map[string]bool{}}
+			byDir[dir] = t
+			targets = append(targets, t)
+		}
+		if name == "" {
+			t.names = nil
+		} else if t.names != nil {
+			t.names[name] = true
+		}
+	}
+	return targets, nil
+}
+
+// notify sends the change without blocking, because the pending change covers the new one.
+func notify(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+func debounce(changes <-chan struct{}, onChange func()) {
+	for range changes {
+		timer := time.NewTimer(debounceDelay)
+	settle:
+		for {
+			select {
+			case <-changes:
+				timer.Reset(debounceDelay)
+			case <-timer.C:
+				break settle
+			}
+		}
+		onChange()
+	}
+}
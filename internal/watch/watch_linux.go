@@ -0,0 +1,62 @@
+//go:build linux
+
+package watch
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+func start(targets []*target, changes chan<- struct{}) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("inotify_init1: %w", err)
+	}
+
+	byWatch := make(map[int32]*target, len(targets))
+	for _, t := range targets {
+		wd, err := unix.InotifyAddWatch(fd, t.dir, inotifyMask)
+		if err != nil {
+			unix.Close(fd)
+			return fmt.Errorf("inotify_add_watch(%q): %w", t.dir, err)
+		}
+		byWatch[int32(wd)] = t
+	}
+
+	go func() {
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			n, err := unix.Read(fd, buf)
+			if err == unix.EINTR {
+				continue
+			}
+			if err != nil {
+				logging.Logf(logging.LevelError, "failed to read inotify events", "error", err)
+				return
+			}
+
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+
+				name := string(nameBytes)
+				for i, c := range nameBytes {
+					if c == 0 {
+						name = string(nameBytes[:i]) // padded by NULs
+						break
+					}
+				}
+				if t, ok := byWatch[event.Wd]; ok && t.match(name) {
+					notify(changes)
+				}
+			}
+		}
+	}()
+	return nil
+}
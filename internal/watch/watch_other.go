@@ -0,0 +1,66 @@
+//go:build !linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const pollInterval = time.Second
+
+func start(targets []*target, changes chan<- struct{}) error {
+	prev := snapshot(targets)
+	go func() {
+		t := time.NewTicker(pollInterval)
+		defer t.Stop()
+		for range t.C {
+			next := snapshot(targets)
+			if !equalSnapshots(prev, next) {
+				notify(changes)
+			}
+			prev = next
+		}
+	}()
+	return nil
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot returns the states of the files of the targets by the paths. The files failed to stat are missing.
+func snapshot(targets []*target) map[string]fileState {
+	states := map[string]fileState{}
+	for _, t := range targets {
+		entries, err := os.ReadDir(t.dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !t.match(entry.Name()) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			states[filepath.Join(t.dir, entry.Name())] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return states
+}
+
+func equalSnapshots(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(state.modTime) || other.size != state.size {
+			return false
+		}
+	}
+	return true
+}
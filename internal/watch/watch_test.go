@@ -0,0 +1,103 @@
+package watch_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/watch"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		watchDir bool // watch the directory instead of the file
+		change   func(dir string) error
+		expected bool
+	}{
+		{
+			name:     "modified file",
+			change:   func(dir string) error { return os.WriteFile(filepath.Join(dir, "main.yaml"), []byte("v2"), 0o644) },
+			expected: true,
+		},
+		{
+			name: "replaced file by rename",
+			change: func(dir string) error {
+				if err := os.WriteFile(filepath.Join(dir, "main.yaml.swp"), []byte("v2"), 0o644); err != nil {
+					return err
+				}
+				return os.Rename(filepath.Join(dir, "main.yaml.swp"), filepath.Join(dir, "main.yaml"))
+			},
+			expected: true,
+		},
+		{
+			name:     "removed file",
+			change:   func(dir string) error { return os.Remove(filepath.Join(dir, "main.yaml")) },
+			expected: true,
+		},
+		{
+			name:     "other file in the directory",
+			change:   func(dir string) error { return os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("v1"), 0o644) },
+			expected: false,
+		},
+		{
+			name:     "created file in the watched directory",
+			watchDir: true,
+			change:   func(dir string) error { return os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("v1"), 0o644) },
+			expected: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "main.yaml")
+			if err := os.WriteFile(path, []byte("v1"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.watchDir {
+				path = dir
+			}
+
+			changed := make(chan struct{}, 1)
+			if err := watch.Watch([]string{path}, func() {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond) // to make the modification times differ for the polling
+			if err := tt.change(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			// the polling on the other platforms takes a second at most
+			select {
+			case <-changed:
+				if !tt.expected {
+					t.Error("should not be notified")
+				}
+			case <-time.After(3 * time.Second):
+				if tt.expected {
+					t.Error("should be notified")
+				}
+			}
+		})
+	}
+}
+
+func TestWatchMissingPath(t *testing.T) {
+	t.Parallel()
+
+	err := watch.Watch([]string{filepath.Join(t.TempDir(), "missing.yaml")}, func() {})
+	if err == nil {
+		t.Fatal("should be error")
+	}
+	t.Logf("expected error: %v", err)
+}
@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Checkpoint is the state of an execution at a step boundary of the main workflow to resume the execution later.
+// The nested steps are not checkpointed, so the execution is resumed from the top-level step.
+type Checkpoint struct {
+	Step      StepName       `json:"step"` // to execute next
+	Variables map[string]any `json:"variables"`
+}
+
+// WithCheckpoint makes the execution pass the checkpoint to the save function at every step boundary of the main workflow.
+func WithCheckpoint(save func(*Checkpoint)) ExecuteOption {
+	return func(c *executeConfig) {
+		c.saveCheckpoint = save
+	}
+}
+
+// ResumeFrom makes the execution resume from the checkpoint instead of the start of the main workflow.
+// The arguments of the execution are ignored because the variables of the checkpoint have them.
+func ResumeFrom(checkpoint *Checkpoint) ExecuteOption {
+	return func(c *executeConfig) {
+		c.resume = checkpoint
+	}
+}
+
+// MarshalJSON encodes the checkpoint. The floats are always encoded with the decimal points to be decoded as the floats.
+func (c *Checkpoint) MarshalJSON() ([]byte, error) {
+	variables, err := encodeCheckpointValue(c.Variables)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Step      StepName `json:"step"`
+		Variables any      `json:"variables"`
+	}{Step: c.Step, Variables: variables})
+}
+
+// UnmarshalJSON decodes the checkpoint encoded by MarshalJSON.
+func (c *Checkpoint) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Step      StepName       `json:"step"`
+		Variables map[string]any `json:"variables"`
+	}
+	if err := unmarshalJSONUseNumber(b, &raw); err != nil {
+		return err
+	}
+
+	variables, err := decodeJSONNumberRecursive(raw.Variables)
+	if err != nil {
+		return fmt.Errorf("variables: %w", err)
+	}
+	c.Step = raw.Step
+	c.Variables = variables.(map[string]any)
+	return nil
+}
+
+func encodeCheckpointValue(v any) (any, error) {
+	switch vv := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(vv))
+		for key, value := range vv {
+			var err error
+			m[key], err = encodeCheckpointValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return m, nil
+
+	case []any:
+		s := make([]any, len(vv))
+		for i, value := range vv {
+			var err error
+			s[i], err = encodeCheckpointValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		return s, nil
+
+	case float64:
+		if math.IsNaN(vv) || math.IsInf(vv, 0) {
+			return nil, fmt.Errorf("unsupported float: %v", vv)
+		}
+		s := strconv.FormatFloat(vv, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return json.Number(s), nil
+
+	case nil, bool, int64, string:
+		return v, nil
+
+	case int:
+		return int64(vv), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported value: %T", v)
+	}
+}
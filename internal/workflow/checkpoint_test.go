@@ -0,0 +1,223 @@
+package workflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+const checkpointWorkflow = `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - total: 0
+          - ratio: 1.5
+    - loop:
+        for:
+          value: n
+          in: ${args.numbers}
+          steps:
+            - add:
+                assign:
+                  - total: ${total + n}
+    - double:
+        assign:
+          - total: ${total * 2}
+    - done:
+        return:
+          total: ${total}
+          ratio: ${ratio}
+`
+
+func TestCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(checkpointWorkflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		args     map[string]any
+		expected []*workflow.Checkpoint
+	}{
+		{
+			name: "numbers",
+			args: map[string]any{"numbers": []any{int64(1), int64(2)}},
+			expected: []*workflow.Checkpoint{
+				{Step: "loop", Variables: map[string]any{"args": map[string]any{"numbers": []any{int64(1), int64(2)}}, "total": int64(0), "ratio": 1.5}},
+				{Step: "double", Variables: map[string]any{"args": map[string]any{"numbers": []any{int64(1), int64(2)}}, "total": int64(3), "ratio": 1.5}},
+				{Step: "done", Variables: map[string]any{"args": map[string]any{"numbers": []any{int64(1), int64(2)}}, "total": int64(6), "ratio": 1.5}},
+			},
+		},
+		{
+			name: "empty",
+			args: map[string]any{"numbers": []any{}},
+			expected: []*workflow.Checkpoint{
+				{Step: "loop", Variables: map[string]any{"args": map[string]any{"numbers": []any{}}, "total": int64(0), "ratio": 1.5}},
+				{Step: "double", Variables: map[string]any{"args": map[string]any{"numbers": []any{}}, "total": int64(0), "ratio": 1.5}},
+				{Step: "done", Variables: map[string]any{"args": map[string]any{"numbers": []any{}}, "total": int64(0), "ratio": 1.5}},
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var checkpoints []*workflow.Checkpoint
+			_, err := root.Execute(context.Background(), tt.args, workflow.WithCheckpoint(func(checkpoint *workflow.Checkpoint) {
+				// round-trip the checkpoint like the file of --checkpoint
+				b, err := json.Marshal(checkpoint)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var decoded workflow.Checkpoint
+				if err := json.Unmarshal(b, &decoded); err != nil {
+					t.Fatal(err)
+				}
+				checkpoints = append(checkpoints, &decoded)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, checkpoints); diff != "" {
+				t.Errorf("unexpected checkpoints (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResumeFrom(t *testing.T) {
+	t.Parallel()
+
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(checkpointWorkflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		checkpoint string
+		expected   any
+		wantErr    bool
+	}{
+		{
+			name:       "from the loop",
+			checkpoint: `{"step":"loop","variables":{"args":{"numbers":[1,2,3]},"total":10,"ratio":1.5}}`,
+			expected:   map[string]any{"total": int64(32), "ratio": 1.5},
+		},
+		{
+			name:       "from the last step",
+			checkpoint: `{"step":"done","variables":{"total":1,"ratio":2.0}}`,
+			expected:   map[string]any{"total": int64(1), "ratio": 2.0},
+		},
+		{
+			name:       "unknown step",
+			checkpoint: `{"step":"unknown","variables":{}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "nested step",
+			checkpoint: `{"step":"add","variables":{"total":1,"ratio":1.5}}`,
+			wantErr:    true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var checkpoint workflow.Checkpoint
+			if err := json.Unmarshal([]byte(tt.checkpoint), &checkpoint); err != nil {
+				t.Fatal(err)
+			}
+
+			// the arguments are ignored
+			ret, err := root.Execute(context.Background(), map[string]any{"numbers": []any{int64(100)}}, workflow.ResumeFrom(&checkpoint))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckpointJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name       string
+		checkpoint *workflow.Checkpoint
+		expected   string
+		wantErr    bool
+	}{
+		{
+			name: "values",
+			checkpoint: &workflow.Checkpoint{Step: "next", Variables: map[string]any{
+				"int":    int64(1),
+				"float":  2.0,
+				"big":    1e21,
+				"string": "s",
+				"bool":   true,
+				"null":   nil,
+				"list":   []any{int64(1), 1.5},
+				"map":    map[string]any{"f": 0.0},
+			}},
+			expected: `{"step":"next","variables":{"big":1e+21,"bool":true,"float":2.0,"int":1,"list":[1,1.5],"map":{"f":0.0},"null":null,"string":"s"}}`,
+		},
+		{
+			name:       "NaN",
+			checkpoint: &workflow.Checkpoint{Step: "next", Variables: map[string]any{"nan": math.NaN()}},
+			wantErr:    true,
+		},
+		{
+			name:       "unsupported value",
+			checkpoint: &workflow.Checkpoint{Step: "next", Variables: map[string]any{"f": func() {}}},
+			wantErr:    true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := json.Marshal(tt.checkpoint)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.expected {
+				t.Errorf("unexpected JSON: %s, want %s", b, tt.expected)
+			}
+
+			var decoded workflow.Checkpoint
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.checkpoint, &decoded); diff != "" {
+				t.Errorf("unexpected decoded checkpoint (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/goccy/go-json"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
@@ -38,14 +39,31 @@ func (d *workflowDef) compile(name string) (*Workflow, error) {
 		return nil, fmt.Errorf("empty steps")
 	}
 
+	params, err := compileParams(d.Params)
+	if err != nil {
+		return nil, err
+	}
 	wf := Workflow{
-		Name:    name,
-		Params:  make([]types.Argument, len(d.Params)),
-		stepMap: map[StepName]Step{},
+		Name:   name,
+		Params: params,
+	}
+
+	if err := checkStepNames(nil, d.Steps, newStepNameScope(false)); err != nil {
+		return nil, err
 	}
 
-	// parse params
-	for i, param := range d.Params {
+	// parse steps
+	wf.entryStep, wf.stepMap, err = compileSteps(d.Steps, "end")
+	if err != nil {
+		return nil, err
+	}
+
+	return &wf, nil
+}
+
+func compileParams(paramDefs []any) ([]types.Argument, error) {
+	params := make([]types.Argument, len(paramDefs))
+	for i, param := range paramDefs {
 		switch v := param.(type) {
 		case map[string]any:
 			if len(v) != 1 {
@@ -57,14 +75,14 @@ func (d *workflowDef) compile(name string) (*Workflow, error) {
 					return nil, fmt.Errorf("params[%d]: invalid number", i)
 				}
 
-				wf.Params[i] = types.Argument{
+				params[i] = types.Argument{
 					Name:    key,
 					Default: v,
 				}
 			}
 
 		case string:
-			wf.Params[i] = types.Argument{
+			params[i] = types.Argument{
 				Name: v,
 			}
 
@@ -72,32 +90,130 @@ func (d *workflowDef) compile(name string) (*Workflow, error) {
 			return nil, fmt.Errorf("params[%d]: invalid type", i)
 		}
 	}
+	return params, nil
+}
 
-	// parse steps
-	for i, stepDef := range d.Steps {
-		if _, duplicated := wf.stepMap[stepDef.name]; duplicated {
-			return nil, fmt.Errorf("%s: duplicated step name in steps", stepDef.name)
+// compileSteps compiles the steps and links each of them to the next one. The last step is linked to lastNextStepName.
+func compileSteps(stepDefs []*workflowStepDef, lastNextStepName StepName) (Step, map[StepName]Step, error) {
+	var entryStep Step
+	stepMap := make(map[StepName]Step, len(stepDefs))
+	for i, stepDef := range stepDefs {
+		if _, duplicated := stepMap[stepDef.name]; duplicated {
+			return nil, nil, fmt.Errorf("%s: duplicated step name in steps", stepDef.name)
 		}
 
 		var defaultNextStepName StepName
-		if i == len(d.Steps)-1 {
-			defaultNextStepName = "end"
+		if i == len(stepDefs)-1 {
+			defaultNextStepName = lastNextStepName
 		} else {
-			defaultNextStepName = d.Steps[i+1].name
+			defaultNextStepName = stepDefs[i+1].name
 		}
 
 		var err error
-		wf.stepMap[stepDef.name], err = stepDef.compile(defaultNextStepName)
+		stepMap[stepDef.name], err = stepDef.compile(defaultNextStepName)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", stepDef.name, err)
+			return nil, nil, fmt.Errorf("%s: %w", stepDef.name, err)
 		}
 
-		if wf.entryStep == nil {
-			wf.entryStep = wf.stepMap[stepDef.name]
+		if entryStep == nil {
+			entryStep = stepMap[stepDef.name]
 		}
 	}
+	return entryStep, stepMap, nil
+}
 
-	return &wf, nil
+// stepNameScope is the step names jumping to each other, which must be unique in the scope.
+// The steps nested in the steps (e.g. steps, try, except and switch) share the scope of the outer steps, and the steps of
+// for and parallel branches have their own scopes.
+type stepNameScope struct {
+	loop  bool // break and continue are reserved in the steps of for
+	paths map[StepName][]StepName
+}
+
+func newStepNameScope(loop bool) *stepNameScope {
+	return &stepNameScope{loop: loop, paths: map[StepName][]StepName{}}
+}
+
+// check checks the name of the last step of the path, and adds it to the scope.
+func (s *stepNameScope) check(path []StepName) error {
+	name := path[len(path)-1]
+	if name == "end" || (s.loop && (name == "break" || name == "continue")) {
+		return fmt.Errorf("cannot use the special step name %q", name)
+	}
+	if defined, ok := s.paths[name]; ok {
+		return fmt.Errorf("duplicated step name, also defined at %s", formatStepPath(defined))
+	}
+	s.paths[name] = path
+	return nil
+}
+
+func formatStepPath(path []StepName) string {
+	names := make([]string, len(path))
+	for i, name := range path {
+		names[i] = string(name)
+	}
+	return strings.Join(names, " > ")
+}
+
+// checkStepNames checks the names of the steps and the nested steps of them in the scope before the compilation to
+// report the path of the invalid name.
+func checkStepNames(path []StepName, steps []*workflowStepDef, scope *stepNameScope) error {
+	for _, step := range steps {
+		stepPath := append(path[:len(path):len(path)], step.name)
+		if err := scope.check(stepPath); err != nil {
+			return fmt.Errorf("%s: %w", formatStepPath(stepPath), err)
+		}
+		if err := checkNestedStepNames(stepPath, step.stepDef, scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkNestedStepNames(path []StepName, def anonymousStepDef, scope *stepNameScope) error {
+	nested := decodeNestedSteps(def)
+	if err := checkStepNames(path, decodeStepDefs(nested.Steps), scope); err != nil {
+		return err
+	}
+	if nested.Try != nil {
+		if err := checkNestedStepNames(path, nested.Try, scope); err != nil {
+			return err
+		}
+	}
+	if err := checkStepNames(path, decodeStepDefs(nested.Except.Steps), scope); err != nil {
+		return err
+	}
+	for _, condition := range nested.Switch {
+		if err := checkNestedStepNames(path, condition, scope); err != nil {
+			return err
+		}
+	}
+	if err := checkStepNames(path, decodeStepDefs(nested.For.Steps), newStepNameScope(true)); err != nil {
+		return err
+	}
+	if err := checkStepNames(path, decodeStepDefs(nested.Parallel.For.Steps), newStepNameScope(true)); err != nil {
+		return err
+	}
+	for _, branch := range nested.Parallel.Branches {
+		for name, branchDef := range branch {
+			if err := checkStepNames(append(path[:len(path):len(path)], name), decodeStepDefs(branchDef.Steps), newStepNameScope(false)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeStepDefs decodes the steps ignoring the malformed ones, which are reported by the compilation of them.
+func decodeStepDefs(stepsRaw []json.RawMessage) []*workflowStepDef {
+	steps := make([]*workflowStepDef, 0, len(stepsRaw))
+	for _, raw := range stepsRaw {
+		var step workflowStepDef
+		if err := json.Unmarshal(raw, &step); err == nil {
+			steps = append(steps, &step)
+		}
+	}
+	return steps
 }
 
 type workflowStepDef struct {
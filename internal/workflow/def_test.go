@@ -0,0 +1,194 @@
+package workflow_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestStepNames(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name          string
+		source        string
+		expectedError string // empty if the workflow is valid
+	}{
+		{
+			name: "unique names",
+			source: `
+main:
+  steps:
+    - a:
+        assign:
+          - x: 1
+    - b:
+        return: ${x}
+`,
+		},
+		{
+			name: "duplicated top-level names",
+			source: `
+main:
+  steps:
+    - a:
+        assign:
+          - x: 1
+    - a:
+        return: ${x}
+`,
+			expectedError: "main: a: duplicated step name, also defined at a",
+		},
+		{
+			name: "duplicated in switch",
+			source: `
+main:
+  steps:
+    - check:
+        switch:
+          - condition: ${true}
+            steps:
+              - done:
+                  return: 1
+    - done:
+        return: 2
+`,
+			expectedError: "main: done: duplicated step name, also defined at check > done",
+		},
+		{
+			name: "duplicated in try and except",
+			source: `
+main:
+  steps:
+    - guarded:
+        try:
+          steps:
+            - work:
+                return: 1
+        except:
+          as: e
+          steps:
+            - work:
+                return: 2
+`,
+			expectedError: "main: guarded > work: duplicated step name, also defined at guarded > work",
+		},
+		{
+			name: "same name in the scope of for",
+			source: `
+main:
+  steps:
+    - loop:
+        for:
+          value: v
+          in: [1]
+          steps:
+            - loop:
+                assign:
+                  - x: ${v}
+    - done:
+        return: ok
+`,
+		},
+		{
+			name: "duplicated in for",
+			source: `
+main:
+  steps:
+    - loop:
+        for:
+          value: v
+          in: [1]
+          steps:
+            - a:
+                assign:
+                  - x: ${v}
+            - a:
+                assign:
+                  - y: ${v}
+    - done:
+        return: ok
+`,
+			expectedError: "main: loop > a: duplicated step name, also defined at loop > a",
+		},
+		{
+			name: "same name in the parallel branches",
+			source: `
+main:
+  steps:
+    - fanout:
+        parallel:
+          branches:
+            - b1:
+                steps:
+                  - work:
+                      assign:
+                        - x: 1
+            - b2:
+                steps:
+                  - work:
+                      assign:
+                        - y: 1
+    - done:
+        return: ok
+`,
+		},
+		{
+			name: "reserved end",
+			source: `
+main:
+  steps:
+    - end:
+        return: ok
+`,
+			expectedError: `main: end: cannot use the special step name "end"`,
+		},
+		{
+			name: "reserved break in for",
+			source: `
+main:
+  steps:
+    - loop:
+        for:
+          value: v
+          in: [1]
+          steps:
+            - break:
+                assign:
+                  - x: ${v}
+    - done:
+        return: ok
+`,
+			expectedError: `main: loop > break: cannot use the special step name "break"`,
+		},
+		{
+			name: "continue outside for",
+			source: `
+main:
+  steps:
+    - continue:
+        return: ok
+`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("should be error")
+			}
+			if err.Error() != tt.expectedError {
+				t.Errorf("unexpected error: %q, want %q", err.Error(), tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package workflow
+
+import (
+	"github.com/goccy/go-yaml/ast"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// FindDefinition returns the position of the subworkflow of the name, or the step of the name in the routine at the
+// line (from 1) of the source of the workflow, e.g. to jump from the values of next and call to their definitions.
+// It returns nil if neither of them is found.
+func FindDefinition(source []byte, line int, name string) *Position {
+	l := newStepLocator(source)
+	if routine, ok := l.routines[name]; ok {
+		tk := routine.Key.GetToken()
+		return &Position{Line: tk.Position.Line, Column: tk.Position.Column, Length: len(tk.Value)}
+	}
+
+	// the routine at the line is the last one starting before it
+	routine := ""
+	for _, name := range l.routineNames {
+		if l.routines[name].Key.GetToken().Position.Line <= line {
+			routine = name
+		}
+	}
+	if routine == "" {
+		return nil
+	}
+	finder := &stepFinder{name: StepName(name)}
+	ast.Walk(finder, l.routines[routine].Value)
+	if finder.found == nil {
+		return nil
+	}
+	tk := finder.found.Key.GetToken()
+	return &Position{Line: tk.Position.Line, Column: tk.Position.Column, Length: len(tk.Value)}
+}
+
+// LookupFunction returns the function of the standard library by the dotted name, e.g. http.get.
+func LookupFunction(name string) (types.Function, bool) {
+	value, ok := lookupDefault(name)
+	if !ok {
+		return nil, false
+	}
+	f, ok := value.(types.Function)
+	return f, ok
+}
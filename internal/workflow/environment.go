@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+)
+
+// ExecutionInfo is the information of an execution exposed as the built-in environment variables.
+// refs. https://cloud.google.com/workflows/docs/reference/environment-variables
+type ExecutionInfo struct {
+	ProjectID     string
+	ProjectNumber string
+	Location      string
+	WorkflowID    string
+	RevisionID    string
+	ExecutionID   string
+}
+
+// WithExecutionInfo sets the information of the execution. Empty fields don't override the ones set before.
+func WithExecutionInfo(info ExecutionInfo) ExecuteOption {
+	return func(c *executeConfig) {
+		for name, value := range info.builtinEnv() {
+			if value != "" {
+				c.env[name] = value
+			}
+		}
+	}
+}
+
+// WithEnv sets the user-defined environment variables of the workflow.
+// They are looked up by sys.get_env before the environment variables of the process. Once any of them are set, only the
+// built-in ones (GOOGLE_CLOUD_*) fall back to the process like the production, which doesn't leak the host environment.
+func WithEnv(env map[string]string) ExecuteOption {
+	return func(c *executeConfig) {
+		for name, value := range env {
+			c.env[name] = value
+		}
+		if len(env) != 0 {
+			c.isolatedEnv = true
+		}
+	}
+}
+
+// ValidateEnvName checks that the name is usable as a user-defined environment variable.
+// refs. https://cloud.google.com/workflows/docs/use-environment-variables
+func ValidateEnvName(name string) error {
+	if name == "" {
+		return fmt.Errorf("environment variable name must not be empty")
+	}
+	for _, prefix := range []string{"GOOGLE", "WORKFLOWS"} {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("environment variable name %q must not start with %s", name, prefix)
+		}
+	}
+	return nil
+}
+
+func (info ExecutionInfo) builtinEnv() map[string]string {
+	return map[string]string{
+		"GOOGLE_CLOUD_PROJECT_ID":            info.ProjectID,
+		"GOOGLE_CLOUD_PROJECT_NUMBER":        info.ProjectNumber,
+		"GOOGLE_CLOUD_LOCATION":              info.Location,
+		"GOOGLE_CLOUD_WORKFLOW_ID":           info.WorkflowID,
+		"GOOGLE_CLOUD_WORKFLOW_REVISION_ID":  info.RevisionID,
+		"GOOGLE_CLOUD_WORKFLOW_EXECUTION_ID": info.ExecutionID,
+	}
+}
+
+// NewExecutionID generates a random execution ID formatted as UUID v4 by the random source of the context (see
+// defaults.WithRandom).
+func NewExecutionID(ctx context.Context) string {
+	return defaults.NewUUID(ctx)
+}
@@ -0,0 +1,149 @@
+package workflow_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestWithEnv(t *testing.T) {
+	t.Parallel()
+
+	const getEnvWorkflow = `
+main:
+  params: [args]
+  steps:
+    - get:
+        return: ${sys.get_env(args.name, "none")}
+`
+	const subworkflowGetEnvWorkflow = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: getEnv
+        args:
+          name: ${args.name}
+        result: value
+    - done:
+        return: ${value}
+getEnv:
+  params: [name]
+  steps:
+    - get:
+        return: ${sys.get_env(name, "none")}
+`
+	for _, tt := range []struct {
+		name     string
+		source   string
+		env      string
+		opts     []workflow.ExecuteOption
+		expected any
+	}{
+		{
+			name:     "environment of the process without the user-defined ones",
+			source:   getEnvWorkflow,
+			env:      "PATH",
+			expected: os.Getenv("PATH"),
+		},
+		{
+			name:     "environment of the process with the empty user-defined ones",
+			source:   getEnvWorkflow,
+			env:      "PATH",
+			opts:     []workflow.ExecuteOption{workflow.WithEnv(map[string]string{})},
+			expected: os.Getenv("PATH"),
+		},
+		{
+			name:     "user-defined one",
+			source:   getEnvWorkflow,
+			env:      "FOO",
+			opts:     []workflow.ExecuteOption{workflow.WithEnv(map[string]string{"FOO": "bar"})},
+			expected: "bar",
+		},
+		{
+			name:     "user-defined one overridden by the later option",
+			source:   getEnvWorkflow,
+			env:      "FOO",
+			opts:     []workflow.ExecuteOption{workflow.WithEnv(map[string]string{"FOO": "bar"}), workflow.WithEnv(map[string]string{"FOO": "baz"})},
+			expected: "baz",
+		},
+		{
+			name:     "environment of the process isolated by the user-defined ones",
+			source:   getEnvWorkflow,
+			env:      "PATH",
+			opts:     []workflow.ExecuteOption{workflow.WithEnv(map[string]string{"FOO": "bar"})},
+			expected: "none",
+		},
+		{
+			name:     "environment of the process isolated in the subworkflows",
+			source:   subworkflowGetEnvWorkflow,
+			env:      "PATH",
+			opts:     []workflow.ExecuteOption{workflow.WithEnv(map[string]string{"FOO": "bar"})},
+			expected: "none",
+		},
+		{
+			name:   "built-in one with the user-defined ones",
+			source: getEnvWorkflow,
+			env:    "GOOGLE_CLOUD_PROJECT_ID",
+			opts: []workflow.ExecuteOption{
+				workflow.WithExecutionInfo(workflow.ExecutionInfo{ProjectID: "my-project"}),
+				workflow.WithEnv(map[string]string{"FOO": "bar"}),
+			},
+			expected: "my-project",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := root.Execute(context.Background(), map[string]any{"name": tt.env}, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateEnvName(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "FOO"},
+		{name: "MY_GOOGLE"},
+		{name: "", wantErr: true},
+		{name: "GOOGLE_CLOUD_PROJECT_ID", wantErr: true},
+		{name: "WORKFLOWS_FOO", wantErr: true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := workflow.ValidateEnvName(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
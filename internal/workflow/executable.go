@@ -1,54 +1,383 @@
 package workflow
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/expression"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/tracing"
 	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
 	"github.com/mitchellh/mapstructure"
 	"github.com/samber/lo"
-	"golang.org/x/sync/errgroup"
 )
 
 type WorkflowRoot map[string]*Workflow
 
-func (r WorkflowRoot) Execute(args any) (any, error) {
+// ExecuteOption configures an execution of the workflow.
+type ExecuteOption func(*executeConfig)
+
+type executeConfig struct {
+	serializeParallel bool
+	env               map[string]string
+	isolatedEnv       bool // the user-defined environment variables are configured
+	globals           *types.SymbolTable
+	symbols           map[string]any
+	workflows         *types.SymbolTable
+	callbacks         defaults.CallbackRegistry
+	logRecorder       defaults.LogRecorder
+	callLogLevel      string
+	stepGate          *StepGate
+	saveCheckpoint    func(*Checkpoint)
+	resume            *Checkpoint
+	tracer            *tracing.Tracer
+	trace             *Trace
+	stepTracer        *stepTracer
+	variableLogger    *variableLogger
+	journal           *Journal
+	hooks             []Hooks
+	newClock          func() defaults.Clock
+	newRandom         func() io.Reader
+	httpClient        *http.Client
+	limits            Limits
+	strictArgs        bool
+	resolveWorkflow   WorkflowResolver
+	steps             *int64 // the number of the executed steps shared with the child executions, see countStep
+}
+
+// SerializeParallel makes parallel steps execute their branches and iterations sequentially in the declaration order.
+func SerializeParallel(enabled bool) ExecuteOption {
+	return func(c *executeConfig) {
+		c.serializeParallel = enabled
+	}
+}
+
+// WithCallbackRegistry makes events.create_callback_endpoint host the callback endpoints by the registry.
+func WithCallbackRegistry(registry defaults.CallbackRegistry) ExecuteOption {
+	return func(c *executeConfig) {
+		c.callbacks = registry
+	}
+}
+
+// WithLogRecorder makes the recorder capture the logs of sys.log and the call logging.
+func WithLogRecorder(recorder defaults.LogRecorder) ExecuteOption {
+	return func(c *executeConfig) {
+		c.logRecorder = recorder
+	}
+}
+
+// WithCallLogLevel enables the call logging in the level (LOG_ALL_CALLS, LOG_ERRORS_ONLY or LOG_NONE).
+// The calls are not logged in the other levels, as CALL_LOG_LEVEL_UNSPECIFIED.
+// refs. https://cloud.google.com/workflows/docs/log-workflow#call-logging
+func WithCallLogLevel(callLogLevel string) ExecuteOption {
+	return func(c *executeConfig) {
+		c.callLogLevel = callLogLevel
+	}
+}
+
+// StepObserver observes the progress of the steps of an execution, e.g. to stream it.
+// The methods are called concurrently by the branches of the parallel steps.
+type StepObserver interface {
+	StepStarted(step StepName)
+	StepFinished(step StepName, err error)
+}
+
+// WithStepObserver makes the observer observe the starts and the finishes of the steps including the nested ones.
+func WithStepObserver(observer StepObserver) ExecuteOption {
+	return func(c *executeConfig) {
+		c.hooks = append(c.hooks, stepObserverHooks{observer: observer})
+	}
+}
+
+// WithClock makes each execution use the clock returned by newClock instead of the real time, e.g. a fake clock for
+// the deterministic executions.
+func WithClock(newClock func() defaults.Clock) ExecuteOption {
+	return func(c *executeConfig) {
+		c.newClock = newClock
+	}
+}
+
+// WithRandom makes each execution read the random bytes of uuid.generate and the execution IDs from the random source
+// returned by newRandom instead of crypto/rand, e.g. a seeded one for the reproducible IDs.
+func WithRandom(newRandom func() io.Reader) ExecuteOption {
+	return func(c *executeConfig) {
+		c.newRandom = newRandom
+	}
+}
+
+// WithHTTPClient makes the execution send the outbound HTTP requests of http.* and the connectors by the client instead
+// of the transport of the process, see defaults.WithHTTPClient.
+func WithHTTPClient(client *http.Client) ExecuteOption {
+	return func(c *executeConfig) {
+		c.httpClient = client
+	}
+}
+
+// WithTracer makes the execution traced by the tracer. The execution is the root span, and the steps are the child spans of it.
+func WithTracer(tracer *tracing.Tracer) ExecuteOption {
+	return func(c *executeConfig) {
+		c.tracer = tracer
+	}
+}
+
+// logCall writes the call logging of the level in the same format as sys.log, and records it if the recorder is given.
+// The entries of LOG_ERRORS_ONLY are also logged in LOG_ALL_CALLS.
+func (c *executeConfig) logCall(level string, severity string, payload map[string]any) {
+	switch {
+	case c.callLogLevel == "LOG_ALL_CALLS":
+	case c.callLogLevel == "LOG_ERRORS_ONLY" && level == "LOG_ERRORS_ONLY":
+	default:
+		return
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to log the call", "error", err)
+		return
+	}
+	log.Printf(`{"severity":%q,"jsonPayload":%s}`, severity, string(b))
+	if c.logRecorder != nil {
+		c.logRecorder.RecordLog(&defaults.LogEntry{Timestamp: time.Now().UTC(), Severity: severity, JSONPayload: payload})
+	}
+}
+
+// tracksStepPath reports whether the contexts of the steps have their routines and paths, see withStepPath.
+func (c *executeConfig) tracksStepPath() bool {
+	return c.stepTracer != nil || c.variableLogger != nil || c.journal != nil || len(c.hooks) != 0
+}
+
+func getExecuteConfig(st *types.SymbolTable) *executeConfig {
+	if v, ok := st.Get(types.InternalExecuteConfigSymbol); ok {
+		return v.(*executeConfig)
+	}
+	return &executeConfig{}
+}
+
+// globalSymbolTable returns the symbol table of the standard library (and the stubs and the registered symbols).
+func (c *executeConfig) globalSymbolTable() *types.SymbolTable {
+	if c.globals != nil {
+		return c.globals
+	}
+	return defaults.DefaultSymbolTable
+}
+
+// workflowSymbolTable returns the symbol table of the subworkflows of the execution as the parent of the workflows.
+func (c *executeConfig) workflowSymbolTable() *types.SymbolTable {
+	if c.workflows != nil {
+		return c.workflows
+	}
+	return c.globalSymbolTable()
+}
+
+// Execute executes the main workflow. The execution is aborted with the error of the context when the context is done.
+func (r WorkflowRoot) Execute(ctx context.Context, args any, opts ...ExecuteOption) (any, error) {
+	config := &executeConfig{
+		env:   map[string]string{},
+		steps: new(int64),
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if len(config.symbols) != 0 {
+		globals, err := overlaySymbols(config.globalSymbolTable(), config.symbols)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbol %w", err)
+		}
+		config.globals = globals
+	}
+	return r.execute(ctx, args, config)
+}
+
+func (r WorkflowRoot) execute(ctx context.Context, args any, config *executeConfig) (ret any, err error) {
 	mainWorkflow, ok := r["main"]
 	if !ok {
 		return nil, fmt.Errorf("main workflow is not defined")
 	}
 
-	st := &types.SymbolTable{
-		Symbols: map[string]any{},
-		Parent:  defaults.DefaultSymbolTable,
+	// the child executions (e.g. experimental.executions.map) share the clock of the parent
+	if config.newClock != nil && defaults.ClockFromContext(ctx) == nil {
+		ctx = defaults.WithClock(ctx, config.newClock())
+	}
+	if config.newRandom != nil && defaults.RandomFromContext(ctx) == nil {
+		ctx = defaults.WithRandom(ctx, config.newRandom())
+	}
+	if config.httpClient != nil {
+		ctx = defaults.WithHTTPClient(ctx, config.httpClient)
+	}
+	if _, ok := config.env["GOOGLE_CLOUD_WORKFLOW_EXECUTION_ID"]; !ok {
+		config.env["GOOGLE_CLOUD_WORKFLOW_EXECUTION_ID"] = NewExecutionID(ctx)
+	}
+
+	// the child executions (e.g. experimental.executions.map) are traced in the trace of the parent
+	var span *tracing.Span
+	if tracing.FromContext(ctx) != nil {
+		ctx, span = tracing.Start(ctx, "execution")
+	} else if config.tracer != nil {
+		ctx, span = config.tracer.Start(ctx, "execution")
+	}
+	if journal := config.journal; journal != nil {
+		ctx = expression.WithObserver(context.WithValue(ctx, journalKey{}, journal), journal.observeExpression)
+		journal.write(ctx, &JournalEntry{Type: "execution", Args: args})
+		defer func() {
+			entry := &JournalEntry{Type: "result", Result: ret}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			journal.write(ctx, entry)
+		}()
+	}
+	if config.journal != nil || len(config.hooks) != 0 {
+		defer func() {
+			var exception types.Exception
+			if errors.As(err, &exception) {
+				config.onException(ctx, exception, "uncaught")
+			}
+		}()
+	}
+	if span != nil {
+		span.SetAttribute("workflows.workflow_id", config.env["GOOGLE_CLOUD_WORKFLOW_ID"])
+		span.SetAttribute("workflows.execution_id", config.env["GOOGLE_CLOUD_WORKFLOW_EXECUTION_ID"])
+		defer func() {
+			span.End(err)
+		}()
+	}
+
+	symbols := map[string]any{
+		"experimental": map[string]any{
+			"executions": map[string]any{
+				"map": &executionsMapFunction{root: r},
+			},
+		},
 	}
 	for name, workflow := range r {
 		if name == "main" {
 			continue
 		}
 
-		name := name
-		workflow := workflow
-		st.Symbols[name] = types.NewRawFunction(name, workflow.Params, func(args []any) (any, error) {
-			st := &types.SymbolTable{
-				Symbols: map[string]any{},
-				Parent:  defaults.DefaultSymbolTable,
-			}
-			for i, param := range workflow.Params {
-				st.Symbols[param.Name] = args[i]
-			}
-			return workflow.Execute(st)
-		})
+		symbols[name] = &subworkflowFunction{workflow: workflow}
+	}
+	config.workflows = &types.SymbolTable{
+		Symbols:  symbols,
+		ReadOnly: true,
+		Parent:   config.globalSymbolTable(),
 	}
 
+	st := &types.SymbolTable{
+		Symbols: map[string]any{
+			types.InternalExecuteConfigSymbol: config,
+			types.InternalEnvironmentSymbol:   config.env,
+		},
+		Parent: config.workflows,
+	}
+	if config.callbacks != nil {
+		st.Symbols[types.InternalCallbackRegistrySymbol] = config.callbacks
+	}
+	if config.isolatedEnv {
+		st.Symbols[types.InternalIsolatedEnvironmentSymbol] = true
+	}
+	if config.logRecorder != nil {
+		st.Symbols[types.InternalLogRecorderSymbol] = config.logRecorder
+	}
+	if config.resume != nil {
+		step, ok := mainWorkflow.stepMap[config.resume.Step]
+		if !ok {
+			return nil, fmt.Errorf("step %q of the checkpoint is not found in the main workflow", config.resume.Step)
+		}
+		for name, value := range config.resume.Variables {
+			st.Symbols[name] = value
+		}
+		return mainWorkflow.executeSteps(ctx, st, step, config.saveCheckpoint)
+	}
 	if len(mainWorkflow.Params) == 1 {
 		st.Symbols[mainWorkflow.Params[0].Name] = args
 	}
-	return mainWorkflow.Execute(st)
+	if err := mainWorkflow.bindParams(st); err != nil {
+		return nil, err
+	}
+	return mainWorkflow.executeSteps(ctx, st, mainWorkflow.entryStep, config.saveCheckpoint)
+}
+
+// runConcurrently calls f with 0 to n-1 concurrently and waits for them. The goroutines are tracked for the clock of
+// the context (see defaults.TrackGoroutines), and the last finished one takes over the waiting goroutine.
+func runConcurrently(ctx context.Context, n int, f func(i int)) {
+	if n == 0 {
+		return
+	}
+
+	defaults.TrackGoroutines(ctx, n-1)
+	remaining := int64(n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if atomic.AddInt64(&remaining, -1) != 0 {
+					defaults.TrackGoroutines(ctx, -1)
+				}
+			}()
+			f(i)
+		}()
+	}
+	wg.Wait()
+}
+
+type subworkflowFunction struct {
+	workflow *Workflow
+}
+
+var _ types.ScopedFunction = (*subworkflowFunction)(nil)
+
+func (f *subworkflowFunction) Name() string {
+	return f.workflow.Name
+}
+
+func (f *subworkflowFunction) Args() []string {
+	return lo.Map(f.workflow.Params, func(param types.Argument, _ int) string {
+		return param.Name
+	})
+}
+
+func (f *subworkflowFunction) Call(ctx context.Context, args []any) (any, error) {
+	return f.CallInScope(ctx, nil, args)
+}
+
+func (f *subworkflowFunction) CallInScope(ctx context.Context, caller *types.SymbolTable, args []any) (any, error) {
+	return types.NewRawFunction(f.workflow.Name, f.workflow.Params, func(ctx context.Context, args []any) (any, error) {
+		st := &types.SymbolTable{
+			Symbols: map[string]any{},
+			Parent:  defaults.DefaultSymbolTable,
+		}
+		if caller != nil {
+			config := getExecuteConfig(caller)
+			var err error
+			if ctx, err = config.enterCall(ctx); err != nil {
+				return nil, err
+			}
+			st.Parent = config.workflowSymbolTable()
+			for _, sym := range types.InternalScopedSymbols {
+				if v, ok := caller.Get(sym); ok {
+					st.Symbols[sym] = v
+				}
+			}
+		}
+		for i, param := range f.workflow.Params {
+			st.Symbols[param.Name] = args[i]
+		}
+		return f.workflow.Execute(ctx, st)
+	}).Call(ctx, args)
 }
 
 type Workflow struct {
@@ -57,9 +386,17 @@ type Workflow struct {
 
 	entryStep Step
 	stepMap   map[StepName]Step
+	positions map[StepName]*Position // in the source
 }
 
-func (w *Workflow) Execute(symbolTable *types.SymbolTable) (ret any, err error) {
+func (w *Workflow) Execute(ctx context.Context, symbolTable *types.SymbolTable) (any, error) {
+	if err := w.bindParams(symbolTable); err != nil {
+		return nil, err
+	}
+	return w.executeSteps(ctx, symbolTable, w.entryStep, nil)
+}
+
+func (w *Workflow) bindParams(symbolTable *types.SymbolTable) error {
 	for _, param := range w.Params {
 		if _, ok := symbolTable.Symbols[param.Name]; ok {
 			continue
@@ -70,16 +407,26 @@ func (w *Workflow) Execute(symbolTable *types.SymbolTable) (ret any, err error)
 		if param.Default != nil {
 			symbolTable.Symbols[param.Name] = param.Default
 		}
-		return nil, fmt.Errorf("missing param: %s", param.Name)
+		return fmt.Errorf("missing param: %s", param.Name)
 	}
+	return nil
+}
 
+// executeSteps executes the steps from the step. The checkpoint is saved at every step boundary if saveCheckpoint is given.
+func (w *Workflow) executeSteps(ctx context.Context, symbolTable *types.SymbolTable, step Step, saveCheckpoint func(*Checkpoint)) (ret any, err error) {
 	ev := expression.Evaluator{SymbolTable: symbolTable}
-	step := w.entryStep
+	if getExecuteConfig(symbolTable).tracksStepPath() {
+		ctx = withStepRoutine(ctx, w.Name)
+	}
 	for step != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", step.Name(), err)
+		}
+
 		var nextStepName StepName
-		ret, nextStepName, err = step.Execute(&ev)
+		ret, nextStepName, err = step.Execute(ctx, &ev)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", step.Name(), err)
+			return nil, &stepError{workflow: w, step: step.Name(), err: err}
 		}
 		if nextStepName == "end" {
 			return ret, nil
@@ -91,16 +438,50 @@ func (w *Workflow) Execute(symbolTable *types.SymbolTable) (ret any, err error)
 		if !ok {
 			return nil, fmt.Errorf("%s: not found", nextStepName)
 		}
+		if saveCheckpoint != nil {
+			saveCheckpoint(&Checkpoint{Step: nextStepName, Variables: userVariables(symbolTable)})
+		}
 
 		step = nextStep
 	}
 	return
 }
 
+// userVariables returns the variables of the scope except for the internal symbols.
+func userVariables(symbolTable *types.SymbolTable) map[string]any {
+	variables := make(map[string]any, len(symbolTable.Symbols))
+	for name, value := range symbolTable.Symbols {
+		if !strings.HasPrefix(name, "__INTERNAL_") {
+			variables[name] = value
+		}
+	}
+	return variables
+}
+
+// visibleVariables returns the variables visible from the scope, except for the read-only scopes of the standard
+// library and the subworkflows.
+func visibleVariables(symbolTable *types.SymbolTable) map[string]any {
+	variables := map[string]any{}
+	for st := symbolTable; st != nil && !st.ReadOnly; st = st.Parent {
+		for name, value := range userVariables(st) {
+			if _, shadowed := variables[name]; shadowed {
+				continue
+			}
+			if shared, ok := value.(*types.SharedVariable); ok {
+				shared.RLock()
+				value = shared.Value
+				shared.RUnlock()
+			}
+			variables[name] = value
+		}
+	}
+	return variables
+}
+
 type StepName string
 
 type AnonymousStep interface {
-	Execute(*expression.Evaluator) (any, StepName, error)
+	Execute(context.Context, *expression.Evaluator) (any, StepName, error)
 }
 
 type Step interface {
@@ -118,11 +499,59 @@ func (s *namedStep) Name() StepName {
 	return s.name
 }
 
-func (s *namedStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
-	ret, next, err := s.step.Execute(ev)
+func (s *namedStep) Execute(ctx context.Context, ev *expression.Evaluator) (ret any, next StepName, err error) {
+	config := getExecuteConfig(ev.SymbolTable)
+	if config.stepGate != nil {
+		if err := config.stepGate.wait(ctx); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+	if err := config.countStep(); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", s.name, err)
+	}
+	ctx, span := tracing.Start(ctx, string(s.name))
+	defer func() {
+		span.End(err)
+	}()
+
+	config.trace.record(map[string]any{"step": s.name})
+	if config.tracksStepPath() {
+		ctx = withStepPath(ctx, s.name)
+	}
+	if len(config.hooks) != 0 {
+		state := &StepState{Routine: stepRoutineFromContext(ctx), Path: stepPathFromContext(ctx), Step: s.name, symbolTable: ev.SymbolTable}
+		after, hookErr := config.beforeStep(ctx, state)
+		if hookErr != nil {
+			return nil, "", fmt.Errorf("%s: %w", s.name, hookErr)
+		}
+		defer func() {
+			after(next, err)
+		}()
+	}
+	if journal := config.journal; journal != nil {
+		journal.write(ctx, &JournalEntry{Type: "step"})
+		defer func() {
+			entry := &JournalEntry{Type: "stepFinished", Next: next}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			journal.write(ctx, entry)
+		}()
+	}
+	if tracer := config.stepTracer; tracer != nil {
+		finish := tracer.start(ctx, s.name, ev.SymbolTable)
+		defer func() {
+			finish(next, err)
+		}()
+	}
+
+	ret, next, err = s.step.Execute(ctx, ev)
 	if err != nil {
 		return nil, "", err
 	}
+	if err := config.checkVariablesSize(ev.SymbolTable); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", s.name, err)
+	}
 
 	if next == "" {
 		next = s.next
@@ -177,7 +606,7 @@ func newAssignStep(def anonymousStepDef) (*assignStep, error) {
 	}, nil
 }
 
-func (s *assignStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
+func (s *assignStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
 	var inheritedVariables *types.InternalInheritedVariables
 	if v, ok := ev.SymbolTable.Get(types.InternalInheritedVariablesSymbol); ok {
 		inheritedVariables = v.(*types.InternalInheritedVariables)
@@ -186,15 +615,16 @@ func (s *assignStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
 			return assign.left
 		})
 
-		unlock, err := ev.LockSharedVariablesIfNeeded(exprs...)
+		unlock, err := ev.LockSharedVariablesIfNeeded(ctx, exprs...)
 		if err != nil {
 			return nil, "", fmt.Errorf("LockSharedVariablesIfNeeded: %w", err)
 		}
 		defer unlock()
 	}
 
+	config := getExecuteConfig(ev.SymbolTable)
 	for i, assign := range s.assigns {
-		ref, err := ev.ResolveReference(assign.left)
+		ref, err := ev.ResolveReference(ctx, assign.left)
 		if err != nil {
 			return nil, "", fmt.Errorf("invalid assign[%d]: %w", i, err)
 		}
@@ -204,23 +634,28 @@ func (s *assignStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
 		}
 		if inheritedVariables != nil {
 			rootSym, _ := variable.Paths()
-			if _, inherited := inheritedVariables.Shared[rootSym]; inherited {
+			if shared, inherited := inheritedVariables.Shared[rootSym]; inherited && !shared {
 				return nil, "", fmt.Errorf("invalid assign[%d]: cannot assign to non-shared variable in parallel step", i)
 			}
 		}
 
-		value, err := ev.EvaluateValueRecursive(assign.right)
+		value, err := ev.EvaluateValueRecursive(ctx, assign.right)
 		if err != nil {
 			return nil, "", fmt.Errorf("invalid assign[%d]: %w", i, err)
 		}
 		variable.Set(value)
+		config.trace.record(map[string]any{"assign": map[string]any{assign.left.Source: value}})
+		if logger := config.variableLogger; logger != nil {
+			rootSym, _ := variable.Paths()
+			logger.log(ctx, ev.SymbolTable, rootSym, "")
+		}
 	}
 	return nil, "", nil
 }
 
 type nopStep struct{}
 
-func (nopStep) Execute(*expression.Evaluator) (any, StepName, error) {
+func (nopStep) Execute(context.Context, *expression.Evaluator) (any, StepName, error) {
 	return nil, "", nil
 }
 
@@ -229,8 +664,8 @@ type nextStep struct {
 	next StepName
 }
 
-func (s *nextStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
-	ret, _, err := s.step.Execute(ev)
+func (s *nextStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
+	ret, _, err := s.step.Execute(ctx, ev)
 	if err != nil {
 		return nil, "", err
 	}
@@ -259,8 +694,8 @@ func newReturnStep(def anonymousStepDef) (*returnStep, error) {
 	}, nil
 }
 
-func (s *returnStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
-	ret, err := ev.EvaluateValueRecursive(s.returnValue)
+func (s *returnStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
+	ret, err := ev.EvaluateValueRecursive(ctx, s.returnValue)
 	if err != nil {
 		return nil, "", fmt.Errorf("%s: %w", s.returnValue, err)
 	}
@@ -301,20 +736,20 @@ func newRaiseStep(def anonymousStepDef) (*raiseStep, error) {
 	}, nil
 }
 
-func (s *raiseStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
+func (s *raiseStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
 	if expr, ok := s.raiseValue.(*expression.Expr); ok {
-		ret, err := ev.EvaluateValue(expr)
+		ret, err := ev.EvaluateValue(ctx, expr)
 		if err != nil {
 			return nil, "", fmt.Errorf("invalid raise: %w", err)
 		}
 
-		return s.raise(ev, ret)
+		return s.raise(ctx, ev, ret)
 	}
 
-	return s.raise(ev, s.raiseValue)
+	return s.raise(ctx, ev, s.raiseValue)
 }
 
-func (s *raiseStep) raise(ev *expression.Evaluator, value any) (any, StepName, error) {
+func (s *raiseStep) raise(ctx context.Context, ev *expression.Evaluator, value any) (any, StepName, error) {
 	switch v := value.(type) {
 	case types.Exception:
 		return nil, "", v
@@ -334,38 +769,50 @@ func (s *raiseStep) raise(ev *expression.Evaluator, value any) (any, StepName, e
 }
 
 type anonymousStepsStep struct {
-	steps []AnonymousStep
+	entryStep Step
+	stepMap   map[StepName]Step
 }
 
 func newAnonymousStepsStep(def anonymousStepDef) (*anonymousStepsStep, error) {
-	var stepsDef []anonymousStepDef
-	err := json.Unmarshal(def["steps"], stepsDef)
+	var stepDefs []*workflowStepDef
+	err := json.Unmarshal(def["steps"], &stepDefs)
 	if err != nil {
 		return nil, fmt.Errorf("invalid steps: %w", err)
 	}
+	if len(stepDefs) == 0 {
+		return nil, fmt.Errorf("invalid steps: empty steps")
+	}
 
-	steps := make([]AnonymousStep, len(stepsDef))
-	for i, stepDef := range stepsDef {
-		steps[i], err = stepDef.compile()
-		if err != nil {
-			return nil, fmt.Errorf("invalid steps[%d]: %w", i, err)
-		}
+	// the last step falls through to the step next to the outer step
+	entryStep, stepMap, err := compileSteps(stepDefs, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid steps: %w", err)
 	}
 
 	return &anonymousStepsStep{
-		steps: steps,
+		entryStep: entryStep,
+		stepMap:   stepMap,
 	}, nil
 }
 
-func (s *anonymousStepsStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
-	for i, step := range s.steps {
-		ret, nextStep, err := step.Execute(ev)
+func (s *anonymousStepsStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
+	step := s.entryStep
+	for step != nil {
+		ret, nextStepName, err := step.Execute(ctx, ev)
 		if err != nil {
-			return nil, "", fmt.Errorf("invalid condition[%d]: %w", i, err)
+			return nil, "", &stepError{step: step.Name(), err: err}
+		}
+		if nextStepName == "" {
+			return ret, "", nil
 		}
-		if nextStep != "" {
-			return ret, nextStep, nil
+
+		nextStep, ok := s.stepMap[nextStepName]
+		if !ok {
+			// jump to outside of the steps (e.g. end, break, continue or the outer steps)
+			return ret, nextStepName, nil
 		}
+
+		step = nextStep
 	}
 	return nil, "", nil
 }
@@ -440,16 +887,8 @@ func newCallStep(def anonymousStepDef) (*callStep, error) {
 	}, nil
 }
 
-func (s *callStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
-	if _, ok := ev.SymbolTable.Get(types.InternalInheritedVariablesSymbol); ok && s.result != nil {
-		unlock, err := ev.LockSharedVariablesIfNeeded(s.result)
-		if err != nil {
-			return nil, "", fmt.Errorf("LockSharedVariablesIfNeeded: %w", err)
-		}
-		defer unlock()
-	}
-
-	callRef, err := ev.ResolveReference(s.call)
+func (s *callStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
+	callRef, err := ev.ResolveReference(ctx, s.call)
 	if err != nil {
 		return nil, "", fmt.Errorf("unknown call %q: %w", s.call.Source, err)
 	}
@@ -463,7 +902,7 @@ func (s *callStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
 		return nil, "", fmt.Errorf("not a callable function: %s", s.call.Source)
 	}
 
-	argsRaw, err := ev.EvaluateValueRecursive(s.args)
+	argsRaw, err := ev.EvaluateValueRecursive(ctx, s.args)
 	if err != nil {
 		return nil, "", fmt.Errorf("invalid args: %w", err)
 	}
@@ -473,6 +912,9 @@ func (s *callStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
 	case []any:
 		args = v
 	case map[string]any:
+		if err := getExecuteConfig(ev.SymbolTable).checkArgs(f, v); err != nil {
+			return nil, "", fmt.Errorf("call %q: %w", s.call.Source, err)
+		}
 		args = lo.Map(f.Args(), func(key string, _ int) any {
 			value, ok := v[key]
 			if ok {
@@ -484,25 +926,66 @@ func (s *callStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
 		panic(fmt.Sprintf("invalid args value: %T %+v", v, v))
 	}
 
-	var variable expression.Variable
+	tracing.FromContext(ctx).SetAttribute("workflows.call", f.Name())
+
+	config := getExecuteConfig(ev.SymbolTable)
+	config.logCall("LOG_ALL_CALLS", "INFO", map[string]any{
+		"callStarted": map[string]any{"function": f.Name(), "args": argsRaw},
+	})
+
+	var ret any
+	start := time.Now()
+	if sf, ok := f.(types.ScopedFunction); ok {
+		ret, err = sf.CallInScope(ctx, ev.SymbolTable, args)
+	} else {
+		ret, err = f.Call(ctx, args)
+	}
+	if len(config.hooks) != 0 {
+		config.onCall(ctx, &CallEvent{
+			Function:  f.Name(),
+			Args:      argsRaw,
+			Result:    ret,
+			Exception: callException(err),
+			Err:       err,
+			Duration:  time.Since(start),
+		})
+	}
+	if err != nil {
+		var exception types.Exception
+		if errors.As(err, &exception) {
+			config.logCall("LOG_ERRORS_ONLY", "ERROR", map[string]any{
+				"exceptionRaised": map[string]any{"function": f.Name(), "exception": exception.Exception()},
+			})
+			config.trace.record(map[string]any{"call": f.Name(), "args": argsRaw, "exception": exception.Exception()})
+		}
+		return nil, "", fmt.Errorf("call %q: %w", s.call.Source, err)
+	}
+	config.logCall("LOG_ALL_CALLS", "INFO", map[string]any{
+		"callSucceeded": map[string]any{"function": f.Name(), "response": ret},
+	})
+	config.trace.record(map[string]any{"call": f.Name(), "args": argsRaw, "result": ret})
 	if s.result != nil {
-		resultRef, err := ev.ResolveReference(s.result)
+		// lock the shared variable only while writing the result to not block the other branches during the call
+		unlock, err := ev.LockSharedVariablesIfNeeded(ctx, s.result)
 		if err != nil {
-			return nil, "", fmt.Errorf("unknown result %q: %w", s.call.Source, err)
+			return nil, "", fmt.Errorf("LockSharedVariablesIfNeeded: %w", err)
 		}
+		defer unlock()
 
-		variable, err = resultRef.ResolveVariable(ev.SymbolTable)
+		resultRef, err := ev.ResolveReference(ctx, s.result)
 		if err != nil {
 			return nil, "", fmt.Errorf("unknown result %q: %w", s.call.Source, err)
 		}
-	}
 
-	ret, err := f.Call(args)
-	if err != nil {
-		return nil, "", fmt.Errorf("call %q: %w", s.call.Source, err)
-	}
-	if variable != nil {
+		variable, err := resultRef.ResolveVariable(ev.SymbolTable)
+		if err != nil {
+			return nil, "", fmt.Errorf("unknown result %q: %w", s.call.Source, err)
+		}
 		variable.Set(ret)
+		if logger := config.variableLogger; logger != nil {
+			rootSym, _ := variable.Paths()
+			logger.log(ctx, ev.SymbolTable, rootSym, f.Name())
+		}
 	}
 
 	return ret, "", nil
@@ -563,15 +1046,15 @@ func newSwitchStep(def anonymousStepDef) (*switchStep, error) {
 	}, nil
 }
 
-func (s *switchStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
+func (s *switchStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
 	for i, c := range s.conditions {
-		ret, err := ev.EvaluateValue(c.condition)
+		ret, err := ev.EvaluateValue(ctx, c.condition)
 		if err != nil {
 			return nil, "", fmt.Errorf("invalid condition[%d]: %w", i, err)
 		}
 
 		if ok, isBool := ret.(bool); isBool && ok {
-			ret, nextStepName, err := c.step.Execute(ev)
+			ret, nextStepName, err := c.step.Execute(ctx, ev)
 			if err != nil {
 				return nil, "", err
 			}
@@ -581,7 +1064,7 @@ func (s *switchStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
 	}
 
 	if s.defaultStep != nil {
-		ret, nextStepName, err := s.defaultStep.Execute(ev)
+		ret, nextStepName, err := s.defaultStep.Execute(ctx, ev)
 		if err != nil {
 			return nil, "", err
 		}
@@ -728,8 +1211,8 @@ func newTryStep(def anonymousStepDef) (*tryStep, error) {
 	}, nil
 }
 
-func (s *tryStep) evaluateRetryPolicy(ev *expression.Evaluator) (*retryPolicy, error) {
-	retryAny, err := ev.EvaluateValue(s.retryPolicy)
+func (s *tryStep) evaluateRetryPolicy(ctx context.Context, ev *expression.Evaluator) (*retryPolicy, error) {
+	retryAny, err := ev.EvaluateValue(ctx, s.retryPolicy)
 	if err != nil {
 		return nil, err
 	}
@@ -757,17 +1240,17 @@ func (s *tryStep) evaluateRetryPolicy(ev *expression.Evaluator) (*retryPolicy, e
 	return policy, nil
 }
 
-func (s *tryStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
+func (s *tryStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
 	if s.retryPolicy == nil {
-		return s.execute(ev, nil)
+		return s.execute(ctx, ev, nil)
 	}
 
-	policy, err := s.evaluateRetryPolicy(ev)
+	policy, err := s.evaluateRetryPolicy(ctx, ev)
 	if err != nil {
 		return nil, "", fmt.Errorf("retry: %w", err)
 	}
 
-	return s.execute(ev, &retryStatus{
+	return s.execute(ctx, ev, &retryStatus{
 		restRetries: policy.maxRetries,
 		delay:       policy.backoff.initialDelay,
 		policy:      policy,
@@ -780,8 +1263,8 @@ type retryStatus struct {
 	policy      *retryPolicy
 }
 
-func (s *tryStep) execute(ev *expression.Evaluator, retry *retryStatus) (any, StepName, error) {
-	ret, nextStepName, err := s.realStep.Execute(ev)
+func (s *tryStep) execute(ctx context.Context, ev *expression.Evaluator, retry *retryStatus) (any, StepName, error) {
+	ret, nextStepName, err := s.realStep.Execute(ctx, ev)
 	if err == nil {
 		return ret, nextStepName, nil
 	}
@@ -790,32 +1273,38 @@ func (s *tryStep) execute(ev *expression.Evaluator, retry *retryStatus) (any, St
 	if !errors.As(err, &exception) {
 		return nil, "", err
 	}
+	config := getExecuteConfig(ev.SymbolTable)
 	if retry != nil && retry.restRetries > 0 {
-		predicate, err := ev.EvaluateValue(retry.policy.predicate)
+		predicate, err := ev.EvaluateValue(ctx, retry.policy.predicate)
 		if err != nil {
 			panic(err)
 		}
 
-		result, err := predicate.(types.Function).Call([]any{exception.Exception()})
+		result, err := predicate.(types.Function).Call(ctx, []any{exception.Exception()})
 		if err != nil {
 			panic(err)
 		}
 
 		if result.(bool) {
-			time.Sleep(retry.delay)
+			config.onException(ctx, exception, "retry")
+			if err := defaults.Sleep(ctx, retry.delay); err != nil {
+				return nil, "", err
+			}
 			retry.delay = time.Duration(float64(retry.delay) * retry.policy.backoff.multiplier)
 			if retry.delay > retry.policy.backoff.maxDelay {
 				retry.delay = retry.policy.backoff.maxDelay
 			}
 			retry.restRetries--
-			return s.execute(ev, retry)
+			return s.execute(ctx, ev, retry)
 		}
 	}
 	if s.exceptStep == nil {
+		config.onException(ctx, exception, "raise")
 		return nil, "", err
 	}
 
-	return s.exceptStep.execute(ev.SymbolTable, exception)
+	config.onException(ctx, exception, "except")
+	return s.exceptStep.execute(ctx, ev.SymbolTable, exception)
 }
 
 func newExceptStep(def json.RawMessage) (*exceptStep, error) {
@@ -856,9 +1345,9 @@ type exceptStep struct {
 	steps *anonymousStepsStep
 }
 
-func (s *exceptStep) execute(symbolTable *types.SymbolTable, exception types.Exception) (any, StepName, error) {
+func (s *exceptStep) execute(ctx context.Context, symbolTable *types.SymbolTable, exception types.Exception) (any, StepName, error) {
 	evaluator := expression.Evaluator{SymbolTable: symbolTable.ShallowClone()}
-	ref, err := evaluator.ResolveReference(s.as)
+	ref, err := evaluator.ResolveReference(ctx, s.as)
 	if err != nil {
 		panic(err)
 	}
@@ -869,7 +1358,7 @@ func (s *exceptStep) execute(symbolTable *types.SymbolTable, exception types.Exc
 	}
 	variable.Set(exception.Exception())
 
-	ret, nextStepName, err := s.steps.Execute(&evaluator)
+	ret, nextStepName, err := s.steps.Execute(ctx, &evaluator)
 	if err != nil {
 		return nil, "", err
 	}
@@ -906,30 +1395,13 @@ func newForStep(def anonymousStepDef, parallel *parallelPolicy) (*forStep, error
 	}
 
 	// parse steps
-	wf := &forStepsWorkflow{
-		stepMap: make(map[StepName]Step, len(decoded.Steps)),
+	entryStep, stepMap, err := compileSteps(decoded.Steps, "continue")
+	if err != nil {
+		return nil, err
 	}
-	for i, stepDef := range decoded.Steps {
-		if _, duplicated := wf.stepMap[stepDef.name]; duplicated {
-			return nil, fmt.Errorf("%s: duplicated step name in steps", stepDef.name)
-		}
-
-		var defaultNextStepName StepName
-		if i == len(decoded.Steps)-1 {
-			defaultNextStepName = "continue"
-		} else {
-			defaultNextStepName = decoded.Steps[i+1].name
-		}
-
-		var err error
-		wf.stepMap[stepDef.name], err = stepDef.compile(defaultNextStepName)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", stepDef.name, err)
-		}
-
-		if wf.entryStep == nil {
-			wf.entryStep = wf.stepMap[stepDef.name]
-		}
+	wf := &forStepsWorkflow{
+		entryStep: entryStep,
+		stepMap:   stepMap,
 	}
 
 	return &forStep{
@@ -947,15 +1419,15 @@ type forStep struct {
 	parallel *parallelPolicy
 }
 
-func (s *forStep) Execute(ev *expression.Evaluator) (any, StepName, error) {
+func (s *forStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
 	if s.parallel != nil {
-		return s.executeInParallel(ev)
+		return s.executeInParallel(ctx, ev)
 	}
-	return s.executeInSerial(ev)
+	return s.executeInSerial(ctx, ev)
 }
 
-func (s *forStep) executeInSerial(ev *expression.Evaluator) (any, StepName, error) {
-	inAny, err := ev.EvaluateValueRecursive(s.in)
+func (s *forStep) executeInSerial(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
+	inAny, err := ev.EvaluateValueRecursive(ctx, s.in)
 	if err != nil {
 		return nil, "", fmt.Errorf("in: %w", err)
 	}
@@ -976,7 +1448,7 @@ func (s *forStep) executeInSerial(ev *expression.Evaluator) (any, StepName, erro
 			Parent: ev.SymbolTable,
 		}
 
-		ctrl, err := s.workflow.execute(symbolTable)
+		ctrl, err := s.workflow.execute(ctx, symbolTable, nil)
 		if err != nil {
 			return nil, "", fmt.Errorf("in[%d]: %w", i, err)
 		}
@@ -993,8 +1465,8 @@ func (s *forStep) executeInSerial(ev *expression.Evaluator) (any, StepName, erro
 	return nil, "", nil
 }
 
-func (s *forStep) executeInParallel(ev *expression.Evaluator) (any, StepName, error) {
-	inAny, err := ev.EvaluateValueRecursive(s.in)
+func (s *forStep) executeInParallel(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
+	inAny, err := ev.EvaluateValueRecursive(ctx, s.in)
 	if err != nil {
 		return nil, "", fmt.Errorf("in: %w", err)
 	}
@@ -1007,56 +1479,35 @@ func (s *forStep) executeInParallel(ev *expression.Evaluator) (any, StepName, er
 		}
 	}
 
-	symbolTable := ev.SymbolTable.ShallowClone()
-	inheritedVariables := &types.InternalInheritedVariables{
-		Shared: make(map[string]bool, len(symbolTable.Symbols)),
+	ids := make([]string, len(in))
+	for i := range in {
+		ids[i] = strconv.Itoa(i)
 	}
-	for key := range symbolTable.KeysChan() {
-		inheritedVariables.Shared[key] = false
-	}
-	for i, shared := range s.parallel.shared {
-		ref, err := ev.ResolveReference(shared)
-		if err != nil {
-			return nil, "", fmt.Errorf("invalid shared[%d]: %w", i, err)
-		}
 
-		v, err := ref.ResolveVariable(symbolTable)
-		if err != nil {
-			return nil, "", fmt.Errorf("invalid shared[%d]: %w", i, err)
+	// break stops all the remaining iterations at their next step boundary
+	var broken atomic.Bool
+	err = s.parallel.execute(ctx, ev, ids, func(i int, symbolTable *types.SymbolTable) error {
+		if broken.Load() {
+			return nil
 		}
 
-		value := v.Get()
-		v.Set(&types.SharedVariable{Value: value})
-
-		root, _ := v.Paths()
-		inheritedVariables.Shared[root] = true
-	}
-	symbolTable.Symbols[types.InternalInheritedVariablesSymbol] = inheritedVariables
-
-	eg := errgroup.Group{}
-	for i, v := range in {
-		i := i
-		v := v
-		eg.Go(func() error {
-			symbolTable := &types.SymbolTable{
-				Symbols: map[string]any{
-					s.value: v,
-				},
-				Parent: symbolTable,
-			}
-
-			ctrl, err := s.workflow.execute(symbolTable)
-			if err != nil {
-				return fmt.Errorf("in[%d]: %w", i, err)
-			}
-			if ctrl == continueForStepLoopControl {
-				return nil
-			}
+		symbolTable = &types.SymbolTable{
+			Symbols: map[string]any{
+				s.value: in[i],
+			},
+			Parent: symbolTable,
+		}
 
-			return nil
-		})
-	}
-	return nil, "", eg.Wait()
+		ctrl, err := s.workflow.execute(ctx, symbolTable, broken.Load)
+		if err != nil {
+			return fmt.Errorf("in[%d]: %w", i, err)
+		}
+		if ctrl == breakForStepLoopControl {
+			broken.Store(true)
+		}
+		return nil
+	})
+	return nil, "", err
 }
 
 type forStepLoopControl int
@@ -1072,14 +1523,22 @@ type forStepsWorkflow struct {
 	stepMap   map[StepName]Step
 }
 
-func (w *forStepsWorkflow) execute(symbolTable *types.SymbolTable) (forStepLoopControl, error) {
+// execute runs an iteration of the loop. interrupted is checked at every step boundary to stop the iteration as break.
+func (w *forStepsWorkflow) execute(ctx context.Context, symbolTable *types.SymbolTable, interrupted func() bool) (forStepLoopControl, error) {
 	ev := expression.Evaluator{SymbolTable: symbolTable}
 	step := w.entryStep
 	for step != nil {
-		_, nextStepName, err := step.Execute(&ev)
-		if err != nil {
+		if interrupted != nil && interrupted() {
+			return breakForStepLoopControl, nil
+		}
+		if err := ctx.Err(); err != nil {
 			return 0, fmt.Errorf("%s: %w", step.Name(), err)
 		}
+
+		_, nextStepName, err := step.Execute(ctx, &ev)
+		if err != nil {
+			return 0, &stepError{step: step.Name(), err: err}
+		}
 		if nextStepName == "break" {
 			return breakForStepLoopControl, nil
 		} else if nextStepName == "continue" {
@@ -1116,8 +1575,10 @@ func newParallelStep(def anonymousStepDef) (AnonymousStep, error) {
 	}
 
 	var sharedDef []string
-	if err := json.Unmarshal(parallelDef["shared"], &sharedDef); err != nil {
-		return nil, fmt.Errorf("parallel: invalid shared: %w", err)
+	if sharedJSON, ok := parallelDef["shared"]; ok {
+		if err := json.Unmarshal(sharedJSON, &sharedDef); err != nil {
+			return nil, fmt.Errorf("parallel: invalid shared: %w", err)
+		}
 	}
 
 	shared := make([]*expression.Expr, len(sharedDef))
@@ -1130,6 +1591,9 @@ func newParallelStep(def anonymousStepDef) (AnonymousStep, error) {
 		if !shared[i].IsSymbol() {
 			return nil, fmt.Errorf("parallel: invalid shared[%d]: must be a variable", i)
 		}
+		if lo.ContainsBy(shared[:i], func(e *expression.Expr) bool { return e.Source == shared[i].Source }) {
+			return nil, fmt.Errorf("parallel: invalid shared[%d]: %q is declared twice", i, def)
+		}
 	}
 
 	policy := &parallelPolicy{
@@ -1147,7 +1611,11 @@ func newParallelStep(def anonymousStepDef) (AnonymousStep, error) {
 			return nil, fmt.Errorf("parallel: %w", err)
 		}
 	} else if parallelDef["branches"] != nil {
-		panic("TODO")
+		var err error
+		step, err = newBranchesStep(parallelDef, policy)
+		if err != nil {
+			return nil, fmt.Errorf("parallel: %w", err)
+		}
 	} else {
 		return nil, fmt.Errorf("parallel: must specify `for` or `branches`")
 	}
@@ -1155,7 +1623,229 @@ func newParallelStep(def anonymousStepDef) (AnonymousStep, error) {
 	return step, nil
 }
 
+// maxParallelNestingDepth is the maximum depth of nested parallel steps including the ones in subworkflows.
+const maxParallelNestingDepth = 2
+
 type parallelPolicy struct {
 	exceptionPolicy string
 	shared          []*expression.Expr
 }
+
+// execute runs f for each id concurrently (or sequentially if serialized) on a symbol table which exposes the shared variables,
+// then writes the shared variables back to the caller's scope after all of them are finished. The variables shared by the
+// outer parallel step already are shared as they are, so the writes of the sibling branches of it are not lost.
+func (p *parallelPolicy) execute(ctx context.Context, ev *expression.Evaluator, ids []string, f func(i int, symbolTable *types.SymbolTable) error) error {
+	depth := 1
+	if v, ok := ev.SymbolTable.Get(types.InternalParallelDepthSymbol); ok {
+		depth += v.(int)
+	}
+	if depth > maxParallelNestingDepth {
+		return &types.Error{
+			Tag: types.ParallelNestingErrorTag,
+			Err: fmt.Errorf("parallel steps cannot be nested more than %d levels", maxParallelNestingDepth),
+		}
+	}
+
+	symbolTable := ev.SymbolTable.ShallowClone()
+	symbolTable.Symbols[types.InternalParallelDepthSymbol] = depth
+	inheritedVariables := &types.InternalInheritedVariables{
+		Shared: make(map[string]bool, len(symbolTable.Symbols)),
+	}
+	for key := range symbolTable.KeysChan() {
+		inheritedVariables.Shared[key] = false
+	}
+
+	// the shared variables created by this parallel step, which are written back after all branches are finished
+	sharedVariables := make(map[string]*types.SharedVariable, len(p.shared))
+	sharedExprs := make([]*expression.Expr, 0, len(p.shared))
+	for i, shared := range p.shared {
+		ref, err := ev.ResolveReference(ctx, shared)
+		if err != nil {
+			return fmt.Errorf("invalid shared[%d]: %w", i, err)
+		}
+
+		v, err := ref.ResolveVariable(ev.SymbolTable)
+		if err != nil {
+			return fmt.Errorf("invalid shared[%d]: %w", i, err)
+		}
+
+		root, _ := v.Paths()
+		current, declared := ev.SymbolTable.Get(root)
+		if !declared {
+			return fmt.Errorf("invalid shared[%d]: %q must be declared before the parallel step", i, root)
+		}
+
+		inheritedVariables.Shared[root] = true
+		if outer, ok := current.(*types.SharedVariable); ok {
+			// nested in the parallel step sharing it, so the reads and the writes go through the lock of the outer one
+			symbolTable.Symbols[root] = outer
+			continue
+		}
+		sharedVariables[root] = &types.SharedVariable{Value: v.Get()}
+		symbolTable.Symbols[root] = sharedVariables[root]
+		sharedExprs = append(sharedExprs, shared)
+	}
+	symbolTable.Symbols[types.InternalInheritedVariablesSymbol] = inheritedVariables
+
+	errs := make([]error, len(ids))
+	if getExecuteConfig(ev.SymbolTable).serializeParallel {
+		for i := range ids {
+			errs[i] = f(i, symbolTable)
+		}
+	} else {
+		runConcurrently(ctx, len(ids), func(i int) {
+			errs[i] = f(i, symbolTable)
+		})
+	}
+
+	// write back the shared variables created by this parallel step to the caller's scope
+	if len(sharedVariables) != 0 {
+		unlock, err := ev.LockSharedVariablesIfNeeded(ctx, sharedExprs...)
+		if err != nil {
+			return fmt.Errorf("LockSharedVariablesIfNeeded: %w", err)
+		}
+		for root, shared := range sharedVariables {
+			ev.SymbolTable.Set(root, shared.Value)
+		}
+		unlock()
+	}
+
+	return p.joinErrors(ids, errs)
+}
+
+func (p *parallelPolicy) joinErrors(ids []string, errs []error) error {
+	var branches []any
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		var exception types.Exception
+		if !errors.As(err, &exception) {
+			return err
+		}
+
+		branches = append(branches, map[string]any{
+			"id":    ids[i],
+			"error": exception.Exception(),
+		})
+	}
+	if len(branches) == 0 {
+		return nil
+	}
+
+	return &types.Error{
+		Tag: types.UnhandledBranchErrorTag,
+		Err: fmt.Errorf("One or more branches or iterations encountered an unhandled runtime error"),
+		Extra: map[string]any{
+			"branches": branches,
+		},
+	}
+}
+
+func newBranchesStep(def map[string]json.RawMessage, parallel *parallelPolicy) (*branchesStep, error) {
+	var branchDefs []*workflowStepDef
+	if err := json.Unmarshal(def["branches"], &branchDefs); err != nil {
+		return nil, fmt.Errorf("invalid branches: %w", err)
+	}
+	if len(branchDefs) < 2 {
+		return nil, fmt.Errorf("invalid branches: at least 2 branches are required")
+	}
+
+	branches := make([]*parallelBranch, len(branchDefs))
+	for i, branchDef := range branchDefs {
+		for _, b := range branches[:i] {
+			if b.name == branchDef.name {
+				return nil, fmt.Errorf("%s: duplicated branch name in branches", branchDef.name)
+			}
+		}
+
+		stepsJSON, ok := branchDef.stepDef["steps"]
+		if !ok || len(branchDef.stepDef) != 1 {
+			return nil, fmt.Errorf("%s: branch must have only steps", branchDef.name)
+		}
+
+		var stepDefs []*workflowStepDef
+		if err := json.Unmarshal(stepsJSON, &stepDefs); err != nil {
+			return nil, fmt.Errorf("%s: invalid steps: %w", branchDef.name, err)
+		}
+		if len(stepDefs) == 0 {
+			return nil, fmt.Errorf("%s: empty steps", branchDef.name)
+		}
+
+		entryStep, stepMap, err := compileSteps(stepDefs, "end")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", branchDef.name, err)
+		}
+		branch := &parallelBranch{
+			name:      branchDef.name,
+			entryStep: entryStep,
+			stepMap:   stepMap,
+		}
+		branches[i] = branch
+	}
+
+	return &branchesStep{
+		branches: branches,
+		parallel: parallel,
+	}, nil
+}
+
+type branchesStep struct {
+	branches []*parallelBranch
+	parallel *parallelPolicy
+}
+
+func (s *branchesStep) Execute(ctx context.Context, ev *expression.Evaluator) (any, StepName, error) {
+	ids := lo.Map(s.branches, func(b *parallelBranch, _ int) string {
+		return string(b.name)
+	})
+
+	err := s.parallel.execute(ctx, ev, ids, func(i int, symbolTable *types.SymbolTable) error {
+		symbolTable = &types.SymbolTable{
+			Symbols: map[string]any{},
+			Parent:  symbolTable,
+		}
+
+		if err := s.branches[i].execute(ctx, symbolTable); err != nil {
+			return fmt.Errorf("%s: %w", s.branches[i].name, err)
+		}
+		return nil
+	})
+	return nil, "", err
+}
+
+type parallelBranch struct {
+	name      StepName
+	entryStep Step
+	stepMap   map[StepName]Step
+}
+
+func (b *parallelBranch) execute(ctx context.Context, symbolTable *types.SymbolTable) error {
+	ev := expression.Evaluator{SymbolTable: symbolTable}
+	step := b.entryStep
+	for step != nil {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s: %w", step.Name(), err)
+		}
+
+		_, nextStepName, err := step.Execute(ctx, &ev)
+		if err != nil {
+			return &stepError{step: step.Name(), err: err}
+		}
+		if nextStepName == "end" {
+			return nil
+		} else if nextStepName == "" {
+			return fmt.Errorf("%s: next step is not defined", step.Name())
+		}
+
+		nextStep, ok := b.stepMap[nextStepName]
+		if !ok {
+			return fmt.Errorf("%s: not found", nextStepName)
+		}
+
+		step = nextStep
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// WorkflowResolver resolves the workflow ID of experimental.executions.map into the current revision of the workflow.
+// The revision ID is optional.
+type WorkflowResolver func(workflowID string) (root WorkflowRoot, revisionID string, ok bool)
+
+// WithWorkflowResolver makes experimental.executions.map execute the workflows resolved by the resolver, which raises
+// ValueError for the unknown workflow IDs. The workflow of the execution itself is executed for any workflow IDs without it.
+func WithWorkflowResolver(resolve WorkflowResolver) ExecuteOption {
+	return func(c *executeConfig) {
+		c.resolveWorkflow = resolve
+	}
+}
+
+// executionsMapFunction is experimental.executions.map which starts the child executions for each argument in parallel
+// and returns the list of their results. The child executions run in-process with the configuration of the parent, so
+// they share e.g. the hooks, the journal and the number of the executed steps of the limit.
+// refs. https://cloud.google.com/workflows/docs/reference/stdlib/experimental.executions/map
+type executionsMapFunction struct {
+	root WorkflowRoot
+}
+
+var _ types.ScopedFunction = (*executionsMapFunction)(nil)
+
+func (f *executionsMapFunction) Name() string {
+	return "experimental.executions.map"
+}
+
+func (f *executionsMapFunction) Args() []string {
+	return []string{"workflow_id", "arguments"}
+}
+
+func (f *executionsMapFunction) Call(ctx context.Context, args []any) (any, error) {
+	return f.CallInScope(ctx, nil, args)
+}
+
+func (f *executionsMapFunction) CallInScope(ctx context.Context, caller *types.SymbolTable, args []any) (any, error) {
+	return types.MustNewFunction(f.Name(), []types.Argument{
+		{Name: "workflow_id"},
+		{Name: "arguments"},
+	}, func(ctx context.Context, workflowID string, arguments []any) ([]any, error) {
+		parent := &executeConfig{steps: new(int64)}
+		if caller != nil {
+			parent = getExecuteConfig(caller)
+		}
+
+		root, revisionID := f.root, ""
+		if parent.resolveWorkflow != nil {
+			var ok bool
+			if root, revisionID, ok = parent.resolveWorkflow(workflowID); !ok {
+				return nil, &types.Error{
+					Tag: types.ValueErrorTag,
+					Err: fmt.Errorf("workflow %q is not found", workflowID),
+				}
+			}
+		}
+
+		results := make([]any, len(arguments))
+		errs := make([]error, len(arguments))
+		execute := func(i int) {
+			results[i], errs[i] = root.execute(ctx, arguments[i], parent.child(ctx, workflowID, revisionID))
+		}
+
+		if parent.serializeParallel {
+			for i := range arguments {
+				execute(i)
+			}
+		} else {
+			runConcurrently(ctx, len(arguments), execute)
+		}
+
+		for i, err := range errs {
+			if err != nil {
+				return nil, fmt.Errorf("execution[%d] of %s: %w", i, workflowID, err)
+			}
+		}
+		return results, nil
+	}).Call(ctx, args)
+}
+
+// child returns the configuration of the child execution of the workflow, which inherits the configuration of the
+// parent except the environment variables of the execution and the checkpoints.
+func (c *executeConfig) child(ctx context.Context, workflowID, revisionID string) *executeConfig {
+	env := make(map[string]string, len(c.env))
+	for name, value := range c.env {
+		env[name] = value
+	}
+	env["GOOGLE_CLOUD_WORKFLOW_ID"] = workflowID
+	env["GOOGLE_CLOUD_WORKFLOW_EXECUTION_ID"] = NewExecutionID(ctx)
+	if revisionID != "" {
+		env["GOOGLE_CLOUD_WORKFLOW_REVISION_ID"] = revisionID
+	}
+
+	child := *c
+	child.env = env
+	child.workflows = nil
+	child.saveCheckpoint = nil
+	child.resume = nil
+	return &child
+}
@@ -0,0 +1,192 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+type stepRecorder struct {
+	mu    sync.Mutex
+	steps []workflow.StepName
+}
+
+func (r *stepRecorder) StepStarted(step workflow.StepName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+}
+
+func (r *stepRecorder) StepFinished(workflow.StepName, error) {}
+
+func TestExecutionsMap(t *testing.T) {
+	t.Parallel()
+
+	const parentSource = `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - arguments: [1, 2, 3]
+    - map:
+        call: experimental.executions.map
+        args:
+          workflow_id: ${args.workflow_id}
+          arguments: ${arguments}
+        result: results
+    - done:
+        return: ${results}
+`
+	child, err := workflow.ParseWorkflowYAML(strings.NewReader(`
+main:
+  params: [n]
+  steps:
+    - double:
+        return: ${n * 2}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := workflow.ParseWorkflowYAML(strings.NewReader(`
+main:
+  steps:
+    - env:
+        return: ${sys.get_env("GOOGLE_CLOUD_WORKFLOW_ID", "") + "@" + sys.get_env("GOOGLE_CLOUD_WORKFLOW_REVISION_ID", "")}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := workflow.WithWorkflowResolver(func(workflowID string) (workflow.WorkflowRoot, string, bool) {
+		switch workflowID {
+		case "child":
+			return child, "", true
+		case "env":
+			return env, "000002-abc", true
+		default:
+			return nil, "", false
+		}
+	})
+
+	for _, tt := range []struct {
+		name             string
+		source           string
+		workflowID       string
+		opts             []workflow.ExecuteOption
+		expected         any
+		expectedSteps    int
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name:       "workflow resolved by the workflow ID",
+			source:     parentSource,
+			workflowID: "child",
+			opts:       []workflow.ExecuteOption{resolver},
+			expected:   []any{int64(2), int64(4), int64(6)},
+			// init, map and done of the parent, and double of the children
+			expectedSteps: 6,
+		},
+		{
+			name:          "environment variables of the resolved workflow",
+			source:        parentSource,
+			workflowID:    "env",
+			opts:          []workflow.ExecuteOption{resolver},
+			expected:      []any{"env@000002-abc", "env@000002-abc", "env@000002-abc"},
+			expectedSteps: 6,
+		},
+		{
+			name:             "unknown workflow ID",
+			source:           parentSource,
+			workflowID:       "unknown",
+			opts:             []workflow.ExecuteOption{resolver},
+			expectedErrorTag: types.ValueErrorTag,
+		},
+		{
+			name: "same workflow without the resolver",
+			source: `
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${"workflow_id" in args}
+            next: init
+          - condition: ${true}
+            return: ${args.n * 2}
+    - init:
+        assign:
+          - arguments: [{n: 1}, {n: 2}]
+    - map:
+        call: experimental.executions.map
+        args:
+          workflow_id: ${args.workflow_id}
+          arguments: ${arguments}
+        result: results
+    - done:
+        return: ${results}
+`,
+			workflowID:    "any",
+			expected:      []any{int64(2), int64(4)},
+			expectedSteps: 6,
+		},
+		{
+			name:       "steps of the children counted in the limit of the parent",
+			source:     parentSource,
+			workflowID: "child",
+			opts: []workflow.ExecuteOption{
+				resolver,
+				workflow.WithLimits(workflow.Limits{MaxSteps: 4}),
+			},
+			expectedErrorTag: types.ResourceLimitErrorTag,
+		},
+		{
+			name:       "steps of the children within the limit of the parent",
+			source:     parentSource,
+			workflowID: "child",
+			opts: []workflow.ExecuteOption{
+				resolver,
+				workflow.WithLimits(workflow.Limits{MaxSteps: 6}),
+			},
+			expected:      []any{int64(2), int64(4), int64(6)},
+			expectedSteps: 6,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := &stepRecorder{}
+			opts := append(tt.opts[:len(tt.opts):len(tt.opts)], workflow.WithStepObserver(recorder))
+			ret, err := root.Execute(context.Background(), map[string]any{"workflow_id": tt.workflowID}, opts...)
+			if tt.expectedErrorTag != "" {
+				var e *types.Error
+				if !errors.As(err, &e) || e.Tag != tt.expectedErrorTag {
+					t.Fatalf("should be %s but got %v", tt.expectedErrorTag, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+			if len(recorder.steps) != tt.expectedSteps {
+				t.Errorf("unexpected steps: %v, want %d steps", recorder.steps, tt.expectedSteps)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package workflow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/samber/lo"
+)
+
+// GraphNodeKind is the kind of the node of the step graph.
+type GraphNodeKind int
+
+const (
+	GraphStartNode  GraphNodeKind = iota // the entry of a routine
+	GraphEndNode                         // the end of a routine
+	GraphStepNode                        // a step
+	GraphSwitchNode                      // a switch step
+)
+
+// GraphNode is a node of the step graph.
+type GraphNode struct {
+	ID      string
+	Routine string
+	Kind    GraphNodeKind
+	Label   string
+	Detail  string // what the step does (e.g. the called function), or empty
+}
+
+// GraphEdge is a transition between the nodes of the step graph.
+type GraphEdge struct {
+	From  string
+	To    string
+	Label string // the condition of the transition (e.g. the condition of the switch), or empty for the next step
+	Call  bool   // the call of the subworkflow, which returns to the caller
+}
+
+// Graph is the step graph of a workflow to render it for the documentations and the reviews.
+type Graph struct {
+	Routines []string // in the order of the source
+	Nodes    []*GraphNode
+	Edges    []*GraphEdge
+}
+
+// GraphWorkflowYAML builds the step graph of the workflow including the branches of the switches, the try and except,
+// the loops, the parallel branches and the calls of the subworkflows. The invalid parts of the workflow are skipped.
+func GraphWorkflowYAML(r io.Reader) (*Graph, error) {
+	yamlBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
+	}
+	return graphWorkflow(jsonBytes, yamlBytes)
+}
+
+// GraphWorkflowJSON is the same as GraphWorkflowYAML for the workflow in JSON.
+func GraphWorkflowJSON(r io.Reader) (*Graph, error) {
+	jsonBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	return graphWorkflow(jsonBytes, jsonBytes)
+}
+
+func graphWorkflow(jsonBytes, source []byte) (*Graph, error) {
+	var routines map[string]json.RawMessage
+	if err := json.Unmarshal(jsonBytes, &routines); err != nil {
+		return nil, fmt.Errorf("json.Decode: %w", err)
+	}
+
+	g := &Graph{Routines: lo.Filter(newStepLocator(source).routineNames, func(name string, _ int) bool { return routines[name] != nil })}
+	for _, name := range lo.Keys(routines) {
+		if !lo.Contains(g.Routines, name) {
+			g.Routines = append(g.Routines, name)
+		}
+	}
+
+	b := &graphBuilder{graph: g, starts: map[string]*GraphNode{}}
+	for _, name := range g.Routines {
+		b.starts[name] = b.addNode(name, GraphStartNode, name, "")
+	}
+	for _, name := range g.Routines {
+		b.addRoutine(name, routines[name])
+	}
+	return g, nil
+}
+
+type graphBuilder struct {
+	graph   *Graph
+	starts  map[string]*GraphNode // by the routine names
+	routine string
+	end     *GraphNode // of the routine
+}
+
+// graphScope is the steps jumping to each other by their names as lintScope.
+type graphScope struct {
+	kind   lintScopeKind
+	parent *graphScope
+	byName map[StepName]*GraphNode
+	exit   *GraphNode // the target of break or end
+	loop   *GraphNode // the target of continue
+}
+
+func (s *graphScope) resolve(name StepName) *GraphNode {
+	for scope := s; scope != nil; scope = scope.parent {
+		if node, ok := scope.byName[name]; ok {
+			return node
+		}
+		switch {
+		case scope.kind == loopScope && name == "break", scope.kind == rootScope && name == "end":
+			return scope.exit
+		case scope.kind == loopScope && name == "continue":
+			return scope.loop
+		case scope.kind != nestedScope:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (b *graphBuilder) addNode(routine string, kind GraphNodeKind, label, detail string) *GraphNode {
+	node := &GraphNode{ID: fmt.Sprintf("n%d", len(b.graph.Nodes)), Routine: routine, Kind: kind, Label: label, Detail: detail}
+	b.graph.Nodes = append(b.graph.Nodes, node)
+	return node
+}
+
+func (b *graphBuilder) addEdge(from, to *GraphNode, label string) {
+	if from != nil && to != nil {
+		b.graph.Edges = append(b.graph.Edges, &GraphEdge{From: from.ID, To: to.ID, Label: label})
+	}
+}
+
+func (b *graphBuilder) addRoutine(name string, raw json.RawMessage) {
+	var def struct {
+		Steps []json.RawMessage `json:"steps"`
+	}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return
+	}
+
+	b.routine = name
+	b.end = b.addNode(name, GraphEndNode, "end", "")
+	scope := &graphScope{kind: rootScope, byName: map[StepName]*GraphNode{}, exit: b.end}
+	b.addEdge(b.starts[name], b.addSteps(def.Steps, scope, b.end), "")
+}
+
+// addSteps adds the steps falling through to the exit after the last step, and returns the node of the first step.
+func (b *graphBuilder) addSteps(stepsRaw []json.RawMessage, scope *graphScope, exit *GraphNode) *GraphNode {
+	steps := decodeStepDefs(stepsRaw)
+	if len(steps) == 0 {
+		return exit
+	}
+
+	// the names of all steps are resolvable from the nested steps of the former steps
+	nodes := make([]*GraphNode, len(steps))
+	for i, step := range steps {
+		kind := GraphStepNode
+		if _, ok := step.stepDef["switch"]; ok {
+			kind = GraphSwitchNode
+		}
+		nodes[i] = b.addNode(b.routine, kind, string(step.name), stepDetail(step.stepDef))
+		scope.byName[step.name] = nodes[i]
+	}
+	for i, step := range steps {
+		next := exit
+		if i+1 < len(steps) {
+			next = nodes[i+1]
+		}
+		b.addStep(nodes[i], step.stepDef, scope, next)
+	}
+	return nodes[0]
+}
+
+func (b *graphBuilder) nestedScope(kind lintScopeKind, parent *graphScope) *graphScope {
+	return &graphScope{kind: kind, parent: parent, byName: map[StepName]*GraphNode{}}
+}
+
+// addStep adds the transitions of the step, which falls through to the next node.
+func (b *graphBuilder) addStep(node *GraphNode, def anonymousStepDef, scope *graphScope, next *GraphNode) {
+	var fields struct {
+		Call  string `json:"call"`
+		Next  string `json:"next"`
+		Retry any    `json:"retry"`
+		For   struct {
+			Value string `json:"value"`
+			In    any    `json:"in"`
+		} `json:"for"`
+		Parallel struct {
+			For struct {
+				Value string `json:"value"`
+				In    any    `json:"in"`
+			} `json:"for"`
+		} `json:"parallel"`
+	}
+	decodeStepFields(def, &fields)
+	nested := decodeNestedSteps(def)
+
+	if start, ok := b.starts[fields.Call]; ok {
+		b.graph.Edges = append(b.graph.Edges, &GraphEdge{From: node.ID, To: start.ID, Label: "call", Call: true})
+	}
+	if fields.Next != "" {
+		next = scope.resolve(StepName(fields.Next))
+	}
+	if _, ok := def["return"]; ok {
+		b.addEdge(node, b.end, "return")
+		return
+	}
+	if _, ok := def["raise"]; ok {
+		b.addEdge(node, b.end, "raise")
+		return
+	}
+
+	switch {
+	case nested.Steps != nil:
+		b.addEdge(node, b.addSteps(nested.Steps, b.nestedScope(nestedScope, scope), next), "")
+
+	case nested.Try != nil:
+		tryNested := decodeNestedSteps(nested.Try)
+		if tryNested.Steps != nil {
+			b.addEdge(node, b.addSteps(tryNested.Steps, b.nestedScope(nestedScope, scope), next), "try")
+		} else {
+			var call struct {
+				Call string `json:"call"`
+			}
+			decodeStepFields(nested.Try, &call)
+			if start, ok := b.starts[call.Call]; ok {
+				b.graph.Edges = append(b.graph.Edges, &GraphEdge{From: node.ID, To: start.ID, Label: "call", Call: true})
+			}
+			b.addEdge(node, next, "")
+		}
+		if fields.Retry != nil {
+			b.addEdge(node, node, "retry")
+		}
+		if nested.Except.Steps != nil {
+			label := "except"
+			if nested.Except.As != "" {
+				label += " as " + nested.Except.As
+			}
+			b.addEdge(node, b.addSteps(nested.Except.Steps, b.nestedScope(nestedScope, scope), next), label)
+		}
+
+	case nested.For.Steps != nil || nested.Parallel.For.Steps != nil:
+		value, in, steps, label := fields.For.Value, fields.For.In, nested.For.Steps, "for "
+		if nested.Parallel.For.Steps != nil {
+			value, in, steps, label = fields.Parallel.For.Value, fields.Parallel.For.In, nested.Parallel.For.Steps, "parallel for "
+		}
+		loop := b.nestedScope(loopScope, scope)
+		loop.exit, loop.loop = next, node
+		b.addEdge(node, b.addSteps(steps, loop, node), fmt.Sprintf("%s%s in %v", label, value, formatGraphValue(in)))
+		b.addEdge(node, next, "done")
+
+	case nested.Parallel.Branches != nil:
+		for _, branch := range nested.Parallel.Branches {
+			for name, branchDef := range branch {
+				branchScope := b.nestedScope(rootScope, nil)
+				branchScope.exit = next
+				b.addEdge(node, b.addSteps(branchDef.Steps, branchScope, next), "branch "+string(name))
+			}
+		}
+
+	case nested.Switch != nil:
+		exhaustive := false
+		for _, condition := range nested.Switch {
+			var conditionFields struct {
+				Condition any    `json:"condition"`
+				Next      string `json:"next"`
+			}
+			decodeStepFields(condition, &conditionFields)
+			label := formatGraphValue(conditionFields.Condition)
+			exhaustive = exhaustive || isDefaultCondition(condition["condition"])
+
+			target := next
+			if conditionFields.Next != "" {
+				target = scope.resolve(StepName(conditionFields.Next))
+			}
+			conditionNested := decodeNestedSteps(condition)
+			switch {
+			case condition["return"] != nil:
+				b.addEdge(node, b.end, label+" return")
+			case condition["raise"] != nil:
+				b.addEdge(node, b.end, label+" raise")
+			case conditionNested.Steps != nil:
+				b.addEdge(node, b.addSteps(conditionNested.Steps, b.nestedScope(nestedScope, scope), target), label)
+			default:
+				b.addEdge(node, target, label)
+			}
+			if exhaustive {
+				break
+			}
+		}
+		if !exhaustive {
+			b.addEdge(node, next, "otherwise")
+		}
+
+	default:
+		b.addEdge(node, next, "")
+	}
+}
+
+// stepDetail describes what the step does.
+func stepDetail(def anonymousStepDef) string {
+	var fields struct {
+		Call   string           `json:"call"`
+		Assign []map[string]any `json:"assign"`
+		Try    anonymousStepDef `json:"try"`
+	}
+	decodeStepFields(def, &fields)
+	switch {
+	case fields.Try != nil:
+		return strings.TrimSpace("try " + stepDetail(fields.Try))
+	case fields.Call != "":
+		return "call " + fields.Call
+	case fields.Assign != nil:
+		return "assign " + strings.Join(lo.FlatMap(fields.Assign, func(assign map[string]any, _ int) []string { return lo.Keys(assign) }), ", ")
+	case def["return"] != nil:
+		return "return"
+	case def["raise"] != nil:
+		return "raise"
+	default:
+		return ""
+	}
+}
+
+func formatGraphValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// WriteDOT renders the graph in the DOT language of Graphviz with a cluster for each routine.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("  node [shape=box];\n")
+	for i, routine := range g.Routines {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&b, "    label=%s;\n", dotQuote(routine))
+		for _, node := range g.Nodes {
+			if node.Routine != routine {
+				continue
+			}
+			label := node.Label
+			if node.Detail != "" {
+				label += "\n" + node.Detail
+			}
+			var shape string
+			switch node.Kind {
+			case GraphStartNode:
+				shape = " shape=oval"
+			case GraphEndNode:
+				shape = " shape=doublecircle"
+			case GraphSwitchNode:
+				shape = " shape=diamond"
+			}
+			fmt.Fprintf(&b, "    %s [label=%s%s];\n", node.ID, dotQuote(label), shape)
+		}
+		b.WriteString("  }\n")
+	}
+	for _, edge := range g.Edges {
+		var attrs []string
+		if edge.Label != "" {
+			attrs = append(attrs, "label="+dotQuote(edge.Label))
+		}
+		if edge.Call {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(&b, "  %s -> %s;\n", edge.From, edge.To)
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s [%s];\n", edge.From, edge.To, strings.Join(attrs, " "))
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteMermaid renders the graph in the flowchart of Mermaid with a subgraph for each routine.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for i, routine := range g.Routines {
+		fmt.Fprintf(&b, "  subgraph routine%d [%s]\n", i, mermaidQuote(routine))
+		for _, node := range g.Nodes {
+			if node.Routine != routine {
+				continue
+			}
+			label := node.Label
+			if node.Detail != "" {
+				label += "<br/>" + node.Detail
+			}
+			switch node.Kind {
+			case GraphStartNode:
+				fmt.Fprintf(&b, "    %s([%s])\n", node.ID, mermaidQuote(label))
+			case GraphEndNode:
+				fmt.Fprintf(&b, "    %s((%s))\n", node.ID, mermaidQuote(label))
+			case GraphSwitchNode:
+				fmt.Fprintf(&b, "    %s{%s}\n", node.ID, mermaidQuote(label))
+			default:
+				fmt.Fprintf(&b, "    %s[%s]\n", node.ID, mermaidQuote(label))
+			}
+		}
+		b.WriteString("  end\n")
+	}
+	for _, edge := range g.Edges {
+		arrow := "-->"
+		if edge.Call {
+			arrow = "-.->"
+		}
+		if edge.Label == "" {
+			fmt.Fprintf(&b, "  %s %s %s\n", edge.From, arrow, edge.To)
+		} else {
+			fmt.Fprintf(&b, "  %s %s|%s| %s\n", edge.From, arrow, mermaidQuote(edge.Label), edge.To)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,334 @@
+package workflow_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+// summarizeEdges formats the edges as "ROUTINE/FROM -LABEL-> ROUTINE/TO" by the labels of the nodes.
+func summarizeEdges(g *workflow.Graph) []string {
+	nodes := lo.KeyBy(g.Nodes, func(node *workflow.GraphNode) string { return node.ID })
+	return lo.Map(g.Edges, func(edge *workflow.GraphEdge, _ int) string {
+		arrow := "->"
+		if edge.Label != "" {
+			arrow = fmt.Sprintf("-%s->", edge.Label)
+		}
+		if edge.Call {
+			arrow = "." + arrow
+		}
+		from, to := nodes[edge.From], nodes[edge.To]
+		return fmt.Sprintf("%s/%s %s %s/%s", from.Routine, from.Label, arrow, to.Routine, to.Label)
+	})
+}
+
+func TestGraphWorkflow(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		source   string
+		json     bool
+		expected []string
+	}{
+		{
+			name: "sequential steps",
+			source: `
+main:
+  steps:
+    - first:
+        assign:
+          - x: 1
+    - second:
+        return: ${x}
+`,
+			expected: []string{
+				"main/first -> main/second",
+				"main/second -return-> main/end",
+				"main/main -> main/first",
+			},
+		},
+		{
+			name: "switch",
+			source: `
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${args.a}
+            next: a
+          - condition: ${args.b}
+            return: b
+    - fallthrough:
+        next: end
+    - a:
+        return: a
+`,
+			expected: []string{
+				"main/check -${args.a}-> main/a",
+				"main/check -${args.b} return-> main/end",
+				"main/check -otherwise-> main/fallthrough",
+				"main/fallthrough -> main/end",
+				"main/a -return-> main/end",
+				"main/main -> main/check",
+			},
+		},
+		{
+			name: "exhaustive switch",
+			source: `
+main:
+  steps:
+    - check:
+        switch:
+          - condition: true
+            steps:
+              - inner:
+                  return: 1
+    - unreachable:
+        return: 2
+`,
+			expected: []string{
+				"main/inner -return-> main/end",
+				"main/check -true-> main/inner",
+				"main/unreachable -return-> main/end",
+				"main/main -> main/check",
+			},
+		},
+		{
+			name: "try and except with retry",
+			source: `
+main:
+  steps:
+    - guarded:
+        try:
+          call: http.get
+          args:
+            url: https://example.com
+        retry: ${http.default_retry}
+        except:
+          as: e
+          steps:
+            - failed:
+                raise: ${e}
+    - done:
+        return: ok
+`,
+			expected: []string{
+				"main/guarded -> main/done",
+				"main/guarded -retry-> main/guarded",
+				"main/failed -raise-> main/end",
+				"main/guarded -except as e-> main/failed",
+				"main/done -return-> main/end",
+				"main/main -> main/guarded",
+			},
+		},
+		{
+			name: "for loop",
+			source: `
+main:
+  steps:
+    - loop:
+        for:
+          value: v
+          in: [1, 2]
+          steps:
+            - skip:
+                switch:
+                  - condition: ${v == 1}
+                    next: continue
+            - stop:
+                next: break
+    - done:
+        return: ok
+`,
+			expected: []string{
+				"main/skip -${v == 1}-> main/loop",
+				"main/skip -otherwise-> main/stop",
+				"main/stop -> main/done",
+				"main/loop -for v in [1,2]-> main/skip",
+				"main/loop -done-> main/done",
+				"main/done -return-> main/end",
+				"main/main -> main/loop",
+			},
+		},
+		{
+			name: "parallel branches",
+			source: `
+main:
+  steps:
+    - fanout:
+        parallel:
+          branches:
+            - b1:
+                steps:
+                  - one:
+                      assign:
+                        - x: 1
+            - b2:
+                steps:
+                  - two:
+                      assign:
+                        - y: 1
+    - done:
+        return: ok
+`,
+			expected: []string{
+				"main/one -> main/done",
+				"main/fanout -branch b1-> main/one",
+				"main/two -> main/done",
+				"main/fanout -branch b2-> main/two",
+				"main/done -return-> main/end",
+				"main/main -> main/fanout",
+			},
+		},
+		{
+			name: "subworkflow call",
+			source: `
+main:
+  steps:
+    - call_sub:
+        call: sub
+        result: r
+    - done:
+        return: ${r}
+sub:
+  steps:
+    - r:
+        return: ok
+`,
+			expected: []string{
+				"main/call_sub .-call-> sub/sub",
+				"main/call_sub -> main/done",
+				"main/done -return-> main/end",
+				"main/main -> main/call_sub",
+				"sub/r -return-> sub/end",
+				"sub/sub -> sub/r",
+			},
+		},
+		{
+			name:   "JSON workflow",
+			source: `{"main": {"steps": [{"done": {"return": "ok"}}]}}`,
+			json:   true,
+			expected: []string{
+				"main/done -return-> main/end",
+				"main/main -> main/done",
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			graph := workflow.GraphWorkflowYAML
+			if tt.json {
+				graph = workflow.GraphWorkflowJSON
+			}
+			g, err := graph(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, summarizeEdges(g)); diff != "" {
+				t.Errorf("unexpected edges (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGraphRender(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - check:
+        switch:
+          - condition: ${x == "a"}
+            next: call_sub
+    - call_sub:
+        call: sub
+sub:
+  steps:
+    - done:
+        return: ok
+`
+	for _, tt := range []struct {
+		name     string
+		render   func(*workflow.Graph, *strings.Builder) error
+		expected string
+	}{
+		{
+			name:   "DOT",
+			render: func(g *workflow.Graph, b *strings.Builder) error { return g.WriteDOT(b) },
+			expected: `digraph workflow {
+  node [shape=box];
+  subgraph cluster_0 {
+    label="main";
+    n0 [label="main" shape=oval];
+    n2 [label="end" shape=doublecircle];
+    n3 [label="check" shape=diamond];
+    n4 [label="call_sub\ncall sub"];
+  }
+  subgraph cluster_1 {
+    label="sub";
+    n1 [label="sub" shape=oval];
+    n5 [label="end" shape=doublecircle];
+    n6 [label="done\nreturn"];
+  }
+  n3 -> n4 [label="${x == \"a\"}"];
+  n3 -> n4 [label="otherwise"];
+  n4 -> n1 [label="call" style=dashed];
+  n4 -> n2;
+  n0 -> n3;
+  n6 -> n5 [label="return"];
+  n1 -> n6;
+}
+`,
+		},
+		{
+			name:   "Mermaid",
+			render: func(g *workflow.Graph, b *strings.Builder) error { return g.WriteMermaid(b) },
+			expected: `flowchart TD
+  subgraph routine0 ["main"]
+    n0(["main"])
+    n2(("end"))
+    n3{"check"}
+    n4["call_sub<br/>call sub"]
+  end
+  subgraph routine1 ["sub"]
+    n1(["sub"])
+    n5(("end"))
+    n6["done<br/>return"]
+  end
+  n3 -->|"${x == #quot;a#quot;}"| n4
+  n3 -->|"otherwise"| n4
+  n4 -.->|"call"| n1
+  n4 --> n2
+  n0 --> n3
+  n6 -->|"return"| n5
+  n1 --> n6
+`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g, err := workflow.GraphWorkflowYAML(strings.NewReader(source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if err := tt.render(g, &b); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, b.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
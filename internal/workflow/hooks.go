@@ -0,0 +1,129 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// Hooks are called by the executor around the steps (including the nested ones), the call steps and the exceptions
+// of an execution, e.g. for the custom instrumentation like the metrics or the coverage. The methods are called
+// concurrently by the branches of the parallel steps unless they are serialized. Embed NopHooks to implement a part
+// of them.
+type Hooks interface {
+	// BeforeStep is called before the step. The execution is aborted with the error if it returns an error.
+	BeforeStep(ctx context.Context, state *StepState) error
+	// AfterStep is called after the step with the state given to BeforeStep, the next step (empty for the last one of
+	// the nested steps, or when the step fails) and the error of the step.
+	AfterStep(ctx context.Context, state *StepState, next StepName, err error)
+	// OnCall is called after the function of the call step returns.
+	OnCall(ctx context.Context, call *CallEvent)
+	// OnException is called when the try step retries (retry), catches (except) or re-raises (raise) the exception,
+	// or when the exception is uncaught by the execution (uncaught).
+	OnException(ctx context.Context, exception any, handling string)
+}
+
+// CallEvent is the call of the function by the call step.
+type CallEvent struct {
+	Function  string
+	Args      any // the arguments as they are written, which is a map of the names or a list
+	Result    any
+	Exception any   // the value of the exception raised by the function, or nil
+	Err       error // the error of the function including the exception, or nil
+	Duration  time.Duration
+}
+
+// NopHooks is the Hooks doing nothing.
+type NopHooks struct{}
+
+var _ Hooks = NopHooks{}
+
+func (NopHooks) BeforeStep(context.Context, *StepState) error { return nil }
+
+func (NopHooks) AfterStep(context.Context, *StepState, StepName, error) {}
+
+func (NopHooks) OnCall(context.Context, *CallEvent) {}
+
+func (NopHooks) OnException(context.Context, any, string) {}
+
+// WithHooks makes the execution call the hooks in the order, which are called after the hooks given before.
+func WithHooks(hooks ...Hooks) ExecuteOption {
+	return func(c *executeConfig) {
+		c.hooks = append(c.hooks, hooks...)
+	}
+}
+
+// beforeStep calls BeforeStep of the hooks, and returns the function to call AfterStep of them in the reverse order.
+// When one of them returns an error, AfterStep of the hooks called before it is called with the error.
+func (c *executeConfig) beforeStep(ctx context.Context, state *StepState) (func(next StepName, err error), error) {
+	after := func(hooks []Hooks) func(StepName, error) {
+		return func(next StepName, err error) {
+			for i := len(hooks) - 1; i >= 0; i-- {
+				hooks[i].AfterStep(ctx, state, next, err)
+			}
+		}
+	}
+
+	for i, hook := range c.hooks {
+		if err := hook.BeforeStep(ctx, state); err != nil {
+			after(c.hooks[:i])("", err)
+			return nil, err
+		}
+	}
+	return after(c.hooks), nil
+}
+
+func (c *executeConfig) onCall(ctx context.Context, call *CallEvent) {
+	for _, hook := range c.hooks {
+		hook.OnCall(ctx, call)
+	}
+}
+
+// onException notifies the journal and the hooks of the handling of the exception.
+func (c *executeConfig) onException(ctx context.Context, exception types.Exception, handling string) {
+	c.journal.exception(ctx, exception, handling)
+	if len(c.hooks) == 0 {
+		return
+	}
+
+	value := exception.Exception()
+	for _, hook := range c.hooks {
+		hook.OnException(ctx, value, handling)
+	}
+}
+
+// stepHook is the Hooks calling the StepHook before the steps.
+type stepHook struct {
+	NopHooks
+	hook StepHook
+}
+
+func (h stepHook) BeforeStep(ctx context.Context, state *StepState) error {
+	return h.hook(ctx, state)
+}
+
+// stepObserverHooks is the Hooks notifying the StepObserver of the starts and the finishes of the steps.
+type stepObserverHooks struct {
+	NopHooks
+	observer StepObserver
+}
+
+func (h stepObserverHooks) BeforeStep(_ context.Context, state *StepState) error {
+	h.observer.StepStarted(state.Step)
+	return nil
+}
+
+func (h stepObserverHooks) AfterStep(_ context.Context, state *StepState, _ StepName, err error) {
+	h.observer.StepFinished(state.Step, err)
+}
+
+// callException returns the value of the exception of the error of the call, or nil.
+func callException(err error) any {
+	var exception types.Exception
+	if errors.As(err, &exception) {
+		return exception.Exception()
+	}
+	return nil
+}
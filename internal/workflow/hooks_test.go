@@ -0,0 +1,287 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+var errHookFailed = errors.New("hook failed")
+
+// recordingHooks records the calls of the hooks into the shared events.
+type recordingHooks struct {
+	workflow.NopHooks
+	id     string
+	failAt workflow.StepName
+	mu     *sync.Mutex
+	events *[]string
+}
+
+func (h recordingHooks) record(format string, args ...any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.events = append(*h.events, h.id+": "+fmt.Sprintf(format, args...))
+}
+
+func (h recordingHooks) BeforeStep(_ context.Context, state *workflow.StepState) error {
+	h.record("before %s", strings.Join(state.Path, "/"))
+	if state.Step == h.failAt {
+		return errHookFailed
+	}
+	return nil
+}
+
+func (h recordingHooks) AfterStep(_ context.Context, state *workflow.StepState, next workflow.StepName, err error) {
+	h.record("after %s next=%q err=%t", state.Step, next, err != nil)
+}
+
+func (h recordingHooks) OnCall(_ context.Context, call *workflow.CallEvent) {
+	h.record("call %s args=%v result=%v exception=%v", call.Function, call.Args, call.Result, call.Exception)
+}
+
+func (h recordingHooks) OnException(_ context.Context, exception any, handling string) {
+	h.record("exception %v %s", exception, handling)
+}
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name        string
+		source      string
+		hooks       []recordingHooks
+		expected    []string
+		expectedErr error
+		wantErr     bool
+	}{
+		{
+			name: "steps and calls",
+			source: `
+main:
+  steps:
+    - upper:
+        call: text.to_upper
+        args:
+          source: a
+        result: upper
+    - done:
+        return: ${upper}
+`,
+			hooks: []recordingHooks{{id: "h"}},
+			expected: []string{
+				"h: before upper",
+				"h: call text.to_upper args=map[source:a] result=A exception=<nil>",
+				`h: after upper next="done" err=false`,
+				"h: before done",
+				`h: after done next="end" err=false`,
+			},
+		},
+		{
+			name: "nested steps",
+			source: `
+main:
+  steps:
+    - outer:
+        steps:
+          - inner:
+              assign:
+                - x: 1
+    - done:
+        return: ${x}
+`,
+			hooks: []recordingHooks{{id: "h"}},
+			expected: []string{
+				"h: before outer",
+				"h: before outer/inner",
+				`h: after inner next="" err=false`,
+				`h: after outer next="done" err=false`,
+				"h: before done",
+				`h: after done next="end" err=false`,
+			},
+		},
+		{
+			name: "exception retried and caught",
+			source: `
+main:
+  steps:
+    - try_fail:
+        try:
+          raise: boom
+        retry:
+          predicate: ${always}
+          max_retries: 1
+          backoff:
+            initial_delay: 1
+            max_delay: 1
+            multiplier: 1
+        except:
+          as: e
+          steps:
+            - caught:
+                return: ${e}
+always:
+  params: [e]
+  steps:
+    - retry:
+        return: true
+`,
+			hooks: []recordingHooks{{id: "h"}},
+			expected: []string{
+				"h: before try_fail",
+				"h: exception boom retry",
+				"h: exception boom except",
+				"h: before try_fail/caught",
+				`h: after caught next="end" err=false`,
+				`h: after try_fail next="end" err=false`,
+			},
+		},
+		{
+			name: "exception of the call",
+			source: `
+main:
+  steps:
+    - try_get:
+        try:
+          call: json.decode
+          args:
+            data: ${text.encode("x")}
+        except:
+          as: e
+          steps:
+            - caught:
+                return: ${e.tags}
+`,
+			hooks: []recordingHooks{{id: "h"}},
+			expected: []string{
+				"h: before try_get",
+				"h: call json.decode args=map[data:[120]] result=<nil> exception=map[message:ValueError: invalid character 'x' looking for beginning of value tags:[ValueError]]",
+				"h: exception map[message:ValueError: invalid character 'x' looking for beginning of value tags:[ValueError]] except",
+				"h: before try_get/caught",
+				`h: after caught next="end" err=false`,
+				`h: after try_get next="end" err=false`,
+			},
+		},
+		{
+			name: "exception re-raised and uncaught",
+			source: `
+main:
+  steps:
+    - try_fail:
+        try:
+          raise: boom
+        retry:
+          predicate: ${never}
+          max_retries: 1
+          backoff:
+            initial_delay: 1
+            max_delay: 1
+            multiplier: 1
+    - done:
+        return: unreachable
+never:
+  params: [e]
+  steps:
+    - not_retry:
+        return: false
+`,
+			hooks: []recordingHooks{{id: "h"}},
+			expected: []string{
+				"h: before try_fail",
+				"h: exception boom raise",
+				`h: after try_fail next="" err=true`,
+				"h: exception boom uncaught",
+			},
+			wantErr: true,
+		},
+		{
+			name: "multiple hooks",
+			source: `
+main:
+  steps:
+    - done:
+        return: 1
+`,
+			hooks: []recordingHooks{{id: "h1"}, {id: "h2"}},
+			expected: []string{
+				"h1: before done",
+				"h2: before done",
+				`h2: after done next="end" err=false`,
+				`h1: after done next="end" err=false`,
+			},
+		},
+		{
+			name: "abort by the hook",
+			source: `
+main:
+  steps:
+    - first:
+        assign:
+          - x: 1
+    - second:
+        return: ${x}
+`,
+			hooks: []recordingHooks{{id: "h1"}, {id: "h2", failAt: "second"}, {id: "h3"}},
+			expected: []string{
+				"h1: before first",
+				"h2: before first",
+				"h3: before first",
+				`h3: after first next="second" err=false`,
+				`h2: after first next="second" err=false`,
+				`h1: after first next="second" err=false`,
+				"h1: before second",
+				"h2: before second",
+				`h1: after second next="" err=true`,
+			},
+			expectedErr: errHookFailed,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			var events []string
+			hooks := make([]workflow.Hooks, len(tt.hooks))
+			for i, h := range tt.hooks {
+				h.mu, h.events = &mu, &events
+				hooks[i] = h
+			}
+
+			_, err = root.Execute(context.Background(), nil,
+				workflow.WithHooks(hooks...),
+				workflow.WithClock(func() defaults.Clock { return defaults.NewFakeClock(time.Now()) }),
+			)
+			switch {
+			case tt.expectedErr != nil:
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("should be %v but got %v", tt.expectedErr, err)
+				}
+				t.Logf("expected error: %v", err)
+			case tt.wantErr:
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.expected, events); diff != "" {
+				t.Errorf("unexpected events (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
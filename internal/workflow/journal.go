@@ -0,0 +1,201 @@
+package workflow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/expression"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/samber/lo"
+)
+
+// Journal writes the JSON lines of the events of an execution with their timestamps as they happen, e.g. as the audit
+// trail for the postmortems of the failed executions. The events are the start and the end of the execution, the starts
+// and the finishes of the steps, the evaluated expressions, the outbound HTTP interactions and the exceptions.
+type Journal struct {
+	mu     sync.Mutex
+	w      io.Writer
+	redact *regexp.Regexp
+}
+
+// JournalEntry is a line of the journal. The fields other than Time and Type are set by the type of the event.
+type JournalEntry struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"` // execution, result, step, stepFinished, expression, http or exception
+	Routine string    `json:"routine,omitempty"`
+	Path    []string  `json:"path,omitempty"` // the steps containing the step and the step
+
+	Args        any             `json:"args,omitempty"`        // execution
+	Result      any             `json:"result,omitempty"`      // result
+	Next        StepName        `json:"next,omitempty"`        // stepFinished
+	Expression  string          `json:"expression,omitempty"`  // expression
+	Value       any             `json:"value,omitempty"`       // expression
+	Interaction json.RawMessage `json:"interaction,omitempty"` // http, in the format of the cassette of --record
+	Exception   any             `json:"exception,omitempty"`   // exception
+	Handling    string          `json:"handling,omitempty"`    // exception: retry, except, raise (by a try step) or uncaught
+	Error       string          `json:"error,omitempty"`       // result, stepFinished and expression
+}
+
+// NewJournal returns the journal writing the entries into w. Unless redact is nil, the values are redacted like
+// WithVariableLog: the keys of the maps matching redact at any depth, the values of the expressions whose sources match
+// it (e.g. ${secret}), the response headers and the keys of the JSON bodies of the HTTP interactions, and the response
+// bodies of Secret Manager. The replays of the journal get [REDACTED] for them.
+func NewJournal(w io.Writer, redact *regexp.Regexp) *Journal {
+	return &Journal{w: w, redact: redact}
+}
+
+// WithJournal makes the execution write its events into the journal.
+// The HTTP interactions are written by JournalHTTPInteraction, e.g. via defaults.ObserveHTTPInteractions.
+func WithJournal(journal *Journal) ExecuteOption {
+	return func(c *executeConfig) {
+		c.journal = journal
+	}
+}
+
+// write writes the entry in the step of the context if it is not nil.
+func (j *Journal) write(ctx context.Context, entry *JournalEntry) {
+	if j == nil {
+		return
+	}
+
+	entry.Time = time.Now().UTC()
+	entry.Routine = stepRoutineFromContext(ctx)
+	entry.Path = stepPathFromContext(ctx)
+	if j.redact != nil {
+		entry.Args = redactValue(j.redact, "", entry.Args)
+		entry.Result = redactValue(j.redact, "", entry.Result)
+		entry.Value = redactValue(j.redact, entry.Expression, entry.Value)
+		entry.Exception = redactValue(j.redact, "", entry.Exception)
+	}
+	line, err := json.MarshalWithOption(entry, json.DisableHTMLEscape())
+	if err != nil {
+		line = []byte(fmt.Sprintf(`{"time":%q,"type":%q,"error":%q}`, entry.Time.Format(time.RFC3339Nano), entry.Type, err.Error()))
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		logging.Logf(logging.LevelError, "failed to write journal", "error", err)
+	}
+}
+
+type journalKey struct{}
+
+// JournalHTTPInteraction writes the outbound HTTP interaction into the journal of the execution of the context of the
+// request, or does nothing if the execution is not journaled.
+func JournalHTTPInteraction(ctx context.Context, interaction any) {
+	journal, ok := ctx.Value(journalKey{}).(*Journal)
+	if !ok {
+		return
+	}
+
+	b, err := json.Marshal(interaction)
+	if err == nil && journal.redact != nil {
+		b, err = journal.redactInteraction(b)
+	}
+	if err != nil {
+		logging.Logf(logging.LevelError, "failed to journal HTTP interaction", "error", err)
+		return
+	}
+	journal.write(ctx, &JournalEntry{Type: "http", Interaction: b})
+}
+
+// secretManagerHosts are the hosts of Secret Manager, whose response bodies are the secrets.
+var secretManagerHosts = []string{"secretmanager.googleapis.com", "secretmanager.mtls.googleapis.com"}
+
+// redactInteraction redacts the interaction in the format of the cassette of --record, which has the request with the
+// method, the url and the body, and the response with the status, the headers and the body.
+func (j *Journal) redactInteraction(b []byte) ([]byte, error) {
+	var interaction map[string]any
+	if err := json.Unmarshal(b, &interaction); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	req, _ := interaction["request"].(map[string]any)
+	res, _ := interaction["response"].(map[string]any)
+	var secret bool
+	if u, err := url.Parse(fmt.Sprint(req["url"])); err == nil {
+		secret = lo.Contains(secretManagerHosts, u.Hostname())
+	}
+	j.redactBody(req)
+	if res != nil {
+		res["headers"] = redactValue(j.redact, "", res["headers"])
+		if _, ok := res["body"]; ok && secret {
+			res["body"] = map[string]any{"string": redactedValue}
+		} else {
+			j.redactBody(res)
+		}
+	}
+	return json.Marshal(interaction)
+}
+
+// redactBody redacts the keys of the JSON body of the request or the response. The body is kept as it is unless any
+// keys are redacted.
+func (j *Journal) redactBody(message map[string]any) {
+	body, _ := message["body"].(map[string]any)
+	s, ok := body["string"].(string)
+	if !ok {
+		return
+	}
+
+	var value any
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return // not JSON
+	}
+	original, err := json.MarshalWithOption(value, json.DisableHTMLEscape())
+	if err != nil {
+		return
+	}
+	redacted, err := json.MarshalWithOption(redactValue(j.redact, "", value), json.DisableHTMLEscape())
+	if err != nil || bytes.Equal(original, redacted) {
+		return
+	}
+	body["string"] = string(redacted)
+}
+
+func (j *Journal) observeExpression(ctx context.Context, expr *expression.Expr, value any, err error) {
+	entry := &JournalEntry{Type: "expression", Expression: expr.Source, Value: value}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	j.write(ctx, entry)
+}
+
+func (j *Journal) exception(ctx context.Context, exception types.Exception, handling string) {
+	j.write(ctx, &JournalEntry{Type: "exception", Exception: exception.Exception(), Handling: handling})
+}
+
+// ReadJournal reads the entries of the journal written by WithJournal.
+func ReadJournal(r io.Reader) ([]*JournalEntry, error) {
+	var entries []*JournalEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		entry := &JournalEntry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+	return entries, nil
+}
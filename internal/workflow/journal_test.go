@@ -0,0 +1,122 @@
+package workflow_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(`
+main:
+  params: [args]
+  steps:
+    - assign:
+        assign:
+          - pass: ${args.password}
+    - login:
+        call: http.post
+        args:
+          url: https://auth.example.com/login
+          body:
+            user: ${args.user}
+        result: login
+    - secret:
+        call: http.get
+        args:
+          url: https://secretmanager.googleapis.com/v1/projects/p/secrets/s/versions/latest:access
+        result: secret
+    - done:
+        return: ${login.body.expires_in}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// serves the login and the secret without the network
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		header := http.Header{"Content-Type": {"application/json"}}
+		body := `{"payload":{"data":"c2VjcmV0LXBheWxvYWQ="}}`
+		if req.URL.Host == "auth.example.com" {
+			header.Set("Set-Cookie", "session=cookie-value")
+			body = `{"access_token":"access-token-value","expires_in":3600}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	})
+	client := &http.Client{Transport: defaults.ObserveHTTPInteractions(transport, workflow.JournalHTTPInteraction)}
+	args := map[string]any{"user": "alice", "password": "password-value"}
+	secrets := []string{"password-value", "access-token-value", "cookie-value", "c2VjcmV0LXBheWxvYWQ="}
+
+	for _, tt := range []struct {
+		name            string
+		redact          *regexp.Regexp
+		expectRedaction bool
+	}{
+		{
+			name:            "redacted",
+			redact:          regexp.MustCompile(`(?i)password|token|cookie`),
+			expectRedaction: true,
+		},
+		{
+			name: "not redacted",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			ret, err := root.Execute(context.Background(), args,
+				workflow.WithJournal(workflow.NewJournal(&buf, tt.redact)),
+				workflow.WithHTTPClient(client),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ret != float64(3600) {
+				t.Errorf("unexpected result: %v", ret)
+			}
+
+			journal := buf.String()
+			for _, secret := range secrets {
+				if strings.Contains(journal, secret) == tt.expectRedaction {
+					t.Errorf("%q is unexpectedly redacted or not redacted in the journal:\n%s", secret, journal)
+				}
+			}
+			for _, value := range []string{"alice", "expires_in", "3600"} {
+				if !strings.Contains(journal, value) {
+					t.Errorf("%q is unexpectedly redacted in the journal:\n%s", value, journal)
+				}
+			}
+
+			entries, err := workflow.ReadJournal(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var interactions int
+			for _, entry := range entries {
+				if entry.Type == "http" {
+					interactions++
+				}
+			}
+			if interactions != 2 {
+				t.Errorf("unexpected HTTP interactions: %d", interactions)
+			}
+		})
+	}
+}
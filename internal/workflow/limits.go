@@ -0,0 +1,133 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// Limits are the resource limits of an execution. The zero values mean no limits.
+type Limits struct {
+	MaxSteps         int // the number of the executed steps, ResourceLimitError is raised beyond it
+	MaxCallDepth     int // the depth of the calls of the subworkflows, RecursionError is raised beyond it
+	MaxVariablesSize int // the size in bytes of the variables visible from a step as JSON, ResourceLimitError is raised beyond it
+}
+
+// ProductionLimits are the resource limits of the production Workflows.
+// refs. https://cloud.google.com/workflows/quotas#resource_limit
+var ProductionLimits = Limits{
+	MaxSteps:         100000,
+	MaxCallDepth:     20,
+	MaxVariablesSize: 512 * 1024,
+}
+
+// WithLimits makes the executions raise the exceptions beyond the limits.
+func WithLimits(limits Limits) ExecuteOption {
+	return func(c *executeConfig) {
+		c.limits = limits
+	}
+}
+
+// StrictArgs makes the call steps raise TypeError for the named arguments which the functions don't have, which are
+// ignored by default.
+func StrictArgs(enabled bool) ExecuteOption {
+	return func(c *executeConfig) {
+		c.strictArgs = enabled
+	}
+}
+
+// countStep counts the executed step, and returns the error if it's beyond the limit.
+func (c *executeConfig) countStep() error {
+	if c.limits.MaxSteps == 0 {
+		return nil
+	}
+	if n := atomic.AddInt64(c.steps, 1); n > int64(c.limits.MaxSteps) {
+		return &types.Error{
+			Tag: types.ResourceLimitErrorTag,
+			Err: fmt.Errorf("the number of the executed steps exceeds the limit %d", c.limits.MaxSteps),
+		}
+	}
+	return nil
+}
+
+// checkVariablesSize returns the error if the variables visible from the scope are beyond the limit.
+func (c *executeConfig) checkVariablesSize(symbolTable *types.SymbolTable) error {
+	if c.limits.MaxVariablesSize == 0 {
+		return nil
+	}
+
+	size := variablesSize(visibleVariables(symbolTable))
+	if size > c.limits.MaxVariablesSize {
+		return &types.Error{
+			Tag: types.ResourceLimitErrorTag,
+			Err: fmt.Errorf("the size of the variables %d bytes exceeds the limit %d bytes", size, c.limits.MaxVariablesSize),
+		}
+	}
+	return nil
+}
+
+// variablesSize returns the size of the variables as a JSON object. The variables not serializable as JSON (e.g. the
+// functions) are not measured, so the others are still limited.
+func variablesSize(variables map[string]any) int {
+	size := len("{}")
+	measured := 0
+	for name, value := range variables {
+		b, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			continue
+		}
+		if measured > 0 {
+			size += len(",")
+		}
+		size += len(key) + len(":") + len(b)
+		measured++
+	}
+	return size
+}
+
+type callDepthKey struct{}
+
+// enterCall returns the context of the call of the subworkflow, or the error if it's deeper than the limit.
+func (c *executeConfig) enterCall(ctx context.Context) (context.Context, error) {
+	if c.limits.MaxCallDepth == 0 {
+		return ctx, nil
+	}
+
+	depth, _ := ctx.Value(callDepthKey{}).(int)
+	if depth >= c.limits.MaxCallDepth {
+		return nil, &types.Error{
+			Tag: types.RecursionErrorTag,
+			Err: fmt.Errorf("the depth of the calls exceeds the limit %d", c.limits.MaxCallDepth),
+		}
+	}
+	return context.WithValue(ctx, callDepthKey{}, depth+1), nil
+}
+
+// checkArgs returns the error of the named arguments which the function doesn't have in the strict mode.
+func (c *executeConfig) checkArgs(f types.Function, args map[string]any) error {
+	names := f.Args()
+	if !c.strictArgs || names == nil {
+		return nil
+	}
+
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+	for name := range args {
+		if !known[name] {
+			return &types.Error{
+				Tag: types.TypeErrorTag,
+				Err: fmt.Errorf("unexpected argument %q of %s", name, f.Name()),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	const loopWorkflow = `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - loop:
+        for:
+          value: v
+          in: [1, 2, 3]
+          steps:
+            - add:
+                assign:
+                  - total: ${total + v}
+    - done:
+        return: ${total}
+`
+	const recursionWorkflow = `
+main:
+  params: [args]
+  steps:
+    - call:
+        call: countdown
+        args:
+          n: 3
+        result: ret
+    - done:
+        return: ${ret}
+countdown:
+  params: [n]
+  steps:
+    - check:
+        switch:
+          - condition: ${n == 0}
+            return: ${0}
+    - next:
+        call: countdown
+        args:
+          n: ${n - 1}
+        result: ret
+    - done:
+        return: ${ret + 1}
+`
+	const variablesWorkflow = `
+main:
+  params: [args]
+  steps:
+    - assign:
+        assign:
+          - s: "0123456789012345678901234567890123456789"
+    - done:
+        return: ${len(s)}
+`
+	for _, tt := range []struct {
+		name             string
+		source           string
+		args             any
+		limits           workflow.Limits
+		expected         any
+		expectedErrorTag types.ErrorTag
+	}{
+		{
+			name:     "steps within the limit",
+			source:   loopWorkflow,
+			limits:   workflow.Limits{MaxSteps: 6},
+			expected: int64(6),
+		},
+		{
+			name:             "steps beyond the limit",
+			source:           loopWorkflow,
+			limits:           workflow.Limits{MaxSteps: 5},
+			expectedErrorTag: types.ResourceLimitErrorTag,
+		},
+		{
+			name:     "call depth within the limit",
+			source:   recursionWorkflow,
+			limits:   workflow.Limits{MaxCallDepth: 4},
+			expected: int64(3),
+		},
+		{
+			name:             "call depth beyond the limit",
+			source:           recursionWorkflow,
+			limits:           workflow.Limits{MaxCallDepth: 3},
+			expectedErrorTag: types.RecursionErrorTag,
+		},
+		{
+			name:     "variables within the limit",
+			source:   variablesWorkflow,
+			limits:   workflow.Limits{MaxVariablesSize: 64},
+			expected: int64(40),
+		},
+		{
+			name:             "variables beyond the limit",
+			source:           variablesWorkflow,
+			limits:           workflow.Limits{MaxVariablesSize: 32},
+			expectedErrorTag: types.ResourceLimitErrorTag,
+		},
+		{
+			name:             "variables beyond the limit with the variables not serializable as JSON",
+			source:           variablesWorkflow,
+			args:             map[string]any{"f": func() {}},
+			limits:           workflow.Limits{MaxVariablesSize: 32},
+			expectedErrorTag: types.ResourceLimitErrorTag,
+		},
+		{
+			name:     "no limits",
+			source:   variablesWorkflow,
+			args:     map[string]any{"f": func() {}},
+			expected: int64(40),
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := root.Execute(context.Background(), tt.args, workflow.WithLimits(tt.limits))
+			if tt.expectedErrorTag != "" {
+				var e *types.Error
+				if !errors.As(err, &e) || e.Tag != tt.expectedErrorTag {
+					t.Fatalf("should be %s but got %v", tt.expectedErrorTag, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,415 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/expression"
+	"github.com/samber/lo"
+)
+
+type lintScopeKind int
+
+const (
+	nestedScope lintScopeKind = iota // the nested steps, which pass the unknown next steps to the outer steps
+	loopScope                        // the steps of for, which accept break and continue
+	rootScope                        // the steps of a routine or a parallel branch, which accept end
+)
+
+// lintScope is the steps jumping to each other by their names.
+type lintScope struct {
+	kind   lintScopeKind
+	parent *lintScope
+	steps  []*lintStep
+	byName map[StepName]*lintStep
+}
+
+// resolve finds the step of the next step name from the innermost steps, or returns whether the name is a special one.
+func (s *lintScope) resolve(name StepName) (step *lintStep, ok bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if step, ok := scope.byName[name]; ok {
+			return step, true
+		}
+		switch scope.kind {
+		case loopScope:
+			return nil, name == "break" || name == "continue"
+		case rootScope:
+			return nil, name == "end"
+		}
+	}
+	return nil, false
+}
+
+type lintStep struct {
+	path     []StepName
+	scope    *lintScope
+	nested   []*lintScope
+	nexts    []StepName // the explicit next steps of the step and its switch conditions
+	terminal bool       // never falls through to the next step
+}
+
+func (v *validator) lintRoutine(name string, raw json.RawMessage) {
+	v.routine = name
+
+	var def struct {
+		Params []any             `json:"params"`
+		Steps  []json.RawMessage `json:"steps"`
+	}
+	if err := unmarshalJSONUseNumber(raw, &def); err != nil {
+		return
+	}
+	params, err := compileParams(def.Params)
+	if err != nil {
+		return
+	}
+
+	l := &variableLinter{validator: v, params: map[string]bool{}, defined: map[string]bool{}, reported: map[string]bool{}}
+	for _, param := range params {
+		if v.subworkflows[param.Name] {
+			v.warn(nil, fmt.Errorf("param %q shadows the subworkflow", param.Name))
+		} else if _, ok := defaults.DefaultSymbolTable.Get(param.Name); ok || param.Name == "experimental" {
+			v.warn(nil, fmt.Errorf("param %q shadows the standard library", param.Name))
+		}
+		l.params[param.Name] = true
+		l.defined[param.Name] = true
+	}
+
+	v.lintJumps(v.buildLintScope(nil, def.Steps, rootScope, nil))
+	l.lintSteps(nil, def.Steps)
+}
+
+func (v *validator) buildLintScope(path []StepName, stepsRaw []json.RawMessage, kind lintScopeKind, parent *lintScope) *lintScope {
+	scope := &lintScope{kind: kind, parent: parent, byName: map[StepName]*lintStep{}}
+	for _, raw := range stepsRaw {
+		var def workflowStepDef
+		if err := json.Unmarshal(raw, &def); err != nil {
+			continue
+		}
+
+		step := &lintStep{path: append(path[:len(path):len(path)], def.name), scope: scope}
+		step.terminal = v.collectJumps(step, def.stepDef)
+		scope.steps = append(scope.steps, step)
+		scope.byName[def.name] = step
+	}
+	return scope
+}
+
+// collectJumps collects the explicit next steps and the nested steps of the definition into the step, and returns
+// whether the definition never falls through to the next step.
+func (v *validator) collectJumps(step *lintStep, def anonymousStepDef) bool {
+	var next StepName
+	hasNext := json.Unmarshal(def["next"], &next) == nil
+	if hasNext {
+		step.nexts = append(step.nexts, next)
+	}
+
+	nested := decodeNestedSteps(def)
+	step.nested = append(step.nested,
+		v.buildLintScope(step.path, nested.Steps, nestedScope, step.scope),
+		v.buildLintScope(step.path, nested.Except.Steps, nestedScope, step.scope),
+		v.buildLintScope(step.path, nested.For.Steps, loopScope, step.scope),
+		v.buildLintScope(step.path, nested.Parallel.For.Steps, loopScope, step.scope),
+	)
+	for _, branch := range nested.Parallel.Branches {
+		for name, branchDef := range branch {
+			step.nested = append(step.nested, v.buildLintScope(append(step.path[:len(step.path):len(step.path)], name), branchDef.Steps, rootScope, nil))
+		}
+	}
+	if nested.Try != nil {
+		v.collectJumps(step, nested.Try)
+	}
+
+	// the switch never falls through if all of the conditions until the default one jump
+	exhaustive, jumps := false, true
+	for _, condition := range nested.Switch {
+		conditionJumps := v.collectJumps(step, condition)
+		if !exhaustive {
+			jumps = jumps && conditionJumps
+			exhaustive = isDefaultCondition(condition["condition"])
+		}
+	}
+
+	_, returns := def["return"]
+	_, raises := def["raise"]
+	return hasNext || returns || raises || (exhaustive && jumps)
+}
+
+func isDefaultCondition(raw json.RawMessage) bool {
+	var condition any
+	if err := json.Unmarshal(raw, &condition); err != nil {
+		return false
+	}
+	return condition == true || condition == "${true}"
+}
+
+// lintJumps reports the unknown next steps as the errors, and the steps never reached from the first step as the warnings.
+func (v *validator) lintJumps(root *lintScope) {
+	edges := map[*lintStep][]*lintStep{}
+	var collectEdges func(scope *lintScope)
+	collectEdges = func(scope *lintScope) {
+		for i, step := range scope.steps {
+			if !step.terminal && i+1 < len(scope.steps) {
+				edges[step] = append(edges[step], scope.steps[i+1])
+			}
+			for _, next := range step.nexts {
+				target, ok := scope.resolve(next)
+				if !ok {
+					v.report(step.path, fmt.Errorf("unknown next step %q", next))
+				} else if target != nil {
+					edges[step] = append(edges[step], target)
+				}
+			}
+			for _, nested := range step.nested {
+				if len(nested.steps) > 0 {
+					edges[step] = append(edges[step], nested.steps[0])
+				}
+				collectEdges(nested)
+			}
+		}
+	}
+	collectEdges(root)
+	if len(root.steps) == 0 {
+		return
+	}
+
+	reached := map[*lintStep]bool{root.steps[0]: true}
+	for queue := []*lintStep{root.steps[0]}; len(queue) > 0; queue = queue[1:] {
+		for _, next := range edges[queue[0]] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	// report the outermost unreachable steps only
+	var reportUnreachable func(scope *lintScope)
+	reportUnreachable = func(scope *lintScope) {
+		for _, step := range scope.steps {
+			if !reached[step] {
+				v.warn(step.path, fmt.Errorf("unreachable step"))
+				continue
+			}
+			for _, nested := range step.nested {
+				reportUnreachable(nested)
+			}
+		}
+	}
+	reportUnreachable(root)
+}
+
+// lintSubworkflows reports the subworkflows never called from main directly or indirectly as the warnings.
+func (v *validator) lintSubworkflows(routines map[string]json.RawMessage, names []string) {
+	references := map[string][]string{}
+	for name, raw := range routines {
+		var value any
+		if err := json.Unmarshal(raw, &value); err == nil {
+			references[name] = v.collectReferences(value, references[name])
+		}
+	}
+
+	called := map[string]bool{"main": true}
+	for queue := []string{"main"}; len(queue) > 0; queue = queue[1:] {
+		for _, name := range references[queue[0]] {
+			if !called[name] {
+				called[name] = true
+				queue = append(queue, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if !called[name] {
+			v.routine = name
+			v.warn(nil, fmt.Errorf("subworkflow is never called"))
+		}
+	}
+}
+
+// collectReferences collects the subworkflows called by the calls or the expressions in the value.
+func (v *validator) collectReferences(value any, references []string) []string {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, value := range value {
+			if name, ok := value.(string); ok && key == "call" && v.subworkflows[name] {
+				references = append(references, name)
+			}
+			references = v.collectReferences(value, references)
+		}
+	case []any:
+		for _, value := range value {
+			references = v.collectReferences(value, references)
+		}
+	case string:
+		if !expression.IsExpr(value) {
+			break
+		}
+		if expr, err := expression.ParseExpr(expression.TrimExprParen(value)); err == nil {
+			for _, name := range expr.Symbols() {
+				if v.subworkflows[name] {
+					references = append(references, name)
+				}
+			}
+		}
+	}
+	return references
+}
+
+// variableLinter reports the variables read before any assignment in the order of the source, and the params shadowed
+// by the variables (the values and the indexes) of the loops and the exceptions. The jumps are not followed to keep it simple.
+type variableLinter struct {
+	*validator
+	params   map[string]bool
+	defined  map[string]bool
+	reported map[string]bool
+}
+
+func (l *variableLinter) lintSteps(path []StepName, stepsRaw []json.RawMessage) {
+	for _, raw := range stepsRaw {
+		var def workflowStepDef
+		if err := json.Unmarshal(raw, &def); err == nil {
+			l.lintStep(append(path[:len(path):len(path)], def.name), def.stepDef)
+		}
+	}
+}
+
+func (l *variableLinter) lintStep(path []StepName, def anonymousStepDef) {
+	var fields struct {
+		Condition any              `json:"condition"`
+		Args      any              `json:"args"`
+		Result    string           `json:"result"`
+		Assign    []map[string]any `json:"assign"`
+		Return    any              `json:"return"`
+		Raise     any              `json:"raise"`
+		For       struct {
+			In any `json:"in"`
+		} `json:"for"`
+		Parallel struct {
+			Shared []string `json:"shared"`
+			For    struct {
+				In any `json:"in"`
+			} `json:"for"`
+		} `json:"parallel"`
+	}
+	decodeStepFields(def, &fields)
+	nested := decodeNestedSteps(def)
+
+	l.read(path, fields.Condition)
+	l.read(path, fields.Args)
+	for _, assign := range fields.Assign {
+		for key, value := range assign {
+			l.read(path, value)
+			l.assign(path, key)
+		}
+	}
+	l.read(path, fields.Return)
+	l.read(path, fields.Raise)
+
+	l.lintSteps(path, nested.Steps)
+	if nested.Try != nil {
+		l.lintStep(path, nested.Try)
+	}
+	l.scoped(path, "except as", nested.Except.As, func() {
+		l.lintSteps(path, nested.Except.Steps)
+	})
+	l.read(path, fields.For.In)
+	l.scoped(path, "for value", nested.For.Value, func() {
+		l.scoped(path, "for index", nested.For.Index, func() {
+			l.lintSteps(path, nested.For.Steps)
+		})
+	})
+	for _, name := range fields.Parallel.Shared {
+		l.readSymbol(path, name)
+	}
+	l.read(path, fields.Parallel.For.In)
+	l.scoped(path, "for value", nested.Parallel.For.Value, func() {
+		l.scoped(path, "for index", nested.Parallel.For.Index, func() {
+			l.lintSteps(path, nested.Parallel.For.Steps)
+		})
+	})
+	for _, branch := range nested.Parallel.Branches {
+		for name, branchDef := range branch {
+			l.lintSteps(append(path[:len(path):len(path)], name), branchDef.Steps)
+		}
+	}
+	for _, condition := range nested.Switch {
+		l.lintStep(path, condition)
+	}
+
+	if fields.Result != "" {
+		l.defined[fields.Result] = true
+	}
+}
+
+// scoped defines the variable only while linting the steps using it.
+func (l *variableLinter) scoped(path []StepName, kind, name string, lintSteps func()) {
+	if name == "" {
+		lintSteps()
+		return
+	}
+	if l.params[name] {
+		l.warn(path, fmt.Errorf("%s %q shadows the param", kind, name))
+	}
+	if l.defined[name] {
+		lintSteps()
+		return
+	}
+
+	l.defined[name] = true
+	lintSteps()
+	delete(l.defined, name)
+}
+
+func (l *variableLinter) assign(path []StepName, key string) {
+	expr, err := expression.ParseExpr(key)
+	if err != nil {
+		return
+	}
+	if expr.IsSymbol() {
+		l.defined[key] = true
+		return
+	}
+
+	// the fields and the indexes of the variable are assigned to the existing variable
+	for _, name := range expr.Symbols() {
+		l.readSymbol(path, name)
+	}
+}
+
+func (l *variableLinter) read(path []StepName, value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		keys := lo.Keys(value)
+		sort.Strings(keys) // to report in the stable order
+		for _, key := range keys {
+			l.read(path, value[key])
+		}
+	case []any:
+		for _, value := range value {
+			l.read(path, value)
+		}
+	case string:
+		if !expression.IsExpr(value) {
+			break
+		}
+		if expr, err := expression.ParseExpr(expression.TrimExprParen(value)); err == nil {
+			for _, name := range expr.Symbols() {
+				l.readSymbol(path, name)
+			}
+		}
+	}
+}
+
+func (l *variableLinter) readSymbol(path []StepName, name string) {
+	if l.defined[name] || l.reported[name] || l.subworkflows[name] || name == "experimental" {
+		return
+	}
+	if _, ok := defaults.DefaultSymbolTable.Get(name); ok {
+		return
+	}
+
+	l.reported[name] = true
+	l.warn(path, fmt.Errorf("variable %q is read before any assignment", name))
+}
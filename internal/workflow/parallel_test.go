@@ -0,0 +1,579 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestParallelStep(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name                 string
+		source               string
+		opts                 []workflow.ExecuteOption
+		expected             any
+		expectedErrorTag     types.ErrorTag
+		expectToBeParseErr   bool
+		expectToBeExecuteErr bool
+	}{
+		{
+			name: "branches with shared variables",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - a: 0
+          - b: 0
+    - parallel:
+        parallel:
+          shared: [a, b]
+          branches:
+            - first:
+                steps:
+                  - assignA:
+                      assign:
+                        - a: 1
+            - second:
+                steps:
+                  - assignB:
+                      assign:
+                        - b: 2
+    - done:
+        return: ${a * 10 + b}
+`,
+			expected: int64(12),
+		},
+		{
+			name: "branches without shared variables",
+			source: `
+main:
+  steps:
+    - parallel:
+        parallel:
+          branches:
+            - first:
+                steps:
+                  - assign:
+                      assign:
+                        - a: 1
+            - second:
+                steps:
+                  - assign:
+                      assign:
+                        - a: 2
+    - done:
+        return: ok
+`,
+			expected: "ok",
+		},
+		{
+			name: "assign to non-shared variable",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - a: 0
+    - parallel:
+        parallel:
+          branches:
+            - first:
+                steps:
+                  - assign:
+                      assign:
+                        - a: 1
+            - second:
+                steps:
+                  - done:
+                      next: end
+`,
+			expectToBeExecuteErr: true,
+		},
+		{
+			name: "unhandled exception in branches",
+			source: `
+main:
+  steps:
+    - parallel:
+        parallel:
+          branches:
+            - first:
+                steps:
+                  - raise:
+                      raise: boom
+            - second:
+                steps:
+                  - done:
+                      return: 1
+`,
+			expectedErrorTag: types.UnhandledBranchErrorTag,
+		},
+		{
+			name: "parallel for with shared variables",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - parallel:
+        parallel:
+          shared: [total]
+          for:
+            value: v
+            in: [1, 2, 3, 4]
+            steps:
+              - add:
+                  assign:
+                    - total: ${total + v}
+    - done:
+        return: ${total}
+`,
+			expected: int64(10),
+		},
+		{
+			name: "break in parallel for",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - parallel:
+        parallel:
+          shared: [total]
+          for:
+            value: v
+            in: [1, 2, 3]
+            steps:
+              - check:
+                  switch:
+                    - condition: ${v == 1}
+                      next: break
+              - wait:
+                  call: sys.sleep
+                  args:
+                    seconds: 0.2
+              - add:
+                  assign:
+                    - total: ${total + v}
+    - done:
+        return: ${total}
+`,
+			expected: int64(0),
+		},
+		{
+			name: "continue in parallel for",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - parallel:
+        parallel:
+          shared: [total]
+          for:
+            value: v
+            in: [1, 2, 3]
+            steps:
+              - check:
+                  switch:
+                    - condition: ${v == 1}
+                      next: continue
+              - add:
+                  assign:
+                    - total: ${total + v}
+    - done:
+        return: ${total}
+`,
+			expected: int64(5),
+		},
+		{
+			name: "nested parallel steps",
+			source: `
+main:
+  steps:
+    - outer:
+        parallel:
+          for:
+            value: i
+            in: [1, 2]
+            steps:
+              - inner:
+                  parallel:
+                    for:
+                      value: j
+                      in: [1, 2]
+                      steps:
+                        - noop:
+                            assign:
+                              - k: ${i + j}
+    - done:
+        return: ok
+`,
+			expected: "ok",
+		},
+		{
+			name: "too deeply nested parallel steps",
+			source: `
+main:
+  steps:
+    - outer:
+        parallel:
+          for:
+            value: i
+            in: [1]
+            steps:
+              - inner:
+                  parallel:
+                    for:
+                      value: j
+                      in: [1]
+                      steps:
+                        - innermost:
+                            parallel:
+                              for:
+                                value: k
+                                in: [1]
+                                steps:
+                                  - noop:
+                                      assign:
+                                        - l: 1
+`,
+			expectedErrorTag: types.UnhandledBranchErrorTag,
+		},
+		{
+			name: "too deeply nested parallel steps in subworkflow",
+			source: `
+main:
+  steps:
+    - outer:
+        parallel:
+          for:
+            value: i
+            in: [1]
+            steps:
+              - inner:
+                  parallel:
+                    for:
+                      value: j
+                      in: [1]
+                      steps:
+                        - call:
+                            call: sub
+sub:
+  steps:
+    - innermost:
+        parallel:
+          for:
+            value: k
+            in: [1]
+            steps:
+              - noop:
+                  assign:
+                    - l: 1
+`,
+			expectedErrorTag: types.UnhandledBranchErrorTag,
+		},
+		{
+			name: "parallel step in subworkflow",
+			source: `
+main:
+  steps:
+    - call:
+        call: sub
+        result: r
+    - done:
+        return: ${r}
+sub:
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - parallel:
+        parallel:
+          shared: [total]
+          for:
+            value: k
+            in: [1, 2]
+            steps:
+              - add:
+                  assign:
+                    - total: ${total + k}
+    - done:
+        return: ${total}
+`,
+			expected: int64(3),
+		},
+		{
+			name: "undeclared shared variable",
+			source: `
+main:
+  steps:
+    - parallel:
+        parallel:
+          shared: [total]
+          for:
+            value: v
+            in: [1, 2]
+            steps:
+              - add:
+                  assign:
+                    - total: ${v}
+`,
+			expectToBeExecuteErr: true,
+		},
+		{
+			name: "shared variable declared twice",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - parallel:
+        parallel:
+          shared: [total, total]
+          for:
+            value: v
+            in: [1, 2]
+            steps:
+              - add:
+                  assign:
+                    - total: ${v}
+`,
+			expectToBeParseErr: true,
+		},
+		{
+			name: "shared variables in nested steps",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - total: 0
+          - results: {}
+          - errors: 0
+    - parallel:
+        parallel:
+          shared: [total, results, errors]
+          for:
+            value: v
+            in: [1, 2, 3]
+            steps:
+              - block:
+                  steps:
+                    - add:
+                        assign:
+                          - total: ${total + v}
+                    - check:
+                        switch:
+                          - condition: ${v == 2}
+                            steps:
+                              - convert:
+                                  call: double
+                                  args:
+                                    attribute: ${v}
+                                  result: d
+                              - store:
+                                  assign:
+                                    - results.two: ${d}
+              - fail:
+                  try:
+                    raise: boom
+                  except:
+                    as: e
+                    steps:
+                      - count:
+                          assign:
+                            - errors: ${errors + 1}
+    - done:
+        return: ${total * 100 + results.two * 10 + errors}
+`,
+			expected: float64(623),
+		},
+		{
+			name: "shared variables in nested parallel steps",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - outer:
+        parallel:
+          shared: [total]
+          branches:
+            - first:
+                steps:
+                  - inner:
+                      parallel:
+                        shared: [total]
+                        for:
+                          value: v
+                          in: [1, 2]
+                          steps:
+                            - wait_outer:
+                                call: sys.sleep
+                                args:
+                                  seconds: 0.2
+                            - add:
+                                assign:
+                                  - total: ${total + v}
+            - second:
+                steps:
+                  - wait_inner:
+                      call: sys.sleep
+                      args:
+                        seconds: 0.05
+                  - add:
+                      assign:
+                        - total: ${total + 10}
+    - done:
+        return: ${total}
+`,
+			expected: int64(13),
+		},
+		{
+			name: "serialized parallel for",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - results: []
+    - parallel:
+        parallel:
+          shared: [results]
+          for:
+            value: v
+            in: [1, 2, 3, 4, 5]
+            steps:
+              - append:
+                  assign:
+                    - results: ${list.concat(results, v)}
+    - done:
+        return: ${results}
+`,
+			opts:     []workflow.ExecuteOption{workflow.SerializeParallel(true)},
+			expected: []any{int64(1), int64(2), int64(3), int64(4), int64(5)},
+		},
+		{
+			name: "serialized parallel branches",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - results: []
+    - parallel:
+        parallel:
+          shared: [results]
+          branches:
+            - first:
+                steps:
+                  - wait:
+                      call: sys.sleep
+                      args:
+                        seconds: 0.1
+                  - append:
+                      assign:
+                        - results: ${list.concat(results, "first")}
+            - second:
+                steps:
+                  - append:
+                      assign:
+                        - results: ${list.concat(results, "second")}
+    - done:
+        return: ${results}
+`,
+			opts:     []workflow.ExecuteOption{workflow.SerializeParallel(true)},
+			expected: []any{"first", "second"},
+		},
+		{
+			name: "single branch",
+			source: `
+main:
+  steps:
+    - parallel:
+        parallel:
+          branches:
+            - first:
+                steps:
+                  - done:
+                      return: 1
+`,
+			expectToBeParseErr: true,
+		},
+		{
+			name: "duplicated branch name",
+			source: `
+main:
+  steps:
+    - parallel:
+        parallel:
+          branches:
+            - first:
+                steps:
+                  - done:
+                      return: 1
+            - first:
+                steps:
+                  - done:
+                      return: 1
+`,
+			expectToBeParseErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				if tt.expectToBeParseErr {
+					t.Logf("expected parse error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectToBeParseErr {
+				t.Fatal("should be parse error")
+			}
+
+			ret, err := root.Execute(context.Background(), nil, tt.opts...)
+			if err != nil {
+				if tt.expectToBeExecuteErr {
+					t.Logf("expected execute error: %v", err)
+					return
+				}
+
+				var e *types.Error
+				if tt.expectedErrorTag != "" && errors.As(err, &e) && e.Tag == tt.expectedErrorTag {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectToBeExecuteErr {
+				t.Fatal("should be execute error")
+			}
+			if tt.expectedErrorTag != "" {
+				t.Fatalf("should be %s", tt.expectedErrorTag)
+			}
+
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
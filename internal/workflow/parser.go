@@ -20,11 +20,30 @@ func ParseWorkflowYAML(r io.Reader) (WorkflowRoot, error) {
 		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
 	}
 
-	return ParseWorkflowJSON(bytes.NewReader(jsonBytes))
+	root, err := parseWorkflowJSON(jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	root.attachStepPositions(yamlBytes)
+	return root, nil
 }
 
 func ParseWorkflowJSON(r io.Reader) (WorkflowRoot, error) {
-	decoder := json.NewDecoder(r)
+	jsonBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	root, err := parseWorkflowJSON(jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	root.attachStepPositions(jsonBytes)
+	return root, nil
+}
+
+func parseWorkflowJSON(jsonBytes []byte) (WorkflowRoot, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
 	decoder.UseNumber()
 
 	var root workflowRootDef
@@ -0,0 +1,67 @@
+package workflow
+
+import (
+	"context"
+	"sync"
+)
+
+// StepGate holds the steps of an execution at the step boundaries while it's paused, e.g. to debug the long executions.
+// The zero value is not paused.
+type StepGate struct {
+	mu      sync.Mutex
+	resumed chan struct{} // closed on resume, or nil if not paused
+}
+
+// WithStepGate makes the steps of the execution wait for the gate to be resumed before they start.
+func WithStepGate(gate *StepGate) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stepGate = gate
+	}
+}
+
+// Pause makes the steps wait from the next step boundary. It returns false if it's already paused.
+func (g *StepGate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// Resume releases the waiting steps. It returns false if it's not paused.
+func (g *StepGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+// Paused reports whether the gate is paused.
+func (g *StepGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// wait waits for the gate to be resumed, or the context to be done.
+func (g *StepGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,177 @@
+package workflow
+
+// expressionPattern matches the expressions like ${a + b}.
+const expressionPattern = `^\$\{[\s\S]*\}$`
+
+// JSONSchema returns the JSON Schema (draft-07) of the syntax supported by the emulator, e.g. for the editors to
+// validate the workflow files. The fields not supported by the emulator are rejected as the additional properties.
+// refs. https://cloud.google.com/workflows/docs/reference/syntax
+func JSONSchema() map[string]any {
+	stepProperties := map[string]any{
+		"call":   map[string]any{"type": "string", "description": "Function of the standard library or the connectors, or the subworkflow to call"},
+		"args":   map[string]any{"type": []string{"object", "array"}, "description": "Arguments of the call"},
+		"result": map[string]any{"type": "string", "description": "Variable to store the result of the call"},
+		"assign": map[string]any{
+			"type":        "array",
+			"description": "Assignments of the variables in order",
+			"items":       map[string]any{"type": "object", "minProperties": 1, "maxProperties": 1},
+		},
+		"switch": map[string]any{
+			"type":        "array",
+			"description": "Conditions evaluated in order, and the step of the first true one is executed",
+			"minItems":    1,
+			"items":       schemaRef("switchCondition"),
+		},
+		"next":     map[string]any{"type": "string", "description": "Step to jump to, or end, break and continue"},
+		"return":   map[string]any{"description": "Value to return from the routine"},
+		"raise":    map[string]any{"description": "Exception to raise (a string, a map or an expression)"},
+		"steps":    schemaRef("steps"),
+		"try":      schemaRef("step"),
+		"retry":    map[string]any{"oneOf": []any{schemaRef("expression"), schemaRef("retryPolicy")}},
+		"except":   schemaRef("except"),
+		"for":      schemaRef("for"),
+		"parallel": schemaRef("parallel"),
+	}
+	switchConditionProperties := map[string]any{
+		"condition": map[string]any{
+			"description": "Expression, or true for the last default condition",
+			"oneOf":       []any{schemaRef("expression"), map[string]any{"enum": []any{true}}},
+		},
+	}
+	for name, property := range stepProperties {
+		switchConditionProperties[name] = property
+	}
+	stepDependencies := map[string]any{
+		"args":   []string{"call"},
+		"result": []string{"call"},
+		"retry":  []string{"try"},
+		"except": []string{"try"},
+	}
+
+	return map[string]any{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "Workflow supported by google-cloud-workflow-emulator",
+		"type":                 "object",
+		"required":             []string{"main"},
+		"description":          "Routines of the workflow by their names, the main workflow and the subworkflows",
+		"additionalProperties": schemaRef("routine"),
+		"definitions": map[string]any{
+			"expression": map[string]any{"type": "string", "pattern": expressionPattern},
+			"routine": map[string]any{
+				"type":     "object",
+				"required": []string{"steps"},
+				"properties": map[string]any{
+					"params": map[string]any{
+						"type":        "array",
+						"description": "Names of the params, or the single key maps of the names to the default values (main can have a single param only)",
+						"items": map[string]any{"oneOf": []any{
+							map[string]any{"type": "string"},
+							map[string]any{"type": "object", "minProperties": 1, "maxProperties": 1},
+						}},
+					},
+					"steps": schemaRef("steps"),
+				},
+				"additionalProperties": false,
+			},
+			"steps": map[string]any{
+				"type":     "array",
+				"minItems": 1,
+				"items": map[string]any{
+					"type":                 "object",
+					"description":          "Step of the name",
+					"minProperties":        1,
+					"maxProperties":        1,
+					"propertyNames":        map[string]any{"not": map[string]any{"enum": []string{"end"}}},
+					"additionalProperties": schemaRef("step"),
+				},
+			},
+			"step": map[string]any{
+				"type":                 "object",
+				"minProperties":        1,
+				"properties":           stepProperties,
+				"additionalProperties": false,
+				"dependencies":         stepDependencies,
+				"not":                  map[string]any{"required": []string{"return", "raise"}},
+			},
+			"switchCondition": map[string]any{
+				"type":                 "object",
+				"required":             []string{"condition"},
+				"minProperties":        2,
+				"properties":           switchConditionProperties,
+				"additionalProperties": false,
+				"dependencies":         stepDependencies,
+			},
+			"retryPolicy": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"predicate":   schemaRef("expression"),
+					"max_retries": map[string]any{"type": "integer", "minimum": 0},
+					"backoff": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"initial_delay": map[string]any{"type": "number", "description": "Seconds"},
+							"max_delay":     map[string]any{"type": "number", "description": "Seconds"},
+							"multiplier":    map[string]any{"type": "number"},
+						},
+						"additionalProperties": false,
+					},
+				},
+				"additionalProperties": false,
+			},
+			"except": map[string]any{
+				"type":     "object",
+				"required": []string{"as", "steps"},
+				"properties": map[string]any{
+					"as":    map[string]any{"type": "string", "description": "Variable to store the exception"},
+					"steps": schemaRef("steps"),
+				},
+				"additionalProperties": false,
+			},
+			"for": map[string]any{
+				"type":        "object",
+				"description": "Loop over the list (index and range are not supported by the emulator)",
+				"required":    []string{"value", "in", "steps"},
+				"properties": map[string]any{
+					"value": map[string]any{"type": "string"},
+					"in":    map[string]any{"oneOf": []any{map[string]any{"type": "array"}, schemaRef("expression")}},
+					"steps": schemaRef("steps"),
+				},
+				"additionalProperties": false,
+			},
+			"parallel": map[string]any{
+				"type":        "object",
+				"description": "Branches or the loop executed in parallel (concurrency_limit is not supported by the emulator)",
+				"properties": map[string]any{
+					"exception_policy": map[string]any{"enum": []string{"continueAll"}},
+					"shared":           map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"for":              schemaRef("for"),
+					"branches": map[string]any{
+						"type":     "array",
+						"minItems": 2,
+						"items": map[string]any{
+							"type":          "object",
+							"description":   "Branch of the name",
+							"minProperties": 1,
+							"maxProperties": 1,
+							"additionalProperties": map[string]any{
+								"type":                 "object",
+								"required":             []string{"steps"},
+								"properties":           map[string]any{"steps": schemaRef("steps")},
+								"additionalProperties": false,
+							},
+						},
+					},
+				},
+				"oneOf": []any{
+					map[string]any{"required": []string{"for"}},
+					map[string]any{"required": []string{"branches"}},
+				},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/definitions/" + name}
+}
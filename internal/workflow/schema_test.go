@@ -0,0 +1,307 @@
+package workflow_test
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+// schemaValidator validates the documents by the keywords of JSON Schema used by workflow.JSONSchema.
+type schemaValidator struct {
+	definitions map[string]any
+}
+
+func (v *schemaValidator) validate(schema map[string]any, value any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		definition, ok := v.definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unresolved $ref %s", path, ref)
+		}
+		return v.validate(definition, value, path)
+	}
+
+	if t, ok := schema["type"]; ok {
+		types, ok := t.([]any)
+		if !ok {
+			types = []any{t}
+		}
+		actual := schemaType(value)
+		if !lo.ContainsBy(types, func(t any) bool { return t == actual || (t == "number" && actual == "integer") }) {
+			return fmt.Errorf("%s: %s is not %v", path, actual, t)
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && !lo.ContainsBy(enum, func(e any) bool { return e == value }) {
+		return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, ok := value.(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			return fmt.Errorf("%s: %q doesn't match %s", path, s, pattern)
+		}
+	}
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if n, ok := value.(float64); ok && n < minimum {
+			return fmt.Errorf("%s: %v is less than %v", path, n, minimum)
+		}
+	}
+	if not, ok := schema["not"].(map[string]any); ok && v.validate(not, value, path) == nil {
+		return fmt.Errorf("%s: matches the schema of not", path)
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		matched := lo.CountBy(oneOf, func(s any) bool { return v.validate(s.(map[string]any), value, path) == nil })
+		if matched != 1 {
+			return fmt.Errorf("%s: matches %d schemas of oneOf", path, matched)
+		}
+	}
+
+	switch value := value.(type) {
+	case []any:
+		if minItems, ok := schema["minItems"].(float64); ok && float64(len(value)) < minItems {
+			return fmt.Errorf("%s: fewer items than %v", path, minItems)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		if minProperties, ok := schema["minProperties"].(float64); ok && float64(len(value)) < minProperties {
+			return fmt.Errorf("%s: fewer properties than %v", path, minProperties)
+		}
+		if maxProperties, ok := schema["maxProperties"].(float64); ok && float64(len(value)) > maxProperties {
+			return fmt.Errorf("%s: more properties than %v", path, maxProperties)
+		}
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := value[name.(string)]; !ok {
+					return fmt.Errorf("%s: %s is required", path, name)
+				}
+			}
+		}
+		if dependencies, ok := schema["dependencies"].(map[string]any); ok {
+			for name, required := range dependencies {
+				if _, ok := value[name]; !ok {
+					continue
+				}
+				for _, dependency := range required.([]any) {
+					if _, ok := value[dependency.(string)]; !ok {
+						return fmt.Errorf("%s: %s requires %s", path, name, dependency)
+					}
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		keys := lo.Keys(value)
+		sort.Strings(keys)
+		for _, name := range keys {
+			if propertyNames, ok := schema["propertyNames"].(map[string]any); ok {
+				if err := v.validate(propertyNames, name, path+"."+name); err != nil {
+					return err
+				}
+			}
+			property, ok := properties[name].(map[string]any)
+			if !ok {
+				switch additional := schema["additionalProperties"].(type) {
+				case bool:
+					if !additional {
+						return fmt.Errorf("%s: unknown property %s", path, name)
+					}
+					continue
+				case map[string]any:
+					property = additional
+				default:
+					continue
+				}
+			}
+			if err := v.validate(property, value[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func schemaType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	t.Parallel()
+
+	// the schema is used as JSON by the editors
+	b, err := json.Marshal(workflow.JSONSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+	v := &schemaValidator{definitions: schema["definitions"].(map[string]any)}
+
+	for _, tt := range []struct {
+		name    string
+		source  string
+		wantErr bool
+	}{
+		{
+			name: "steps of all kinds",
+			source: `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - x: 1
+    - get:
+        try:
+          call: http.get
+          args:
+            url: https://example.com
+          result: res
+        retry:
+          predicate: ${http.default_retry_predicate}
+          max_retries: 3
+          backoff:
+            initial_delay: 1
+            max_delay: 10
+            multiplier: 2
+        except:
+          as: e
+          steps:
+            - failed:
+                raise: ${e}
+    - check:
+        switch:
+          - condition: ${x > 1}
+            next: loop
+          - condition: true
+            assign:
+              - x: 2
+    - loop:
+        for:
+          value: v
+          in: ${args.list}
+          steps:
+            - skip:
+                next: continue
+    - fanout:
+        parallel:
+          shared: [x]
+          branches:
+            - b1:
+                steps:
+                  - one:
+                      call: sub
+                      args:
+                        a: 1
+            - b2:
+                steps:
+                  - two:
+                      return: 2
+    - done:
+        return: ${x}
+sub:
+  params: [a, b: 2]
+  steps:
+    - r:
+        return: ${a + b}
+`,
+		},
+		{
+			name:    "missing main",
+			source:  "sub:\n  steps:\n    - r:\n        return: 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported field of the step",
+			source:  "main:\n  steps:\n    - s:\n        unknown_field: 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "return with raise",
+			source:  "main:\n  steps:\n    - s:\n        return: 1\n        raise: x\n",
+			wantErr: true,
+		},
+		{
+			name:    "end as a step name",
+			source:  "main:\n  steps:\n    - end:\n        return: 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "result without call",
+			source:  "main:\n  steps:\n    - s:\n        result: r\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative max_retries",
+			source:  "main:\n  steps:\n    - s:\n        try:\n          return: 1\n        retry:\n          predicate: ${true}\n          max_retries: -1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported range of for",
+			source:  "main:\n  steps:\n    - l:\n        for:\n          value: v\n          range: [1, 2]\n          steps:\n            - s:\n                return: 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported concurrency_limit of parallel",
+			source:  "main:\n  steps:\n    - p:\n        parallel:\n          concurrency_limit: 2\n          for:\n            value: v\n            in: [1]\n            steps:\n              - s:\n                  return: 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "condition not an expression",
+			source:  "main:\n  steps:\n    - s:\n        switch:\n          - condition: x > 1\n            return: 1\n",
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			jsonBytes, err := yaml.YAMLToJSON([]byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc any
+			if err := json.Unmarshal(jsonBytes, &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			err = v.validate(schema, doc, "$")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// Position is the position of a step name in the source of the workflow.
+type Position struct {
+	Line   int
+	Column int
+	Length int
+}
+
+// StackTraceElement is a frame of the routine (the main workflow or a subworkflow) running the failed step.
+type StackTraceElement struct {
+	Step     StepName
+	Routine  string
+	Position *Position // nil if unknown
+}
+
+// stepError is the error of the step, which keeps the step name to build the stack trace.
+type stepError struct {
+	workflow *Workflow // of the step directly in the routine, or nil for the nested steps
+	step     StepName
+	err      error
+}
+
+func (e *stepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.step, e.err)
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+// StackTrace returns the frames of the routines running the failed step of the error returned by the execution,
+// the innermost routine first.
+func StackTrace(err error) []StackTraceElement {
+	var frames []StackTraceElement
+	var workflow *Workflow
+	for ; err != nil; err = errors.Unwrap(err) {
+		e, ok := err.(*stepError)
+		if !ok {
+			continue
+		}
+
+		if e.workflow != nil {
+			workflow = e.workflow
+			frames = append(frames, StackTraceElement{Routine: workflow.Name})
+		} else if workflow == nil {
+			continue // unreachable
+		}
+
+		// the innermost step in the routine
+		frame := &frames[len(frames)-1]
+		frame.Step = e.step
+		frame.Position = workflow.positions[e.step]
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// attachStepPositions records the positions of the steps in the source of the workflow to the routines for the stack traces.
+// The positions are unknown if the source is not parsable as YAML (or JSON as a subset of it).
+func (r WorkflowRoot) attachStepPositions(source []byte) {
+	file, err := parser.ParseBytes(source, 0)
+	if err != nil || len(file.Docs) == 0 {
+		return
+	}
+
+	for _, routine := range mappingValues(file.Docs[0].Body) {
+		wf, ok := r[routine.Key.GetToken().Value]
+		if !ok {
+			continue
+		}
+
+		positions := stepPositionVisitor{}
+		ast.Walk(positions, routine.Value)
+		wf.positions = positions
+	}
+}
+
+func mappingValues(node ast.Node) []*ast.MappingValueNode {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}
+	default:
+		return nil
+	}
+}
+
+// stepPositionVisitor collects the positions of the step names, which are the keys of the single key mappings in the sequences.
+// The first one is taken if the step names are duplicated in the nested steps.
+type stepPositionVisitor map[StepName]*Position
+
+func (v stepPositionVisitor) Visit(node ast.Node) ast.Visitor {
+	seq, ok := node.(*ast.SequenceNode)
+	if !ok {
+		return v
+	}
+
+	for _, item := range seq.Values {
+		values := mappingValues(item)
+		if len(values) != 1 {
+			continue
+		}
+
+		tk := values[0].Key.GetToken()
+		if _, ok := v[StepName(tk.Value)]; !ok {
+			v[StepName(tk.Value)] = &Position{Line: tk.Position.Line, Column: tk.Position.Column, Length: len(tk.Value)}
+		}
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// StepHook is called before each step (including the nested ones) is executed, e.g. to inspect the variables.
+// The execution is aborted with the error if it returns an error. It is called concurrently by the branches of the
+// parallel steps unless they are serialized.
+type StepHook func(ctx context.Context, state *StepState) error
+
+// StepState is the state of the execution before a step.
+type StepState struct {
+	Routine string
+	Path    []string // the steps containing the step and the step
+	Step    StepName
+
+	symbolTable *types.SymbolTable
+}
+
+// Variables returns the variables visible from the step, except for the standard library and the subworkflows.
+// The values are shared with the execution, so they must not be modified.
+func (s *StepState) Variables() map[string]any {
+	return visibleVariables(s.symbolTable)
+}
+
+// SetVariable sets the value of the variable visible from the step, or defines the variable in the scope of the step.
+// The value is decoded from JSON, whose integers are int64 and the other numbers are float64 as the assign steps.
+func (s *StepState) SetVariable(name string, valueJSON []byte) error {
+	if strings.HasPrefix(name, "__INTERNAL_") {
+		return fmt.Errorf("cannot set the internal variable: %s", name)
+	}
+
+	var value any
+	if err := unmarshalJSONUseNumber(valueJSON, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	value, err := decodeJSONNumberRecursive(value)
+	if err != nil {
+		return fmt.Errorf("invalid number: %w", err)
+	}
+
+	for st := s.symbolTable; st != nil && !st.ReadOnly; st = st.Parent {
+		current, ok := st.Symbols[name]
+		if !ok {
+			continue
+		}
+		if shared, ok := current.(*types.SharedVariable); ok {
+			shared.Lock()
+			defer shared.Unlock()
+			shared.Value = value
+			return nil
+		}
+		st.Symbols[name] = value
+		return nil
+	}
+	s.symbolTable.Symbols[name] = value
+	return nil
+}
+
+// WithStepHook makes the execution call the hook before each step.
+func WithStepHook(hook StepHook) ExecuteOption {
+	return func(c *executeConfig) {
+		c.hooks = append(c.hooks, stepHook{hook: hook})
+	}
+}
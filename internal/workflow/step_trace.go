@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// stepTracer writes a JSON line per executed step with the routine, the path of the nested steps, the duration, the
+// next step and the names of the changed variables of it, e.g. to see the control flow of an execution.
+type stepTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// WithStepTrace makes the execution write a JSON line per executed step into w when the step is finished.
+// The nested steps are written before the steps containing them.
+func WithStepTrace(w io.Writer) ExecuteOption {
+	return func(c *executeConfig) {
+		c.stepTracer = &stepTracer{w: w}
+	}
+}
+
+// stepTraceEntry is the line of the executed step.
+type stepTraceEntry struct {
+	Routine  string   `json:"routine"`
+	Path     []string `json:"path"` // the steps containing the step (including the calls of the subworkflows) and the step
+	Step     StepName `json:"step"`
+	Duration string   `json:"duration"`
+	Next     StepName `json:"next,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Changed  []string `json:"changed"`
+}
+
+type stepPathKey struct{}
+
+type stepRoutineKey struct{}
+
+// withStepRoutine returns the context of the steps executed in the routine.
+func withStepRoutine(ctx context.Context, routine string) context.Context {
+	return context.WithValue(ctx, stepRoutineKey{}, routine)
+}
+
+// withStepPath returns the context of the step and the steps nested in it.
+func withStepPath(ctx context.Context, name StepName) context.Context {
+	parent := stepPathFromContext(ctx)
+	return context.WithValue(ctx, stepPathKey{}, append(parent[:len(parent):len(parent)], string(name)))
+}
+
+// stepPathFromContext returns the steps containing the current step and the current step.
+func stepPathFromContext(ctx context.Context) []string {
+	path, _ := ctx.Value(stepPathKey{}).([]string)
+	return path
+}
+
+func stepRoutineFromContext(ctx context.Context) string {
+	routine, _ := ctx.Value(stepRoutineKey{}).(string)
+	return routine
+}
+
+// start starts tracing the step in the context given by withStepPath, and returns the function to finish it.
+func (t *stepTracer) start(ctx context.Context, name StepName, symbolTable *types.SymbolTable) func(next StepName, err error) {
+	path := stepPathFromContext(ctx)
+	routine := stepRoutineFromContext(ctx)
+	before := snapshotVariables(symbolTable)
+	startedAt := time.Now()
+
+	return func(next StepName, err error) {
+		entry := &stepTraceEntry{
+			Routine:  routine,
+			Path:     path,
+			Step:     name,
+			Duration: time.Since(startedAt).String(),
+			Changed:  changedVariables(before, snapshotVariables(symbolTable)),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Next = next
+		}
+		t.write(entry)
+	}
+}
+
+func (t *stepTracer) write(entry *stepTraceEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line = []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.w.Write(append(line, '\n')); err != nil {
+		logging.Logf(logging.LevelError, "failed to write step trace", "error", err)
+	}
+}
+
+// snapshotVariables returns the JSON of the variables visible from the scope (except for the read-only scopes of the
+// standard library and the subworkflows) to compare them after the step, because the lists and the maps can be modified
+// in place.
+func snapshotVariables(symbolTable *types.SymbolTable) map[string]string {
+	variables := visibleVariables(symbolTable)
+	snapshot := make(map[string]string, len(variables))
+	for name, value := range variables {
+		b, err := json.Marshal(value)
+		if err != nil {
+			snapshot[name] = fmt.Sprintf("%#v", value)
+			continue
+		}
+		snapshot[name] = string(b)
+	}
+	return snapshot
+}
+
+// changedVariables returns the sorted names of the variables assigned or modified between the snapshots.
+func changedVariables(before, after map[string]string) []string {
+	changed := []string{}
+	for name, value := range after {
+		if v, ok := before[name]; !ok || v != value {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
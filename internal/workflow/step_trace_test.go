@@ -0,0 +1,143 @@
+package workflow_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// stepTraceLine is the line of WithStepTrace except for the duration.
+type stepTraceLine struct {
+	Routine string   `json:"routine"`
+	Path    []string `json:"path"`
+	Step    string   `json:"step"`
+	Next    string   `json:"next,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Changed []string `json:"changed"`
+}
+
+func TestStepTrace(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		source   string
+		expected []stepTraceLine
+		wantErr  bool
+	}{
+		{
+			name: "assignments and jumps",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - a: 1
+          - b: 2
+        next: update
+    - skipped:
+        assign:
+          - a: 0
+    - update:
+        assign:
+          - a: ${a + b}
+    - done:
+        return: ${a}
+`,
+			expected: []stepTraceLine{
+				{Routine: "main", Path: []string{"init"}, Step: "init", Next: "update", Changed: []string{"a", "b"}},
+				{Routine: "main", Path: []string{"update"}, Step: "update", Next: "done", Changed: []string{"a"}},
+				{Routine: "main", Path: []string{"done"}, Step: "done", Next: "end", Changed: []string{}},
+			},
+		},
+		{
+			name: "nested steps and subworkflow calls",
+			source: `
+main:
+  steps:
+    - outer:
+        steps:
+          - call_sub:
+              call: sub
+              args:
+                x: 1
+              result: r
+    - done:
+        return: ${r}
+sub:
+  params: [x]
+  steps:
+    - double:
+        return: ${x * 2}
+`,
+			expected: []stepTraceLine{
+				{Routine: "sub", Path: []string{"outer", "call_sub", "double"}, Step: "double", Next: "end", Changed: []string{}},
+				{Routine: "main", Path: []string{"outer", "call_sub"}, Step: "call_sub", Changed: []string{"r"}},
+				{Routine: "main", Path: []string{"outer"}, Step: "outer", Next: "done", Changed: []string{"r"}},
+				{Routine: "main", Path: []string{"done"}, Step: "done", Next: "end", Changed: []string{}},
+			},
+		},
+		{
+			name: "uncaught exception",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - m: {}
+    - fail:
+        raise: ${"boom"}
+`,
+			expected: []stepTraceLine{
+				{Routine: "main", Path: []string{"init"}, Step: "init", Next: "fail", Changed: []string{"m"}},
+				{Routine: "main", Path: []string{"fail"}, Step: "fail", Error: "boom", Changed: []string{}},
+			},
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			_, err = root.Execute(context.Background(), nil, workflow.WithStepTrace(&buf))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []stepTraceLine
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				var entry struct {
+					stepTraceLine
+					Duration string `json:"duration"`
+				}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("invalid line %q: %v", line, err)
+				}
+				if _, err := time.ParseDuration(entry.Duration); err != nil {
+					t.Errorf("invalid duration of %q: %v", line, err)
+				}
+				got = append(got, entry.stepTraceLine)
+			}
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("unexpected step trace (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
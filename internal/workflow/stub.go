@@ -0,0 +1,264 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// Stubs are the functions replaced with the canned responses or the local HTTP endpoints.
+type Stubs struct {
+	symbolTable *types.SymbolTable
+}
+
+// WithStubs replaces the functions (e.g. googleapis.bigquery.v2.jobs.query) by the stubs.
+func WithStubs(stubs *Stubs) ExecuteOption {
+	return func(c *executeConfig) {
+		c.globals = stubs.symbolTable
+	}
+}
+
+// stubDef is the definition of a stub which has one of return, raise or url.
+//
+//	googleapis.bigquery.v2.jobs.query:
+//	  return: {jobComplete: true, rows: []}
+//	my.custom.func:
+//	  args: [a, b]
+//	  url: http://localhost:8080/my/custom/func
+type stubDef struct {
+	Args   []string        `json:"args"`
+	Return json.RawMessage `json:"return"`
+	Raise  json.RawMessage `json:"raise"`
+	URL    string          `json:"url"`
+}
+
+func ParseStubsYAML(r io.Reader) (*Stubs, error) {
+	yamlBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
+	}
+
+	var defs map[string]*stubDef
+	if err = json.Unmarshal(jsonBytes, &defs); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	functions := make(map[string]any, len(defs))
+	for name, def := range defs {
+		f, err := def.compile(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stub %s: %w", name, err)
+		}
+		functions[name] = f
+	}
+
+	st, err := overlaySymbols(defaults.DefaultSymbolTable, functions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stub %w", err)
+	}
+	return &Stubs{symbolTable: st}, nil
+}
+
+// WithSymbols registers the additional symbols into the standard library by the dotted names (e.g. my.helpers.slugify),
+// which are the functions (types.Function) or the values, without forking the defaults package. They replace the
+// functions of the same names including the stubs.
+func WithSymbols(symbols map[string]any) ExecuteOption {
+	return func(c *executeConfig) {
+		if c.symbols == nil {
+			c.symbols = make(map[string]any, len(symbols))
+		}
+		for name, value := range symbols {
+			c.symbols[name] = value
+		}
+	}
+}
+
+// overlaySymbols returns the read only symbol table overriding the symbols of the parent by the dotted names.
+func overlaySymbols(parent *types.SymbolTable, values map[string]any) (*types.SymbolTable, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names) // the namespaces precede their members to report the conflicts consistently
+
+	symbols := map[string]any{}
+	for _, name := range names {
+		path := strings.Split(name, ".")
+		root := path[0]
+		if _, ok := symbols[root]; !ok {
+			symbols[root], _ = parent.Get(root)
+		}
+
+		var err error
+		symbols[root], err = overrideNestedSymbol(symbols[root], path[1:], values[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return &types.SymbolTable{
+		Symbols:  symbols,
+		ReadOnly: true,
+		Parent:   parent,
+	}, nil
+}
+
+// overrideNestedSymbol sets the value to the nested map by copying the maps on the path not to modify the standard library.
+func overrideNestedSymbol(v any, names []string, value any) (any, error) {
+	if len(names) == 0 {
+		return value, nil
+	}
+
+	m := map[string]any{}
+	switch vv := v.(type) {
+	case nil:
+		// create a new namespace
+	case map[string]any:
+		for key, value := range vv {
+			m[key] = value
+		}
+	default:
+		return nil, fmt.Errorf("cannot override %T by the namespace", v)
+	}
+
+	var err error
+	m[names[0]], err = overrideNestedSymbol(m[names[0]], names[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (d *stubDef) compile(name string) (types.Function, error) {
+	kinds := 0
+	for _, defined := range []bool{d.Return != nil, d.Raise != nil, d.URL != ""} {
+		if defined {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("exactly one of return, raise or url is required")
+	}
+
+	args := d.Args
+	if args == nil {
+		// respect the arguments of the replaced function to accept the named arguments
+		if f, ok := lookupFunction(name); ok {
+			args = f.Args()
+		}
+	}
+
+	var call func(context.Context, map[string]any, []any) (any, error)
+	switch {
+	case d.Return != nil:
+		call = func(context.Context, map[string]any, []any) (any, error) {
+			return decodeStubValue(d.Return)
+		}
+
+	case d.Raise != nil:
+		call = func(context.Context, map[string]any, []any) (any, error) {
+			v, err := decodeStubValue(d.Raise)
+			if err != nil {
+				return nil, err
+			}
+
+			switch e := v.(type) {
+			case string:
+				return nil, types.NewExceptionByString(e)
+			case map[string]any:
+				return nil, types.NewExceptionByMap(e)
+			default:
+				return nil, fmt.Errorf("raise must be a string or a map but got %T", v)
+			}
+		}
+
+	default:
+		post := defaults.HTTP["post"].(types.Function)
+		call = func(ctx context.Context, namedArgs map[string]any, positionalArgs []any) (any, error) {
+			body := map[string]any{"name": name}
+			if namedArgs != nil {
+				body["args"] = namedArgs
+			} else {
+				body["args"] = positionalArgs
+			}
+
+			res, err := post.Call(ctx, []any{d.URL, types.SubstitutionNone, body})
+			if err != nil {
+				return nil, err
+			}
+			return res.(map[string]any)["body"], nil
+		}
+	}
+
+	return &stubFunction{name: name, args: args, call: call}, nil
+}
+
+func lookupFunction(name string) (types.Function, bool) {
+	names := strings.Split(name, ".")
+	v, ok := defaults.DefaultSymbolTable.Get(names[0])
+	for _, key := range names[1:] {
+		if !ok {
+			break
+		}
+
+		var m map[string]any
+		if m, ok = v.(map[string]any); ok {
+			v, ok = m[key]
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+
+	f, ok := v.(types.Function)
+	return f, ok
+}
+
+// decodeStubValue decodes the value for each call not to share it between the calls.
+func decodeStubValue(raw json.RawMessage) (any, error) {
+	var v any
+	if err := unmarshalJSONUseNumber(raw, &v); err != nil {
+		return nil, err
+	}
+	return decodeJSONNumberRecursive(v)
+}
+
+type stubFunction struct {
+	name string
+	args []string
+	call func(ctx context.Context, namedArgs map[string]any, positionalArgs []any) (any, error)
+}
+
+func (f *stubFunction) Name() string {
+	return f.name
+}
+
+func (f *stubFunction) Args() []string {
+	return f.args
+}
+
+func (f *stubFunction) Call(ctx context.Context, args []any) (any, error) {
+	if f.args == nil {
+		return f.call(ctx, nil, args)
+	}
+
+	namedArgs := make(map[string]any, len(args))
+	for i, arg := range args {
+		if i < len(f.args) && arg != types.SubstitutionNone {
+			namedArgs[f.args[i]] = arg
+		}
+	}
+	return f.call(ctx, namedArgs, args)
+}
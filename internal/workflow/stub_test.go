@@ -0,0 +1,326 @@
+package workflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestStubs(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"received": req})
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tt := range []struct {
+		name               string
+		stubs              string
+		source             string
+		expected           any
+		expectToBeParseErr bool
+	}{
+		{
+			name: "return the canned response of the connector",
+			stubs: `
+googleapis.bigquery.v2.jobs.query:
+  return:
+    jobComplete: true
+    totalRows: "1"
+`,
+			source: `
+main:
+  steps:
+    - query:
+        call: googleapis.bigquery.v2.jobs.query
+        args:
+          projectId: p
+          body:
+            query: SELECT 1
+        result: res
+    - done:
+        return: ${res}
+`,
+			expected: map[string]any{"jobComplete": true, "totalRows": "1"},
+		},
+		{
+			name: "replace the standard library",
+			stubs: `
+sys.now:
+  return: 1700000000
+`,
+			source: `
+main:
+  steps:
+    - done:
+        return: ${sys.now()}
+`,
+			expected: int64(1700000000),
+		},
+		{
+			name: "raise the string",
+			stubs: `
+my.func:
+  raise: boom
+`,
+			source: `
+main:
+  steps:
+    - call:
+        try:
+          call: my.func
+        except:
+          as: e
+          steps:
+            - caught:
+                return: ${e}
+`,
+			expected: "boom",
+		},
+		{
+			name: "raise the map",
+			stubs: `
+my.func:
+  raise:
+    code: 404
+    tags: [HttpError]
+`,
+			source: `
+main:
+  steps:
+    - call:
+        try:
+          call: my.func
+        except:
+          as: e
+          steps:
+            - caught:
+                return: ${e.code}
+`,
+			expected: int64(404),
+		},
+		{
+			name: "post the named arguments to the URL",
+			stubs: `
+my.func:
+  args: [a, b]
+  url: ` + ts.URL + `
+`,
+			source: `
+main:
+  steps:
+    - call:
+        call: my.func
+        args:
+          a: 1
+          b: x
+        result: res
+    - done:
+        return: ${res.received}
+`,
+			expected: map[string]any{"name": "my.func", "args": map[string]any{"a": float64(1), "b": "x"}},
+		},
+		{
+			name: "post the positional arguments to the URL",
+			stubs: `
+my.func:
+  url: ` + ts.URL + `
+`,
+			source: `
+main:
+  steps:
+    - done:
+        return: ${map.get(my.func(1, "x"), "received")}
+`,
+			expected: map[string]any{"name": "my.func", "args": []any{float64(1), "x"}},
+		},
+		{
+			name: "reject the stub without the response",
+			stubs: `
+my.func:
+  args: [a]
+`,
+			expectToBeParseErr: true,
+		},
+		{
+			name: "reject the stub with the multiple responses",
+			stubs: `
+my.func:
+  return: 1
+  raise: boom
+`,
+			expectToBeParseErr: true,
+		},
+		{
+			name: "reject the stub overriding the function by the namespace",
+			stubs: `
+sys.now.x:
+  return: 1
+`,
+			expectToBeParseErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stubs, err := workflow.ParseStubsYAML(strings.NewReader(tt.stubs))
+			if err != nil {
+				if tt.expectToBeParseErr {
+					t.Logf("expected error: %v", err)
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.expectToBeParseErr {
+				t.Fatal("should be parse error")
+			}
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ret, err := root.Execute(context.Background(), nil, workflow.WithStubs(stubs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithSymbols(t *testing.T) {
+	t.Parallel()
+
+	stubs, err := workflow.ParseStubsYAML(strings.NewReader(`
+company.name:
+  return: stub
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		symbols  map[string]any
+		opts     []workflow.ExecuteOption
+		source   string
+		expected any
+		wantErr  bool
+	}{
+		{
+			name:    "value",
+			symbols: map[string]any{"company.regions": []any{"us-central1"}},
+			source: `
+main:
+  steps:
+    - done:
+        return: ${company.regions[0]}
+`,
+			expected: "us-central1",
+		},
+		{
+			name: "function",
+			symbols: map[string]any{"company.greet": types.NewRawFunction("company.greet", []types.Argument{{Name: "name"}}, func(ctx context.Context, args []any) (any, error) {
+				return "hello " + args[0].(string), nil
+			})},
+			source: `
+main:
+  steps:
+    - greet:
+        call: company.greet
+        args:
+          name: alice
+        result: res
+    - done:
+        return: ${res}
+`,
+			expected: "hello alice",
+		},
+		{
+			name:    "keep the siblings in the standard library",
+			symbols: map[string]any{"text.shout": "!"},
+			source: `
+main:
+  steps:
+    - done:
+        return: ${text.to_upper("a") + text.shout}
+`,
+			expected: "A!",
+		},
+		{
+			name:    "replace the standard library",
+			symbols: map[string]any{"sys.now": types.NewRawFunction("sys.now", nil, func(ctx context.Context, args []any) (any, error) { return int64(1700000000), nil })},
+			source: `
+main:
+  steps:
+    - done:
+        return: ${sys.now()}
+`,
+			expected: int64(1700000000),
+		},
+		{
+			name:    "replace the stub",
+			symbols: map[string]any{"company.name": types.NewRawFunction("company.name", nil, func(ctx context.Context, args []any) (any, error) { return "symbol", nil })},
+			opts:    []workflow.ExecuteOption{workflow.WithStubs(stubs)},
+			source: `
+main:
+  steps:
+    - done:
+        return: ${company.name()}
+`,
+			expected: "symbol",
+		},
+		{
+			name:    "reject overriding the function by the namespace",
+			symbols: map[string]any{"sys.now.x": 1},
+			source: `
+main:
+  steps:
+    - done:
+        return: 1
+`,
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ret, err := root.Execute(context.Background(), nil, append(tt.opts, workflow.WithSymbols(tt.symbols))...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
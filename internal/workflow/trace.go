@@ -0,0 +1,87 @@
+package workflow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Trace records the steps, the assignments and the calls of an execution in the order of them, e.g. to compare the
+// behavior of the workflow with the snapshot of the previous execution. The entries are the canonical JSON lines whose
+// keys of the maps are sorted, so the executions behaving the same produce the same trace unless the parallel steps
+// are executed concurrently (see SerializeParallel).
+type Trace struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// WithTrace makes the execution record its steps, assignments and calls into the trace.
+func WithTrace(trace *Trace) ExecuteOption {
+	return func(c *executeConfig) {
+		c.trace = trace
+	}
+}
+
+// record appends the entry to the trace if it is not nil.
+func (t *Trace) record(entry map[string]any) {
+	if t == nil {
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line = []byte(fmt.Sprintf(`{"error":%q}`, err.Error())) // to keep the position of the entry
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, string(line))
+}
+
+// Lines returns the entries recorded so far.
+func (t *Trace) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.lines...)
+}
+
+// WriteTo writes the entries as JSON lines.
+func (t *Trace) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, line := range t.Lines() {
+		n, err := io.WriteString(w, line+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, fmt.Errorf("io.WriteString: %w", err)
+		}
+	}
+	return written, nil
+}
+
+// ReadTraceLines reads the entries of the trace written by Trace.WriteTo.
+func ReadTraceLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+	return lines, nil
+}
+
+// DiffTraceLines returns the diff of the entries of the traces (-expected +actual), or empty if they are the same.
+func DiffTraceLines(expected, actual []string) string {
+	if len(expected) == 0 && len(actual) == 0 {
+		return ""
+	}
+	return cmp.Diff(expected, actual)
+}
@@ -0,0 +1,146 @@
+package workflow_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		source   string
+		args     any
+		expected []string
+	}{
+		{
+			name: "assignments with the sorted keys",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - m: {b: 2, a: 1}
+          - n: 1
+    - done:
+        return: ${n}
+`,
+			expected: []string{
+				`{"step":"init"}`,
+				`{"assign":{"m":{"a":1,"b":2}}}`,
+				`{"assign":{"n":1}}`,
+				`{"step":"done"}`,
+			},
+		},
+		{
+			name: "calls of the subworkflow and the standard library",
+			source: `
+main:
+  params: [args]
+  steps:
+    - call_sub:
+        call: double
+        args:
+          x: ${args.n}
+        result: r
+    - split:
+        call: text.split
+        args:
+          source: a,b
+          separator: ","
+        result: parts
+    - done:
+        return: ${r}
+double:
+  params: [x]
+  steps:
+    - calc:
+        return: ${x * 2}
+`,
+			args: map[string]any{"n": int64(2)},
+			expected: []string{
+				`{"step":"call_sub"}`,
+				`{"step":"calc"}`,
+				`{"args":{"x":2},"call":"double","result":4}`,
+				`{"step":"split"}`,
+				`{"args":{"separator":",","source":"a,b"},"call":"text.split","result":["a","b"]}`,
+				`{"step":"done"}`,
+			},
+		},
+		{
+			name: "switch and jump",
+			source: `
+main:
+  steps:
+    - check:
+        switch:
+          - condition: ${true}
+            next: done
+    - skipped:
+        assign:
+          - x: 1
+    - done:
+        return: ok
+`,
+			expected: []string{
+				`{"step":"check"}`,
+				`{"step":"done"}`,
+			},
+		},
+		{
+			name: "raised exception",
+			source: `
+main:
+  steps:
+    - guarded:
+        try:
+          raise: oops
+        except:
+          as: e
+          steps:
+            - handle:
+                return: ${e}
+`,
+			expected: []string{
+				`{"step":"guarded"}`,
+				`{"step":"handle"}`,
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			trace := &workflow.Trace{}
+			if _, err := root.Execute(context.Background(), tt.args, workflow.WithTrace(trace)); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, trace.Lines()); diff != "" {
+				t.Errorf("unexpected trace (-want +got):\n%s", diff)
+			}
+
+			// the snapshot written by the trace is read as the same lines
+			var buf bytes.Buffer
+			if _, err := trace.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			lines, err := workflow.ReadTraceLines(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := workflow.DiffTraceLines(trace.Lines(), lines); diff != "" {
+				t.Errorf("unexpected lines (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/expression"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/samber/lo"
+)
+
+// typeChecker reports the likely type errors of the expressions and the calls of the standard library in a routine.
+// The types of the variables are inferred from all of their assignments in the routine at first, because the jumps
+// are not followed, and the variables assigned the different types are unknown.
+type typeChecker struct {
+	*validator
+	variables map[string]types.ValueType
+	checking  bool // false while inferring the types of the variables
+}
+
+func (v *validator) checkTypes(name string, raw json.RawMessage) {
+	v.routine = name
+
+	var def struct {
+		Params []any             `json:"params"`
+		Steps  []json.RawMessage `json:"steps"`
+	}
+	if err := unmarshalJSONUseNumber(raw, &def); err != nil {
+		return
+	}
+	params, err := compileParams(def.Params)
+	if err != nil {
+		return
+	}
+
+	c := &typeChecker{validator: v, variables: map[string]types.ValueType{}}
+	for _, param := range params {
+		c.variables[param.Name] = types.UnknownType
+	}
+	c.checkSteps(nil, def.Steps)
+	c.checking = true
+	c.checkSteps(nil, def.Steps)
+}
+
+func (c *typeChecker) define(name string, t types.ValueType) {
+	if c.checking {
+		return
+	}
+	if prev, ok := c.variables[name]; ok && prev != t {
+		t = types.UnknownType
+	}
+	c.variables[name] = t
+}
+
+func (c *typeChecker) checkSteps(path []StepName, stepsRaw []json.RawMessage) {
+	for _, raw := range stepsRaw {
+		var def workflowStepDef
+		if err := json.Unmarshal(raw, &def); err == nil {
+			c.checkStep(append(path[:len(path):len(path)], def.name), def.stepDef)
+		}
+	}
+}
+
+func (c *typeChecker) checkStep(path []StepName, def anonymousStepDef) {
+	var fields struct {
+		Condition json.RawMessage              `json:"condition"`
+		Call      string                       `json:"call"`
+		Args      json.RawMessage              `json:"args"`
+		Result    string                       `json:"result"`
+		Assign    []map[string]json.RawMessage `json:"assign"`
+		Return    json.RawMessage              `json:"return"`
+		Raise     json.RawMessage              `json:"raise"`
+		For       struct {
+			In json.RawMessage `json:"in"`
+		} `json:"for"`
+		Parallel struct {
+			For struct {
+				In json.RawMessage `json:"in"`
+			} `json:"for"`
+		} `json:"parallel"`
+	}
+	decodeStepFields(def, &fields)
+	nested := decodeNestedSteps(def)
+
+	c.inferRaw(path, fields.Condition)
+	for _, assign := range fields.Assign {
+		for key, raw := range assign {
+			t := c.inferRaw(path, raw)
+			if left, err := expression.ParseExpr(key); err == nil && left.IsSymbol() {
+				c.define(key, t)
+			}
+		}
+	}
+	if fields.Call != "" {
+		t := c.checkCall(path, fields.Call, fields.Args)
+		if fields.Result != "" {
+			c.define(fields.Result, t)
+		}
+	}
+	c.inferRaw(path, fields.Return)
+	c.inferRaw(path, fields.Raise)
+	c.inferRaw(path, fields.For.In)
+	c.inferRaw(path, fields.Parallel.For.In)
+
+	if nested.Except.As != "" {
+		c.define(nested.Except.As, types.MapType)
+	}
+	if nested.For.Value != "" {
+		c.define(nested.For.Value, types.UnknownType)
+	}
+	if nested.Parallel.For.Value != "" {
+		c.define(nested.Parallel.For.Value, types.UnknownType)
+	}
+	c.checkSteps(path, nested.Steps)
+	if nested.Try != nil {
+		c.checkStep(path, nested.Try)
+	}
+	c.checkSteps(path, nested.Except.Steps)
+	c.checkSteps(path, nested.For.Steps)
+	c.checkSteps(path, nested.Parallel.For.Steps)
+	for _, branch := range nested.Parallel.Branches {
+		for name, branchDef := range branch {
+			c.checkSteps(append(path[:len(path):len(path)], name), branchDef.Steps)
+		}
+	}
+	for _, condition := range nested.Switch {
+		c.checkStep(path, condition)
+	}
+}
+
+// checkCall checks the args of the call of the standard library, and returns the type of the result.
+func (c *typeChecker) checkCall(path []StepName, call string, argsRaw json.RawMessage) types.ValueType {
+	var args any = []any{}
+	if argsRaw != nil {
+		args = decodeRawValue(argsRaw)
+	}
+
+	value, ok := lookupDefault(call)
+	f, isTyped := value.(types.TypedFunction)
+	if !ok || !isTyped {
+		c.infer(path, args)
+		return types.UnknownType
+	}
+
+	signature := f.Signature()
+	switch args := args.(type) {
+	case map[string]any:
+		for i, name := range f.Args() {
+			arg, ok := args[name]
+			if !ok {
+				if i < signature.MinimumArgs {
+					c.reportType(path, fmt.Errorf("%s: missing argument %s", f.Name(), name))
+				}
+				continue
+			}
+			if t := c.infer(path, arg); !t.AssignableTo(signature.Args[i]) {
+				c.reportType(path, fmt.Errorf("%s: invalid argument %s: expected type is %s but actual %s", f.Name(), name, signature.Args[i], t))
+			}
+		}
+	case []any:
+		if len(args) > len(signature.Args) {
+			c.reportType(path, fmt.Errorf("%s: too many arguments: %d arguments are allowed but got %d arguments", f.Name(), len(signature.Args), len(args)))
+		} else if len(args) < signature.MinimumArgs {
+			c.reportType(path, fmt.Errorf("%s: missing arguments: %d arguments are required but got %d arguments", f.Name(), signature.MinimumArgs, len(args)))
+		}
+		for i, arg := range args {
+			if t := c.infer(path, arg); i < len(signature.Args) && !t.AssignableTo(signature.Args[i]) {
+				c.reportType(path, fmt.Errorf("%s: invalid argument[%d] %s: expected type is %s but actual %s", f.Name(), i, f.Args()[i], signature.Args[i], t))
+			}
+		}
+	}
+	return signature.Result
+}
+
+func (c *typeChecker) inferRaw(path []StepName, raw json.RawMessage) types.ValueType {
+	if raw == nil {
+		return types.UnknownType
+	}
+	return c.infer(path, decodeRawValue(raw))
+}
+
+// infer returns the type of the value of the step, which is checked if it is an expression.
+func (c *typeChecker) infer(path []StepName, value any) types.ValueType {
+	switch value := value.(type) {
+	case map[string]any:
+		keys := lo.Keys(value)
+		sort.Strings(keys) // to report in the stable order
+		for _, key := range keys {
+			c.infer(path, value[key])
+		}
+		return types.MapType
+	case []any:
+		for _, value := range value {
+			c.infer(path, value)
+		}
+		return types.ListType
+	case string:
+		if !expression.IsExpr(value) {
+			return types.StringType
+		}
+		expr, err := expression.ParseExpr(expression.TrimExprParen(value))
+		if err != nil {
+			return types.UnknownType
+		}
+		t, errs := expr.CheckTypes(defaults.DefaultSymbolTable, c.variables)
+		for _, err := range errs {
+			c.reportType(path, fmt.Errorf("%s: %w", value, err))
+		}
+		return t
+	default:
+		return types.TypeOf(value)
+	}
+}
+
+func (c *typeChecker) reportType(path []StepName, err error) {
+	if c.checking {
+		c.report(path, fmt.Errorf("type error: %w", err))
+	}
+}
+
+func decodeRawValue(raw json.RawMessage) any {
+	var value any
+	if err := unmarshalJSONUseNumber(raw, &value); err != nil {
+		return nil
+	}
+	value, _ = decodeJSONNumberRecursive(value)
+	return value
+}
@@ -0,0 +1,433 @@
+package workflow
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/expression"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/samber/lo"
+)
+
+// ValidationError is an error of the workflow found by the validation.
+type ValidationError struct {
+	Routine  string     // empty for the errors of the whole workflow
+	Steps    []StepName // the path from the top-level step of the routine, or empty for the errors of the routine
+	Position *Position  // nil if unknown
+	Warning  bool       // true for the suspicious parts found by the lint, which don't fail the executions
+	Err      error
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.Position != nil {
+		fmt.Fprintf(&b, "%d:%d: ", e.Position.Line, e.Position.Column)
+	}
+	if e.Warning {
+		b.WriteString("warning: ")
+	}
+	if e.Routine != "" {
+		b.WriteString(e.Routine)
+		for _, step := range e.Steps {
+			b.WriteString(" > ")
+			b.WriteString(string(step))
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateWorkflowYAML compiles all steps of the workflow without executing it, and returns all errors of them instead of the first one.
+// The calls are checked to be the functions of the standard library or the subworkflows.
+// The routines without the errors are linted too, and the suspicious parts of them are returned as the warnings.
+func ValidateWorkflowYAML(r io.Reader, opts ...ValidateOption) ([]*ValidationError, error) {
+	yamlBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return []*ValidationError{{Err: fmt.Errorf("yaml.YAMLToJSON: %w", err)}}, nil
+	}
+	return validateWorkflow(jsonBytes, yamlBytes, opts), nil
+}
+
+// ValidateWorkflowJSON is the same as ValidateWorkflowYAML for the workflow in JSON.
+func ValidateWorkflowJSON(r io.Reader, opts ...ValidateOption) ([]*ValidationError, error) {
+	jsonBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	return validateWorkflow(jsonBytes, jsonBytes, opts), nil
+}
+
+func validateWorkflow(jsonBytes, source []byte, opts []ValidateOption) []*ValidationError {
+	var routines map[string]json.RawMessage
+	if err := json.Unmarshal(jsonBytes, &routines); err != nil {
+		return []*ValidationError{{Err: fmt.Errorf("json.Decode: %w", err)}}
+	}
+
+	v := &validator{locator: newStepLocator(source), subworkflows: map[string]bool{}}
+	for _, opt := range opts {
+		opt(v)
+	}
+	for name := range routines {
+		if name != "main" {
+			v.subworkflows[name] = true
+		}
+	}
+	if _, ok := routines["main"]; !ok {
+		v.errors = append(v.errors, &ValidationError{Err: fmt.Errorf("main is required in workflow")})
+	}
+
+	// in the order of the source to report the errors from the top
+	names := lo.Filter(v.locator.routineNames, func(name string, _ int) bool { return routines[name] != nil })
+	for _, name := range lo.Keys(routines) {
+		if !lo.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		v.validateRoutine(name, routines[name])
+	}
+	for _, name := range names {
+		// the errors make the static analysis noisy
+		if !lo.ContainsBy(v.errors, func(e *ValidationError) bool { return e.Routine == name }) {
+			v.lintRoutine(name, routines[name])
+			if v.typeCheck {
+				v.checkTypes(name, routines[name])
+			}
+		}
+	}
+	if _, ok := routines["main"]; ok {
+		v.lintSubworkflows(routines, names)
+	}
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		a, b := v.errors[i].Position, v.errors[j].Position
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return v.errors
+}
+
+// referenceRegexp matches the dotted names, which are resolved statically.
+var referenceRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// ValidateOption configures the validation of the workflow.
+type ValidateOption func(*validator)
+
+// WithTypeCheck makes the validation infer the types of the expressions, and report the likely type errors of the
+// operators and the calls of the standard library (e.g. "a" + 1 or the wrong count of the arguments) as the errors.
+func WithTypeCheck(enabled bool) ValidateOption {
+	return func(v *validator) {
+		v.typeCheck = enabled
+	}
+}
+
+type validator struct {
+	locator      *stepLocator
+	subworkflows map[string]bool
+	typeCheck    bool
+	routine      string
+	names        *stepNameScope // of the steps being validated
+	errors       []*ValidationError
+}
+
+func (v *validator) report(steps []StepName, err error) {
+	v.reportAt(steps, 0, err)
+}
+
+func (v *validator) warn(steps []StepName, err error) {
+	v.report(steps, err)
+	v.errors[len(v.errors)-1].Warning = true
+}
+
+// reportAt reports the error of the step, which is the nth (from 0) step of the name in the same steps.
+func (v *validator) reportAt(steps []StepName, nth int, err error) {
+	v.errors = append(v.errors, &ValidationError{
+		Routine:  v.routine,
+		Steps:    steps,
+		Position: v.locator.position(v.routine, steps, nth),
+		Err:      err,
+	})
+}
+
+func (v *validator) validateRoutine(name string, raw json.RawMessage) {
+	v.routine = name
+
+	var def struct {
+		Params []any             `json:"params"`
+		Steps  []json.RawMessage `json:"steps"`
+	}
+	if err := unmarshalJSONUseNumber(raw, &def); err != nil {
+		v.report(nil, err)
+		return
+	}
+	if len(def.Steps) == 0 {
+		v.report(nil, fmt.Errorf("empty steps"))
+	}
+	if _, err := compileParams(def.Params); err != nil {
+		v.report(nil, err)
+	} else if name == "main" && len(def.Params) > 1 {
+		v.report(nil, fmt.Errorf("main can have a single params only, multiple params are not supported"))
+	}
+	v.names = newStepNameScope(false)
+	v.validateSteps(nil, def.Steps)
+}
+
+func (v *validator) inStepNameScope(scope *stepNameScope, validate func()) {
+	outer := v.names
+	v.names = scope
+	validate()
+	v.names = outer
+}
+
+func (v *validator) validateSteps(path []StepName, stepsRaw []json.RawMessage) {
+	seen := map[StepName]int{}
+	for i, raw := range stepsRaw {
+		var def workflowStepDef
+		if err := json.Unmarshal(raw, &def); err != nil {
+			v.report(path, fmt.Errorf("steps[%d]: %w", i, err))
+			continue
+		}
+
+		stepPath := append(path[:len(path):len(path)], def.name)
+		if err := v.names.check(stepPath); err != nil {
+			v.reportAt(stepPath, seen[def.name], err)
+		}
+		seen[def.name]++
+		v.validateStep(stepPath, def.stepDef, "")
+	}
+}
+
+// validateStep reports the errors of the nested steps of the step, or the error of the step itself if they are valid.
+func (v *validator) validateStep(path []StepName, def anonymousStepDef, context string) {
+	reported := len(v.errors)
+	v.validateNestedSteps(path, def)
+
+	// compile the copy because the compilation consumes the definition
+	if _, err := anonymousStepDef(lo.Assign(anonymousStepDef{}, def)).compile(); err != nil {
+		if len(v.errors) == reported {
+			v.report(path, fmt.Errorf("%s%w", context, err))
+		}
+		return
+	}
+
+	if callJSON, ok := def["call"]; ok {
+		var call string
+		if err := json.Unmarshal(callJSON, &call); err == nil && !v.resolvable(call, true) {
+			v.report(path, fmt.Errorf("%sunknown call %q", context, call))
+		}
+	}
+	if retryJSON, ok := def["retry"]; ok {
+		var policy retryPolicyDef
+		var retry string
+		if err := json.Unmarshal(retryJSON, &policy); err == nil {
+			if predicate := expression.TrimExprParen(policy.Predicate); !v.resolvable(predicate, true) {
+				v.report(path, fmt.Errorf("%sunknown retry predicate %q", context, predicate))
+			}
+		} else if err := json.Unmarshal(retryJSON, &retry); err == nil {
+			if retry = expression.TrimExprParen(retry); !v.resolvable(retry, false) {
+				v.report(path, fmt.Errorf("%sunknown retry policy %q", context, retry))
+			}
+		}
+	}
+}
+
+// nestedStepsDef is the nested steps of a step to analyze them without the compilation.
+type nestedStepsDef struct {
+	Steps  []json.RawMessage `json:"steps"`
+	Try    anonymousStepDef  `json:"try"`
+	Except struct {
+		As    string            `json:"as"`
+		Steps []json.RawMessage `json:"steps"`
+	} `json:"except"`
+	For struct {
+		Value string            `json:"value"`
+		Index string            `json:"index"`
+		Steps []json.RawMessage `json:"steps"`
+	} `json:"for"`
+	Parallel struct {
+		For struct {
+			Value string            `json:"value"`
+			Index string            `json:"index"`
+			Steps []json.RawMessage `json:"steps"`
+		} `json:"for"`
+		Branches []map[StepName]struct {
+			Steps []json.RawMessage `json:"steps"`
+		} `json:"branches"`
+	} `json:"parallel"`
+	Switch []anonymousStepDef `json:"switch"`
+}
+
+func decodeNestedSteps(def anonymousStepDef) *nestedStepsDef {
+	var nested nestedStepsDef
+	decodeStepFields(def, &nested)
+	return &nested
+}
+
+// decodeStepFields decodes the fields of the step one by one to ignore the malformed fields, which are reported by the compilation of the step.
+func decodeStepFields(def anonymousStepDef, v any) {
+	for key, raw := range def {
+		_ = json.Unmarshal([]byte(`{"`+key+`":`+string(raw)+`}`), v)
+	}
+}
+
+func (v *validator) validateNestedSteps(path []StepName, def anonymousStepDef) {
+	nested := decodeNestedSteps(def)
+	v.validateSteps(path, nested.Steps)
+	if nested.Try != nil {
+		v.validateStep(path, nested.Try, "try: ")
+	}
+	v.validateSteps(path, nested.Except.Steps)
+	v.inStepNameScope(newStepNameScope(true), func() {
+		v.validateSteps(path, nested.For.Steps)
+	})
+	v.inStepNameScope(newStepNameScope(true), func() {
+		v.validateSteps(path, nested.Parallel.For.Steps)
+	})
+	for _, branch := range nested.Parallel.Branches {
+		for name, branchDef := range branch {
+			v.inStepNameScope(newStepNameScope(false), func() {
+				v.validateSteps(append(path[:len(path):len(path)], name), branchDef.Steps)
+			})
+		}
+	}
+	for i, condition := range nested.Switch {
+		delete(condition, "condition")
+		v.validateStep(path, condition, fmt.Sprintf("switch[%d]: ", i))
+	}
+}
+
+// resolvable checks the name is a subworkflow or a function (or any value unless function) of the standard library.
+// The dynamic references (e.g. to the variables by the indexes) are not checked.
+func (v *validator) resolvable(name string, function bool) bool {
+	if !referenceRegexp.MatchString(name) {
+		return true
+	}
+
+	if names := strings.Split(name, "."); v.subworkflows[names[0]] {
+		return len(names) == 1
+	}
+	if name == "experimental.executions.map" {
+		return true
+	}
+
+	value, ok := lookupDefault(name)
+	if _, isFunction := value.(types.Function); function && !isFunction {
+		return false
+	}
+	return ok
+}
+
+// lookupDefault returns the value of the dotted name in the standard library.
+func lookupDefault(name string) (any, bool) {
+	names := strings.Split(name, ".")
+	value, ok := defaults.DefaultSymbolTable.Get(names[0])
+	for _, name := range names[1:] {
+		if !ok {
+			break
+		}
+		m, isMap := value.(map[string]any)
+		if !isMap {
+			return nil, false
+		}
+		value, ok = m[name]
+	}
+	return value, ok
+}
+
+// stepLocator finds the positions of the steps by their paths in the source of the workflow.
+type stepLocator struct {
+	routineNames []string // in the order of the source
+	routines     map[string]*ast.MappingValueNode
+}
+
+func newStepLocator(source []byte) *stepLocator {
+	l := &stepLocator{routines: map[string]*ast.MappingValueNode{}}
+	file, err := parser.ParseBytes(source, 0)
+	if err != nil || len(file.Docs) == 0 {
+		return l
+	}
+
+	for _, routine := range mappingValues(file.Docs[0].Body) {
+		name := routine.Key.GetToken().Value
+		l.routineNames = append(l.routineNames, name)
+		l.routines[name] = routine
+	}
+	return l
+}
+
+// position returns the position of the innermost step found in the path, or the routine if none of them is found.
+// The last step of the path is the nth (from 0) step of the name in the same steps.
+func (l *stepLocator) position(routine string, path []StepName, nth int) *Position {
+	node, ok := l.routines[routine]
+	if !ok {
+		return nil
+	}
+
+	for i, name := range path {
+		finder := &stepFinder{name: name}
+		if i == len(path)-1 {
+			finder.nth = nth
+		}
+		ast.Walk(finder, node.Value)
+		if finder.found == nil {
+			break
+		}
+		node = finder.found
+	}
+	tk := node.Key.GetToken()
+	return &Position{Line: tk.Position.Line, Column: tk.Position.Column, Length: len(tk.Value)}
+}
+
+// stepFinder finds the nth step of the name in the first steps having the name, preferring the shallower ones.
+type stepFinder struct {
+	name  StepName
+	nth   int
+	found *ast.MappingValueNode
+}
+
+func (f *stepFinder) Visit(node ast.Node) ast.Visitor {
+	if f.found != nil {
+		return nil
+	}
+	seq, ok := node.(*ast.SequenceNode)
+	if !ok {
+		return f
+	}
+
+	var matched []*ast.MappingValueNode
+	for _, item := range seq.Values {
+		values := mappingValues(item)
+		if len(values) == 1 && StepName(values[0].Key.GetToken().Value) == f.name {
+			matched = append(matched, values[0])
+		}
+	}
+	if len(matched) == 0 {
+		return f
+	}
+	if f.nth < len(matched) {
+		f.found = matched[f.nth]
+	} else {
+		f.found = matched[0]
+	}
+	return nil
+}
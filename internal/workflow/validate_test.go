@@ -0,0 +1,461 @@
+package workflow_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/samber/lo"
+)
+
+func TestValidateWorkflow(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name      string
+		source    string
+		json      bool
+		typeCheck bool
+		expected  []string
+	}{
+		{
+			name: "valid workflow",
+			source: `
+main:
+  steps:
+    - call_sub:
+        call: sub
+        args:
+          a: 1
+        result: r
+    - done:
+        return: ${r}
+sub:
+  params: [a]
+  steps:
+    - r:
+        return: ${a}
+`,
+			expected: []string{},
+		},
+		{
+			name: "all errors of the steps",
+			source: `
+main:
+  steps:
+    - first:
+        assign:
+          - a: ${1 +}
+    - second:
+        call: no.such.function
+    - third:
+        call: missing_subworkflow
+`,
+			expected: []string{
+				`4:7: main > first: invalid assign[0]: invalid token + at 3: expr="1 +"`,
+				`7:7: main > second: unknown call "no.such.function"`,
+				`9:7: main > third: unknown call "missing_subworkflow"`,
+			},
+		},
+		{
+			name: "nested step",
+			source: `
+main:
+  steps:
+    - loop:
+        for:
+          value: v
+          in: [1, 2]
+          steps:
+            - bad:
+                return: ${v +}
+`,
+			expected: []string{
+				`9:15: main > loop > bad: invalid return: invalid token + at 3: expr="v +"`,
+			},
+		},
+		{
+			name: "unknown next step",
+			source: `
+main:
+  steps:
+    - jump:
+        next: nowhere
+`,
+			expected: []string{
+				`4:7: main > jump: unknown next step "nowhere"`,
+			},
+		},
+		{
+			name: "duplicated step name",
+			source: `
+main:
+  steps:
+    - check:
+        switch:
+          - condition: ${true}
+            steps:
+              - done:
+                  return: 1
+    - done:
+        return: 2
+`,
+			expected: []string{
+				`10:7: main > done: duplicated step name, also defined at check > done`,
+			},
+		},
+		{
+			name: "warnings of the lint",
+			source: `
+main:
+  steps:
+    - done:
+        return: ok
+    - unused:
+        assign:
+          - x: 1
+sub:
+  steps:
+    - r:
+        return: ok
+`,
+			expected: []string{
+				`6:7: warning: main > unused: unreachable step`,
+				`9:1: warning: sub: subworkflow is never called`,
+			},
+		},
+		{
+			name: "variable read before any assignment",
+			source: `
+main:
+  steps:
+    - first:
+        assign:
+          - y: ${x + 1}
+          - m.key: 1
+    - again:
+        assign:
+          - z: ${x + y}
+    - x:
+        assign:
+          - x: 1
+    - done:
+        return: ${x + z}
+`,
+			expected: []string{
+				`4:7: warning: main > first: variable "x" is read before any assignment`,
+				`4:7: warning: main > first: variable "m" is read before any assignment`,
+			},
+		},
+		{
+			name: "variables assigned before the reads",
+			source: `
+main:
+  params: [args]
+  steps:
+    - init:
+        assign:
+          - m: {}
+          - m.key: ${args.key}
+    - call_sub:
+        call: sub
+        args:
+          x: ${m.key}
+        result: r
+    - done:
+        return: ${r + sys.now()}
+sub:
+  params: [x]
+  steps:
+    - r:
+        return: ${x}
+`,
+			expected: []string{},
+		},
+		{
+			name: "variables of the loops",
+			source: `
+main:
+  steps:
+    - loop:
+        for:
+          value: v
+          index: i
+          in: [1, 2]
+          steps:
+            - use:
+                assign:
+                  - last: ${v + i}
+    - parallel_loop:
+        parallel:
+          for:
+            value: pv
+            index: pi
+            in: [1, 2]
+            steps:
+              - use:
+                  call: sys.log
+                  args:
+                    text: ${pv + pi}
+    - done:
+        return: ${v + last}
+`,
+			expected: []string{
+				`24:7: warning: main > done: variable "v" is read before any assignment`,
+			},
+		},
+		{
+			name: "variable of the exception",
+			source: `
+main:
+  steps:
+    - try_fail:
+        try:
+          raise: boom
+        except:
+          as: e
+          steps:
+            - caught:
+                assign:
+                  - message: ${e}
+    - done:
+        return: ${message + e}
+`,
+			expected: []string{
+				`13:7: warning: main > done: variable "e" is read before any assignment`,
+			},
+		},
+		{
+			name: "variable assigned in one branch of the switch",
+			source: `
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${args.ok}
+            steps:
+              - assign_x:
+                  assign:
+                    - x: 1
+          - condition: ${true}
+            steps:
+              - read_x:
+                  return: ${x}
+    - done:
+        return: ${x}
+`,
+			expected: []string{},
+		},
+		{
+			name: "variable read in the switch before the assignment",
+			source: `
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${args.ok}
+            return: ${x}
+          - condition: ${true}
+            assign:
+              - x: 1
+    - done:
+        return: ${x}
+`,
+			expected: []string{
+				`5:7: warning: main > check: variable "x" is read before any assignment`,
+			},
+		},
+		{
+			name: "shared variables",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - total: 0
+    - parallel:
+        parallel:
+          shared: [total, missing]
+          branches:
+            - first:
+                steps:
+                  - add:
+                      assign:
+                        - total: ${total + 1}
+            - second:
+                steps:
+                  - add_more:
+                      assign:
+                        - total: ${total + 2}
+    - done:
+        return: ${total}
+`,
+			expected: []string{
+				`7:7: warning: main > parallel: variable "missing" is read before any assignment`,
+			},
+		},
+		{
+			name: "params shadowing the subworkflows and the standard library",
+			source: `
+main:
+  params: [sub]
+  steps:
+    - call_sub:
+        call: helper
+        args:
+          sys: 1
+          text: 2
+        result: r
+    - done:
+        return: ${r}
+helper:
+  params: [sys, text]
+  steps:
+    - r:
+        return: ${sys + text}
+sub:
+  params: [a]
+  steps:
+    - r:
+        return: ${a}
+`,
+			expected: []string{
+				`2:1: warning: main: param "sub" shadows the subworkflow`,
+				`13:1: warning: helper: param "sys" shadows the standard library`,
+				`13:1: warning: helper: param "text" shadows the standard library`,
+				`18:1: warning: sub: subworkflow is never called`,
+			},
+		},
+		{
+			name: "variables shadowing the params",
+			source: `
+main:
+  params: [args]
+  steps:
+    - loop:
+        for:
+          value: args
+          index: i
+          in: [1, 2]
+          steps:
+            - inner:
+                for:
+                  value: v
+                  index: args
+                  in: [1, 2]
+                  steps:
+                    - use:
+                        assign:
+                          - x: ${args + v + i}
+    - try_fail:
+        try:
+          raise: boom
+        except:
+          as: args
+          steps:
+            - caught:
+                return: ${args}
+`,
+			expected: []string{
+				`5:7: warning: main > loop: for value "args" shadows the param`,
+				`11:15: warning: main > loop > inner: for index "args" shadows the param`,
+				`20:7: warning: main > try_fail: except as "args" shadows the param`,
+			},
+		},
+		{
+			name: "variables not shadowing the params",
+			source: `
+main:
+  params: [args]
+  steps:
+    - loop:
+        for:
+          value: v
+          index: i
+          in: ${args.list}
+          steps:
+            - use:
+                assign:
+                  - x: ${v + i}
+    - try_fail:
+        try:
+          raise: boom
+        except:
+          as: e
+          steps:
+            - caught:
+                return: ${e}
+`,
+			expected: []string{},
+		},
+		{
+			name: "missing main",
+			source: `
+sub:
+  steps:
+    - r:
+        return: ok
+`,
+			expected: []string{
+				`main is required in workflow`,
+			},
+		},
+		{
+			name: "type errors",
+			source: `
+main:
+  steps:
+    - a:
+        assign:
+          - x: ${"a" + 1}
+    - b:
+        return: ${len(1, 2)}
+`,
+			typeCheck: true,
+			expected: []string{
+				`4:7: main > a: type error: ${"a" + 1}: invalid operator "+" for left=string right=integer`,
+				`7:7: main > b: type error: ${len(1, 2)}: len: too many arguments: 1 arguments are allowed but got 2 arguments`,
+			},
+		},
+		{
+			name: "type errors without the type check",
+			source: `
+main:
+  steps:
+    - a:
+        return: ${"a" + 1}
+`,
+			expected: []string{},
+		},
+		{
+			name:   "JSON workflow",
+			source: `{"main": {"steps": [{"bad": {"call": "no.such.function"}}]}}`,
+			json:   true,
+			expected: []string{
+				`1:22: main > bad: unknown call "no.such.function"`,
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			validate := workflow.ValidateWorkflowYAML
+			if tt.json {
+				validate = workflow.ValidateWorkflowJSON
+			}
+			errs, err := validate(strings.NewReader(tt.source), workflow.WithTypeCheck(tt.typeCheck))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := lo.Map(errs, func(e *workflow.ValidationError, _ int) string { return e.Error() })
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("unexpected errors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
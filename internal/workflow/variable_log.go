@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/logging"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+)
+
+// redactedValue replaces the values of the redacted variables and keys.
+const redactedValue = "[REDACTED]"
+
+// variableLogger writes the new values of the variables after the assignments and the calls, e.g. to debug why a
+// variable has an unexpected value.
+type variableLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	redact *regexp.Regexp
+}
+
+// WithVariableLog makes the execution write the variables assigned by the assign steps and the results of the calls
+// with their new values into w. The values of the variables, and the keys of the maps in them at any depth, whose
+// names match redact are replaced by [REDACTED] unless redact is nil.
+func WithVariableLog(w io.Writer, redact *regexp.Regexp) ExecuteOption {
+	return func(c *executeConfig) {
+		c.variableLogger = &variableLogger{w: w, redact: redact}
+	}
+}
+
+// log writes the current value of the variable like "main.step: name = value (http.get)", where the function is the
+// call of the result or empty for the assignments.
+func (l *variableLogger) log(ctx context.Context, symbolTable *types.SymbolTable, name string, function string) {
+	value, _ := symbolTable.Get(name)
+	b, err := json.MarshalWithOption(redactValue(l.redact, name, value), json.DisableHTMLEscape())
+	if err != nil {
+		b = []byte(fmt.Sprintf("%#v", value))
+	}
+
+	var line strings.Builder
+	line.WriteString(stepRoutineFromContext(ctx))
+	if path := stepPathFromContext(ctx); len(path) != 0 {
+		line.WriteString(".")
+		line.WriteString(path[len(path)-1])
+	}
+	fmt.Fprintf(&line, ": %s = %s", name, b)
+	if function != "" {
+		fmt.Fprintf(&line, " (%s)", function)
+	}
+	line.WriteString("\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := io.WriteString(l.w, line.String()); err != nil {
+		logging.Logf(logging.LevelError, "failed to write variable log", "error", err)
+	}
+}
+
+// redactValue returns the copy of the value whose keys matching the redaction are redacted.
+func redactValue(redact *regexp.Regexp, key string, value any) any {
+	if redact == nil {
+		return value
+	}
+	if redact.MatchString(key) {
+		return redactedValue
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for k, value := range v {
+			redacted[k] = redactValue(redact, k, value)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, value := range v {
+			redacted[i] = redactValue(redact, "", value)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,81 @@
+package workflow_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+func TestVariableLog(t *testing.T) {
+	t.Parallel()
+
+	const source = `
+main:
+  steps:
+    - init:
+        assign:
+          - user: {name: alice, password: p@ss, tokens: [{api_key: k1}]}
+          - user.name: bob
+          - password: p@ss
+    - upper:
+        call: text.to_upper
+        args:
+          source: ${user.name}
+        result: name
+    - nested:
+        steps:
+          - count:
+              assign:
+                - n: ${len(user)}
+    - done:
+        return: ${name}
+`
+	for _, tt := range []struct {
+		name     string
+		redact   *regexp.Regexp
+		expected string
+	}{
+		{
+			name:   "redacted",
+			redact: regexp.MustCompile(`(?i)password|api_?key`),
+			expected: `main.init: user = {"name":"alice","password":"[REDACTED]","tokens":[{"api_key":"[REDACTED]"}]}
+main.init: user = {"name":"bob","password":"[REDACTED]","tokens":[{"api_key":"[REDACTED]"}]}
+main.init: password = "[REDACTED]"
+main.upper: name = "BOB" (text.to_upper)
+main.count: n = 3
+`,
+		},
+		{
+			name: "not redacted",
+			expected: `main.init: user = {"name":"alice","password":"p@ss","tokens":[{"api_key":"k1"}]}
+main.init: user = {"name":"bob","password":"p@ss","tokens":[{"api_key":"k1"}]}
+main.init: password = "p@ss"
+main.upper: name = "BOB" (text.to_upper)
+main.count: n = 3
+`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if _, err := root.Execute(context.Background(), nil, workflow.WithVariableLog(&buf, tt.redact)); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, buf.String()); diff != "" {
+				t.Errorf("unexpected variable log (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
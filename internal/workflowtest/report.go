@@ -0,0 +1,163 @@
+package workflowtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// Suite is the results of the test cases in a file.
+type Suite struct {
+	Name    string
+	Results []*Result
+}
+
+func (s *Suite) failures() int {
+	n := 0
+	for _, result := range s.Results {
+		if !result.Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Suite) seconds() float64 {
+	var sec float64
+	for _, result := range s.Results {
+		sec += result.Duration.Seconds()
+	}
+	return sec
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",cdata"`
+}
+
+// WriteJUnitXML writes the results in the JUnit XML format, which the CI systems show as the test reports.
+func WriteJUnitXML(w io.Writer, suites []*Suite) error {
+	var total float64
+	report := &junitTestSuites{}
+	for _, suite := range suites {
+		s := &junitTestSuite{
+			Name:     suite.Name,
+			Tests:    len(suite.Results),
+			Failures: suite.failures(),
+			Time:     formatSeconds(suite.seconds()),
+		}
+		for _, result := range suite.Results {
+			c := &junitTestCase{
+				Name:      result.Name,
+				ClassName: suite.Name,
+				Time:      formatSeconds(result.Duration.Seconds()),
+			}
+			if !result.Passed() {
+				c.Failure = &junitFailure{
+					Message:  strings.SplitN(result.Failure, "\n", 2)[0],
+					Contents: result.Failure,
+				}
+			}
+			s.Cases = append(s.Cases, c)
+		}
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Suites = append(report.Suites, s)
+		total += suite.seconds()
+	}
+	report.Time = formatSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("io.WriteString: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("xml.Encode: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("io.WriteString: %w", err)
+	}
+	return nil
+}
+
+func formatSeconds(sec float64) string {
+	return fmt.Sprintf("%.3f", sec)
+}
+
+type jsonReport struct {
+	Tests    int          `json:"tests"`
+	Failures int          `json:"failures"`
+	Suites   []*jsonSuite `json:"suites"`
+}
+
+type jsonSuite struct {
+	Name     string      `json:"name"`
+	Tests    int         `json:"tests"`
+	Failures int         `json:"failures"`
+	Cases    []*jsonCase `json:"cases"`
+}
+
+type jsonCase struct {
+	Name     string  `json:"name"`
+	Passed   bool    `json:"passed"`
+	Duration float64 `json:"durationSeconds"`
+	Failure  string  `json:"failure,omitempty"`
+}
+
+// WriteJSON writes the results in JSON for the other tools.
+func WriteJSON(w io.Writer, suites []*Suite) error {
+	report := &jsonReport{Suites: []*jsonSuite{}}
+	for _, suite := range suites {
+		s := &jsonSuite{
+			Name:     suite.Name,
+			Tests:    len(suite.Results),
+			Failures: suite.failures(),
+			Cases:    []*jsonCase{},
+		}
+		for _, result := range suite.Results {
+			s.Cases = append(s.Cases, &jsonCase{
+				Name:     result.Name,
+				Passed:   result.Passed(),
+				Duration: result.Duration.Seconds(),
+				Failure:  result.Failure,
+			})
+		}
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Suites = append(report.Suites, s)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("json.Encode: %w", err)
+	}
+	return nil
+}
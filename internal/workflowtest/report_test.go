@@ -0,0 +1,119 @@
+package workflowtest_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflowtest"
+)
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	suites := []*workflowtest.Suite{
+		{
+			Name: "greet_test.yaml",
+			Results: []*workflowtest.Result{
+				{Name: "greets", Duration: 1500 * time.Millisecond},
+				{Name: "fails", Duration: 250 * time.Millisecond, Failure: "result mismatch (-want +got):\n- a\n+ b"},
+			},
+		},
+		{
+			Name: "empty_test.yaml",
+		},
+	}
+	for _, tt := range []struct {
+		name     string
+		write    func(io.Writer, []*workflowtest.Suite) error
+		suites   []*workflowtest.Suite
+		expected string
+	}{
+		{
+			name:   "JUnit XML",
+			write:  workflowtest.WriteJUnitXML,
+			suites: suites,
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="2" failures="1" time="1.750">
+  <testsuite name="greet_test.yaml" tests="2" failures="1" time="1.750">
+    <testcase name="greets" classname="greet_test.yaml" time="1.500"></testcase>
+    <testcase name="fails" classname="greet_test.yaml" time="0.250">
+      <failure message="result mismatch (-want +got):"><![CDATA[result mismatch (-want +got):
+- a
++ b]]></failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="empty_test.yaml" tests="0" failures="0" time="0.000"></testsuite>
+</testsuites>
+`,
+		},
+		{
+			name:  "JUnit XML without suites",
+			write: workflowtest.WriteJUnitXML,
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="0" failures="0" time="0.000"></testsuites>
+`,
+		},
+		{
+			name:   "JSON",
+			write:  workflowtest.WriteJSON,
+			suites: suites,
+			expected: `{
+  "tests": 2,
+  "failures": 1,
+  "suites": [
+    {
+      "name": "greet_test.yaml",
+      "tests": 2,
+      "failures": 1,
+      "cases": [
+        {
+          "name": "greets",
+          "passed": true,
+          "durationSeconds": 1.5
+        },
+        {
+          "name": "fails",
+          "passed": false,
+          "durationSeconds": 0.25,
+          "failure": "result mismatch (-want +got):\n- a\n+ b"
+        }
+      ]
+    },
+    {
+      "name": "empty_test.yaml",
+      "tests": 0,
+      "failures": 0,
+      "cases": []
+    }
+  ]
+}
+`,
+		},
+		{
+			name:  "JSON without suites",
+			write: workflowtest.WriteJSON,
+			expected: `{
+  "tests": 0,
+  "failures": 0,
+  "suites": []
+}
+`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b strings.Builder
+			if err := tt.write(&b, tt.suites); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, b.String()); diff != "" {
+				t.Errorf("unexpected report (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package workflowtest
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// testFileSuffix is the suffix of the test case files next to the workflow files, e.g. hello_test.yaml for hello.yaml.
+const testFileSuffix = "_test.yaml"
+
+// TestFilePath returns the path of the test case file of the workflow file by the convention.
+func TestFilePath(workflowFile string) string {
+	return strings.TrimSuffix(workflowFile, filepath.Ext(workflowFile)) + testFileSuffix
+}
+
+// IsTestFile reports whether the file is a test case file by the convention, which is not a workflow file.
+func IsTestFile(path string) bool {
+	return strings.HasSuffix(path, testFileSuffix)
+}
+
+// ScaffoldWorkflow is the starter workflow, which calls an API with the retries and formats its response by a subworkflow.
+const ScaffoldWorkflow = `main:
+  params: [args]
+  steps:
+    - fetch:
+        try:
+          call: http.get
+          args:
+            url: https://api.example.com/greetings
+            query:
+              name: ${args.name}
+          result: response
+        retry: ${http.default_retry}
+    - format:
+        call: greet
+        args:
+          greeting: ${response.body.greeting}
+          name: ${args.name}
+        result: message
+    - done:
+        return: ${message}
+
+greet:
+  params: [greeting, name]
+  steps:
+    - build:
+        return: ${greeting + ", " + name + "!"}
+`
+
+// ScaffoldTestCases is the test cases of ScaffoldWorkflow, which are run by the test subcommand.
+const ScaffoldTestCases = `- name: greets the user
+  args: {name: alice}
+  httpMocks:
+    - method: GET
+      url: https://api.example.com/greetings*
+      response:
+        body: {greeting: Hello}
+  expect:
+    result: Hello, alice!
+- name: raises the HTTP errors
+  args: {name: alice}
+  httpMocks:
+    - url: https://api.example.com/greetings*
+      response:
+        status: 404
+        body: not found
+  expect:
+    exception: {code: 404, tags: [HttpError]}
+`
@@ -0,0 +1,68 @@
+package workflowtest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflowtest"
+)
+
+func TestTestFilePath(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name         string
+		workflowFile string
+		expected     string
+	}{
+		{name: "YAML", workflowFile: "hello.yaml", expected: "hello_test.yaml"},
+		{name: "JSON", workflowFile: "dir/hello.json", expected: "dir/hello_test.yaml"},
+		{name: "dotted name", workflowFile: "hello.v2.yaml", expected: "hello.v2_test.yaml"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := workflowtest.TestFilePath(tt.workflowFile)
+			if path != tt.expected {
+				t.Errorf("unexpected path: %s, want %s", path, tt.expected)
+			}
+			if !workflowtest.IsTestFile(path) {
+				t.Errorf("%s should be a test file", path)
+			}
+			if workflowtest.IsTestFile(tt.workflowFile) {
+				t.Errorf("%s should not be a test file", tt.workflowFile)
+			}
+		})
+	}
+}
+
+// TestScaffold isn't parallel since the runner replaces the transport of the process by the HTTP mocks of the test cases.
+func TestScaffold(t *testing.T) {
+	errs, err := workflow.ValidateWorkflowYAML(strings.NewReader(workflowtest.ScaffoldWorkflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range errs {
+		t.Errorf("scaffold workflow should be valid: %v", e)
+	}
+
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(workflowtest.ScaffoldWorkflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases, err := workflowtest.ParseTestCasesYAML(strings.NewReader(workflowtest.ScaffoldTestCases))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			if result := testRunner.Run(context.Background(), root, tc, nil); !result.Passed() {
+				t.Errorf("scaffold test case should pass: %s", result.Failure)
+			}
+		})
+	}
+}
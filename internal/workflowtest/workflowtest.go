@@ -0,0 +1,251 @@
+// Package workflowtest runs the test cases of the workflows, which define the arguments, the HTTP mocks and the environment
+// variables of the executions with their expected results or exceptions.
+package workflowtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// TestCase is a test case of the workflow.
+//
+//	# tests.yaml
+//	- name: greets the user
+//	  workflow: sample # can be omitted if a single workflow is tested
+//	  args: {name: alice}
+//	  env: {GREETING: hello}
+//	  httpMocks: # the same as --http-mocks
+//	    - url: https://api.example.com/users/*
+//	      response: {body: {name: alice}}
+//	  expect:
+//	    result: hello alice # or exception: {tags: [HttpError]}
+type TestCase struct {
+	Name      string
+	Workflow  string
+	Args      any
+	Env       map[string]string
+	HTTPMocks *defaults.HTTPMocks // nil to send the requests by the default transport
+
+	result    any
+	hasResult bool
+	exception any // matches the subset of the raised exception
+}
+
+type testCaseDef struct {
+	Name      string            `json:"name"`
+	Workflow  string            `json:"workflow"`
+	Args      any               `json:"args"`
+	Env       map[string]string `json:"env"`
+	HTTPMocks json.RawMessage   `json:"httpMocks"`
+	Expect    struct {
+		Result    json.RawMessage `json:"result"`
+		Exception json.RawMessage `json:"exception"`
+	} `json:"expect"`
+}
+
+// ParseTestCasesYAML parses the list of the test cases.
+func ParseTestCasesYAML(r io.Reader) ([]*TestCase, error) {
+	yamlBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.YAMLToJSON: %w", err)
+	}
+
+	var defs []*testCaseDef
+	if err := json.Unmarshal(jsonBytes, &defs); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	testCases := make([]*TestCase, len(defs))
+	for i, def := range defs {
+		tc := &TestCase{
+			Name:     def.Name,
+			Workflow: def.Workflow,
+			Args:     def.Args,
+			Env:      def.Env,
+		}
+		if tc.Name == "" {
+			tc.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if def.HTTPMocks != nil {
+			tc.HTTPMocks, err = defaults.ParseHTTPMocksJSON(bytes.NewReader(def.HTTPMocks))
+			if err != nil {
+				return nil, fmt.Errorf("invalid test case %q: httpMocks: %w", tc.Name, err)
+			}
+		}
+		if def.Expect.Result != nil && def.Expect.Exception != nil {
+			return nil, fmt.Errorf("invalid test case %q: expect either of result or exception", tc.Name)
+		}
+		if def.Expect.Result != nil {
+			tc.hasResult = true
+			if err := json.Unmarshal(def.Expect.Result, &tc.result); err != nil {
+				return nil, fmt.Errorf("invalid test case %q: expect.result: %w", tc.Name, err)
+			}
+		}
+		if def.Expect.Exception != nil {
+			if err := json.Unmarshal(def.Expect.Exception, &tc.exception); err != nil {
+				return nil, fmt.Errorf("invalid test case %q: expect.exception: %w", tc.Name, err)
+			}
+		}
+		testCases[i] = tc
+	}
+	return testCases, nil
+}
+
+// Result is the result of a test case.
+type Result struct {
+	Name     string
+	Duration time.Duration
+	Failure  string // empty if passed
+}
+
+func (r *Result) Passed() bool {
+	return r.Failure == ""
+}
+
+// Runner runs the test cases one by one, because the HTTP mocks of them replace the transport of the process.
+type Runner struct {
+	mu    sync.Mutex
+	base  http.RoundTripper
+	mocks *defaults.HTTPMocks
+}
+
+// NewRunner creates a runner hooking the outbound HTTP requests to the mocks of the test cases.
+// It must be called before executing any workflows as defaults.WrapHTTPTransport.
+func NewRunner() *Runner {
+	r := &Runner{}
+	defaults.WrapHTTPTransport(func(base http.RoundTripper) http.RoundTripper {
+		r.base = base
+		return (*runnerTransport)(r)
+	})
+	return r
+}
+
+type runnerTransport Runner
+
+func (t *runnerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	mocks := t.mocks
+	t.mu.Unlock()
+	if mocks != nil {
+		return mocks.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Run executes the workflow by the test case, and checks the result or the exception of it.
+func (r *Runner) Run(ctx context.Context, root workflow.WorkflowRoot, tc *TestCase, env map[string]string, opts ...workflow.ExecuteOption) *Result {
+	r.mu.Lock()
+	r.mocks = tc.HTTPMocks
+	r.mu.Unlock()
+
+	caseEnv := make(map[string]string, len(env)+len(tc.Env))
+	for name, value := range env {
+		caseEnv[name] = value
+	}
+	for name, value := range tc.Env {
+		caseEnv[name] = value
+	}
+
+	start := time.Now()
+	ret, err := root.Execute(ctx, tc.Args, append(opts[:len(opts):len(opts)], workflow.WithEnv(caseEnv))...)
+	return &Result{
+		Name:     tc.Name,
+		Duration: time.Since(start),
+		Failure:  tc.check(ret, err),
+	}
+}
+
+// check returns the failure message of the result or the error of the execution, or empty if it is expected.
+func (tc *TestCase) check(ret any, err error) string {
+	var exception types.Exception
+	if err != nil && !errors.As(err, &exception) {
+		return fmt.Sprintf("failed to execute workflow: %v", err)
+	}
+
+	if tc.exception != nil {
+		if exception == nil {
+			return fmt.Sprintf("expected an exception but succeeded with the result: %s", formatValue(ret))
+		}
+		if actual := normalize(exception.Exception()); !matchSubset(tc.exception, actual) {
+			return fmt.Sprintf("exception mismatch (-want +got):\n%s", cmp.Diff(tc.exception, actual))
+		}
+		return ""
+	}
+
+	if exception != nil {
+		return fmt.Sprintf("unexpected exception: %s", formatValue(exception.Exception()))
+	}
+	if actual := normalize(ret); tc.hasResult && !cmp.Equal(tc.result, actual) {
+		return fmt.Sprintf("result mismatch (-want +got):\n%s", cmp.Diff(tc.result, actual))
+	}
+	return ""
+}
+
+// normalize converts the value to the JSON values to compare it with the expected ones (e.g. the integers to float64).
+func normalize(value any) any {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+// matchSubset reports whether the actual value has all fields of the expected maps, and the others are equal.
+func matchSubset(expected, actual any) bool {
+	switch expected := expected.(type) {
+	case map[string]any:
+		m, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, value := range expected {
+			if v, ok := m[key]; !ok || !matchSubset(value, v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		l, ok := actual.([]any)
+		if !ok || len(l) != len(expected) {
+			return false
+		}
+		for i, value := range expected {
+			if !matchSubset(value, l[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return cmp.Equal(expected, actual)
+	}
+}
+
+func formatValue(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
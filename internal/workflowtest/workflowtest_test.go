@@ -0,0 +1,228 @@
+package workflowtest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflowtest"
+)
+
+// testRunner is shared by the tests since NewRunner wraps the transport of the process.
+var testRunner = workflowtest.NewRunner()
+
+func TestParseTestCasesYAML(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		source   string
+		expected []*workflowtest.TestCase // compared by the exported fields except HTTPMocks
+		wantErr  bool
+	}{
+		{
+			name: "named and unnamed cases",
+			source: `
+- name: greets
+  workflow: greet
+  args: {name: alice}
+  env: {GREETING: hello}
+  expect:
+    result: hello alice
+- expect:
+    exception: {tags: [HttpError]}
+`,
+			expected: []*workflowtest.TestCase{
+				{Name: "greets", Workflow: "greet", Args: map[string]any{"name": "alice"}, Env: map[string]string{"GREETING": "hello"}},
+				{Name: "#2"},
+			},
+		},
+		{
+			name: "both of result and exception",
+			source: `
+- expect:
+    result: ok
+    exception: {tags: [HttpError]}
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid HTTP mocks",
+			source: `
+- httpMocks: {url: https://example.com}
+`,
+			wantErr: true,
+		},
+		{
+			name:    "not a list",
+			source:  `name: greets`,
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testCases, err := workflowtest.ParseTestCasesYAML(strings.NewReader(tt.source))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, testCases, cmp.Comparer(func(a, b *workflowtest.TestCase) bool {
+				return a.Name == b.Name && a.Workflow == b.Workflow && cmp.Equal(a.Args, b.Args) && cmp.Equal(a.Env, b.Env)
+			})); diff != "" {
+				t.Errorf("unexpected test cases (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestRunner isn't parallel since the runner replaces the transport of the process by the HTTP mocks of the test cases.
+func TestRunner(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"real"}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	const source = `
+main:
+  params: [args]
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: ${args.url}
+        result: res
+    - done:
+        return: ${sys.get_env("GREETING", "hi") + " " + res.body.name}
+`
+	root, err := workflow.ParseWorkflowYAML(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name            string
+		testCase        string
+		env             map[string]string
+		expectedFailure string // the first line of the failure, or empty if passed
+	}{
+		{
+			name: "mocked result",
+			testCase: `
+- args: {url: "https://api.example.com/users/1"}
+  httpMocks:
+    - url: https://api.example.com/users/*
+      response: {body: {name: alice}}
+  expect:
+    result: hi alice
+`,
+		},
+		{
+			name: "env of the test case over the others",
+			testCase: `
+- args: {url: "https://api.example.com/users/1"}
+  env: {GREETING: hello}
+  httpMocks:
+    - url: https://api.example.com/users/*
+      response: {body: {name: alice}}
+  expect:
+    result: hello alice
+`,
+			env: map[string]string{"GREETING": "hey"},
+		},
+		{
+			name: "real request without mocks",
+			testCase: `
+- args: {url: "` + ts.URL + `"}
+  expect:
+    result: hi real
+`,
+		},
+		{
+			name: "result mismatch",
+			testCase: `
+- args: {url: "https://api.example.com/users/1"}
+  httpMocks:
+    - url: https://api.example.com/users/*
+      response: {body: {name: alice}}
+  expect:
+    result: hi bob
+`,
+			expectedFailure: "result mismatch (-want +got):",
+		},
+		{
+			name: "expected exception",
+			testCase: `
+- args: {url: "https://api.example.com/users/1"}
+  httpMocks:
+    - url: https://api.example.com/users/*
+      response: {status: 404, body: {}}
+  expect:
+    exception: {tags: [HttpError], code: 404}
+`,
+		},
+		{
+			name: "exception mismatch",
+			testCase: `
+- args: {url: "https://api.example.com/users/1"}
+  httpMocks:
+    - url: https://api.example.com/users/*
+      response: {status: 404, body: {}}
+  expect:
+    exception: {code: 500}
+`,
+			expectedFailure: "exception mismatch (-want +got):",
+		},
+		{
+			name: "unexpected exception",
+			testCase: `
+- args: {url: "https://api.example.com/users/1"}
+  httpMocks:
+    - url: https://api.example.com/users/*
+      response: {status: 404, body: {}}
+  expect:
+    result: hi alice
+`,
+			expectedFailure: `unexpected exception: {"body":{},"code":404,"headers":{"Content-Type":"application/json"},"message":"HTTP server responded with error code 404","tags":["HttpError"],"url":"https://api.example.com/users/1"}`,
+		},
+		{
+			name: "succeeded unexpectedly",
+			testCase: `
+- args: {url: "https://api.example.com/users/1"}
+  httpMocks:
+    - url: https://api.example.com/users/*
+      response: {body: {name: alice}}
+  expect:
+    exception: {tags: [HttpError]}
+`,
+			expectedFailure: `expected an exception but succeeded with the result: "hi alice"`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			testCases, err := workflowtest.ParseTestCasesYAML(strings.NewReader(tt.testCase))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := testRunner.Run(context.Background(), root, testCases[0], tt.env)
+			if failure, _, _ := strings.Cut(result.Failure, "\n"); failure != tt.expectedFailure {
+				t.Errorf("unexpected failure: %s, want %s", result.Failure, tt.expectedFailure)
+			}
+			if result.Passed() != (tt.expectedFailure == "") {
+				t.Errorf("unexpected passed: %v", result.Passed())
+			}
+		})
+	}
+}
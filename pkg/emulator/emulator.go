@@ -0,0 +1,324 @@
+// Package emulator is the Go API to embed the emulator of Google Cloud Workflows into the Go programs, e.g. to execute
+// the workflows in process in the tests of the services calling them, or to serve the emulated APIs by httptest.Server.
+//
+//	wf, err := emulator.LoadFile("./workflows/order.yaml")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	result, err := wf.Execute(ctx, map[string]any{"id": "1"}, emulator.WithFakeTime(start))
+//	if exception, ok := emulator.AsException(err); ok {
+//		t.Logf("tags: %v", exception.Tags())
+//	}
+package emulator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/defaults"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/types"
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+// Workflow is the parsed workflow definition with its subworkflows.
+type Workflow struct {
+	root   workflow.WorkflowRoot
+	source []byte
+}
+
+// ParseYAML parses the workflow definition in YAML.
+func ParseYAML(source []byte) (*Workflow, error) {
+	root, err := workflow.ParseWorkflowYAML(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	return &Workflow{root: root, source: source}, nil
+}
+
+// ParseJSON parses the workflow definition in JSON.
+func ParseJSON(source []byte) (*Workflow, error) {
+	root, err := workflow.ParseWorkflowJSON(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	return &Workflow{root: root, source: source}, nil
+}
+
+// LoadFile parses the workflow definition file in YAML (.yaml) or JSON (.json).
+func LoadFile(filePath string) (*Workflow, error) {
+	var parse func([]byte) (*Workflow, error)
+	switch filepath.Ext(filePath) {
+	case ".yaml":
+		parse = ParseYAML
+	case ".json":
+		parse = ParseJSON
+	default:
+		return nil, fmt.Errorf("unsupported file extension: %s", filePath)
+	}
+
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(%q): %w", filePath, err)
+	}
+	wf, err := parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return wf, nil
+}
+
+// Execute executes the main workflow with the arguments, which are passed as they are (e.g. the maps of any and the
+// lists of any decoded from JSON), and returns the result. The uncaught exceptions are returned as *Exception.
+func (w *Workflow) Execute(ctx context.Context, args any, opts ...Option) (any, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := w.root.Execute(ctx, args, c.executeOptions()...)
+	var exception types.Exception
+	if errors.As(err, &exception) {
+		return nil, &Exception{err: err, value: exception.Exception()}
+	}
+	return ret, err
+}
+
+// Exception is the uncaught exception of the execution.
+type Exception struct {
+	err   error
+	value any
+}
+
+// AsException returns the uncaught exception of the error returned by Execute if it is.
+func AsException(err error) (*Exception, bool) {
+	var exception *Exception
+	ok := errors.As(err, &exception)
+	return exception, ok
+}
+
+func (e *Exception) Error() string {
+	return e.err.Error()
+}
+
+func (e *Exception) Unwrap() error {
+	return e.err
+}
+
+// Value returns the value of the exception caught by the except blocks, which is a map like
+// {"message": "...", "tags": ["HttpError"]} or the raised value.
+func (e *Exception) Value() any {
+	return e.value
+}
+
+// Tags returns the tags of the exception, or nil if it is not a map with the tags.
+func (e *Exception) Tags() []string {
+	m, ok := e.value.(map[string]any)
+	if !ok {
+		return nil
+	}
+	list, _ := m["tags"].([]any)
+	tags := make([]string, 0, len(list))
+	for _, tag := range list {
+		tags = append(tags, fmt.Sprint(tag))
+	}
+	return tags
+}
+
+// HasTag reports whether the exception has the tag (e.g. HttpError).
+func (e *Exception) HasTag(tag string) bool {
+	for _, t := range e.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecutionInfo is the information of the executions exposed as the built-in environment variables like
+// GOOGLE_CLOUD_PROJECT_ID. The empty fields are not exposed.
+type ExecutionInfo struct {
+	ProjectID     string
+	ProjectNumber string
+	Location      string
+	WorkflowID    string
+}
+
+// Option configures the executions.
+type Option func(*config)
+
+type config struct {
+	info              ExecutionInfo
+	env               map[string]string
+	serializeParallel bool
+	newClock          func() defaults.Clock
+	seed              int64
+	symbols           map[string]any
+	httpClient        *http.Client
+	hooks             []Hooks
+	extensions        bool
+	strict            bool
+}
+
+// ErrStrictWithExtensions is returned for the options of both WithStrict and WithExtensions like
+// --strict and --extensions of the CLI.
+var ErrStrictWithExtensions = errors.New("WithStrict and WithExtensions are exclusive")
+
+func newConfig(opts []Option) (*config, error) {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.strict && c.extensions {
+		return nil, ErrStrictWithExtensions
+	}
+	return c, nil
+}
+
+func (c *config) executeOptions() []workflow.ExecuteOption {
+	opts := []workflow.ExecuteOption{
+		workflow.SerializeParallel(c.serializeParallel),
+		workflow.WithExecutionInfo(workflow.ExecutionInfo{
+			ProjectID:     c.info.ProjectID,
+			ProjectNumber: c.info.ProjectNumber,
+			Location:      c.info.Location,
+			WorkflowID:    c.info.WorkflowID,
+		}),
+		workflow.WithEnv(c.env),
+	}
+	if c.newClock != nil {
+		opts = append(opts, workflow.WithClock(c.newClock))
+	}
+	if seed := c.seed; seed != 0 {
+		opts = append(opts, workflow.WithRandom(func() io.Reader {
+			return defaults.NewSeededRandom(seed)
+		}))
+	}
+	if c.httpClient != nil {
+		opts = append(opts, workflow.WithHTTPClient(c.httpClient))
+	}
+	if len(c.hooks) != 0 {
+		opts = append(opts, workflow.WithHooks(c.hooks...))
+	}
+	if c.strict {
+		opts = append(opts, workflow.WithLimits(workflow.ProductionLimits), workflow.StrictArgs(true))
+	}
+	if c.extensions {
+		opts = append(opts, workflow.WithSymbols(defaults.Extensions))
+	}
+	if len(c.symbols) != 0 {
+		opts = append(opts, workflow.WithSymbols(c.symbols))
+	}
+	return opts
+}
+
+// WithExecutionInfo exposes the information of the executions as the built-in environment variables.
+func WithExecutionInfo(info ExecutionInfo) Option {
+	return func(c *config) {
+		c.info = info
+	}
+}
+
+// WithEnv sets the user-defined environment variables for sys.get_env, which hide the ones of the process except GOOGLE_CLOUD_*.
+func WithEnv(env map[string]string) Option {
+	return func(c *config) {
+		c.env = env
+	}
+}
+
+// WithSerializeParallel executes the parallel steps sequentially in the declaration order.
+func WithSerializeParallel() Option {
+	return func(c *config) {
+		c.serializeParallel = true
+	}
+}
+
+// WithFakeTime starts each execution at the time of the fake clock read by sys.now, which is advanced by the sleeps
+// and the backoffs of the retries without waiting for them.
+func WithFakeTime(start time.Time) Option {
+	return func(c *config) {
+		c.newClock = func() defaults.Clock { return defaults.NewFakeClock(start) }
+	}
+}
+
+// WithVirtualTime starts each execution at the time of the fake clock, which jumps to the earliest deadline of the
+// sleeps and the timeouts of events.await_callback when all branches of the execution wait for them.
+func WithVirtualTime(start time.Time) Option {
+	return func(c *config) {
+		c.newClock = func() defaults.Clock { return defaults.NewVirtualClock(start) }
+	}
+}
+
+// WithSeed makes each execution generate the reproducible UUIDs of uuid.generate and the execution IDs by the seed.
+func WithSeed(seed int64) Option {
+	return func(c *config) {
+		c.seed = seed
+	}
+}
+
+// WithHTTPClient makes the executions send the outbound HTTP requests of http.* and the connectors by the client, e.g.
+// httptest.Server.Client(). The redirects are followed up to 10 times unless the client has its own CheckRedirect.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithHTTPTransport makes the executions send the outbound HTTP requests of http.* and the connectors by the transport,
+// e.g. to mock the services or to authenticate the requests by the custom credentials in the tests.
+func WithHTTPTransport(transport http.RoundTripper) Option {
+	return WithHTTPClient(&http.Client{Transport: transport})
+}
+
+// WithExtensions enables the convenience functions of the emulator which Cloud Workflows doesn't have (list.filter,
+// list.map, list.sort, math.floor, math.round, math.pow, time.add and text.join).
+func WithExtensions() Option {
+	return func(c *config) {
+		c.extensions = true
+	}
+}
+
+// WithStrict checks the compatibility with Cloud Workflows by the production limits of the executed steps, the call
+// depth and the size of the variables, and by raising TypeError for the named arguments which the functions don't
+// have. It's exclusive with WithExtensions, see ErrStrictWithExtensions.
+func WithStrict() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WithFunction registers the function into the standard library by the dotted name (e.g. company.text.slugify), or
+// replaces the standard one. It's called with the arguments bound to the parameters by the positions or the names,
+// which are the values as they are in the workflows (e.g. int64, float64, string, bool, nil, []any and map[string]any).
+// The error is raised as the exception tagged SystemError in the workflows unless it's the context error.
+func WithFunction(name string, params []string, fn func(ctx context.Context, args []any) (any, error)) Option {
+	args := make([]types.Argument, len(params))
+	for i, param := range params {
+		args[i] = types.Argument{Name: param}
+	}
+	return WithSymbol(name, types.NewRawFunction(name, args, func(ctx context.Context, args []any) (any, error) {
+		ret, err := fn(ctx, args)
+		if err != nil && ctx.Err() == nil {
+			var exception types.Exception
+			if !errors.As(err, &exception) {
+				err = &types.Error{Tag: types.SystemErrorTag, Err: err}
+			}
+		}
+		return ret, err
+	}))
+}
+
+// WithSymbol registers the value into the standard library by the dotted name (e.g. company.regions).
+func WithSymbol(name string, value any) Option {
+	return func(c *config) {
+		if c.symbols == nil {
+			c.symbols = map[string]any{}
+		}
+		c.symbols[name] = value
+	}
+}
@@ -0,0 +1,543 @@
+package emulator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/pkg/emulator"
+)
+
+func TestWorkflowExecute(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s", r.URL.Query().Get("name"))
+	}))
+	t.Cleanup(ts.Close)
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello TLS")
+	}))
+	t.Cleanup(tlsServer.Close)
+
+	double := emulator.WithFunction("company.math.double", []string{"n"}, func(ctx context.Context, args []any) (any, error) {
+		n, ok := args[0].(int64)
+		if !ok {
+			return nil, errors.New("n must be an integer")
+		}
+		return n * 2, nil
+	})
+
+	for _, tt := range []struct {
+		name         string
+		expr         string
+		opts         []emulator.Option
+		expected     any
+		expectedTag  string
+		expectedErr  error
+		expectToFail bool
+	}{
+		{
+			name:     "function",
+			expr:     `company.math.double(21)`,
+			opts:     []emulator.Option{double},
+			expected: int64(42),
+		},
+		{
+			name:        "function error",
+			expr:        `company.math.double("a")`,
+			opts:        []emulator.Option{double},
+			expectedTag: "SystemError",
+		},
+		{
+			name:     "symbol",
+			expr:     `company.region`,
+			opts:     []emulator.Option{emulator.WithSymbol("company.region", "asia-northeast1")},
+			expected: "asia-northeast1",
+		},
+		{
+			name:     "env",
+			expr:     `sys.get_env("FOO", "none")`,
+			opts:     []emulator.Option{emulator.WithEnv(map[string]string{"FOO": "bar"})},
+			expected: "bar",
+		},
+		{
+			name:     "execution info",
+			expr:     `sys.get_env("GOOGLE_CLOUD_PROJECT_ID", "none")`,
+			opts:     []emulator.Option{emulator.WithExecutionInfo(emulator.ExecutionInfo{ProjectID: "my-project"})},
+			expected: "my-project",
+		},
+		{
+			name:     "fake time",
+			expr:     `sys.now()`,
+			opts:     []emulator.Option{emulator.WithFakeTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+			expected: int64(1704067200),
+		},
+		{
+			name:     "HTTP transport",
+			expr:     `text.decode(map.get(http.get("` + ts.URL + `?name=alice"), "body"))`,
+			opts:     []emulator.Option{emulator.WithHTTPTransport(ts.Client().Transport)},
+			expected: "hello alice",
+		},
+		{
+			name:     "HTTP client",
+			expr:     `text.decode(map.get(http.get("` + tlsServer.URL + `"), "body"))`,
+			opts:     []emulator.Option{emulator.WithHTTPClient(tlsServer.Client())},
+			expected: "hello TLS",
+		},
+		{
+			name:         "HTTP client not given",
+			expr:         `text.decode(map.get(http.get("` + tlsServer.URL + `"), "body"))`,
+			expectToFail: true,
+		},
+		{
+			name:     "extensions",
+			expr:     `math.floor(1.5)`,
+			opts:     []emulator.Option{emulator.WithExtensions()},
+			expected: int64(1),
+		},
+		{
+			name:         "extensions not enabled",
+			expr:         `math.floor(1.5)`,
+			expectToFail: true,
+		},
+		{
+			name:        "strict with extensions",
+			expr:        `1`,
+			opts:        []emulator.Option{emulator.WithStrict(), emulator.WithExtensions()},
+			expectedErr: emulator.ErrStrictWithExtensions,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			wf, err := emulator.ParseYAML([]byte(`
+main:
+  steps:
+    - done:
+        return: '${` + tt.expr + `}'
+`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := wf.Execute(context.Background(), nil, tt.opts...)
+			switch {
+			case tt.expectedTag != "":
+				if exception, ok := emulator.AsException(err); !ok || !exception.HasTag(tt.expectedTag) {
+					t.Fatalf("should be %s but got %v", tt.expectedTag, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			case tt.expectedErr != nil:
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("should be %v but got %v", tt.expectedErr, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			case tt.expectToFail:
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"hello.yaml":   "main:\n  steps:\n    - done:\n        return: hello yaml\n",
+		"hello.json":   `{"main": {"steps": [{"done": {"return": "hello json"}}]}}`,
+		"hello.txt":    "main:\n  steps:\n    - done:\n        return: hello txt\n",
+		"invalid.yaml": "main:\n  steps: 1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		file     string
+		expected any
+		wantErr  bool
+	}{
+		{
+			name:     "YAML",
+			file:     "hello.yaml",
+			expected: "hello yaml",
+		},
+		{
+			name:     "JSON",
+			file:     "hello.json",
+			expected: "hello json",
+		},
+		{
+			name:    "unsupported extension",
+			file:    "hello.txt",
+			wantErr: true,
+		},
+		{
+			name:    "not found",
+			file:    "missing.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "invalid workflow",
+			file:    "invalid.yaml",
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			wf, err := emulator.LoadFile(filepath.Join(dir, tt.file))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := wf.Execute(context.Background(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestException(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name          string
+		raise         string
+		expectedValue any
+		expectedTags  []string
+	}{
+		{
+			name:          "string",
+			raise:         `boom`,
+			expectedValue: "boom",
+		},
+		{
+			name: "map",
+			raise: `
+          message: boom
+          tags: [ValueError]`,
+			expectedValue: map[string]any{"message": "boom", "tags": []any{"ValueError"}},
+			expectedTags:  []string{"ValueError"},
+		},
+		{
+			name: "map without tags",
+			raise: `
+          code: 1`,
+			expectedValue: map[string]any{"code": 1.0},
+			expectedTags:  []string{},
+		},
+		{
+			name:          "invalid raise",
+			raise:         `${1 / 0}`,
+			expectedValue: map[string]any{"message": "TypeError: invalid raise: must be string or map", "tags": []any{"TypeError"}},
+			expectedTags:  []string{"TypeError"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			wf, err := emulator.ParseYAML([]byte(`
+main:
+  steps:
+    - fail:
+        raise: ` + tt.raise + `
+`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = wf.Execute(context.Background(), nil)
+			exception, ok := emulator.AsException(err)
+			if !ok {
+				t.Fatalf("should be exception but got %v", err)
+			}
+			if diff := cmp.Diff(tt.expectedValue, exception.Value()); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedTags, exception.Tags()); diff != "" {
+				t.Errorf("unexpected tags (-want +got):\n%s", diff)
+			}
+			for _, tag := range tt.expectedTags {
+				if !exception.HasTag(tag) {
+					t.Errorf("should have tag %s", tag)
+				}
+			}
+			if exception.HasTag("HttpError") {
+				t.Error("should not have tag HttpError")
+			}
+		})
+	}
+}
+
+func TestExecuteOptions(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name     string
+		source   string
+		opts     []emulator.Option
+		expected any
+	}{
+		{
+			name: "serialize parallel",
+			source: `
+main:
+  steps:
+    - init:
+        assign:
+          - order: []
+    - branches:
+        parallel:
+          shared: [order]
+          for:
+            value: i
+            in: [1, 2, 3, 4, 5]
+            steps:
+              - append:
+                  assign:
+                    - order: ${list.concat(order, i)}
+    - done:
+        return: ${order}
+`,
+			opts:     []emulator.Option{emulator.WithSerializeParallel()},
+			expected: []any{int64(1), int64(2), int64(3), int64(4), int64(5)},
+		},
+		{
+			name: "seed",
+			source: `
+main:
+  steps:
+    - done:
+        return: ${uuid.generate()}
+`,
+			opts:     []emulator.Option{emulator.WithSeed(1)},
+			expected: "9566c74d-1003-4c4d-bbbb-0407d1e2c649",
+		},
+		{
+			name: "virtual time",
+			source: `
+main:
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 3600
+    - done:
+        return: ${sys.now()}
+`,
+			opts:     []emulator.Option{emulator.WithVirtualTime(start)},
+			expected: int64(1704070800),
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			wf, err := emulator.ParseYAML([]byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := wf.Execute(context.Background(), nil, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithFunction(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name        string
+		expr        string
+		fn          func(ctx context.Context, args []any) (any, error)
+		expected    any
+		expectedTag string
+		expectedErr error
+	}{
+		{
+			name: "positional arguments",
+			expr: `company.join("a", 1)`,
+			fn: func(ctx context.Context, args []any) (any, error) {
+				return fmt.Sprintf("%v-%v", args[0], args[1]), nil
+			},
+			expected: "a-1",
+		},
+		{
+			name: "argument values",
+			expr: `company.join(1.5, null)`,
+			fn: func(ctx context.Context, args []any) (any, error) {
+				return args, nil
+			},
+			expected: []any{1.5, nil},
+		},
+		{
+			name: "error",
+			expr: `company.join("a", "b")`,
+			fn: func(ctx context.Context, args []any) (any, error) {
+				return nil, errors.New("boom")
+			},
+			expectedTag: "SystemError",
+		},
+		{
+			name: "context error",
+			expr: `company.join("a", "b")`,
+			fn: func(ctx context.Context, args []any) (any, error) {
+				cancelCtx, cancel := context.WithCancel(ctx)
+				cancel()
+				return nil, cancelCtx.Err()
+			},
+			expectedErr: context.Canceled,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			wf, err := emulator.ParseYAML([]byte(`
+main:
+  steps:
+    - done:
+        return: '${` + tt.expr + `}'
+`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := wf.Execute(context.Background(), nil, emulator.WithFunction("company.join", []string{"a", "b"}, tt.fn))
+			switch {
+			case tt.expectedTag != "":
+				if exception, ok := emulator.AsException(err); !ok || !exception.HasTag(tt.expectedTag) {
+					t.Fatalf("should be %s but got %v", tt.expectedTag, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			case tt.expectedErr != nil:
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("should be %v but got %v", tt.expectedErr, err)
+				}
+				t.Logf("expected error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, ret); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	t.Parallel()
+
+	wf, err := emulator.ParseYAML([]byte(`
+main:
+  steps:
+    - get:
+        call: sys.get_env
+        args:
+          name: FOO
+          default: none
+          unknown: 1
+        result: value
+    - done:
+        return: ${value}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret, err := wf.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	} else if ret != "none" {
+		t.Errorf("unexpected result: %v", ret)
+	}
+
+	_, err = wf.Execute(context.Background(), nil, emulator.WithStrict())
+	if exception, ok := emulator.AsException(err); !ok || !exception.HasTag("TypeError") {
+		t.Fatalf("should be TypeError but got %v", err)
+	}
+	t.Logf("expected error: %v", err)
+}
+
+func TestNewServer(t *testing.T) {
+	t.Parallel()
+
+	wf, err := emulator.ParseYAML([]byte(`
+main:
+  steps:
+    - done:
+        return: hello
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := emulator.NewServer(map[string]*emulator.Workflow{"hello": wf}, emulator.WithStrict(), emulator.WithExtensions()); !errors.Is(err, emulator.ErrStrictWithExtensions) {
+		t.Fatalf("should be %v but got %v", emulator.ErrStrictWithExtensions, err)
+	}
+
+	emu, err := emulator.NewServer(map[string]*emulator.Workflow{"hello": wf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(emu)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { _ = emu.Shutdown(context.Background()) })
+
+	res, err := http.Get(srv.URL + "/v1/projects/my-project/locations/us-central1/workflows/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status: %d", res.StatusCode)
+	}
+}
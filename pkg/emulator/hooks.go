@@ -0,0 +1,26 @@
+package emulator
+
+import (
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/workflow"
+)
+
+type (
+	// Hooks are called around the steps, the call steps and the exceptions of the executions, see WithHooks.
+	Hooks = workflow.Hooks
+	// NopHooks is the Hooks doing nothing to be embedded into the hooks implementing a part of the methods.
+	NopHooks = workflow.NopHooks
+	// StepState is the state of the execution before a step given to the hooks.
+	StepState = workflow.StepState
+	// StepName is the name of a step.
+	StepName = workflow.StepName
+	// CallEvent is the call of the function by the call step given to the hooks.
+	CallEvent = workflow.CallEvent
+)
+
+// WithHooks makes the executions call the hooks for the custom instrumentation, e.g. to measure the durations of the
+// steps and the calls, or to collect the executed steps as the coverage.
+func WithHooks(hooks ...Hooks) Option {
+	return func(c *config) {
+		c.hooks = append(c.hooks, hooks...)
+	}
+}
@@ -0,0 +1,148 @@
+package emulator_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/pkg/emulator"
+)
+
+// coverageHooks collects the executed steps, the called functions and the handlings of the exceptions.
+type coverageHooks struct {
+	emulator.NopHooks
+	mu         sync.Mutex
+	steps      []emulator.StepName
+	calls      []string
+	exceptions []string
+}
+
+func (h *coverageHooks) BeforeStep(_ context.Context, state *emulator.StepState) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.steps = append(h.steps, state.Step)
+	return nil
+}
+
+func (h *coverageHooks) OnCall(_ context.Context, call *emulator.CallEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call.Function)
+}
+
+func (h *coverageHooks) OnException(_ context.Context, _ any, handling string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.exceptions = append(h.exceptions, handling)
+}
+
+func TestWithHooks(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name               string
+		source             string
+		serializeParallel  bool
+		expectedSteps      []emulator.StepName
+		expectedCalls      []string
+		expectedExceptions []string
+		wantErr            bool
+	}{
+		{
+			name: "steps and calls",
+			source: `
+main:
+  steps:
+    - get:
+        call: sys.get_env
+        args:
+          name: FOO
+          default: none
+        result: foo
+    - done:
+        return: ${foo}
+`,
+			expectedSteps: []emulator.StepName{"get", "done"},
+			expectedCalls: []string{"sys.get_env"},
+		},
+		{
+			name: "parallel branches",
+			source: `
+main:
+  steps:
+    - branches:
+        parallel:
+          branches:
+            - first:
+                steps:
+                  - log_first:
+                      call: sys.log
+                      args:
+                        text: first
+            - second:
+                steps:
+                  - log_second:
+                      call: sys.log
+                      args:
+                        text: second
+`,
+			serializeParallel: true,
+			expectedSteps:     []emulator.StepName{"branches", "log_first", "log_second"},
+			expectedCalls:     []string{"sys.log", "sys.log"},
+		},
+		{
+			name: "exceptions",
+			source: `
+main:
+  steps:
+    - try_fail:
+        try:
+          raise: boom
+        except:
+          as: e
+          steps:
+            - fail:
+                raise: ${e}
+`,
+			expectedSteps:      []emulator.StepName{"try_fail", "fail"},
+			expectedExceptions: []string{"except", "uncaught"},
+			wantErr:            true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			wf, err := emulator.ParseYAML([]byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hooks := &coverageHooks{}
+			opts := []emulator.Option{emulator.WithHooks(hooks)}
+			if tt.serializeParallel {
+				opts = append(opts, emulator.WithSerializeParallel())
+			}
+			_, err = wf.Execute(context.Background(), nil, opts...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("should be error")
+				}
+				t.Logf("expected error: %v", err)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.expectedSteps, hooks.steps); diff != "" {
+				t.Errorf("unexpected steps (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedCalls, hooks.calls); diff != "" {
+				t.Errorf("unexpected calls (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedExceptions, hooks.exceptions); diff != "" {
+				t.Errorf("unexpected exceptions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
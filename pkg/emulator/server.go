@@ -0,0 +1,60 @@
+package emulator
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/internal/server"
+)
+
+// Server is the http.Handler serving the emulated Workflows and Workflow Executions REST APIs of the workflows,
+// e.g. by httptest.Server for the Go clients of the APIs in the tests.
+//
+//	emu, err := emulator.NewServer(map[string]*emulator.Workflow{"order": wf})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	srv := httptest.NewServer(emu)
+//	defer srv.Close()
+//	defer emu.Shutdown(context.Background())
+//	emu.SetCallbackBaseURL(srv.URL)
+type Server struct {
+	store   *server.ExecutionStore
+	handler http.Handler
+}
+
+// NewServer returns the server of the workflows by the workflow IDs, which executes them by the options.
+func NewServer(workflows map[string]*Workflow, opts ...Option) (*Server, error) {
+	loaded := make(map[string]*server.LoadedWorkflow, len(workflows))
+	for id, wf := range workflows {
+		loaded[id] = &server.LoadedWorkflow{Root: wf.root, Source: wf.source}
+	}
+
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	store, err := server.NewExecutionStore(func() (map[string]*server.LoadedWorkflow, error) {
+		return loaded, nil
+	}, c.executeOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{store: store, handler: server.NewHTTPHandler(store)}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// SetCallbackBaseURL makes the executions host the callback endpoints of events.create_callback_endpoint under the
+// URL of the server (e.g. httptest.Server.URL) instead of the ad-hoc local ports. It must be called before the
+// executions are created.
+func (s *Server) SetCallbackBaseURL(baseURL string) {
+	s.store.SetCallbackBaseURL(baseURL)
+}
+
+// Shutdown waits for the in-flight executions, and cancels them when the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.store.Shutdown(ctx)
+}
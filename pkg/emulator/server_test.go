@@ -0,0 +1,260 @@
+package emulator_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/pkg/emulator"
+)
+
+const testWorkflowsPath = "/v1/projects/my-project/locations/us-central1/workflows"
+
+type testExecution struct {
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Result string `json:"result"`
+	Error  *struct {
+		Payload string `json:"payload"`
+	} `json:"error"`
+}
+
+func newTestServer(t *testing.T, sources map[string]string, opts ...emulator.Option) (*emulator.Server, *httptest.Server) {
+	t.Helper()
+
+	workflows := make(map[string]*emulator.Workflow, len(sources))
+	for id, source := range sources {
+		wf, err := emulator.ParseYAML([]byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		workflows[id] = wf
+	}
+
+	emu, err := emulator.NewServer(workflows, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(emu)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { _ = emu.Shutdown(context.Background()) })
+	emu.SetCallbackBaseURL(srv.URL)
+	return emu, srv
+}
+
+func createExecution(t *testing.T, srv *httptest.Server, workflowID, argument string) (testExecution, int) {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]any{"argument": argument})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Post(srv.URL+testWorkflowsPath+"/"+workflowID+"/executions", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var ex testExecution
+	if res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(&ex); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ex, res.StatusCode
+}
+
+func waitExecution(t *testing.T, srv *httptest.Server, name string) testExecution {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		res, err := http.Get(srv.URL + "/v1/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ex testExecution
+		err = json.NewDecoder(res.Body).Decode(&ex)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ex.State != "ACTIVE" {
+			return ex
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("execution is not finished: %+v", ex)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name                 string
+		source               string
+		opts                 []emulator.Option
+		argument             string
+		expectedState        string
+		expectedResult       string
+		expectedResultPrefix string
+		expectedError        string
+	}{
+		{
+			name: "arguments",
+			source: `
+main:
+  params: [args]
+  steps:
+    - done:
+        return: '${"hello " + args.name}'
+`,
+			argument:       `{"name":"alice"}`,
+			expectedState:  "SUCCEEDED",
+			expectedResult: `"hello alice"`,
+		},
+		{
+			name: "options",
+			source: `
+main:
+  steps:
+    - done:
+        return: '${sys.get_env("FOO", "none")}'
+`,
+			opts:           []emulator.Option{emulator.WithEnv(map[string]string{"FOO": "bar"})},
+			expectedState:  "SUCCEEDED",
+			expectedResult: `"bar"`,
+		},
+		{
+			name: "uncaught exception",
+			source: `
+main:
+  steps:
+    - fail:
+        raise: boom
+`,
+			expectedState: "FAILED",
+			expectedError: `"boom"`,
+		},
+		{
+			name: "callback base URL",
+			source: `
+main:
+  steps:
+    - create_callback:
+        call: events.create_callback_endpoint
+        result: callback
+    - done:
+        return: ${callback.url}
+`,
+			expectedState:        "SUCCEEDED",
+			expectedResultPrefix: `"%s/`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, srv := newTestServer(t, map[string]string{"wf": tt.source}, tt.opts...)
+			ex, status := createExecution(t, srv, "wf", tt.argument)
+			if status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+
+			ex = waitExecution(t, srv, ex.Name)
+			if ex.State != tt.expectedState {
+				t.Fatalf("unexpected state: %+v", ex)
+			}
+			switch {
+			case tt.expectedResultPrefix != "":
+				if prefix := strings.Replace(tt.expectedResultPrefix, "%s", srv.URL, 1); !strings.HasPrefix(ex.Result, prefix) {
+					t.Errorf("unexpected result: %s, should start with %s", ex.Result, prefix)
+				}
+			case tt.expectedError != "":
+				if ex.Error == nil {
+					t.Fatalf("should have error: %+v", ex)
+				}
+				if diff := cmp.Diff(tt.expectedError, ex.Error.Payload); diff != "" {
+					t.Errorf("unexpected error payload (-want +got):\n%s", diff)
+				}
+			default:
+				if diff := cmp.Diff(tt.expectedResult, ex.Result); diff != "" {
+					t.Errorf("unexpected result (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name          string
+		source        string
+		timeout       time.Duration
+		expectedErr   error
+		expectedState string
+	}{
+		{
+			name: "finished in time",
+			source: `
+main:
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 0.1
+`,
+			timeout:       10 * time.Second,
+			expectedState: "SUCCEEDED",
+		},
+		{
+			name: "cancelled by timeout",
+			source: `
+main:
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 60
+`,
+			timeout:       100 * time.Millisecond,
+			expectedErr:   context.DeadlineExceeded,
+			expectedState: "CANCELLED",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			emu, srv := newTestServer(t, map[string]string{"wf": tt.source})
+			ex, status := createExecution(t, srv, "wf", "")
+			if status != http.StatusOK {
+				t.Fatalf("unexpected status: %d", status)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := emu.Shutdown(ctx); !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("should be %v but got %v", tt.expectedErr, err)
+			}
+
+			if ex = waitExecution(t, srv, ex.Name); ex.State != tt.expectedState {
+				t.Errorf("unexpected state: %+v", ex)
+			}
+			if _, status := createExecution(t, srv, "wf", ""); status == http.StatusOK {
+				t.Error("should not accept the executions after the shutdown")
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Package workflowtest is the helper of the Go tests of the workflows, which loads and executes them in process by
+// pkg/emulator and asserts on the results or the exceptions.
+//
+//	func TestOrder(t *testing.T) {
+//		wf := workflowtest.Load(t, "./workflows/order.yaml",
+//			workflowtest.FreezeTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+//			workflowtest.HandleHTTP(mux), // serves http.* and the connectors in process
+//		)
+//		wf.Run(map[string]any{"id": "1"}).AssertResult(map[string]any{"status": "shipped"})
+//		wf.Run(map[string]any{}).AssertException("KeyError")
+//	}
+package workflowtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/karupanerura/google-cloud-workflow-emulator/pkg/emulator"
+)
+
+// Workflow is the workflow under the test with the options of its executions.
+type Workflow struct {
+	t    testing.TB
+	wf   *emulator.Workflow
+	opts []emulator.Option
+}
+
+// Load loads the workflow file in YAML (.yaml) or JSON (.json), and fails the test if it's invalid.
+func Load(t testing.TB, filePath string, opts ...emulator.Option) *Workflow {
+	t.Helper()
+	wf, err := emulator.LoadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+	return &Workflow{t: t, wf: wf, opts: opts}
+}
+
+// ParseYAML parses the workflow definition in YAML written in the test, and fails the test if it's invalid.
+func ParseYAML(t testing.TB, source string, opts ...emulator.Option) *Workflow {
+	t.Helper()
+	wf, err := emulator.ParseYAML([]byte(source))
+	if err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	return &Workflow{t: t, wf: wf, opts: opts}
+}
+
+// Run executes the workflow with the arguments by the options of the workflow and the given ones.
+func (w *Workflow) Run(args any, opts ...emulator.Option) *Execution {
+	w.t.Helper()
+	return w.RunContext(context.Background(), args, opts...)
+}
+
+// RunContext executes the workflow as Run, which is aborted when the context is done.
+func (w *Workflow) RunContext(ctx context.Context, args any, opts ...emulator.Option) *Execution {
+	w.t.Helper()
+	ret, err := w.wf.Execute(ctx, args, append(w.opts[:len(w.opts):len(w.opts)], opts...)...)
+	return &Execution{t: w.t, Result: ret, Err: err}
+}
+
+// Execution is the finished execution of the workflow.
+type Execution struct {
+	t      testing.TB
+	Result any
+	Err    error // the uncaught exception is *emulator.Exception
+}
+
+// Exception returns the uncaught exception of the execution, or nil.
+func (e *Execution) Exception() *emulator.Exception {
+	exception, _ := emulator.AsException(e.Err)
+	return exception
+}
+
+// AssertSucceeded fails the test unless the execution succeeded.
+func (e *Execution) AssertSucceeded() *Execution {
+	e.t.Helper()
+	if exception := e.Exception(); exception != nil {
+		e.t.Fatalf("unexpected exception: %s", formatValue(exception.Value()))
+	} else if e.Err != nil {
+		e.t.Fatalf("failed to execute workflow: %v", e.Err)
+	}
+	return e
+}
+
+// AssertResult fails the test unless the execution succeeded with the result. The values are compared as JSON, so
+// the numbers of the different types are equal (e.g. 1 and int64(1)).
+func (e *Execution) AssertResult(want any) *Execution {
+	e.t.Helper()
+	e.AssertSucceeded()
+	if diff := cmp.Diff(normalize(want), normalize(e.Result)); diff != "" {
+		e.t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+	return e
+}
+
+// AssertException fails the test unless the execution raised the uncaught exception with all of the tags
+// (e.g. HttpError).
+func (e *Execution) AssertException(tags ...string) *Execution {
+	e.t.Helper()
+	exception := e.Exception()
+	if exception == nil {
+		if e.Err != nil {
+			e.t.Fatalf("failed to execute workflow: %v", e.Err)
+		}
+		e.t.Fatalf("expected an exception but succeeded with the result: %s", formatValue(e.Result))
+	}
+	for _, tag := range tags {
+		if !exception.HasTag(tag) {
+			e.t.Errorf("exception has no tag %s: %s", tag, formatValue(exception.Value()))
+		}
+	}
+	return e
+}
+
+// HandleHTTP serves the outbound HTTP requests of http.* and the connectors by the handler in process regardless of
+// their hosts, e.g. by http.ServeMux routing the paths to the fake responses.
+func HandleHTTP(handler http.Handler) emulator.Option {
+	return emulator.WithHTTPTransport(handlerTransport{handler: handler})
+}
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	res := rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+// FreezeTime starts each execution at the time of the fake clock read by sys.now, which is advanced only by the
+// sleeps and the backoffs of the retries without waiting for them.
+func FreezeTime(at time.Time) emulator.Option {
+	return emulator.WithFakeTime(at)
+}
+
+// normalize converts the value to the JSON values to compare it regardless of the types of the numbers.
+func normalize(value any) any {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+func formatValue(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
@@ -0,0 +1,289 @@
+package workflowtest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karupanerura/google-cloud-workflow-emulator/pkg/emulator"
+	"github.com/karupanerura/google-cloud-workflow-emulator/pkg/workflowtest"
+)
+
+// fakeT records the failures of the assertions instead of failing the test.
+type fakeT struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+	t.fatal = true
+	runtime.Goexit()
+}
+
+// runFakeT runs the function by the fakeT in the goroutine to be exited by Fatalf.
+func runFakeT(f func(t testing.TB)) *fakeT {
+	ft := &fakeT{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(ft)
+	}()
+	<-done
+	return ft
+}
+
+const greetWorkflow = `
+main:
+  params: [args]
+  steps:
+    - check:
+        switch:
+          - condition: ${not("name" in args)}
+            raise:
+              message: name is required
+              tags: [ValueError]
+    - done:
+        return:
+          message: ${"hello " + args.name}
+          length: ${len(args.name)}
+`
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name             string
+		run              func(wf *workflowtest.Workflow)
+		expectedFailure  string
+		expectedFatal    bool
+		expectedFailures int
+	}{
+		{
+			name: "result",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run(map[string]any{"name": "alice"}).AssertResult(map[string]any{"message": "hello alice", "length": 5})
+			},
+		},
+		{
+			name: "result mismatch",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run(map[string]any{"name": "alice"}).AssertResult(map[string]any{"message": "hello bob", "length": 5})
+			},
+			expectedFailure:  "result mismatch (-want +got):",
+			expectedFailures: 1,
+		},
+		{
+			name: "result of the exception",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run(map[string]any{}).AssertResult(map[string]any{})
+			},
+			expectedFailure:  `unexpected exception: {"message":"name is required","tags":["ValueError"]}`,
+			expectedFatal:    true,
+			expectedFailures: 1,
+		},
+		{
+			name: "exception",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run(map[string]any{}).AssertException("ValueError")
+			},
+		},
+		{
+			name: "exception without the tag",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run(map[string]any{}).AssertException("ValueError", "KeyError")
+			},
+			expectedFailure:  `exception has no tag KeyError: {"message":"name is required","tags":["ValueError"]}`,
+			expectedFailures: 1,
+		},
+		{
+			name: "exception of the success",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run(map[string]any{"name": "alice"}).AssertException("ValueError")
+			},
+			expectedFailure:  `expected an exception but succeeded with the result: {"length":5,"message":"hello alice"}`,
+			expectedFatal:    true,
+			expectedFailures: 1,
+		},
+		{
+			name: "succeeded",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run(map[string]any{"name": "alice"}).AssertSucceeded()
+			},
+		},
+		{
+			name: "succeeded of the exception",
+			run: func(wf *workflowtest.Workflow) {
+				wf.Run("not a map").AssertSucceeded()
+			},
+			expectedFailure:  "unexpected exception: ",
+			expectedFatal:    true,
+			expectedFailures: 1,
+		},
+		{
+			name: "cancelled",
+			run: func(wf *workflowtest.Workflow) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				wf.RunContext(ctx, map[string]any{"name": "alice"}).AssertException()
+			},
+			expectedFailure:  "failed to execute workflow: ",
+			expectedFatal:    true,
+			expectedFailures: 1,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ft := runFakeT(func(t testing.TB) {
+				tt.run(workflowtest.ParseYAML(t, greetWorkflow))
+			})
+			if len(ft.failures) != tt.expectedFailures {
+				t.Fatalf("unexpected failures: %q", ft.failures)
+			}
+			if ft.fatal != tt.expectedFatal {
+				t.Errorf("unexpected fatal: %t", ft.fatal)
+			}
+			if tt.expectedFailures != 0 && !strings.HasPrefix(ft.failures[0], tt.expectedFailure) {
+				t.Errorf("unexpected failure: %s, should start with %s", ft.failures[0], tt.expectedFailure)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/greet", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s from %s", r.URL.Query().Get("name"), r.Host)
+	})
+
+	for _, tt := range []struct {
+		name     string
+		source   string
+		opts     []emulator.Option
+		runOpts  []emulator.Option
+		expected any
+	}{
+		{
+			name: "handle HTTP",
+			source: `
+main:
+  steps:
+    - get:
+        call: http.get
+        args:
+          url: https://api.example.com/greet
+          query:
+            name: alice
+        result: res
+    - done:
+        return: ${text.decode(res.body)}
+`,
+			opts:     []emulator.Option{workflowtest.HandleHTTP(mux)},
+			expected: "hello alice from api.example.com",
+		},
+		{
+			name: "freeze time",
+			source: `
+main:
+  steps:
+    - sleep:
+        call: sys.sleep
+        args:
+          seconds: 60
+    - done:
+        return: ${sys.now()}
+`,
+			opts:     []emulator.Option{workflowtest.FreezeTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+			expected: 1704067260,
+		},
+		{
+			name: "options of the run",
+			source: `
+main:
+  steps:
+    - done:
+        return: ${sys.get_env("FOO", "none") + " " + sys.get_env("GOOGLE_CLOUD_PROJECT_ID", "none")}
+`,
+			opts:     []emulator.Option{emulator.WithEnv(map[string]string{"FOO": "bar"})},
+			runOpts:  []emulator.Option{emulator.WithExecutionInfo(emulator.ExecutionInfo{ProjectID: "my-project"})},
+			expected: "bar my-project",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			workflowtest.ParseYAML(t, tt.source, tt.opts...).Run(nil, tt.runOpts...).AssertResult(tt.expected)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"greet.yaml":   greetWorkflow,
+		"invalid.yaml": "main:\n  steps: 1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name            string
+		file            string
+		expectedFailure string
+	}{
+		{
+			name: "workflow",
+			file: "greet.yaml",
+		},
+		{
+			name:            "not found",
+			file:            "missing.yaml",
+			expectedFailure: "failed to load workflow: ",
+		},
+		{
+			name:            "invalid workflow",
+			file:            "invalid.yaml",
+			expectedFailure: "failed to load workflow: ",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ft := runFakeT(func(t testing.TB) {
+				workflowtest.Load(t, filepath.Join(dir, tt.file)).Run(map[string]any{"name": "alice"}).AssertResult(map[string]any{"message": "hello alice", "length": 5})
+			})
+			if tt.expectedFailure == "" {
+				if len(ft.failures) != 0 {
+					t.Errorf("unexpected failures: %q", ft.failures)
+				}
+				return
+			}
+			if !ft.fatal || len(ft.failures) != 1 || !strings.HasPrefix(ft.failures[0], tt.expectedFailure) {
+				t.Errorf("unexpected failures: %q, should fail by %s", ft.failures, tt.expectedFailure)
+			}
+		})
+	}
+}